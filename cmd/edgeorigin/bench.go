@@ -0,0 +1,467 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// benchOptions 压测选项
+type benchOptions struct {
+	duration    time.Duration
+	concurrency int
+	keys        int     // 键空间大小
+	reads       float64 // Get占全部操作的比例
+	size        int64   // 写入的文件大小
+	maxSize     int64   // 大于size时写入大小在[size, maxSize]内均匀分布
+	dist        string  // 键的分布：zipf或uniform
+	zipfS       float64 // zipf分布的参数s，越大访问越集中
+	seed        int64
+	ttl         time.Duration
+	prefill     bool
+	url         string // 不为空时压测HTTP前端，{n}替换为键的序号
+}
+
+// runBench 对缓存或HTTP前端压测，报告吞吐量和延迟分位数
+func runBench(ctx context.Context, cmd *command, env *env, args []string) error {
+	var t target
+	fs := cmd.flags(env, &t)
+	opts := benchOptions{size: 4 << 10}
+	fs.DurationVar(&opts.duration, "duration", 10*time.Second, "how long to run")
+	fs.IntVar(&opts.concurrency, "concurrency", 16, "number of concurrent workers")
+	fs.IntVar(&opts.keys, "keys", 10000, "number of distinct keys")
+	fs.Float64Var(&opts.reads, "reads", 0.9, "fraction of operations that are gets, the rest are sets")
+	fs.Var((*sizeFlag)(&opts.size), "size", "entry `size` such as 512, 4KB or 1MB")
+	fs.Var((*sizeFlag)(&opts.maxSize), "max-size", "if set, entry sizes are uniform between -size and this `size`")
+	fs.StringVar(&opts.dist, "dist", "zipf", "key distribution: zipf or uniform")
+	fs.Float64Var(&opts.zipfS, "zipf-s", 1.1, "zipf skew, must be greater than 1")
+	fs.Int64Var(&opts.seed, "seed", 1, "random seed, runs with the same seed issue the same key sequence per worker")
+	fs.DurationVar(&opts.ttl, "ttl", time.Hour, "ttl of written entries")
+	fs.BoolVar(&opts.prefill, "prefill", true, "write every key once before measuring so gets can hit")
+	fs.StringVar(&opts.url, "url", "", "benchmark GETs against an http `url` instead of a cache, {n} is replaced by the key number, e.g. http://127.0.0.1:8080/img/{n}.jpg")
+	if _, err := cmd.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	var op benchOp
+	if opts.url != "" {
+		if countSet(t.dir, t.configFile, t.remote) > 0 {
+			return errors.New("-url cannot be combined with -dir, -config or -remote")
+		}
+		op = httpOp(opts.url, &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: opts.concurrency}})
+	} else {
+		cache, closeCache, err := t.open()
+		if err != nil {
+			return err
+		}
+		defer closeCache()
+		data := make([]byte, opts.maxSize)
+		rand.New(rand.NewSource(opts.seed)).Read(data)
+		if opts.prefill {
+			logf(env, "writing %d keys...\n", opts.keys)
+			if err := prefill(ctx, cache, &opts, data); err != nil {
+				return err
+			}
+		}
+		op = cacheOp(cache, &opts, data)
+	}
+
+	logf(env, "running for %v with %d workers...\n", opts.duration, opts.concurrency)
+	result := bench(ctx, &opts, op)
+	if env.json() {
+		return writeJSON(env.stdout, result.summary())
+	}
+	return result.print(env.stdout)
+}
+
+// logf 把进度写到标准错误，-output json时不打印，标准错误上只有JSON格式的错误
+func logf(env *env, format string, args ...interface{}) {
+	if !env.json() {
+		fmt.Fprintf(env.stderr, format, args...)
+	}
+}
+
+// validate 检查选项并填充默认值
+func (o *benchOptions) validate() error {
+	switch {
+	case o.duration <= 0:
+		return errors.New("-duration must be positive")
+	case o.concurrency <= 0:
+		return errors.New("-concurrency must be positive")
+	case o.keys <= 0:
+		return errors.New("-keys must be positive")
+	case o.reads < 0 || o.reads > 1:
+		return errors.New("-reads must be between 0 and 1")
+	case o.size <= 0:
+		return errors.New("-size must be positive")
+	case o.maxSize != 0 && o.maxSize < o.size:
+		return errors.New("-max-size must not be smaller than -size")
+	case o.dist != "zipf" && o.dist != "uniform":
+		return fmt.Errorf("unknown distribution %q", o.dist)
+	case o.dist == "zipf" && o.zipfS <= 1:
+		return errors.New("-zipf-s must be greater than 1")
+	}
+	if o.maxSize == 0 {
+		o.maxSize = o.size
+	}
+	return nil
+}
+
+// benchKey 返回第n个键
+func benchKey(n int) string {
+	return fmt.Sprintf("bench/%08d", n)
+}
+
+// keyPicker 返回worker按分布选择键序号的函数
+func (o *benchOptions) keyPicker(rng *rand.Rand) func() int {
+	if o.dist == "uniform" {
+		return func() int { return rng.Intn(o.keys) }
+	}
+	zipf := rand.NewZipf(rng, o.zipfS, 1, uint64(o.keys-1))
+	return func() int { return int(zipf.Uint64()) }
+}
+
+// entrySize 返回一次写入的大小
+func (o *benchOptions) entrySize(rng *rand.Rand) int64 {
+	if o.maxSize == o.size {
+		return o.size
+	}
+	return o.size + rng.Int63n(o.maxSize-o.size+1)
+}
+
+// prefill 并发写入所有的键
+func prefill(ctx context.Context, cache filecache.Cache, opts *benchOptions, data []byte) error {
+	next := make(chan int)
+	errs := make(chan error, opts.concurrency)
+	var wg sync.WaitGroup
+	for w := 0; w < opts.concurrency; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(opts.seed - int64(w) - 1))
+			for n := range next {
+				size := opts.entrySize(rng)
+				if err := cache.Set(ctx, benchKey(n), bytes.NewReader(data[:size]), "application/octet-stream", opts.ttl); err != nil {
+					errs <- fmt.Errorf("failed to write %s: %w", benchKey(n), err)
+					return
+				}
+			}
+		}(w)
+	}
+
+	var err error
+feed:
+	for n := 0; n < opts.keys; n++ {
+		select {
+		case next <- n:
+		case err = <-errs:
+			break feed
+		case <-ctx.Done():
+			err = ctx.Err()
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+	select {
+	case err = <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+// benchOp 执行一次操作，返回操作名、传输的字节数和是否未命中
+type benchOp func(ctx context.Context, rng *rand.Rand, pick func() int) (name string, n int64, miss bool, err error)
+
+// cacheOp 按比例对缓存执行Get和Set
+func cacheOp(cache filecache.Cache, opts *benchOptions, data []byte) benchOp {
+	return func(ctx context.Context, rng *rand.Rand, pick func() int) (string, int64, bool, error) {
+		key := benchKey(pick())
+		if rng.Float64() >= opts.reads {
+			size := opts.entrySize(rng)
+			return "set", size, false, cache.Set(ctx, key, bytes.NewReader(data[:size]), "application/octet-stream", opts.ttl)
+		}
+		reader, _, err := cache.Get(ctx, key)
+		if errors.Is(err, filecache.ErrNotFound) {
+			return "get", 0, true, nil
+		}
+		if err != nil {
+			return "get", 0, false, err
+		}
+		defer reader.Close()
+		n, err := io.Copy(io.Discard, reader)
+		return "get", n, false, err
+	}
+}
+
+// httpOp 对HTTP前端执行GET，X-Cache不为HIT的响应计为未命中
+func httpOp(url string, client *http.Client) benchOp {
+	if !strings.Contains(url, "{n}") {
+		url = strings.TrimSuffix(url, "/") + "/{n}"
+	}
+	return func(ctx context.Context, rng *rand.Rand, pick func() int) (string, int64, bool, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.ReplaceAll(url, "{n}", strconv.Itoa(pick())), nil)
+		if err != nil {
+			return "get", 0, false, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return "get", 0, false, err
+		}
+		defer resp.Body.Close()
+		n, err := io.Copy(io.Discard, resp.Body)
+		if err == nil && resp.StatusCode >= 400 {
+			err = fmt.Errorf("status %s", resp.Status)
+		}
+		return "get", n, !strings.HasPrefix(resp.Header.Get("X-Cache"), "HIT"), err
+	}
+}
+
+// opStats 一种操作的统计
+type opStats struct {
+	latencies []time.Duration
+	bytes     int64
+	misses    int64
+	errors    int64
+	lastErr   error
+}
+
+// merge 合并另一个worker的统计
+func (s *opStats) merge(other *opStats) {
+	s.latencies = append(s.latencies, other.latencies...)
+	s.bytes += other.bytes
+	s.misses += other.misses
+	s.errors += other.errors
+	if other.lastErr != nil {
+		s.lastErr = other.lastErr
+	}
+}
+
+// percentile 返回已排序的延迟中第p（0到1之间）分位的值
+func (s *opStats) percentile(p float64) time.Duration {
+	if len(s.latencies) == 0 {
+		return 0
+	}
+	i := int(float64(len(s.latencies))*p+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(s.latencies) {
+		i = len(s.latencies) - 1
+	}
+	return s.latencies[i]
+}
+
+// benchResult 压测结果
+type benchResult struct {
+	elapsed time.Duration
+	ops     map[string]*opStats
+}
+
+// bench 用opts.concurrency个worker在opts.duration内反复执行op
+func bench(ctx context.Context, opts *benchOptions, op benchOp) *benchResult {
+	ctx, cancel := context.WithTimeout(ctx, opts.duration)
+	defer cancel()
+
+	workers := make([]map[string]*opStats, opts.concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := range workers {
+		stats := make(map[string]*opStats)
+		workers[w] = stats
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(opts.seed + int64(w)))
+			pick := opts.keyPicker(rng)
+			for ctx.Err() == nil {
+				began := time.Now()
+				name, n, miss, err := op(ctx, rng, pick)
+				elapsed := time.Since(began)
+				if ctx.Err() != nil {
+					// 截止时被取消的操作不计入结果
+					return
+				}
+				s := stats[name]
+				if s == nil {
+					s = &opStats{}
+					stats[name] = s
+				}
+				if err != nil {
+					s.errors++
+					s.lastErr = err
+					continue
+				}
+				s.latencies = append(s.latencies, elapsed)
+				s.bytes += n
+				if miss {
+					s.misses++
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	result := &benchResult{elapsed: time.Since(start), ops: make(map[string]*opStats)}
+	for _, stats := range workers {
+		for name, s := range stats {
+			if result.ops[name] == nil {
+				result.ops[name] = &opStats{}
+			}
+			result.ops[name].merge(s)
+		}
+	}
+	for _, s := range result.ops {
+		sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+	}
+	return result
+}
+
+// opSummary 一种操作的吞吐量和延迟分位数，延迟以time.Duration的格式表示
+type opSummary struct {
+	Op        string  `json:"op"`
+	Ops       int     `json:"ops"`
+	OpsPerSec float64 `json:"ops_per_sec"`
+	MBPerSec  float64 `json:"mb_per_sec"`
+	MissRate  float64 `json:"miss_rate"` // 只统计get，其他操作为0
+	Errors    int64   `json:"errors"`
+	Mean      string  `json:"mean"`
+	P50       string  `json:"p50"`
+	P90       string  `json:"p90"`
+	P99       string  `json:"p99"`
+	P999      string  `json:"p99_9"`
+	Max       string  `json:"max"`
+	LastError string  `json:"last_error,omitempty"`
+}
+
+// benchSummary -output json时的压测结果
+type benchSummary struct {
+	Elapsed string      `json:"elapsed"`
+	Ops     []opSummary `json:"ops"`
+}
+
+// summary 按操作名称的顺序汇总每种操作的结果
+func (r *benchResult) summary() *benchSummary {
+	names := make([]string, 0, len(r.ops))
+	for name := range r.ops {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	seconds := r.elapsed.Seconds()
+	summary := &benchSummary{Elapsed: r.elapsed.Round(time.Millisecond).String(), Ops: []opSummary{}}
+	for _, name := range names {
+		s := r.ops[name]
+		count := len(s.latencies)
+		var total time.Duration
+		for _, l := range s.latencies {
+			total += l
+		}
+		mean := time.Duration(0)
+		if count > 0 {
+			mean = total / time.Duration(count)
+		}
+		op := opSummary{
+			Op:        name,
+			Ops:       count,
+			OpsPerSec: float64(count) / seconds,
+			MBPerSec:  float64(s.bytes) / seconds / (1 << 20),
+			Errors:    s.errors,
+			Mean:      roundLatency(mean).String(),
+			P50:       roundLatency(s.percentile(0.5)).String(),
+			P90:       roundLatency(s.percentile(0.9)).String(),
+			P99:       roundLatency(s.percentile(0.99)).String(),
+			P999:      roundLatency(s.percentile(0.999)).String(),
+			Max:       roundLatency(s.percentile(1)).String(),
+		}
+		if name == "get" && count > 0 {
+			op.MissRate = float64(s.misses) / float64(count)
+		}
+		if s.lastErr != nil {
+			op.LastError = s.lastErr.Error()
+		}
+		summary.Ops = append(summary.Ops, op)
+	}
+	return summary
+}
+
+// print 输出每种操作的吞吐量和延迟分位数，有操作出错时在表格之后列出每种操作的最后一个错误
+func (r *benchResult) print(w io.Writer) error {
+	summary := r.summary()
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "OP\tOPS\tOPS/S\tMB/S\tMISS\tERRORS\tMEAN\tP50\tP90\tP99\tP99.9\tMAX")
+	for _, op := range summary.Ops {
+		miss := "-"
+		if op.Op == "get" && op.Ops > 0 {
+			miss = fmt.Sprintf("%.1f%%", op.MissRate*100)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%.0f\t%.1f\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n", op.Op, op.Ops, op.OpsPerSec,
+			op.MBPerSec, miss, op.Errors, op.Mean, op.P50, op.P90, op.P99, op.P999, op.Max)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, op := range summary.Ops {
+		if op.LastError != "" {
+			fmt.Fprintf(w, "last %s error: %s\n", op.Op, op.LastError)
+		}
+	}
+	return nil
+}
+
+// roundLatency 把延迟舍入到3位有效数字左右
+func roundLatency(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(time.Microsecond)
+	}
+	return d
+}
+
+// sizeFlag 带单位的大小选项，按1024换算
+type sizeFlag int64
+
+func (f *sizeFlag) String() string {
+	return formatSize(int64(*f))
+}
+
+func (f *sizeFlag) Set(s string) error {
+	units := []struct {
+		suffix string
+		scale  int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"B", 1}}
+	upper, scale := strings.ToUpper(strings.TrimSpace(s)), int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(upper, u.suffix) {
+			upper, scale = strings.TrimSuffix(upper, u.suffix), u.scale
+			break
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(upper), 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q", s)
+	}
+	*f = sizeFlag(n * scale)
+	return nil
+}
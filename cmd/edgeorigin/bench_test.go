@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestBench(t *testing.T) {
+	t.Run("cache", func(t *testing.T) {
+		code, stdout, stderr := execute(t, "", "bench", "-dir", t.TempDir(), "-duration", "300ms", "-concurrency", "4",
+			"-keys", "50", "-size", "1KB", "-max-size", "2KB", "-reads", "1")
+		if code != 0 {
+			t.Fatalf("Failed to run bench: %s", stderr)
+		}
+		if !strings.Contains(stdout, "P99.9") || !strings.Contains(stdout, "get ") {
+			t.Errorf("Expected a latency table for gets, got:\n%s", stdout)
+		}
+		if !strings.Contains(stdout, " 0.0% ") {
+			t.Errorf("Expected prefilled keys to hit, got:\n%s", stdout)
+		}
+	})
+
+	t.Run("http", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasPrefix(r.URL.Path, "/img/") || !strings.HasSuffix(r.URL.Path, ".jpg") {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("X-Cache", "HIT")
+			w.Write([]byte("data"))
+		}))
+		defer srv.Close()
+
+		code, stdout, stderr := execute(t, "", "bench", "-url", srv.URL+"/img/{n}.jpg", "-duration", "200ms", "-concurrency", "2", "-dist", "uniform")
+		if code != 0 || !strings.Contains(stdout, " 0.0% ") || strings.Contains(stdout, "last get error") {
+			t.Errorf("Expected cache hits without errors, got %d:\n%s%s", code, stdout, stderr)
+		}
+
+		var summary benchSummary
+		code, stdout, stderr = execute(t, "", "bench", "-url", srv.URL+"/img/{n}.jpg", "-duration", "100ms", "-output", "json")
+		decodeJSON(t, stdout, &summary)
+		if code != 0 || stderr != "" || len(summary.Ops) != 1 || summary.Ops[0].Op != "get" || summary.Ops[0].Ops == 0 || summary.Ops[0].LastError != "" {
+			t.Errorf("Expected the results as json, got %d %s %s", code, stdout, stderr)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"-dir", t.TempDir(), "-reads", "2"},
+			{"-dir", t.TempDir(), "-dist", "pareto"},
+			{"-dir", t.TempDir(), "-zipf-s", "1"},
+			{"-dir", t.TempDir(), "-size", "4KB", "-max-size", "1KB"},
+			{"-dir", t.TempDir(), "-url", "http://127.0.0.1:1"},
+		} {
+			if code, _, _ := execute(t, "", append([]string{"bench"}, args...)...); code != 1 {
+				t.Errorf("Expected exit code 1 for %v, got %d", args, code)
+			}
+		}
+	})
+}
+
+func TestBenchOps(t *testing.T) {
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	opts := &benchOptions{duration: 100 * time.Millisecond, concurrency: 2, keys: 10, reads: 0.5, size: 8, dist: "zipf", zipfS: 1.5, seed: 1, ttl: time.Hour}
+	if err := opts.validate(); err != nil {
+		t.Fatalf("Failed to validate options: %v", err)
+	}
+
+	result := bench(context.Background(), opts, cacheOp(cache, opts, make([]byte, 8)))
+	get, set := result.ops["get"], result.ops["set"]
+	if get == nil || set == nil || len(get.latencies) == 0 || len(set.latencies) == 0 {
+		t.Fatalf("Expected both gets and sets, got %+v", result.ops)
+	}
+	for i := 1; i < len(get.latencies); i++ {
+		if get.latencies[i] < get.latencies[i-1] {
+			t.Fatal("Expected sorted latencies")
+		}
+	}
+	if get.percentile(0.5) > get.percentile(0.99) || get.percentile(1) != get.latencies[len(get.latencies)-1] {
+		t.Errorf("Unexpected percentiles: p50 %v p99 %v max %v", get.percentile(0.5), get.percentile(0.99), get.percentile(1))
+	}
+
+	t.Run("Reproducible", func(t *testing.T) {
+		pick := func() []int {
+			next := opts.keyPicker(rand.New(rand.NewSource(7)))
+			keys := make([]int, 20)
+			for i := range keys {
+				keys[i] = next()
+				if keys[i] < 0 || keys[i] >= opts.keys {
+					t.Fatalf("Key %d out of range", keys[i])
+				}
+			}
+			return keys
+		}
+		a, b := pick(), pick()
+		for i := range a {
+			if a[i] != b[i] {
+				t.Fatalf("Expected the same key sequence for the same seed, got %v and %v", a, b)
+			}
+		}
+	})
+
+	t.Run("Size", func(t *testing.T) {
+		for in, want := range map[string]int64{"512": 512, "4KB": 4 << 10, "1m": 1 << 20, "2G": 2 << 30} {
+			var f sizeFlag
+			if err := f.Set(in); err != nil || int64(f) != want {
+				t.Errorf("Expected %s to be %d, got %d %v", in, want, f, err)
+			}
+		}
+		var f sizeFlag
+		if err := f.Set("lots"); err == nil {
+			t.Error("Expected error for invalid size")
+		}
+	})
+}
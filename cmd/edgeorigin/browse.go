@@ -0,0 +1,578 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// previewSize 详情页预览读取的最大字节数
+const previewSize = 64 << 10
+
+// runBrowse 在终端中交互式地浏览缓存
+func runBrowse(ctx context.Context, cmd *command, env *env, args []string) error {
+	var t target
+	fs := cmd.flags(env, &t)
+	prefix := fs.String("prefix", "", "only browse keys starting with `prefix`")
+	if _, err := cmd.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if err := env.textOnly(cmd); err != nil {
+		return err
+	}
+	in, ok := env.stdin.(*os.File)
+	if !ok || !term.IsTerminal(int(in.Fd())) {
+		return errors.New("browse requires a terminal")
+	}
+	out, ok := env.stdout.(*os.File)
+	if !ok || !term.IsTerminal(int(out.Fd())) {
+		return errors.New("browse requires a terminal")
+	}
+
+	cache, closeCache, err := t.open()
+	if err != nil {
+		return err
+	}
+	defer closeCache()
+
+	b := newBrowser(ctx, cache, *prefix)
+	if err := b.load(); err != nil {
+		return err
+	}
+
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(in.Fd()), state)
+	// 切换到备用屏幕并隐藏光标，退出时恢复
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+
+	keys := bufio.NewReader(in)
+	for {
+		width, height, err := term.GetSize(int(out.Fd()))
+		if err != nil {
+			width, height = 80, 24
+		}
+		var frame strings.Builder
+		b.render(&frame, width, height)
+		if _, err := io.WriteString(out, frame.String()); err != nil {
+			return err
+		}
+
+		k, err := readKey(keys)
+		if err != nil {
+			return err
+		}
+		if b.handle(k) {
+			return nil
+		}
+	}
+}
+
+// keypress 按键，普通字符为其本身，特殊键为下面的常量
+type keypress rune
+
+const (
+	keyUp keypress = -1 - iota
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyEnter
+	keyEscape
+	keyBackspace
+	keyInterrupt
+)
+
+// readKey 从原始模式的终端输入中读取一个按键，不认识的转义序列被忽略
+func readKey(r *bufio.Reader) (keypress, error) {
+	c, _, err := r.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	switch c {
+	case '\r', '\n':
+		return keyEnter, nil
+	case 127, '\b':
+		return keyBackspace, nil
+	case 3:
+		return keyInterrupt, nil
+	case 0x1b:
+	default:
+		return keypress(c), nil
+	}
+
+	// 单独的Esc后面没有缓冲的输入
+	if r.Buffered() == 0 {
+		return keyEscape, nil
+	}
+	if next, _ := r.ReadByte(); next != '[' && next != 'O' {
+		return keyEscape, nil
+	}
+	seq := ""
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		seq += string(b)
+		if b >= 0x40 && b <= 0x7e {
+			break
+		}
+	}
+	switch seq {
+	case "A":
+		return keyUp, nil
+	case "B":
+		return keyDown, nil
+	case "5~":
+		return keyPageUp, nil
+	case "6~":
+		return keyPageDown, nil
+	case "H", "1~", "7~":
+		return keyHome, nil
+	case "F", "4~", "8~":
+		return keyEnd, nil
+	}
+	return readKey(r)
+}
+
+// browser 浏览界面的状态，按键由handle处理，render绘制整个屏幕
+type browser struct {
+	ctx    context.Context
+	cache  filecache.Cache
+	prefix string
+
+	files   []*filecache.FileInfo // 满足prefix和filter的文件，按键排序
+	all     []*filecache.FileInfo
+	cursor  int
+	offset  int // 列表第一行显示的文件
+	page    int // 上一次绘制时列表的行数，用于翻页
+	filter  string
+	editing bool   // 正在输入过滤条件
+	confirm string // 等待确认删除的键
+	message string // 状态栏显示的一次性消息
+
+	detail  *filecache.FileInfo // 不为nil时显示详情页
+	preview []string
+	scroll  int
+}
+
+// newBrowser 创建浏览界面，只显示以prefix开头的键
+func newBrowser(ctx context.Context, cache filecache.Cache, prefix string) *browser {
+	return &browser{ctx: ctx, cache: cache, prefix: prefix, page: 10}
+}
+
+// load 重新列出文件，尽量保持光标所在的键
+func (b *browser) load() error {
+	files, err := b.cache.List(b.ctx)
+	if err != nil {
+		return err
+	}
+	b.all = b.all[:0]
+	for _, info := range files {
+		if strings.HasPrefix(info.Key, b.prefix) {
+			b.all = append(b.all, info)
+		}
+	}
+	sort.Slice(b.all, func(i, j int) bool { return b.all[i].Key < b.all[j].Key })
+	b.applyFilter()
+	return nil
+}
+
+// applyFilter 按过滤条件筛选文件
+func (b *browser) applyFilter() {
+	current := b.selected()
+	b.files = b.files[:0]
+	for _, info := range b.all {
+		if strings.Contains(info.Key, b.filter) {
+			b.files = append(b.files, info)
+		}
+	}
+	b.cursor = 0
+	if current != nil {
+		i := sort.Search(len(b.files), func(i int) bool { return b.files[i].Key >= current.Key })
+		b.cursor = i
+	}
+	b.move(0)
+}
+
+// selected 返回光标所在的文件
+func (b *browser) selected() *filecache.FileInfo {
+	if b.cursor < 0 || b.cursor >= len(b.files) {
+		return nil
+	}
+	return b.files[b.cursor]
+}
+
+// move 移动光标并限制在列表范围内
+func (b *browser) move(delta int) {
+	b.cursor += delta
+	if b.cursor >= len(b.files) {
+		b.cursor = len(b.files) - 1
+	}
+	if b.cursor < 0 {
+		b.cursor = 0
+	}
+}
+
+// handle 处理一个按键，返回是否退出
+func (b *browser) handle(k keypress) bool {
+	b.message = ""
+	if k == keyInterrupt {
+		return true
+	}
+
+	if b.confirm != "" {
+		name := b.confirm
+		b.confirm = ""
+		if k == 'y' || k == 'Y' {
+			b.delete(name)
+		} else {
+			b.message = "delete cancelled"
+		}
+		return false
+	}
+
+	if b.editing {
+		switch k {
+		case keyEnter:
+			b.editing = false
+		case keyEscape:
+			b.editing = false
+			b.filter = ""
+		case keyBackspace:
+			if b.filter != "" {
+				_, size := utf8.DecodeLastRuneInString(b.filter)
+				b.filter = b.filter[:len(b.filter)-size]
+			}
+		default:
+			if k >= ' ' {
+				b.filter += string(rune(k))
+			}
+		}
+		b.applyFilter()
+		return false
+	}
+
+	if b.detail != nil {
+		switch k {
+		case 'q', keyEscape, keyBackspace, 'h':
+			b.detail = nil
+		case keyUp, 'k':
+			if b.scroll > 0 {
+				b.scroll--
+			}
+		case keyDown, 'j':
+			if b.scroll < len(b.preview)-1 {
+				b.scroll++
+			}
+		case 'd':
+			b.confirm = b.detail.Key
+		case 'p':
+			b.pin(b.detail.Key)
+		}
+		return false
+	}
+
+	switch k {
+	case 'q', keyEscape:
+		return true
+	case keyUp, 'k':
+		b.move(-1)
+	case keyDown, 'j':
+		b.move(1)
+	case keyPageUp:
+		b.move(-b.page)
+	case keyPageDown, ' ':
+		b.move(b.page)
+	case keyHome, 'g':
+		b.move(-len(b.files))
+	case keyEnd, 'G':
+		b.move(len(b.files))
+	case keyEnter, 'l':
+		if info := b.selected(); info != nil {
+			b.open(info.Key)
+		}
+	case '/':
+		b.editing = true
+	case 'd':
+		if info := b.selected(); info != nil {
+			b.confirm = info.Key
+		}
+	case 'p':
+		if info := b.selected(); info != nil {
+			b.pin(info.Key)
+		}
+	case 'r':
+		if err := b.load(); err != nil {
+			b.message = err.Error()
+		} else {
+			b.message = fmt.Sprintf("%d entries", len(b.all))
+		}
+	}
+	return false
+}
+
+// open 打开文件的详情页，文本文件读取开头的previewSize字节作为预览
+func (b *browser) open(key string) {
+	info, err := b.cache.GetInfo(b.ctx, key)
+	if err != nil {
+		b.message = fmt.Sprintf("%s: %v", key, err)
+		return
+	}
+	b.detail, b.preview, b.scroll = info, nil, 0
+
+	reader, _, err := b.cache.Get(b.ctx, key)
+	if err != nil {
+		b.preview = []string{fmt.Sprintf("(failed to read: %v)", err)}
+		return
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(io.LimitReader(reader, previewSize))
+	if err != nil {
+		b.preview = []string{fmt.Sprintf("(failed to read: %v)", err)}
+		return
+	}
+	if !isText(info.MimeType, data) {
+		b.preview = []string{fmt.Sprintf("(binary, %s)", formatSize(info.Size))}
+		return
+	}
+	b.preview = strings.Split(strings.TrimRight(sanitize(string(data)), "\n"), "\n")
+	if info.Size > int64(len(data)) {
+		b.preview = append(b.preview, fmt.Sprintf("... (%s more)", formatSize(info.Size-int64(len(data)))))
+	}
+}
+
+// delete 删除文件并刷新列表
+func (b *browser) delete(key string) {
+	if err := b.cache.Delete(b.ctx, key); err != nil {
+		b.message = fmt.Sprintf("%s: %v", key, err)
+		return
+	}
+	b.detail = nil
+	if err := b.load(); err != nil {
+		b.message = err.Error()
+		return
+	}
+	b.message = "deleted " + key
+}
+
+// pin 把文件改为永不过期
+func (b *browser) pin(key string) {
+	if err := pin(b.ctx, b.cache, key); err != nil {
+		b.message = fmt.Sprintf("%s: %v", key, err)
+		return
+	}
+	if err := b.load(); err != nil {
+		b.message = err.Error()
+		return
+	}
+	if b.detail != nil {
+		if info, err := b.cache.GetInfo(b.ctx, key); err == nil {
+			b.detail = info
+		}
+	}
+	b.message = "pinned " + key
+}
+
+// pin 用filecache.NoExpiry重新写入文件使其永不过期；缓存支持条件写入时只在文件没有被并发修改时写入
+func pin(ctx context.Context, cache filecache.Cache, key string) error {
+	reader, info, err := cache.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if info.NeverExpires() {
+		return nil
+	}
+	if cas, ok := cache.(filecache.ConditionalSetter); ok && info.Version != 0 {
+		swapped, err := cas.CompareAndSwap(ctx, key, info.Version, reader, info.MimeType, filecache.NoExpiry)
+		if err != nil {
+			return err
+		}
+		if !swapped {
+			return errors.New("entry changed while pinning, try again")
+		}
+		return nil
+	}
+	return cache.Set(ctx, key, reader, info.MimeType, filecache.NoExpiry)
+}
+
+// isText 返回文件是否可以作为文本预览
+func isText(mimeType string, data []byte) bool {
+	// 预览可能在多字节字符中间截断，忽略末尾不完整的字符
+	valid := data
+	for i := 1; i < utf8.UTFMax && len(valid) > 0 && !utf8.Valid(valid); i++ {
+		valid = valid[:len(valid)-1]
+	}
+	if !utf8.Valid(valid) {
+		return false
+	}
+	for _, t := range []string{mimeType, http.DetectContentType(data)} {
+		if strings.HasPrefix(t, "text/") || strings.Contains(t, "json") || strings.Contains(t, "xml") ||
+			strings.Contains(t, "javascript") || strings.Contains(t, "yaml") {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitize 展开制表符并替换控制字符，避免破坏终端显示
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n':
+			return r
+		case r < ' ' || r == 0x7f || r == utf8.RuneError:
+			return '?'
+		}
+		return r
+	}, strings.ReplaceAll(s, "\t", "    "))
+}
+
+// render 绘制整个屏幕，width和height为终端的列数和行数
+func (b *browser) render(w io.Writer, width, height int) {
+	var lines []string
+	if b.detail != nil {
+		lines = b.renderDetail(height - 1)
+	} else {
+		lines = b.renderList(height - 1)
+	}
+
+	fmt.Fprint(w, "\x1b[H\x1b[2J")
+	for _, line := range lines {
+		fmt.Fprint(w, fit(line, width), "\r\n")
+	}
+	for i := len(lines); i < height-1; i++ {
+		fmt.Fprint(w, "\r\n")
+	}
+	fmt.Fprint(w, "\x1b[7m", pad(fit(b.status(), width), width), "\x1b[0m")
+}
+
+// renderList 返回列表页的各行，第一行为标题
+func (b *browser) renderList(height int) []string {
+	title := fmt.Sprintf("edgeorigin browse  %d/%d entries", len(b.files), len(b.all))
+	if b.prefix != "" {
+		title += fmt.Sprintf("  prefix: %s", b.prefix)
+	}
+	if b.filter != "" || b.editing {
+		title += fmt.Sprintf("  filter: %s", b.filter)
+	}
+	lines := []string{title, fmt.Sprintf("  %-8s %-10s %-24s %s", "SIZE", "EXPIRES", "TYPE", "KEY")}
+
+	b.page = height - len(lines)
+	if b.page < 1 {
+		b.page = 1
+	}
+	if b.cursor < b.offset {
+		b.offset = b.cursor
+	}
+	if b.cursor >= b.offset+b.page {
+		b.offset = b.cursor - b.page + 1
+	}
+
+	now := time.Now()
+	for i := b.offset; i < len(b.files) && i < b.offset+b.page; i++ {
+		info := b.files[i]
+		line := fmt.Sprintf("  %-8s %-10s %-24s %s", formatSize(info.Size), formatExpiry(info, now), fit(info.MimeType, 24), sanitize(info.Key))
+		if i == b.cursor {
+			line = "\x1b[7m>" + line[1:] + "\x1b[0m"
+		}
+		lines = append(lines, line)
+	}
+	if len(b.files) == 0 {
+		lines = append(lines, "  (no entries)")
+	}
+	return lines
+}
+
+// renderDetail 返回详情页的各行：文件信息和预览
+func (b *browser) renderDetail(height int) []string {
+	info := b.detail
+	expires := "never"
+	if !info.NeverExpires() {
+		expires = fmt.Sprintf("%s (%s)", formatTime(info.ExpiresAt), formatExpiry(info, time.Now()))
+	}
+	lines := []string{
+		"key:         " + sanitize(info.Key),
+		fmt.Sprintf("size:        %s (%d bytes)", formatSize(info.Size), info.Size),
+		"type:        " + info.MimeType,
+		"created:     " + formatTime(info.CreatedAt),
+		"expires:     " + expires,
+		fmt.Sprintf("accesses:    %d", info.AccessCount),
+		"last access: " + formatTime(info.LastAccess),
+		"",
+	}
+	end := b.scroll + height - len(lines)
+	if end > len(b.preview) {
+		end = len(b.preview)
+	}
+	if b.scroll < end {
+		lines = append(lines, b.preview[b.scroll:end]...)
+	}
+	return lines
+}
+
+// status 返回底部状态栏：确认提示、消息或按键说明
+func (b *browser) status() string {
+	switch {
+	case b.confirm != "":
+		return fmt.Sprintf("delete %s? (y/n)", sanitize(b.confirm))
+	case b.editing:
+		return "filter: " + b.filter + "_  (enter apply, esc clear)"
+	case b.message != "":
+		return b.message
+	case b.detail != nil:
+		return "j/k scroll  d delete  p pin  q back"
+	}
+	return "j/k move  enter view  / filter  d delete  p pin  r refresh  q quit"
+}
+
+// fit 把s截断到width列，不计ANSI转义序列
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	cols, escape := 0, false
+	for i, r := range s {
+		switch {
+		case escape:
+			escape = r < 0x40 || r > 0x7e || r == '['
+		case r == 0x1b:
+			escape = true
+		default:
+			if cols == width {
+				if strings.Contains(s[i:], "\x1b[0m") {
+					return s[:i] + "\x1b[0m"
+				}
+				return s[:i]
+			}
+			cols++
+		}
+	}
+	return s
+}
+
+// pad 用空格把s补足到width列
+func pad(s string, width int) string {
+	if n := width - utf8.RuneCountInString(s); n > 0 {
+		return s + strings.Repeat(" ", n)
+	}
+	return s
+}
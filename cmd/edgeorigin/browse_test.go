@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestBrowse(t *testing.T) {
+	ctx := context.Background()
+	cache, err := filecache.NewBadgerCache(&filecache.Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1 << 20,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+	for key, data := range map[string]string{
+		"static/app.css":  "body {\n\tmargin: 0;\n}\n",
+		"static/logo.png": "\x89PNG\r\n\x1a\n\x00\x00",
+		"other/readme":    "hello",
+	} {
+		if err := cache.Set(ctx, key, strings.NewReader(data), detectType("", key), time.Hour); err != nil {
+			t.Fatalf("Failed to set %s: %v", key, err)
+		}
+	}
+
+	b := newBrowser(ctx, cache, "static/")
+	if err := b.load(); err != nil {
+		t.Fatalf("Failed to load entries: %v", err)
+	}
+	screen := func() string {
+		var w strings.Builder
+		b.render(&w, 100, 20)
+		return w.String()
+	}
+	press := func(keys ...keypress) {
+		for _, k := range keys {
+			if b.handle(k) {
+				t.Fatalf("Unexpected quit on key %d", k)
+			}
+		}
+	}
+
+	t.Run("List", func(t *testing.T) {
+		s := screen()
+		if !strings.Contains(s, "2/2 entries") || !strings.Contains(s, "static/app.css") || strings.Contains(s, "other/readme") {
+			t.Errorf("Expected the two static entries, got:\n%s", s)
+		}
+		if strings.Count(s, "\r\n") != 19 {
+			t.Errorf("Expected the screen to fill 20 rows, got %d", strings.Count(s, "\r\n")+1)
+		}
+	})
+
+	t.Run("Preview", func(t *testing.T) {
+		press(keyEnter)
+		s := screen()
+		if !strings.Contains(s, "key:         static/app.css") || !strings.Contains(s, "    margin: 0;") {
+			t.Errorf("Expected info and text preview, got:\n%s", s)
+		}
+		press('q', keyDown, keyEnter)
+		if s := screen(); !strings.Contains(s, "(binary, 10B)") {
+			t.Errorf("Expected binary placeholder, got:\n%s", s)
+		}
+		press(keyEscape)
+	})
+
+	t.Run("Pin", func(t *testing.T) {
+		press('p')
+		info, err := cache.GetInfo(ctx, "static/logo.png")
+		if err != nil || !info.NeverExpires() {
+			t.Fatalf("Expected logo to be pinned, got %+v %v", info, err)
+		}
+		if s := screen(); !strings.Contains(s, "pinned static/logo.png") {
+			t.Errorf("Expected pinned message, got:\n%s", s)
+		}
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		press('/', 'c', 's', 's', keyEnter)
+		if len(b.files) != 1 || b.files[0].Key != "static/app.css" {
+			t.Errorf("Expected filter to keep app.css, got %d entries", len(b.files))
+		}
+		press('/', keyEscape)
+		if len(b.files) != 2 {
+			t.Errorf("Expected escape to clear the filter, got %d entries", len(b.files))
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		press('g', 'd', 'n')
+		if ok, _ := cache.Exists(ctx, "static/app.css"); !ok {
+			t.Fatal("Expected delete to be cancelled")
+		}
+		press('d')
+		if s := screen(); !strings.Contains(s, "delete static/app.css? (y/n)") {
+			t.Errorf("Expected confirmation prompt, got:\n%s", s)
+		}
+		press('y')
+		if ok, _ := cache.Exists(ctx, "static/app.css"); ok {
+			t.Error("Expected app.css to be deleted")
+		}
+		if sel := b.selected(); sel == nil || sel.Key != "static/logo.png" {
+			t.Errorf("Expected cursor to move to the next entry, got %+v", sel)
+		}
+	})
+
+	t.Run("Quit", func(t *testing.T) {
+		if !b.handle('q') {
+			t.Error("Expected q to quit")
+		}
+	})
+}
+
+func TestReadKey(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("j\x1b[A\x1b[6~\r\x1b[Z\x7fé"))
+	want := []keypress{'j', keyUp, keyPageDown, keyEnter, keyBackspace, 'é'}
+	for _, w := range want {
+		k, err := readKey(r)
+		if err != nil {
+			t.Fatalf("Failed to read key: %v", err)
+		}
+		if k != w {
+			t.Errorf("Expected key %d, got %d", w, k)
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	if got := fit("\x1b[7mabcdef\x1b[0m", 3); got != "\x1b[7mabc\x1b[0m" {
+		t.Errorf("Expected escapes to be kept and not counted, got %q", got)
+	}
+	if got := fit("abc", 5); got != "abc" {
+		t.Errorf("Expected short string unchanged, got %q", got)
+	}
+}
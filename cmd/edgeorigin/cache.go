@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/seraphico/EdgeOrigin/pkg/config"
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+	"github.com/seraphico/EdgeOrigin/pkg/server/admin"
+)
+
+// target 命令操作的缓存：本机的数据目录，或者运行中节点的管理接口
+type target struct {
+	dir        string
+	configFile string
+	remote     string
+	token      string
+	namespaces namespaceFlag
+}
+
+// namespaceFlag 可以重复的-namespace选项，依次进入嵌套的命名空间
+type namespaceFlag []string
+
+func (f *namespaceFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *namespaceFlag) Set(name string) error {
+	if name == "" {
+		return errors.New("namespace cannot be empty")
+	}
+	*f = append(*f, name)
+	return nil
+}
+
+// register 注册选择缓存的公共选项
+func (t *target) register(fs *flag.FlagSet) {
+	fs.StringVar(&t.dir, "dir", "", "local cache data directory (EDGEORIGIN_* environment variables apply)")
+	fs.StringVar(&t.configFile, "config", "", "open the local cache described by the `file`'s cache section")
+	fs.StringVar(&t.remote, "remote", "", "admin api `url` of a running node, e.g. http://127.0.0.1:9090/admin or unix:///run/edgeorigin/admin.sock (default $EDGEORIGIN_ADMIN_URL unless -dir or -config is set)")
+	fs.StringVar(&t.token, "token", os.Getenv("EDGEORIGIN_ADMIN_TOKEN"), "admin api token or api key (default $EDGEORIGIN_ADMIN_TOKEN)")
+	fs.Var(&t.namespaces, "namespace", "operate on the namespace `name`, repeat for nested namespaces (local caches only)")
+}
+
+// open 打开缓存，返回选中的命名空间和关闭函数。本机的Badger数据目录同时只能由一个进程打开，
+// 节点运行中时需要通过-remote访问
+func (t *target) open() (filecache.Cache, func() error, error) {
+	if t.dir == "" && t.configFile == "" && t.remote == "" {
+		t.remote = os.Getenv("EDGEORIGIN_ADMIN_URL")
+	}
+	var root filecache.Cache
+	switch set := countSet(t.dir, t.configFile, t.remote); {
+	case set == 0:
+		return nil, nil, errors.New("one of -dir, -config or -remote is required")
+	case set > 1:
+		return nil, nil, errors.New("-dir, -config and -remote cannot be combined")
+
+	case t.remote != "":
+		if len(t.namespaces) > 1 {
+			return nil, nil, errors.New("-remote supports a single -namespace")
+		}
+		client, err := admin.NewClient(t.remote, admin.ClientOptions{Token: t.token})
+		if err != nil {
+			return nil, nil, err
+		}
+		root = client
+
+	default:
+		cfg, err := t.localConfig()
+		if err != nil {
+			return nil, nil, err
+		}
+		if root, err = filecache.NewCacheWithConfig(cfg); err != nil {
+			return nil, nil, fmt.Errorf("failed to open cache in %s: %w", cfg.DataDir, err)
+		}
+	}
+
+	cache := root
+	for _, name := range t.namespaces {
+		cache = cache.Namespace(name)
+	}
+	return cache, root.Close, nil
+}
+
+// wholeDir 检查处理整个本机数据目录的命令的选项，args中的目录参数等同于-dir
+func (t *target) wholeDir(args []string) error {
+	if len(args) == 1 {
+		if t.dir != "" {
+			return errors.New("the directory argument and -dir cannot be combined")
+		}
+		t.dir = args[0]
+	}
+	switch {
+	case t.remote != "":
+		return errors.New("a local data directory is required, -remote is not supported")
+	case t.dir == "" && t.configFile == "":
+		return errors.New("a data directory or -config is required")
+	case len(t.namespaces) > 0:
+		return errors.New("the whole data directory is processed, -namespace is not supported")
+	}
+	return nil
+}
+
+// localConfig 返回本机缓存的配置；命令行工具不运行定时备份
+func (t *target) localConfig() (*filecache.Config, error) {
+	var cfg *filecache.Config
+	if t.configFile != "" {
+		c, err := config.Load(t.configFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg = &c.Cache
+	} else {
+		var err error
+		if cfg, err = filecache.LoadConfigFromEnv(); err != nil {
+			return nil, err
+		}
+		cfg.DataDir = t.dir
+	}
+	if _, err := os.Stat(cfg.DataDir); err != nil {
+		return nil, fmt.Errorf("cache data directory: %w", err)
+	}
+	cfg.Backup = nil
+	return cfg, nil
+}
+
+// countSet 返回非空字符串的个数
+func countSet(values ...string) int {
+	n := 0
+	for _, v := range values {
+		if v != "" {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// runGet 把文件内容写到标准输出或-o指定的文件；-output json时必须指定-o，标准输出上是文件信息
+func runGet(ctx context.Context, cmd *command, env *env, args []string) error {
+	var t target
+	fs := cmd.flags(env, &t)
+	output := fs.String("o", "", "write to `file` instead of stdout")
+	args, err := cmd.parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	if env.json() && *output == "" {
+		return errors.New("-output json needs -o, the content cannot share stdout with the json")
+	}
+
+	cache, closeCache, err := t.open()
+	if err != nil {
+		return err
+	}
+	defer closeCache()
+
+	reader, info, err := cache.Get(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if *output == "" {
+		_, err = io.Copy(env.stdout, reader)
+		return err
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, reader); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if env.json() {
+		return writeJSON(env.stdout, info)
+	}
+	return nil
+}
+
+// runPut 把文件或标准输入写入缓存，-output json时输出写入后的文件信息
+func runPut(ctx context.Context, cmd *command, env *env, args []string) error {
+	var t target
+	fs := cmd.flags(env, &t)
+	mimeType := fs.String("type", "", "MIME `type`, detected from the file or key extension by default")
+	ttlFlag := fs.String("ttl", "", "`ttl` such as 1h, or none to never expire (default: the cache's default ttl)")
+	args, err := cmd.parse(fs, args, 1, 2)
+	if err != nil {
+		return err
+	}
+	ttl, err := parseTTL(*ttlFlag)
+	if err != nil {
+		return err
+	}
+
+	key, data, name := args[0], env.stdin, ""
+	if len(args) == 2 && args[1] != "-" {
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		data, name = f, args[1]
+	}
+	if *mimeType == "" {
+		*mimeType = detectType(name, key)
+	}
+
+	cache, closeCache, err := t.open()
+	if err != nil {
+		return err
+	}
+	defer closeCache()
+	if err := cache.Set(ctx, key, data, *mimeType, ttl); err != nil {
+		return err
+	}
+	if !env.json() {
+		return nil
+	}
+	info, err := cache.GetInfo(ctx, key)
+	if err != nil {
+		return err
+	}
+	return writeJSON(env.stdout, info)
+}
+
+// parseTTL 解析-ttl选项，空字符串表示使用默认TTL
+func parseTTL(s string) (time.Duration, error) {
+	switch s {
+	case "":
+		return 0, nil
+	case "none":
+		return filecache.NoExpiry, nil
+	}
+	ttl, err := time.ParseDuration(s)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("invalid ttl %q", s)
+	}
+	return ttl, nil
+}
+
+// detectType 按文件名或键的扩展名返回MIME类型
+func detectType(name, key string) string {
+	for _, s := range []string{name, key} {
+		if t := mime.TypeByExtension(filepath.Ext(s)); t != "" {
+			return t
+		}
+	}
+	return "application/octet-stream"
+}
+
+// runRm 删除文件，不存在的键不算错误；某个键删除失败时继续删除其余的键。
+// -output json时输出已删除的键
+func runRm(ctx context.Context, cmd *command, env *env, args []string) error {
+	var t target
+	fs := cmd.flags(env, &t)
+	args, err := cmd.parse(fs, args, 1, -1)
+	if err != nil {
+		return err
+	}
+
+	cache, closeCache, err := t.open()
+	if err != nil {
+		return err
+	}
+	defer closeCache()
+
+	var errs []error
+	deleted := []string{}
+	for _, key := range args {
+		if err := cache.Delete(ctx, key); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		deleted = append(deleted, key)
+	}
+	if env.json() {
+		if err := writeJSON(env.stdout, map[string][]string{"deleted": deleted}); err != nil {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runLs 按键的顺序列出文件，-output json时输出文件信息的数组
+func runLs(ctx context.Context, cmd *command, env *env, args []string) error {
+	var t target
+	fs := cmd.flags(env, &t)
+	prefix := fs.String("prefix", "", "only list keys starting with `prefix`")
+	long := fs.Bool("l", false, "show size, expiry, access count and type")
+	expired := fs.Bool("expired", false, "only list expired entries")
+	limit := fs.Int("limit", 0, "list at most `n` entries, 0 for all")
+	if _, err := cmd.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	cache, closeCache, err := t.open()
+	if err != nil {
+		return err
+	}
+	defer closeCache()
+
+	files, err := cache.List(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	matched := files[:0]
+	for _, info := range files {
+		if strings.HasPrefix(info.Key, *prefix) && (!*expired || now.After(info.ExpiresAt)) {
+			matched = append(matched, info)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Key < matched[j].Key })
+	if *limit > 0 && len(matched) > *limit {
+		matched = matched[:*limit]
+	}
+
+	if env.json() {
+		if matched == nil {
+			matched = []*filecache.FileInfo{}
+		}
+		return writeJSON(env.stdout, matched)
+	}
+	if !*long {
+		for _, info := range matched {
+			fmt.Fprintln(env.stdout, info.Key)
+		}
+		return nil
+	}
+	w := tabwriter.NewWriter(env.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SIZE\tEXPIRES\tHITS\tTYPE\tKEY")
+	for _, info := range matched {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", formatSize(info.Size), formatExpiry(info, now), info.AccessCount, info.MimeType, info.Key)
+	}
+	return w.Flush()
+}
+
+// runStat 显示文件信息，-output json时输出找到的文件信息的数组
+func runStat(ctx context.Context, cmd *command, env *env, args []string) error {
+	var t target
+	fs := cmd.flags(env, &t)
+	args, err := cmd.parse(fs, args, 1, -1)
+	if err != nil {
+		return err
+	}
+
+	cache, closeCache, err := t.open()
+	if err != nil {
+		return err
+	}
+	defer closeCache()
+
+	now := time.Now()
+	var errs []error
+	infos := []*filecache.FileInfo{}
+	for i, key := range args {
+		info, err := cache.GetInfo(ctx, key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		if env.json() {
+			infos = append(infos, info)
+			continue
+		}
+		if i > 0 {
+			fmt.Fprintln(env.stdout)
+		}
+		w := tabwriter.NewWriter(env.stdout, 0, 4, 1, ' ', 0)
+		fmt.Fprintf(w, "key:\t%s\n", info.Key)
+		fmt.Fprintf(w, "size:\t%s (%d bytes)\n", formatSize(info.Size), info.Size)
+		fmt.Fprintf(w, "type:\t%s\n", info.MimeType)
+		fmt.Fprintf(w, "created:\t%s\n", formatTime(info.CreatedAt))
+		if info.NeverExpires() {
+			fmt.Fprintf(w, "expires:\tnever\n")
+		} else {
+			fmt.Fprintf(w, "expires:\t%s (%s)\n", formatTime(info.ExpiresAt), formatExpiry(info, now))
+		}
+		fmt.Fprintf(w, "accesses:\t%d\n", info.AccessCount)
+		fmt.Fprintf(w, "last access:\t%s\n", formatTime(info.LastAccess))
+		if info.Version != 0 {
+			fmt.Fprintf(w, "version:\t%d\n", info.Version)
+		}
+		w.Flush()
+	}
+	if env.json() {
+		if err := writeJSON(env.stdout, infos); err != nil {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runStats 显示统计信息
+func runStats(ctx context.Context, cmd *command, env *env, args []string) error {
+	var t target
+	fs := cmd.flags(env, &t)
+	if _, err := cmd.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	cache, closeCache, err := t.open()
+	if err != nil {
+		return err
+	}
+	defer closeCache()
+
+	stats, err := cache.Stats()
+	if err != nil {
+		return err
+	}
+	if env.json() {
+		return writeJSON(env.stdout, stats)
+	}
+	w := tabwriter.NewWriter(env.stdout, 0, 4, 1, ' ', 0)
+	fmt.Fprintf(w, "files:\t%d\n", stats.TotalFiles)
+	fmt.Fprintf(w, "size:\t%s (%d bytes)\n", formatSize(stats.TotalSize), stats.TotalSize)
+	fmt.Fprintf(w, "hit rate:\t%.2f%%\n", stats.HitRate*100)
+	fmt.Fprintf(w, "expired:\t%d\n", stats.ExpiredFiles)
+	fmt.Fprintf(w, "evictions:\t%d\n", stats.Evictions)
+	fmt.Fprintf(w, "last cleanup:\t%s\n", formatTime(stats.LastCleanup))
+	if !stats.LastBackup.IsZero() {
+		fmt.Fprintf(w, "last backup:\t%s\n", formatTime(stats.LastBackup))
+	}
+	return w.Flush()
+}
+
+// runCleanup 清理过期文件，支持增量清理的缓存显示清理结果
+func runCleanup(ctx context.Context, cmd *command, env *env, args []string) error {
+	var t target
+	fs := cmd.flags(env, &t)
+	if _, err := cmd.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	cache, closeCache, err := t.open()
+	if err != nil {
+		return err
+	}
+	defer closeCache()
+
+	cleaner, ok := cache.(filecache.IncrementalCleaner)
+	if !ok {
+		if err := cache.Cleanup(ctx); err != nil {
+			return err
+		}
+		if env.json() {
+			return writeJSON(env.stdout, &filecache.CleanupResult{Done: true})
+		}
+		fmt.Fprintln(env.stdout, "cleanup done")
+		return nil
+	}
+	result, err := cleaner.CleanupWithOptions(ctx, filecache.CleanupOptions{})
+	if err != nil {
+		return err
+	}
+	if env.json() {
+		return writeJSON(env.stdout, result)
+	}
+	fmt.Fprintf(env.stdout, "scanned %d entries, removed %d expired (%s), purged %d from trash, deleted %d orphaned records\n",
+		result.Scanned, result.Removed, formatSize(result.BytesReclaimed), result.TrashPurged, result.Orphans)
+	return nil
+}
+
+// formatSize 按1024换算为带单位的大小，与EDGEORIGIN_*环境变量中的单位一致
+func formatSize(n int64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	v, i := float64(n)/1024, 0
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f%cB", v, units[i])
+}
+
+// formatExpiry 返回剩余的TTL，永不过期和已过期的文件分别为never和expired
+func formatExpiry(info *filecache.FileInfo, now time.Time) string {
+	switch {
+	case info.NeverExpires():
+		return "never"
+	case !now.Before(info.ExpiresAt):
+		return "expired"
+	}
+	return info.ExpiresAt.Sub(now).Round(time.Second).String()
+}
+
+// formatTime 按本地时区显示时间，零值为-
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
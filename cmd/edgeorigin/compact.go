@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// runCompact 离线压缩本机的数据目录：合并LSM并反复重写value log，直到没有可回收的文件，
+// 报告压缩前后数据目录占用的空间
+func runCompact(ctx context.Context, cmd *command, env *env, args []string) error {
+	var t target
+	flags := cmd.flags(env, &t)
+	args, err := cmd.parse(flags, args, 0, 1)
+	if err != nil {
+		return err
+	}
+	if t.remote != "" {
+		return errors.New("compact needs exclusive access to a local data directory, use POST /compact on the admin api of a running node")
+	}
+	if err := t.wholeDir(args); err != nil {
+		return err
+	}
+
+	cfg, err := t.localConfig()
+	if err != nil {
+		return err
+	}
+	before, err := dirSize(cfg.DataDir)
+	if err != nil {
+		return err
+	}
+
+	cache, closeCache, err := t.open()
+	if err != nil {
+		return err
+	}
+	compactor, ok := cache.(filecache.Compactor)
+	if !ok {
+		closeCache()
+		return errors.New("compaction is not supported")
+	}
+	start := time.Now()
+	err = compactor.Compact(ctx)
+	elapsed := time.Since(start)
+	// 关闭时会把memtable写入磁盘，关闭后再统计才准确
+	if closeErr := closeCache(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to compact %s: %w", cfg.DataDir, err)
+	}
+
+	after, err := dirSize(cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	reclaimed := before - after
+	if reclaimed < 0 {
+		reclaimed = 0
+	}
+	if env.json() {
+		return writeJSON(env.stdout, map[string]interface{}{
+			"dir":       cfg.DataDir,
+			"duration":  elapsed.Round(time.Millisecond).String(),
+			"before":    before,
+			"after":     after,
+			"reclaimed": reclaimed,
+		})
+	}
+	fmt.Fprintf(env.stdout, "compacted %s in %s: %s -> %s, reclaimed %s\n",
+		cfg.DataDir, elapsed.Round(time.Millisecond), formatSize(before), formatSize(after), formatSize(reclaimed))
+	return nil
+}
+
+// dirSize 返回目录下所有普通文件的大小之和
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure %s: %w", dir, err)
+	}
+	return size, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	data := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+	// 写入超过一个value log文件（64MB）的数据再删除，每次打开关闭都会写出一个L0表，
+	// 表足够多时Flatten才会合并，value log中被删除的数据才能被回收
+	session := func(from, to int, fn func(cache filecache.Cache, key string) error) {
+		cache, err := filecache.NewBadgerCache(&filecache.Config{
+			DataDir:         dir,
+			MaxCacheSize:    1 << 30,
+			DefaultTTL:      time.Hour,
+			CleanupInterval: time.Hour,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		for i := from; i < to; i++ {
+			if err := fn(cache, fmt.Sprintf("file-%02d", i)); err != nil {
+				t.Fatalf("Failed to update file-%02d: %v", i, err)
+			}
+		}
+		if err := cache.Close(); err != nil {
+			t.Fatalf("Failed to close cache: %v", err)
+		}
+	}
+	for i := 0; i < 80; i += 10 {
+		session(i, i+10, func(cache filecache.Cache, key string) error {
+			return cache.Set(ctx, key, bytes.NewReader(data), "application/octet-stream", time.Hour)
+		})
+	}
+	session(1, 80, func(cache filecache.Cache, key string) error {
+		return cache.Delete(ctx, key)
+	})
+	before, err := dirSize(dir)
+	if err != nil {
+		t.Fatalf("Failed to measure directory: %v", err)
+	}
+
+	code, stdout, stderr := execute(t, "", "compact", dir)
+	if code != 0 || !strings.Contains(stdout, "reclaimed") {
+		t.Fatalf("Failed to compact: %d %s%s", code, stdout, stderr)
+	}
+	after, err := dirSize(dir)
+	if err != nil {
+		t.Fatalf("Failed to measure directory: %v", err)
+	}
+	if after >= before-(32<<20) {
+		t.Errorf("Expected compaction to reclaim the deleted files, got %d -> %d:\n%s", before, after, stdout)
+	}
+	if _, stdout, _ := execute(t, "", "get", "-dir", dir, "file-00"); stdout != string(data) {
+		t.Error("Expected the remaining file to survive compaction")
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"-remote", "http://127.0.0.1:1"},
+			{"-dir", dir, dir},
+			{"-namespace", "tenant", dir},
+			{t.TempDir() + "/missing"},
+		} {
+			if code, _, _ := execute(t, "", append([]string{"compact"}, args...)...); code != 1 {
+				t.Errorf("Expected exit code 1 for %v, got %d", args, code)
+			}
+		}
+	})
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// shells 支持生成补全脚本的shell
+var shells = []string{"bash", "zsh", "fish"}
+
+func init() {
+	// completion遍历commands，写在commands的初始化中会形成初始化循环
+	commands = append(commands, &command{"completion", "bash|zsh|fish", "generate a shell completion script", runCompletion})
+}
+
+// runCompletion 按子命令和它们的选项生成bash、zsh或fish的补全脚本，选项改变后重新生成即可：
+//
+//	edgeorigin completion bash > /etc/bash_completion.d/edgeorigin
+//	edgeorigin completion zsh > "${fpath[1]}/_edgeorigin"
+//	edgeorigin completion fish > ~/.config/fish/completions/edgeorigin.fish
+func runCompletion(ctx context.Context, cmd *command, env *env, args []string) error {
+	fs := cmd.flags(env, nil)
+	args, err := cmd.parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	if err := env.textOnly(cmd); err != nil {
+		return err
+	}
+
+	specs := commandSpecs(ctx)
+	w := bufio.NewWriter(env.stdout)
+	switch args[0] {
+	case "bash":
+		writeBash(w, specs)
+	case "zsh":
+		writeZsh(w, specs)
+	case "fish":
+		writeFish(w, specs)
+	default:
+		return fmt.Errorf("unsupported shell %q, expected %s", args[0], strings.Join(shells, ", "))
+	}
+	return w.Flush()
+}
+
+// commandSpec 生成补全脚本用的子命令说明
+type commandSpec struct {
+	name    string
+	summary string
+	flags   []flagSpec
+	words   []string // 可选的参数，为空时补全文件名
+}
+
+// flagSpec 子命令的一个选项
+type flagSpec struct {
+	name    string
+	usage   string
+	arg     string   // 参数的说明，布尔选项为空
+	choices []string // 参数的可选值，为空时补全文件名
+}
+
+// commandSpecs 返回按名称排序的子命令说明，包括help。选项取自以-h运行子命令时创建的FlagSet，
+// 所有子命令都在解析选项之后才开始工作，以-h运行不会产生其他影响
+func commandSpecs(ctx context.Context) []commandSpec {
+	var specs []commandSpec
+	names := []string{}
+	for _, cmd := range commands {
+		var fs *flag.FlagSet
+		discard := &env{stdin: strings.NewReader(""), stdout: io.Discard, stderr: io.Discard, flagSet: &fs}
+		cmd.run(ctx, cmd, discard, []string{"-h"})
+
+		spec := commandSpec{name: cmd.name, summary: cmd.summary}
+		fs.VisitAll(func(f *flag.Flag) {
+			arg, usage := flag.UnquoteUsage(f)
+			fl := flagSpec{name: f.Name, usage: shortUsage(usage), arg: arg}
+			if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+				fl.arg = ""
+			}
+			if c, ok := f.Value.(interface{ choices() []string }); ok {
+				fl.choices = c.choices()
+			}
+			spec.flags = append(spec.flags, fl)
+		})
+		if cmd.name == "completion" {
+			spec.words = shells
+		}
+		specs = append(specs, spec)
+		names = append(names, cmd.name)
+	}
+	sort.Strings(names)
+	specs = append(specs, commandSpec{name: "help", summary: "show the usage of a command", words: names})
+	sort.Slice(specs, func(i, j int) bool { return specs[i].name < specs[j].name })
+	return specs
+}
+
+// shortUsage 只保留选项说明中括号和举例之前的部分
+func shortUsage(usage string) string {
+	for _, sep := range []string{" (", ", e.g.", "\n"} {
+		usage, _, _ = strings.Cut(usage, sep)
+	}
+	return usage
+}
+
+// writeBash 生成bash补全脚本：第一个参数补全子命令，以-开头时补全子命令的选项，
+// 有可选值的选项补全可选值，其余情况由-o default补全文件名
+func writeBash(w io.Writer, specs []commandSpec) {
+	var names []string
+	for _, spec := range specs {
+		names = append(names, spec.name)
+	}
+	fmt.Fprintln(w, "# bash completion for edgeorigin, generated by edgeorigin completion bash")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "_edgeorigin() {")
+	fmt.Fprintln(w, "\tlocal cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}")
+	fmt.Fprintln(w, "\tif [[ $COMP_CWORD -eq 1 ]]; then")
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(names, " "))
+	fmt.Fprintln(w, "\t\treturn")
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w, "\tlocal flags= words=")
+	fmt.Fprintln(w, "\tcase ${COMP_WORDS[1]} in")
+	for _, spec := range specs {
+		fmt.Fprintf(w, "\t%s)\n", spec.name)
+		var flags []string
+		for _, f := range spec.flags {
+			flags = append(flags, "-"+f.name)
+		}
+		if len(flags) > 0 {
+			fmt.Fprintf(w, "\t\tflags=%q\n", strings.Join(flags, " "))
+		}
+		if len(spec.words) > 0 {
+			fmt.Fprintf(w, "\t\twords=%q\n", strings.Join(spec.words, " "))
+		}
+		for _, f := range spec.flags {
+			if len(f.choices) > 0 {
+				fmt.Fprintf(w, "\t\tif [[ $prev == -%s ]]; then\n", f.name)
+				fmt.Fprintf(w, "\t\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(f.choices, " "))
+				fmt.Fprintln(w, "\t\t\treturn")
+				fmt.Fprintln(w, "\t\tfi")
+			}
+		}
+		var valued []string
+		for _, f := range spec.flags {
+			if f.arg != "" && len(f.choices) == 0 {
+				valued = append(valued, "-"+f.name)
+			}
+		}
+		if len(valued) > 0 {
+			fmt.Fprintf(w, "\t\tcase $prev in %s) return ;; esac\n", strings.Join(valued, "|"))
+		}
+		fmt.Fprintln(w, "\t\t;;")
+	}
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, "\tif [[ $cur == -* ]]; then")
+	fmt.Fprintln(w, "\t\tCOMPREPLY=($(compgen -W \"$flags\" -- \"$cur\"))")
+	fmt.Fprintln(w, "\telif [[ -n $words ]]; then")
+	fmt.Fprintln(w, "\t\tCOMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))")
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "complete -o default -F _edgeorigin edgeorigin")
+}
+
+// writeZsh 生成zsh补全脚本，既可以放在fpath中自动加载，也可以直接source
+func writeZsh(w io.Writer, specs []commandSpec) {
+	// zshQuote 加上单引号
+	zshQuote := func(s string) string {
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	}
+	// zshEscape 转义_describe和_arguments说明中的特殊字符
+	zshEscape := strings.NewReplacer("[", `\[`, "]", `\]`, ":", `\:`).Replace
+	fmt.Fprintln(w, "#compdef edgeorigin")
+	fmt.Fprintln(w, "# zsh completion for edgeorigin, generated by edgeorigin completion zsh")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "_edgeorigin() {")
+	fmt.Fprintln(w, "\tlocal -a commands")
+	fmt.Fprintln(w, "\tcommands=(")
+	for _, spec := range specs {
+		fmt.Fprintf(w, "\t\t%s\n", zshQuote(spec.name+":"+zshEscape(spec.summary)))
+	}
+	fmt.Fprintln(w, "\t)")
+	fmt.Fprintln(w, "\tlocal state")
+	fmt.Fprintln(w, "\t_arguments -C '1:command:->command' '*::argument:->argument'")
+	fmt.Fprintln(w, "\tcase $state in")
+	fmt.Fprintln(w, "\tcommand)")
+	fmt.Fprintln(w, "\t\t_describe -t commands 'edgeorigin command' commands")
+	fmt.Fprintln(w, "\t\t;;")
+	fmt.Fprintln(w, "\targument)")
+	fmt.Fprintln(w, "\t\tcase $words[1] in")
+	for _, spec := range specs {
+		fmt.Fprintf(w, "\t\t%s)\n", spec.name)
+		fmt.Fprint(w, "\t\t\t_arguments")
+		for _, f := range spec.flags {
+			// Go的选项都可以重复，后出现的生效或者累加
+			arg := "*-" + f.name + "[" + zshEscape(f.usage) + "]"
+			switch {
+			case len(f.choices) > 0:
+				arg += ":" + f.arg + ":(" + strings.Join(f.choices, " ") + ")"
+			case f.arg != "":
+				arg += ":" + f.arg + ":_files"
+			}
+			fmt.Fprintf(w, " \\\n\t\t\t\t%s", zshQuote(arg))
+		}
+		if len(spec.words) > 0 {
+			fmt.Fprintf(w, " \\\n\t\t\t\t'1:argument:(%s)'", strings.Join(spec.words, " "))
+		} else {
+			fmt.Fprint(w, " \\\n\t\t\t\t'*:file:_files'")
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "\t\t\t;;")
+	}
+	fmt.Fprintln(w, "\t\tesac")
+	fmt.Fprintln(w, "\t\t;;")
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `if [[ $funcstack[1] == _edgeorigin ]]; then`)
+	fmt.Fprintln(w, `	_edgeorigin "$@"`)
+	fmt.Fprintln(w, "else")
+	fmt.Fprintln(w, "\tcompdef _edgeorigin edgeorigin")
+	fmt.Fprintln(w, "fi")
+}
+
+// writeFish 生成fish补全脚本，Go的选项以单个-开头，对应fish的-o
+func writeFish(w io.Writer, specs []commandSpec) {
+	// fishQuote 加上单引号
+	fishQuote := func(s string) string {
+		return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+	}
+	fmt.Fprintln(w, "# fish completion for edgeorigin, generated by edgeorigin completion fish")
+	fmt.Fprintln(w)
+	for _, spec := range specs {
+		fmt.Fprintf(w, "complete -c edgeorigin -n __fish_use_subcommand -f -a %s -d %s\n", spec.name, fishQuote(spec.summary))
+	}
+	for _, spec := range specs {
+		fmt.Fprintln(w)
+		cond := fishQuote("__fish_seen_subcommand_from " + spec.name)
+		if len(spec.words) > 0 {
+			fmt.Fprintf(w, "complete -c edgeorigin -n %s -f -a %s\n", cond, fishQuote(strings.Join(spec.words, " ")))
+		}
+		for _, f := range spec.flags {
+			fmt.Fprintf(w, "complete -c edgeorigin -n %s -o %s", cond, f.name)
+			switch {
+			case len(f.choices) > 0:
+				fmt.Fprintf(w, " -x -a %s", fishQuote(strings.Join(f.choices, " ")))
+			case f.arg != "":
+				fmt.Fprint(w, " -r")
+			}
+			fmt.Fprintf(w, " -d %s\n", fishQuote(f.usage))
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompletion(t *testing.T) {
+	for _, shell := range shells {
+		t.Run(shell, func(t *testing.T) {
+			code, stdout, stderr := execute(t, "", "completion", shell)
+			if code != 0 {
+				t.Fatalf("Failed to generate %s completion: %s", shell, stderr)
+			}
+			for _, want := range []string{"purge", "verify", "output", "older-than", "text json"} {
+				if !strings.Contains(stdout, want) {
+					t.Errorf("Expected the script to contain %q", want)
+				}
+			}
+			if _, err := exec.LookPath(shell); err != nil {
+				return
+			}
+			file := filepath.Join(t.TempDir(), "edgeorigin."+shell)
+			if err := os.WriteFile(file, []byte(stdout), 0644); err != nil {
+				t.Fatalf("Failed to write script: %v", err)
+			}
+			if out, err := exec.Command(shell, "-n", file).CombinedOutput(); err != nil {
+				t.Errorf("Expected a valid %s script, got %v: %s", shell, err, out)
+			}
+		})
+	}
+
+	t.Run("complete", func(t *testing.T) {
+		if _, err := exec.LookPath("bash"); err != nil {
+			t.Skip("bash is not installed")
+		}
+		_, script, _ := execute(t, "", "completion", "bash")
+		// complete 模拟在命令行输入words后按Tab
+		complete := func(words ...string) string {
+			t.Helper()
+			cmd := exec.Command("bash", "-c", script+`
+COMP_WORDS=("$@"); COMP_CWORD=$(($# - 1)); _edgeorigin; echo "${COMPREPLY[*]}"`, "bash", "edgeorigin")
+			cmd.Args = append(cmd.Args, words...)
+			out, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Fatalf("Failed to run bash: %v: %s", err, out)
+			}
+			return strings.TrimSpace(string(out))
+		}
+		for _, c := range []struct {
+			words []string
+			want  string
+		}{
+			{[]string{"sta"}, "stat stats"},
+			{[]string{"purge", "-older"}, "-older-than"},
+			{[]string{"ls", "-output", "j"}, "json"},
+			{[]string{"completion", "f"}, "fish"},
+			{[]string{"help", "ex"}, "export"},
+			{[]string{"get", "-dir", ""}, ""},
+		} {
+			if got := complete(c.words...); got != c.want {
+				t.Errorf("Expected %v to complete to %q, got %q", c.words, c.want, got)
+			}
+		}
+	})
+
+	if code, _, _ := execute(t, "", "completion", "powershell"); code != 1 {
+		t.Errorf("Expected exit code 1 for an unsupported shell, got %d", code)
+	}
+	if code, _, _ := execute(t, "", "completion"); code != 2 {
+		t.Errorf("Expected exit code 2 without a shell, got %d", code)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// 导出包的压缩格式
+const (
+	compressNone = "none"
+	compressGzip = "gzip"
+	compressZstd = "zstd"
+)
+
+// 压缩流开头的魔数
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// archiveResult -output json时导出或导入的结果
+type archiveResult struct {
+	File     string `json:"file"`
+	Entries  int    `json:"entries"`
+	Bytes    int64  `json:"bytes,omitempty"` // 导出包的大小，只在导出时报告
+	Duration string `json:"duration"`
+}
+
+// runExport 把缓存中符合条件的未过期文件写入导出包，用于预热新节点；两个参数时第一个是本机数据目录，
+// 导出包为-时写到标准输出
+func runExport(ctx context.Context, cmd *command, env *env, args []string) error {
+	var t target
+	fs := cmd.flags(env, &t)
+	prefix := fs.String("prefix", "", "only export keys starting with `prefix`")
+	minAccess := fs.Int64("min-access", 0, "only export entries read at least `n` times")
+	compress := fs.String("compress", "", "`format` of the archive: none, gzip or zstd (default: by the file extension, none for stdout)")
+	args, err := cmd.parse(fs, args, 1, 2)
+	if err != nil {
+		return err
+	}
+	file, err := t.archiveArgs(args)
+	if err != nil {
+		return err
+	}
+	format := *compress
+	if format == "" {
+		format = compressionFor(file)
+	}
+	if format != compressNone && format != compressGzip && format != compressZstd {
+		return fmt.Errorf("invalid compression %q, expected none, gzip or zstd", format)
+	}
+
+	cache, closeCache, err := t.open()
+	if err != nil {
+		return err
+	}
+	defer closeCache()
+
+	start := time.Now()
+	out, report := io.Writer(env.stdout), env.stdout
+	var tmp *os.File
+	if file == "-" {
+		report = env.stderr
+	} else {
+		// 先写到同一目录下的临时文件，导出失败时不留下不完整的导出包
+		if tmp, err = os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".tmp*"); err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		out = tmp
+	}
+	counter := &countingWriter{w: out}
+	w, err := compressWriter(counter, format)
+	if err != nil {
+		return err
+	}
+	n, err := filecache.ExportWithOptions(ctx, cache, w, filecache.ExportOptions{Prefix: *prefix, MinAccessCount: *minAccess})
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if tmp != nil {
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp.Name(), file); err != nil {
+			return err
+		}
+	}
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if env.json() {
+		return writeJSON(report, archiveResult{File: file, Entries: n, Bytes: counter.n, Duration: elapsed.String()})
+	}
+	fmt.Fprintf(report, "exported %d entries to %s (%s) in %s\n", n, file, formatSize(counter.n), elapsed)
+	return nil
+}
+
+// runImport 把导出包中符合条件的文件写入缓存，按内容识别gzip和zstd压缩；两个参数时第一个是本机数据目录，
+// 目录不存在时创建，导出包为-时读取标准输入
+func runImport(ctx context.Context, cmd *command, env *env, args []string) error {
+	var t target
+	fs := cmd.flags(env, &t)
+	prefix := fs.String("prefix", "", "only import keys starting with `prefix`")
+	minAccess := fs.Int64("min-access", 0, "only import entries that had been read at least `n` times when exported")
+	skipExisting := fs.Bool("skip-existing", false, "keep entries that already exist instead of overwriting them")
+	args, err := cmd.parse(fs, args, 1, 2)
+	if err != nil {
+		return err
+	}
+	file, err := t.archiveArgs(args)
+	if err != nil {
+		return err
+	}
+
+	in := env.stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	r, err := decompressReader(in)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	defer r.Close()
+
+	if t.dir != "" {
+		if err := os.MkdirAll(t.dir, 0755); err != nil {
+			return err
+		}
+	}
+	cache, closeCache, err := t.open()
+	if err != nil {
+		return err
+	}
+	defer closeCache()
+
+	start := time.Now()
+	n, err := filecache.ImportWithOptions(ctx, cache, r, filecache.ImportOptions{
+		Prefix:         *prefix,
+		MinAccessCount: *minAccess,
+		SkipExisting:   *skipExisting,
+	})
+	if err != nil {
+		return err
+	}
+	if err := closeCache(); err != nil {
+		return err
+	}
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if env.json() {
+		return writeJSON(env.stdout, archiveResult{File: file, Entries: n, Duration: elapsed.String()})
+	}
+	fmt.Fprintf(env.stdout, "imported %d entries from %s in %s\n", n, file, elapsed)
+	return nil
+}
+
+// archiveArgs 处理export和import的参数：两个参数时第一个是本机数据目录，等同于-dir；返回导出包的文件名
+func (t *target) archiveArgs(args []string) (string, error) {
+	if len(args) == 2 {
+		if t.dir != "" {
+			return "", errors.New("the directory argument and -dir cannot be combined")
+		}
+		t.dir = args[0]
+	}
+	return args[len(args)-1], nil
+}
+
+// compressionFor 按文件扩展名选择压缩格式
+func compressionFor(name string) string {
+	switch filepath.Ext(name) {
+	case ".zst", ".zstd":
+		return compressZstd
+	case ".gz", ".tgz":
+		return compressGzip
+	default:
+		return compressNone
+	}
+}
+
+// compressWriter 返回按format压缩后写入w的Writer，关闭时写完压缩流，不关闭w
+func compressWriter(w io.Writer, format string) (io.WriteCloser, error) {
+	switch format {
+	case compressZstd:
+		return zstd.NewWriter(w)
+	case compressGzip:
+		return gzip.NewWriter(w), nil
+	default:
+		return nopWriteCloser{w}, nil
+	}
+}
+
+// decompressReader 按开头的魔数识别gzip和zstd压缩，其他内容原样读取
+func decompressReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(head, zstdMagic):
+		d, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	case bytes.HasPrefix(head, gzipMagic):
+		return gzip.NewReader(br)
+	default:
+		return io.NopCloser(br), nil
+	}
+}
+
+// nopWriteCloser 关闭时不做任何事的Writer
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// countingWriter 统计写入的字节数
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
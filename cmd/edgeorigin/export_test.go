@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	base := t.TempDir()
+	src := filepath.Join(base, "src")
+	cache, err := filecache.NewBadgerCache(&filecache.Config{
+		DataDir:         src,
+		MaxCacheSize:    1 << 20,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	for _, key := range []string{"assets/app.js", "assets/app.css", "assets/old.js", "index.html"} {
+		if err := cache.Set(ctx, key, strings.NewReader(key), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+	}
+	for _, key := range []string{"assets/app.js", "assets/app.js", "assets/app.css", "index.html"} {
+		readString(t, cache, key)
+	}
+	if err := cache.Close(); err != nil {
+		t.Fatalf("Failed to close cache: %v", err)
+	}
+
+	// keys 返回本机数据目录中按顺序排列的键
+	keys := func(t *testing.T, dir string) string {
+		t.Helper()
+		code, stdout, stderr := execute(t, "", "ls", "-dir", dir)
+		if code != 0 {
+			t.Fatalf("Failed to list %s: %s", dir, stderr)
+		}
+		return strings.Join(strings.Fields(stdout), ",")
+	}
+
+	archive := filepath.Join(base, "warm.tar.zst")
+	t.Run("Zstd", func(t *testing.T) {
+		code, stdout, stderr := execute(t, "", "export", "-prefix", "assets/", "-min-access", "1", src, archive)
+		if code != 0 || !strings.HasPrefix(stdout, "exported 2 entries to "+archive) {
+			t.Fatalf("Expected 2 hot assets to be exported, got %d %q %s", code, stdout, stderr)
+		}
+		data, err := os.ReadFile(archive)
+		if err != nil || !strings.HasPrefix(string(data), string(zstdMagic)) {
+			t.Fatalf("Expected a zstd archive, got %v", err)
+		}
+		if matches, _ := filepath.Glob(archive + ".tmp*"); len(matches) != 0 {
+			t.Errorf("Expected temporary files to be removed, got %v", matches)
+		}
+
+		dst := filepath.Join(base, "new-pop")
+		code, stdout, stderr = execute(t, "", "import", "-min-access", "2", dst, archive)
+		if code != 0 || !strings.HasPrefix(stdout, "imported 1 entries") {
+			t.Fatalf("Expected 1 entry to be imported, got %d %q %s", code, stdout, stderr)
+		}
+		if got := keys(t, dst); got != "assets/app.js" {
+			t.Errorf("Expected only the hottest entry, got %s", got)
+		}
+	})
+
+	t.Run("Stdout", func(t *testing.T) {
+		code, archive, stderr := execute(t, "", "export", "-dir", src, "-compress", "gzip", "-")
+		if code != 0 || !strings.HasPrefix(stderr, "exported 4 entries to -") || !strings.HasPrefix(archive, string(gzipMagic)) {
+			t.Fatalf("Expected a gzip archive on stdout, got %d %s", code, stderr)
+		}
+
+		dst := filepath.Join(base, "stdin")
+		if code, _, stderr := execute(t, "", "put", "-dir", mkdir(t, dst), "index.html", "-"); code != 0 {
+			t.Fatalf("Failed to put file: %s", stderr)
+		}
+		code, stdout, stderr := execute(t, archive, "import", "-skip-existing", "-dir", dst, "-")
+		if code != 0 || !strings.HasPrefix(stdout, "imported 3 entries from -") {
+			t.Fatalf("Expected 3 entries to be imported, got %d %q %s", code, stdout, stderr)
+		}
+		if got := keys(t, dst); got != "assets/app.css,assets/app.js,assets/old.js,index.html" {
+			t.Errorf("Expected all entries, got %s", got)
+		}
+		if code, stdout, _ := execute(t, "", "get", "-dir", dst, "index.html"); code != 0 || stdout != "" {
+			t.Errorf("Expected the existing entry to be kept, got %q", stdout)
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var result archiveResult
+		code, stdout, _ := execute(t, "", "export", "-output", "json", "-prefix", "index", src, filepath.Join(base, "index.tar"))
+		if decodeJSON(t, stdout, &result); code != 0 || result.Entries != 1 || result.Bytes == 0 {
+			t.Errorf("Expected the export result as json, got %d %s", code, stdout)
+		}
+		var imported archiveResult
+		code, stdout, _ = execute(t, "", "import", "-output", "json", filepath.Join(base, "json"), filepath.Join(base, "index.tar"))
+		if decodeJSON(t, stdout, &imported); code != 0 || imported.Entries != 1 || imported.Bytes != 0 {
+			t.Errorf("Expected the import result as json, got %d %s", code, stdout)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		if code, _, _ := execute(t, "", "export", src); code != 1 {
+			t.Errorf("Expected exit code 1 without a target, got %d", code)
+		}
+		if code, _, _ := execute(t, "", "export"); code != 2 {
+			t.Errorf("Expected exit code 2 without arguments, got %d", code)
+		}
+		for _, args := range [][]string{
+			{"export", "-compress", "lz4", src, filepath.Join(base, "out.tar")},
+			{"export", "-dir", src, src, filepath.Join(base, "out.tar")},
+			{"import", src, filepath.Join(base, "missing.tar")},
+			{"import", filepath.Join(base, "bad"), filepath.Join(src, "badger", "MANIFEST")},
+		} {
+			if code, _, _ := execute(t, "", args...); code != 1 {
+				t.Errorf("Expected exit code 1 for %v, got %d", args, code)
+			}
+		}
+	})
+}
+
+// mkdir 创建目录并返回路径
+func mkdir(t *testing.T, dir string) string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	return dir
+}
@@ -0,0 +1,170 @@
+// Command edgeorigin 按配置文件运行边缘节点，也是调试和运维缓存的命令行工具，直接打开本机的数据目录，
+// 或者通过管理接口（pkg/server/admin）访问运行中的节点：
+//
+//	edgeorigin serve -c /etc/edgeorigin/edgeorigin.yaml
+//	edgeorigin ls -dir /var/cache/edgeorigin -l
+//	edgeorigin get -remote http://127.0.0.1:9090/admin -o app.js static/app.js
+//	edgeorigin put -remote unix:///run/edgeorigin/admin.sock -ttl none fonts/a.woff2 a.woff2
+//	edgeorigin stats -remote http://127.0.0.1:9090/admin -output json
+//
+// 每个子命令的选项写在参数之前，edgeorigin help <命令> 查看说明；-output json 时结果以JSON写到标准输出，
+// 错误以JSON写到标准错误，便于脚本处理。edgeorigin completion bash|zsh|fish 生成shell补全脚本
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+)
+
+// command 子命令
+type command struct {
+	name    string
+	args    string // 参数说明，显示在用法中
+	summary string
+	run     func(ctx context.Context, cmd *command, env *env, args []string) error
+}
+
+// commands 所有子命令，按名称排序后显示
+var commands = []*command{
+	{"serve", "", "run an edge node (cache, proxy, admin api and metrics) from a config file", runServe},
+	{"get", "<key>", "write an entry to stdout or a file", runGet},
+	{"put", "<key> [file]", "store a file (or stdin) under key", runPut},
+	{"rm", "<key>...", "delete entries", runRm},
+	{"ls", "", "list entries", runLs},
+	{"stat", "<key>...", "show entry metadata", runStat},
+	{"stats", "", "show cache statistics", runStats},
+	{"cleanup", "", "remove expired entries", runCleanup},
+	{"purge", "", "purge entries by key prefix or cache tag on a running node", runPurge},
+	{"compact", "[dir]", "reclaim disk space of a local data directory offline", runCompact},
+	{"verify", "[dir]", "check every entry of a local data directory and optionally repair it", runVerify},
+	{"export", "[dir] <file>", "write entries to a tar archive (gzip or zstd by extension) to warm other nodes", runExport},
+	{"import", "[dir] <file>", "load entries from an export archive", runImport},
+	{"migrate", "", "copy all entries between backends, or from a badger backup of an older version", runMigrate},
+	{"bench", "", "measure throughput and latency of a cache or the http frontend", runBench},
+	{"browse", "", "browse, inspect, delete and pin entries in a terminal ui", runBrowse},
+}
+
+// env 命令的输入输出，测试时替换
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	output outputFormat // -output选项，由flags注册
+
+	// flagSet 不为空时flags把创建的选项保存到这里，用于生成补全脚本
+	flagSet **flag.FlagSet
+}
+
+// errUsage 参数错误，已经打印了用法
+var errUsage = errors.New("usage")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	os.Exit(run(ctx, &env{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}, os.Args[1:]))
+}
+
+// run 执行args指定的子命令，返回进程的退出码：成功为0，出错为1，参数错误为2
+func run(ctx context.Context, env *env, args []string) int {
+	if len(args) == 0 {
+		usage(env.stderr)
+		return 2
+	}
+	name, args := args[0], args[1:]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		if len(args) > 0 {
+			if cmd := lookup(args[0]); cmd != nil {
+				// -h打印包括子命令自身选项在内的用法
+				help := *env
+				help.stderr = env.stdout
+				cmd.run(ctx, cmd, &help, []string{"-h"})
+				return 0
+			}
+		}
+		usage(env.stdout)
+		return 0
+	}
+
+	cmd := lookup(name)
+	if cmd == nil {
+		fmt.Fprintf(env.stderr, "edgeorigin: unknown command %q\n", name)
+		usage(env.stderr)
+		return 2
+	}
+	err := cmd.run(ctx, cmd, env, args)
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errUsage), errors.Is(err, flag.ErrHelp):
+		return 2
+	case env.json():
+		writeJSON(env.stderr, jsonError{Command: cmd.name, Error: err.Error()})
+		return 1
+	default:
+		fmt.Fprintf(env.stderr, "edgeorigin %s: %v\n", cmd.name, err)
+		return 1
+	}
+}
+
+// lookup 按名称查找子命令
+func lookup(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// usage 打印子命令列表
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: edgeorigin <command> [options] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	sorted := append([]*command(nil), commands...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].name < sorted[j].name })
+	for _, cmd := range sorted {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "run 'edgeorigin help <command>' for the options of a command")
+}
+
+// flags 返回子命令的选项，包括所有子命令共有的-output，t非空时还包括选择缓存的公共选项
+func (c *command) flags(env *env, t *target) *flag.FlagSet {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.SetOutput(env.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: edgeorigin %s [options] %s\n\n%s\n\noptions:\n", c.name, c.args, c.summary)
+		fs.PrintDefaults()
+	}
+	env.output = outputText
+	fs.Var(&env.output, "output", "`format` of the results: text or json")
+	if t != nil {
+		t.register(fs)
+	}
+	if env.flagSet != nil {
+		*env.flagSet = fs
+	}
+	return fs
+}
+
+// parse 解析子命令的选项，剩余参数的个数不在[min, max]内时打印用法；max为-1表示不限制
+func (c *command) parse(fs *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if n := fs.NArg(); n < min || (max >= 0 && n > max) {
+		fs.Usage()
+		return nil, errUsage
+	}
+	return fs.Args(), nil
+}
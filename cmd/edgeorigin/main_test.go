@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+	"github.com/seraphico/EdgeOrigin/pkg/server/admin"
+)
+
+// execute 执行命令，返回退出码、标准输出和标准错误
+func execute(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), &env{stdin: strings.NewReader(stdin), stdout: &stdout, stderr: &stderr}, args)
+	return code, stdout.String(), stderr.String()
+}
+
+// testCommands 在target选项指定的缓存上依次执行各个命令
+func testCommands(t *testing.T, target ...string) {
+	with := func(args ...string) []string {
+		return append(append(args[:1:1], target...), args[1:]...)
+	}
+
+	t.Run("put", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "app.css")
+		if err := os.WriteFile(file, []byte("body{}"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if code, _, stderr := execute(t, "", with("put", "-ttl", "1h", "static/app.css", file)...); code != 0 {
+			t.Fatalf("Failed to put file: %s", stderr)
+		}
+		if code, _, stderr := execute(t, "hello", with("put", "-ttl", "none", "-type", "text/plain", "hello")...); code != 0 {
+			t.Fatalf("Failed to put stdin: %s", stderr)
+		}
+		if code, _, _ := execute(t, "", with("put", "-ttl", "-1h", "bad")...); code != 1 {
+			t.Errorf("Expected exit code 1 for invalid ttl, got %d", code)
+		}
+	})
+
+	t.Run("get", func(t *testing.T) {
+		code, stdout, stderr := execute(t, "", with("get", "hello")...)
+		if code != 0 || stdout != "hello" {
+			t.Errorf("Expected hello, got %d %q %s", code, stdout, stderr)
+		}
+		if code, _, stderr := execute(t, "", with("get", "missing")...); code != 1 || !strings.Contains(stderr, "not found") {
+			t.Errorf("Expected not found error, got %d %s", code, stderr)
+		}
+	})
+
+	t.Run("ls", func(t *testing.T) {
+		if _, stdout, _ := execute(t, "", with("ls")...); stdout != "hello\nstatic/app.css\n" {
+			t.Errorf("Expected both keys in order, got %q", stdout)
+		}
+		if _, stdout, _ := execute(t, "", with("ls", "-prefix", "static/")...); stdout != "static/app.css\n" {
+			t.Errorf("Expected prefix filter, got %q", stdout)
+		}
+		_, stdout, _ := execute(t, "", with("ls", "-l")...)
+		if !strings.Contains(stdout, "never") || !strings.Contains(stdout, "text/css") {
+			t.Errorf("Expected long listing with expiry and type, got:\n%s", stdout)
+		}
+	})
+
+	t.Run("stat", func(t *testing.T) {
+		code, stdout, _ := execute(t, "", with("stat", "static/app.css")...)
+		if code != 0 || !strings.Contains(stdout, "size:        6B (6 bytes)") {
+			t.Errorf("Expected file info, got %d:\n%s", code, stdout)
+		}
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		code, stdout, _ := execute(t, "", with("stats")...)
+		if code != 0 || !strings.Contains(stdout, "files:        2") {
+			t.Errorf("Expected 2 files, got %d:\n%s", code, stdout)
+		}
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		code, stdout, stderr := execute(t, "", with("cleanup")...)
+		if code != 0 || !strings.Contains(stdout, "removed 0 expired") {
+			t.Errorf("Expected cleanup result, got %d %q %s", code, stdout, stderr)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var files []filecache.FileInfo
+		_, stdout, _ := execute(t, "", with("ls", "-output", "json", "-prefix", "static/")...)
+		if decodeJSON(t, stdout, &files); len(files) != 1 || files[0].Key != "static/app.css" || !strings.HasPrefix(files[0].MimeType, "text/css") {
+			t.Errorf("Expected the listing as json, got %s", stdout)
+		}
+		_, stdout, _ = execute(t, "", with("stat", "-output", "json", "hello", "static/app.css")...)
+		if decodeJSON(t, stdout, &files); len(files) != 2 || files[0].Size != 5 || !files[0].NeverExpires() {
+			t.Errorf("Expected file info as json, got %s", stdout)
+		}
+		var stats filecache.Stats
+		_, stdout, _ = execute(t, "", with("stats", "-output", "json")...)
+		if decodeJSON(t, stdout, &stats); stats.TotalFiles != 2 {
+			t.Errorf("Expected stats as json, got %s", stdout)
+		}
+		var result filecache.CleanupResult
+		_, stdout, _ = execute(t, "", with("cleanup", "-output", "json")...)
+		if decodeJSON(t, stdout, &result); !result.Done {
+			t.Errorf("Expected the cleanup result as json, got %s", stdout)
+		}
+
+		file := filepath.Join(t.TempDir(), "hello.txt")
+		var info filecache.FileInfo
+		_, stdout, _ = execute(t, "", with("get", "-output", "json", "-o", file, "hello")...)
+		if decodeJSON(t, stdout, &info); info.Key != "hello" {
+			t.Errorf("Expected the file info of get as json, got %s", stdout)
+		}
+		if data, err := os.ReadFile(file); err != nil || string(data) != "hello" {
+			t.Errorf("Expected the content in the file, got %q %v", data, err)
+		}
+		_, stdout, _ = execute(t, "hi", with("put", "-output", "json", "-ttl", "1h", "tmp/hi.txt")...)
+		if decodeJSON(t, stdout, &info); info.Key != "tmp/hi.txt" || info.Size != 2 || info.MimeType != "text/plain; charset=utf-8" {
+			t.Errorf("Expected the stored file info as json, got %s", stdout)
+		}
+		var deleted map[string][]string
+		code, stdout, _ := execute(t, "", with("rm", "-output", "json", "tmp/hi.txt")...)
+		if decodeJSON(t, stdout, &deleted); code != 0 || len(deleted["deleted"]) != 1 {
+			t.Errorf("Expected the deleted keys as json, got %d %s", code, stdout)
+		}
+	})
+
+	t.Run("rm", func(t *testing.T) {
+		if code, _, stderr := execute(t, "", with("rm", "hello", "static/app.css")...); code != 0 {
+			t.Fatalf("Failed to delete files: %s", stderr)
+		}
+		if _, stdout, _ := execute(t, "", with("ls")...); stdout != "" {
+			t.Errorf("Expected no files, got %q", stdout)
+		}
+		if _, stdout, _ := execute(t, "", with("ls", "-output", "json")...); stdout != "[]\n" {
+			t.Errorf("Expected an empty json array, got %q", stdout)
+		}
+	})
+}
+
+func TestCommands(t *testing.T) {
+	t.Run("local", func(t *testing.T) {
+		testCommands(t, "-dir", t.TempDir())
+	})
+
+	t.Run("remote", func(t *testing.T) {
+		cache, err := filecache.NewMemoryCacheWithConfig(&filecache.Config{MaxCacheSize: 1 << 20, DefaultTTL: time.Hour, CleanupInterval: time.Hour})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		defer cache.Close()
+		handler, err := admin.NewHandler(cache, admin.Options{Token: "secret"})
+		if err != nil {
+			t.Fatalf("Failed to create handler: %v", err)
+		}
+		srv := httptest.NewServer(handler)
+		defer srv.Close()
+
+		t.Setenv("EDGEORIGIN_ADMIN_TOKEN", "secret")
+		testCommands(t, "-remote", srv.URL, "-namespace", "tenant")
+		execute(t, "data", "put", "-remote", srv.URL, "-namespace", "tenant", "key")
+		if ok, _ := cache.Namespace("tenant").Exists(context.Background(), "key"); !ok {
+			t.Error("Expected the file to be stored in the namespace")
+		}
+	})
+}
+
+func TestUsage(t *testing.T) {
+	if code, _, stderr := execute(t, ""); code != 2 || !strings.Contains(stderr, "commands:") {
+		t.Errorf("Expected usage without a command, got %d %s", code, stderr)
+	}
+	if code, _, stderr := execute(t, "", "frobnicate"); code != 2 || !strings.Contains(stderr, "unknown command") {
+		t.Errorf("Expected unknown command error, got %d %s", code, stderr)
+	}
+	if code, stdout, _ := execute(t, "", "help", "put"); code != 0 || !strings.Contains(stdout, "-ttl") || !strings.Contains(stdout, "-remote") {
+		t.Errorf("Expected put usage with its options, got %d:\n%s", code, stdout)
+	}
+	if code, _, _ := execute(t, "", "get", "-dir", t.TempDir()); code != 2 {
+		t.Errorf("Expected exit code 2 for missing key, got %d", code)
+	}
+	if code, _, stderr := execute(t, "", "ls", "-dir", t.TempDir(), "-remote", "http://127.0.0.1:1"); code != 1 || !strings.Contains(stderr, "cannot be combined") {
+		t.Errorf("Expected error for -dir with -remote, got %d %s", code, stderr)
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// backendUsage 迁移支持的后端格式
+const backendUsage = "badger:<dir>, fsblob:<dir> or snapshot:<file>"
+
+// backend 迁移的一端：Badger数据目录、文件系统后端的数据目录，或者Badger备份格式的快照文件
+type backend struct {
+	kind string
+	path string
+}
+
+// parseBackend 解析形如badger:/var/cache/edgeorigin的后端
+func parseBackend(s string) (*backend, error) {
+	kind, path, _ := strings.Cut(s, ":")
+	if path != "" {
+		switch kind {
+		case "badger", "fsblob", "snapshot":
+			return &backend{kind: kind, path: filepath.Clean(path)}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid backend %q, expected %s", s, backendUsage)
+}
+
+func (b *backend) String() string {
+	return b.kind + ":" + b.path
+}
+
+// exists 判断后端的目录或文件是否存在
+func (b *backend) exists() bool {
+	_, err := os.Stat(b.path)
+	return err == nil
+}
+
+// open 打开后端，配置取自EDGEORIGIN_*环境变量；作为目标时目录不存在则创建
+func (b *backend) open(ctx context.Context, source bool) (filecache.Cache, func() error, error) {
+	if source {
+		if _, err := os.Stat(b.path); err != nil {
+			return nil, nil, fmt.Errorf("source: %w", err)
+		}
+	}
+	cfg, err := filecache.LoadConfigFromEnv()
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg.DataDir = b.path
+	cfg.Backup = nil
+
+	var cache filecache.Cache
+	switch b.kind {
+	case "snapshot":
+		return openSnapshot(ctx, b.path, cfg)
+	case "fsblob":
+		if err = filecache.ValidateConfig(cfg); err == nil {
+			cache, err = filecache.NewFSBlobCache(cfg)
+		}
+	default:
+		cache, err = filecache.NewCacheWithConfig(cfg)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open %s: %w", b, err)
+	}
+	return cache, cache.Close, nil
+}
+
+// openSnapshot 把快照恢复到临时的Badger目录中作为迁移源，关闭时删除临时目录。
+// 快照是Badger的备份格式，旧的Badger大版本用自己的badger backup命令导出后即可迁移到当前版本
+func openSnapshot(ctx context.Context, file string, cfg *filecache.Config) (filecache.Cache, func() error, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("source: %w", err)
+	}
+	defer f.Close()
+
+	tmp, err := os.MkdirTemp("", "edgeorigin-migrate-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg.DataDir = tmp
+	cfg.EncryptionKey = "" // 备份中的数据已经解密
+	cache, err := filecache.NewBadgerCache(cfg)
+	if err != nil {
+		os.RemoveAll(tmp)
+		return nil, nil, err
+	}
+	closeCache := func() error {
+		err := cache.Close()
+		if rmErr := os.RemoveAll(tmp); err == nil {
+			err = rmErr
+		}
+		return err
+	}
+	if err := cache.(filecache.Snapshotter).RestoreSnapshot(ctx, f); err != nil {
+		closeCache()
+		return nil, nil, fmt.Errorf("failed to load snapshot %s: %w", file, err)
+	}
+	return cache, closeCache, nil
+}
+
+// migrateReport -output json时的迁移结果
+type migrateReport struct {
+	Source      string                  `json:"source"`
+	Destination string                  `json:"destination"`
+	DryRun      bool                    `json:"dry_run"`
+	Namespaces  []migrateNamespace      `json:"namespaces"`
+	Total       filecache.MigrateResult `json:"total"`
+	Duration    string                  `json:"duration"`
+}
+
+// migrateNamespace 一个命名空间的迁移结果，根命名空间为空
+type migrateNamespace struct {
+	Namespace string `json:"namespace"`
+	filecache.MigrateResult
+	Failures []string `json:"errors,omitempty"` // -keep-going时复制失败的文件
+}
+
+// runMigrate 把源后端所有命名空间中未过期的文件复制到目标后端，-output json时不打印进度，
+// 复制失败的文件记录在结果中
+func runMigrate(ctx context.Context, cmd *command, env *env, args []string) error {
+	fs := cmd.flags(env, nil)
+	from := fs.String("from", "", "source `backend`: "+backendUsage)
+	to := fs.String("to", "", "destination `backend`: badger:<dir> or fsblob:<dir>")
+	concurrency := fs.Int("concurrency", 4, "number of entries copied concurrently")
+	resume := fs.Bool("resume", false, "skip entries that already exist in the destination, to continue an interrupted migration")
+	dryRun := fs.Bool("dry-run", false, "report what would be copied without writing anything")
+	keepGoing := fs.Bool("keep-going", false, "continue after entries that fail to copy")
+	if _, err := cmd.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *from == "" || *to == "" {
+		fs.Usage()
+		return errUsage
+	}
+	src, err := parseBackend(*from)
+	if err != nil {
+		return err
+	}
+	dst, err := parseBackend(*to)
+	if err != nil {
+		return err
+	}
+	switch {
+	case dst.kind == "snapshot":
+		return errors.New("a snapshot can only be used as the source")
+	case src.path == dst.path:
+		return errors.New("source and destination must be different")
+	case *concurrency <= 0:
+		return errors.New("-concurrency must be positive")
+	}
+
+	srcCache, closeSrc, err := src.open(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer closeSrc()
+
+	// 试运行不创建目标，目标已存在时仍然检查是否为空以及哪些文件已经存在
+	var dstCache filecache.Cache
+	if !*dryRun || dst.exists() {
+		var closeDst func() error
+		if dstCache, closeDst, err = dst.open(ctx, false); err != nil {
+			return err
+		}
+		defer closeDst()
+		if !*resume {
+			if err := checkEmpty(ctx, dstCache); err != nil {
+				return fmt.Errorf("destination %s %w, use -resume to continue an interrupted migration", dst, err)
+			}
+		}
+	}
+
+	start := time.Now()
+	p := &progress{w: env.stderr, last: start}
+	report := &migrateReport{Source: src.String(), Destination: dst.String(), DryRun: *dryRun, Namespaces: []migrateNamespace{}}
+	total := &report.Total
+	w := tabwriter.NewWriter(env.stdout, 0, 4, 2, ' ', 0)
+	if !env.json() {
+		fmt.Fprintln(w, "NAMESPACE\tTOTAL\tCOPIED\tSKIPPED\tFAILED\tSIZE")
+	}
+	err = eachNamespace(ctx, srcCache, nil, func(names []string, src filecache.Cache) error {
+		label := strings.Join(names, "/")
+		var dst filecache.Cache
+		if dstCache != nil {
+			dst = namespaceOf(dstCache, names)
+		}
+
+		var result *filecache.MigrateResult
+		var err error
+		if *dryRun {
+			result, err = planMigrate(ctx, src, dst, *resume)
+		} else {
+			opts := filecache.MigrateOptions{
+				Concurrency:     *concurrency,
+				SkipExisting:    *resume,
+				ContinueOnError: *keepGoing,
+			}
+			if !env.json() {
+				opts.Progress = p.callback(label)
+			}
+			result, err = filecache.Migrate(ctx, src, dst, opts)
+		}
+		if result == nil {
+			return err
+		}
+		if env.json() {
+			ns := migrateNamespace{Namespace: label, MigrateResult: *result}
+			for _, e := range result.Errors {
+				ns.Failures = append(ns.Failures, e.Error())
+			}
+			report.Namespaces = append(report.Namespaces, ns)
+		} else {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\n", orDash(label), result.Total, result.Copied, result.Skipped, result.Failed, formatSize(result.Bytes))
+			for _, e := range result.Errors {
+				fmt.Fprintf(env.stderr, "edgeorigin migrate: %v\n", e)
+			}
+		}
+		total.Total += result.Total
+		total.Copied += result.Copied
+		total.Skipped += result.Skipped
+		total.Failed += result.Failed
+		total.Bytes += result.Bytes
+		return err
+	})
+	if flushErr := w.Flush(); err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case env.json():
+		report.Duration = time.Since(start).Round(time.Millisecond).String()
+		if err := writeJSON(env.stdout, report); err != nil {
+			return err
+		}
+	case *dryRun:
+		fmt.Fprintf(env.stdout, "dry run: would copy %d files (%s) from %s to %s\n", total.Copied, formatSize(total.Bytes), src, dst)
+		return nil
+	default:
+		fmt.Fprintf(env.stdout, "copied %d files (%s) from %s to %s in %s\n", total.Copied, formatSize(total.Bytes), src, dst, time.Since(start).Round(time.Millisecond))
+	}
+	if total.Failed > 0 {
+		return fmt.Errorf("%d files failed to copy, run again with -resume to retry them", total.Failed)
+	}
+	return nil
+}
+
+// checkEmpty 检查缓存中没有文件也没有子命名空间
+func checkEmpty(ctx context.Context, cache filecache.Cache) error {
+	files, err := cache.List(ctx)
+	if err != nil {
+		return err
+	}
+	if len(files) > 0 {
+		return errors.New("is not empty")
+	}
+	if lister, ok := cache.(filecache.NamespaceLister); ok {
+		names, err := lister.Namespaces(ctx)
+		if err != nil {
+			return err
+		}
+		if len(names) > 0 {
+			return errors.New("already has namespaces")
+		}
+	}
+	return nil
+}
+
+// planMigrate 统计Migrate会复制的文件，不写入目标；resume为true时跳过dst中已存在的文件
+func planMigrate(ctx context.Context, src, dst filecache.Cache, resume bool) (*filecache.MigrateResult, error) {
+	files, err := src.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source files: %w", err)
+	}
+	result := &filecache.MigrateResult{Total: int64(len(files))}
+	for _, file := range files {
+		if _, ok := file.RemainingTTL(); !ok {
+			result.Skipped++
+			continue
+		}
+		if resume && dst != nil {
+			exists, err := dst.Exists(ctx, file.Key)
+			if err != nil {
+				return result, err
+			}
+			if exists {
+				result.Skipped++
+				continue
+			}
+		}
+		result.Copied++
+		result.Bytes += file.Size
+	}
+	return result, nil
+}
+
+// eachNamespace 依次对cache及其所有子命名空间调用fn，names为从cache开始的命名空间路径
+func eachNamespace(ctx context.Context, cache filecache.Cache, names []string, fn func(names []string, ns filecache.Cache) error) error {
+	if err := fn(names, cache); err != nil {
+		return err
+	}
+	lister, ok := cache.(filecache.NamespaceLister)
+	if !ok {
+		return nil
+	}
+	children, err := lister.Namespaces(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range children {
+		path := append(names[:len(names):len(names)], name)
+		if err := eachNamespace(ctx, cache.Namespace(name), path, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// namespaceOf 返回cache中names指定的嵌套命名空间
+func namespaceOf(cache filecache.Cache, names []string) filecache.Cache {
+	for _, name := range names {
+		cache = cache.Namespace(name)
+	}
+	return cache
+}
+
+// progress 每秒最多打印一次迁移进度
+type progress struct {
+	w    io.Writer
+	last time.Time
+}
+
+// callback 返回用作MigrateOptions.Progress的回调，Migrate串行调用它
+func (p *progress) callback(label string) func(*filecache.MigrateResult) {
+	return func(r *filecache.MigrateResult) {
+		now := time.Now()
+		if now.Sub(p.last) < time.Second {
+			return
+		}
+		p.last = now
+		fmt.Fprintf(p.w, "migrating %s: %d/%d files, %s copied\n", orDash(label), r.Copied+r.Skipped+r.Failed, r.Total, formatSize(r.Bytes))
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	base := t.TempDir()
+	src := filepath.Join(base, "badger")
+	cache, err := filecache.NewBadgerCache(&filecache.Config{
+		DataDir:         src,
+		MaxCacheSize:    1 << 20,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	for _, c := range []filecache.Cache{cache, cache.Namespace("tenant").Namespace("site")} {
+		for _, key := range []string{"app.css", "app.js"} {
+			if err := c.Set(ctx, key, strings.NewReader(key), "text/plain", time.Hour); err != nil {
+				t.Fatalf("Failed to set file: %v", err)
+			}
+		}
+	}
+	if err := cache.Set(ctx, "old", strings.NewReader("old"), "text/plain", time.Millisecond); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+	snapshot, err := os.Create(filepath.Join(base, "old.bak"))
+	if err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+	if _, err := cache.(filecache.Snapshotter).Snapshot(ctx, snapshot, 0); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+	snapshot.Close()
+	if err := cache.Close(); err != nil {
+		t.Fatalf("Failed to close cache: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	dst := filepath.Join(base, "blobs")
+	t.Run("DryRun", func(t *testing.T) {
+		code, stdout, stderr := execute(t, "", "migrate", "-from", "badger:"+src, "-to", "fsblob:"+dst, "-dry-run")
+		if code != 0 || !strings.Contains(stdout, "would copy 4 files") {
+			t.Errorf("Expected 4 files to be planned, got %d:\n%s%s", code, stdout, stderr)
+		}
+		if _, err := os.Stat(dst); !os.IsNotExist(err) {
+			t.Errorf("Expected dry run not to create the destination, got %v", err)
+		}
+	})
+
+	t.Run("Copy", func(t *testing.T) {
+		code, stdout, stderr := execute(t, "", "migrate", "-from", "badger:"+src, "-to", "fsblob:"+dst)
+		if code != 0 || !strings.Contains(stdout, "copied 4 files (26B)") || !strings.Contains(stdout, "tenant/site  2      2") {
+			t.Fatalf("Expected 4 files to be copied, got %d:\n%s%s", code, stdout, stderr)
+		}
+		blobs, err := filecache.NewFSBlobCache(&filecache.Config{DataDir: dst, MaxCacheSize: 1 << 20, DefaultTTL: time.Hour, CleanupInterval: time.Hour})
+		if err != nil {
+			t.Fatalf("Failed to open destination: %v", err)
+		}
+		defer blobs.Close()
+		if got := readString(t, blobs.Namespace("tenant").Namespace("site"), "app.js"); got != "app.js" {
+			t.Errorf("Expected nested namespace to be migrated, got %q", got)
+		}
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		code, _, stderr := execute(t, "", "migrate", "-from", "badger:"+src, "-to", "fsblob:"+dst)
+		if code != 1 || !strings.Contains(stderr, "is not empty, use -resume") {
+			t.Errorf("Expected a non-empty destination to be rejected, got %d %s", code, stderr)
+		}
+		code, stdout, stderr := execute(t, "", "migrate", "-from", "badger:"+src, "-to", "fsblob:"+dst, "-resume")
+		if code != 0 || !strings.Contains(stdout, "copied 0 files") {
+			t.Errorf("Expected existing files to be skipped, got %d:\n%s%s", code, stdout, stderr)
+		}
+
+		var report migrateReport
+		code, stdout, stderr = execute(t, "", "migrate", "-from", "badger:"+src, "-to", "fsblob:"+dst, "-resume", "-output", "json")
+		decodeJSON(t, stdout, &report)
+		if code != 0 || len(report.Namespaces) != 3 || report.Namespaces[2].Namespace != "tenant/site" || report.Total.Skipped != 5 || stderr != "" {
+			t.Errorf("Expected the results as json, got %d %s %s", code, stdout, stderr)
+		}
+	})
+
+	t.Run("Snapshot", func(t *testing.T) {
+		upgraded := filepath.Join(base, "upgraded")
+		code, stdout, stderr := execute(t, "", "migrate", "-from", "snapshot:"+filepath.Join(base, "old.bak"), "-to", "badger:"+upgraded)
+		if code != 0 || !strings.Contains(stdout, "copied 4 files") {
+			t.Errorf("Expected the snapshot to be migrated, got %d:\n%s%s", code, stdout, stderr)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"-from", "badger:" + src},
+			{"-from", "badger:" + src, "-to", "s3:bucket"},
+			{"-from", "badger:" + src, "-to", "snapshot:" + filepath.Join(base, "new.bak")},
+			{"-from", "badger:" + src, "-to", "badger:" + src},
+			{"-from", "badger:" + filepath.Join(base, "missing"), "-to", "badger:" + filepath.Join(base, "new")},
+		} {
+			if code, _, _ := execute(t, "", append([]string{"migrate"}, args...)...); code == 0 {
+				t.Errorf("Expected %v to fail", args)
+			}
+		}
+	})
+}
+
+// readString 读取文件内容
+func readString(t *testing.T, cache filecache.Cache, key string) string {
+	t.Helper()
+	reader, _, err := cache.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Failed to get %s: %v", key, err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", key, err)
+	}
+	return string(data)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// outputFormat -output选项，命令结果的输出格式
+type outputFormat string
+
+const (
+	outputText outputFormat = "text"
+	outputJSON outputFormat = "json"
+)
+
+func (f *outputFormat) String() string {
+	return string(*f)
+}
+
+func (f *outputFormat) Set(value string) error {
+	switch v := outputFormat(value); v {
+	case outputText, outputJSON:
+		*f = v
+		return nil
+	}
+	return fmt.Errorf("invalid format %q, expected text or json", value)
+}
+
+// choices 返回可选的值，用于生成补全脚本
+func (f *outputFormat) choices() []string {
+	return []string{string(outputText), string(outputJSON)}
+}
+
+// json 是否以JSON输出结果
+func (e *env) json() bool {
+	return e.output == outputJSON
+}
+
+// textOnly 不支持JSON输出的命令（交互式界面、长期运行的服务等）在-output json时返回的错误
+func (e *env) textOnly(cmd *command) error {
+	if e.json() {
+		return fmt.Errorf("-output json is not supported by %s", cmd.name)
+	}
+	return nil
+}
+
+// writeJSON 把v以缩进的JSON写到w，每个命令在JSON模式下只输出一个值
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// jsonError JSON模式下写到标准错误的错误
+type jsonError struct {
+	Command string `json:"command"`
+	Error   string `json:"error"`
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// decodeJSON 解析命令的JSON输出
+func decodeJSON(t *testing.T, s string, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(s), v); err != nil {
+		t.Fatalf("Failed to decode %q: %v", s, err)
+	}
+}
+
+func TestOutput(t *testing.T) {
+	dir := t.TempDir()
+	code, _, stderr := execute(t, "", "stat", "-dir", dir, "-output", "json", "missing")
+	var e jsonError
+	decodeJSON(t, stderr, &e)
+	if code != 1 || e.Command != "stat" || !strings.Contains(e.Error, "not found") {
+		t.Errorf("Expected a json error, got %d %s", code, stderr)
+	}
+
+	if code, _, stderr := execute(t, "", "ls", "-dir", dir, "-output", "yaml"); code != 1 || !strings.Contains(stderr, "expected text or json") {
+		t.Errorf("Expected an invalid format to be rejected, got %d %s", code, stderr)
+	}
+	for _, args := range [][]string{
+		{"get", "-dir", dir, "-output", "json", "key"},
+		{"browse", "-dir", dir, "-output", "json"},
+		{"serve", "-c", "edgeorigin.yaml", "-output", "json"},
+		{"completion", "-output", "json", "bash"},
+	} {
+		if code, stdout, _ := execute(t, "", args...); code != 1 || stdout != "" {
+			t.Errorf("Expected %v to be rejected, got %d %q", args, code, stdout)
+		}
+	}
+}
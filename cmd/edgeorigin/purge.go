@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/seraphico/EdgeOrigin/pkg/server/admin"
+)
+
+// listFlag 可以重复的字符串选项
+type listFlag []string
+
+func (f *listFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *listFlag) Set(value string) error {
+	if value == "" {
+		return errors.New("value cannot be empty")
+	}
+	*f = append(*f, value)
+	return nil
+}
+
+// purgeResult -output json时一个前缀或标签的清除结果
+type purgeResult struct {
+	Prefix    string `json:"prefix,omitempty"`
+	OlderThan string `json:"older_than,omitempty"`
+	Tag       string `json:"tag,omitempty"`
+	Purged    int    `json:"purged"`
+}
+
+// runPurge 通过管理接口按前缀或标签清除运行中节点上的文件，供发布流程失效旧资源；
+// 某个前缀或标签清除失败时继续清除其余的
+func runPurge(ctx context.Context, cmd *command, env *env, args []string) error {
+	var t target
+	fs := cmd.flags(env, &t)
+	var prefixes, tags listFlag
+	fs.Var(&prefixes, "prefix", "purge entries whose key starts with `prefix`, repeat for several prefixes")
+	fs.Var(&tags, "tag", "purge entries carrying the cache `tag` (Surrogate-Key or Cache-Tag), repeat for several tags")
+	olderThan := fs.Duration("older-than", 0, "only purge -prefix entries created longer than `duration` ago, e.g. 24h")
+	if _, err := cmd.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	switch {
+	case len(prefixes) == 0 && len(tags) == 0:
+		fs.Usage()
+		return errUsage
+	case t.dir != "" || t.configFile != "":
+		return errors.New("purge works on a running node, use -remote")
+	case *olderThan < 0:
+		return errors.New("-older-than must be positive")
+	case *olderThan > 0 && len(tags) > 0:
+		return errors.New("-older-than cannot be combined with -tag, tag purges are handled by the node's tag index")
+	}
+
+	cache, closeCache, err := t.open()
+	if err != nil {
+		return err
+	}
+	defer closeCache()
+	client := cache.(*admin.Client)
+
+	var errs []error
+	total := 0
+	results := []purgeResult{}
+	report := func(selector string, result purgeResult, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", selector, err))
+			return
+		}
+		total += result.Purged
+		results = append(results, result)
+		if !env.json() {
+			fmt.Fprintf(env.stdout, "%s: purged %d entries\n", selector, result.Purged)
+		}
+	}
+	for _, prefix := range prefixes {
+		selector, result := "prefix "+prefix, purgeResult{Prefix: prefix}
+		if *olderThan > 0 {
+			selector += " older than " + olderThan.String()
+			result.OlderThan = olderThan.String()
+		}
+		n, err := client.PurgePrefix(ctx, prefix, *olderThan)
+		result.Purged = n
+		report(selector, result, err)
+	}
+	for _, tag := range tags {
+		n, err := client.PurgeTag(ctx, tag)
+		report("tag "+tag, purgeResult{Tag: tag, Purged: n}, err)
+	}
+	switch {
+	case env.json():
+		err := writeJSON(env.stdout, map[string]interface{}{"results": results, "purged": total})
+		if err != nil {
+			return err
+		}
+	case len(prefixes)+len(tags) > 1:
+		fmt.Fprintf(env.stdout, "purged %d entries\n", total)
+	}
+	return errors.Join(errs...)
+}
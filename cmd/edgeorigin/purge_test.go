@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+	"github.com/seraphico/EdgeOrigin/pkg/server/admin"
+)
+
+// tagPurger 删除键中包含标签的文件
+type tagPurger struct {
+	cache filecache.Cache
+}
+
+func (p *tagPurger) PurgeTag(ctx context.Context, tag string) (int, error) {
+	files, err := p.cache.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, info := range files {
+		if strings.Contains(info.Key, tag) {
+			if err := p.cache.Delete(ctx, info.Key); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func TestPurge(t *testing.T) {
+	ctx := context.Background()
+	cache, err := filecache.NewMemoryCacheWithConfig(&filecache.Config{MaxCacheSize: 1 << 20, DefaultTTL: time.Hour, CleanupInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+	handler, err := admin.NewHandler(cache, admin.Options{Token: "secret", TagPurger: &tagPurger{cache}})
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	t.Setenv("EDGEORIGIN_ADMIN_TOKEN", "secret")
+
+	for _, key := range []string{"assets/v1/app.js", "assets/v1/app.css", "assets/v2/app.js", "release-42/index.html"} {
+		if err := cache.Set(ctx, key, strings.NewReader("x"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set %s: %v", key, err)
+		}
+	}
+
+	t.Run("OlderThan", func(t *testing.T) {
+		code, stdout, stderr := execute(t, "", "purge", "-remote", srv.URL, "-prefix", "assets/v1/", "-older-than", "24h")
+		if code != 0 || stdout != "prefix assets/v1/ older than 24h0m0s: purged 0 entries\n" {
+			t.Errorf("Expected recent entries to be kept, got %d %q %s", code, stdout, stderr)
+		}
+	})
+
+	t.Run("PrefixAndTag", func(t *testing.T) {
+		code, stdout, stderr := execute(t, "", "purge", "-remote", srv.URL, "--prefix", "assets/v1/", "--tag", "release-42")
+		want := "prefix assets/v1/: purged 2 entries\ntag release-42: purged 1 entries\npurged 3 entries\n"
+		if code != 0 || stdout != want {
+			t.Errorf("Expected %q, got %d %q %s", want, code, stdout, stderr)
+		}
+		files, _ := cache.List(ctx)
+		if len(files) != 1 || files[0].Key != "assets/v2/app.js" {
+			t.Errorf("Expected only assets/v2/app.js to be kept, got %v", files)
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		code, stdout, stderr := execute(t, "", "purge", "-remote", srv.URL, "-output", "json", "-prefix", "assets/", "-older-than", "1ns", "-prefix", "missing/")
+		var result struct {
+			Results []purgeResult `json:"results"`
+			Purged  int           `json:"purged"`
+		}
+		decodeJSON(t, stdout, &result)
+		if code != 0 || result.Purged != 1 || len(result.Results) != 2 || result.Results[0] != (purgeResult{Prefix: "assets/", OlderThan: "1ns", Purged: 1}) {
+			t.Errorf("Expected the purge results as json, got %d %s %s", code, stdout, stderr)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		if code, _, _ := execute(t, "", "purge", "-remote", srv.URL); code != 2 {
+			t.Errorf("Expected exit code 2 without -prefix or -tag, got %d", code)
+		}
+		for _, args := range [][]string{
+			{"purge", "-remote", srv.URL, "-prefix", ""},
+			{"purge", "-dir", t.TempDir(), "-prefix", "a"},
+			{"purge", "-remote", srv.URL, "-tag", "a", "-older-than", "1h"},
+			{"purge", "-remote", srv.URL, "-prefix", "a", "-older-than", "-1h"},
+			{"purge", "-remote", srv.URL, "-token", "wrong", "-prefix", "a"},
+		} {
+			if code, _, _ := execute(t, "", args...); code != 1 {
+				t.Errorf("Expected exit code 1 for %v, got %d", args, code)
+			}
+		}
+	})
+}
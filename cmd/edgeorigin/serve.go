@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/config"
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+	"github.com/seraphico/EdgeOrigin/pkg/origin"
+	"github.com/seraphico/EdgeOrigin/pkg/server/admin"
+	"github.com/seraphico/EdgeOrigin/pkg/server/edge"
+	"github.com/seraphico/EdgeOrigin/pkg/server/metrics"
+	"github.com/seraphico/EdgeOrigin/pkg/server/tlsutil"
+)
+
+const (
+	// defaultAdminPath TCP监听上管理接口的默认挂载路径
+	defaultAdminPath = "/admin"
+	// defaultMetricsPath 指标的默认路径
+	defaultMetricsPath = "/metrics"
+	// adminSocketMode 管理接口套接字文件的权限
+	adminSocketMode = 0660
+)
+
+// runServe 按配置文件运行完整的边缘节点：打开缓存，为每个站点创建回源代理，在边缘监听上服务客户端，
+// 按配置提供管理接口和Prometheus指标。收到SIGHUP（-watch时还有文件修改）时重新加载配置，
+// ctx结束（SIGINT或SIGTERM）时停止接受新连接，等待进行中的请求完成后关闭缓存
+func runServe(ctx context.Context, cmd *command, env *env, args []string) (err error) {
+	fs := cmd.flags(env, nil)
+	var file string
+	fs.StringVar(&file, "c", "", "configuration `file` (.json, .yaml, .yml or .toml)")
+	fs.StringVar(&file, "config", "", "same as -c `file`")
+	watch := fs.Bool("watch", false, "also reload the configuration when the file changes")
+	grace := fs.Duration("shutdown-timeout", 30*time.Second, "how long to wait for in-flight requests when shutting down")
+	if _, err := cmd.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if file == "" {
+		fs.Usage()
+		return errUsage
+	}
+	if err := env.textOnly(cmd); err != nil {
+		return err
+	}
+	if *grace <= 0 {
+		return errors.New("-shutdown-timeout must be positive")
+	}
+	cfg, err := config.Load(file)
+	if err != nil {
+		return err
+	}
+	if len(cfg.Sites) == 0 {
+		return errors.New("no sites configured, nothing to serve")
+	}
+	logger := log.New(env.stderr, "", log.LstdFlags)
+
+	cache, err := filecache.NewCacheWithConfig(&cfg.Cache)
+	if err != nil {
+		return fmt.Errorf("failed to open cache: %w", err)
+	}
+	defer closeWith(&err, cache.Close)
+	router, err := origin.NewRouter(cache, cfg.Sites)
+	if err != nil {
+		return err
+	}
+	defer closeWith(&err, router.Close)
+
+	edgeCfg := edge.Config{}
+	if cfg.Server != nil {
+		edgeCfg = *cfg.Server
+	}
+	if cfg.AccessLog != nil {
+		opts, sink, logErr := cfg.AccessLog.Options()
+		if logErr != nil {
+			return fmt.Errorf("failed to open access log: %w", logErr)
+		}
+		if sink != nil {
+			defer closeWith(&err, sink.Close)
+		}
+		edgeCfg.AccessLog = &opts
+	}
+	server, err := edge.NewServer(router, edgeCfg)
+	if err != nil {
+		return err
+	}
+
+	reloadOpts := config.ReloadOptions{
+		Router: router,
+		OnReload: func(_ *config.Config, err error) {
+			if err != nil {
+				logger.Printf("failed to reload %s: %v", file, err)
+				return
+			}
+			logger.Printf("reloaded %s", file)
+		},
+	}
+	if _, ok := cache.(filecache.Reconfigurer); ok {
+		reloadOpts.Cache = cache
+	}
+	if cfg.AccessLog != nil {
+		reloadOpts.Server = server
+	}
+	reloader, err := config.NewReloader(file, cfg, reloadOpts)
+	if err != nil {
+		return err
+	}
+
+	// 先建立管理接口和指标的监听，端口被占用时在开始服务之前退出
+	aux, err := listenAux(cfg, cache, router, reloader)
+	for _, s := range aux {
+		defer s.lis.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	errc := make(chan error, len(aux)+1)
+	go func() { errc <- server.ListenAndServe() }()
+	for _, s := range aux {
+		s := s
+		logger.Printf("serving %s on %s", s.name, s.addr)
+		go func() { errc <- s.srv.Serve(s.lis) }()
+	}
+	logger.Printf("serving %d sites on %s", len(cfg.Sites), edgeAddr(edgeCfg))
+
+	loops, stopLoops := context.WithCancel(ctx)
+	defer stopLoops()
+	go reloader.HandleSignals(loops)
+	if *watch {
+		go reloader.Watch(loops, 0)
+	}
+
+	running := 1 + len(aux)
+	select {
+	case <-ctx.Done():
+		logger.Printf("shutting down, waiting up to %s for in-flight requests", *grace)
+	case err = <-errc:
+		running--
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+	}
+	stopLoops()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *grace)
+	defer cancel()
+	shutdownErr := server.Shutdown(shutdownCtx)
+	for _, s := range aux {
+		if err := s.srv.Shutdown(shutdownCtx); shutdownErr == nil {
+			shutdownErr = err
+		}
+	}
+	for ; running > 0; running-- {
+		if e := <-errc; err == nil && !errors.Is(e, http.ErrServerClosed) {
+			err = e
+		}
+	}
+	if err == nil {
+		err = shutdownErr
+	}
+	return err
+}
+
+// closeWith 调用close，*err为空时记录close返回的错误；用于defer，使关闭缓存的错误也能报告
+func closeWith(err *error, close func() error) {
+	if closeErr := close(); *err == nil {
+		*err = closeErr
+	}
+}
+
+// auxServer 边缘监听之外的管理接口或指标监听
+type auxServer struct {
+	name string
+	addr string
+	lis  net.Listener
+	srv  *http.Server
+}
+
+// listenAux 按配置建立管理接口（TCP和Unix套接字）和指标的监听，TCP监听按配置使用TLS；
+// 出错时仍返回已经建立的监听，由调用方关闭
+func listenAux(cfg *config.Config, cache filecache.Cache, router *origin.Router, reloader *config.Reloader) ([]*auxServer, error) {
+	var servers []*auxServer
+	add := func(name, network, addr string, tlsConfig *tlsutil.Config, handler http.Handler) error {
+		var lis net.Listener
+		var err error
+		if network == "unix" {
+			lis, err = admin.ListenUnix(addr, adminSocketMode)
+		} else {
+			lis, err = tlsutil.Listen(addr, tlsConfig)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to listen for %s: %w", name, err)
+		}
+		servers = append(servers, &auxServer{
+			name: name,
+			addr: lis.Addr().String(),
+			lis:  lis,
+			srv:  &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second},
+		})
+		return nil
+	}
+
+	if a := cfg.Admin; a != nil {
+		handler, err := admin.NewHandler(cache, admin.Options{
+			Token:     a.Token,
+			APIKeys:   cfg.Cache.APIKeys,
+			TagPurger: router,
+			Upstreams: router,
+			Reload:    reloader.Reload,
+		})
+		if err != nil {
+			return servers, err
+		}
+		if a.Addr != "" {
+			path := strings.TrimSuffix(a.Path, "/")
+			if a.Path == "" {
+				path = defaultAdminPath
+			}
+			mux := http.NewServeMux()
+			mux.Handle(path+"/", http.StripPrefix(path, handler))
+			if err := add("admin api", "tcp", a.Addr, a.TLS, mux); err != nil {
+				return servers, err
+			}
+		}
+		if a.Socket != "" {
+			if err := add("admin api", "unix", a.Socket, nil, handler); err != nil {
+				return servers, err
+			}
+		}
+	}
+
+	if m := cfg.Metrics; m != nil {
+		path := m.Path
+		if path == "" {
+			path = defaultMetricsPath
+		}
+		mux := http.NewServeMux()
+		mux.Handle(path, metrics.NewHandler(cache, metrics.Options{Upstreams: router}))
+		if err := add("metrics", "tcp", m.Addr, m.TLS, mux); err != nil {
+			return servers, err
+		}
+	}
+	return servers, nil
+}
+
+// edgeAddr 返回边缘监听的地址，与edge.NewServer的默认值相同
+func edgeAddr(cfg edge.Config) string {
+	switch {
+	case cfg.Addr != "":
+		return cfg.Addr
+	case cfg.TLS != nil:
+		return ":443"
+	default:
+		return ":80"
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/internal/tlstest"
+)
+
+// freeAddr 返回本机一个空闲的TCP地址
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer lis.Close()
+	return lis.Addr().String()
+}
+
+// fetch 发送请求并返回状态码和响应体，连接失败时返回0
+func fetch(method, url, token string) (int, string) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return 0, err.Error()
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err.Error()
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestServe(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		io.WriteString(w, "from origin "+r.URL.Path)
+	}))
+	defer upstream.Close()
+
+	edgeAddr, adminAddr, metricsAddr := freeAddr(t), freeAddr(t), freeAddr(t)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "edgeorigin.yaml")
+	err := os.WriteFile(file, []byte(`
+cache:
+  data_dir: `+filepath.Join(dir, "cache")+`
+sites:
+  - hosts: ["*"]
+    namespace: default
+    options:
+      upstream: `+upstream.URL+`
+server:
+  addr: `+edgeAddr+`
+admin:
+  addr: `+adminAddr+`
+  token: secret
+metrics:
+  addr: `+metricsAddr+`
+`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	done := make(chan int, 1)
+	go func() {
+		done <- run(ctx, &env{stdin: strings.NewReader(""), stdout: &stdout, stderr: &stderr}, []string{"serve", "-c", file})
+	}()
+
+	var code int
+	var body string
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if code, body = fetch(http.MethodGet, "http://"+edgeAddr+"/app.js", ""); code != 0 {
+			break
+		}
+	}
+	if code != http.StatusOK || body != "from origin /app.js" {
+		cancel()
+		<-done
+		t.Fatalf("Expected the proxied response, got %d %q\n%s", code, body, stderr.String())
+	}
+
+	if code, body := fetch(http.MethodGet, "http://"+adminAddr+"/admin/entries?namespace=default", "secret"); code != http.StatusOK || !strings.Contains(body, `"total":1`) {
+		t.Errorf("Expected the cached entry in the admin api, got %d %s", code, body)
+	}
+	if code, _ := fetch(http.MethodGet, "http://"+adminAddr+"/admin/stats", ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %d", code)
+	}
+	if code, _ := fetch(http.MethodPost, "http://"+adminAddr+"/admin/reload", "secret"); code != http.StatusOK {
+		t.Errorf("Expected reload to succeed, got %d", code)
+	}
+	if code, body := fetch(http.MethodGet, "http://"+metricsAddr+"/metrics", ""); code != http.StatusOK || !strings.Contains(body, `edgeorigin_cache_files{namespace="default"} 1`) {
+		t.Errorf("Expected metrics of the site namespace, got %d %s", code, body)
+	}
+
+	cancel()
+	select {
+	case code := <-done:
+		if code != 0 {
+			t.Errorf("Expected a clean shutdown, got exit code %d: %s", code, stderr.String())
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected serve to stop after the context is canceled")
+	}
+	for _, want := range []string{"serving admin api on " + adminAddr, "serving 1 sites on " + edgeAddr, "reloaded " + file, "shutting down"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("Expected log to contain %q, got:\n%s", want, stderr.String())
+		}
+	}
+	if code, _ := fetch(http.MethodGet, "http://"+edgeAddr+"/app.js", ""); code != 0 {
+		t.Errorf("Expected the edge listener to be closed, got %d", code)
+	}
+}
+
+func TestServeTLS(t *testing.T) {
+	edgeAddr, adminAddr, metricsAddr := freeAddr(t), freeAddr(t), freeAddr(t)
+	dir := t.TempDir()
+	ca := tlstest.NewCA(t, dir, "edge-ca")
+	cert, key := ca.Issue("edge-1", 2, x509.ExtKeyUsageServerAuth)
+	file := filepath.Join(dir, "edgeorigin.yaml")
+	err := os.WriteFile(file, []byte(`
+cache:
+  data_dir: `+filepath.Join(dir, "cache")+`
+sites:
+  - hosts: ["*"]
+    options:
+      upstream: http://127.0.0.1:1
+server:
+  addr: `+edgeAddr+`
+admin:
+  addr: `+adminAddr+`
+  token: secret
+  tls:
+    cert_file: `+cert+`
+    key_file: `+key+`
+metrics:
+  addr: `+metricsAddr+`
+  tls:
+    cert_file: `+cert+`
+    key_file: `+key+`
+`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	done := make(chan int, 1)
+	go func() {
+		done <- run(ctx, &env{stdin: strings.NewReader(""), stdout: &stdout, stderr: &stderr}, []string{"serve", "-c", file})
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	pool := x509.NewCertPool()
+	pem, _ := os.ReadFile(ca.File)
+	pool.AppendCertsFromPEM(pem)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, ServerName: "edge-1"}}}
+	get := func(url string) (int, error) {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	var code int
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if code, err = get("https://" + adminAddr + "/admin/stats"); err == nil {
+			break
+		}
+	}
+	if code != http.StatusOK {
+		t.Fatalf("Expected the admin api over tls, got %d %v\n%s", code, err, stderr.String())
+	}
+	if code, err := get("https://" + metricsAddr + "/metrics"); code != http.StatusOK {
+		t.Errorf("Expected metrics over tls, got %d %v", code, err)
+	}
+	if code, _ := fetch(http.MethodGet, "http://"+adminAddr+"/admin/stats", "secret"); code == http.StatusOK {
+		t.Error("Expected the admin api to refuse plain http")
+	}
+}
+
+func TestServeInvalid(t *testing.T) {
+	dir := t.TempDir()
+	noSites := filepath.Join(dir, "empty.yaml")
+	if err := os.WriteFile(noSites, []byte("cache:\n  data_dir: "+filepath.Join(dir, "cache")+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer busy.Close()
+	taken := filepath.Join(dir, "taken.yaml")
+	err = os.WriteFile(taken, []byte(`
+cache:
+  data_dir: `+filepath.Join(dir, "cache")+`
+sites:
+  - hosts: ["*"]
+    options:
+      upstream: http://127.0.0.1:1
+server:
+  addr: `+freeAddr(t)+`
+metrics:
+  addr: `+busy.Addr().String()+`
+`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	if code, _, _ := execute(t, "", "serve"); code != 2 {
+		t.Errorf("Expected exit code 2 without a config file, got %d", code)
+	}
+	for _, c := range []struct {
+		file string
+		want string
+	}{
+		{filepath.Join(dir, "missing.yaml"), "failed to read config file"},
+		{noSites, "no sites configured"},
+		{taken, "failed to listen for metrics"},
+	} {
+		if code, _, stderr := execute(t, "", "serve", "-c", c.file); code != 1 || !strings.Contains(stderr, c.want) {
+			t.Errorf("Expected %q, got %d %s", c.want, code, stderr)
+		}
+	}
+}
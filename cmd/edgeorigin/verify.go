@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// runVerify 离线检查本机数据目录中的每个条目，-repair时修复能够修复的问题；
+// 仍有未修复的问题时以错误退出，便于在启动脚本中使用
+func runVerify(ctx context.Context, cmd *command, env *env, args []string) error {
+	var t target
+	flags := cmd.flags(env, &t)
+	repair := flags.Bool("repair", false, "remove unpaired or unreadable entries, fix recorded sizes and recompute stats")
+	args, err := cmd.parse(flags, args, 0, 1)
+	if err != nil {
+		return err
+	}
+	if err := t.wholeDir(args); err != nil {
+		return err
+	}
+
+	cache, closeCache, err := t.open()
+	if err != nil {
+		return err
+	}
+	defer closeCache()
+	verifier, ok := cache.(filecache.Verifier)
+	if !ok {
+		return errors.New("verification is not supported")
+	}
+	report, err := verifier.Verify(ctx, filecache.VerifyOptions{Repair: *repair})
+	if report != nil {
+		if err := printReport(env, report); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if err := closeCache(); err != nil {
+		return err
+	}
+
+	switch n := report.Unrepaired(); {
+	case n == 0:
+		return nil
+	case *repair:
+		return fmt.Errorf("%d problems could not be repaired", n)
+	default:
+		return fmt.Errorf("%d problems found, run with -repair to fix them", n)
+	}
+}
+
+// printReport 打印发现的问题和检查的条目数，-output json时输出整个报告
+func printReport(env *env, report *filecache.VerifyReport) error {
+	if env.json() {
+		if report.Problems == nil {
+			report.Problems = []filecache.VerifyProblem{}
+		}
+		return writeJSON(env.stdout, report)
+	}
+	if len(report.Problems) > 0 {
+		w := tabwriter.NewWriter(env.stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "PROBLEM\tNAMESPACE\tKEY\tSTATUS\tDETAIL")
+		for _, p := range report.Problems {
+			key, status := p.Key, "found"
+			if p.Trash {
+				key += " (trash)"
+			}
+			if p.Repaired {
+				status = "repaired"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", p.Kind, orDash(p.Namespace), orDash(key), status, p.Detail)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(env.stdout, "checked %d entries (%s), %d problems, %d repaired\n",
+		report.Entries, formatSize(report.Bytes), len(report.Problems), len(report.Problems)-report.Unrepaired())
+	return err
+}
+
+// orDash 空字符串显示为-
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
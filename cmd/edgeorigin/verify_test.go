@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := filecache.NewBadgerCache(&filecache.Config{
+		DataDir:         dir,
+		MaxCacheSize:    1 << 20,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	if err := cache.Set(context.Background(), "app.css", strings.NewReader("body{}"), "text/css", time.Hour); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+	if err := cache.Close(); err != nil {
+		t.Fatalf("Failed to close cache: %v", err)
+	}
+
+	code, stdout, stderr := execute(t, "", "verify", dir)
+	if code != 0 || stdout != "checked 1 entries (6B), 0 problems, 0 repaired\n" {
+		t.Fatalf("Expected a clean report, got %d %q %s", code, stdout, stderr)
+	}
+
+	// 模拟崩溃留下的没有FileInfo的文件数据
+	db, err := badger.Open(badger.DefaultOptions(filepath.Join(dir, "badger")).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open badger: %v", err)
+	}
+	err = db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte("file:orphan"), []byte("data"))
+	})
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		t.Fatalf("Failed to write orphan: %v", err)
+	}
+
+	code, stdout, stderr = execute(t, "", "verify", "-dir", dir)
+	if code != 1 || !strings.Contains(stdout, "orphaned_data  -          orphan  found") || !strings.Contains(stderr, "run with -repair") {
+		t.Errorf("Expected the orphan to be reported, got %d:\n%s%s", code, stdout, stderr)
+	}
+	var report filecache.VerifyReport
+	code, stdout, _ = execute(t, "", "verify", "-output", "json", dir)
+	decodeJSON(t, stdout, &report)
+	if code != 1 || report.Entries != 1 || len(report.Problems) != 1 || report.Problems[0].Kind != filecache.ProblemOrphanedData {
+		t.Errorf("Expected the report as json, got %d %s", code, stdout)
+	}
+	code, stdout, stderr = execute(t, "", "verify", "-repair", dir)
+	if code != 0 || !strings.Contains(stdout, "1 problems, 1 repaired") {
+		t.Errorf("Expected the orphan to be repaired, got %d:\n%s%s", code, stdout, stderr)
+	}
+	if code, stdout, _ := execute(t, "", "verify", dir); code != 0 || !strings.Contains(stdout, "0 problems") {
+		t.Errorf("Expected no problems after repair, got %d:\n%s", code, stdout)
+	}
+	if code, _, _ := execute(t, "", "verify", "-namespace", "tenant", dir); code != 1 {
+		t.Errorf("Expected exit code 1 for -namespace, got %d", code)
+	}
+}
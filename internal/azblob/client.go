@@ -0,0 +1,334 @@
+// Package azblob 是基于net/http的最小Azure Blob Storage客户端，支持Shared Key和SAS认证
+package azblob
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// APIVersion 使用的REST API版本，blob索引标签和按标签查找需要2021-04-10及以上
+const APIVersion = "2021-12-02"
+
+var (
+	// ErrNotFound blob不存在
+	ErrNotFound = errors.New("blob not found")
+	// ErrConditionNotMet 条件请求（If-Match、x-ms-if-tags等）的条件不满足
+	ErrConditionNotMet = errors.New("condition not met")
+)
+
+// Config Azure Blob客户端配置
+type Config struct {
+	Account    string       // 存储账户
+	AccountKey string       // base64编码的账户密钥，为空时使用SASToken
+	SASToken   string       // SAS令牌（不含前导?）
+	Endpoint   string       // 服务地址，默认 https://<account>.blob.core.windows.net；Azurite为 http://127.0.0.1:10000/<account>
+	Container  string       // 容器
+	HTTPClient *http.Client // 自定义HTTP客户端
+}
+
+// Client Azure Blob客户端
+type Client struct {
+	cfg      Config
+	key      []byte
+	endpoint *url.URL
+	sas      url.Values
+	http     *http.Client
+	now      func() time.Time
+}
+
+// Error Azure返回的错误
+type Error struct {
+	StatusCode int    `xml:"-"`
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("azblob: unexpected status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("azblob: %s (%d): %s", e.Code, e.StatusCode, e.Message)
+}
+
+// Blob 列表中的blob
+type Blob struct {
+	Name       string
+	Size       int64
+	MimeType   string
+	CreatedAt  time.Time
+	AccessTier string
+	Metadata   map[string]string
+	Tags       map[string]string
+}
+
+// ListResult List Blobs结果
+type ListResult struct {
+	Blobs      []Blob
+	NextMarker string
+}
+
+// New 创建Azure Blob客户端
+func New(cfg Config) (*Client, error) {
+	if cfg.Account == "" {
+		return nil, fmt.Errorf("azure account cannot be empty")
+	}
+	if cfg.Container == "" {
+		return nil, fmt.Errorf("azure container cannot be empty")
+	}
+	if cfg.AccountKey == "" && cfg.SASToken == "" {
+		return nil, fmt.Errorf("azure account key or sas token is required")
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://" + cfg.Account + ".blob.core.windows.net"
+	}
+
+	c := &Client{cfg: cfg, http: cfg.HTTPClient, now: time.Now}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+
+	var err error
+	if cfg.AccountKey != "" {
+		if c.key, err = base64.StdEncoding.DecodeString(cfg.AccountKey); err != nil {
+			return nil, fmt.Errorf("invalid azure account key: %w", err)
+		}
+	}
+	if cfg.SASToken != "" {
+		if c.sas, err = url.ParseQuery(strings.TrimPrefix(cfg.SASToken, "?")); err != nil {
+			return nil, fmt.Errorf("invalid azure sas token: %w", err)
+		}
+	}
+	if c.endpoint, err = url.Parse(strings.TrimSuffix(cfg.Endpoint, "/")); err != nil {
+		return nil, fmt.Errorf("invalid azure endpoint: %w", err)
+	}
+	if c.endpoint.Scheme == "" || c.endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid azure endpoint: %q", cfg.Endpoint)
+	}
+
+	return c, nil
+}
+
+// Container 返回容器名称
+func (c *Client) Container() string {
+	return c.cfg.Container
+}
+
+// PutBlob 上传块blob，header可包含Content-Type、x-ms-meta-*、x-ms-tags、x-ms-access-tier
+func (c *Client) PutBlob(ctx context.Context, name string, body io.Reader, size int64, header http.Header) error {
+	h := http.Header{"X-Ms-Blob-Type": {"BlockBlob"}}
+	for k, v := range header {
+		h[k] = v
+	}
+	resp, err := c.do(ctx, http.MethodPut, name, nil, h, body, size)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// GetBlob 下载blob，调用方负责关闭响应体
+func (c *Client) GetBlob(ctx context.Context, name string, header http.Header) (*http.Response, error) {
+	return c.do(ctx, http.MethodGet, name, nil, header, nil, 0)
+}
+
+// GetProperties 获取blob属性和元数据
+func (c *Client) GetProperties(ctx context.Context, name string) (http.Header, error) {
+	resp, err := c.do(ctx, http.MethodHead, name, nil, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp.Header, nil
+}
+
+// DeleteBlob 删除blob，header可包含If-Match或x-ms-if-tags条件
+func (c *Client) DeleteBlob(ctx context.Context, name string, header http.Header) error {
+	resp, err := c.do(ctx, http.MethodDelete, name, nil, header, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// SetTier 设置blob访问层（Hot、Cool、Cold、Archive）
+func (c *Client) SetTier(ctx context.Context, name, tier string) error {
+	resp, err := c.do(ctx, http.MethodPut, name, url.Values{"comp": {"tier"}},
+		http.Header{"X-Ms-Access-Tier": {tier}}, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ListBlobs 按前缀列出blob及其元数据和索引标签，marker为上一页的NextMarker
+func (c *Client) ListBlobs(ctx context.Context, prefix, marker string) (*ListResult, error) {
+	query := url.Values{
+		"restype": {"container"},
+		"comp":    {"list"},
+		"include": {"metadata,tags"},
+	}
+	if prefix != "" {
+		query.Set("prefix", prefix)
+	}
+	if marker != "" {
+		query.Set("marker", marker)
+	}
+	return c.list(ctx, query)
+}
+
+// FindBlobsByTags 按索引标签表达式查找容器中的blob，返回的Blob只包含名称和标签
+// 例如 "expires-at" < '2024-01-01T00:00:00Z'
+func (c *Client) FindBlobsByTags(ctx context.Context, where, marker string) (*ListResult, error) {
+	query := url.Values{
+		"restype": {"container"},
+		"comp":    {"blobs"},
+		"where":   {where},
+	}
+	if marker != "" {
+		query.Set("marker", marker)
+	}
+	return c.list(ctx, query)
+}
+
+// list 发送容器级列表请求并解析结果
+func (c *Client) list(ctx context.Context, query url.Values) (*ListResult, error) {
+	resp, err := c.do(ctx, http.MethodGet, "", query, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body enumerationResults
+	if err := xml.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode list result: %w", err)
+	}
+
+	result := &ListResult{NextMarker: body.NextMarker}
+	for _, b := range body.Blobs {
+		blob := Blob{
+			Name:       b.Name,
+			Size:       b.Properties.ContentLength,
+			MimeType:   b.Properties.ContentType,
+			AccessTier: b.Properties.AccessTier,
+			Metadata:   make(map[string]string),
+			Tags:       make(map[string]string),
+		}
+		if t, err := http.ParseTime(b.Properties.CreationTime); err == nil {
+			blob.CreatedAt = t
+		}
+		for _, item := range b.Metadata.Items {
+			blob.Metadata[strings.ToLower(item.XMLName.Local)] = item.Value
+		}
+		for _, tag := range b.Tags.Tags {
+			blob.Tags[tag.Key] = tag.Value
+		}
+		result.Blobs = append(result.Blobs, blob)
+	}
+	return result, nil
+}
+
+// blobURL 返回容器或blob的地址，blob名称按路径段转义
+func (c *Client) blobURL(name string, query url.Values) *url.URL {
+	u := *c.endpoint
+	path := u.EscapedPath() + "/" + url.PathEscape(c.cfg.Container)
+	if name != "" {
+		segments := strings.Split(name, "/")
+		for i, s := range segments {
+			segments[i] = url.PathEscape(s)
+		}
+		path += "/" + strings.Join(segments, "/")
+	}
+	u.RawPath = path
+	u.Path, _ = url.PathUnescape(path)
+
+	values := url.Values{}
+	for k, v := range query {
+		values[k] = v
+	}
+	for k, v := range c.sas {
+		values[k] = v
+	}
+	// Azure不把+解释为空格
+	u.RawQuery = strings.ReplaceAll(values.Encode(), "+", "%20")
+	return &u
+}
+
+// do 发送签名请求，非2xx响应转换为错误
+func (c *Client) do(ctx context.Context, method, name string, query url.Values, header http.Header, body io.Reader, size int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.blobURL(name, query).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	req.Header.Set("X-Ms-Version", APIVersion)
+	req.Header.Set("X-Ms-Date", c.now().UTC().Format(http.TimeFormat))
+	if c.key != nil {
+		SignSharedKey(req, c.cfg.Account, c.key)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 || resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	case http.StatusPreconditionFailed:
+		return nil, ErrConditionNotMet
+	}
+	azErr := &Error{StatusCode: resp.StatusCode}
+	if method != http.MethodHead {
+		xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(azErr)
+	}
+	if azErr.Code == "" {
+		azErr.Code = resp.Header.Get("X-Ms-Error-Code")
+	}
+	return nil, azErr
+}
+
+// enumerationResults List Blobs和Find Blobs by Tags的响应
+type enumerationResults struct {
+	Blobs []struct {
+		Name       string `xml:"Name"`
+		Properties struct {
+			CreationTime  string `xml:"Creation-Time"`
+			ContentLength int64  `xml:"Content-Length"`
+			ContentType   string `xml:"Content-Type"`
+			AccessTier    string `xml:"AccessTier"`
+		} `xml:"Properties"`
+		Metadata struct {
+			Items []struct {
+				XMLName xml.Name
+				Value   string `xml:",chardata"`
+			} `xml:",any"`
+		} `xml:"Metadata"`
+		Tags struct {
+			Tags []struct {
+				Key   string `xml:"Key"`
+				Value string `xml:"Value"`
+			} `xml:"TagSet>Tag"`
+		} `xml:"Tags"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
@@ -0,0 +1,54 @@
+package azblob
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCanonicalResource(t *testing.T) {
+	u, _ := url.Parse("http://127.0.0.1:10000/devstoreaccount1/cache/edge/a%20b.png?restype=container&Comp=list&include=tags&include=metadata")
+	got := canonicalResource(u, "devstoreaccount1")
+	want := "/devstoreaccount1/devstoreaccount1/cache/edge/a%20b.png\ncomp:list\ninclude:metadata,tags\nrestype:container"
+	if got != want {
+		t.Errorf("Unexpected canonical resource:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCanonicalHeaders(t *testing.T) {
+	header := http.Header{
+		"X-Ms-Version":   {"2021-12-02"},
+		"X-Ms-Date":      {"Mon, 02 Jan 2006 15:04:05 GMT"},
+		"X-Ms-Meta-Name": {" value "},
+		"Content-Type":   {"text/plain"},
+	}
+	got := canonicalHeaders(header)
+	want := "x-ms-date:Mon, 02 Jan 2006 15:04:05 GMT\nx-ms-meta-name:value\nx-ms-version:2021-12-02\n"
+	if got != want {
+		t.Errorf("Unexpected canonical headers:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSignSharedKey(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://account.blob.core.windows.net/cache/key", nil)
+	req.Header.Set("X-Ms-Date", "Mon, 02 Jan 2006 15:04:05 GMT")
+	SignSharedKey(req, "account", []byte("secret"))
+
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "SharedKey account:") {
+		t.Fatalf("Unexpected authorization: %s", auth)
+	}
+
+	// 相同请求签名稳定，修改被签名的请求头后签名改变
+	first := auth
+	SignSharedKey(req, "account", []byte("secret"))
+	if req.Header.Get("Authorization") != first {
+		t.Error("Expected stable signature")
+	}
+	req.Header.Set("X-Ms-Date", "Tue, 03 Jan 2006 15:04:05 GMT")
+	SignSharedKey(req, "account", []byte("secret"))
+	if req.Header.Get("Authorization") == first {
+		t.Error("Expected signature to change with x-ms-date")
+	}
+}
@@ -0,0 +1,409 @@
+// Package azuretest 提供用于测试的内存Azure Blob服务，使用Azurite风格的 /<account>/<container>/<blob> 地址
+package azuretest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/internal/azblob"
+)
+
+// AccountKey 测试账户密钥（base64编码）
+var AccountKey = base64.StdEncoding.EncodeToString([]byte("azuretest-account-key"))
+
+// blob 存储的blob
+type blob struct {
+	data      []byte
+	header    http.Header
+	tags      map[string]string
+	tier      string
+	etag      string
+	createdAt time.Time
+}
+
+// Server 内存Azure Blob服务
+type Server struct {
+	*httptest.Server
+
+	account    string
+	mu         sync.Mutex
+	containers map[string]map[string]*blob
+}
+
+// NewServer 启动内存Azure Blob服务，container为预先创建的容器
+func NewServer(account string, containers ...string) *Server {
+	s := &Server{account: account, containers: make(map[string]map[string]*blob)}
+	for _, container := range containers {
+		s.containers[container] = make(map[string]*blob)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Endpoint 返回客户端使用的服务地址
+func (s *Server) Endpoint() string {
+	return s.URL + "/" + s.account
+}
+
+// Names 返回容器中的所有blob名称
+func (s *Server) Names(container string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var names []string
+	for name := range s.containers[container] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Tier 返回blob的访问层
+func (s *Server) Tier(container, name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if b := s.containers[container][name]; b != nil {
+		return b.tier
+	}
+	return ""
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if !s.verify(r) {
+		writeError(w, http.StatusForbidden, "AuthenticationFailed", "signature mismatch")
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 3)
+	if len(parts) < 2 || parts[0] != s.account {
+		writeError(w, http.StatusBadRequest, "InvalidUri", "invalid path")
+		return
+	}
+	containerName := parts[1]
+	name := ""
+	if len(parts) == 3 {
+		name = parts[2]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	container, ok := s.containers[containerName]
+	if !ok {
+		writeError(w, http.StatusNotFound, "ContainerNotFound", "container not found")
+		return
+	}
+
+	query := r.URL.Query()
+	switch {
+	case name == "" && r.Method == http.MethodGet && query.Get("comp") == "list":
+		s.list(w, r, container)
+	case name == "" && r.Method == http.MethodGet && query.Get("comp") == "blobs":
+		s.findByTags(w, r, containerName, container)
+	case r.Method == http.MethodPut && query.Get("comp") == "tier":
+		b := container[name]
+		if b == nil {
+			writeError(w, http.StatusNotFound, "BlobNotFound", "blob not found")
+			return
+		}
+		b.tier = r.Header.Get("X-Ms-Access-Tier")
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPut && name != "":
+		s.put(w, r, container, name)
+	case (r.Method == http.MethodGet || r.Method == http.MethodHead) && name != "":
+		b := container[name]
+		if b == nil {
+			writeError(w, http.StatusNotFound, "BlobNotFound", "blob not found")
+			return
+		}
+		for k, v := range b.header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("ETag", b.etag)
+		w.Header().Set("X-Ms-Creation-Time", b.createdAt.Format(http.TimeFormat))
+		w.Header().Set("X-Ms-Access-Tier", b.tier)
+		w.Header().Set("X-Ms-Tag-Count", strconv.Itoa(len(b.tags)))
+		http.ServeContent(w, r, "", b.createdAt, bytes.NewReader(b.data))
+	case r.Method == http.MethodDelete && name != "":
+		b := container[name]
+		if b == nil {
+			writeError(w, http.StatusNotFound, "BlobNotFound", "blob not found")
+			return
+		}
+		if match := r.Header.Get("If-Match"); match != "" && match != b.etag {
+			writeError(w, http.StatusPreconditionFailed, "ConditionNotMet", "etag mismatch")
+			return
+		}
+		if where := r.Header.Get("X-Ms-If-Tags"); where != "" {
+			ok, err := matchTags(where, containerName, b.tags)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "InvalidHeaderValue", err.Error())
+				return
+			}
+			if !ok {
+				writeError(w, http.StatusPreconditionFailed, "ConditionNotMet", "tags mismatch")
+				return
+			}
+		}
+		delete(container, name)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		writeError(w, http.StatusBadRequest, "UnsupportedHttpVerb", "unsupported request")
+	}
+}
+
+// verify 使用测试密钥重新计算签名并比较
+func (s *Server) verify(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return false
+	}
+	key, _ := base64.StdEncoding.DecodeString(AccountKey)
+	clone := r.Clone(r.Context())
+	azblob.SignSharedKey(clone, s.account, key)
+	return clone.Header.Get("Authorization") == auth
+}
+
+// put 处理Put Blob
+func (s *Server) put(w http.ResponseWriter, r *http.Request, container map[string]*blob, name string) {
+	if r.Header.Get("X-Ms-Blob-Type") != "BlockBlob" {
+		writeError(w, http.StatusBadRequest, "InvalidHeaderValue", "only block blobs are supported")
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidInput", err.Error())
+		return
+	}
+
+	header := http.Header{}
+	for k, v := range r.Header {
+		if k == "Content-Type" || strings.HasPrefix(k, "X-Ms-Meta-") {
+			header[k] = v
+		}
+	}
+	tags := make(map[string]string)
+	if raw := r.Header.Get("X-Ms-Tags"); raw != "" {
+		values, err := url.ParseQuery(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidTag", err.Error())
+			return
+		}
+		for k := range values {
+			tags[k] = values.Get(k)
+		}
+	}
+	tier := r.Header.Get("X-Ms-Access-Tier")
+	if tier == "" {
+		tier = "Hot"
+	}
+
+	sum := md5.Sum(data)
+	container[name] = &blob{
+		data:      data,
+		header:    header,
+		tags:      tags,
+		tier:      tier,
+		etag:      `"` + hex.EncodeToString(sum[:]) + `"`,
+		createdAt: time.Now().UTC(),
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// xmlBlob 列表响应中的blob
+type xmlBlob struct {
+	Name          string         `xml:"Name"`
+	ContainerName string         `xml:"ContainerName,omitempty"`
+	Properties    *xmlProperties `xml:"Properties,omitempty"`
+	Metadata      *struct {
+		Items []xmlItem
+	} `xml:"Metadata,omitempty"`
+	Tags *xmlTags `xml:"Tags,omitempty"`
+}
+
+type xmlProperties struct {
+	CreationTime  string `xml:"Creation-Time"`
+	ContentLength int64  `xml:"Content-Length"`
+	ContentType   string `xml:"Content-Type"`
+	AccessTier    string `xml:"AccessTier"`
+}
+
+type xmlItem struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+type xmlTags struct {
+	Tags []xmlTag `xml:"TagSet>Tag"`
+}
+
+type xmlTag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+type xmlResults struct {
+	XMLName    xml.Name  `xml:"EnumerationResults"`
+	Blobs      []xmlBlob `xml:"Blobs>Blob"`
+	NextMarker string    `xml:"NextMarker"`
+}
+
+// list 处理List Blobs
+func (s *Server) list(w http.ResponseWriter, r *http.Request, container map[string]*blob) {
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	marker := query.Get("marker")
+	maxResults := 5000
+	if v, err := strconv.Atoi(query.Get("maxresults")); err == nil && v > 0 {
+		maxResults = v
+	}
+	include := query.Get("include")
+
+	var names []string
+	for name := range container {
+		if strings.HasPrefix(name, prefix) && name > marker {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	result := xmlResults{}
+	for i, name := range names {
+		if i == maxResults {
+			result.NextMarker = names[i-1]
+			break
+		}
+		b := container[name]
+		item := xmlBlob{Name: name}
+		item.Properties = &xmlProperties{
+			CreationTime:  b.createdAt.Format(http.TimeFormat),
+			ContentLength: int64(len(b.data)),
+			ContentType:   b.header.Get("Content-Type"),
+			AccessTier:    b.tier,
+		}
+		if strings.Contains(include, "metadata") {
+			item.Metadata = &struct{ Items []xmlItem }{}
+			for k := range b.header {
+				if meta, ok := strings.CutPrefix(k, "X-Ms-Meta-"); ok {
+					item.Metadata.Items = append(item.Metadata.Items, xmlItem{XMLName: xml.Name{Local: strings.ToLower(meta)}, Value: b.header.Get(k)})
+				}
+			}
+		}
+		if strings.Contains(include, "tags") && len(b.tags) > 0 {
+			item.Tags = &xmlTags{Tags: sortedTags(b.tags)}
+		}
+		result.Blobs = append(result.Blobs, item)
+	}
+	writeXML(w, result)
+}
+
+// findByTags 处理Find Blobs by Tags
+func (s *Server) findByTags(w http.ResponseWriter, r *http.Request, containerName string, container map[string]*blob) {
+	where := r.URL.Query().Get("where")
+	marker := r.URL.Query().Get("marker")
+
+	var names []string
+	for name, b := range container {
+		ok, err := matchTags(where, containerName, b.tags)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidQueryParameterValue", err.Error())
+			return
+		}
+		if ok && name > marker {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	result := xmlResults{}
+	for _, name := range names {
+		result.Blobs = append(result.Blobs, xmlBlob{
+			Name:          name,
+			ContainerName: containerName,
+			Tags:          &xmlTags{Tags: sortedTags(container[name].tags)},
+		})
+	}
+	writeXML(w, result)
+}
+
+// clausePattern 标签表达式中的单个条件
+var clausePattern = regexp.MustCompile(`^\s*(?:"([^"]+)"|(@container))\s*(=|>=|<=|>|<)\s*'([^']*)'\s*$`)
+
+// andPattern 标签表达式中的AND连接符
+var andPattern = regexp.MustCompile(`(?i)\s+AND\s+`)
+
+// matchTags 计算由AND连接的标签表达式
+func matchTags(where, container string, tags map[string]string) (bool, error) {
+	for _, clause := range andPattern.Split(where, -1) {
+		m := clausePattern.FindStringSubmatch(clause)
+		if m == nil {
+			return false, fmt.Errorf("invalid tag expression %q", clause)
+		}
+		value, ok := tags[m[1]]
+		if m[2] != "" {
+			value, ok = container, true
+		}
+		if !ok {
+			return false, nil
+		}
+
+		var match bool
+		switch m[3] {
+		case "=":
+			match = value == m[4]
+		case ">":
+			match = value > m[4]
+		case ">=":
+			match = value >= m[4]
+		case "<":
+			match = value < m[4]
+		case "<=":
+			match = value <= m[4]
+		}
+		if !match {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func sortedTags(tags map[string]string) []xmlTag {
+	var result []xmlTag
+	for k, v := range tags {
+		result = append(result, xmlTag{Key: k, Value: v})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("X-Ms-Error-Code", code)
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string   `xml:"Code"`
+		Message string   `xml:"Message"`
+	}{Code: code, Message: message})
+}
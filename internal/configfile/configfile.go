@@ -0,0 +1,182 @@
+// Package configfile 按扩展名解析JSON、YAML和TOML配置文件。YAML和TOML先解析为通用的值，再转换为JSON
+// 交给encoding/json，三种格式都使用结构体的json标签作为字段名；time.Duration字段除了纳秒数，
+// 还可以写成"90s"、"1h30m"这样的字符串
+package configfile
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	durationType    = reflect.TypeOf(time.Duration(0))
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textType        = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Decode 按filename的扩展名（.json、.yaml、.yml、.toml）解析data并填充v，v必须是指针
+func Decode(filename string, data []byte, v interface{}) error {
+	var raw interface{}
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&raw); err != nil {
+			return fmt.Errorf("failed to parse json: %w", err)
+		}
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("failed to parse yaml: %w", err)
+		}
+	case ".toml":
+		var table map[string]interface{}
+		if _, err := toml.Decode(string(data), &table); err != nil {
+			return fmt.Errorf("failed to parse toml: %w", err)
+		}
+		raw = table
+	default:
+		return fmt.Errorf("unsupported config file extension %q: must be .json, .yaml, .yml or .toml", ext)
+	}
+	if raw == nil {
+		// 空文件
+		return nil
+	}
+
+	normalized, err := normalize(generic(raw), reflect.TypeOf(v))
+	if err != nil {
+		return err
+	}
+	data, err = json.Marshal(normalized)
+	if err != nil {
+		return fmt.Errorf("failed to convert config: %w", err)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// generic 把YAML和TOML解析出的值统一为encoding/json的通用形式：对象为map[string]interface{}，
+// 数组为[]interface{}；YAML中非字符串的键（例如状态码）转换为字符串
+func generic(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = generic(item)
+		}
+		return val
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			m[fmt.Sprint(k)] = generic(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range val {
+			val[i] = generic(item)
+		}
+		return val
+	case []map[string]interface{}:
+		items := make([]interface{}, len(val))
+		for i, item := range val {
+			items[i] = generic(item)
+		}
+		return items
+	}
+	return v
+}
+
+// normalize 按目标类型t转换v：time.Duration字段的字符串解析为纳秒数，其他值不变
+func normalize(v interface{}, t reflect.Type) (interface{}, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == durationType {
+		if s, ok := v.(string); ok {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid duration %q: %w", s, err)
+			}
+			return int64(d), nil
+		}
+		return v, nil
+	}
+	if ptr := reflect.PointerTo(t); ptr.Implements(unmarshalerType) || ptr.Implements(textType) {
+		// 类型自己解析
+		return v, nil
+	}
+
+	var err error
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v, nil
+		}
+		fields := jsonFields(t)
+		for k, item := range m {
+			if ft, ok := fields[strings.ToLower(k)]; ok {
+				if m[k], err = normalize(item, ft); err != nil {
+					return nil, fmt.Errorf("%s: %w", k, err)
+				}
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := v.([]interface{})
+		if !ok {
+			return v, nil
+		}
+		for i, item := range items {
+			if items[i], err = normalize(item, t.Elem()); err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+		}
+	case reflect.Map:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v, nil
+		}
+		for k, item := range m {
+			if m[k], err = normalize(item, t.Elem()); err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+		}
+	}
+	return v, nil
+}
+
+// jsonFields 返回结构体按json标签（小写）索引的字段类型，与encoding/json一样展开匿名嵌入的结构体
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for k, v := range jsonFields(ft) {
+				if _, ok := fields[k]; !ok {
+					fields[k] = v
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[strings.ToLower(name)] = f.Type
+	}
+	return fields
+}
@@ -0,0 +1,126 @@
+package configfile
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type testBackend struct {
+	URL     string        `json:"url"`
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+type testEmbedded struct {
+	Retention time.Duration `json:"retention"`
+}
+
+type testConfig struct {
+	testEmbedded
+	Name     string                `json:"name"`
+	TTL      time.Duration         `json:"ttl"`
+	Backends []testBackend         `json:"backends"`
+	Negative map[int]time.Duration `json:"negative"`
+	Limit    *time.Duration        `json:"limit,omitempty"`
+	Ignored  time.Duration         `json:"-"`
+}
+
+func TestDecode(t *testing.T) {
+	expect := func(t *testing.T, c *testConfig) {
+		t.Helper()
+		if c.Name != "edge" || c.TTL != 90*time.Minute || c.Retention != 24*time.Hour {
+			t.Errorf("Unexpected scalar fields: %+v", c)
+		}
+		if len(c.Backends) != 2 || c.Backends[0].URL != "http://a" || c.Backends[1].Timeout != 5*time.Second {
+			t.Errorf("Unexpected backends: %+v", c.Backends)
+		}
+		if c.Negative[404] != time.Minute {
+			t.Errorf("Expected negative TTL for 404, got %v", c.Negative)
+		}
+		if c.Limit == nil || *c.Limit != time.Second {
+			t.Errorf("Expected limit of 1s, got %v", c.Limit)
+		}
+	}
+
+	files := map[string]string{
+		"config.json": `{
+			"name": "edge", "ttl": "1h30m", "retention": 86400000000000,
+			"backends": [{"url": "http://a"}, {"url": "http://b", "timeout": "5s"}],
+			"negative": {"404": "1m"}, "limit": "1s"
+		}`,
+		"config.yaml": `
+name: edge
+ttl: 1h30m
+retention: 24h
+backends:
+  - url: http://a
+  - url: http://b
+    timeout: 5s
+negative:
+  404: 1m
+limit: 1s
+`,
+		"config.toml": `
+name = "edge"
+ttl = "1h30m"
+retention = "24h"
+limit = "1s"
+
+[negative]
+404 = "1m"
+
+[[backends]]
+url = "http://a"
+
+[[backends]]
+url = "http://b"
+timeout = "5s"
+`,
+	}
+	for name, data := range files {
+		t.Run(name, func(t *testing.T) {
+			var c testConfig
+			if err := Decode(name, []byte(data), &c); err != nil {
+				t.Fatalf("Failed to decode: %v", err)
+			}
+			expect(t, &c)
+		})
+	}
+
+	t.Run("yml", func(t *testing.T) {
+		var c testConfig
+		if err := Decode("config.YML", []byte("ttl: 10s\n"), &c); err != nil {
+			t.Fatalf("Failed to decode: %v", err)
+		}
+		if c.TTL != 10*time.Second {
+			t.Errorf("Expected TTL of 10s, got %v", c.TTL)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		c := testConfig{Name: "default"}
+		if err := Decode("config.yaml", nil, &c); err != nil {
+			t.Fatalf("Failed to decode empty file: %v", err)
+		}
+		if c.Name != "default" {
+			t.Errorf("Expected empty file to keep defaults, got %q", c.Name)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		var c testConfig
+		if err := Decode("config.ini", []byte("name=edge"), &c); err == nil {
+			t.Error("Expected error for unsupported extension")
+		}
+		if err := Decode("config.yaml", []byte("name: [edge"), &c); err == nil {
+			t.Error("Expected error for invalid yaml")
+		}
+		if err := Decode("config.toml", []byte("name = "), &c); err == nil {
+			t.Error("Expected error for invalid toml")
+		}
+		err := Decode("config.yaml", []byte("backends:\n  - timeout: soon\n"), &c)
+		if err == nil || !strings.Contains(err.Error(), "backends") {
+			t.Errorf("Expected invalid duration error naming the field, got %v", err)
+		}
+	})
+}
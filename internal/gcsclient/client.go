@@ -0,0 +1,277 @@
+// Package gcsclient 是基于net/http的最小Google Cloud Storage JSON API客户端
+package gcsclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DefaultEndpoint GCS JSON API地址
+const DefaultEndpoint = "https://storage.googleapis.com"
+
+var (
+	// ErrNotFound 对象不存在
+	ErrNotFound = errors.New("object not found")
+	// ErrPreconditionFailed 条件写入/删除的前提不满足（generation不匹配）
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
+
+// TokenSource 返回OAuth2访问令牌
+type TokenSource func(ctx context.Context) (string, error)
+
+// Config GCS客户端配置
+type Config struct {
+	Endpoint    string       // 服务地址，默认DefaultEndpoint，可指向模拟器
+	Bucket      string       // 存储桶
+	TokenSource TokenSource  // 访问令牌来源，为空时使用GCE元数据服务
+	HTTPClient  *http.Client // 自定义HTTP客户端
+}
+
+// Client GCS客户端
+type Client struct {
+	cfg      Config
+	endpoint *url.URL
+	http     *http.Client
+}
+
+// Error GCS返回的错误
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("gcs: unexpected status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("gcs: %s (%d)", e.Message, e.StatusCode)
+}
+
+// Object 对象元数据
+type Object struct {
+	Name        string            `json:"name"`
+	Size        int64             `json:"size,string"`
+	ContentType string            `json:"contentType,omitempty"`
+	Generation  int64             `json:"generation,string"`
+	TimeCreated time.Time         `json:"timeCreated"`
+	Updated     time.Time         `json:"updated"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// ListResult 列表结果
+type ListResult struct {
+	Items         []*Object `json:"items"`
+	NextPageToken string    `json:"nextPageToken"`
+}
+
+// Conditions 条件请求参数，字段为nil表示不限制
+type Conditions struct {
+	GenerationMatch *int64 // 仅当对象generation等于该值时执行，0表示对象不存在
+}
+
+// New 创建GCS客户端
+func New(cfg Config) (*Client, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("gcs bucket cannot be empty")
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = DefaultEndpoint
+	}
+
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gcs endpoint: %w", err)
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid gcs endpoint: %q", cfg.Endpoint)
+	}
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if cfg.TokenSource == nil {
+		cfg.TokenSource = MetadataTokenSource(httpClient)
+	}
+
+	return &Client{cfg: cfg, endpoint: endpoint, http: httpClient}, nil
+}
+
+// Bucket 返回存储桶名称
+func (c *Client) Bucket() string {
+	return c.cfg.Bucket
+}
+
+// Insert 使用multipart上传创建或覆盖对象
+func (c *Client) Insert(ctx context.Context, obj *Object, body io.Reader, size int64, cond Conditions) (*Object, error) {
+	meta, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	boundary := randomBoundary()
+	head := fmt.Sprintf("--%s\r\nContent-Type: application/json; charset=UTF-8\r\n\r\n%s\r\n--%s\r\nContent-Type: %s\r\n\r\n",
+		boundary, meta, boundary, contentType(obj.ContentType))
+	tail := fmt.Sprintf("\r\n--%s--\r\n", boundary)
+
+	query := url.Values{"uploadType": {"multipart"}}
+	cond.apply(query)
+	header := http.Header{"Content-Type": {"multipart/related; boundary=" + boundary}}
+
+	resp, err := c.do(ctx, http.MethodPost, "/upload/storage/v1/b/"+url.PathEscape(c.cfg.Bucket)+"/o", query, header,
+		io.MultiReader(bytes.NewReader([]byte(head)), body, bytes.NewReader([]byte(tail))),
+		int64(len(head))+size+int64(len(tail)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	created := &Object{}
+	if err := json.NewDecoder(resp.Body).Decode(created); err != nil {
+		return nil, fmt.Errorf("failed to decode object: %w", err)
+	}
+	return created, nil
+}
+
+// Get 获取对象元数据
+func (c *Client) Get(ctx context.Context, name string) (*Object, error) {
+	resp, err := c.do(ctx, http.MethodGet, c.objectPath(name), nil, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	obj := &Object{}
+	if err := json.NewDecoder(resp.Body).Decode(obj); err != nil {
+		return nil, fmt.Errorf("failed to decode object: %w", err)
+	}
+	return obj, nil
+}
+
+// Download 下载对象内容，generation大于0时读取指定版本
+func (c *Client) Download(ctx context.Context, name string, generation int64, header http.Header) (*http.Response, error) {
+	query := url.Values{"alt": {"media"}}
+	if generation > 0 {
+		query.Set("generation", strconv.FormatInt(generation, 10))
+	}
+	return c.do(ctx, http.MethodGet, c.objectPath(name), query, header, nil, 0)
+}
+
+// Delete 删除对象
+func (c *Client) Delete(ctx context.Context, name string, cond Conditions) error {
+	query := url.Values{}
+	cond.apply(query)
+	resp, err := c.do(ctx, http.MethodDelete, c.objectPath(name), query, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// List 按前缀列出对象，pageToken为上一页的NextPageToken
+func (c *Client) List(ctx context.Context, prefix, pageToken string) (*ListResult, error) {
+	query := url.Values{"prefix": {prefix}}
+	if pageToken != "" {
+		query.Set("pageToken", pageToken)
+	}
+	resp, err := c.do(ctx, http.MethodGet, "/storage/v1/b/"+url.PathEscape(c.cfg.Bucket)+"/o", query, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &ListResult{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, fmt.Errorf("failed to decode list result: %w", err)
+	}
+	return result, nil
+}
+
+// objectPath 返回对象的API路径，对象名中的斜杠需要转义
+func (c *Client) objectPath(name string) string {
+	return "/storage/v1/b/" + url.PathEscape(c.cfg.Bucket) + "/o/" + url.PathEscape(name)
+}
+
+// do 发送带令牌的请求，非2xx响应转换为错误
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, header http.Header, body io.Reader, size int64) (*http.Response, error) {
+	u := *c.endpoint
+	u.Path = ""
+	u.RawPath = ""
+	u.Opaque = "//" + u.Host + path
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+
+	token, err := c.cfg.TokenSource(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 || resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	case http.StatusPreconditionFailed:
+		return nil, ErrPreconditionFailed
+	}
+	var apiErr struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&apiErr)
+	return nil, &Error{StatusCode: resp.StatusCode, Message: apiErr.Error.Message}
+}
+
+// apply 将条件写入查询参数
+func (c Conditions) apply(query url.Values) {
+	if c.GenerationMatch != nil {
+		query.Set("ifGenerationMatch", strconv.FormatInt(*c.GenerationMatch, 10))
+	}
+}
+
+// contentType 返回上传使用的内容类型
+func contentType(s string) string {
+	if s == "" {
+		return "application/octet-stream"
+	}
+	return s
+}
+
+// randomBoundary 生成multipart分隔符
+func randomBoundary() string {
+	var buf [16]byte
+	rand.Read(buf[:])
+	return hex.EncodeToString(buf[:])
+}
@@ -0,0 +1,66 @@
+package gcsclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// metadataTokenURL GCE元数据服务的默认服务账号令牌地址
+const metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// StaticToken 返回固定令牌的TokenSource，令牌为空时不发送Authorization头（模拟器）
+func StaticToken(token string) TokenSource {
+	return func(context.Context) (string, error) {
+		return token, nil
+	}
+}
+
+// MetadataTokenSource 从GCE/GKE元数据服务获取令牌，并在过期前缓存
+func MetadataTokenSource(httpClient *http.Client) TokenSource {
+	var (
+		mu      sync.Mutex
+		token   string
+		expires time.Time
+	)
+
+	return func(ctx context.Context) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// 提前一分钟刷新
+		if token != "" && time.Now().Add(time.Minute).Before(expires) {
+			return token, nil
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataTokenURL, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("metadata server returned status %d", resp.StatusCode)
+		}
+
+		var body struct {
+			AccessToken string `json:"access_token"`
+			ExpiresIn   int64  `json:"expires_in"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return "", fmt.Errorf("failed to decode token: %w", err)
+		}
+
+		token = body.AccessToken
+		expires = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+		return token, nil
+	}
+}
@@ -0,0 +1,254 @@
+// Package gcstest 提供用于测试的内存GCS JSON API服务
+package gcstest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pageSize 列表分页大小
+const pageSize = 1000
+
+// object 存储的对象
+type object struct {
+	Name        string            `json:"name"`
+	Bucket      string            `json:"bucket"`
+	Size        int64             `json:"size,string"`
+	ContentType string            `json:"contentType,omitempty"`
+	Generation  int64             `json:"generation,string"`
+	TimeCreated time.Time         `json:"timeCreated"`
+	Updated     time.Time         `json:"updated"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+
+	data []byte
+}
+
+// Server 内存GCS服务
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	buckets    map[string]map[string]*object
+	generation int64
+}
+
+// NewServer 启动内存GCS服务，bucket为预先创建的存储桶
+func NewServer(buckets ...string) *Server {
+	s := &Server{buckets: make(map[string]map[string]*object)}
+	for _, bucket := range buckets {
+		s.buckets[bucket] = make(map[string]*object)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Names 返回存储桶中的所有对象名
+func (s *Server) Names(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var names []string
+	for name := range s.buckets[bucket] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		writeError(w, http.StatusUnauthorized, "missing access token")
+		return
+	}
+
+	path := r.URL.EscapedPath()
+	upload := strings.HasPrefix(path, "/upload")
+	path = strings.TrimPrefix(path, "/upload")
+	rest, ok := strings.CutPrefix(path, "/storage/v1/b/")
+	if !ok {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	parts := strings.SplitN(rest, "/", 3)
+	bucketName, _ := url.PathUnescape(parts[0])
+	if len(parts) < 2 || parts[1] != "o" {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	name := ""
+	if len(parts) == 3 {
+		name, _ = url.PathUnescape(parts[2])
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, ok := s.buckets[bucketName]
+	if !ok {
+		writeError(w, http.StatusNotFound, "bucket not found")
+		return
+	}
+
+	switch {
+	case upload && r.Method == http.MethodPost && name == "":
+		s.insert(w, r, bucketName, bucket)
+	case r.Method == http.MethodGet && name == "":
+		s.list(w, r, bucket)
+	case r.Method == http.MethodGet:
+		s.get(w, r, bucket, name)
+	case r.Method == http.MethodDelete:
+		obj := bucket[name]
+		if obj == nil {
+			writeError(w, http.StatusNotFound, "object not found")
+			return
+		}
+		if !matchGeneration(r, obj) {
+			writeError(w, http.StatusPreconditionFailed, "generation mismatch")
+			return
+		}
+		delete(bucket, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// insert 处理multipart上传
+func (s *Server) insert(w http.ResponseWriter, r *http.Request, bucketName string, bucket map[string]*object) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || r.URL.Query().Get("uploadType") != "multipart" {
+		writeError(w, http.StatusBadRequest, "unsupported upload")
+		return
+	}
+
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	metaPart, err := reader.NextPart()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	obj := &object{}
+	if err := json.NewDecoder(metaPart).Decode(obj); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	mediaPart, err := reader.NextPart()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	data, err := io.ReadAll(mediaPart)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !matchGeneration(r, bucket[obj.Name]) {
+		writeError(w, http.StatusPreconditionFailed, "generation mismatch")
+		return
+	}
+
+	s.generation++
+	now := time.Now().UTC()
+	obj.Bucket = bucketName
+	obj.Size = int64(len(data))
+	obj.Generation = s.generation
+	obj.TimeCreated = now
+	obj.Updated = now
+	obj.data = data
+	if obj.ContentType == "" {
+		obj.ContentType = mediaPart.Header.Get("Content-Type")
+	}
+	bucket[obj.Name] = obj
+
+	writeJSON(w, obj)
+}
+
+// list 处理对象列表
+func (s *Server) list(w http.ResponseWriter, r *http.Request, bucket map[string]*object) {
+	prefix := r.URL.Query().Get("prefix")
+	token := r.URL.Query().Get("pageToken")
+
+	var names []string
+	for name := range bucket {
+		if strings.HasPrefix(name, prefix) && name > token {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	result := struct {
+		Items         []*object `json:"items,omitempty"`
+		NextPageToken string    `json:"nextPageToken,omitempty"`
+	}{}
+	for i, name := range names {
+		if i == pageSize {
+			result.NextPageToken = names[i-1]
+			break
+		}
+		result.Items = append(result.Items, bucket[name])
+	}
+	writeJSON(w, result)
+}
+
+// get 处理元数据和内容下载
+func (s *Server) get(w http.ResponseWriter, r *http.Request, bucket map[string]*object, name string) {
+	obj := bucket[name]
+	if obj == nil {
+		writeError(w, http.StatusNotFound, "object not found")
+		return
+	}
+
+	if r.URL.Query().Get("alt") != "media" {
+		writeJSON(w, obj)
+		return
+	}
+	if g := r.URL.Query().Get("generation"); g != "" && g != strconv.FormatInt(obj.Generation, 10) {
+		writeError(w, http.StatusNotFound, "generation not found")
+		return
+	}
+	w.Header().Set("Content-Type", obj.ContentType)
+	w.Header().Set("X-Goog-Generation", strconv.FormatInt(obj.Generation, 10))
+	http.ServeContent(w, r, "", obj.Updated, bytes.NewReader(obj.data))
+}
+
+// matchGeneration 检查ifGenerationMatch条件，0表示对象必须不存在
+func matchGeneration(r *http.Request, obj *object) bool {
+	value := r.URL.Query().Get("ifGenerationMatch")
+	if value == "" {
+		return true
+	}
+	want, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return false
+	}
+	if obj == nil {
+		return want == 0
+	}
+	return obj.Generation == want
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{"code": status, "message": message},
+	})
+}
@@ -0,0 +1,207 @@
+// Package grpcwire 为手写的protobuf消息提供gRPC编解码支持
+// 消息直接按protobuf线格式编码，与protoc根据同一份.proto生成的其他语言客户端兼容
+package grpcwire
+
+import (
+	"fmt"
+	"math"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	_ "google.golang.org/grpc/encoding/proto" // 先注册默认的proto编解码器
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Name 编解码器的名称，Go客户端以它作为content-subtype发送请求
+// 使用独立的名称注册，不覆盖gRPC默认的proto编解码器，同一进程中protoc生成的其他服务不受影响
+const Name = "edgeorigin"
+
+// Message 手写的protobuf消息
+type Message interface {
+	// MarshalWire 按protobuf线格式编码
+	MarshalWire() ([]byte, error)
+
+	// UnmarshalWire 按protobuf线格式解码
+	UnmarshalWire(b []byte) error
+}
+
+// codec 编码Message，其他类型交给gRPC默认的proto编解码器
+type codec struct {
+	fallback encoding.Codec
+}
+
+func init() {
+	encoding.RegisterCodec(Codec())
+}
+
+// Codec 返回编码Message的编解码器
+func Codec() encoding.Codec {
+	return codec{fallback: encoding.GetCodec("proto")}
+}
+
+// CallOption 让客户端调用使用Codec编解码
+func CallOption() grpc.CallOption {
+	return grpc.CallContentSubtype(Name)
+}
+
+// ServerOption 让服务端对所有请求使用Codec编解码
+// Go客户端通过CallOption选择编解码器，不需要该选项；protoc生成的其他语言客户端以默认的proto发送请求时需要
+func ServerOption() grpc.ServerOption {
+	return grpc.ForceServerCodec(Codec())
+}
+
+func (c codec) Marshal(v interface{}) ([]byte, error) {
+	if m, ok := v.(Message); ok {
+		return m.MarshalWire()
+	}
+	if c.fallback == nil {
+		return nil, fmt.Errorf("grpcwire: cannot marshal %T", v)
+	}
+	return c.fallback.Marshal(v)
+}
+
+func (c codec) Unmarshal(data []byte, v interface{}) error {
+	if m, ok := v.(Message); ok {
+		return m.UnmarshalWire(data)
+	}
+	if c.fallback == nil {
+		return fmt.Errorf("grpcwire: cannot unmarshal %T", v)
+	}
+	return c.fallback.Unmarshal(data, v)
+}
+
+func (codec) Name() string {
+	return Name
+}
+
+// Field 解码出的字段值
+type Field struct {
+	Num    protowire.Number
+	Type   protowire.Type
+	Varint uint64 // VarintType、Fixed32Type、Fixed64Type的值
+	Bytes  []byte // BytesType的值，引用原始数据
+}
+
+// String 把BytesType的值作为字符串返回
+func (f Field) String() string {
+	return string(f.Bytes)
+}
+
+// Double 把Fixed64Type的值作为double返回
+func (f Field) Double() float64 {
+	return math.Float64frombits(f.Varint)
+}
+
+// Varints 返回repeated整数字段的值，兼容packed和非packed两种编码
+func (f Field) Varints() ([]uint64, error) {
+	if f.Type != protowire.BytesType {
+		return []uint64{f.Varint}, nil
+	}
+
+	var values []uint64
+	for b := f.Bytes; len(b) > 0; {
+		v, n := protowire.ConsumeVarint(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		values = append(values, v)
+		b = b[n:]
+	}
+	return values, nil
+}
+
+// Decode 依次解码b中的字段，未知的字段由fn自行忽略
+func Decode(b []byte, fn func(f Field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		f := Field{Num: num, Type: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.Varint, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			f.Varint = uint64(v)
+		case protowire.Fixed64Type:
+			f.Varint, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			f.Bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AppendString 追加非空的字符串字段，空值按proto3的约定省略
+func AppendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+// AppendBytes 追加非空的bytes字段
+func AppendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+// AppendVarint 追加非零的整数或布尔字段
+func AppendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// AppendDouble 追加非零的double字段
+func AppendDouble(b []byte, num protowire.Number, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
+
+// AppendPacked 以packed编码追加repeated整数字段
+func AppendPacked(b []byte, num protowire.Number, values []uint64) []byte {
+	if len(values) == 0 {
+		return b
+	}
+
+	var packed []byte
+	for _, v := range values {
+		packed = protowire.AppendVarint(packed, v)
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, packed)
+}
+
+// AppendMessage 追加嵌套消息字段
+func AppendMessage(b []byte, num protowire.Number, m Message) ([]byte, error) {
+	data, err := m.MarshalWire()
+	if err != nil {
+		return nil, err
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, data), nil
+}
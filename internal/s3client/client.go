@@ -0,0 +1,267 @@
+// Package s3client 是基于net/http的最小S3兼容客户端，支持AWS S3、MinIO等对象存储
+package s3client
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("object not found")
+
+// Config S3客户端配置
+type Config struct {
+	Endpoint        string       `json:"endpoint"`          // 服务地址，例如 https://s3.us-east-1.amazonaws.com 或 http://minio:9000
+	Region          string       `json:"region"`            // 区域，默认us-east-1
+	Bucket          string       `json:"bucket"`            // 存储桶
+	AccessKeyID     string       `json:"access_key_id"`     // 访问密钥ID
+	SecretAccessKey string       `json:"secret_access_key"` // 访问密钥
+	SessionToken    string       `json:"session_token"`     // 临时凭证的会话令牌
+	PathStyle       bool         `json:"path_style"`        // 使用路径风格访问，MinIO等通常需要开启
+	HTTPClient      *http.Client `json:"-"`                 // 自定义HTTP客户端
+}
+
+// Client S3客户端
+type Client struct {
+	cfg      Config
+	endpoint *url.URL
+	http     *http.Client
+	now      func() time.Time
+}
+
+// Error S3返回的错误
+type Error struct {
+	StatusCode int    `xml:"-"`
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: unexpected status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("s3: %s (%d): %s", e.Code, e.StatusCode, e.Message)
+}
+
+// Object 列表中的对象
+type Object struct {
+	Key          string    `xml:"Key"`
+	Size         int64     `xml:"Size"`
+	LastModified time.Time `xml:"LastModified"`
+	ETag         string    `xml:"ETag"`
+}
+
+// ListResult ListObjectsV2结果
+type ListResult struct {
+	Objects               []Object `xml:"Contents"`
+	IsTruncated           bool     `xml:"IsTruncated"`
+	NextContinuationToken string   `xml:"NextContinuationToken"`
+}
+
+// New 创建S3客户端
+func New(cfg Config) (*Client, error) {
+	if cfg.Endpoint == "" {
+		return nil, fmt.Errorf("s3 endpoint cannot be empty")
+	}
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket cannot be empty")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint: %q", cfg.Endpoint)
+	}
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{cfg: cfg, endpoint: endpoint, http: httpClient, now: time.Now}, nil
+}
+
+// Bucket 返回存储桶名称
+func (c *Client) Bucket() string {
+	return c.cfg.Bucket
+}
+
+// PutObject 上传对象，size为-1时使用分块传输编码
+func (c *Client) PutObject(ctx context.Context, key string, body io.Reader, size int64, header http.Header) error {
+	resp, err := c.do(ctx, http.MethodPut, key, nil, header, body, size, UnsignedPayload)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// GetObject 下载对象，调用方负责关闭响应体
+func (c *Client) GetObject(ctx context.Context, key string, header http.Header) (*http.Response, error) {
+	return c.do(ctx, http.MethodGet, key, nil, header, nil, 0, EmptyPayloadHash)
+}
+
+// HeadObject 获取对象元数据
+func (c *Client) HeadObject(ctx context.Context, key string) (http.Header, error) {
+	resp, err := c.do(ctx, http.MethodHead, key, nil, nil, nil, 0, EmptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp.Header, nil
+}
+
+// DeleteObject 删除对象，对象不存在时不返回错误
+func (c *Client) DeleteObject(ctx context.Context, key string) error {
+	resp, err := c.do(ctx, http.MethodDelete, key, nil, nil, nil, 0, EmptyPayloadHash)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ListObjectsV2 按前缀列出对象，token为上一页的NextContinuationToken
+func (c *Client) ListObjectsV2(ctx context.Context, prefix, token string, maxKeys int) (*ListResult, error) {
+	query := url.Values{"list-type": {"2"}}
+	if prefix != "" {
+		query.Set("prefix", prefix)
+	}
+	if token != "" {
+		query.Set("continuation-token", token)
+	}
+	if maxKeys > 0 {
+		query.Set("max-keys", strconv.Itoa(maxKeys))
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, "", query, nil, nil, 0, EmptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &ListResult{}
+	if err := xml.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, fmt.Errorf("failed to decode list response: %w", err)
+	}
+	return result, nil
+}
+
+// DeleteObjects 批量删除对象，单次最多1000个
+func (c *Client) DeleteObjects(ctx context.Context, keys []string) error {
+	type object struct {
+		Key string `xml:"Key"`
+	}
+	type deleteRequest struct {
+		XMLName xml.Name `xml:"Delete"`
+		Quiet   bool     `xml:"Quiet"`
+		Objects []object `xml:"Object"`
+	}
+
+	for start := 0; start < len(keys); start += 1000 {
+		end := start + 1000
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		req := deleteRequest{Quiet: true}
+		for _, key := range keys[start:end] {
+			req.Objects = append(req.Objects, object{Key: key})
+		}
+		body, err := xml.Marshal(req)
+		if err != nil {
+			return err
+		}
+
+		sum := md5.Sum(body)
+		header := http.Header{}
+		header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+		header.Set("Content-Type", "application/xml")
+
+		resp, err := c.do(ctx, http.MethodPost, "", url.Values{"delete": {""}}, header,
+			bytes.NewReader(body), int64(len(body)), hashHex(body))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+	}
+	return nil
+}
+
+// objectURL 返回对象的URL
+func (c *Client) objectURL(key string, query url.Values) *url.URL {
+	u := *c.endpoint
+	basePath := strings.TrimSuffix(u.Path, "/")
+
+	path := "/" + key
+	if c.cfg.PathStyle {
+		path = "/" + c.cfg.Bucket + path
+	} else {
+		u.Host = c.cfg.Bucket + "." + u.Host
+	}
+
+	u.Path = basePath + path
+	u.RawPath = Escape(basePath, false) + Escape(path, false)
+	u.RawQuery = ""
+	if len(query) > 0 {
+		u.RawQuery = canonicalQuery(query)
+	}
+	return &u
+}
+
+// do 发送签名请求，非2xx响应转换为错误
+func (c *Client) do(ctx context.Context, method, key string, query url.Values, header http.Header, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.objectURL(key, query).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+
+	Sign(req, Credentials{
+		AccessKeyID:     c.cfg.AccessKeyID,
+		SecretAccessKey: c.cfg.SecretAccessKey,
+		SessionToken:    c.cfg.SessionToken,
+	}, c.cfg.Region, "s3", payloadHash, c.now())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 || resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	s3Err := &Error{StatusCode: resp.StatusCode}
+	if method != http.MethodHead {
+		xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(s3Err)
+	}
+	return nil, s3Err
+}
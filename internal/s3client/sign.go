@@ -0,0 +1,254 @@
+package s3client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// 签名算法
+	signAlgorithm = "AWS4-HMAC-SHA256"
+	// UnsignedPayload 流式上传时不对请求体签名
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+	// EmptyPayloadHash 空请求体的SHA256
+	EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	amzDateFormat = "20060102T150405Z"
+)
+
+// Credentials 访问凭证
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// 校验签名的错误
+var (
+	// ErrMissingSignature 请求没有签名
+	ErrMissingSignature = errors.New("missing signature")
+	// ErrMalformedAuthorization Authorization头格式错误
+	ErrMalformedAuthorization = errors.New("malformed authorization header")
+	// ErrInvalidAccessKey 访问密钥ID不存在
+	ErrInvalidAccessKey = errors.New("invalid access key id")
+	// ErrSignatureMismatch 签名不匹配
+	ErrSignatureMismatch = errors.New("signature does not match")
+	// ErrRequestTimeSkewed 请求时间与服务器时间相差超过15分钟
+	ErrRequestTimeSkewed = errors.New("request time too skewed")
+)
+
+// maxClockSkew 允许的最大时钟偏差
+const maxClockSkew = 15 * time.Minute
+
+// Sign 按AWS Signature Version 4为请求签名，payloadHash为请求体的十六进制SHA256或UnsignedPayload
+func Sign(req *http.Request, creds Credentials, region, service, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(amzDateFormat)
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	names := signedHeaderNames(req)
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	signature := computeSignature(req, names, payloadHash, creds.SecretAccessKey, amzDate, scope)
+
+	req.Header.Set("Authorization", signAlgorithm+
+		" Credential="+creds.AccessKeyID+"/"+scope+
+		",SignedHeaders="+strings.Join(names, ";")+
+		",Signature="+signature)
+}
+
+// Verify 校验请求的Signature Version 4签名，secret根据访问密钥ID返回密钥，成功时返回访问密钥ID
+// 只支持Authorization头签名，请求体的哈希由调用方在读取请求体时校验
+func Verify(req *http.Request, secret func(accessKeyID string) (string, bool), now time.Time) (string, error) {
+	auth := req.Header.Get("Authorization")
+	if auth == "" {
+		return "", ErrMissingSignature
+	}
+	rest, ok := strings.CutPrefix(auth, signAlgorithm+" ")
+	if !ok {
+		return "", ErrMalformedAuthorization
+	}
+
+	fields := make(map[string]string)
+	for _, part := range strings.Split(rest, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return "", ErrMalformedAuthorization
+		}
+		fields[name] = value
+	}
+	accessKeyID, scope, ok := strings.Cut(fields["Credential"], "/")
+	if !ok || fields["SignedHeaders"] == "" || fields["Signature"] == "" {
+		return "", ErrMalformedAuthorization
+	}
+	names := strings.Split(fields["SignedHeaders"], ";")
+
+	amzDate := req.Header.Get("X-Amz-Date")
+	signedAt, err := time.Parse(amzDateFormat, amzDate)
+	if err != nil || !strings.HasPrefix(scope, amzDate[:8]+"/") {
+		return "", ErrMalformedAuthorization
+	}
+	if skew := now.Sub(signedAt); skew > maxClockSkew || skew < -maxClockSkew {
+		return "", ErrRequestTimeSkewed
+	}
+	payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		return "", ErrMalformedAuthorization
+	}
+
+	secretAccessKey, ok := secret(accessKeyID)
+	if !ok {
+		return "", ErrInvalidAccessKey
+	}
+	want := computeSignature(req, names, payloadHash, secretAccessKey, amzDate, scope)
+	if !hmac.Equal([]byte(want), []byte(fields["Signature"])) {
+		return "", ErrSignatureMismatch
+	}
+	return accessKeyID, nil
+}
+
+// computeSignature 计算请求的签名，scope为 <date>/<region>/<service>/aws4_request
+func computeSignature(req *http.Request, names []string, payloadHash, secretAccessKey, amzDate, scope string) string {
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders(req, names),
+		strings.Join(names, ";"),
+		payloadHash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		signAlgorithm,
+		amzDate,
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := []byte("AWS4" + secretAccessKey)
+	for _, part := range strings.Split(scope, "/") {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// signedHeaderNames 返回需要签名的请求头，签名覆盖Host、Content-Type、Range及所有x-amz-*头
+func signedHeaderNames(req *http.Request) []string {
+	names := []string{"host"}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || lower == "content-md5" || lower == "range" || strings.HasPrefix(lower, "x-amz-") {
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// canonicalHeaders 返回规范化的请求头，names为排好序的小写请求头名称
+func canonicalHeaders(req *http.Request, names []string) string {
+	var b strings.Builder
+	for _, name := range names {
+		var value string
+		switch name {
+		case "host":
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		case "content-length":
+			// 服务端收到的请求中Content-Length不在Header里
+			value = req.Header.Get("Content-Length")
+			if value == "" && req.ContentLength >= 0 {
+				value = strconv.FormatInt(req.ContentLength, 10)
+			}
+		default:
+			values := req.Header.Values(name)
+			trimmed := make([]string, len(values))
+			for i, v := range values {
+				trimmed[i] = strings.Join(strings.Fields(v), " ")
+			}
+			value = strings.Join(trimmed, ",")
+		}
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(value)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// canonicalURI 返回规范化的路径
+func canonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// canonicalQuery 返回按键排序并编码的查询字符串
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, Escape(k, true)+"="+Escape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// Escape 按AWS规则进行URI编码，encodeSlash为false时保留路径分隔符
+func Escape(s string, encodeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&0x0f])
+		}
+	}
+	return b.String()
+}
+
+// hashHex 返回SHA256的十六进制编码
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,196 @@
+// Package s3test 提供用于测试的内存S3兼容服务，只支持路径风格访问
+package s3test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// object 存储的对象
+type object struct {
+	data         []byte
+	header       http.Header
+	etag         string
+	lastModified time.Time
+}
+
+// Server 内存S3服务
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	buckets map[string]map[string]*object
+}
+
+// NewServer 启动内存S3服务，bucket为预先创建的存储桶
+func NewServer(buckets ...string) *Server {
+	s := &Server{buckets: make(map[string]map[string]*object)}
+	for _, bucket := range buckets {
+		s.buckets[bucket] = make(map[string]*object)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Keys 返回存储桶中的所有对象键
+func (s *Server) Keys(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []string
+	for key := range s.buckets[bucket] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+		writeError(w, http.StatusForbidden, "AccessDenied", "missing signature")
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	bucketName, key, _ := strings.Cut(path, "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, ok := s.buckets[bucketName]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchBucket", "bucket does not exist")
+		return
+	}
+
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		s.list(w, r, bucket)
+	case key == "" && r.Method == http.MethodPost && r.URL.Query().Has("delete"):
+		s.deleteObjects(w, r, bucket)
+	case r.Method == http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+			return
+		}
+		header := http.Header{}
+		for name, values := range r.Header {
+			lower := strings.ToLower(name)
+			if lower == "content-type" || strings.HasPrefix(lower, "x-amz-meta-") {
+				header[name] = values
+			}
+		}
+		sum := md5.Sum(data)
+		bucket[key] = &object{
+			data:         data,
+			header:       header,
+			etag:         `"` + hex.EncodeToString(sum[:]) + `"`,
+			lastModified: time.Now().UTC().Truncate(time.Second),
+		}
+		w.Header().Set("ETag", bucket[key].etag)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		obj, ok := bucket[key]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey", "key does not exist")
+			return
+		}
+		for name, values := range obj.header {
+			w.Header()[name] = values
+		}
+		w.Header().Set("ETag", obj.etag)
+		http.ServeContent(w, r, "", obj.lastModified, bytes.NewReader(obj.data))
+	case r.Method == http.MethodDelete:
+		delete(bucket, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+	}
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request, bucket map[string]*object) {
+	type content struct {
+		Key          string `xml:"Key"`
+		Size         int64  `xml:"Size"`
+		LastModified string `xml:"LastModified"`
+		ETag         string `xml:"ETag"`
+	}
+	type result struct {
+		XMLName               xml.Name  `xml:"ListBucketResult"`
+		Contents              []content `xml:"Contents"`
+		IsTruncated           bool      `xml:"IsTruncated"`
+		NextContinuationToken string    `xml:"NextContinuationToken,omitempty"`
+	}
+
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	after := query.Get("continuation-token")
+	maxKeys, err := strconv.Atoi(query.Get("max-keys"))
+	if err != nil || maxKeys <= 0 {
+		maxKeys = 1000
+	}
+
+	var keys []string
+	for key := range bucket {
+		if strings.HasPrefix(key, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	res := result{}
+	if len(keys) > maxKeys {
+		keys = keys[:maxKeys]
+		res.IsTruncated = true
+		res.NextContinuationToken = keys[len(keys)-1]
+	}
+	for _, key := range keys {
+		obj := bucket[key]
+		res.Contents = append(res.Contents, content{
+			Key:          key,
+			Size:         int64(len(obj.data)),
+			LastModified: obj.lastModified.Format(time.RFC3339),
+			ETag:         obj.etag,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(res)
+}
+
+func (s *Server) deleteObjects(w http.ResponseWriter, r *http.Request, bucket map[string]*object) {
+	var req struct {
+		Objects []struct {
+			Key string `xml:"Key"`
+		} `xml:"Object"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "MalformedXML", err.Error())
+		return
+	}
+	for _, obj := range req.Objects {
+		delete(bucket, obj.Key)
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	io.WriteString(w, "<DeleteResult></DeleteResult>")
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string   `xml:"Code"`
+		Message string   `xml:"Message"`
+	}{Code: code, Message: message})
+}
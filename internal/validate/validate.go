@@ -0,0 +1,87 @@
+// Package validate 收集配置检查中发现的所有问题，每个问题带有出错字段的路径，例如
+// "sites[0].options.upstream: invalid upstream"，路径使用配置文件中的字段名（结构体的json标签）
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// FieldError 带有字段路径的配置错误
+type FieldError struct {
+	Path string // 字段路径，例如cache.backup.interval、sites[1].hosts
+	Err  error
+}
+
+// Error 返回"路径: 错误"
+func (e *FieldError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap 返回原始错误
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Errors 按字段路径收集的配置错误，零值可以直接使用
+type Errors []error
+
+// Add 记录字段path的错误，err为nil时忽略。err是Errors.Err或errors.Join的结果时逐个记录，
+// 其中的FieldError的路径接在path之后，因此下级的检查只需要使用相对路径
+func (e *Errors) Add(path string, err error) {
+	if err == nil {
+		return
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			e.Add(path, err)
+		}
+		return
+	}
+	if field, ok := err.(*FieldError); ok {
+		*e = append(*e, &FieldError{Path: Join(path, field.Path), Err: field.Err})
+		return
+	}
+	*e = append(*e, &FieldError{Path: path, Err: err})
+}
+
+// Addf 按格式记录字段path的错误
+func (e *Errors) Addf(path, format string, args ...interface{}) {
+	e.Add(path, fmt.Errorf(format, args...))
+}
+
+// Err 返回所有错误，每个一行；没有错误时返回nil
+func (e Errors) Err() error {
+	return errors.Join(e...)
+}
+
+// Join 拼接字段路径，下标（"[0]"）直接接在前面的路径之后
+func Join(parent, child string) string {
+	switch {
+	case parent == "":
+		return child
+	case child == "":
+		return parent
+	case strings.HasPrefix(child, "["):
+		return parent + child
+	default:
+		return parent + "." + child
+	}
+}
+
+// Index 返回列表元素的路径，例如Index("sites", 2)为"sites[2]"
+func Index(path string, i int) string {
+	return fmt.Sprintf("%s[%d]", path, i)
+}
+
+// Key 返回map元素的路径，例如Key("namespace_quotas", "tenant")为`namespace_quotas["tenant"]`
+func Key(path string, key interface{}) string {
+	if s, ok := key.(string); ok {
+		return fmt.Sprintf("%s[%q]", path, s)
+	}
+	return fmt.Sprintf("%s[%v]", path, key)
+}
@@ -0,0 +1,54 @@
+package validate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	t.Run("Empty", func(t *testing.T) {
+		var errs Errors
+		errs.Add("cache", nil)
+		if err := errs.Err(); err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		var options Errors
+		options.Addf("upstream", "invalid upstream %q", "ftp://origin")
+		options.Addf(Index("headers", 1), "invalid header name %q", "")
+
+		var site Errors
+		site.Addf("hosts", "cannot be empty")
+		site.Add("options", options.Err())
+
+		var errs Errors
+		errs.Add(Index("sites", 2), site.Err())
+		errs.Addf(Key("cache.namespace_quotas", "tenant"), "must be positive")
+
+		want := []string{
+			`sites[2].hosts: cannot be empty`,
+			`sites[2].options.upstream: invalid upstream "ftp://origin"`,
+			`sites[2].options.headers[1]: invalid header name ""`,
+			`cache.namespace_quotas["tenant"]: must be positive`,
+		}
+		if got := errs.Err().Error(); got != strings.Join(want, "\n") {
+			t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(want, "\n"), got)
+		}
+	})
+
+	t.Run("Unwrap", func(t *testing.T) {
+		sentinel := errors.New("boom")
+		var errs Errors
+		errs.Add("server.tls", sentinel)
+		if err := errs.Err(); !errors.Is(err, sentinel) {
+			t.Errorf("Expected error to wrap the original, got %v", err)
+		}
+		var field *FieldError
+		if !errors.As(errs.Err(), &field) || field.Path != "server.tls" {
+			t.Errorf("Expected a FieldError for server.tls, got %v", field)
+		}
+	})
+}
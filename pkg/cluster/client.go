@@ -0,0 +1,496 @@
+// Package cluster 把多个EdgeOrigin节点当作一个键空间使用
+// 客户端按一致性哈希（或最高随机权重哈希）把每个键路由到唯一的节点，
+// 节点列表可以是固定的，也可以来自定期刷新的发现源
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// ErrNoNodes 集群中没有可用节点
+var ErrNoNodes = errors.New("cluster has no nodes")
+
+// Options 集群客户端选项
+type Options struct {
+	Nodes     []Node    // 固定的节点列表，Discovery为空时使用
+	Discovery Discovery // 节点发现来源，优先于Nodes
+
+	// Dial 为节点创建缓存客户端，节点被移除或客户端关闭时调用其Close
+	Dial func(node Node) (filecache.Cache, error)
+
+	Hash            HashMode      // 哈希算法，默认ConsistentHash
+	VirtualNodes    int           // 一致性哈希中每单位权重的虚拟节点数，默认160
+	RefreshInterval time.Duration // 从Discovery刷新节点列表的间隔，0表示只在创建时读取一次
+	OnError         func(error)   // 后台刷新失败时回调，可用于记录日志
+}
+
+// member 集群中的一个节点及其缓存客户端
+type member struct {
+	node  Node
+	cache filecache.Cache
+}
+
+// state 所有命名空间共享的节点状态
+type state struct {
+	opts Options
+
+	mu      sync.RWMutex
+	members map[string]*member
+	picker  picker
+
+	refreshMu sync.Mutex // 串行化刷新，避免重复Dial
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// Client 按键哈希把请求路由到集群节点的缓存，实现filecache.Cache
+// 单键操作只访问键所在的节点，List、Cleanup、Flush、Stats作用于所有节点
+type Client struct {
+	state *state
+	path  []string // 命名空间路径，根客户端为空
+
+	mu           sync.RWMutex // 保护命中统计
+	hits, misses int64
+
+	namespaces map[string]*Client
+	nsMu       sync.Mutex
+}
+
+// New 创建集群客户端，读取一次节点列表并连接所有节点
+func New(ctx context.Context, opts Options) (*Client, error) {
+	if opts.Dial == nil {
+		return nil, fmt.Errorf("cluster dial function cannot be nil")
+	}
+	if opts.Discovery == nil {
+		opts.Discovery = StaticNodes(opts.Nodes)
+	}
+	if opts.VirtualNodes <= 0 {
+		opts.VirtualNodes = defaultVirtualNodes
+	}
+	if opts.Hash != ConsistentHash && opts.Hash != Rendezvous {
+		return nil, fmt.Errorf("unknown hash mode %d", opts.Hash)
+	}
+
+	s := &state{
+		opts:    opts,
+		members: make(map[string]*member),
+		done:    make(chan struct{}),
+	}
+	if err := s.refresh(ctx); err != nil {
+		s.close()
+		return nil, err
+	}
+	if opts.RefreshInterval > 0 {
+		s.wg.Add(1)
+		go s.refreshLoop()
+	}
+
+	return newClient(s, nil), nil
+}
+
+// newClient 返回指定命名空间路径的客户端
+func newClient(s *state, path []string) *Client {
+	return &Client{state: s, path: path, namespaces: make(map[string]*Client)}
+}
+
+// Refresh 立即从发现源刷新节点列表
+func (c *Client) Refresh(ctx context.Context) error {
+	return c.state.refresh(ctx)
+}
+
+// Nodes 返回当前的节点列表，按ID排序
+func (c *Client) Nodes() []Node {
+	c.state.mu.RLock()
+	defer c.state.mu.RUnlock()
+
+	nodes := make([]Node, 0, len(c.state.members))
+	for _, m := range c.state.members {
+		nodes = append(nodes, m.node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes
+}
+
+// Owner 返回键所在的节点，集群中没有节点时返回false
+func (c *Client) Owner(key string) (Node, bool) {
+	c.state.mu.RLock()
+	defer c.state.mu.RUnlock()
+
+	m := c.state.picker.pick(key)
+	if m == nil {
+		return Node{}, false
+	}
+	return m.node, true
+}
+
+// node 返回键所在节点上当前命名空间的缓存
+func (c *Client) node(key string) (filecache.Cache, error) {
+	c.state.mu.RLock()
+	m := c.state.picker.pick(key)
+	c.state.mu.RUnlock()
+
+	if m == nil {
+		return nil, ErrNoNodes
+	}
+	return resolve(m.cache, c.path), nil
+}
+
+// Set 存储文件到键所在的节点
+func (c *Client) Set(ctx context.Context, key string, data io.Reader, mimeType string, ttl time.Duration) error {
+	cache, err := c.node(key)
+	if err != nil {
+		return err
+	}
+	return cache.Set(ctx, key, data, mimeType, ttl)
+}
+
+// Get 从键所在的节点获取文件
+func (c *Client) Get(ctx context.Context, key string) (io.ReadCloser, *filecache.FileInfo, error) {
+	cache, err := c.node(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader, info, err := cache.Get(ctx, key)
+	if err != nil {
+		c.recordMiss()
+		return nil, nil, err
+	}
+	c.recordHit()
+	return reader, info, nil
+}
+
+// Exists 检查文件是否存在
+func (c *Client) Exists(ctx context.Context, key string) (bool, error) {
+	cache, err := c.node(key)
+	if err != nil {
+		return false, err
+	}
+	return cache.Exists(ctx, key)
+}
+
+// Delete 从键所在的节点删除文件
+func (c *Client) Delete(ctx context.Context, key string) error {
+	cache, err := c.node(key)
+	if err != nil {
+		return err
+	}
+	return cache.Delete(ctx, key)
+}
+
+// GetInfo 获取文件信息
+func (c *Client) GetInfo(ctx context.Context, key string) (*filecache.FileInfo, error) {
+	cache, err := c.node(key)
+	if err != nil {
+		return nil, err
+	}
+	return cache.GetInfo(ctx, key)
+}
+
+// List 并行列出所有节点中的文件，结果按键排序
+// 节点增减后旧节点上可能残留不再归属它的键，这些键会出现在结果中，
+// 同一个键出现在多个节点上时只返回当前归属节点上的那一份
+func (c *Client) List(ctx context.Context) ([]*filecache.FileInfo, error) {
+	members := c.state.snapshot()
+	lists := make([][]*filecache.FileInfo, len(members))
+	err := each(members, func(i int, m *member) error {
+		files, err := resolve(m.cache, c.path).List(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list node %s: %w", m.node.ID, err)
+		}
+		lists[i] = files
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]int)
+	var files []*filecache.FileInfo
+	for i, list := range lists {
+		for _, info := range list {
+			j, ok := seen[info.Key]
+			if !ok {
+				seen[info.Key] = len(files)
+				files = append(files, info)
+				continue
+			}
+			if owner, ok := c.Owner(info.Key); ok && owner.ID == members[i].node.ID {
+				files[j] = info
+			}
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Key < files[j].Key })
+	return files, nil
+}
+
+// Cleanup 并行清理所有节点
+func (c *Client) Cleanup(ctx context.Context) error {
+	return each(c.state.snapshot(), func(_ int, m *member) error {
+		if err := resolve(m.cache, c.path).Cleanup(ctx); err != nil {
+			return fmt.Errorf("failed to clean up node %s: %w", m.node.ID, err)
+		}
+		return nil
+	})
+}
+
+// Flush 清空所有节点
+func (c *Client) Flush(ctx context.Context) error {
+	err := each(c.state.snapshot(), func(_ int, m *member) error {
+		if err := resolve(m.cache, c.path).Flush(ctx); err != nil {
+			return fmt.Errorf("failed to flush node %s: %w", m.node.ID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.hits, c.misses = 0, 0
+	c.mu.Unlock()
+	return nil
+}
+
+// Namespace 返回由各节点的同名命名空间组成的集群客户端
+func (c *Client) Namespace(name string) filecache.Cache {
+	if name == "" {
+		return c
+	}
+
+	c.nsMu.Lock()
+	defer c.nsMu.Unlock()
+
+	if ns, ok := c.namespaces[name]; ok {
+		return ns
+	}
+	path := append(append([]string(nil), c.path...), name)
+	ns := newClient(c.state, path)
+	c.namespaces[name] = ns
+	return ns
+}
+
+// Close 停止刷新并关闭所有节点的缓存客户端，命名空间的Close不做任何事
+func (c *Client) Close() error {
+	if len(c.path) > 0 {
+		return nil
+	}
+	return c.state.close()
+}
+
+// Stats 汇总各节点的统计信息，命中率按集群客户端整体计算
+func (c *Client) Stats() (*filecache.Stats, error) {
+	members := c.state.snapshot()
+	all := make([]*filecache.Stats, len(members))
+	err := each(members, func(i int, m *member) error {
+		stats, err := resolve(m.cache, c.path).Stats()
+		if err != nil {
+			return fmt.Errorf("failed to get stats of node %s: %w", m.node.ID, err)
+		}
+		all[i] = stats
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &filecache.Stats{}
+	for _, s := range all {
+		stats.TotalFiles += s.TotalFiles
+		stats.TotalSize += s.TotalSize
+		stats.ExpiredFiles += s.ExpiredFiles
+		stats.Evictions += s.Evictions
+		if s.LastCleanup.After(stats.LastCleanup) {
+			stats.LastCleanup = s.LastCleanup
+		}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if total := float64(c.hits + c.misses); total > 0 {
+		stats.HitRate = float64(c.hits) / total
+		stats.MissRate = float64(c.misses) / total
+	}
+	return stats, nil
+}
+
+// recordHit 记录一次命中
+func (c *Client) recordHit() {
+	c.mu.Lock()
+	c.hits++
+	c.mu.Unlock()
+}
+
+// recordMiss 记录一次未命中
+func (c *Client) recordMiss() {
+	c.mu.Lock()
+	c.misses++
+	c.mu.Unlock()
+}
+
+// refreshLoop 定期刷新节点列表
+func (s *state) refreshLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.opts.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), s.opts.RefreshInterval)
+			err := s.refresh(ctx)
+			cancel()
+			if err != nil && s.opts.OnError != nil {
+				s.opts.OnError(err)
+			}
+		}
+	}
+}
+
+// refresh 读取节点列表，连接新节点，替换路由表后关闭已移除的节点
+// 地址或权重变化的节点视为新节点；任何一个新节点连接失败时保留原路由表
+func (s *state) refresh(ctx context.Context) error {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	nodes, err := s.opts.Discovery.Nodes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to discover nodes: %w", err)
+	}
+
+	s.mu.RLock()
+	current := s.members
+	s.mu.RUnlock()
+
+	members := make(map[string]*member, len(nodes))
+	var dialed []*member
+	fail := func(err error) error {
+		for _, m := range dialed {
+			m.cache.Close()
+		}
+		return err
+	}
+	for _, node := range nodes {
+		if node.ID == "" {
+			return fail(fmt.Errorf("node id cannot be empty"))
+		}
+		if _, ok := members[node.ID]; ok {
+			return fail(fmt.Errorf("duplicate node id %q", node.ID))
+		}
+		if m, ok := current[node.ID]; ok && m.node == node {
+			members[node.ID] = m
+			continue
+		}
+		cache, err := s.opts.Dial(node)
+		if err != nil {
+			return fail(fmt.Errorf("failed to dial node %s: %w", node.ID, err))
+		}
+		m := &member{node: node, cache: cache}
+		members[node.ID] = m
+		dialed = append(dialed, m)
+	}
+
+	list := make([]*member, 0, len(members))
+	for _, m := range members {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].node.ID < list[j].node.ID })
+
+	var p picker
+	if s.opts.Hash == Rendezvous {
+		p = &rendezvous{members: list}
+	} else {
+		p = newHashRing(list, s.opts.VirtualNodes)
+	}
+
+	s.mu.Lock()
+	s.members = members
+	s.picker = p
+	s.mu.Unlock()
+
+	// 仍在使用旧节点的请求可能因关闭而失败
+	for id, m := range current {
+		if members[id] != m {
+			m.cache.Close()
+		}
+	}
+	return nil
+}
+
+// snapshot 返回当前所有节点，按ID排序
+func (s *state) snapshot() []*member {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]*member, 0, len(s.members))
+	for _, m := range s.members {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].node.ID < list[j].node.ID })
+	return list
+}
+
+// close 停止刷新并关闭所有节点
+func (s *state) close() error {
+	var firstErr error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+
+		s.refreshMu.Lock()
+		defer s.refreshMu.Unlock()
+
+		s.mu.Lock()
+		members := s.members
+		s.members = make(map[string]*member)
+		s.picker = newHashRing(nil, s.opts.VirtualNodes)
+		s.mu.Unlock()
+
+		for _, m := range members {
+			if err := m.cache.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	})
+	return firstErr
+}
+
+// resolve 返回路径对应的命名空间
+func resolve(cache filecache.Cache, path []string) filecache.Cache {
+	for _, name := range path {
+		cache = cache.Namespace(name)
+	}
+	return cache
+}
+
+// each 并行对每个节点执行fn，返回第一个错误
+func each(members []*member, fn func(i int, m *member) error) error {
+	errs := make([]error, len(members))
+	var wg sync.WaitGroup
+	for i, m := range members {
+		wg.Add(1)
+		go func(i int, m *member) {
+			defer wg.Done()
+			errs[i] = fn(i, m)
+		}(i, m)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// testFleet 以内存缓存模拟的节点集合，按地址复用同一个缓存
+type testFleet struct {
+	mu     sync.Mutex
+	caches map[string]filecache.Cache
+}
+
+func newTestFleet() *testFleet {
+	return &testFleet{caches: make(map[string]filecache.Cache)}
+}
+
+// dial 返回节点的缓存，关闭客户端不会清空节点上的数据
+func (f *testFleet) dial(node Node) (filecache.Cache, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cache, ok := f.caches[node.Addr]
+	if !ok {
+		cache = filecache.NewMemoryCache(1 << 20)
+		f.caches[node.Addr] = cache
+	}
+	return nopCloser{cache}, nil
+}
+
+type nopCloser struct {
+	filecache.Cache
+}
+
+func (nopCloser) Close() error { return nil }
+
+func testNodes(n int) []Node {
+	nodes := make([]Node, n)
+	for i := range nodes {
+		nodes[i] = Node{ID: fmt.Sprintf("node-%d", i), Addr: fmt.Sprintf("10.0.0.%d:7070", i)}
+	}
+	return nodes
+}
+
+func readString(t *testing.T, cache filecache.Cache, key string) string {
+	t.Helper()
+	reader, _, err := cache.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Failed to get %s: %v", key, err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", key, err)
+	}
+	return string(data)
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+
+	for _, mode := range []HashMode{ConsistentHash, Rendezvous} {
+		t.Run(fmt.Sprintf("Mode%d", mode), func(t *testing.T) {
+			fleet := newTestFleet()
+			client, err := New(ctx, Options{Nodes: testNodes(4), Dial: fleet.dial, Hash: mode})
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+			defer client.Close()
+
+			keys := make([]string, 200)
+			for i := range keys {
+				keys[i] = fmt.Sprintf("file-%03d", i)
+				if err := client.Set(ctx, keys[i], strings.NewReader(keys[i]), "text/plain", time.Hour); err != nil {
+					t.Fatalf("Failed to set file: %v", err)
+				}
+			}
+
+			t.Run("SingleOwner", func(t *testing.T) {
+				for _, key := range keys {
+					owner, ok := client.Owner(key)
+					if !ok {
+						t.Fatal("Expected key to have an owner")
+					}
+					for addr, cache := range fleet.caches {
+						exists, _ := cache.Exists(ctx, key)
+						if exists != (addr == owner.Addr) {
+							t.Fatalf("Expected %s only on %s, found on %s: %v", key, owner.Addr, addr, exists)
+						}
+					}
+				}
+				for addr, cache := range fleet.caches {
+					files, _ := cache.List(ctx)
+					if len(files) < len(keys)/8 {
+						t.Errorf("Expected keys to be spread evenly, %s has %d", addr, len(files))
+					}
+				}
+			})
+
+			t.Run("GetAndDelete", func(t *testing.T) {
+				if got := readString(t, client, "file-007"); got != "file-007" {
+					t.Errorf("Expected 'file-007', got %q", got)
+				}
+				if err := client.Delete(ctx, "file-007"); err != nil {
+					t.Fatalf("Failed to delete file: %v", err)
+				}
+				if exists, _ := client.Exists(ctx, "file-007"); exists {
+					t.Error("Expected file to be deleted")
+				}
+			})
+
+			t.Run("ListAndStats", func(t *testing.T) {
+				files, err := client.List(ctx)
+				if err != nil {
+					t.Fatalf("Failed to list files: %v", err)
+				}
+				if len(files) != len(keys)-1 {
+					t.Errorf("Expected %d files, got %d", len(keys)-1, len(files))
+				}
+				stats, err := client.Stats()
+				if err != nil {
+					t.Fatalf("Failed to get stats: %v", err)
+				}
+				if stats.TotalFiles != int64(len(keys)-1) {
+					t.Errorf("Expected %d files in stats, got %d", len(keys)-1, stats.TotalFiles)
+				}
+			})
+
+			t.Run("Namespace", func(t *testing.T) {
+				ns := client.Namespace("tenant")
+				if err := ns.Set(ctx, "file-000", strings.NewReader("tenant"), "text/plain", time.Hour); err != nil {
+					t.Fatalf("Failed to set file: %v", err)
+				}
+				if got := readString(t, ns, "file-000"); got != "tenant" {
+					t.Errorf("Expected 'tenant', got %q", got)
+				}
+				if got := readString(t, client, "file-000"); got != "file-000" {
+					t.Errorf("Expected root namespace to be isolated, got %q", got)
+				}
+				if err := ns.Flush(ctx); err != nil {
+					t.Fatalf("Failed to flush namespace: %v", err)
+				}
+				if exists, _ := client.Exists(ctx, "file-000"); !exists {
+					t.Error("Expected namespace flush to keep root files")
+				}
+			})
+		})
+	}
+}
+
+func TestClientRebalance(t *testing.T) {
+	ctx := context.Background()
+
+	for _, mode := range []HashMode{ConsistentHash, Rendezvous} {
+		t.Run(fmt.Sprintf("Mode%d", mode), func(t *testing.T) {
+			nodes := testNodes(5)
+			var mu sync.Mutex
+			current := nodes
+			discovery := DiscoveryFunc(func(context.Context) ([]Node, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				return current, nil
+			})
+
+			fleet := newTestFleet()
+			client, err := New(ctx, Options{Discovery: discovery, Dial: fleet.dial, Hash: mode})
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+			defer client.Close()
+
+			before := make(map[string]string)
+			for i := 0; i < 2000; i++ {
+				key := fmt.Sprintf("key-%d", i)
+				owner, _ := client.Owner(key)
+				before[key] = owner.ID
+			}
+
+			mu.Lock()
+			current = nodes[:4]
+			mu.Unlock()
+			if err := client.Refresh(ctx); err != nil {
+				t.Fatalf("Failed to refresh: %v", err)
+			}
+			if got := len(client.Nodes()); got != 4 {
+				t.Fatalf("Expected 4 nodes after refresh, got %d", got)
+			}
+
+			moved := 0
+			for key, id := range before {
+				owner, _ := client.Owner(key)
+				if owner.ID == nodes[4].ID {
+					t.Fatalf("Expected removed node to own no keys")
+				}
+				if id != nodes[4].ID && owner.ID != id {
+					moved++
+				}
+			}
+			if moved != 0 {
+				t.Errorf("Expected only keys of the removed node to move, %d others moved", moved)
+			}
+		})
+	}
+}
+
+func TestClientDiscoveryRefresh(t *testing.T) {
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	current := testNodes(1)
+	discovery := DiscoveryFunc(func(context.Context) ([]Node, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return current, nil
+	})
+
+	fleet := newTestFleet()
+	client, err := New(ctx, Options{Discovery: discovery, Dial: fleet.dial, RefreshInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	mu.Lock()
+	current = testNodes(3)
+	mu.Unlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(client.Nodes()) != 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 3 nodes after refresh, got %d", len(client.Nodes()))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Run("InvalidNodes", func(t *testing.T) {
+		mu.Lock()
+		current = []Node{{ID: "a"}, {ID: "a"}}
+		mu.Unlock()
+		if err := client.Refresh(ctx); err == nil {
+			t.Error("Expected error for duplicate node ids")
+		}
+		if got := len(client.Nodes()); got != 3 {
+			t.Errorf("Expected failed refresh to keep 3 nodes, got %d", got)
+		}
+	})
+
+	t.Run("NoNodes", func(t *testing.T) {
+		empty, err := New(ctx, Options{Dial: fleet.dial})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		defer empty.Close()
+		if err := empty.Set(ctx, "k", strings.NewReader("v"), "text/plain", time.Hour); err != ErrNoNodes {
+			t.Errorf("Expected ErrNoNodes, got %v", err)
+		}
+	})
+}
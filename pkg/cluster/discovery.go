@@ -0,0 +1,40 @@
+package cluster
+
+import "context"
+
+// Node 集群中的一个节点
+type Node struct {
+	ID     string `json:"id"`     // 节点标识，决定键的分布，重启后应保持不变
+	Addr   string `json:"addr"`   // 节点地址，传给Dial
+	Weight int    `json:"weight"` // 权重，默认1，权重为2的节点分到约两倍的键
+}
+
+// weight 返回有效权重
+func (n Node) weight() int {
+	if n.Weight <= 0 {
+		return 1
+	}
+	return n.Weight
+}
+
+// Discovery 节点发现来源
+type Discovery interface {
+	// Nodes 返回当前的节点列表
+	Nodes(ctx context.Context) ([]Node, error)
+}
+
+// StaticNodes 固定的节点列表
+type StaticNodes []Node
+
+// Nodes 返回固定的节点列表
+func (s StaticNodes) Nodes(context.Context) ([]Node, error) {
+	return s, nil
+}
+
+// DiscoveryFunc 把函数适配为Discovery
+type DiscoveryFunc func(ctx context.Context) ([]Node, error)
+
+// Nodes 调用f
+func (f DiscoveryFunc) Nodes(ctx context.Context) ([]Node, error) {
+	return f(ctx)
+}
@@ -0,0 +1,380 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/seraphico/EdgeOrigin/internal/grpcwire"
+)
+
+const (
+	defaultGossipInterval = time.Second
+	defaultFanout         = 3
+	defaultSuspectTimeout = 5 * time.Second
+	defaultDeadRetention  = time.Minute
+)
+
+// MembershipOptions 成员管理选项
+type MembershipOptions struct {
+	Self  Node     // 本节点，Addr为同时提供Gossip服务和缓存服务的地址
+	Seeds []string // 加入集群时联系的节点地址，成员表中没有可用节点时也会重新联系
+
+	// Dial 连接其他节点的Gossip服务，默认使用不加密的gRPC连接
+	Dial func(addr string) (*grpc.ClientConn, error)
+
+	GossipInterval time.Duration // 交换成员表的间隔，默认1秒
+	Fanout         int           // 每轮交换的节点数，默认3
+	SuspectTimeout time.Duration // 被怀疑的节点超过该时间没有反驳时判定为失效，默认5秒
+	DeadRetention  time.Duration // 失效节点的记录保留时间，用于阻止过时的状态复活它，默认1分钟
+	OnError        func(error)   // 与其他节点交换失败时回调，可用于记录日志
+}
+
+// memberEntry 成员表中的记录及其最后一次变化的本地时间
+type memberEntry struct {
+	state   MemberState
+	changed time.Time
+}
+
+// Membership 通过Gossip协议维护集群成员表，实现Discovery
+// 每轮随机选择几个节点推拉完整的成员表；交换失败的节点被标记为SUSPECT，
+// 超时未反驳后判定为DEAD，从Nodes的结果中移除
+type Membership struct {
+	opts MembershipOptions
+
+	mu      sync.Mutex
+	members map[string]*memberEntry
+
+	connMu sync.Mutex
+	conns  map[string]*grpc.ClientConn
+}
+
+// NewMembership 创建成员表，只包含本节点；调用Register注册服务后用Join加入集群
+func NewMembership(opts MembershipOptions) (*Membership, error) {
+	if opts.Self.ID == "" {
+		return nil, fmt.Errorf("node id cannot be empty")
+	}
+	if opts.Self.Addr == "" {
+		return nil, fmt.Errorf("node address cannot be empty")
+	}
+	if opts.Dial == nil {
+		opts.Dial = func(addr string) (*grpc.ClientConn, error) {
+			return grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		}
+	}
+	if opts.GossipInterval <= 0 {
+		opts.GossipInterval = defaultGossipInterval
+	}
+	if opts.Fanout <= 0 {
+		opts.Fanout = defaultFanout
+	}
+	if opts.SuspectTimeout <= 0 {
+		opts.SuspectTimeout = defaultSuspectTimeout
+	}
+	if opts.DeadRetention <= 0 {
+		opts.DeadRetention = defaultDeadRetention
+	}
+
+	m := &Membership{
+		opts:    opts,
+		members: make(map[string]*memberEntry),
+		conns:   make(map[string]*grpc.ClientConn),
+	}
+	m.members[opts.Self.ID] = &memberEntry{
+		state:   MemberState{Node: opts.Self, Status: MemberAlive},
+		changed: time.Now(),
+	}
+	return m, nil
+}
+
+// Register 在gRPC服务上注册Gossip服务
+func (m *Membership) Register(s grpc.ServiceRegistrar) {
+	s.RegisterService(&gossipServiceDesc, m)
+}
+
+// Join 与种子节点交换成员表，至少一个成功时返回nil；没有种子节点时直接返回
+func (m *Membership) Join(ctx context.Context) error {
+	if len(m.opts.Seeds) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, addr := range m.opts.Seeds {
+		if addr == m.opts.Self.Addr {
+			continue
+		}
+		err := m.push(ctx, addr)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("failed to join %s: %w", addr, err))
+	}
+	return errors.Join(errs...)
+}
+
+// Run 定期与其他节点交换成员表直到ctx取消；取消时不会通知其他节点，正常下线应先调用Leave
+func (m *Membership) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.opts.GossipInterval)
+	defer ticker.Stop()
+	defer m.closeConns()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			m.round(ctx)
+		}
+	}
+}
+
+// Leave 把本节点标记为DEAD并通知其他节点，之后其他节点不再把键路由到本节点
+func (m *Membership) Leave(ctx context.Context) error {
+	m.mu.Lock()
+	self := m.members[m.opts.Self.ID]
+	self.state.Incarnation++
+	self.state.Status = MemberDead
+	self.changed = time.Now()
+	m.mu.Unlock()
+
+	peers := m.peers(0)
+	if len(peers) == 0 {
+		return nil
+	}
+	errs := make([]error, len(peers))
+	var wg sync.WaitGroup
+	for i, peer := range peers {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			errs[i] = m.push(ctx, addr)
+		}(i, peer.Addr)
+	}
+	wg.Wait()
+
+	// 只要有一个节点收到，离开的消息就会继续传播
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to notify any member: %w", errors.Join(errs...))
+}
+
+// Nodes 返回ALIVE和SUSPECT状态的节点，按ID排序
+// 被怀疑的节点在判定失效前仍然参与路由，避免短暂的网络抖动导致键迁移
+func (m *Membership) Nodes(context.Context) ([]Node, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var nodes []Node
+	for _, e := range m.members {
+		if e.state.Status != MemberDead {
+			nodes = append(nodes, e.state.Node)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes, nil
+}
+
+// Members 返回完整的成员表，包括失效的节点，按ID排序
+func (m *Membership) Members() []MemberState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.states()
+}
+
+// states 返回成员表的副本，调用方需持有mu
+func (m *Membership) states() []MemberState {
+	states := make([]MemberState, 0, len(m.members))
+	for _, e := range m.members {
+		states = append(states, e.state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Node.ID < states[j].Node.ID })
+	return states
+}
+
+// round 执行一轮Gossip：更新超时状态，随机选择节点交换成员表
+// 没有可用节点时联系种子节点，使重启或网络分区后的节点重新加入集群
+func (m *Membership) round(ctx context.Context) {
+	m.expire(time.Now())
+
+	ctx, cancel := context.WithTimeout(ctx, m.opts.GossipInterval)
+	defer cancel()
+
+	peers := m.peers(m.opts.Fanout)
+	if len(peers) == 0 {
+		if err := m.Join(ctx); err != nil && m.opts.OnError != nil {
+			m.opts.OnError(err)
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, peer := range peers {
+		wg.Add(1)
+		go func(peer Node) {
+			defer wg.Done()
+			if err := m.push(ctx, peer.Addr); err != nil {
+				m.suspect(peer.ID)
+				if m.opts.OnError != nil {
+					m.opts.OnError(fmt.Errorf("failed to gossip with %s: %w", peer.ID, err))
+				}
+			}
+		}(peer)
+	}
+	wg.Wait()
+}
+
+// peers 随机返回至多n个未失效的其他节点，n为0时返回全部
+func (m *Membership) peers(n int) []Node {
+	m.mu.Lock()
+	var peers []Node
+	for id, e := range m.members {
+		if id != m.opts.Self.ID && e.state.Status != MemberDead {
+			peers = append(peers, e.state.Node)
+		}
+	}
+	m.mu.Unlock()
+
+	rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	if n > 0 && len(peers) > n {
+		peers = peers[:n]
+	}
+	return peers
+}
+
+// push 把成员表发送给addr并合并对方返回的成员表
+func (m *Membership) push(ctx context.Context, addr string) error {
+	conn, err := m.conn(addr)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	req := &ExchangeRequest{From: m.opts.Self.ID, Members: m.states()}
+	m.mu.Unlock()
+
+	resp := new(ExchangeResponse)
+	if err := conn.Invoke(ctx, exchangeMethodName, req, resp, grpcwire.CallOption()); err != nil {
+		return err
+	}
+	m.merge(resp.Members)
+	return nil
+}
+
+// exchange 处理其他节点推送的成员表
+func (m *Membership) exchange(_ context.Context, req *ExchangeRequest) (*ExchangeResponse, error) {
+	m.merge(req.Members)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return &ExchangeResponse{Members: m.states()}, nil
+}
+
+// merge 合并收到的成员表：incarnation大的记录胜出，相同时 DEAD > SUSPECT > ALIVE
+// 关于本节点的记录不会被覆盖，本节点被怀疑或判定失效时增大incarnation反驳
+func (m *Membership) merge(states []MemberState) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range states {
+		if s.Node.ID == "" {
+			continue
+		}
+		e, ok := m.members[s.Node.ID]
+		if s.Node.ID == m.opts.Self.ID {
+			if e.state.Status == MemberAlive && (s.Incarnation > e.state.Incarnation ||
+				s.Incarnation == e.state.Incarnation && s.Status != MemberAlive) {
+				e.state.Incarnation = s.Incarnation + 1
+				e.changed = now
+			}
+			continue
+		}
+		if !ok {
+			m.members[s.Node.ID] = &memberEntry{state: s, changed: now}
+			continue
+		}
+		if s.Incarnation > e.state.Incarnation ||
+			s.Incarnation == e.state.Incarnation && s.Status > e.state.Status {
+			e.state = s
+			e.changed = now
+		}
+	}
+}
+
+// suspect 把交换失败的节点标记为SUSPECT
+func (m *Membership) suspect(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.members[id]; ok && e.state.Status == MemberAlive {
+		e.state.Status = MemberSuspect
+		e.changed = time.Now()
+	}
+}
+
+// expire 把超时的SUSPECT节点判定为DEAD，删除保留期已过的DEAD记录
+func (m *Membership) expire(now time.Time) {
+	m.mu.Lock()
+	var removed []string
+	for id, e := range m.members {
+		if id == m.opts.Self.ID {
+			continue
+		}
+		switch {
+		case e.state.Status == MemberSuspect && now.Sub(e.changed) >= m.opts.SuspectTimeout:
+			e.state.Status = MemberDead
+			e.changed = now
+		case e.state.Status == MemberDead && now.Sub(e.changed) >= m.opts.DeadRetention:
+			delete(m.members, id)
+			removed = append(removed, e.state.Node.Addr)
+		}
+	}
+	m.mu.Unlock()
+
+	m.connMu.Lock()
+	defer m.connMu.Unlock()
+	for _, addr := range removed {
+		if conn, ok := m.conns[addr]; ok {
+			conn.Close()
+			delete(m.conns, addr)
+		}
+	}
+}
+
+// conn 返回到addr的连接，连接在节点被删除或Run退出前复用
+func (m *Membership) conn(addr string) (*grpc.ClientConn, error) {
+	m.connMu.Lock()
+	defer m.connMu.Unlock()
+
+	if conn, ok := m.conns[addr]; ok {
+		return conn, nil
+	}
+	conn, err := m.opts.Dial(addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial %s: %w", addr, err)
+	}
+	m.conns[addr] = conn
+	return conn, nil
+}
+
+// closeConns 关闭所有连接
+func (m *Membership) closeConns() {
+	m.connMu.Lock()
+	defer m.connMu.Unlock()
+
+	for addr, conn := range m.conns {
+		conn.Close()
+		delete(m.conns, addr)
+	}
+}
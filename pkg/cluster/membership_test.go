@@ -0,0 +1,195 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testNetwork 按地址查找内存监听器的网络
+type testNetwork struct {
+	mu        sync.Mutex
+	listeners map[string]*bufconn.Listener
+}
+
+// serve 在addr上启动注册了成员服务的gRPC服务，返回停止函数
+func (n *testNetwork) serve(t *testing.T, addr string, m *Membership) (stop func()) {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	m.Register(server)
+	go server.Serve(lis)
+
+	n.mu.Lock()
+	n.listeners[addr] = lis
+	n.mu.Unlock()
+
+	var once sync.Once
+	stop = func() { once.Do(server.Stop) }
+	t.Cleanup(stop)
+	return stop
+}
+
+// dial 连接addr上的服务，地址不存在时在发起请求时失败
+func (n *testNetwork) dial(addr string) (*grpc.ClientConn, error) {
+	return grpc.Dial("passthrough:///"+addr,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			n.mu.Lock()
+			lis, ok := n.listeners[addr]
+			n.mu.Unlock()
+			if !ok {
+				return nil, fmt.Errorf("unknown address %s", addr)
+			}
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+}
+
+// eventually 等待条件成立
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// nodeIDs 返回Discovery当前的节点ID
+func nodeIDs(d Discovery) string {
+	nodes, _ := d.Nodes(context.Background())
+	ids := ""
+	for i, n := range nodes {
+		if i > 0 {
+			ids += ","
+		}
+		ids += n.ID
+	}
+	return ids
+}
+
+func TestMembership(t *testing.T) {
+	network := &testNetwork{listeners: make(map[string]*bufconn.Listener)}
+
+	members := make([]*Membership, 3)
+	stops := make([]func(), 3)
+	cancels := make([]context.CancelFunc, 3)
+	var wg sync.WaitGroup
+	for i := range members {
+		m, err := NewMembership(MembershipOptions{
+			Self:           Node{ID: fmt.Sprintf("node-%d", i), Addr: fmt.Sprintf("10.0.0.%d:7070", i)},
+			Seeds:          []string{"10.0.0.0:7070"},
+			Dial:           network.dial,
+			GossipInterval: 10 * time.Millisecond,
+			SuspectTimeout: 100 * time.Millisecond,
+			DeadRetention:  time.Minute,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create membership: %v", err)
+		}
+		members[i] = m
+		stops[i] = network.serve(t, m.opts.Self.Addr, m)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancels[i] = cancel
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.Run(ctx)
+		}()
+	}
+	defer func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+		wg.Wait()
+	}()
+
+	all := "node-0,node-1,node-2"
+	for i, m := range members {
+		eventually(t, fmt.Sprintf("node-%d to see all members", i), func() bool { return nodeIDs(m) == all })
+	}
+
+	t.Run("Leave", func(t *testing.T) {
+		if err := members[2].Leave(context.Background()); err != nil {
+			t.Fatalf("Failed to leave: %v", err)
+		}
+		cancels[2]()
+		stops[2]()
+		for i := 0; i < 2; i++ {
+			m := members[i]
+			eventually(t, fmt.Sprintf("node-%d to drop node-2", i), func() bool { return nodeIDs(m) == "node-0,node-1" })
+		}
+		states := members[0].Members()
+		if len(states) != 3 || states[2].Status != MemberDead {
+			t.Errorf("Expected node-2 to be kept as dead, got %+v", states)
+		}
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		stops[1]()
+		cancels[1]()
+		eventually(t, "node-0 to detect failure of node-1", func() bool { return nodeIDs(members[0]) == "node-0" })
+	})
+
+	t.Run("Refute", func(t *testing.T) {
+		// 以新的Run和服务重启node-1，它需要反驳自己的DEAD记录
+		restarted, err := NewMembership(MembershipOptions{
+			Self:           Node{ID: "node-1", Addr: "10.0.0.1:7070"},
+			Seeds:          []string{"10.0.0.0:7070"},
+			Dial:           network.dial,
+			GossipInterval: 10 * time.Millisecond,
+			SuspectTimeout: 100 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create membership: %v", err)
+		}
+		network.serve(t, "10.0.0.1:7070", restarted)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			restarted.Run(ctx)
+		}()
+		defer func() {
+			cancel()
+			<-done
+		}()
+
+		eventually(t, "node-0 to see node-1 again", func() bool { return nodeIDs(members[0]) == "node-0,node-1" })
+	})
+}
+
+func TestExchangeWire(t *testing.T) {
+	req := &ExchangeRequest{
+		From: "node-0",
+		Members: []MemberState{
+			{Node: Node{ID: "node-0", Addr: "a:1", Weight: 2}, Incarnation: 7, Status: MemberAlive},
+			{Node: Node{ID: "node-1", Addr: "b:1"}, Incarnation: 3, Status: MemberDead},
+		},
+	}
+	b, err := req.MarshalWire()
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	got := new(ExchangeRequest)
+	if err := got.UnmarshalWire(b); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	if got.From != req.From || len(got.Members) != 2 || got.Members[0] != req.Members[0] || got.Members[1] != req.Members[1] {
+		t.Errorf("Expected %+v, got %+v", req, got)
+	}
+}
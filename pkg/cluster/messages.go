@@ -0,0 +1,130 @@
+package cluster
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/seraphico/EdgeOrigin/internal/grpcwire"
+)
+
+// MemberStatus 成员状态，取值与gossip.proto一致
+type MemberStatus int32
+
+const (
+	MemberAlive   MemberStatus = 0 // 正常
+	MemberSuspect MemberStatus = 1 // 最近一次交换失败，超时后变为MemberDead
+	MemberDead    MemberStatus = 2 // 已离开或被判定失效
+)
+
+// String 返回状态名称
+func (s MemberStatus) String() string {
+	switch s {
+	case MemberAlive:
+		return "alive"
+	case MemberSuspect:
+		return "suspect"
+	case MemberDead:
+		return "dead"
+	}
+	return "unknown"
+}
+
+// MemberState 成员表中的一条记录
+type MemberState struct {
+	Node        Node         `json:"node"`
+	Incarnation uint64       `json:"incarnation"` // 只由节点本身增大，用于反驳过时的状态
+	Status      MemberStatus `json:"status"`
+}
+
+// ExchangeRequest 推送成员表的请求
+type ExchangeRequest struct {
+	From    string // 发送方节点ID
+	Members []MemberState
+}
+
+// MarshalWire 按protobuf线格式编码
+func (m *ExchangeRequest) MarshalWire() ([]byte, error) {
+	var b []byte
+	b = grpcwire.AppendString(b, 1, m.From)
+	b = appendMembers(b, 2, m.Members)
+	return b, nil
+}
+
+// UnmarshalWire 按protobuf线格式解码
+func (m *ExchangeRequest) UnmarshalWire(b []byte) error {
+	*m = ExchangeRequest{}
+	return grpcwire.Decode(b, func(f grpcwire.Field) error {
+		switch f.Num {
+		case 1:
+			m.From = f.String()
+		case 2:
+			state, err := decodeMember(f.Bytes)
+			if err != nil {
+				return err
+			}
+			m.Members = append(m.Members, state)
+		}
+		return nil
+	})
+}
+
+// ExchangeResponse 对方合并后的成员表
+type ExchangeResponse struct {
+	Members []MemberState
+}
+
+// MarshalWire 按protobuf线格式编码
+func (m *ExchangeResponse) MarshalWire() ([]byte, error) {
+	return appendMembers(nil, 1, m.Members), nil
+}
+
+// UnmarshalWire 按protobuf线格式解码
+func (m *ExchangeResponse) UnmarshalWire(b []byte) error {
+	*m = ExchangeResponse{}
+	return grpcwire.Decode(b, func(f grpcwire.Field) error {
+		if f.Num != 1 {
+			return nil
+		}
+		state, err := decodeMember(f.Bytes)
+		if err != nil {
+			return err
+		}
+		m.Members = append(m.Members, state)
+		return nil
+	})
+}
+
+// appendMembers 追加repeated MemberState字段
+func appendMembers(b []byte, num protowire.Number, members []MemberState) []byte {
+	for _, s := range members {
+		var member []byte
+		member = grpcwire.AppendString(member, 1, s.Node.ID)
+		member = grpcwire.AppendString(member, 2, s.Node.Addr)
+		member = grpcwire.AppendVarint(member, 3, uint64(s.Node.Weight))
+		member = grpcwire.AppendVarint(member, 4, s.Incarnation)
+		member = grpcwire.AppendVarint(member, 5, uint64(s.Status))
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendBytes(b, member)
+	}
+	return b
+}
+
+// decodeMember 解码一条MemberState
+func decodeMember(b []byte) (MemberState, error) {
+	var s MemberState
+	err := grpcwire.Decode(b, func(f grpcwire.Field) error {
+		switch f.Num {
+		case 1:
+			s.Node.ID = f.String()
+		case 2:
+			s.Node.Addr = f.String()
+		case 3:
+			s.Node.Weight = int(int32(f.Varint))
+		case 4:
+			s.Incarnation = f.Varint
+		case 5:
+			s.Status = MemberStatus(f.Varint)
+		}
+		return nil
+	})
+	return s, err
+}
@@ -0,0 +1,193 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// peerCache 协作缓存：本节点负责的键读写本地缓存，其他键转发给负责它的节点
+// 这样每个键在集群中只缓存一份，任何节点的未命中都会先查询负责节点，仍未命中才回源
+type peerCache struct {
+	local filecache.Cache
+	peers *Client
+	self  string
+
+	mu           sync.RWMutex // 保护命中统计
+	hits, misses int64
+
+	namespaces map[string]*peerCache
+	nsMu       sync.Mutex
+}
+
+// NewPeerCache 返回协作缓存，self为本节点在peers中的ID
+// 负责节点不可达时读操作回落到本地缓存，写操作返回错误
+// 节点对外提供的缓存服务应直接使用local，避免成员表不一致时请求在节点之间来回转发
+// 关闭时只关闭local，peers由调用方关闭
+func NewPeerCache(local filecache.Cache, peers *Client, self string) filecache.Cache {
+	return &peerCache{
+		local:      local,
+		peers:      peers,
+		self:       self,
+		namespaces: make(map[string]*peerCache),
+	}
+}
+
+// remote 返回键是否由其他节点负责
+func (c *peerCache) remote(key string) bool {
+	owner, ok := c.peers.Owner(key)
+	return ok && owner.ID != c.self
+}
+
+// fallback 返回转发失败后是否应该回落到本地缓存
+func fallback(err error) bool {
+	return err != nil && !errors.Is(err, filecache.ErrNotFound)
+}
+
+// Set 写入负责节点
+func (c *peerCache) Set(ctx context.Context, key string, data io.Reader, mimeType string, ttl time.Duration) error {
+	if c.remote(key) {
+		return c.peers.Set(ctx, key, data, mimeType, ttl)
+	}
+	return c.local.Set(ctx, key, data, mimeType, ttl)
+}
+
+// Get 从负责节点获取文件
+func (c *peerCache) Get(ctx context.Context, key string) (io.ReadCloser, *filecache.FileInfo, error) {
+	var (
+		reader io.ReadCloser
+		info   *filecache.FileInfo
+		err    error
+	)
+	if c.remote(key) {
+		reader, info, err = c.peers.Get(ctx, key)
+		if fallback(err) {
+			reader, info, err = c.local.Get(ctx, key)
+		}
+	} else {
+		reader, info, err = c.local.Get(ctx, key)
+	}
+
+	if err != nil {
+		c.recordMiss()
+		return nil, nil, err
+	}
+	c.recordHit()
+	return reader, info, nil
+}
+
+// Exists 检查文件是否存在
+func (c *peerCache) Exists(ctx context.Context, key string) (bool, error) {
+	if c.remote(key) {
+		exists, err := c.peers.Exists(ctx, key)
+		if !fallback(err) {
+			return exists, err
+		}
+	}
+	return c.local.Exists(ctx, key)
+}
+
+// Delete 删除负责节点上的文件，同时删除本地可能残留的副本
+func (c *peerCache) Delete(ctx context.Context, key string) error {
+	if c.remote(key) {
+		if err := c.peers.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return c.local.Delete(ctx, key)
+}
+
+// GetInfo 获取文件信息
+func (c *peerCache) GetInfo(ctx context.Context, key string) (*filecache.FileInfo, error) {
+	if c.remote(key) {
+		info, err := c.peers.GetInfo(ctx, key)
+		if !fallback(err) {
+			return info, err
+		}
+	}
+	return c.local.GetInfo(ctx, key)
+}
+
+// List 列出本地缓存中的文件
+func (c *peerCache) List(ctx context.Context) ([]*filecache.FileInfo, error) {
+	return c.local.List(ctx)
+}
+
+// Cleanup 清理本地缓存
+func (c *peerCache) Cleanup(ctx context.Context) error {
+	return c.local.Cleanup(ctx)
+}
+
+// Flush 清空本地缓存
+func (c *peerCache) Flush(ctx context.Context) error {
+	if err := c.local.Flush(ctx); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.hits, c.misses = 0, 0
+	c.mu.Unlock()
+	return nil
+}
+
+// Namespace 返回由本地和集群中同名命名空间组成的协作缓存
+func (c *peerCache) Namespace(name string) filecache.Cache {
+	if name == "" {
+		return c
+	}
+
+	c.nsMu.Lock()
+	defer c.nsMu.Unlock()
+
+	if ns, ok := c.namespaces[name]; ok {
+		return ns
+	}
+	ns := &peerCache{
+		local:      c.local.Namespace(name),
+		peers:      c.peers.Namespace(name).(*Client),
+		self:       c.self,
+		namespaces: make(map[string]*peerCache),
+	}
+	c.namespaces[name] = ns
+	return ns
+}
+
+// Close 关闭本地缓存
+func (c *peerCache) Close() error {
+	return c.local.Close()
+}
+
+// Stats 返回本地缓存的统计信息，命中率按协作缓存整体计算
+func (c *peerCache) Stats() (*filecache.Stats, error) {
+	stats, err := c.local.Stats()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	stats.HitRate, stats.MissRate = 0, 0
+	if total := float64(c.hits + c.misses); total > 0 {
+		stats.HitRate = float64(c.hits) / total
+		stats.MissRate = float64(c.misses) / total
+	}
+	return stats, nil
+}
+
+// recordHit 记录一次命中
+func (c *peerCache) recordHit() {
+	c.mu.Lock()
+	c.hits++
+	c.mu.Unlock()
+}
+
+// recordMiss 记录一次未命中
+func (c *peerCache) recordMiss() {
+	c.mu.Lock()
+	c.misses++
+	c.mu.Unlock()
+}
@@ -0,0 +1,74 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestPeerCache(t *testing.T) {
+	ctx := context.Background()
+
+	nodes := testNodes(3)
+	fleet := newTestFleet()
+	client, err := New(ctx, Options{Nodes: nodes, Dial: fleet.dial})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	peers := make([]filecache.Cache, len(nodes))
+	for i, node := range nodes {
+		peers[i] = NewPeerCache(fleet.caches[node.Addr], client, node.ID)
+	}
+
+	// 找一个不由node-0负责的键
+	key := ""
+	for i := 0; key == ""; i++ {
+		k := fmt.Sprintf("file-%d", i)
+		if owner, _ := client.Owner(k); owner.ID != nodes[0].ID {
+			key = k
+		}
+	}
+	owner, _ := client.Owner(key)
+
+	t.Run("SetForwardsToOwner", func(t *testing.T) {
+		if err := peers[0].Set(ctx, key, strings.NewReader("shared"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		if exists, _ := fleet.caches[nodes[0].Addr].Exists(ctx, key); exists {
+			t.Error("Expected file not to be stored on the non-owner")
+		}
+		if exists, _ := fleet.caches[owner.Addr].Exists(ctx, key); !exists {
+			t.Error("Expected file to be stored on the owner")
+		}
+	})
+
+	t.Run("GetForwardsToOwner", func(t *testing.T) {
+		for i, peer := range peers {
+			if got := readString(t, peer, key); got != "shared" {
+				t.Errorf("Expected node-%d to read 'shared', got %q", i, got)
+			}
+		}
+	})
+
+	t.Run("MissReturnsNotFound", func(t *testing.T) {
+		if _, _, err := peers[0].Get(ctx, "missing"); !errors.Is(err, filecache.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := peers[0].Delete(ctx, key); err != nil {
+			t.Fatalf("Failed to delete file: %v", err)
+		}
+		if exists, _ := fleet.caches[owner.Addr].Exists(ctx, key); exists {
+			t.Error("Expected file to be deleted on the owner")
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package cluster
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// HashMode 选择节点的哈希算法
+type HashMode int
+
+const (
+	// ConsistentHash 带虚拟节点的一致性哈希环，节点增减时只有相邻区间的键迁移
+	ConsistentHash HashMode = iota
+	// Rendezvous 最高随机权重哈希，不需要虚拟节点，分布更均匀但每次选择需要遍历所有节点
+	Rendezvous
+)
+
+// defaultVirtualNodes 每个权重为1的节点在哈希环上的虚拟节点数
+const defaultVirtualNodes = 160
+
+// picker 根据键选择节点
+type picker interface {
+	pick(key string) *member
+}
+
+// ringPoint 哈希环上的一个虚拟节点
+type ringPoint struct {
+	hash   uint64
+	member *member
+}
+
+// hashRing 一致性哈希环
+type hashRing struct {
+	points []ringPoint
+}
+
+// newHashRing 为每个节点放置 virtualNodes*Weight 个虚拟节点
+func newHashRing(members []*member, virtualNodes int) *hashRing {
+	r := &hashRing{}
+	for _, m := range members {
+		for i := 0; i < virtualNodes*m.node.weight(); i++ {
+			r.points = append(r.points, ringPoint{hash: hashString(m.node.ID + "#" + strconv.Itoa(i)), member: m})
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i].hash < r.points[j].hash })
+	return r
+}
+
+// pick 返回顺时针方向第一个虚拟节点所属的节点
+func (r *hashRing) pick(key string) *member {
+	if len(r.points) == 0 {
+		return nil
+	}
+	h := hashString(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].member
+}
+
+// rendezvous 加权的最高随机权重哈希
+type rendezvous struct {
+	members []*member
+}
+
+// pick 返回得分最高的节点，得分为 -weight/ln(h)，h为节点和键的哈希归一化到(0,1)
+func (r *rendezvous) pick(key string) *member {
+	var best *member
+	bestScore := math.Inf(-1)
+	for _, m := range r.members {
+		h := hashString(m.node.ID + "\x00" + key)
+		u := (float64(h>>11) + 0.5) / (1 << 53)
+		score := -float64(m.node.weight()) / math.Log(u)
+		if score > bestScore || (score == bestScore && best != nil && m.node.ID < best.node.ID) {
+			best, bestScore = m, score
+		}
+	}
+	return best
+}
+
+// hashString 64位FNV-1a哈希再经过MurmurHash3的fmix64混合，所有客户端的结果一致
+// 单纯的FNV-1a对只有末尾不同的短字符串（如虚拟节点名）分布较差
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,41 @@
+package cluster
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// 服务定义与gossip.proto保持一致
+const (
+	gossipServiceName  = "edgeorigin.cluster.v1.Gossip"
+	exchangeMethodName = "/" + gossipServiceName + "/Exchange"
+)
+
+// gossipServer Gossip服务的服务端接口
+type gossipServer interface {
+	exchange(ctx context.Context, req *ExchangeRequest) (*ExchangeResponse, error)
+}
+
+var gossipServiceDesc = grpc.ServiceDesc{
+	ServiceName: gossipServiceName,
+	HandlerType: (*gossipServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Exchange", Handler: exchangeHandler},
+	},
+	Metadata: "gossip.proto",
+}
+
+func exchangeHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(ExchangeRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(gossipServer).exchange(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: exchangeMethodName}
+	return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(gossipServer).exchange(ctx, req.(*ExchangeRequest))
+	})
+}
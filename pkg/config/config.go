@@ -0,0 +1,201 @@
+// Package config 定义EdgeOrigin的完整配置：缓存、站点（回源路由）、边缘监听、访问日志、管理接口和指标，
+// 可以从JSON、YAML或TOML文件加载
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/seraphico/EdgeOrigin/internal/configfile"
+	"github.com/seraphico/EdgeOrigin/internal/validate"
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+	"github.com/seraphico/EdgeOrigin/pkg/origin"
+	"github.com/seraphico/EdgeOrigin/pkg/server/accesslog"
+	"github.com/seraphico/EdgeOrigin/pkg/server/edge"
+	"github.com/seraphico/EdgeOrigin/pkg/server/tlsutil"
+)
+
+// Config 完整配置
+type Config struct {
+	// Cache 缓存配置，文件中没有设置的字段使用filecache.DefaultConfig的值
+	Cache filecache.Config `json:"cache"`
+
+	// Sites 站点及其回源选项，按Host路由，见origin.NewRouter
+	Sites []origin.Site `json:"sites,omitempty"`
+
+	// Server 边缘监听配置
+	Server *edge.Config `json:"server,omitempty"`
+
+	// AccessLog 访问日志配置，为空时不记录
+	AccessLog *AccessLogConfig `json:"access_log,omitempty"`
+
+	// Admin 管理接口配置，为空时不提供管理接口
+	Admin *AdminConfig `json:"admin,omitempty"`
+
+	// Metrics 指标配置，为空时不导出指标
+	Metrics *MetricsConfig `json:"metrics,omitempty"`
+}
+
+// AdminConfig 管理接口（pkg/server/admin）的监听配置，Addr和Socket至少设置一个，
+// Token和cache.api_keys至少设置一个
+type AdminConfig struct {
+	// Addr TCP监听地址，例如"127.0.0.1:9090"；管理接口可以读写全部缓存，不要暴露在公网上
+	Addr string `json:"addr,omitempty"`
+
+	// Path TCP监听上管理接口的挂载路径，默认"/admin"
+	Path string `json:"path,omitempty"`
+
+	// Socket Unix套接字路径，权限为0660，管理接口挂载在根路径，见admin.ListenUnix
+	Socket string `json:"socket,omitempty"`
+
+	// Token 访问令牌，持有者拥有全部权限；按角色授权使用cache.api_keys
+	Token string `json:"token,omitempty"`
+
+	// TLS Addr上的监听使用TLS，设置client_ca_file时还要求客户端证书（双向TLS）；Unix套接字不使用TLS
+	TLS *tlsutil.Config `json:"tls,omitempty"`
+}
+
+// MetricsConfig Prometheus指标的监听配置，见pkg/server/metrics
+type MetricsConfig struct {
+	// Addr 监听地址，例如":9100"
+	Addr string `json:"addr"`
+
+	// Path 指标的路径，默认"/metrics"
+	Path string `json:"path,omitempty"`
+
+	// TLS 监听使用TLS，设置client_ca_file时只有持有有效客户端证书的采集端才能读取指标
+	TLS *tlsutil.Config `json:"tls,omitempty"`
+}
+
+// AccessLogConfig 访问日志配置
+type AccessLogConfig struct {
+	// Path 日志文件路径，为空时写入标准输出
+	Path string `json:"path,omitempty"`
+
+	// Format 日志格式，默认为combined
+	Format accesslog.Format `json:"format,omitempty"`
+
+	// Rotation 日志文件的轮转策略
+	Rotation accesslog.Rotation `json:"rotation,omitempty"`
+
+	// SampleRate 随机记录的请求比例，0表示全部记录
+	SampleRate float64 `json:"sample_rate,omitempty"`
+
+	// KeepErrors 抽样时仍然记录所有5xx响应
+	KeepErrors bool `json:"keep_errors,omitempty"`
+}
+
+// Load 从文件加载配置，按扩展名支持.json、.yaml、.yml和.toml；
+// 三种格式的字段名相同，时长可以写成纳秒数或"1h30m"这样的字符串；
+// 缓存配置之后再叠加EDGEORIGIN_*环境变量，见filecache.ApplyEnv
+func Load(filename string) (*Config, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	config := &Config{Cache: *filecache.DefaultConfig()}
+	if err := configfile.Decode(filename, data, config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+	if err := filecache.ApplyEnv(&config.Cache); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Validate 检查配置：缓存、站点的路由和回源选项、边缘监听、管理接口和指标（包括TLS证书和密钥能否加载）以及访问日志，
+// 不打开缓存、不连接上游也不监听端口。返回的错误列出所有问题，每行一个，以字段路径开头，
+// 例如"sites[0].options.upstream: invalid upstream"
+func (c *Config) Validate() error {
+	var errs validate.Errors
+	errs.Add("cache", filecache.ValidateConfig(&c.Cache))
+	errs.Add("sites", origin.ValidateSites(c.Sites))
+	if c.Server != nil {
+		errs.Add("server", c.Server.Validate())
+	}
+	if c.AccessLog != nil {
+		if c.AccessLog.SampleRate < 0 || c.AccessLog.SampleRate > 1 {
+			errs.Addf("access_log.sample_rate", "must be between 0 and 1")
+		}
+		switch c.AccessLog.Format {
+		case "", accesslog.FormatCombined, accesslog.FormatJSON:
+		default:
+			errs.Addf("access_log.format", "unknown access log format %q", c.AccessLog.Format)
+		}
+		errs.Add("access_log.rotation", c.AccessLog.Rotation.Validate())
+	}
+	if a := c.Admin; a != nil {
+		if a.Addr == "" && a.Socket == "" {
+			errs.Addf("admin", "addr or socket is required")
+		}
+		if a.Addr != "" {
+			if _, _, err := net.SplitHostPort(a.Addr); err != nil {
+				errs.Add("admin.addr", err)
+			}
+		}
+		if a.Path != "" && !strings.HasPrefix(a.Path, "/") {
+			errs.Addf("admin.path", "must start with /")
+		}
+		if a.Token == "" && len(c.Cache.APIKeys) == 0 {
+			errs.Addf("admin.token", "a token or cache.api_keys is required")
+		}
+		if a.TLS != nil {
+			if a.Addr == "" {
+				errs.Addf("admin.tls", "requires addr")
+			}
+			if _, err := a.TLS.ServerConfig(); err != nil {
+				errs.Add("admin.tls", err)
+			}
+		}
+	}
+	if m := c.Metrics; m != nil {
+		if _, _, err := net.SplitHostPort(m.Addr); err != nil {
+			errs.Add("metrics.addr", err)
+		}
+		if m.Path != "" && !strings.HasPrefix(m.Path, "/") {
+			errs.Addf("metrics.path", "must start with /")
+		}
+		if m.TLS != nil {
+			if _, err := m.TLS.ServerConfig(); err != nil {
+				errs.Add("metrics.tls", err)
+			}
+		}
+	}
+	return errs.Err()
+}
+
+// ValidateFile 加载并检查配置文件，不打开Badger、不创建回源代理也不监听端口，
+// 供CI和命令行在部署前检查配置；返回的错误与Load相同
+func ValidateFile(filename string) error {
+	_, err := Load(filename)
+	return err
+}
+
+// Options 按配置创建访问日志选项；写入文件时同时返回FileSink，调用方负责在退出时关闭
+func (c *AccessLogConfig) Options() (accesslog.Options, *accesslog.FileSink, error) {
+	opts := accesslog.Options{SampleRate: c.SampleRate, KeepErrors: c.KeepErrors}
+	format := c.Format
+	if format == "" {
+		format = accesslog.FormatCombined
+	}
+	if c.Path == "" {
+		sink, err := accesslog.NewWriterSink(os.Stdout, format)
+		if err != nil {
+			return opts, nil, err
+		}
+		opts.Sinks = []accesslog.Sink{sink}
+		return opts, nil, nil
+	}
+	sink, err := accesslog.NewFileSink(c.Path, format, c.Rotation)
+	if err != nil {
+		return opts, nil, err
+	}
+	opts.Sinks = []accesslog.Sink{sink}
+	return opts, sink, nil
+}
@@ -0,0 +1,283 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/server/accesslog"
+)
+
+// writeFile 在临时目录写入配置文件并返回路径
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// writeCert 在临时目录写入自签名证书和私钥，返回两个文件的路径
+func writeCert(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "edge"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	certFile := writeFile(t, "edge.crt", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	keyFile := writeFile(t, "edge.key", string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})))
+	return certFile, keyFile
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("yaml", func(t *testing.T) {
+		certFile, keyFile := writeCert(t)
+		path := writeFile(t, "edgeorigin.yaml", `
+cache:
+  data_dir: /var/cache/edge
+  max_cache_size: 10737418240
+  default_ttl: 1h
+sites:
+  - hosts: [static.example.com, "*.cdn.example.com"]
+    options:
+      upstreams:
+        - url: http://origin-a
+          priority: 1
+        - url: http://origin-b
+          priority: 2
+      health_check:
+        path: /healthz
+        interval: 10s
+      default_ttl: 10m
+      negative_ttl:
+        404: 30s
+server:
+  addr: ":443"
+  read_header_timeout: 5s
+  tls:
+    cert_file: `+certFile+`
+    key_file: `+keyFile+`
+  http3:
+    alt_svc_max_age: 24h
+  concurrency:
+    per_client: 8
+access_log:
+  path: /var/log/edge/access.log
+  format: json
+  rotation:
+    interval: 24h
+    max_backups: 7
+admin:
+  addr: 127.0.0.1:9090
+  token: secret
+metrics:
+  addr: ":9100"
+`)
+		c, err := Load(path)
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if c.Cache.DataDir != "/var/cache/edge" || c.Cache.MaxCacheSize != 10<<30 || c.Cache.DefaultTTL != time.Hour {
+			t.Errorf("Unexpected cache config: %+v", c.Cache)
+		}
+		if c.Cache.CleanupInterval != time.Hour || !c.Cache.Compression {
+			t.Errorf("Expected unset cache fields to keep defaults, got %+v", c.Cache)
+		}
+		if len(c.Sites) != 1 || len(c.Sites[0].Hosts) != 2 {
+			t.Fatalf("Unexpected sites: %+v", c.Sites)
+		}
+		opts := c.Sites[0].Options
+		if len(opts.Upstreams) != 2 || opts.Upstreams[1].URL != "http://origin-b" || opts.Upstreams[1].Priority != 2 {
+			t.Errorf("Unexpected upstreams: %+v", opts.Upstreams)
+		}
+		if opts.HealthCheck == nil || opts.HealthCheck.Interval != 10*time.Second {
+			t.Errorf("Unexpected health check: %+v", opts.HealthCheck)
+		}
+		if opts.DefaultTTL != 10*time.Minute || opts.NegativeTTL[404] != 30*time.Second {
+			t.Errorf("Unexpected TTLs: %v %v", opts.DefaultTTL, opts.NegativeTTL)
+		}
+		if c.Server == nil || c.Server.Addr != ":443" || c.Server.ReadHeaderTimeout != 5*time.Second {
+			t.Fatalf("Unexpected server config: %+v", c.Server)
+		}
+		if c.Server.HTTP3 == nil || c.Server.HTTP3.AltSvcMaxAge != 24*time.Hour {
+			t.Errorf("Unexpected HTTP/3 config: %+v", c.Server.HTTP3)
+		}
+		if c.Server.Concurrency == nil || c.Server.Concurrency.PerClient != 8 {
+			t.Errorf("Unexpected concurrency limit: %+v", c.Server.Concurrency)
+		}
+		if c.AccessLog == nil || c.AccessLog.Format != accesslog.FormatJSON || c.AccessLog.Rotation.Interval != 24*time.Hour {
+			t.Errorf("Unexpected access log config: %+v", c.AccessLog)
+		}
+		if c.Admin == nil || c.Admin.Addr != "127.0.0.1:9090" || c.Admin.Token != "secret" {
+			t.Errorf("Unexpected admin config: %+v", c.Admin)
+		}
+		if c.Metrics == nil || c.Metrics.Addr != ":9100" {
+			t.Errorf("Unexpected metrics config: %+v", c.Metrics)
+		}
+	})
+
+	t.Run("toml", func(t *testing.T) {
+		path := writeFile(t, "edgeorigin.toml", `
+[cache]
+data_dir = "/var/cache/edge"
+default_ttl = "2h"
+
+[[sites]]
+hosts = ["static.example.com"]
+
+[sites.options]
+upstream = "http://origin"
+stale_while_revalidate = "1m"
+
+[[sites]]
+hosts = ["*"]
+
+[sites.options]
+upstream = "http://fallback"
+
+[server]
+addr = ":8080"
+`)
+		c, err := Load(path)
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if c.Cache.DefaultTTL != 2*time.Hour {
+			t.Errorf("Expected default TTL of 2h, got %v", c.Cache.DefaultTTL)
+		}
+		if len(c.Sites) != 2 || c.Sites[1].Options.Upstream != "http://fallback" {
+			t.Fatalf("Unexpected sites: %+v", c.Sites)
+		}
+		if c.Sites[0].Options.StaleWhileRevalidate != time.Minute {
+			t.Errorf("Expected stale-while-revalidate of 1m, got %v", c.Sites[0].Options.StaleWhileRevalidate)
+		}
+		if c.Server == nil || c.Server.Addr != ":8080" {
+			t.Errorf("Unexpected server config: %+v", c.Server)
+		}
+	})
+
+	t.Run("env", func(t *testing.T) {
+		path := writeFile(t, "edgeorigin.yaml", "cache:\n  data_dir: /from/file\n  default_ttl: 1h\n")
+		t.Setenv("EDGEORIGIN_DATA_DIR", "/from/env")
+		t.Setenv("EDGEORIGIN_MAX_CACHE_SIZE", "20G")
+
+		c, err := Load(path)
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if c.Cache.DataDir != "/from/env" || c.Cache.MaxCacheSize != 20<<30 || c.Cache.DefaultTTL != time.Hour {
+			t.Errorf("Expected env to override the file, got %+v", c.Cache)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := Load(writeFile(t, "edgeorigin.yaml", "cache:\n  max_cache_size: -1\n")); err == nil {
+			t.Error("Expected error for negative cache size")
+		}
+		if _, err := Load(writeFile(t, "edgeorigin.yaml", "sites:\n  - options:\n      upstream: http://origin\n")); err == nil {
+			t.Error("Expected error for site without hosts")
+		}
+		if _, err := Load(writeFile(t, "edgeorigin.yaml", "access_log:\n  format: xml\n")); err == nil {
+			t.Error("Expected error for unknown log format")
+		}
+	})
+
+	t.Run("all errors", func(t *testing.T) {
+		path := writeFile(t, "edgeorigin.yaml", `
+cache:
+  default_ttl: -1h
+sites:
+  - hosts: [static.example.com]
+    options:
+      upstream: ftp://origin
+  - hosts: [static.example.com]
+    options:
+      upstream: http://origin
+      negative_ttl:
+        200: 30s
+server:
+  addr: ":443"
+  http3: {}
+access_log:
+  sample_rate: 2
+admin:
+  path: admin
+  tls: {}
+metrics:
+  addr: "9100"
+  tls:
+    cert_file: missing.crt
+    key_file: missing.key
+`)
+		err := ValidateFile(path)
+		if err == nil {
+			t.Fatal("Expected error for invalid config")
+		}
+		for _, want := range []string{
+			"cache.default_ttl: ",
+			"sites[0].options.upstream: ",
+			"sites[1].hosts[0]: ",
+			"sites[1].options.negative_ttl[200]: ",
+			"server.http3: ",
+			"access_log.sample_rate: ",
+			"admin: ",
+			"admin.path: ",
+			"admin.token: ",
+			"admin.tls: requires addr",
+			"admin.tls: tls server requires cert_file and key_file",
+			"metrics.addr: ",
+			"metrics.tls: ",
+		} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("Expected error for %q, got:\n%v", want, err)
+			}
+		}
+	})
+
+	t.Run("validate only", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "cache")
+		path := writeFile(t, "edgeorigin.yaml", "cache:\n  data_dir: "+dir+"\nsites:\n  - hosts: [example.com]\n    options:\n      upstream: http://origin\n")
+		if err := ValidateFile(path); err != nil {
+			t.Fatalf("Failed to validate config: %v", err)
+		}
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("Expected ValidateFile not to create the data dir, got %v", err)
+		}
+	})
+}
+
+func TestAccessLogOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	opts, file, err := (&AccessLogConfig{Path: path, SampleRate: 0.5}).Options()
+	if err != nil {
+		t.Fatalf("Failed to create options: %v", err)
+	}
+	defer file.Close()
+	if file == nil || len(opts.Sinks) != 1 || opts.SampleRate != 0.5 {
+		t.Errorf("Expected a file sink and sample rate, got %+v", opts)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected log file to be created: %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+	"github.com/seraphico/EdgeOrigin/pkg/origin"
+	"github.com/seraphico/EdgeOrigin/pkg/server/edge"
+)
+
+// defaultWatchInterval Watch检查配置文件的默认间隔
+const defaultWatchInterval = 2 * time.Second
+
+// ReloadOptions 重新加载配置时更新的组件，为空的组件被跳过
+type ReloadOptions struct {
+	// Cache 更新TTL、配额等可以在运行中修改的缓存配置，缓存需实现filecache.Reconfigurer
+	Cache filecache.Cache
+
+	// Router 站点（上游、TTL策略等回源选项）变化时整体替换
+	Router *origin.Router
+
+	// Server 更新访问日志的抽样比例
+	Server *edge.Server
+
+	// OnReload 每次重新加载后调用，err为空表示新的配置已全部应用；
+	// 加载失败时cfg为空，仍在使用原来的配置
+	OnReload func(cfg *Config, err error)
+}
+
+// Reloader 在运行中重新加载配置文件，应用缓存的TTL和配额、站点和访问日志抽样的修改，
+// 不需要重启进程，内存中的缓存层也不会丢失。监听地址、TLS、数据目录、缓存容量等只在启动时使用，修改后需要重启
+type Reloader struct {
+	path string
+	opts ReloadOptions
+
+	mu      sync.Mutex // 串行化Reload
+	current *Config
+}
+
+// NewReloader 返回重新加载path的Reloader，current为启动时加载的配置
+func NewReloader(path string, current *Config, opts ReloadOptions) (*Reloader, error) {
+	if current == nil {
+		return nil, fmt.Errorf("current config cannot be nil")
+	}
+	if opts.Cache != nil {
+		if _, ok := opts.Cache.(filecache.Reconfigurer); !ok {
+			return nil, fmt.Errorf("cache does not support reconfiguration")
+		}
+	}
+	return &Reloader{path: path, opts: opts, current: current}, nil
+}
+
+// Config 返回当前生效的配置
+func (r *Reloader) Config() *Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Reload 重新加载配置文件并应用修改。文件无法解析或校验失败时不做任何修改；
+// 部分组件应用失败时其余组件仍然更新，返回所有错误
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := Load(r.path)
+	if err != nil {
+		r.notify(nil, err)
+		return err
+	}
+
+	var errs []error
+	if r.opts.Cache != nil {
+		if err := r.opts.Cache.(filecache.Reconfigurer).Reconfigure(&cfg.Cache); err != nil {
+			errs = append(errs, fmt.Errorf("failed to reconfigure cache: %w", err))
+		}
+	}
+	if r.opts.Router != nil && !reflect.DeepEqual(cfg.Sites, r.current.Sites) {
+		if err := r.opts.Router.Update(cfg.Sites); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update sites: %w", err))
+		}
+	}
+	if r.opts.Server != nil && cfg.AccessLog != nil {
+		if err := r.opts.Server.SetAccessLogSampling(cfg.AccessLog.SampleRate, cfg.AccessLog.KeepErrors); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update access log: %w", err))
+		}
+	}
+	r.current = cfg
+	err = errors.Join(errs...)
+	r.notify(cfg, err)
+	return err
+}
+
+// notify 调用OnReload
+func (r *Reloader) notify(cfg *Config, err error) {
+	if r.opts.OnReload != nil {
+		r.opts.OnReload(cfg, err)
+	}
+}
+
+// Watch 每隔interval（默认2秒）检查配置文件的修改时间和大小，变化后连续两次检查都相同（文件已写完）时重新加载，
+// 直到ctx结束；加载结果通过OnReload报告
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	loaded, _ := os.Stat(r.path)
+	var pending os.FileInfo
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(r.path)
+		if err != nil {
+			// 编辑器保存时文件可能短暂不存在，下次再检查
+			pending = nil
+			continue
+		}
+		if sameFile(info, loaded) {
+			pending = nil
+			continue
+		}
+		if !sameFile(info, pending) {
+			// 文件可能还在写入
+			pending = info
+			continue
+		}
+		loaded, pending = info, nil
+		r.Reload()
+	}
+}
+
+// sameFile 判断两次Stat的修改时间和大小是否相同
+func sameFile(a, b os.FileInfo) bool {
+	return a != nil && b != nil && a.ModTime().Equal(b.ModTime()) && a.Size() == b.Size()
+}
+
+// HandleSignals 每次收到SIGHUP时重新加载，直到ctx结束；加载结果通过OnReload报告
+func (r *Reloader) HandleSignals(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			r.Reload()
+		}
+	}
+}
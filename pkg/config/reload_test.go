@@ -0,0 +1,137 @@
+package config
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+	"github.com/seraphico/EdgeOrigin/pkg/origin"
+)
+
+func TestReloader(t *testing.T) {
+	upstreamA := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "a")
+	}))
+	defer upstreamA.Close()
+	upstreamB := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "b")
+	}))
+	defer upstreamB.Close()
+
+	dir := t.TempDir()
+	configFor := func(upstream string, ttl string) string {
+		return "cache:\n  data_dir: " + dir + "\n  default_ttl: " + ttl + "\n" +
+			"sites:\n  - hosts: [a.example.com]\n    options:\n      upstream: " + upstream + "\n"
+	}
+	path := writeFile(t, "edgeorigin.yaml", configFor(upstreamA.URL, "1h"))
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	cache, err := filecache.NewBadgerCache(&cfg.Cache)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+	router, err := origin.NewRouter(cache, cfg.Sites)
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+	defer router.Close()
+
+	reloaded := make(chan error, 10)
+	reloader, err := NewReloader(path, cfg, ReloadOptions{
+		Cache:    cache,
+		Router:   router,
+		OnReload: func(cfg *Config, err error) { reloaded <- err },
+	})
+	if err != nil {
+		t.Fatalf("Failed to create reloader: %v", err)
+	}
+	fetch := func(target string) string {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Host = "a.example.com"
+		router.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+	if body := fetch("/before"); body != "a" {
+		t.Fatalf("Expected upstream a, got %q", body)
+	}
+
+	t.Run("reload", func(t *testing.T) {
+		if err := os.WriteFile(path, []byte(configFor(upstreamB.URL, "10m")), 0644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		if err := reloader.Reload(); err != nil {
+			t.Fatalf("Failed to reload: %v", err)
+		}
+		if body := fetch("/after"); body != "b" {
+			t.Errorf("Expected new upstream to be used, got %q", body)
+		}
+		if body := fetch("/before"); body != "a" {
+			t.Errorf("Expected cached entry to survive the reload, got %q", body)
+		}
+		start := time.Now()
+		if err := cache.Set(context.Background(), "ttl", strings.NewReader("x"), "text/plain", 0); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		if _, info, err := cache.Get(context.Background(), "ttl"); err != nil || info.ExpiresAt.Sub(start) > 11*time.Minute {
+			t.Errorf("Expected new default TTL to apply, got %v", info)
+		}
+		if reloader.Config().Cache.DefaultTTL != 10*time.Minute {
+			t.Errorf("Expected current config to be replaced")
+		}
+		<-reloaded
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if err := os.WriteFile(path, []byte("cache:\n  default_ttl: soon\n"), 0644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		if err := reloader.Reload(); err == nil {
+			t.Fatal("Expected invalid config to be rejected")
+		}
+		if err := <-reloaded; err == nil {
+			t.Error("Expected OnReload to receive the error")
+		}
+		if body := fetch("/invalid"); body != "b" {
+			t.Errorf("Expected previous sites to stay in place, got %q", body)
+		}
+	})
+
+	t.Run("watch", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			reloader.Watch(ctx, 10*time.Millisecond)
+			close(done)
+		}()
+		defer func() {
+			cancel()
+			<-done
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+		if err := os.WriteFile(path, []byte(configFor(upstreamA.URL, "1h")+"\n"), 0644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		select {
+		case err := <-reloaded:
+			if err != nil {
+				t.Fatalf("Failed to reload: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected the change to be picked up")
+		}
+		if body := fetch("/watched"); body != "a" {
+			t.Errorf("Expected watched change to switch back to upstream a, got %q", body)
+		}
+	})
+}
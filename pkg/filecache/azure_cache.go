@@ -0,0 +1,376 @@
+package filecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/internal/azblob"
+)
+
+const (
+	// Azure blob元数据名称必须是合法的C#标识符，不能包含连字符
+	azureMetaCreatedAt = "createdat"
+	azureMetaExpiresAt = "expiresat"
+
+	// azureExpiresTag 保存过期时间的blob索引标签，Cleanup据此按标签查找过期blob
+	azureExpiresTag = "expires-at"
+
+	// azureTimeLayout 定长的UTC时间格式，使标签值的字典序与时间顺序一致
+	azureTimeLayout = "2006-01-02T15:04:05.000000000Z"
+)
+
+// AzureConfig Azure Blob缓存配置
+type AzureConfig struct {
+	Account      string        `json:"account"`        // 存储账户
+	AccountKey   string        `json:"account_key"`    // base64编码的账户密钥，为空时使用SASToken
+	SASToken     string        `json:"sas_token"`      // SAS令牌，需要包含读写删除、列举和按标签查找权限
+	Endpoint     string        `json:"endpoint"`       // 服务地址，默认 https://<account>.blob.core.windows.net
+	Container    string        `json:"container"`      // 容器
+	Prefix       string        `json:"prefix"`         // blob名称前缀，多个缓存共用容器时使用
+	DefaultTTL   time.Duration `json:"default_ttl"`    // 默认TTL
+	MaxEntrySize int64         `json:"max_entry_size"` // 单个文件最大大小（字节），0表示不限制
+	AccessTier   string        `json:"access_tier"`    // 写入时的访问层（Hot、Cool、Cold），为空时使用账户默认值
+
+	// TierHint 按键和大小返回写入时的访问层，返回空字符串时使用AccessTier
+	TierHint func(key string, size int64) string `json:"-"`
+
+	HTTPClient *http.Client `json:"-"` // 自定义HTTP客户端
+}
+
+// azureCache Azure Blob Storage文件缓存实现
+// 文件数据存放在 <Prefix>data/<key>，FileInfo存放在blob元数据中，
+// 过期时间同时写入blob索引标签，Cleanup通过按标签查找定位过期blob而无需列举整个容器
+type azureCache struct {
+	client *azblob.Client
+	cfg    AzureConfig
+	prefix string // 当前命名空间的blob名称前缀
+
+	stats objectStats
+
+	namespaces map[string]*azureCache
+	nsMu       sync.Mutex
+}
+
+// NewAzureCache 创建基于Azure Blob Storage的文件缓存
+func NewAzureCache(cfg AzureConfig) (Cache, error) {
+	if err := validateAccessTier(cfg.AccessTier); err != nil {
+		return nil, err
+	}
+
+	client, err := azblob.New(azblob.Config{
+		Account:    cfg.Account,
+		AccountKey: cfg.AccountKey,
+		SASToken:   cfg.SASToken,
+		Endpoint:   cfg.Endpoint,
+		Container:  cfg.Container,
+		HTTPClient: cfg.HTTPClient,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cfg.DefaultTTL <= 0 {
+		cfg.DefaultTTL = DefaultConfig().DefaultTTL
+	}
+
+	return &azureCache{
+		client:     client,
+		cfg:        cfg,
+		prefix:     cfg.Prefix,
+		namespaces: make(map[string]*azureCache),
+	}, nil
+}
+
+// validateAccessTier 检查访问层，Archive层的blob需要解冻后才能读取，不适合作为缓存
+func validateAccessTier(tier string) error {
+	switch tier {
+	case "", "Hot", "Cool", "Cold":
+		return nil
+	}
+	return fmt.Errorf("unsupported access tier %q", tier)
+}
+
+// blobName 返回缓存键对应的blob名称
+func (c *azureCache) blobName(key string) string {
+	return c.dataPrefix() + key
+}
+
+// dataPrefix 返回当前命名空间文件数据的blob名称前缀
+func (c *azureCache) dataPrefix() string {
+	return c.prefix + "data/"
+}
+
+// Set 存储文件到缓存
+func (c *azureCache) Set(ctx context.Context, key string, data io.Reader, mimeType string, ttl time.Duration) error {
+	if ttl <= 0 && ttl != NoExpiry {
+		ttl = c.cfg.DefaultTTL
+	}
+
+	body, size, err := spool(data, c.cfg.MaxEntrySize)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	now := time.Now().UTC()
+	expiresAt := expiryFor(now, ttl)
+	header := http.Header{}
+	if mimeType != "" {
+		header.Set("Content-Type", mimeType)
+	}
+	header.Set("X-Ms-Meta-"+azureMetaCreatedAt, now.Format(azureTimeLayout))
+	header.Set("X-Ms-Meta-"+azureMetaExpiresAt, expiresAt.Format(azureTimeLayout))
+	header.Set("X-Ms-Tags", url.Values{azureExpiresTag: {expiresAt.Format(azureTimeLayout)}}.Encode())
+
+	tier := c.cfg.AccessTier
+	if c.cfg.TierHint != nil {
+		if hint := c.cfg.TierHint(key, size); hint != "" {
+			tier = hint
+		}
+	}
+	if err := validateAccessTier(tier); err != nil {
+		return err
+	}
+	if tier != "" {
+		header.Set("X-Ms-Access-Tier", tier)
+	}
+
+	// 覆盖写入时统计信息只加上大小之差；获取失败时按新文件计算，下次List时按实际blob刷新
+	old, _ := c.GetInfo(ctx, key)
+	if err := c.client.PutBlob(ctx, c.blobName(key), body, size, header); err != nil {
+		return fmt.Errorf("failed to store file: %w", err)
+	}
+
+	c.stats.added(size, old)
+	return nil
+}
+
+// Get 从缓存获取文件
+func (c *azureCache) Get(ctx context.Context, key string) (io.ReadCloser, *FileInfo, error) {
+	resp, err := c.client.GetBlob(ctx, c.blobName(key), nil)
+	if errors.Is(err, azblob.ErrNotFound) {
+		c.stats.miss()
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info := azureFileInfo(key, resp.Header)
+	if time.Now().After(info.ExpiresAt) {
+		resp.Body.Close()
+		return nil, nil, ErrExpired
+	}
+
+	c.stats.hit()
+	return resp.Body, info, nil
+}
+
+// Exists 检查文件是否存在
+func (c *azureCache) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := c.client.GetProperties(ctx, c.blobName(key))
+	if errors.Is(err, azblob.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete 删除文件，先获取blob大小用于更新统计信息
+func (c *azureCache) Delete(ctx context.Context, key string) error {
+	old, _ := c.GetInfo(ctx, key)
+	err := c.client.DeleteBlob(ctx, c.blobName(key), nil)
+	if errors.Is(err, azblob.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	if old != nil {
+		c.stats.removed(old)
+	}
+	return nil
+}
+
+// List 列出当前命名空间的所有缓存文件
+func (c *azureCache) List(ctx context.Context) ([]*FileInfo, error) {
+	blobs, err := c.listBlobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*FileInfo, len(blobs))
+	for i, blob := range blobs {
+		info := &FileInfo{
+			Key:       blob.Name[len(c.dataPrefix()):],
+			Size:      blob.Size,
+			MimeType:  blob.MimeType,
+			CreatedAt: blob.CreatedAt,
+			ExpiresAt: azureExpiresAt(blob.Metadata[azureMetaExpiresAt]),
+		}
+		if t, err := time.Parse(azureTimeLayout, blob.Metadata[azureMetaCreatedAt]); err == nil {
+			info.CreatedAt = t
+		}
+		info.LastAccess = info.CreatedAt
+		files[i] = info
+	}
+
+	c.stats.refresh(files)
+	return files, nil
+}
+
+// GetInfo 获取文件信息
+func (c *azureCache) GetInfo(ctx context.Context, key string) (*FileInfo, error) {
+	header, err := c.client.GetProperties(ctx, c.blobName(key))
+	if errors.Is(err, azblob.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return azureFileInfo(key, header), nil
+}
+
+// Cleanup 清理当前命名空间的过期文件
+// 按索引标签查找过期blob，删除时带上相同的x-ms-if-tags条件，避免误删清理期间被重新写入的blob
+// 索引标签的更新是最终一致的，刚过期的blob可能在下一次清理时才被删除
+func (c *azureCache) Cleanup(ctx context.Context) error {
+	now := time.Now()
+	where := fmt.Sprintf("%q < '%s'", azureExpiresTag, now.UTC().Format(azureTimeLayout))
+	condition := http.Header{"X-Ms-If-Tags": {where}}
+
+	var removed int64
+	marker := ""
+	for {
+		result, err := c.client.FindBlobsByTags(ctx, where, marker)
+		if err != nil {
+			return fmt.Errorf("failed to find expired files: %w", err)
+		}
+
+		for _, blob := range result.Blobs {
+			if !strings.HasPrefix(blob.Name, c.dataPrefix()) {
+				// 其他命名空间或其他缓存的blob
+				continue
+			}
+			err := c.client.DeleteBlob(ctx, blob.Name, condition)
+			if errors.Is(err, azblob.ErrNotFound) || errors.Is(err, azblob.ErrConditionNotMet) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to delete expired file: %w", err)
+			}
+			removed++
+		}
+
+		if result.NextMarker == "" {
+			break
+		}
+		marker = result.NextMarker
+	}
+
+	c.stats.cleaned(removed, now)
+	return nil
+}
+
+// Flush 删除当前命名空间的所有文件并重置统计信息
+func (c *azureCache) Flush(ctx context.Context) error {
+	blobs, err := c.listBlobs(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, blob := range blobs {
+		err := c.client.DeleteBlob(ctx, blob.Name, nil)
+		if err != nil && !errors.Is(err, azblob.ErrNotFound) {
+			return fmt.Errorf("failed to flush cache: %w", err)
+		}
+	}
+
+	c.stats.reset()
+	return nil
+}
+
+// Namespace 返回blob名称前缀为 <prefix>ns/<name>/ 的子缓存
+func (c *azureCache) Namespace(name string) Cache {
+	if name == "" {
+		return c
+	}
+
+	c.nsMu.Lock()
+	defer c.nsMu.Unlock()
+
+	if ns, ok := c.namespaces[name]; ok {
+		return ns
+	}
+	ns := &azureCache{
+		client:     c.client,
+		cfg:        c.cfg,
+		prefix:     c.prefix + "ns/" + url.PathEscape(name) + "/",
+		namespaces: make(map[string]*azureCache),
+	}
+	c.namespaces[name] = ns
+	return ns
+}
+
+// Close 关闭缓存
+func (c *azureCache) Close() error {
+	return nil
+}
+
+// Stats 获取缓存统计信息
+func (c *azureCache) Stats() (*Stats, error) {
+	return c.stats.snapshot(), nil
+}
+
+// listBlobs 分页列出当前命名空间的所有数据blob
+func (c *azureCache) listBlobs(ctx context.Context) ([]azblob.Blob, error) {
+	var blobs []azblob.Blob
+	marker := ""
+	for {
+		result, err := c.client.ListBlobs(ctx, c.dataPrefix(), marker)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list blobs: %w", err)
+		}
+		blobs = append(blobs, result.Blobs...)
+		if result.NextMarker == "" {
+			return blobs, nil
+		}
+		marker = result.NextMarker
+	}
+}
+
+// azureFileInfo 从blob属性解析文件信息
+func azureFileInfo(key string, header http.Header) *FileInfo {
+	info := &FileInfo{
+		Key:       key,
+		MimeType:  header.Get("Content-Type"),
+		ExpiresAt: azureExpiresAt(header.Get("X-Ms-Meta-" + azureMetaExpiresAt)),
+	}
+	if size, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil {
+		info.Size = size
+	}
+	if t, err := time.Parse(azureTimeLayout, header.Get("X-Ms-Meta-"+azureMetaCreatedAt)); err == nil {
+		info.CreatedAt = t
+	} else if t, err := http.ParseTime(header.Get("X-Ms-Creation-Time")); err == nil {
+		info.CreatedAt = t
+	}
+	info.LastAccess = info.CreatedAt
+	return info
+}
+
+// azureExpiresAt 解析过期时间，没有过期时间的blob视为永不过期，由容器生命周期策略管理
+func azureExpiresAt(value string) time.Time {
+	if t, err := time.Parse(azureTimeLayout, value); err == nil {
+		return t
+	}
+	return neverExpires
+}
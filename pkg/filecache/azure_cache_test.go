@@ -0,0 +1,196 @@
+package filecache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/internal/azuretest"
+)
+
+func TestAzureCache(t *testing.T) {
+	server := azuretest.NewServer("devstoreaccount1", "edge-cache")
+	defer server.Close()
+
+	cache, err := NewAzureCache(AzureConfig{
+		Account:      "devstoreaccount1",
+		AccountKey:   azuretest.AccountKey,
+		Endpoint:     server.Endpoint(),
+		Container:    "edge-cache",
+		Prefix:       "edge/",
+		DefaultTTL:   time.Hour,
+		MaxEntrySize: 1024,
+		AccessTier:   "Cool",
+		TierHint: func(key string, size int64) string {
+			if strings.HasSuffix(key, ".json") {
+				return "Hot"
+			}
+			return ""
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+
+	t.Run("SetAndGet", func(t *testing.T) {
+		if err := cache.Set(ctx, "img/logo 1.png", strings.NewReader("png data"), "image/png", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+
+		if got := readString(t, cache, "img/logo 1.png"); got != "png data" {
+			t.Errorf("Expected 'png data', got %q", got)
+		}
+
+		info, err := cache.GetInfo(ctx, "img/logo 1.png")
+		if err != nil {
+			t.Fatalf("Failed to get info: %v", err)
+		}
+		if info.MimeType != "image/png" || info.Size != 8 {
+			t.Errorf("Unexpected info: %+v", info)
+		}
+		if time.Until(info.ExpiresAt) < 59*time.Minute {
+			t.Errorf("Unexpected expiry: %v", info.ExpiresAt)
+		}
+	})
+
+	t.Run("AccessTier", func(t *testing.T) {
+		if err := cache.Set(ctx, "api/config.json", strings.NewReader("{}"), "application/json", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		if tier := server.Tier("edge-cache", "edge/data/img/logo 1.png"); tier != "Cool" {
+			t.Errorf("Expected Cool tier, got %q", tier)
+		}
+		if tier := server.Tier("edge-cache", "edge/data/api/config.json"); tier != "Hot" {
+			t.Errorf("Expected Hot tier, got %q", tier)
+		}
+
+		if _, err := NewAzureCache(AzureConfig{Account: "a", AccountKey: azuretest.AccountKey, Container: "c", AccessTier: "Archive"}); err == nil {
+			t.Error("Expected error for archive tier")
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		if _, _, err := cache.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+		if exists, err := cache.Exists(ctx, "missing"); err != nil || exists {
+			t.Errorf("Expected missing file, got %v, %v", exists, err)
+		}
+		if err := cache.Delete(ctx, "missing"); err != nil {
+			t.Errorf("Failed to delete missing file: %v", err)
+		}
+	})
+
+	t.Run("MaxEntrySize", func(t *testing.T) {
+		err := cache.Set(ctx, "huge", strings.NewReader(strings.Repeat("x", 1025)), "text/plain", time.Hour)
+		if !errors.Is(err, ErrEntryTooLarge) {
+			t.Errorf("Expected ErrEntryTooLarge, got %v", err)
+		}
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		ns := cache.Namespace("site-a")
+		if err := cache.Set(ctx, "short", strings.NewReader("data"), "text/plain", 50*time.Millisecond); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		if err := ns.Set(ctx, "short", strings.NewReader("data"), "text/plain", 50*time.Millisecond); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+
+		if _, _, err := cache.Get(ctx, "short"); !errors.Is(err, ErrExpired) {
+			t.Errorf("Expected ErrExpired, got %v", err)
+		}
+		if err := cache.Cleanup(ctx); err != nil {
+			t.Fatalf("Failed to cleanup: %v", err)
+		}
+		if exists, _ := cache.Exists(ctx, "short"); exists {
+			t.Error("Expected expired file to be removed")
+		}
+		if exists, _ := cache.Exists(ctx, "img/logo 1.png"); !exists {
+			t.Error("Expected valid file to be kept")
+		}
+		// 清理只作用于当前命名空间
+		if exists, _ := ns.Exists(ctx, "short"); !exists {
+			t.Error("Expected namespace file to be kept")
+		}
+
+		stats, err := cache.Stats()
+		if err != nil {
+			t.Fatalf("Failed to get stats: %v", err)
+		}
+		if stats.ExpiredFiles != 1 {
+			t.Errorf("Expected 1 expired file, got %d", stats.ExpiredFiles)
+		}
+	})
+
+	t.Run("Namespace", func(t *testing.T) {
+		ns := cache.Namespace("site-a")
+		if err := ns.Set(ctx, "img/logo 1.png", strings.NewReader("site a"), "image/png", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		if got := readString(t, ns, "img/logo 1.png"); got != "site a" {
+			t.Errorf("Expected 'site a', got %q", got)
+		}
+
+		files, err := cache.List(ctx)
+		if err != nil {
+			t.Fatalf("Failed to list files: %v", err)
+		}
+		if len(files) != 2 {
+			t.Errorf("Expected 2 root files, got %d", len(files))
+		}
+
+		if err := ns.Flush(ctx); err != nil {
+			t.Fatalf("Failed to flush namespace: %v", err)
+		}
+		if files, _ := ns.List(ctx); len(files) != 0 {
+			t.Errorf("Expected empty namespace, got %d files", len(files))
+		}
+		if got := readString(t, cache, "img/logo 1.png"); got != "png data" {
+			t.Errorf("Expected 'png data', got %q", got)
+		}
+	})
+}
+
+func TestAzureCacheStats(t *testing.T) {
+	server := azuretest.NewServer("devstoreaccount1", "edge-cache")
+	defer server.Close()
+
+	cache, err := NewAzureCache(AzureConfig{
+		Account:    "devstoreaccount1",
+		AccountKey: azuretest.AccountKey,
+		Endpoint:   server.Endpoint(),
+		Container:  "edge-cache",
+		DefaultTTL: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	for _, e := range []struct{ key, data string }{{"a", "png data"}, {"a", "png"}, {"b", "hello"}} {
+		if err := cache.Set(ctx, e.key, strings.NewReader(e.data), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set %s: %v", e.key, err)
+		}
+	}
+	if stats, _ := cache.Stats(); stats.TotalFiles != 2 || stats.TotalSize != 8 {
+		t.Errorf("Expected overwrites to be counted by the size difference, got %d files %d bytes", stats.TotalFiles, stats.TotalSize)
+	}
+
+	// 删除不存在的blob不改变统计信息
+	for _, key := range []string{"a", "a"} {
+		if err := cache.Delete(ctx, key); err != nil {
+			t.Fatalf("Failed to delete %s: %v", key, err)
+		}
+	}
+	if stats, _ := cache.Stats(); stats.TotalFiles != 1 || stats.TotalSize != 5 {
+		t.Errorf("Expected deletes to be subtracted, got %d files %d bytes", stats.TotalFiles, stats.TotalSize)
+	}
+}
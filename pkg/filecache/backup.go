@@ -0,0 +1,344 @@
+package filecache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/internal/s3client"
+)
+
+const (
+	// 备份对象名为 <prefix>snapshot-<时间>-<起始版本>-<下一版本>.badger，全量备份的起始版本为0
+	backupObjectPrefix = "snapshot-"
+	backupObjectSuffix = ".badger"
+	// backupTimeLayout 定长的UTC时间格式，使对象名的字典序与时间顺序一致
+	backupTimeLayout = "20060102T150405.000000000Z"
+)
+
+// backupTarget 备份上传位置
+type backupTarget struct {
+	client *s3client.Client
+	prefix string
+
+	mu       sync.Mutex // 串行化备份和恢复
+	since    uint64     // 下一次增量备份的起始版本，0表示下一次为全量备份
+	lastFull time.Time  // 最近一次全量备份的时间
+	drops    uint64     // 上次备份时数据库的DropPrefix/DropAll次数
+}
+
+// backupObject 备份对象，Since为0表示全量备份
+type backupObject struct {
+	Key   string
+	Time  time.Time
+	Since uint64 // 起始版本
+	Next  uint64 // 下一次增量备份的起始版本
+}
+
+// parseBackupObject 解析备份对象名
+func parseBackupObject(prefix, key string) (backupObject, bool) {
+	name := strings.TrimPrefix(key, prefix+backupObjectPrefix)
+	if name == key || !strings.HasSuffix(name, backupObjectSuffix) {
+		return backupObject{}, false
+	}
+	name = strings.TrimSuffix(name, backupObjectSuffix)
+	if len(name) < len(backupTimeLayout) {
+		return backupObject{}, false
+	}
+
+	t, err := time.Parse(backupTimeLayout, name[:len(backupTimeLayout)])
+	if err != nil {
+		return backupObject{}, false
+	}
+	obj := backupObject{Key: key, Time: t}
+
+	// 只有时间的对象名是早期的全量备份
+	if versions := name[len(backupTimeLayout):]; versions != "" {
+		if _, err := fmt.Sscanf(versions, "-%d-%d", &obj.Since, &obj.Next); err != nil {
+			return backupObject{}, false
+		}
+	}
+	return obj, true
+}
+
+// backupObjectKey 生成备份对象名
+func backupObjectKey(prefix string, t time.Time, since, next uint64) string {
+	return fmt.Sprintf("%s%s%s-%d-%d%s", prefix, backupObjectPrefix, t.UTC().Format(backupTimeLayout), since, next, backupObjectSuffix)
+}
+
+// parseBackupDestination 解析 s3://bucket/prefix/ 格式的备份位置
+func parseBackupDestination(dest string) (bucket, prefix string, err error) {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid backup destination: %w", err)
+	}
+	if u.Scheme != "s3" || u.Host == "" {
+		return "", "", fmt.Errorf("invalid backup destination %q: expected s3://bucket/prefix", dest)
+	}
+
+	prefix = strings.TrimPrefix(u.Path, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return u.Host, prefix, nil
+}
+
+// newBackupTarget 根据配置创建备份位置，未配置密钥时读取AWS标准环境变量；
+// 备份间隔必须为正数，否则定时备份的time.NewTicker会panic
+func newBackupTarget(cfg *BackupConfig) (*backupTarget, error) {
+	if cfg.Interval <= 0 {
+		return nil, fmt.Errorf("backup interval must be positive")
+	}
+	bucket, prefix, err := parseBackupDestination(cfg.Destination)
+	if err != nil {
+		return nil, err
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	accessKeyID, secretAccessKey, sessionToken := cfg.AccessKeyID, cfg.SecretAccessKey, ""
+	if accessKeyID == "" {
+		accessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		secretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		sessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+
+	client, err := s3client.New(s3client.Config{
+		Endpoint:        endpoint,
+		Region:          region,
+		Bucket:          bucket,
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		SessionToken:    sessionToken,
+		PathStyle:       cfg.PathStyle,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &backupTarget{client: client, prefix: prefix}, nil
+}
+
+// startBackupRoutine 定期备份
+func (c *badgerCache) startBackupRoutine(ctx context.Context) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.config.Load().Backup.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// 记录错误但不中断备份协程，成功时间见Stats.LastBackup
+			_, _ = c.backup(ctx)
+		}
+	}
+}
+
+// backup 生成快照并上传，然后清理超过保留时间的旧备份，返回备份对象名
+// 距上次全量备份不足FullInterval时只上传上次备份之后写入的数据
+// 快照先写入数据目录下的临时文件，以便上传时提供Content-Length
+func (c *badgerCache) backup(ctx context.Context) (string, error) {
+	target := c.backups
+	target.mu.Lock()
+	defer target.mu.Unlock()
+
+	now := time.Now().UTC()
+	since := target.since
+	if full := c.config.Load().Backup.FullInterval; full <= 0 || now.Sub(target.lastFull) >= full {
+		since = 0
+	}
+	// Flush等批量删除不会出现在增量快照中，之后的第一次备份必须是全量备份
+	drops := c.store.drops.Load()
+	if drops != target.drops {
+		since = 0
+	}
+
+	tmp, err := os.CreateTemp(c.config.Load().DataDir, "snapshot-*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	next, err := c.Snapshot(ctx, tmp, since)
+	if err != nil {
+		return "", err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	key := backupObjectKey(target.prefix, now, since, next)
+	header := http.Header{"Content-Type": {"application/octet-stream"}}
+	if err := target.client.PutObject(ctx, key, tmp, size, header); err != nil {
+		return "", fmt.Errorf("failed to upload snapshot: %w", err)
+	}
+
+	target.since = next
+	target.drops = drops
+	if since == 0 {
+		target.lastFull = now
+	}
+	c.mu.Lock()
+	c.stats.LastBackup = now
+	c.mu.Unlock()
+
+	if err := c.pruneBackups(ctx, now); err != nil {
+		return key, err
+	}
+	return key, nil
+}
+
+// listBackups 按时间顺序列出所有备份对象
+func (c *badgerCache) listBackups(ctx context.Context) ([]backupObject, error) {
+	var backups []backupObject
+	token := ""
+	for {
+		result, err := c.backups.client.ListObjectsV2(ctx, c.backups.prefix+backupObjectPrefix, token, 1000)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list backups: %w", err)
+		}
+		for _, obj := range result.Objects {
+			if backup, ok := parseBackupObject(c.backups.prefix, obj.Key); ok {
+				backups = append(backups, backup)
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Key < backups[j].Key })
+	return backups, nil
+}
+
+// backupChains 把按时间排序的备份分组，每组以全量备份开头，后面是依赖它的增量备份
+func backupChains(backups []backupObject) [][]backupObject {
+	var chains [][]backupObject
+	for _, b := range backups {
+		if b.Since == 0 || len(chains) == 0 {
+			chains = append(chains, []backupObject{b})
+			continue
+		}
+		chains[len(chains)-1] = append(chains[len(chains)-1], b)
+	}
+	return chains
+}
+
+// pruneBackups 删除超过保留时间的备份
+// 增量备份依赖全量备份，只有一组备份全部过期时才删除整组，最新的一组总是保留
+func (c *badgerCache) pruneBackups(ctx context.Context, now time.Time) error {
+	if c.config.Load().Backup.Retention <= 0 {
+		return nil
+	}
+
+	backups, err := c.listBackups(ctx)
+	if err != nil {
+		return err
+	}
+	chains := backupChains(backups)
+	if len(chains) <= 1 {
+		return nil
+	}
+
+	cutoff := now.Add(-c.config.Load().Backup.Retention)
+	var expired []string
+	for _, chain := range chains[:len(chains)-1] {
+		if !chain[len(chain)-1].Time.Before(cutoff) {
+			continue
+		}
+		for _, b := range chain {
+			expired = append(expired, b.Key)
+		}
+	}
+	if err := c.backups.client.DeleteObjects(ctx, expired); err != nil {
+		return fmt.Errorf("failed to prune backups: %w", err)
+	}
+	return nil
+}
+
+// RestoreToTime 从备份恢复缓存在t时的状态
+// 选择t之前最近的全量备份，再按顺序叠加其后在t之前完成的增量备份，版本不连续时停止
+func (c *badgerCache) RestoreToTime(ctx context.Context, t time.Time) error {
+	if err := c.checkSnapshotSupported(); err != nil {
+		return err
+	}
+	if c.backups == nil {
+		return fmt.Errorf("backups are not configured")
+	}
+
+	target := c.backups
+	target.mu.Lock()
+	defer target.mu.Unlock()
+
+	backups, err := c.listBackups(ctx)
+	if err != nil {
+		return err
+	}
+
+	var restore []backupObject
+	for _, chain := range backupChains(backups) {
+		if chain[0].Since != 0 || chain[0].Time.After(t) {
+			continue
+		}
+		restore = chain[:1]
+		for _, b := range chain[1:] {
+			if b.Time.After(t) || b.Since != restore[len(restore)-1].Next {
+				break
+			}
+			restore = append(restore, b)
+		}
+	}
+	if len(restore) == 0 {
+		return fmt.Errorf("no backup found before %s", t.UTC().Format(time.RFC3339))
+	}
+
+	for i, b := range restore {
+		if err := c.loadBackup(ctx, b.Key, i == 0); err != nil {
+			return err
+		}
+	}
+
+	// 恢复后的数据不再与之前的增量链衔接，下一次备份为全量备份
+	target.since = 0
+
+	c.mu.RLock()
+	lastBackup := c.stats.LastBackup
+	c.mu.RUnlock()
+	err = c.reloadAllStats()
+	c.mu.Lock()
+	c.stats.LastBackup = lastBackup
+	c.mu.Unlock()
+	return err
+}
+
+// loadBackup 下载并加载一个备份对象，full为true时先清空数据库
+func (c *badgerCache) loadBackup(ctx context.Context, key string, full bool) error {
+	resp, err := c.backups.client.GetObject(ctx, key, nil)
+	if err != nil {
+		return fmt.Errorf("failed to download backup %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	return c.loadSnapshot(ctx, resp.Body, full)
+}
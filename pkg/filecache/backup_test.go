@@ -0,0 +1,338 @@
+package filecache
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/internal/s3test"
+)
+
+func TestParseBackupDestination(t *testing.T) {
+	tests := []struct {
+		dest   string
+		bucket string
+		prefix string
+	}{
+		{"s3://backups", "backups", ""},
+		{"s3://backups/", "backups", ""},
+		{"s3://backups/edge/node-1", "backups", "edge/node-1/"},
+		{"s3://backups/edge/", "backups", "edge/"},
+	}
+	for _, tt := range tests {
+		bucket, prefix, err := parseBackupDestination(tt.dest)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", tt.dest, err)
+		}
+		if bucket != tt.bucket || prefix != tt.prefix {
+			t.Errorf("%q: expected %q %q, got %q %q", tt.dest, tt.bucket, tt.prefix, bucket, prefix)
+		}
+	}
+
+	for _, dest := range []string{"", "backups/edge", "gs://backups/edge", "s3:///edge"} {
+		if _, _, err := parseBackupDestination(dest); err == nil {
+			t.Errorf("Expected error for %q", dest)
+		}
+	}
+}
+
+// newTestBackupCache 创建备份到server的Badger缓存
+func newTestBackupCache(t *testing.T, server *s3test.Server, backup BackupConfig) *badgerCache {
+	t.Helper()
+
+	backup.Destination = "s3://backups/edge/"
+	backup.Endpoint = server.URL
+	backup.AccessKeyID = "test"
+	backup.SecretAccessKey = "secret"
+	backup.PathStyle = true
+
+	cache, err := newBadgerCache(&Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+		Backup:          &backup,
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	return cache
+}
+
+func TestBadgerCacheBackup(t *testing.T) {
+	server := s3test.NewServer("backups")
+	defer server.Close()
+
+	newCache := func(interval time.Duration) *badgerCache {
+		return newTestBackupCache(t, server, BackupConfig{Interval: interval, Retention: 24 * time.Hour})
+	}
+
+	ctx := context.Background()
+
+	t.Run("ZeroInterval", func(t *testing.T) {
+		_, err := NewBadgerCache(&Config{
+			DataDir:         t.TempDir(),
+			MaxCacheSize:    1024 * 1024,
+			DefaultTTL:      time.Hour,
+			CleanupInterval: time.Hour,
+			Backup:          &BackupConfig{Destination: "s3://backups/edge/"},
+		})
+		if err == nil || !strings.Contains(err.Error(), "backup interval") {
+			t.Errorf("Expected an error for a zero backup interval, got %v", err)
+		}
+	})
+
+	t.Run("UploadAndRestore", func(t *testing.T) {
+		cache := newCache(time.Hour)
+		defer cache.Close()
+
+		if err := cache.Set(ctx, "a.txt", strings.NewReader("aaa"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		key, err := cache.backup(ctx)
+		if err != nil {
+			t.Fatalf("Failed to back up: %v", err)
+		}
+		if !strings.HasPrefix(key, "edge/snapshot-") || !strings.HasSuffix(key, ".badger") {
+			t.Errorf("Unexpected backup key: %s", key)
+		}
+
+		stats, err := cache.Stats()
+		if err != nil {
+			t.Fatalf("Failed to get stats: %v", err)
+		}
+		if stats.LastBackup.IsZero() {
+			t.Error("Expected LastBackup to be set")
+		}
+
+		resp, err := cache.backups.client.GetObject(ctx, key, nil)
+		if err != nil {
+			t.Fatalf("Failed to download backup: %v", err)
+		}
+		defer resp.Body.Close()
+
+		restored := newTestBadgerCache(t)
+		defer restored.Close()
+		if err := restored.(Snapshotter).RestoreSnapshot(ctx, resp.Body); err != nil {
+			t.Fatalf("Failed to restore backup: %v", err)
+		}
+		if got := readString(t, restored, "a.txt"); got != "aaa" {
+			t.Errorf("Expected 'aaa', got %q", got)
+		}
+	})
+
+	t.Run("Retention", func(t *testing.T) {
+		cache := newCache(time.Hour)
+		defer cache.Close()
+
+		// 两个超过保留时间的旧备份和一个仍在保留期内的备份
+		now := time.Now().UTC()
+		old := []string{
+			"edge/snapshot-" + now.Add(-72*time.Hour).Format(backupTimeLayout) + ".badger",
+			"edge/snapshot-" + now.Add(-48*time.Hour).Format(backupTimeLayout) + ".badger",
+		}
+		recent := "edge/snapshot-" + now.Add(-time.Hour).Format(backupTimeLayout) + ".badger"
+		for _, key := range append(old, recent) {
+			if err := cache.backups.client.PutObject(ctx, key, strings.NewReader("old"), 3, http.Header{}); err != nil {
+				t.Fatalf("Failed to put object: %v", err)
+			}
+		}
+
+		key, err := cache.backup(ctx)
+		if err != nil {
+			t.Fatalf("Failed to back up: %v", err)
+		}
+
+		keys := strings.Join(server.Keys("backups"), ",")
+		for _, k := range old {
+			if strings.Contains(keys, k) {
+				t.Errorf("Expected %s to be pruned, got %s", k, keys)
+			}
+		}
+		if !strings.Contains(keys, recent) || !strings.Contains(keys, key) {
+			t.Errorf("Expected recent backups to be kept, got %s", keys)
+		}
+	})
+
+	t.Run("KeepsNewest", func(t *testing.T) {
+		cache := newCache(time.Hour)
+		defer cache.Close()
+
+		backups, err := cache.listBackups(ctx)
+		if err != nil {
+			t.Fatalf("Failed to list backups: %v", err)
+		}
+		newest := backups[len(backups)-1].Key
+
+		// 即使所有备份都已过期，最新的备份也要保留
+		if err := cache.pruneBackups(ctx, time.Now().Add(365*24*time.Hour)); err != nil {
+			t.Fatalf("Failed to prune backups: %v", err)
+		}
+		keys := server.Keys("backups")
+		if len(keys) != 1 || keys[0] != newest {
+			t.Errorf("Expected only %s to be kept, got %v", newest, keys)
+		}
+	})
+
+	t.Run("Scheduled", func(t *testing.T) {
+		cache := newCache(20 * time.Millisecond)
+		defer cache.Close()
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			stats, err := cache.Stats()
+			if err != nil {
+				t.Fatalf("Failed to get stats: %v", err)
+			}
+			if !stats.LastBackup.IsZero() {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("Timed out waiting for scheduled backup")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		_, err := NewBadgerCache(&Config{
+			DataDir:      t.TempDir(),
+			MaxCacheSize: 1024,
+			Backup:       &BackupConfig{Interval: time.Hour, Destination: "backups/edge"},
+		})
+		if err == nil {
+			t.Error("Expected error for invalid backup destination")
+		}
+	})
+}
+
+func TestBadgerCacheRestoreToTime(t *testing.T) {
+	server := s3test.NewServer("backups")
+	defer server.Close()
+
+	cache := newTestBackupCache(t, server, BackupConfig{Interval: time.Hour, FullInterval: time.Hour})
+	defer cache.Close()
+
+	ctx := context.Background()
+	set := func(key, value string) {
+		t.Helper()
+		if err := cache.Set(ctx, key, strings.NewReader(value), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+	}
+	checkpoint := func() time.Time {
+		t.Helper()
+		if _, err := cache.backup(ctx); err != nil {
+			t.Fatalf("Failed to back up: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+		at := time.Now()
+		time.Sleep(5 * time.Millisecond)
+		return at
+	}
+	exists := func(key string) bool {
+		t.Helper()
+		ok, err := cache.Exists(ctx, key)
+		if err != nil {
+			t.Fatalf("Failed to check existence: %v", err)
+		}
+		return ok
+	}
+
+	set("a", "aaa")
+	set("b", "bbb")
+	afterFull := checkpoint()
+
+	// 误删除后又写入了新数据
+	if err := cache.Delete(ctx, "a"); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+	set("c", "ccc")
+	afterPurge := checkpoint()
+
+	set("d", "ddd")
+	afterLatest := checkpoint()
+
+	backups, err := cache.listBackups(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list backups: %v", err)
+	}
+	if len(backups) != 3 || backups[0].Since != 0 || backups[1].Since != backups[0].Next || backups[2].Since != backups[1].Next {
+		t.Fatalf("Expected one full and two chained incremental backups, got %+v", backups)
+	}
+
+	t.Run("BeforePurge", func(t *testing.T) {
+		if err := cache.RestoreToTime(ctx, afterFull); err != nil {
+			t.Fatalf("Failed to restore: %v", err)
+		}
+		if got := readString(t, cache, "a"); got != "aaa" {
+			t.Errorf("Expected 'aaa', got %q", got)
+		}
+		if exists("c") || exists("d") {
+			t.Error("Expected files written after the restore point to be absent")
+		}
+
+		stats, err := cache.Stats()
+		if err != nil {
+			t.Fatalf("Failed to get stats: %v", err)
+		}
+		if stats.TotalFiles != 2 {
+			t.Errorf("Expected restored stats with 2 files, got %d", stats.TotalFiles)
+		}
+	})
+
+	t.Run("AfterPurge", func(t *testing.T) {
+		if err := cache.RestoreToTime(ctx, afterPurge); err != nil {
+			t.Fatalf("Failed to restore: %v", err)
+		}
+		if exists("a") {
+			t.Error("Expected deletion from incremental backup to be replayed")
+		}
+		if !exists("b") || !exists("c") || exists("d") {
+			t.Error("Unexpected files after restore")
+		}
+	})
+
+	t.Run("Latest", func(t *testing.T) {
+		if err := cache.RestoreToTime(ctx, afterLatest); err != nil {
+			t.Fatalf("Failed to restore: %v", err)
+		}
+		if got := readString(t, cache, "d"); got != "ddd" {
+			t.Errorf("Expected 'ddd', got %q", got)
+		}
+	})
+
+	t.Run("FullAfterRestore", func(t *testing.T) {
+		key, err := cache.backup(ctx)
+		if err != nil {
+			t.Fatalf("Failed to back up: %v", err)
+		}
+		if b, ok := parseBackupObject("edge/", key); !ok || b.Since != 0 {
+			t.Errorf("Expected full backup after restore, got %s", key)
+		}
+	})
+
+	t.Run("FullAfterFlush", func(t *testing.T) {
+		if _, err := cache.backup(ctx); err != nil {
+			t.Fatalf("Failed to back up: %v", err)
+		}
+		if err := cache.Flush(ctx); err != nil {
+			t.Fatalf("Failed to flush cache: %v", err)
+		}
+		key, err := cache.backup(ctx)
+		if err != nil {
+			t.Fatalf("Failed to back up: %v", err)
+		}
+		if b, ok := parseBackupObject("edge/", key); !ok || b.Since != 0 {
+			t.Errorf("Expected full backup after flush, got %s", key)
+		}
+	})
+
+	t.Run("NoBackup", func(t *testing.T) {
+		if err := cache.RestoreToTime(ctx, afterFull.Add(-time.Hour)); err == nil {
+			t.Error("Expected error when no backup precedes the restore point")
+		}
+	})
+}
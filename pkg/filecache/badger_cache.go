@@ -1,6 +1,7 @@
 package filecache
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
@@ -21,16 +23,74 @@ const (
 	statsKey       = "stats"
 )
 
+// badgerStore 根缓存与各命名空间共享的Badger实例
+// 轮换密钥等需要重新打开数据库的操作持有写锁，普通读写持有读锁
+type badgerStore struct {
+	mu    sync.RWMutex
+	db    *badger.DB
+	opts  badger.Options
+	drops atomic.Uint64 // DropPrefix/DropAll的次数，这类删除不会出现在增量快照中
+}
+
+// view 执行只读事务
+func (s *badgerStore) view(fn func(txn *badger.Txn) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.View(fn)
+}
+
+// update 执行读写事务
+func (s *badgerStore) update(fn func(txn *badger.Txn) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.Update(fn)
+}
+
+// withDB 在持有读锁的情况下直接使用数据库
+func (s *badgerStore) withDB(fn func(db *badger.DB) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return fn(s.db)
+}
+
+// close 关闭数据库
+func (s *badgerStore) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.db.Close()
+}
+
 // badgerCache Badger文件缓存实现
 type badgerCache struct {
-	db     *badger.DB
-	config *Config
+	store  *badgerStore
+	blobs  *blobStore                    // 非空时文件数据存放在文件系统上，Badger只保存FileInfo
+	config *atomic.Pointer[Config]       // 命名空间共享，Reconfigure时整体替换
+	ttls   *atomic.Pointer[TTLPolicySet] // 由config.TTLPolicies编译，命名空间共享
 	stats  *Stats
 	mu     sync.RWMutex
+
+	prefix     string                  // 命名空间键前缀，根命名空间为空
+	name       string                  // 命名空间的完整路径，各级名称用"/"连接，根命名空间为空
+	namespaces map[string]*badgerCache // 已打开的子命名空间
+	backups    *backupTarget           // 定时备份位置，未配置备份时为空
+	nsMu       sync.Mutex
+	quotaMu    sync.Mutex // 串行化受配额限制的写入
+
+	evictMark atomic.Int64 // 上次按磁盘水位淘汰时的磁盘使用量，见evictForDisk
+
+	cancel    context.CancelFunc // 停止后台协程
+	wg        sync.WaitGroup     // 等待后台协程退出
+	closeOnce sync.Once
+	closeErr  error
 }
 
 // NewBadgerCache 创建新的Badger文件缓存
 func NewBadgerCache(config *Config) (Cache, error) {
+	return newBadgerCache(config, nil)
+}
+
+// newBadgerCache 打开Badger并启动后台清理，blobs非空时文件数据存放在文件系统上
+func newBadgerCache(config *Config, blobs *blobStore) (*badgerCache, error) {
 	if config == nil {
 		config = &Config{
 			DataDir:         "./cache",
@@ -55,6 +115,35 @@ func NewBadgerCache(config *Config) (Cache, error) {
 		opts.Compression = options.None
 	}
 	opts.ValueLogFileSize = 64 << 20 // 64MB
+	// 读取value log中的值时校验CRC，损坏的数据返回错误而不是错误的内容，Verify依赖这一点发现损坏
+	opts.VerifyValueChecksum = true
+
+	// 启用静态加密，文件数据和FileInfo记录都存放在加密的LSM和value log中
+	if config.EncryptionKey != "" {
+		key, err := decodeEncryptionKey(config.EncryptionKey)
+		if err != nil {
+			return nil, err
+		}
+		opts.EncryptionKey = key
+		opts.EncryptionKeyRotationDuration = encryptionKeyRotationDuration
+		opts.IndexCacheSize = encryptionIndexCacheSize
+	}
+
+	ttls, err := NewTTLPolicySet(config.TTLPolicies)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.ttlJitter().validate(); err != nil {
+		return nil, err
+	}
+
+	var backups *backupTarget
+	if config.Backup != nil {
+		var err error
+		if backups, err = newBackupTarget(config.Backup); err != nil {
+			return nil, err
+		}
+	}
 
 	// 打开数据库
 	db, err := badger.Open(opts)
@@ -63,10 +152,16 @@ func NewBadgerCache(config *Config) (Cache, error) {
 	}
 
 	cache := &badgerCache{
-		db:     db,
-		config: config,
-		stats:  &Stats{},
+		store:      &badgerStore{db: db, opts: opts},
+		blobs:      blobs,
+		config:     &atomic.Pointer[Config]{},
+		ttls:       &atomic.Pointer[TTLPolicySet]{},
+		stats:      &Stats{},
+		namespaces: make(map[string]*badgerCache),
+		backups:    backups,
 	}
+	cache.config.Store(config)
+	cache.ttls.Store(ttls)
 
 	// 加载统计信息
 	if err := cache.loadStats(); err != nil {
@@ -75,35 +170,71 @@ func NewBadgerCache(config *Config) (Cache, error) {
 	}
 
 	// 启动清理协程
-	go cache.startCleanupRoutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	cache.cancel = cancel
+	cache.wg.Add(1)
+	go cache.startCleanupRoutine(ctx)
+	if backups != nil {
+		cache.wg.Add(1)
+		go cache.startBackupRoutine(ctx)
+	}
+	if _, _, ok := config.evictWatermarks(); ok {
+		cache.wg.Add(1)
+		go cache.startEvictRoutine(ctx)
+	}
 
 	return cache, nil
 }
 
 // Set 存储文件到缓存
 func (c *badgerCache) Set(ctx context.Context, key string, data io.Reader, mimeType string, ttl time.Duration) error {
-	if ttl <= 0 {
-		ttl = c.config.DefaultTTL
+	if ttl <= 0 && ttl != NoExpiry {
+		ttl = c.defaultTTL(key, mimeType)
 	}
 
-	// 读取数据到内存
-	dataBytes, err := io.ReadAll(data)
-	if err != nil {
-		return fmt.Errorf("failed to read data: %w", err)
+	maxSize := c.config.Load().maxEntrySize()
+	var dataBytes []byte
+	var blobTemp string
+	var size int64
+	var err error
+
+	if c.blobs != nil {
+		// 流式写入临时文件，避免大文件整体读入内存
+		blobTemp, size, err = c.blobs.writeTemp(data, maxSize)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(blobTemp)
+	} else {
+		// 读取数据到内存，多读一个字节即可判断是否超出限制，无需读完超大的数据
+		dataBytes, err = io.ReadAll(io.LimitReader(data, maxSize+1))
+		if err != nil {
+			return fmt.Errorf("failed to read data: %w", err)
+		}
+		size = int64(len(dataBytes))
+
+		// 检查单个文件大小限制
+		if size > maxSize {
+			return fmt.Errorf("%w: file size exceeds max entry size %d", ErrEntryTooLarge, maxSize)
+		}
 	}
 
-	// 检查缓存大小限制
-	if int64(len(dataBytes)) > c.config.MaxCacheSize {
-		return fmt.Errorf("file size %d exceeds max cache size %d", len(dataBytes), c.config.MaxCacheSize)
+	// 检查命名空间配额，必要时淘汰本命名空间的旧文件
+	if quota, ok := c.quota(); ok {
+		c.quotaMu.Lock()
+		defer c.quotaMu.Unlock()
+		if err := c.ensureQuota(ctx, key, size, quota); err != nil {
+			return err
+		}
 	}
 
 	now := time.Now()
-	expiresAt := now.Add(ttl)
+	expiresAt := expiryFor(now, c.config.Load().ttlJitter().apply(ttl))
 
 	// 创建文件信息
 	fileInfo := &FileInfo{
 		Key:         key,
-		Size:        int64(len(dataBytes)),
+		Size:        size,
 		MimeType:    mimeType,
 		CreatedAt:   now,
 		ExpiresAt:   expiresAt,
@@ -117,14 +248,35 @@ func (c *badgerCache) Set(ctx context.Context, key string, data io.Reader, mimeT
 		return fmt.Errorf("failed to marshal file info: %w", err)
 	}
 
+	// 文件数据先落盘，再写入FileInfo，崩溃时最多留下没有FileInfo的孤立文件；
+	// 持有键锁直到FileInfo提交，同一个键的并发写入不会交错
+	defer c.lockBlob(key)()
+	if c.blobs != nil {
+		if err := c.blobs.commit(blobTemp, c.blobPath(key)); err != nil {
+			return fmt.Errorf("failed to store file: %w", err)
+		}
+	}
+
 	// 存储到Badger
-	err = c.db.Update(func(txn *badger.Txn) error {
-		// 存储文件数据
-		if err := txn.Set([]byte(fileDataPrefix+key), dataBytes); err != nil {
+	var oldSize int64
+	replaced := false
+	err = c.store.update(func(txn *badger.Txn) error {
+		// 覆盖写入时记录旧文件大小以修正统计
+		if size, err := c.sizeInTxn(txn, key); err == nil {
+			oldSize = size
+			replaced = true
+		} else if err != badger.ErrKeyNotFound {
 			return err
 		}
+
+		// 存储文件数据
+		if c.blobs == nil {
+			if err := txn.Set(c.dataKey(key), dataBytes); err != nil {
+				return err
+			}
+		}
 		// 存储文件信息
-		return txn.Set([]byte(fileInfoPrefix+key), infoBytes)
+		return txn.Set(c.infoKey(key), infoBytes)
 	})
 
 	if err != nil {
@@ -132,19 +284,27 @@ func (c *badgerCache) Set(ctx context.Context, key string, data io.Reader, mimeT
 	}
 
 	// 更新统计信息
-	c.updateStatsAfterSet(int64(len(dataBytes)))
+	if replaced {
+		c.updateStatsAfterDelete(oldSize)
+	}
+	c.updateStatsAfterSet(size)
 
 	return nil
 }
 
 // Get 从缓存获取文件
 func (c *badgerCache) Get(ctx context.Context, key string) (io.ReadCloser, *FileInfo, error) {
+	return c.get(key, false, true)
+}
+
+// get 获取文件，allowExpired为true时也返回已过期但尚未清理的文件，track为false时不更新命中率和访问信息
+func (c *badgerCache) get(key string, allowExpired, track bool) (io.ReadCloser, *FileInfo, error) {
 	var fileInfo *FileInfo
 	var data []byte
 
-	err := c.db.View(func(txn *badger.Txn) error {
+	err := c.store.view(func(txn *badger.Txn) error {
 		// 获取文件信息
-		infoItem, err := txn.Get([]byte(fileInfoPrefix + key))
+		infoItem, err := txn.Get(c.infoKey(key))
 		if err != nil {
 			return err
 		}
@@ -158,12 +318,17 @@ func (c *badgerCache) Get(ctx context.Context, key string) (io.ReadCloser, *File
 		}
 
 		// 检查是否过期
-		if time.Now().After(fileInfo.ExpiresAt) {
-			return fmt.Errorf("file expired")
+		if !allowExpired && time.Now().After(fileInfo.ExpiresAt) {
+			return ErrExpired
+		}
+
+		// 文件数据在文件系统上时在事务外打开
+		if c.blobs != nil {
+			return nil
 		}
 
 		// 获取文件数据
-		dataItem, err := txn.Get([]byte(fileDataPrefix + key))
+		dataItem, err := txn.Get(c.dataKey(key))
 		if err != nil {
 			return err
 		}
@@ -177,24 +342,43 @@ func (c *badgerCache) Get(ctx context.Context, key string) (io.ReadCloser, *File
 
 	if err != nil {
 		if err == badger.ErrKeyNotFound {
-			c.updateStatsAfterMiss()
-			return nil, nil, fmt.Errorf("file not found")
+			if track {
+				c.updateStatsAfterMiss()
+			}
+			return nil, nil, ErrNotFound
 		}
 		return nil, nil, err
 	}
 
+	var reader io.ReadCloser = newReadCloser(data)
+	if c.blobs != nil {
+		file, err := c.blobs.open(c.blobPath(key), fileInfo)
+		if os.IsNotExist(err) {
+			if track {
+				c.updateStatsAfterMiss()
+			}
+			return nil, nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open blob: %w", err)
+		}
+		reader = file
+	}
+
 	// 更新访问统计
-	c.updateStatsAfterHit()
-	c.updateFileAccess(key, fileInfo)
+	if track {
+		c.updateStatsAfterHit()
+		c.updateFileAccess(key, fileInfo)
+	}
 
-	return &readCloser{data: data}, fileInfo, nil
+	return reader, fileInfo, nil
 }
 
 // Exists 检查文件是否存在
 func (c *badgerCache) Exists(ctx context.Context, key string) (bool, error) {
 	exists := false
-	err := c.db.View(func(txn *badger.Txn) error {
-		_, err := txn.Get([]byte(fileInfoPrefix + key))
+	err := c.store.view(func(txn *badger.Txn) error {
+		_, err := txn.Get(c.infoKey(key))
 		if err == badger.ErrKeyNotFound {
 			exists = false
 			return nil
@@ -208,13 +392,23 @@ func (c *badgerCache) Exists(ctx context.Context, key string) (bool, error) {
 	return exists, err
 }
 
-// Delete 删除文件
+// Delete 删除文件，启用软删除时移入回收站
 func (c *badgerCache) Delete(ctx context.Context, key string) error {
+	if c.config.Load().SoftDelete {
+		return c.softDelete(key)
+	}
+	return c.remove(key)
+}
+
+// remove 永久删除文件
+func (c *badgerCache) remove(key string) error {
+	defer c.lockBlob(key)()
+
 	var fileInfo *FileInfo
 
 	// 先获取文件信息以更新统计
-	err := c.db.View(func(txn *badger.Txn) error {
-		infoItem, err := txn.Get([]byte(fileInfoPrefix + key))
+	err := c.store.view(func(txn *badger.Txn) error {
+		infoItem, err := txn.Get(c.infoKey(key))
 		if err != nil {
 			return err
 		}
@@ -229,16 +423,21 @@ func (c *badgerCache) Delete(ctx context.Context, key string) error {
 	}
 
 	// 删除文件
-	err = c.db.Update(func(txn *badger.Txn) error {
-		if err := txn.Delete([]byte(fileDataPrefix + key)); err != nil {
-			return err
+	err = c.store.update(func(txn *badger.Txn) error {
+		if c.blobs == nil {
+			if err := txn.Delete(c.dataKey(key)); err != nil {
+				return err
+			}
 		}
-		return txn.Delete([]byte(fileInfoPrefix + key))
+		return txn.Delete(c.infoKey(key))
 	})
 
 	if err != nil {
 		return fmt.Errorf("failed to delete file: %w", err)
 	}
+	if c.blobs != nil && fileInfo != nil {
+		c.blobs.remove(c.blobPath(key))
+	}
 
 	// 更新统计信息
 	if fileInfo != nil {
@@ -248,35 +447,49 @@ func (c *badgerCache) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+// sizeInTxn 在事务中读取文件大小
+func (c *badgerCache) sizeInTxn(txn *badger.Txn, key string) (int64, error) {
+	item, err := txn.Get(c.infoKey(key))
+	if err != nil {
+		return 0, err
+	}
+
+	fileInfo := &FileInfo{}
+	if err := item.Value(func(val []byte) error {
+		return json.Unmarshal(val, fileInfo)
+	}); err != nil {
+		return 0, err
+	}
+	return fileInfo.Size, nil
+}
+
 // List 列出所有缓存文件
 func (c *badgerCache) List(ctx context.Context) ([]*FileInfo, error) {
 	var files []*FileInfo
 
-	err := c.db.View(func(txn *badger.Txn) error {
+	err := c.store.view(func(txn *badger.Txn) error {
+		// 只处理当前命名空间的文件信息键
+		prefix := []byte(c.prefix + fileInfoPrefix)
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchValues = true
+		opts.Prefix = prefix
 		it := txn.NewIterator(opts)
 		defer it.Close()
 
-		for it.Rewind(); it.Valid(); it.Next() {
+		for it.Rewind(); it.ValidForPrefix(prefix); it.Next() {
 			item := it.Item()
-			key := string(item.Key())
-
-			// 只处理文件信息键
-			if len(key) > len(fileInfoPrefix) && key[:len(fileInfoPrefix)] == fileInfoPrefix {
-				fileKey := key[len(fileInfoPrefix):]
-				err := item.Value(func(val []byte) error {
-					fileInfo := &FileInfo{}
-					if err := json.Unmarshal(val, fileInfo); err != nil {
-						return err
-					}
-					fileInfo.Key = fileKey
-					files = append(files, fileInfo)
-					return nil
-				})
-				if err != nil {
+			fileKey := string(item.Key()[len(prefix):])
+			err := item.Value(func(val []byte) error {
+				fileInfo := &FileInfo{}
+				if err := json.Unmarshal(val, fileInfo); err != nil {
 					return err
 				}
+				fileInfo.Key = fileKey
+				files = append(files, fileInfo)
+				return nil
+			})
+			if err != nil {
+				return err
 			}
 		}
 		return nil
@@ -289,8 +502,8 @@ func (c *badgerCache) List(ctx context.Context) ([]*FileInfo, error) {
 func (c *badgerCache) GetInfo(ctx context.Context, key string) (*FileInfo, error) {
 	var fileInfo *FileInfo
 
-	err := c.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(fileInfoPrefix + key))
+	err := c.store.view(func(txn *badger.Txn) error {
+		item, err := txn.Get(c.infoKey(key))
 		if err != nil {
 			return err
 		}
@@ -306,6 +519,9 @@ func (c *badgerCache) GetInfo(ctx context.Context, key string) (*FileInfo, error
 	})
 
 	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil, ErrNotFound
+		}
 		return nil, err
 	}
 
@@ -314,69 +530,63 @@ func (c *badgerCache) GetInfo(ctx context.Context, key string) (*FileInfo, error
 
 // Cleanup 清理过期文件
 func (c *badgerCache) Cleanup(ctx context.Context) error {
-	now := time.Now()
-	var expiredFiles []string
-	var totalSize int64
+	_, err := c.CleanupWithOptions(ctx, CleanupOptions{})
+	return err
+}
 
-	// 找出过期文件
-	err := c.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = true
-		it := txn.NewIterator(opts)
-		defer it.Close()
+// Flush 删除所有缓存文件并重置统计信息
+// DropPrefix 会阻塞写入直到删除完成，整个过程对其他读写是原子的
+func (c *badgerCache) Flush(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-		for it.Rewind(); it.Valid(); it.Next() {
-			item := it.Item()
-			key := string(item.Key())
-
-			if len(key) > len(fileInfoPrefix) && key[:len(fileInfoPrefix)] == fileInfoPrefix {
-				fileKey := key[len(fileInfoPrefix):]
-				err := item.Value(func(val []byte) error {
-					fileInfo := &FileInfo{}
-					if err := json.Unmarshal(val, fileInfo); err != nil {
-						return err
-					}
-					if now.After(fileInfo.ExpiresAt) {
-						expiredFiles = append(expiredFiles, fileKey)
-						totalSize += fileInfo.Size
-					}
-					return nil
-				})
-				if err != nil {
-					return err
-				}
-			}
-		}
-		return nil
+	prefixes := [][]byte{
+		[]byte(c.prefix + fileDataPrefix),
+		[]byte(c.prefix + fileInfoPrefix),
+		[]byte(c.prefix + trashPrefix),
+	}
+	err := c.store.withDB(func(db *badger.DB) error {
+		return db.DropPrefix(prefixes...)
 	})
-
+	c.store.drops.Add(1)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to flush cache: %w", err)
 	}
-
-	// 删除过期文件
-	for _, fileKey := range expiredFiles {
-		if err := c.Delete(ctx, fileKey); err != nil {
-			// 记录错误但继续清理其他文件
-			continue
+	if c.blobs != nil {
+		if err := c.blobs.removeNamespace(c.prefix); err != nil {
+			return fmt.Errorf("failed to flush blobs: %w", err)
 		}
 	}
 
-	// 更新统计信息
 	c.mu.Lock()
-	c.stats.ExpiredFiles = int64(len(expiredFiles))
-	c.stats.LastCleanup = now
+	c.stats = &Stats{}
 	c.mu.Unlock()
 
-	// 保存统计信息
-	c.saveStats()
-
-	return nil
+	return c.saveStats()
 }
 
 // Close 关闭缓存
+// 依次停止清理协程、等待其退出、落盘统计信息，最后关闭Badger；重复调用是安全的
+// 命名空间的Close只落盘自身的统计信息，底层数据库由根缓存负责关闭
 func (c *badgerCache) Close() error {
-	return c.db.Close()
+	c.closeOnce.Do(func() {
+		if c.cancel == nil {
+			c.closeErr = c.saveAllStats()
+			return
+		}
+
+		c.cancel()
+		c.wg.Wait()
+
+		if err := c.saveAllStats(); err != nil {
+			c.closeErr = fmt.Errorf("failed to save stats: %w", err)
+		}
+		if err := c.store.close(); err != nil && c.closeErr == nil {
+			c.closeErr = fmt.Errorf("failed to close badger database: %w", err)
+		}
+	})
+	return c.closeErr
 }
 
 // Stats 获取缓存统计信息
@@ -389,19 +599,14 @@ func (c *badgerCache) Stats() (*Stats, error) {
 	return &stats, nil
 }
 
-// readCloser 实现io.ReadCloser接口
+// readCloser 基于内存数据的io.ReadCloser，同时实现io.Seeker和io.ReaderAt以支持范围读取
 type readCloser struct {
-	data []byte
-	pos  int
+	*bytes.Reader
 }
 
-func (r *readCloser) Read(p []byte) (n int, err error) {
-	if r.pos >= len(r.data) {
-		return 0, io.EOF
-	}
-	n = copy(p, r.data[r.pos:])
-	r.pos += n
-	return n, nil
+// newReadCloser 创建读取data的readCloser
+func newReadCloser(data []byte) *readCloser {
+	return &readCloser{Reader: bytes.NewReader(data)}
 }
 
 func (r *readCloser) Close() error {
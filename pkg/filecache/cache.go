@@ -8,60 +8,385 @@ import (
 
 // FileInfo 文件信息
 type FileInfo struct {
-	Key        string    `json:"key"`         // 缓存键
-	Size       int64     `json:"size"`        // 文件大小
-	MimeType   string    `json:"mime_type"`   // MIME类型
-	CreatedAt  time.Time `json:"created_at"`  // 创建时间
-	ExpiresAt  time.Time `json:"expires_at"`  // 过期时间
-	AccessCount int64    `json:"access_count"` // 访问次数
-	LastAccess time.Time `json:"last_access"`  // 最后访问时间
+	Key         string    `json:"key"`               // 缓存键
+	Size        int64     `json:"size"`              // 文件大小
+	MimeType    string    `json:"mime_type"`         // MIME类型
+	CreatedAt   time.Time `json:"created_at"`        // 创建时间
+	ExpiresAt   time.Time `json:"expires_at"`        // 过期时间
+	AccessCount int64     `json:"access_count"`      // 访问次数
+	LastAccess  time.Time `json:"last_access"`       // 最后访问时间
+	Version     int64     `json:"version,omitempty"` // 版本号，支持条件写入的后端使用
+}
+
+// NoExpiry 作为Set的ttl传入时条目永不过期，只会被淘汰或删除，适合字体、带版本号的打包文件等固定资源；
+// 其他非正数的ttl仍表示使用默认TTL
+const NoExpiry time.Duration = -1
+
+// neverExpires 永不过期条目的过期时间
+var neverExpires = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// expiryFor 返回从now开始经过ttl后的过期时间，ttl为NoExpiry时返回neverExpires
+func expiryFor(now time.Time, ttl time.Duration) time.Time {
+	if ttl == NoExpiry {
+		return neverExpires
+	}
+	return now.Add(ttl)
+}
+
+// remainingTTL 返回距expiresAt的剩余TTL，永不过期时返回NoExpiry，已过期时返回0和false
+func remainingTTL(expiresAt time.Time) (time.Duration, bool) {
+	if !expiresAt.Before(neverExpires) {
+		return NoExpiry, true
+	}
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return 0, false
+	}
+	return ttl, true
+}
+
+// NeverExpires 判断条目是否永不过期
+func (f *FileInfo) NeverExpires() bool {
+	return !f.ExpiresAt.Before(neverExpires)
+}
+
+// RemainingTTL 返回条目的剩余TTL，可直接传给Set以保留原有过期时间；
+// 永不过期的条目返回NoExpiry，已过期时返回0和false
+func (f *FileInfo) RemainingTTL() (time.Duration, bool) {
+	return remainingTTL(f.ExpiresAt)
 }
 
 // Cache 文件缓存接口
 type Cache interface {
 	// Set 存储文件到缓存
 	Set(ctx context.Context, key string, data io.Reader, mimeType string, ttl time.Duration) error
-	
+
 	// Get 从缓存获取文件
 	Get(ctx context.Context, key string) (io.ReadCloser, *FileInfo, error)
-	
+
 	// Exists 检查文件是否存在
 	Exists(ctx context.Context, key string) (bool, error)
-	
+
 	// Delete 删除文件
 	Delete(ctx context.Context, key string) error
-	
+
 	// List 列出所有缓存文件
 	List(ctx context.Context) ([]*FileInfo, error)
-	
+
 	// GetInfo 获取文件信息
 	GetInfo(ctx context.Context, key string) (*FileInfo, error)
-	
+
 	// Cleanup 清理过期文件
 	Cleanup(ctx context.Context) error
-	
+
+	// Flush 删除所有缓存文件并重置统计信息
+	Flush(ctx context.Context) error
+
+	// Namespace 返回键空间和统计信息相互隔离的子缓存
+	// List、Cleanup、Flush 只作用于该命名空间
+	Namespace(name string) Cache
+
 	// Close 关闭缓存
 	Close() error
-	
+
 	// Stats 获取缓存统计信息
 	Stats() (*Stats, error)
 }
 
+// CleanupOptions 增量清理选项，零值表示一次性清理整个键空间
+type CleanupOptions struct {
+	MaxEntries  int                  // 单次最多扫描的条目数，0表示不限制
+	MaxDuration time.Duration        // 单次最长运行时间，0表示不限制
+	BatchSize   int                  // 每个删除事务包含的条目数，默认100
+	Cursor      string               // 从该键之后继续扫描，取自上一次的CleanupResult.Cursor
+	Progress    func(*CleanupResult) // 每提交一批后回调，可用于上报进度
+}
+
+// CleanupResult 清理结果
+type CleanupResult struct {
+	Scanned        int64  `json:"scanned"`         // 扫描的条目数
+	Removed        int64  `json:"removed"`         // 删除的过期条目数
+	BytesReclaimed int64  `json:"bytes_reclaimed"` // 回收的字节数
+	TrashPurged    int64  `json:"trash_purged"`    // 永久删除的回收站条目数
+	Orphans        int64  `json:"orphans"`         // 删除的不成对记录和文件数，只在完整扫描一轮后检查
+	Cursor         string `json:"cursor"`          // 下一次扫描的起点
+	Done           bool   `json:"done"`            // 是否已扫描完整个键空间
+}
+
+// IncrementalCleaner 支持分片清理的缓存
+type IncrementalCleaner interface {
+	// CleanupWithOptions 按选项清理过期文件并返回清理结果
+	CleanupWithOptions(ctx context.Context, opts CleanupOptions) (*CleanupResult, error)
+}
+
+// TrashInfo 回收站条目信息
+type TrashInfo struct {
+	FileInfo
+	DeletedAt time.Time `json:"deleted_at"` // 删除时间
+	PurgeAt   time.Time `json:"purge_at"`   // 永久删除时间
+}
+
+// SoftDeleter 支持软删除的缓存
+type SoftDeleter interface {
+	// Restore 从回收站恢复文件，同名的文件已经存在时返回ErrExists
+	Restore(ctx context.Context, key string) error
+
+	// ListTrash 列出回收站中的文件
+	ListTrash(ctx context.Context) ([]*TrashInfo, error)
+}
+
+// KeyRotator 支持轮换静态加密密钥的缓存
+type KeyRotator interface {
+	// RotateEncryptionKey 将加密密钥轮换为newKey（十六进制编码）
+	RotateEncryptionKey(ctx context.Context, newKey string) error
+}
+
+// ConditionalSetter 支持条件写入的缓存
+type ConditionalSetter interface {
+	// SetNX 仅在键不存在或已过期时写入，返回是否写入
+	SetNX(ctx context.Context, key string, data io.Reader, mimeType string, ttl time.Duration) (bool, error)
+
+	// CompareAndSwap 仅在当前版本等于version（取自FileInfo.Version）时写入，返回是否写入
+	CompareAndSwap(ctx context.Context, key string, version int64, data io.Reader, mimeType string, ttl time.Duration) (bool, error)
+}
+
+// Snapshotter 支持在线快照的缓存，快照包含所有命名空间
+type Snapshotter interface {
+	// Snapshot 把版本号大于since的数据写入w，返回下一次增量快照使用的since，since为0时为全量快照
+	Snapshot(ctx context.Context, w io.Writer, since uint64) (uint64, error)
+
+	// RestoreSnapshot 用快照替换缓存中的全部数据
+	RestoreSnapshot(ctx context.Context, r io.Reader) error
+}
+
+// StaleGetter 支持读取已过期但尚未清理的文件的缓存，用于在刷新期间继续提供旧内容
+type StaleGetter interface {
+	// GetStale 与Get相同，但文件已过期时仍然返回，调用方根据FileInfo.ExpiresAt判断是否过期
+	GetStale(ctx context.Context, key string) (io.ReadCloser, *FileInfo, error)
+}
+
+// Peeker 能够只读地读取文件的缓存，用于构建Merkle树、迁移等后台扫描
+type Peeker interface {
+	// Peek 与GetStale相同，但不更新命中率、访问次数、最后访问时间和淘汰顺序，调用方根据FileInfo.ExpiresAt判断是否过期
+	Peek(ctx context.Context, key string) (io.ReadCloser, *FileInfo, error)
+}
+
+// Compactor 支持手动压缩存储的缓存
+type Compactor interface {
+	// Compact 合并存储文件并回收已删除或过期数据占用的磁盘空间
+	Compact(ctx context.Context) error
+}
+
+// NamespaceLister 能够列出子命名空间的缓存，用于迁移等需要遍历所有命名空间的操作
+type NamespaceLister interface {
+	// Namespaces 返回当前缓存中打开过的直接子命名空间的名称
+	Namespaces(ctx context.Context) ([]string, error)
+}
+
+// VerifyOptions 一致性检查选项
+type VerifyOptions struct {
+	// Repair 修复能够修复的问题：删除不成对或无法读取的记录，按实际数据修正FileInfo的大小，重新计算统计信息
+	Repair bool
+}
+
+// ProblemKind 一致性检查发现的问题类型
+type ProblemKind string
+
+const (
+	ProblemCorruptInfo     ProblemKind = "corrupt_info"     // FileInfo无法读取或解析
+	ProblemMissingData     ProblemKind = "missing_data"     // 有FileInfo但没有文件数据
+	ProblemOrphanedData    ProblemKind = "orphaned_data"    // 有文件数据但没有FileInfo，包括写入中断留下的临时文件
+	ProblemUnreadableData  ProblemKind = "unreadable_data"  // 文件数据无法读取或校验和不一致
+	ProblemSizeMismatch    ProblemKind = "size_mismatch"    // 文件数据的大小与FileInfo不一致
+	ProblemStatsMismatch   ProblemKind = "stats_mismatch"   // 统计信息中的文件数或总大小与实际不一致
+	ProblemChecksumFailure ProblemKind = "checksum_failure" // 存储文件（Badger的SST）校验失败，无法自动修复
+)
+
+// VerifyProblem 一致性检查发现的问题
+type VerifyProblem struct {
+	Namespace string       `json:"namespace,omitempty"` // 命名空间，嵌套的命名空间以/连接，根命名空间为空
+	Key       string       `json:"key,omitempty"`       // 条目的键，与单个条目无关的问题为空
+	Trash     bool         `json:"trash,omitempty"`     // 问题出在回收站中的条目
+	Kind      ProblemKind  `json:"kind"`
+	Detail    string       `json:"detail"`
+	Action    RepairAction `json:"action,omitempty"` // 修复方式，不修复时为可以执行的修复，无法自动修复时为空
+	Repaired  bool         `json:"repaired"`         // 是否已修复
+}
+
+// VerifyReport 一致性检查的结果
+type VerifyReport struct {
+	Entries  int64           `json:"entries"`  // 检查的条目数，包括回收站中的条目
+	Bytes    int64           `json:"bytes"`    // 读取并校验的文件数据字节数
+	Problems []VerifyProblem `json:"problems"` // 发现的问题
+}
+
+// Unrepaired 返回尚未修复的问题数
+func (r *VerifyReport) Unrepaired() int {
+	n := 0
+	for _, p := range r.Problems {
+		if !p.Repaired {
+			n++
+		}
+	}
+	return n
+}
+
+// Verifier 支持一致性检查的缓存
+type Verifier interface {
+	// Verify 检查当前命名空间及其所有子命名空间的每个条目：FileInfo与文件数据成对、文件数据可以完整读取且校验和一致、
+	// 大小与FileInfo一致，以及统计信息与实际条目一致
+	Verify(ctx context.Context, opts VerifyOptions) (*VerifyReport, error)
+}
+
+// RepairAction 一致性问题的修复方式
+type RepairAction string
+
+const (
+	RepairRemove  RepairAction = "remove"  // 删除不成对或无法读取的记录和文件
+	RepairRebuild RepairAction = "rebuild" // 按文件数据重建缺失或无法解析的FileInfo
+	RepairResize  RepairAction = "resize"  // 按实际数据修正FileInfo的大小
+	RepairRecount RepairAction = "recount" // 按实际条目重新计算统计信息
+)
+
+// RepairOptions 修复选项
+type RepairOptions struct {
+	// DryRun 只检查并报告将要执行的修复，不修改数据
+	DryRun bool
+	// TTL 重建的FileInfo的有效期，从修复时开始计算；为0时按TTL策略和DefaultTTL，NoExpiry表示永不过期
+	TTL time.Duration
+}
+
+// RepairReport 修复的结果，Problems中每个问题的Action为执行（DryRun时为将要执行）的修复
+type RepairReport struct {
+	VerifyReport
+	Rebuilt int64 `json:"rebuilt"` // 重建的FileInfo数
+	Resized int64 `json:"resized"` // 修正大小的FileInfo数
+	Removed int64 `json:"removed"` // 删除的记录和孤立文件数
+}
+
+// Repairer 支持修复的缓存
+type Repairer interface {
+	// Repair 检查当前命名空间及其所有子命名空间并修复发现的问题：能够完整读取文件数据的条目按数据重建FileInfo
+	// （大小、MIME类型和过期时间），无法读取的条目删除，最后按实际条目重新计算统计信息
+	Repair(ctx context.Context, opts RepairOptions) (*RepairReport, error)
+}
+
+// DiskUsage 缓存占用的磁盘空间和所在文件系统的容量（字节）
+type DiskUsage struct {
+	LSM       int64 `json:"lsm"`        // Badger的LSM树（SST文件）
+	ValueLog  int64 `json:"value_log"`  // Badger的value log
+	DiskTotal int64 `json:"disk_total"` // 数据目录所在文件系统的容量，当前平台不支持时为0
+	DiskFree  int64 `json:"disk_free"`  // 数据目录所在文件系统的可用空间，当前平台不支持时为0
+}
+
+// DiskUsageReporter 能够报告磁盘占用的缓存
+type DiskUsageReporter interface {
+	// DiskUsage 返回存储占用的磁盘空间，由所有命名空间共享；LSM和ValueLog由Badger定期更新，可能滞后约一分钟
+	DiskUsage() (*DiskUsage, error)
+}
+
+// PointInTimeRestorer 支持从定时备份恢复到指定时间的缓存
+type PointInTimeRestorer interface {
+	// RestoreToTime 依次加载t之前最近的全量备份及其后的增量备份，恢复缓存在t时的状态
+	RestoreToTime(ctx context.Context, t time.Time) error
+}
+
 // Stats 缓存统计信息
 type Stats struct {
-	TotalFiles    int64   `json:"total_files"`     // 总文件数
-	TotalSize     int64   `json:"total_size"`      // 总大小（字节）
-	HitRate       float64 `json:"hit_rate"`        // 命中率
-	MissRate      float64 `json:"miss_rate"`       // 未命中率
-	ExpiredFiles  int64   `json:"expired_files"`   // 过期文件数
-	LastCleanup   time.Time `json:"last_cleanup"`   // 最后清理时间
+	TotalFiles   int64     `json:"total_files"`   // 总文件数
+	TotalSize    int64     `json:"total_size"`    // 总大小（字节）
+	HitRate      float64   `json:"hit_rate"`      // 命中率
+	MissRate     float64   `json:"miss_rate"`     // 未命中率
+	ExpiredFiles int64     `json:"expired_files"` // 过期文件数
+	Evictions    int64     `json:"evictions"`     // 因配额等原因被淘汰的文件数
+	LastCleanup  time.Time `json:"last_cleanup"`  // 最后清理时间
+	LastBackup   time.Time `json:"last_backup"`   // 最后一次成功备份的时间
 }
 
 // Config 缓存配置
 type Config struct {
-	DataDir        string        `json:"data_dir"`         // 数据目录
-	MaxCacheSize   int64         `json:"max_cache_size"`    // 最大缓存大小（字节）
-	DefaultTTL     time.Duration `json:"default_ttl"`       // 默认TTL
+	DataDir         string        `json:"data_dir"`         // 数据目录
+	MaxCacheSize    int64         `json:"max_cache_size"`   // 最大缓存大小（字节）
+	MaxEntrySize    int64         `json:"max_entry_size"`   // 单个文件最大大小（字节），0表示与MaxCacheSize相同
+	DefaultTTL      time.Duration `json:"default_ttl"`      // 默认TTL
 	CleanupInterval time.Duration `json:"cleanup_interval"` // 清理间隔
-	Compression    bool          `json:"compression"`       // 是否压缩
+	Compression     bool          `json:"compression"`      // 是否压缩
+	SoftDelete      bool          `json:"soft_delete"`      // 删除时移入回收站而不是直接删除
+	TrashRetention  time.Duration `json:"trash_retention"`  // 回收站保留时间，默认24小时
+	StaleRetention  time.Duration `json:"stale_retention"`  // 过期文件在清理前保留的时间，期间可以通过GetStale读取
+
+	// TTLPolicies 写入时没有指定TTL（ttl<=0）的文件按顺序使用第一条匹配键或MIME类型的规则的TTL，
+	// 例如.css/.js文件7天、application/json 30秒，都不匹配时使用DefaultTTL
+	TTLPolicies []TTLPolicy `json:"ttl_policies,omitempty"`
+
+	// TTLJitter 写入时把每个文件的TTL随机缩短最多这个比例（0到1之间），例如0.1表示在[0.9*TTL, TTL]内随机，
+	// 避免一起写入的文件（例如预热）同时过期、同时回源；0表示不抖动
+	TTLJitter float64 `json:"ttl_jitter,omitempty"`
+
+	// TTLJitterMax 随机缩短的最大时长；与TTLJitter同时设置时作为上限，只设置它时在[TTL-TTLJitterMax, TTL]内随机，
+	// 最多缩短TTL的一半
+	TTLJitterMax time.Duration `json:"ttl_jitter_max,omitempty"`
+
+	// EncryptionKey 十六进制编码的AES密钥（32/48/64个字符，对应AES-128/192/256），为空时不加密
+	EncryptionKey string `json:"encryption_key,omitempty"`
+
+	// MmapMinSize 大于0时，文件系统后端（NewFSBlobCache）中不小于这个大小、且访问次数达到MmapMinAccesses的文件
+	// 通过共享的只读内存映射读取，并发读取直接使用同一份页缓存；0表示不使用
+	MmapMinSize int64 `json:"mmap_min_size,omitempty"`
+
+	// MmapMinAccesses 文件使用内存映射读取需要的访问次数（包括本次），默认2
+	MmapMinAccesses int64 `json:"mmap_min_accesses,omitempty"`
+
+	// EvictHighWatermark 大于0时启用按水位淘汰：所有命名空间的总大小达到MaxCacheSize的这个比例，或者数据目录所在磁盘的
+	// 使用量达到容量的这个比例时，后台协程淘汰文件直到降到EvictLowWatermark，例如0.9；0表示不启用
+	EvictHighWatermark float64 `json:"evict_high_watermark,omitempty"`
+
+	// EvictLowWatermark 淘汰的目标水位，必须小于EvictHighWatermark，0表示比EvictHighWatermark低0.1
+	EvictLowWatermark float64 `json:"evict_low_watermark,omitempty"`
+
+	// EvictInterval 检查水位的间隔，默认10秒
+	EvictInterval time.Duration `json:"evict_interval,omitempty"`
+
+	// NamespaceQuotas 各命名空间的容量配额（字节），键为命名空间的完整路径，嵌套的命名空间用"/"连接各级名称
+	// （例如 cache.Namespace("site").Namespace("img") 对应"site/img"），空字符串表示根命名空间；
+	// 配额只限制该命名空间自身的文件，不包括子命名空间
+	NamespaceQuotas map[string]int64 `json:"namespace_quotas,omitempty"`
+
+	// Backup 定时把快照上传到S3，为空时不备份
+	Backup *BackupConfig `json:"backup,omitempty"`
+
+	// APIKeys 管理接口的访问密钥及其角色
+	APIKeys []APIKey `json:"api_keys,omitempty"`
+}
+
+// AdminRole 管理接口的角色，权限依次递增
+type AdminRole string
+
+const (
+	RoleReadOnly AdminRole = "read-only" // 列出、读取文件和查看统计信息
+	RolePurge    AdminRole = "purge"     // 另外可以删除文件、按前缀或标签清除，但不能清除全部文件
+	RoleAdmin    AdminRole = "admin"     // 全部操作，包括写入、清理、压缩和清除全部文件
+)
+
+// APIKey 管理接口的访问密钥，请求携带 Authorization: Bearer <Key>
+type APIKey struct {
+	Name string    `json:"name"` // 名称，用于区分持有者
+	Key  string    `json:"key"`  // 密钥
+	Role AdminRole `json:"role"` // 角色
+}
+
+// BackupConfig 定时备份配置
+type BackupConfig struct {
+	Interval    time.Duration `json:"interval"`    // 备份间隔
+	Destination string        `json:"destination"` // 备份位置，格式为 s3://bucket/prefix/
+	Retention   time.Duration `json:"retention"`   // 备份保留时间，0表示不清理；最新的备份总是保留
+
+	// FullInterval 全量备份间隔，期间只上传增量备份，0表示每次都是全量备份
+	FullInterval time.Duration `json:"full_interval,omitempty"`
+
+	Endpoint        string `json:"endpoint,omitempty"`          // S3服务地址，默认 https://s3.<region>.amazonaws.com
+	Region          string `json:"region,omitempty"`            // 区域，默认us-east-1
+	AccessKeyID     string `json:"access_key_id,omitempty"`     // 访问密钥ID，为空时读取AWS_ACCESS_KEY_ID等环境变量
+	SecretAccessKey string `json:"secret_access_key,omitempty"` // 访问密钥
+	PathStyle       bool   `json:"path_style,omitempty"`        // 使用路径风格访问
 }
@@ -1,8 +1,13 @@
 package filecache
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -11,7 +16,7 @@ import (
 func TestBadgerCache(t *testing.T) {
 	// 创建测试配置
 	config := &Config{
-		DataDir:         "./test_cache",
+		DataDir:         t.TempDir(),
 		MaxCacheSize:    1024 * 1024, // 1MB
 		DefaultTTL:      time.Hour,
 		CleanupInterval: time.Minute,
@@ -67,6 +72,9 @@ func TestBadgerCache(t *testing.T) {
 	})
 
 	t.Run("List", func(t *testing.T) {
+		// 在单独的命名空间中列出，不包括 "Set and Get" 写入、"Delete" 要删除的 test-file.txt
+		cache := cache.Namespace("list")
+
 		// 添加一些测试文件
 		files := []struct {
 			key      string
@@ -173,6 +181,39 @@ func TestBadgerCache(t *testing.T) {
 		}
 	})
 
+	t.Run("Flush", func(t *testing.T) {
+		if err := cache.Set(ctx, "flush.txt", strings.NewReader("flush me"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+
+		if err := cache.Flush(ctx); err != nil {
+			t.Fatalf("Failed to flush: %v", err)
+		}
+
+		files, err := cache.List(ctx)
+		if err != nil {
+			t.Fatalf("Failed to list files: %v", err)
+		}
+		if len(files) != 0 {
+			t.Errorf("Expected no files after flush, got %d", len(files))
+		}
+
+		stats, err := cache.Stats()
+		if err != nil {
+			t.Fatalf("Failed to get stats: %v", err)
+		}
+		if stats.TotalFiles != 0 || stats.TotalSize != 0 {
+			t.Errorf("Expected stats to be reset, got %+v", stats)
+		}
+	})
+
+	t.Run("MaxEntrySize", func(t *testing.T) {
+		err := cache.Set(ctx, "too-large.bin", strings.NewReader(strings.Repeat("x", 1024*1024+1)), "application/octet-stream", time.Hour)
+		if !errors.Is(err, ErrEntryTooLarge) {
+			t.Errorf("Expected ErrEntryTooLarge, got %v", err)
+		}
+	})
+
 	t.Run("Expiration", func(t *testing.T) {
 		key := "expire-test.txt"
 		data := strings.NewReader("This will expire")
@@ -194,8 +235,8 @@ func TestBadgerCache(t *testing.T) {
 
 		// 获取应该失败
 		_, _, err = cache.Get(ctx, key)
-		if err == nil {
-			t.Error("Expected file to be expired")
+		if !errors.Is(err, ErrExpired) || !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrExpired matching ErrNotFound, got %v", err)
 		}
 
 		// 手动清理
@@ -206,6 +247,137 @@ func TestBadgerCache(t *testing.T) {
 	})
 }
 
+func TestBadgerCacheClose(t *testing.T) {
+	config := &Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Millisecond,
+		Compression:     true,
+	}
+
+	cache, err := NewBadgerCache(config)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := cache.Set(ctx, "close.txt", strings.NewReader("data"), "text/plain", time.Hour); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+
+	// 让清理协程运行几轮
+	time.Sleep(10 * time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() { done <- cache.Close() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Failed to close cache: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return")
+	}
+
+	// 重复关闭是安全的
+	if err := cache.Close(); err != nil {
+		t.Errorf("Second close should not error: %v", err)
+	}
+
+	// 统计信息在关闭时落盘
+	reopened, err := NewBadgerCache(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen cache: %v", err)
+	}
+	defer reopened.Close()
+
+	stats, err := reopened.Stats()
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	if stats.TotalFiles != 1 {
+		t.Errorf("Expected 1 file after reopen, got %d", stats.TotalFiles)
+	}
+}
+
+func TestIncrementalCleanup(t *testing.T) {
+	cache, err := NewBadgerCache(&Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("expired-%d", i)
+		if err := cache.Set(ctx, key, strings.NewReader("12345"), "text/plain", time.Millisecond); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		key := fmt.Sprintf("live-%d", i)
+		if err := cache.Set(ctx, key, strings.NewReader("12345"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	cleaner, ok := cache.(IncrementalCleaner)
+	if !ok {
+		t.Fatal("Badger cache should implement IncrementalCleaner")
+	}
+
+	var removed, reclaimed int64
+	var progressCalls int
+	opts := CleanupOptions{
+		MaxEntries: 3,
+		BatchSize:  2,
+		Progress:   func(*CleanupResult) { progressCalls++ },
+	}
+	for rounds := 0; ; rounds++ {
+		if rounds > 10 {
+			t.Fatal("Cleanup did not finish")
+		}
+		result, err := cleaner.CleanupWithOptions(ctx, opts)
+		if err != nil {
+			t.Fatalf("Failed to cleanup: %v", err)
+		}
+		if result.Scanned > 3 {
+			t.Errorf("Expected at most 3 scanned entries, got %d", result.Scanned)
+		}
+		removed += result.Removed
+		reclaimed += result.BytesReclaimed
+		if result.Done {
+			break
+		}
+		opts.Cursor = result.Cursor
+	}
+
+	if removed != 5 {
+		t.Errorf("Expected 5 removed entries, got %d", removed)
+	}
+	if reclaimed != 25 {
+		t.Errorf("Expected 25 bytes reclaimed, got %d", reclaimed)
+	}
+	if progressCalls == 0 {
+		t.Error("Expected progress to be reported")
+	}
+
+	files, err := cache.List(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list files: %v", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("Expected 2 live files, got %d", len(files))
+	}
+}
+
 func TestConfig(t *testing.T) {
 	t.Run("DefaultConfig", func(t *testing.T) {
 		config := DefaultConfig()
@@ -242,6 +414,13 @@ func TestConfig(t *testing.T) {
 			{DataDir: "./test", MaxCacheSize: 0},
 			{DataDir: "./test", MaxCacheSize: 1024, DefaultTTL: 0},
 			{DataDir: "./test", MaxCacheSize: 1024, DefaultTTL: time.Hour, CleanupInterval: 0},
+			{DataDir: "./test", MaxCacheSize: 1024, MaxEntrySize: 2048, DefaultTTL: time.Hour, CleanupInterval: time.Minute},
+			{DataDir: "./test", MaxCacheSize: 1024, DefaultTTL: time.Hour, CleanupInterval: time.Minute, APIKeys: []APIKey{{Name: "ops", Key: "k", Role: "root"}}},
+			{DataDir: "./test", MaxCacheSize: 1024, DefaultTTL: time.Hour, CleanupInterval: time.Minute, APIKeys: []APIKey{{Name: "ops", Role: RoleAdmin}}},
+			{DataDir: "./test", MaxCacheSize: 1024, DefaultTTL: time.Hour, CleanupInterval: time.Minute, APIKeys: []APIKey{{Name: "a", Key: "k", Role: RolePurge}, {Name: "b", Key: "k", Role: RoleReadOnly}}},
+			{DataDir: "./test", MaxCacheSize: 1024, DefaultTTL: time.Hour, CleanupInterval: time.Minute, EvictHighWatermark: 1.5},
+			{DataDir: "./test", MaxCacheSize: 1024, DefaultTTL: time.Hour, CleanupInterval: time.Minute, EvictHighWatermark: 0.8, EvictLowWatermark: 0.9},
+			{DataDir: "./test", MaxCacheSize: 1024, DefaultTTL: time.Hour, CleanupInterval: time.Minute, EvictHighWatermark: 0.05},
 		}
 
 		for i, config := range invalidConfigs {
@@ -250,4 +429,720 @@ func TestConfig(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("ValidateConfigPaths", func(t *testing.T) {
+		err := ValidateConfig(&Config{
+			DataDir:         "./test",
+			MaxCacheSize:    1024,
+			DefaultTTL:      -time.Hour,
+			CleanupInterval: time.Minute,
+			NamespaceQuotas: map[string]int64{"tenant": 2048},
+			APIKeys:         []APIKey{{Name: "ops", Key: "k", Role: "root"}},
+		})
+		if err == nil {
+			t.Fatal("Expected error for invalid config")
+		}
+		for _, want := range []string{"default_ttl: ", `namespace_quotas["tenant"]: `, "api_keys[0].role: "} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("Expected error for %q, got %v", want, err)
+			}
+		}
+	})
+}
+
+func TestSoftDelete(t *testing.T) {
+	cache, err := NewBadgerCache(&Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+		SoftDelete:      true,
+		TrashRetention:  50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	trash := cache.(SoftDeleter)
+
+	if err := cache.Set(ctx, "hot.css", strings.NewReader("body{}"), "text/css", time.Hour); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+	if err := cache.Delete(ctx, "hot.css"); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+
+	if exists, _ := cache.Exists(ctx, "hot.css"); exists {
+		t.Error("Expected file to be deleted")
+	}
+	records, err := trash.ListTrash(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list trash: %v", err)
+	}
+	if len(records) != 1 || records[0].Key != "hot.css" {
+		t.Fatalf("Expected hot.css in trash, got %+v", records)
+	}
+
+	// 恢复后内容完整
+	if err := trash.Restore(ctx, "hot.css"); err != nil {
+		t.Fatalf("Failed to restore file: %v", err)
+	}
+	reader, _, err := cache.Get(ctx, "hot.css")
+	if err != nil {
+		t.Fatalf("Failed to get restored file: %v", err)
+	}
+	content, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(content) != "body{}" {
+		t.Errorf("Expected 'body{}', got '%s'", string(content))
+	}
+
+	if err := trash.Restore(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	// 同名的文件已经重新写入时拒绝恢复，统计信息不重复计算
+	if err := cache.Delete(ctx, "hot.css"); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+	if err := cache.Set(ctx, "hot.css", strings.NewReader("new"), "text/css", time.Hour); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+	if err := trash.Restore(ctx, "hot.css"); !errors.Is(err, ErrExists) {
+		t.Errorf("Expected ErrExists, got %v", err)
+	}
+	if got := readString(t, cache, "hot.css"); got != "new" {
+		t.Errorf("Expected the new file to be kept, got %q", got)
+	}
+	if stats, _ := cache.Stats(); stats.TotalFiles != 1 || stats.TotalSize != 3 {
+		t.Errorf("Expected stats to count the new file once, got %d files %d bytes", stats.TotalFiles, stats.TotalSize)
+	}
+
+	// 超过保留时间后由Cleanup分批永久删除
+	for _, key := range []string{"hot.css", "a.css", "b.css"} {
+		if key != "hot.css" {
+			if err := cache.Set(ctx, key, strings.NewReader("a{}"), "text/css", time.Hour); err != nil {
+				t.Fatalf("Failed to set file: %v", err)
+			}
+		}
+		if err := cache.Delete(ctx, key); err != nil {
+			t.Fatalf("Failed to delete file: %v", err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	result, err := cache.(IncrementalCleaner).CleanupWithOptions(ctx, CleanupOptions{BatchSize: 2})
+	if err != nil {
+		t.Fatalf("Failed to cleanup: %v", err)
+	}
+	if result.TrashPurged != 3 {
+		t.Errorf("Expected 3 purged trash entries, got %d", result.TrashPurged)
+	}
+	if records, _ := trash.ListTrash(ctx); len(records) != 0 {
+		t.Errorf("Expected the trash to be empty, got %d records", len(records))
+	}
+	if err := trash.Restore(ctx, "hot.css"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected purged file to be unrecoverable, got %v", err)
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	cache, err := NewBadgerCache(&Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	siteA := cache.Namespace("site-a")
+	siteB := cache.Namespace("site-b")
+
+	if cache.Namespace("site-a") != siteA {
+		t.Error("Expected the same namespace instance for the same name")
+	}
+
+	// 同一个键在不同命名空间互不冲突
+	for name, c := range map[string]Cache{"root": cache, "site-a": siteA, "site-b": siteB} {
+		if err := c.Set(ctx, "index.html", strings.NewReader(name), "text/html", time.Hour); err != nil {
+			t.Fatalf("Failed to set file in %s: %v", name, err)
+		}
+	}
+	reader, _, err := siteA.Get(ctx, "index.html")
+	if err != nil {
+		t.Fatalf("Failed to get file: %v", err)
+	}
+	content, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(content) != "site-a" {
+		t.Errorf("Expected 'site-a', got '%s'", string(content))
+	}
+
+	if err := siteA.Set(ctx, "app.js", strings.NewReader("js"), "text/javascript", time.Hour); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+
+	// List 和 Stats 只覆盖当前命名空间
+	files, err := siteA.List(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list files: %v", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("Expected 2 files in site-a, got %d", len(files))
+	}
+	files, err = cache.List(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list files: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("Expected 1 file in root, got %d", len(files))
+	}
+	stats, _ := siteB.Stats()
+	if stats.TotalFiles != 1 {
+		t.Errorf("Expected 1 file in site-b stats, got %d", stats.TotalFiles)
+	}
+
+	// 只列出直接子命名空间
+	siteA.Namespace("nested")
+	names, err := cache.(NamespaceLister).Namespaces(ctx)
+	if err != nil || strings.Join(names, ",") != "site-a,site-b" {
+		t.Errorf("Expected namespaces site-a and site-b, got %v %v", names, err)
+	}
+
+	// Flush 不影响其他命名空间
+	if err := siteA.Flush(ctx); err != nil {
+		t.Fatalf("Failed to flush namespace: %v", err)
+	}
+	if exists, _ := siteA.Exists(ctx, "index.html"); exists {
+		t.Error("Expected site-a to be flushed")
+	}
+	if exists, _ := siteB.Exists(ctx, "index.html"); !exists {
+		t.Error("Expected site-b to be untouched by site-a flush")
+	}
+	if exists, _ := cache.Exists(ctx, "index.html"); !exists {
+		t.Error("Expected root to be untouched by site-a flush")
+	}
+	// 后台清理覆盖所有已注册的命名空间
+	if err := siteB.Set(ctx, "old.html", strings.NewReader("old"), "text/html", time.Millisecond); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := cache.(*badgerCache).cleanupAll(ctx); err != nil {
+		t.Fatalf("Failed to cleanup: %v", err)
+	}
+	if exists, _ := siteB.Exists(ctx, "old.html"); exists {
+		t.Error("Expected expired file in site-b to be cleaned up")
+	}
+}
+
+func TestNamespaceQuota(t *testing.T) {
+	cache, err := NewBadgerCache(&Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+		NamespaceQuotas: map[string]int64{"tenant-a": 100},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	tenantA := cache.Namespace("tenant-a")
+	tenantB := cache.Namespace("tenant-b")
+
+	payload := strings.Repeat("x", 40)
+	for _, key := range []string{"a1", "a2"} {
+		if err := tenantA.Set(ctx, key, strings.NewReader(payload), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set %s: %v", key, err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := tenantB.Set(ctx, "b1", strings.NewReader(strings.Repeat("y", 200)), "text/plain", time.Hour); err != nil {
+		t.Fatalf("Tenant B should not be limited by tenant A quota: %v", err)
+	}
+
+	// 访问a1使a2成为最久未访问的文件
+	reader, _, err := tenantA.Get(ctx, "a1")
+	if err != nil {
+		t.Fatalf("Failed to get a1: %v", err)
+	}
+	reader.Close()
+
+	// 覆盖写入不重复计算空间
+	if err := tenantA.Set(ctx, "a1", strings.NewReader(payload), "text/plain", time.Hour); err != nil {
+		t.Fatalf("Failed to overwrite a1: %v", err)
+	}
+	if stats, _ := tenantA.Stats(); stats.TotalSize != 80 {
+		t.Errorf("Expected 80 bytes used, got %d", stats.TotalSize)
+	}
+
+	// 超出配额时淘汰本命名空间中最久未访问的文件
+	if err := tenantA.Set(ctx, "a3", strings.NewReader(payload), "text/plain", time.Hour); err != nil {
+		t.Fatalf("Failed to set a3: %v", err)
+	}
+	if exists, _ := tenantA.Exists(ctx, "a2"); exists {
+		t.Error("Expected a2 to be evicted")
+	}
+	if exists, _ := tenantA.Exists(ctx, "a1"); !exists {
+		t.Error("Expected a1 to survive eviction")
+	}
+	if exists, _ := tenantB.Exists(ctx, "b1"); !exists {
+		t.Error("Expected tenant B to be untouched")
+	}
+	stats, _ := tenantA.Stats()
+	if stats.Evictions != 1 {
+		t.Errorf("Expected 1 eviction, got %d", stats.Evictions)
+	}
+
+	// 单个文件超过配额时返回配额错误
+	err = tenantA.Set(ctx, "huge", strings.NewReader(strings.Repeat("z", 101)), "text/plain", time.Hour)
+	var quotaErr *QuotaError
+	if !errors.As(err, &quotaErr) || !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Expected QuotaError, got %v", err)
+	}
+	if quotaErr.Namespace != "tenant-a" || quotaErr.Quota != 100 {
+		t.Errorf("Unexpected quota error: %+v", quotaErr)
+	}
+}
+
+func TestNestedNamespaceQuota(t *testing.T) {
+	cache, err := NewBadgerCache(&Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+		NamespaceQuotas: map[string]int64{"site-a/img": 100},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	payload := strings.NewReader(strings.Repeat("x", 101))
+
+	// 配额按完整路径匹配，其他父命名空间下的同名子命名空间和顶层的同名命名空间不受限制
+	for _, ns := range []Cache{cache.Namespace("site-b").Namespace("img"), cache.Namespace("img"), cache.Namespace("site-a")} {
+		payload.Seek(0, io.SeekStart)
+		if err := ns.Set(ctx, "big", payload, "text/plain", time.Hour); err != nil {
+			t.Errorf("Expected namespaces without a quota to be unlimited, got %v", err)
+		}
+	}
+
+	payload.Seek(0, io.SeekStart)
+	err = cache.Namespace("site-a").Namespace("img").Set(ctx, "big", payload, "text/plain", time.Hour)
+	var quotaErr *QuotaError
+	if !errors.As(err, &quotaErr) || quotaErr.Namespace != "site-a/img" {
+		t.Errorf("Expected the quota of site-a/img to apply, got %v", err)
+	}
+}
+
+func TestEncryption(t *testing.T) {
+	dir := t.TempDir()
+	config := &Config{
+		DataDir:         dir,
+		MaxCacheSize:    1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+		EncryptionKey:   strings.Repeat("ab", 32),
+	}
+
+	cache, err := NewBadgerCache(config)
+	if err != nil {
+		t.Fatalf("Failed to create encrypted cache: %v", err)
+	}
+	ctx := context.Background()
+	secret := "customer-secret-payload"
+	if err := cache.Set(ctx, "secret.txt", strings.NewReader(secret), "text/plain", time.Hour); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+	if err := cache.Close(); err != nil {
+		t.Fatalf("Failed to close cache: %v", err)
+	}
+
+	// 磁盘上不应出现明文
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if bytes.Contains(raw, []byte(secret)) || bytes.Contains(raw, []byte("secret.txt")) {
+			t.Errorf("Found plaintext in %s", path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to walk data dir: %v", err)
+	}
+
+	// 错误的密钥无法打开
+	wrong := *config
+	wrong.EncryptionKey = strings.Repeat("cd", 32)
+	if c, err := NewBadgerCache(&wrong); err == nil {
+		c.Close()
+		t.Fatal("Expected opening with wrong key to fail")
+	}
+
+	reopened, err := NewBadgerCache(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen encrypted cache: %v", err)
+	}
+	defer reopened.Close()
+	reader, _, err := reopened.Get(ctx, "secret.txt")
+	if err != nil {
+		t.Fatalf("Failed to get file: %v", err)
+	}
+	content, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(content) != secret {
+		t.Errorf("Expected '%s', got '%s'", secret, string(content))
+	}
+
+	invalid := *config
+	invalid.EncryptionKey = "not-hex"
+	if err := ValidateConfig(&invalid); err == nil {
+		t.Error("Expected invalid encryption key to fail validation")
+	}
+}
+
+func TestRotateEncryptionKey(t *testing.T) {
+	oldKey := strings.Repeat("ab", 32)
+	newKey := strings.Repeat("cd", 16)
+	config := &Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+		EncryptionKey:   oldKey,
+	}
+
+	cache, err := NewBadgerCache(config)
+	if err != nil {
+		t.Fatalf("Failed to create encrypted cache: %v", err)
+	}
+	ctx := context.Background()
+	site := cache.Namespace("site")
+	if err := site.Set(ctx, "a.txt", strings.NewReader("before"), "text/plain", time.Hour); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+
+	rotator := cache.(KeyRotator)
+	if err := rotator.RotateEncryptionKey(ctx, "zz"); err == nil {
+		t.Error("Expected invalid key to be rejected")
+	}
+	if err := rotator.RotateEncryptionKey(ctx, newKey); err != nil {
+		t.Fatalf("Failed to rotate key: %v", err)
+	}
+	if got := cache.(*badgerCache).config.Load().EncryptionKey; got != newKey {
+		t.Errorf("Expected the cache config to carry the new key, got %q", got)
+	}
+
+	// 轮换后已有实例继续可用
+	if err := site.Set(ctx, "b.txt", strings.NewReader("after"), "text/plain", time.Hour); err != nil {
+		t.Fatalf("Failed to set file after rotation: %v", err)
+	}
+	if exists, _ := site.Exists(ctx, "a.txt"); !exists {
+		t.Error("Expected a.txt to survive rotation")
+	}
+	if err := cache.Close(); err != nil {
+		t.Fatalf("Failed to close cache: %v", err)
+	}
+
+	old := *config
+	old.EncryptionKey = oldKey
+	if c, err := NewBadgerCache(&old); err == nil {
+		c.Close()
+		t.Fatal("Expected old key to be rejected after rotation")
+	}
+
+	if config.EncryptionKey != oldKey {
+		t.Fatalf("Expected the caller's config not to be modified")
+	}
+	rotated := *config
+	rotated.EncryptionKey = newKey
+	reopened, err := NewBadgerCache(&rotated)
+	if err != nil {
+		t.Fatalf("Failed to reopen with new key: %v", err)
+	}
+	defer reopened.Close()
+	for _, key := range []string{"a.txt", "b.txt"} {
+		if exists, _ := reopened.Namespace("site").Exists(ctx, key); !exists {
+			t.Errorf("Expected %s to exist after reopen", key)
+		}
+	}
+}
+
+func TestMaxEntrySize(t *testing.T) {
+	cache, err := NewBadgerCache(&Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1024 * 1024,
+		MaxEntrySize:    10,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	if err := cache.Set(ctx, "small", strings.NewReader("0123456789"), "text/plain", time.Hour); err != nil {
+		t.Errorf("Expected entry at the limit to be accepted: %v", err)
+	}
+	err = cache.Set(ctx, "large", strings.NewReader("0123456789a"), "text/plain", time.Hour)
+	if !errors.Is(err, ErrEntryTooLarge) {
+		t.Errorf("Expected ErrEntryTooLarge, got %v", err)
+	}
+
+	// 超大的输入不会整体读入内存
+	endless := &countingReader{}
+	if err := cache.Set(ctx, "endless", endless, "text/plain", time.Hour); !errors.Is(err, ErrEntryTooLarge) {
+		t.Errorf("Expected ErrEntryTooLarge for an endless reader, got %v", err)
+	}
+	if endless.n > 11 {
+		t.Errorf("Expected at most 11 bytes to be read, got %d", endless.n)
+	}
+}
+
+// countingReader 无限返回数据并记录读取的字节数
+type countingReader struct {
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'x'
+	}
+	r.n += int64(len(p))
+	return len(p), nil
+}
+
+func TestCompact(t *testing.T) {
+	config := &Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    16 * 1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+	}
+	cache, err := NewBadgerCache(config)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	payload := strings.Repeat("x", 64*1024)
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("file-%02d", i)
+		if err := cache.Set(ctx, key, strings.NewReader(payload), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		if i%2 == 0 {
+			if err := cache.Delete(ctx, key); err != nil {
+				t.Fatalf("Failed to delete file: %v", err)
+			}
+		}
+	}
+
+	compactor, ok := cache.(Compactor)
+	if !ok {
+		t.Fatal("Expected badger cache to implement Compactor")
+	}
+	if err := compactor.Compact(ctx); err != nil {
+		t.Fatalf("Failed to compact: %v", err)
+	}
+
+	files, err := cache.List(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list files: %v", err)
+	}
+	if len(files) != 25 {
+		t.Errorf("Expected 25 files after compaction, got %d", len(files))
+	}
+}
+
+func TestGetStale(t *testing.T) {
+	cache, err := NewBadgerCache(&Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+		StaleRetention:  time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	if err := cache.Set(ctx, "old", strings.NewReader("stale data"), "text/plain", 10*time.Millisecond); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	if _, _, err := cache.Get(ctx, "old"); err == nil {
+		t.Error("Expected Get to fail for expired file")
+	}
+	getter, ok := cache.(StaleGetter)
+	if !ok {
+		t.Fatal("Expected badger cache to implement StaleGetter")
+	}
+	reader, info, err := getter.GetStale(ctx, "old")
+	if err != nil {
+		t.Fatalf("Failed to get stale file: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(data) != "stale data" || !time.Now().After(info.ExpiresAt) {
+		t.Errorf("Expected expired file with data, got %q expiring at %v", data, info.ExpiresAt)
+	}
+
+	// 保留期内清理不删除过期文件
+	if err := cache.Cleanup(ctx); err != nil {
+		t.Fatalf("Failed to cleanup: %v", err)
+	}
+	if _, _, err := getter.GetStale(ctx, "old"); err != nil {
+		t.Errorf("Expected stale file to survive cleanup within retention, got %v", err)
+	}
+	if _, _, err := getter.GetStale(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	memory := NewMemoryCache(1024).(StaleGetter)
+	memory.(Cache).Set(ctx, "old", strings.NewReader("m"), "text/plain", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, _, err := memory.(Cache).Get(ctx, "old"); !errors.Is(err, ErrExpired) {
+		t.Errorf("Expected ErrExpired from the memory cache, got %v", err)
+	}
+	if _, _, err := memory.GetStale(ctx, "old"); err != nil {
+		t.Errorf("Expected memory cache to return stale file, got %v", err)
+	}
+}
+
+func TestPeek(t *testing.T) {
+	cache, err := NewBadgerCache(&Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	for _, c := range []Cache{cache, NewMemoryCache(1024)} {
+		if err := c.Set(ctx, "file", strings.NewReader("data"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		reader, info, err := c.(Peeker).Peek(ctx, "file")
+		if err != nil {
+			t.Fatalf("Failed to peek file: %v", err)
+		}
+		data, _ := io.ReadAll(reader)
+		reader.Close()
+		if string(data) != "data" || info.Size != 4 {
+			t.Errorf("Expected the file to be returned, got %q %+v", data, info)
+		}
+		if _, _, err := c.(Peeker).Peek(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+
+		// 只读访问不更新访问信息和命中率
+		if info, _ := c.GetInfo(ctx, "file"); info.AccessCount != 0 {
+			t.Errorf("Expected the access count to be unchanged, got %d", info.AccessCount)
+		}
+		if stats, _ := c.Stats(); stats.HitRate != 0 || stats.MissRate != 0 {
+			t.Errorf("Expected the hit rate to be unchanged, got %+v", stats)
+		}
+	}
+}
+
+func TestNoExpiry(t *testing.T) {
+	ctx := context.Background()
+	badger, err := NewBadgerCache(&Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1 << 20,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+		TTLJitter:       0.5,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer badger.Close()
+	memory := NewMemoryCache(1 << 20)
+	defer memory.Close()
+
+	for name, cache := range map[string]Cache{"badger": badger, "memory": memory} {
+		t.Run(name, func(t *testing.T) {
+			if err := cache.Set(ctx, "fonts/app.woff2", strings.NewReader("font"), "font/woff2", NoExpiry); err != nil {
+				t.Fatalf("Failed to set pinned entry: %v", err)
+			}
+			if err := cache.Set(ctx, "default", strings.NewReader("x"), "text/plain", -time.Second); err != nil {
+				t.Fatalf("Failed to set entry: %v", err)
+			}
+
+			info, err := cache.GetInfo(ctx, "fonts/app.woff2")
+			if err != nil {
+				t.Fatalf("Failed to get info: %v", err)
+			}
+			if !info.NeverExpires() || !info.ExpiresAt.Equal(neverExpires) {
+				t.Errorf("Expected pinned entry to never expire, got %v", info.ExpiresAt)
+			}
+			if ttl, ok := info.RemainingTTL(); !ok || ttl != NoExpiry {
+				t.Errorf("Expected remaining ttl NoExpiry, got %v, %v", ttl, ok)
+			}
+
+			info, err = cache.GetInfo(ctx, "default")
+			if err != nil {
+				t.Fatalf("Failed to get info: %v", err)
+			}
+			if info.NeverExpires() {
+				t.Error("Expected negative ttl other than NoExpiry to use the default TTL")
+			}
+
+			if err := cache.Cleanup(ctx); err != nil {
+				t.Fatalf("Failed to cleanup: %v", err)
+			}
+			if exists, _ := cache.Exists(ctx, "fonts/app.woff2"); !exists {
+				t.Error("Expected pinned entry to survive cleanup")
+			}
+		})
+	}
+
+	t.Run("migrate", func(t *testing.T) {
+		dst := NewMemoryCache(1 << 20)
+		defer dst.Close()
+		if _, err := Migrate(ctx, badger, dst, MigrateOptions{}); err != nil {
+			t.Fatalf("Failed to migrate: %v", err)
+		}
+		info, err := dst.GetInfo(ctx, "fonts/app.woff2")
+		if err != nil {
+			t.Fatalf("Failed to get info: %v", err)
+		}
+		if !info.NeverExpires() {
+			t.Errorf("Expected migrated entry to never expire, got %v", info.ExpiresAt)
+		}
+	})
+
+	t.Run("remaining ttl", func(t *testing.T) {
+		if ttl, ok := (&FileInfo{ExpiresAt: time.Now().Add(-time.Minute)}).RemainingTTL(); ok || ttl != 0 {
+			t.Errorf("Expected expired entry to report 0, false, got %v, %v", ttl, ok)
+		}
+		if ttl, ok := (&FileInfo{ExpiresAt: time.Now().Add(time.Hour)}).RemainingTTL(); !ok || ttl <= 0 || ttl > time.Hour {
+			t.Errorf("Expected remaining ttl within an hour, got %v, %v", ttl, ok)
+		}
+	})
 }
@@ -0,0 +1,74 @@
+package filecache
+
+// Capabilities 缓存后端的能力描述，供HTTP服务等包装层选择合适的处理路径
+type Capabilities struct {
+	RangeReads bool `json:"range_reads"` // Get返回的reader实现io.ReadSeeker，可以直接用于http.ServeContent
+	AtomicCAS  bool `json:"atomic_cas"`  // 实现ConditionalSetter，SetNX和CompareAndSwap是原子的
+	NativeTTL  bool `json:"native_ttl"`  // 后端自身在过期后删除数据，不依赖Cleanup
+	Streaming  bool `json:"streaming"`   // Set和Get不需要把整个文件放在内存中
+}
+
+// CapabilityReporter 能够报告自身能力的缓存
+type CapabilityReporter interface {
+	// Capabilities 返回缓存的能力
+	Capabilities() Capabilities
+}
+
+// CapabilitiesOf 返回缓存的能力，未实现CapabilityReporter的缓存按最保守的情况处理（全部为false）
+func CapabilitiesOf(cache Cache) Capabilities {
+	if r, ok := cache.(CapabilityReporter); ok {
+		return r.Capabilities()
+	}
+	return Capabilities{}
+}
+
+// Capabilities 返回Badger缓存的能力，文件系统后端的Set和Get都是流式的
+func (c *badgerCache) Capabilities() Capabilities {
+	return Capabilities{
+		RangeReads: true,
+		Streaming:  c.blobs != nil,
+	}
+}
+
+// Capabilities 返回内存缓存的能力
+func (c *memoryCache) Capabilities() Capabilities {
+	return Capabilities{RangeReads: true}
+}
+
+// Capabilities 返回S3缓存的能力
+func (c *s3Cache) Capabilities() Capabilities {
+	return Capabilities{Streaming: true}
+}
+
+// Capabilities 返回GCS缓存的能力
+func (c *gcsCache) Capabilities() Capabilities {
+	return Capabilities{AtomicCAS: true, Streaming: true}
+}
+
+// Capabilities 返回Azure Blob缓存的能力
+func (c *azureCache) Capabilities() Capabilities {
+	return Capabilities{Streaming: true}
+}
+
+// Capabilities 返回两级缓存的能力：读取可能来自任意一层，写入先经过L2
+func (c *tieredCache) Capabilities() Capabilities {
+	l1, l2 := CapabilitiesOf(c.l1), CapabilitiesOf(c.l2)
+	return Capabilities{
+		RangeReads: l1.RangeReads && l2.RangeReads,
+		NativeTTL:  l1.NativeTTL && l2.NativeTTL,
+		Streaming:  l2.Streaming,
+	}
+}
+
+// Capabilities 返回链式缓存的能力，只有所有层都具备的能力才成立
+// 命中下层并提升时返回的数据可以Seek，因此RangeReads只取决于各层自身
+func (c *chainCache) Capabilities() Capabilities {
+	caps := Capabilities{RangeReads: true, NativeTTL: true, Streaming: true}
+	for _, tier := range c.tiers {
+		t := CapabilitiesOf(tier)
+		caps.RangeReads = caps.RangeReads && t.RangeReads
+		caps.NativeTTL = caps.NativeTTL && t.NativeTTL
+		caps.Streaming = caps.Streaming && t.Streaming
+	}
+	return caps
+}
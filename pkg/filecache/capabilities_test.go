@@ -0,0 +1,61 @@
+package filecache
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCapabilities(t *testing.T) {
+	ctx := context.Background()
+
+	badgerCache := newTestBadgerCache(t)
+	defer badgerCache.Close()
+	memory := NewMemoryCache(1024)
+
+	t.Run("RangeReads", func(t *testing.T) {
+		for name, cache := range map[string]Cache{
+			"badger": badgerCache,
+			"memory": memory,
+			"tiered": NewTieredCache(NewMemoryCache(1024), badgerCache),
+		} {
+			if !CapabilitiesOf(cache).RangeReads {
+				t.Errorf("%s: expected range reads", name)
+				continue
+			}
+			if err := cache.Set(ctx, "key", strings.NewReader("0123456789"), "text/plain", time.Hour); err != nil {
+				t.Fatalf("%s: failed to set file: %v", name, err)
+			}
+			reader, _, err := cache.Get(ctx, "key")
+			if err != nil {
+				t.Fatalf("%s: failed to get file: %v", name, err)
+			}
+			seeker, ok := reader.(io.ReadSeeker)
+			if !ok {
+				t.Fatalf("%s: expected io.ReadSeeker, got %T", name, reader)
+			}
+			seeker.Seek(5, io.SeekStart)
+			data, _ := io.ReadAll(seeker)
+			reader.Close()
+			if string(data) != "56789" {
+				t.Errorf("%s: expected '56789', got %q", name, data)
+			}
+		}
+	})
+
+	t.Run("Chain", func(t *testing.T) {
+		caps := CapabilitiesOf(newTestChain(t, ChainOptions{}, NewMemoryCache(1024), badgerCache))
+		if !caps.RangeReads || caps.Streaming || caps.AtomicCAS {
+			t.Errorf("Unexpected chain capabilities: %+v", caps)
+		}
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		// 未实现CapabilityReporter的缓存按最保守的情况处理
+		if caps := CapabilitiesOf(struct{ Cache }{memory}); caps != (Capabilities{}) {
+			t.Errorf("Expected zero capabilities, got %+v", caps)
+		}
+	})
+}
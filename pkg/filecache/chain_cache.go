@@ -0,0 +1,536 @@
+package filecache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultWriteBackQueue 默认写回队列长度
+	defaultWriteBackQueue = 1024
+	// defaultWriteBackWorkers 默认写回协程数
+	defaultWriteBackWorkers = 4
+)
+
+// WritePolicy 链式缓存的写入策略
+type WritePolicy int
+
+const (
+	// WriteThrough 同步写入所有层，从最下层开始写
+	WriteThrough WritePolicy = iota
+	// WriteBack 只同步写入最上层，下层在后台异步写入；队列满时退化为同步写入
+	WriteBack
+	// WriteAround 只写入最下层并使上层失效，上层在读取时通过提升填充
+	WriteAround
+)
+
+// PromotePolicy 读取命中下层时的提升策略
+type PromotePolicy int
+
+const (
+	// PromoteAll 提升到命中层之上的所有层
+	PromoteAll PromotePolicy = iota
+	// PromoteNext 只提升到命中层的上一层，文件随访问逐层上移
+	PromoteNext
+	// PromoteNone 不提升
+	PromoteNone
+)
+
+// ChainOptions 链式缓存选项
+type ChainOptions struct {
+	Write   WritePolicy   // 写入策略，默认WriteThrough
+	Promote PromotePolicy // 提升策略，默认PromoteAll
+
+	// Admit 决定文件能否放入第tier层（0为最上层），写入和提升时调用；
+	// 不被接受的文件只存放在更下层（并使该层的旧版本失效）。最下层总是接受，为nil时所有层都接受
+	Admit func(tier int, info *FileInfo) bool
+
+	WriteBackQueue   int                         // 写回队列长度，默认1024
+	WriteBackWorkers int                         // 写回协程数，默认4
+	OnWriteBackError func(key string, err error) // 后台写回失败时回调
+}
+
+// chainCache 由多层缓存组成的链式缓存，最上层最快，最下层是权威数据源
+type chainCache struct {
+	tiers      []Cache
+	opts       ChainOptions
+	writer     *chainWriter
+	ownsWriter bool // 根缓存负责关闭写回队列
+
+	mu           sync.RWMutex // 保护命中统计
+	hits, misses int64
+
+	namespaces map[string]*chainCache
+	nsMu       sync.Mutex
+}
+
+// chainJob 等待写回下层的文件
+type chainJob struct {
+	chain     *chainCache
+	key       string
+	data      *spooled
+	mimeType  string
+	expiresAt time.Time
+}
+
+// chainWriter 根缓存和所有命名空间共享的写回队列
+type chainWriter struct {
+	queue chan *chainJob
+	wg    sync.WaitGroup
+
+	mu      sync.Mutex
+	closed  bool
+	pending map[*chainCache]map[string]*chainJob // 每个键只保留最新的待写回版本
+
+	// writeMu 串行化写回与删除，避免删除后被旧的写回任务重新写入
+	writeMu sync.Mutex
+}
+
+// Chain 按从快到慢的顺序组合多层缓存，使用写穿透和全部提升策略
+// 例如 Chain(memory, badger, s3)；关闭时会关闭所有层
+func Chain(caches ...Cache) (Cache, error) {
+	return ChainWithOptions(ChainOptions{}, caches...)
+}
+
+// ChainWithOptions 使用选项组合多层缓存，至少需要一层
+func ChainWithOptions(opts ChainOptions, caches ...Cache) (Cache, error) {
+	if len(caches) == 0 {
+		return nil, fmt.Errorf("chain requires at least one cache")
+	}
+	if opts.WriteBackQueue <= 0 {
+		opts.WriteBackQueue = defaultWriteBackQueue
+	}
+	if opts.WriteBackWorkers <= 0 {
+		opts.WriteBackWorkers = defaultWriteBackWorkers
+	}
+
+	c := newChainCache(caches, opts, nil)
+	if opts.Write == WriteBack && len(caches) > 1 {
+		c.ownsWriter = true
+		c.writer = &chainWriter{
+			queue:   make(chan *chainJob, opts.WriteBackQueue),
+			pending: make(map[*chainCache]map[string]*chainJob),
+		}
+		for i := 0; i < opts.WriteBackWorkers; i++ {
+			c.writer.wg.Add(1)
+			go c.writer.run()
+		}
+	}
+	return c, nil
+}
+
+func newChainCache(caches []Cache, opts ChainOptions, writer *chainWriter) *chainCache {
+	return &chainCache{
+		tiers:      caches,
+		opts:       opts,
+		writer:     writer,
+		namespaces: make(map[string]*chainCache),
+	}
+}
+
+// last 返回最下层的序号
+func (c *chainCache) last() int {
+	return len(c.tiers) - 1
+}
+
+// admit 判断文件能否放入第tier层
+func (c *chainCache) admit(tier int, info *FileInfo) bool {
+	return tier == c.last() || c.opts.Admit == nil || c.opts.Admit(tier, info)
+}
+
+// Set 按写入策略存储文件
+func (c *chainCache) Set(ctx context.Context, key string, data io.Reader, mimeType string, ttl time.Duration) error {
+	body, size, err := spool(data, 0)
+	if err != nil {
+		return err
+	}
+
+	info := &FileInfo{Key: key, Size: size, MimeType: mimeType}
+	switch {
+	case c.opts.Write == WriteAround:
+		defer body.Close()
+		return c.writeTiers(ctx, key, body, info, ttl, c.last(), c.last(), true)
+	case c.opts.Write == WriteBack && c.writer != nil && c.admit(0, info):
+		return c.writeBack(ctx, key, body, info, ttl)
+	default:
+		defer body.Close()
+		return c.writeTiers(ctx, key, body, info, ttl, 0, c.last(), false)
+	}
+}
+
+// writeTiers 从下往上写入第from到第to层，返回最下层的写入错误
+// 上层写入失败或不接受该文件时使其旧版本失效；invalidateAbove为true时同时使from之上的层失效
+func (c *chainCache) writeTiers(ctx context.Context, key string, body *spooled, info *FileInfo, ttl time.Duration, from, to int, invalidateAbove bool) error {
+	for i := to; i >= from; i-- {
+		if !c.admit(i, info) {
+			if err := c.tiers[i].Delete(ctx, key); err != nil && i == to {
+				return err
+			}
+			continue
+		}
+		if err := body.rewind(); err != nil {
+			return err
+		}
+		if err := c.tiers[i].Set(ctx, key, body, info.MimeType, ttl); err != nil {
+			if i == to {
+				return err
+			}
+			_ = c.tiers[i].Delete(ctx, key)
+		}
+	}
+
+	if invalidateAbove {
+		for i := from - 1; i >= 0; i-- {
+			if err := c.tiers[i].Delete(ctx, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeBack 同步写入最上层，并把下层的写入交给后台协程
+func (c *chainCache) writeBack(ctx context.Context, key string, body *spooled, info *FileInfo, ttl time.Duration) error {
+	if err := c.tiers[0].Set(ctx, key, body, info.MimeType, ttl); err != nil {
+		body.Close()
+		return err
+	}
+	if ttl <= 0 && ttl != NoExpiry {
+		// 以最上层实际使用的TTL为准
+		if stored, err := c.tiers[0].GetInfo(ctx, key); err == nil {
+			ttl, _ = stored.RemainingTTL()
+		}
+	}
+
+	job := &chainJob{
+		chain:     c,
+		key:       key,
+		data:      body,
+		mimeType:  info.MimeType,
+		expiresAt: expiryFor(time.Now(), ttl),
+	}
+	if ttl <= 0 && ttl != NoExpiry {
+		job.expiresAt = time.Time{}
+	}
+
+	w := c.writer
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		body.Close()
+		return ErrCacheClosed
+	}
+	if old := w.pending[c][key]; old != nil {
+		old.data.Close()
+	}
+	if w.pending[c] == nil {
+		w.pending[c] = make(map[string]*chainJob)
+	}
+	w.pending[c][key] = job
+
+	select {
+	case w.queue <- job:
+		w.mu.Unlock()
+		return nil
+	default:
+	}
+	w.mu.Unlock()
+
+	// 队列已满，同步写回
+	w.process(job)
+	return nil
+}
+
+// run 后台写回协程
+func (w *chainWriter) run() {
+	defer w.wg.Done()
+	for job := range w.queue {
+		w.process(job)
+	}
+}
+
+// process 把任务写入下层，任务已被更新或删除时跳过
+func (w *chainWriter) process(job *chainJob) {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+
+	w.mu.Lock()
+	current := w.pending[job.chain][job.key] == job
+	if current {
+		delete(w.pending[job.chain], job.key)
+	}
+	w.mu.Unlock()
+	if !current {
+		return
+	}
+	defer job.data.Close()
+
+	var ttl time.Duration
+	if !job.expiresAt.IsZero() {
+		var ok bool
+		if ttl, ok = remainingTTL(job.expiresAt); !ok {
+			return
+		}
+	}
+
+	c := job.chain
+	info := &FileInfo{Key: job.key, MimeType: job.mimeType}
+	if size, err := job.data.Seek(0, io.SeekEnd); err == nil {
+		info.Size = size
+	}
+	err := c.writeTiers(context.Background(), job.key, job.data, info, ttl, 1, c.last(), false)
+	if err != nil && c.opts.OnWriteBackError != nil {
+		c.opts.OnWriteBackError(job.key, err)
+	}
+}
+
+// cancel 丢弃键的待写回任务，key为空时丢弃该缓存的所有任务，调用方需持有w.writeMu
+func (w *chainWriter) cancel(c *chainCache, key string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for k, job := range w.pending[c] {
+		if key == "" || k == key {
+			job.data.Close()
+			delete(w.pending[c], k)
+		}
+	}
+}
+
+// close 停止接收新任务，并等待队列中的任务写完
+func (w *chainWriter) close() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+
+	w.wg.Wait()
+}
+
+// Get 自上而下读取，命中下层时按提升策略写入上层
+func (c *chainCache) Get(ctx context.Context, key string) (io.ReadCloser, *FileInfo, error) {
+	var lastErr error
+	for i, tier := range c.tiers {
+		reader, info, err := tier.Get(ctx, key)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		c.recordHit()
+		return c.promote(ctx, i, reader, info), info, nil
+	}
+
+	c.recordMiss()
+	return nil, nil, lastErr
+}
+
+// promote 把命中第hit层的文件写入上层，返回供调用方读取的数据
+func (c *chainCache) promote(ctx context.Context, hit int, reader io.ReadCloser, info *FileInfo) io.ReadCloser {
+	var targets []int
+	switch c.opts.Promote {
+	case PromoteAll:
+		for i := hit - 1; i >= 0; i-- {
+			targets = append(targets, i)
+		}
+	case PromoteNext:
+		if hit > 0 {
+			targets = append(targets, hit-1)
+		}
+	}
+
+	ttl, live := info.RemainingTTL()
+	var admitted []int
+	for _, i := range targets {
+		if c.admit(i, info) {
+			admitted = append(admitted, i)
+		}
+	}
+	if len(admitted) == 0 || !live {
+		return reader
+	}
+
+	body, _, err := spool(reader, 0)
+	reader.Close()
+	if err != nil {
+		return io.NopCloser(&errorReader{err: err})
+	}
+	for _, i := range admitted {
+		if body.rewind() != nil {
+			break
+		}
+		// 提升失败不影响本次读取
+		if err := c.tiers[i].Set(ctx, info.Key, body, info.MimeType, ttl); err != nil {
+			_ = c.tiers[i].Delete(ctx, info.Key)
+		}
+	}
+	if err := body.rewind(); err != nil {
+		body.Close()
+		return io.NopCloser(&errorReader{err: err})
+	}
+	return body
+}
+
+// errorReader 读取时返回固定错误
+type errorReader struct {
+	err error
+}
+
+func (r *errorReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// Exists 检查任意一层中是否存在文件
+func (c *chainCache) Exists(ctx context.Context, key string) (bool, error) {
+	var lastErr error
+	for _, tier := range c.tiers {
+		exists, err := tier.Exists(ctx, key)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if exists {
+			return true, nil
+		}
+	}
+	return false, lastErr
+}
+
+// Delete 从下往上删除所有层中的文件，并丢弃待写回的版本
+func (c *chainCache) Delete(ctx context.Context, key string) error {
+	if c.writer != nil {
+		c.writer.writeMu.Lock()
+		defer c.writer.writeMu.Unlock()
+		c.writer.cancel(c, key)
+	}
+
+	for i := c.last(); i >= 0; i-- {
+		if err := c.tiers[i].Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List 列出所有缓存文件，以最下层为准；写回模式下尚未写回的文件不会出现
+func (c *chainCache) List(ctx context.Context) ([]*FileInfo, error) {
+	return c.tiers[c.last()].List(ctx)
+}
+
+// GetInfo 自上而下获取文件信息
+func (c *chainCache) GetInfo(ctx context.Context, key string) (*FileInfo, error) {
+	var lastErr error
+	for _, tier := range c.tiers {
+		info, err := tier.GetInfo(ctx, key)
+		if err == nil {
+			return info, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// Cleanup 清理所有层中的过期文件
+func (c *chainCache) Cleanup(ctx context.Context) error {
+	for _, tier := range c.tiers {
+		if err := tier.Cleanup(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush 清空所有层，并丢弃待写回的文件
+func (c *chainCache) Flush(ctx context.Context) error {
+	if c.writer != nil {
+		c.writer.writeMu.Lock()
+		defer c.writer.writeMu.Unlock()
+		c.writer.cancel(c, "")
+	}
+
+	for i := c.last(); i >= 0; i-- {
+		if err := c.tiers[i].Flush(ctx); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	c.hits, c.misses = 0, 0
+	c.mu.Unlock()
+	return nil
+}
+
+// Namespace 返回由各层同名命名空间组成的链式缓存，与根缓存共享写回队列
+func (c *chainCache) Namespace(name string) Cache {
+	if name == "" {
+		return c
+	}
+
+	c.nsMu.Lock()
+	defer c.nsMu.Unlock()
+
+	if ns, ok := c.namespaces[name]; ok {
+		return ns
+	}
+	tiers := make([]Cache, len(c.tiers))
+	for i, tier := range c.tiers {
+		tiers[i] = tier.Namespace(name)
+	}
+	ns := newChainCache(tiers, c.opts, c.writer)
+	c.namespaces[name] = ns
+	return ns
+}
+
+// Close 等待待写回的文件写完后关闭所有层
+func (c *chainCache) Close() error {
+	if c.ownsWriter {
+		c.writer.close()
+	}
+
+	var err error
+	for _, tier := range c.tiers {
+		if closeErr := tier.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to close cache tier: %w", err)
+	}
+	return nil
+}
+
+// Stats 返回最下层的容量统计，命中率按链式缓存整体计算
+func (c *chainCache) Stats() (*Stats, error) {
+	stats, err := c.tiers[c.last()].Stats()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if total := float64(c.hits + c.misses); total > 0 {
+		stats.HitRate = float64(c.hits) / total
+		stats.MissRate = float64(c.misses) / total
+	}
+	return stats, nil
+}
+
+// recordHit 记录一次命中
+func (c *chainCache) recordHit() {
+	c.mu.Lock()
+	c.hits++
+	c.mu.Unlock()
+}
+
+// recordMiss 记录一次未命中
+func (c *chainCache) recordMiss() {
+	c.mu.Lock()
+	c.misses++
+	c.mu.Unlock()
+}
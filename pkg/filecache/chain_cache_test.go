@@ -0,0 +1,165 @@
+package filecache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChain(t *testing.T) {
+	ctx := context.Background()
+
+	// 最上层只接受不超过4字节的文件
+	smallOnly := func(tier int, info *FileInfo) bool {
+		return tier > 0 || info.Size <= 4
+	}
+
+	t.Run("WriteThrough", func(t *testing.T) {
+		tiers := []Cache{NewMemoryCache(1024), NewMemoryCache(1024), newTestBadgerCache(t)}
+		cache := newTestChain(t, ChainOptions{Admit: smallOnly}, tiers...)
+		defer cache.Close()
+
+		if err := cache.Set(ctx, "small", strings.NewReader("1234"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		if err := cache.Set(ctx, "large", strings.NewReader("123456"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+
+		for i, tier := range tiers {
+			if exists, _ := tier.Exists(ctx, "small"); !exists {
+				t.Errorf("Expected small file in tier %d", i)
+			}
+			if exists, _ := tier.Exists(ctx, "large"); exists != (i > 0) {
+				t.Errorf("Unexpected large file presence in tier %d: %v", i, exists)
+			}
+		}
+
+		if err := cache.Delete(ctx, "small"); err != nil {
+			t.Fatalf("Failed to delete file: %v", err)
+		}
+		for i, tier := range tiers {
+			if exists, _ := tier.Exists(ctx, "small"); exists {
+				t.Errorf("Expected small file to be deleted from tier %d", i)
+			}
+		}
+	})
+
+	t.Run("Promotion", func(t *testing.T) {
+		for _, tc := range []struct {
+			name    string
+			promote PromotePolicy
+			want    []bool
+		}{
+			{"All", PromoteAll, []bool{true, true, true}},
+			{"Next", PromoteNext, []bool{false, true, true}},
+			{"None", PromoteNone, []bool{false, false, true}},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				tiers := []Cache{NewMemoryCache(1024), NewMemoryCache(1024), NewMemoryCache(1024)}
+				cache := newTestChain(t, ChainOptions{Promote: tc.promote}, tiers...)
+				defer cache.Close()
+
+				if err := tiers[2].Set(ctx, "key", strings.NewReader("data"), "text/plain", time.Hour); err != nil {
+					t.Fatalf("Failed to set file: %v", err)
+				}
+				if got := readString(t, cache, "key"); got != "data" {
+					t.Errorf("Expected 'data', got %q", got)
+				}
+				for i, tier := range tiers {
+					if exists, _ := tier.Exists(ctx, "key"); exists != tc.want[i] {
+						t.Errorf("Tier %d: expected presence %v, got %v", i, tc.want[i], exists)
+					}
+				}
+			})
+		}
+	})
+
+	t.Run("WriteAround", func(t *testing.T) {
+		tiers := []Cache{NewMemoryCache(1024), NewMemoryCache(1024)}
+		cache := newTestChain(t, ChainOptions{Write: WriteAround}, tiers...)
+		defer cache.Close()
+
+		if err := tiers[0].Set(ctx, "key", strings.NewReader("old"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		if err := cache.Set(ctx, "key", strings.NewReader("new"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		if exists, _ := tiers[0].Exists(ctx, "key"); exists {
+			t.Error("Expected upper tier to be invalidated")
+		}
+		if got := readString(t, cache, "key"); got != "new" {
+			t.Errorf("Expected 'new', got %q", got)
+		}
+		if got := readString(t, tiers[0], "key"); got != "new" {
+			t.Errorf("Expected promoted 'new', got %q", got)
+		}
+	})
+
+	t.Run("WriteBack", func(t *testing.T) {
+		tiers := []Cache{NewMemoryCache(1024), newTestBadgerCache(t)}
+		cache := newTestChain(t, ChainOptions{Write: WriteBack}, tiers...)
+		defer cache.Close()
+
+		if err := cache.Set(ctx, "key", strings.NewReader("data"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		if got := readString(t, tiers[0], "key"); got != "data" {
+			t.Errorf("Expected 'data' in upper tier, got %q", got)
+		}
+
+		// 等待后台写回
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			if exists, _ := tiers[1].Exists(ctx, "key"); exists {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("Expected file to be written back to lower tier")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		info, err := tiers[1].GetInfo(ctx, "key")
+		if err != nil {
+			t.Fatalf("Failed to get info: %v", err)
+		}
+		if time.Until(info.ExpiresAt) < 59*time.Minute {
+			t.Errorf("Expected TTL to be preserved, got %v", info.ExpiresAt)
+		}
+	})
+
+	t.Run("Namespace", func(t *testing.T) {
+		cache := newTestChain(t, ChainOptions{}, NewMemoryCache(1024), newTestBadgerCache(t))
+		defer cache.Close()
+
+		ns := cache.Namespace("site-a")
+		if err := ns.Set(ctx, "key", strings.NewReader("site a"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		if exists, _ := cache.Exists(ctx, "key"); exists {
+			t.Error("Expected namespaces to be isolated")
+		}
+		if got := readString(t, ns, "key"); got != "site a" {
+			t.Errorf("Expected 'site a', got %q", got)
+		}
+	})
+}
+
+func TestChainRequiresCache(t *testing.T) {
+	if cache, err := Chain(); err == nil {
+		cache.Close()
+		t.Error("Expected an error for a chain without caches")
+	}
+}
+
+// newTestChain 组合多层缓存，失败时终止测试
+func newTestChain(t *testing.T, opts ChainOptions, caches ...Cache) Cache {
+	t.Helper()
+	cache, err := ChainWithOptions(opts, caches...)
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	return cache
+}
@@ -0,0 +1,200 @@
+package filecache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// defaultCleanupBatchSize 默认每个删除事务包含的条目数
+const defaultCleanupBatchSize = 100
+
+// expiredEntry 待删除的过期条目
+type expiredEntry struct {
+	key  string
+	size int64
+}
+
+// CleanupWithOptions 按批次清理过期文件
+// 每批先在只读事务中扫描出过期条目，再在单个写事务中删除，避免长时间持有事务
+func (c *badgerCache) CleanupWithOptions(ctx context.Context, opts CleanupOptions) (*CleanupResult, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultCleanupBatchSize
+	}
+
+	start := time.Now()
+	result := &CleanupResult{Cursor: opts.Cursor}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		// 计算本批最多还能扫描多少条
+		limit := -1
+		if opts.MaxEntries > 0 {
+			limit = opts.MaxEntries - int(result.Scanned)
+		}
+
+		batch, scanned, cursor, done, err := c.scanExpired(result.Cursor, opts.BatchSize, limit)
+		if err != nil {
+			return result, err
+		}
+		result.Scanned += scanned
+		result.Cursor = cursor
+		result.Done = done
+
+		if len(batch) > 0 {
+			removed, reclaimed, err := c.deleteExpired(batch)
+			if err != nil {
+				return result, err
+			}
+			result.Removed += removed
+			result.BytesReclaimed += reclaimed
+		}
+
+		if opts.Progress != nil {
+			progress := *result
+			opts.Progress(&progress)
+		}
+
+		if done {
+			break
+		}
+		if opts.MaxEntries > 0 && result.Scanned >= int64(opts.MaxEntries) {
+			break
+		}
+		if opts.MaxDuration > 0 && time.Since(start) >= opts.MaxDuration {
+			break
+		}
+	}
+
+	// 完整扫描一轮后永久删除回收站中超过保留时间的条目，并删除崩溃留下的不成对记录
+	if result.Done {
+		purged, err := c.purgeTrash(ctx, opts.BatchSize)
+		if err != nil {
+			return result, err
+		}
+		result.TrashPurged = purged
+
+		orphans, err := c.removeOrphans(ctx)
+		result.Orphans = orphans
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// 更新统计信息
+	c.mu.Lock()
+	c.stats.ExpiredFiles = result.Removed
+	c.stats.LastCleanup = start
+	c.mu.Unlock()
+
+	// 保存统计信息
+	c.saveStats()
+
+	return result, nil
+}
+
+// scanExpired 从cursor之后扫描，直到找到batchSize个过期条目或扫描满limit条（limit<0表示不限制）
+func (c *badgerCache) scanExpired(cursor string, batchSize, limit int) (batch []expiredEntry, scanned int64, next string, done bool, err error) {
+	// 过期超过StaleRetention的条目才删除
+	cutoff := time.Now().Add(-c.config.Load().StaleRetention)
+	next = cursor
+	prefix := []byte(c.prefix + fileInfoPrefix)
+
+	err = c.store.view(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = true
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		seek := append(append([]byte{}, prefix...), cursor...)
+		for it.Seek(seek); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			fileKey := string(item.Key()[len(prefix):])
+			if cursor != "" && fileKey == cursor {
+				continue
+			}
+			if len(batch) >= batchSize || (limit >= 0 && scanned >= int64(limit)) {
+				return nil
+			}
+
+			scanned++
+			next = fileKey
+			err := item.Value(func(val []byte) error {
+				fileInfo := &FileInfo{}
+				if err := json.Unmarshal(val, fileInfo); err != nil {
+					return err
+				}
+				if cutoff.After(fileInfo.ExpiresAt) {
+					batch = append(batch, expiredEntry{key: fileKey, size: fileInfo.Size})
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		done = true
+		return nil
+	})
+	return batch, scanned, next, done, err
+}
+
+// deleteExpired 在单个事务中删除一批过期条目
+// 删除前重新检查过期时间，避免误删扫描之后被重新写入的条目
+func (c *badgerCache) deleteExpired(batch []expiredEntry) (removed, reclaimed int64, err error) {
+	// 过期超过StaleRetention的条目才删除
+	cutoff := time.Now().Add(-c.config.Load().StaleRetention)
+	var deleted []expiredEntry
+
+	err = c.store.update(func(txn *badger.Txn) error {
+		for _, entry := range batch {
+			item, err := txn.Get(c.infoKey(entry.key))
+			if err == badger.ErrKeyNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			fileInfo := &FileInfo{}
+			if err := item.Value(func(val []byte) error {
+				return json.Unmarshal(val, fileInfo)
+			}); err != nil {
+				return err
+			}
+			if !cutoff.After(fileInfo.ExpiresAt) {
+				continue
+			}
+
+			if c.blobs == nil {
+				if err := txn.Delete(c.dataKey(entry.key)); err != nil {
+					return err
+				}
+			}
+			if err := txn.Delete(c.infoKey(entry.key)); err != nil {
+				return err
+			}
+			deleted = append(deleted, expiredEntry{key: entry.key, size: fileInfo.Size})
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, entry := range deleted {
+		if c.blobs != nil {
+			c.removeBlob(entry.key, false)
+		}
+		c.updateStatsAfterDelete(entry.size)
+		removed++
+		reclaimed += entry.size
+	}
+	return removed, reclaimed, nil
+}
@@ -0,0 +1,57 @@
+package filecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	// compactWorkers 合并LSM时使用的并发数
+	compactWorkers = 2
+	// compactDiscardRatio value log文件中可回收数据的比例超过该值时才重写
+	compactDiscardRatio = 0.5
+)
+
+// Compact 把LSM合并到最底层，再反复重写value log直到没有可回收的文件
+// 命名空间共享同一个Badger实例，在任意命名空间上调用都会压缩整个数据库
+func (c *badgerCache) Compact(ctx context.Context) error {
+	return c.store.withDB(func(db *badger.DB) error {
+		if err := db.Flatten(compactWorkers); err != nil {
+			return fmt.Errorf("failed to flatten lsm tree: %w", err)
+		}
+		return reclaimValueLog(ctx, db)
+	})
+}
+
+// reclaimValueLog 反复重写value log直到没有可回收的文件
+func reclaimValueLog(ctx context.Context, db *badger.DB) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := db.RunValueLogGC(compactDiscardRatio)
+		if errors.Is(err, badger.ErrNoRewrite) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to run value log gc: %w", err)
+		}
+	}
+}
+
+// Compact 依次压缩所有分片
+func (c *shardedCache) Compact(ctx context.Context) error {
+	for i, shard := range c.shards {
+		compactor, ok := shard.(Compactor)
+		if !ok {
+			return fmt.Errorf("compaction is not supported")
+		}
+		if err := compactor.Compact(ctx); err != nil {
+			return fmt.Errorf("failed to compact shard %d: %w", i, err)
+		}
+	}
+	return nil
+}
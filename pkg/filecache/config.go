@@ -4,7 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"time"
+
+	"github.com/seraphico/EdgeOrigin/internal/configfile"
+	"github.com/seraphico/EdgeOrigin/internal/validate"
 )
 
 // DefaultConfig 返回默认配置
@@ -18,7 +22,8 @@ func DefaultConfig() *Config {
 	}
 }
 
-// LoadConfigFromFile 从文件加载配置
+// LoadConfigFromFile 从文件加载配置，按扩展名支持JSON、YAML（.yaml、.yml）和TOML（.toml），
+// 字段名都与JSON相同，时长可以写成"24h"这样的字符串；设置了的EDGEORIGIN_*环境变量覆盖文件中的值，见ApplyEnv
 func LoadConfigFromFile(filename string) (*Config, error) {
 	data, err := os.ReadFile(filename)
 	if err != nil {
@@ -26,9 +31,12 @@ func LoadConfigFromFile(filename string) (*Config, error) {
 	}
 
 	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
+	if err := configfile.Decode(filename, data, &config); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
+	if err := ApplyEnv(&config); err != nil {
+		return nil, err
+	}
 
 	return &config, nil
 }
@@ -43,29 +51,131 @@ func SaveConfigToFile(config *Config, filename string) error {
 	return os.WriteFile(filename, data, 0644)
 }
 
-// ValidateConfig 验证配置
+// ValidateConfig 验证配置，只检查配置本身，不打开数据目录；返回的错误列出所有问题，
+// 每个问题以字段路径开头，例如"backup.interval: must be positive"
 func ValidateConfig(config *Config) error {
 	if config == nil {
 		return fmt.Errorf("config cannot be nil")
 	}
 
+	var errs validate.Errors
 	if config.DataDir == "" {
-		return fmt.Errorf("data directory cannot be empty")
+		errs.Addf("data_dir", "cannot be empty")
 	}
 
 	if config.MaxCacheSize <= 0 {
-		return fmt.Errorf("max cache size must be positive")
+		errs.Addf("max_cache_size", "must be positive")
+	}
+
+	if config.MaxEntrySize < 0 {
+		errs.Addf("max_entry_size", "cannot be negative")
+	} else if config.MaxCacheSize > 0 && config.MaxEntrySize > config.MaxCacheSize {
+		errs.Addf("max_entry_size", "%d exceeds max cache size %d", config.MaxEntrySize, config.MaxCacheSize)
 	}
 
 	if config.DefaultTTL <= 0 {
-		return fmt.Errorf("default TTL must be positive")
+		errs.Addf("default_ttl", "must be positive")
 	}
 
 	if config.CleanupInterval <= 0 {
-		return fmt.Errorf("cleanup interval must be positive")
+		errs.Addf("cleanup_interval", "must be positive")
+	}
+
+	if config.TrashRetention < 0 {
+		errs.Addf("trash_retention", "cannot be negative")
+	}
+
+	if config.StaleRetention < 0 {
+		errs.Addf("stale_retention", "cannot be negative")
+	}
+
+	if config.MmapMinSize < 0 {
+		errs.Addf("mmap_min_size", "cannot be negative")
+	}
+	if config.MmapMinAccesses < 0 {
+		errs.Addf("mmap_min_accesses", "cannot be negative")
+	}
+
+	if config.EncryptionKey != "" {
+		if _, err := decodeEncryptionKey(config.EncryptionKey); err != nil {
+			errs.Add("encryption_key", err)
+		}
+	}
+
+	if _, err := NewTTLPolicySet(config.TTLPolicies); err != nil {
+		errs.Add("ttl_policies", err)
 	}
 
-	return nil
+	if err := config.ttlJitter().validate(); err != nil {
+		errs.Add("ttl_jitter", err)
+	}
+
+	if config.EvictHighWatermark < 0 || config.EvictHighWatermark > 1 {
+		errs.Addf("evict_high_watermark", "must be between 0 and 1")
+	}
+	if config.EvictLowWatermark < 0 || config.EvictLowWatermark > 1 {
+		errs.Addf("evict_low_watermark", "must be between 0 and 1")
+	} else if high, low, ok := config.evictWatermarks(); ok && (low <= 0 || low >= high) {
+		errs.Addf("evict_low_watermark", "%g must be between 0 and the high watermark %g", low, high)
+	}
+	if config.EvictInterval < 0 {
+		errs.Addf("evict_interval", "cannot be negative")
+	}
+
+	names := make([]string, 0, len(config.NamespaceQuotas))
+	for name := range config.NamespaceQuotas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		quota := config.NamespaceQuotas[name]
+		if quota <= 0 {
+			errs.Addf(validate.Key("namespace_quotas", name), "must be positive")
+		} else if config.MaxCacheSize > 0 && quota > config.MaxCacheSize {
+			errs.Addf(validate.Key("namespace_quotas", name), "%d exceeds max cache size %d", quota, config.MaxCacheSize)
+		}
+	}
+
+	if backup := config.Backup; backup != nil {
+		if backup.Interval <= 0 {
+			errs.Addf("backup.interval", "must be positive")
+		}
+		if backup.FullInterval < 0 {
+			errs.Addf("backup.full_interval", "cannot be negative")
+		}
+		if backup.Retention < 0 {
+			errs.Addf("backup.retention", "cannot be negative")
+		}
+		if _, _, err := parseBackupDestination(backup.Destination); err != nil {
+			errs.Add("backup.destination", err)
+		}
+	}
+
+	keys := make(map[string]bool, len(config.APIKeys))
+	for i, key := range config.APIKeys {
+		path := validate.Index("api_keys", i)
+		if key.Key == "" {
+			errs.Addf(validate.Join(path, "key"), "api key %q cannot be empty", key.Name)
+		} else if keys[key.Key] {
+			errs.Addf(validate.Join(path, "key"), "api key %q is duplicated", key.Name)
+		}
+		keys[key.Key] = true
+		switch key.Role {
+		case RoleReadOnly, RolePurge, RoleAdmin:
+		default:
+			errs.Addf(validate.Join(path, "role"), "invalid role %q for api key %q", key.Role, key.Name)
+		}
+	}
+
+	return errs.Err()
+}
+
+// maxEntrySize 返回单个文件的最大大小，未设置时沿用MaxCacheSize
+func (c *Config) maxEntrySize() int64 {
+	if c.MaxEntrySize > 0 {
+		return c.MaxEntrySize
+	}
+	return c.MaxCacheSize
 }
 
 // NewCacheWithConfig 使用配置创建缓存
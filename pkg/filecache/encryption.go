@@ -0,0 +1,107 @@
+package filecache
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	// encryptionKeyRotationDuration Badger数据密钥的轮换周期
+	encryptionKeyRotationDuration = 10 * 24 * time.Hour
+	// encryptionIndexCacheSize 启用加密时Badger要求设置索引缓存
+	encryptionIndexCacheSize = 100 << 20 // 100MB
+)
+
+// decodeEncryptionKey 解析十六进制编码的AES密钥
+func decodeEncryptionKey(encoded string) ([]byte, error) {
+	key, err := hex.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key must be hex encoded: %w", err)
+	}
+
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("encryption key must be 16, 24 or 32 bytes, got %d", len(key))
+	}
+}
+
+// RotateEncryptionKey 将主密钥轮换为newKey（十六进制编码）
+// Badger用主密钥加密KEYREGISTRY中的数据密钥，数据本身由数据密钥加密并按周期自动轮换，
+// 因此轮换主密钥只需重写KEYREGISTRY：短暂阻塞读写、关闭数据库、重写注册表后用新密钥重新打开，
+// 进程、内存中的统计信息和命名空间实例都保持不变。任何一步失败时恢复旧密钥的注册表并用旧密钥重新打开。
+// 成功后缓存使用的配置副本记录新密钥，调用方传入NewBadgerCache的Config不会被修改，需要自行保存新密钥
+func (c *badgerCache) RotateEncryptionKey(ctx context.Context, newKey string) error {
+	key, err := decodeEncryptionKey(newKey)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s := c.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	oldKey := s.opts.EncryptionKey
+	if len(oldKey) == 0 {
+		return fmt.Errorf("encryption is not enabled")
+	}
+
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("failed to close badger database: %w", err)
+	}
+
+	rotateErr := rewriteKeyRegistry(s.opts, oldKey, key)
+	if rotateErr == nil {
+		opts := s.opts
+		opts.EncryptionKey = key
+		db, err := badger.Open(opts)
+		if err == nil {
+			s.db = db
+			s.opts = opts
+			next := *c.config.Load()
+			next.EncryptionKey = newKey
+			c.config.Store(&next)
+			return nil
+		}
+		rotateErr = fmt.Errorf("failed to reopen badger database with the new key: %w", err)
+		// 注册表已经用新密钥写入，恢复为旧密钥后才能用旧密钥打开
+		if err := rewriteKeyRegistry(s.opts, key, oldKey); err != nil {
+			return fmt.Errorf("%w; failed to restore key registry: %v", rotateErr, err)
+		}
+	}
+
+	// 轮换失败时用旧密钥重新打开，保证缓存继续可用
+	db, err := badger.Open(s.opts)
+	if err != nil {
+		return fmt.Errorf("%w; failed to reopen badger database: %v", rotateErr, err)
+	}
+	s.db = db
+	return rotateErr
+}
+
+// rewriteKeyRegistry 用from解密数据目录中KEYREGISTRY的数据密钥，再用to加密写回，数据库必须已经关闭
+func rewriteKeyRegistry(opts badger.Options, from, to []byte) error {
+	registryOpts := badger.KeyRegistryOptions{
+		Dir:                           opts.Dir,
+		ReadOnly:                      true,
+		EncryptionKey:                 from,
+		EncryptionKeyRotationDuration: opts.EncryptionKeyRotationDuration,
+	}
+	registry, err := badger.OpenKeyRegistry(registryOpts)
+	if err != nil {
+		return fmt.Errorf("failed to open key registry: %w", err)
+	}
+	registryOpts.EncryptionKey = to
+	if err := badger.WriteKeyRegistry(registry, registryOpts); err != nil {
+		return fmt.Errorf("failed to write key registry: %w", err)
+	}
+	return nil
+}
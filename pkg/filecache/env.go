@@ -0,0 +1,175 @@
+package filecache
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix 覆盖配置的环境变量前缀，变量名为前缀加上大写的JSON字段名，例如EDGEORIGIN_DATA_DIR
+const EnvPrefix = "EDGEORIGIN_"
+
+// LoadConfigFromEnv 返回默认配置叠加EDGEORIGIN_*环境变量后的配置，适合不带配置文件的容器部署
+func LoadConfigFromEnv() (*Config, error) {
+	config := DefaultConfig()
+	if err := ApplyEnv(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// ApplyEnv 用设置了的EDGEORIGIN_*环境变量覆盖config中对应的字段，支持：
+//
+//	EDGEORIGIN_DATA_DIR、EDGEORIGIN_ENCRYPTION_KEY       字符串
+//	EDGEORIGIN_MAX_CACHE_SIZE、EDGEORIGIN_MAX_ENTRY_SIZE、
+//	EDGEORIGIN_MMAP_MIN_SIZE                              字节数，可以带KB、MB、GB、TB单位（按1024换算）
+//	EDGEORIGIN_MMAP_MIN_ACCESSES                          整数
+//	EDGEORIGIN_DEFAULT_TTL、EDGEORIGIN_CLEANUP_INTERVAL、
+//	EDGEORIGIN_TRASH_RETENTION、EDGEORIGIN_STALE_RETENTION、
+//	EDGEORIGIN_TTL_JITTER_MAX、EDGEORIGIN_EVICT_INTERVAL  时长，例如"24h"
+//	EDGEORIGIN_TTL_JITTER、EDGEORIGIN_EVICT_HIGH_WATERMARK、
+//	EDGEORIGIN_EVICT_LOW_WATERMARK                        0到1之间的小数
+//	EDGEORIGIN_COMPRESSION、EDGEORIGIN_SOFT_DELETE        布尔值
+//
+// 值为空的变量视为没有设置
+func ApplyEnv(config *Config) error {
+	texts := []struct {
+		name string
+		dst  *string
+	}{
+		{"DATA_DIR", &config.DataDir},
+		{"ENCRYPTION_KEY", &config.EncryptionKey},
+	}
+	for _, v := range texts {
+		if s, ok := lookupEnv(v.name); ok {
+			*v.dst = s
+		}
+	}
+
+	sizes := []struct {
+		name string
+		dst  *int64
+	}{
+		{"MAX_CACHE_SIZE", &config.MaxCacheSize},
+		{"MAX_ENTRY_SIZE", &config.MaxEntrySize},
+		{"MMAP_MIN_SIZE", &config.MmapMinSize},
+	}
+	for _, v := range sizes {
+		if s, ok := lookupEnv(v.name); ok {
+			n, err := parseSize(s)
+			if err != nil {
+				return envError(v.name, s, err)
+			}
+			*v.dst = n
+		}
+	}
+
+	if s, ok := lookupEnv("MMAP_MIN_ACCESSES"); ok {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return envError("MMAP_MIN_ACCESSES", s, err)
+		}
+		config.MmapMinAccesses = n
+	}
+
+	ratios := []struct {
+		name string
+		dst  *float64
+	}{
+		{"TTL_JITTER", &config.TTLJitter},
+		{"EVICT_HIGH_WATERMARK", &config.EvictHighWatermark},
+		{"EVICT_LOW_WATERMARK", &config.EvictLowWatermark},
+	}
+	for _, v := range ratios {
+		if s, ok := lookupEnv(v.name); ok {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return envError(v.name, s, err)
+			}
+			*v.dst = f
+		}
+	}
+
+	durations := []struct {
+		name string
+		dst  *time.Duration
+	}{
+		{"DEFAULT_TTL", &config.DefaultTTL},
+		{"CLEANUP_INTERVAL", &config.CleanupInterval},
+		{"TRASH_RETENTION", &config.TrashRetention},
+		{"STALE_RETENTION", &config.StaleRetention},
+		{"TTL_JITTER_MAX", &config.TTLJitterMax},
+		{"EVICT_INTERVAL", &config.EvictInterval},
+	}
+	for _, v := range durations {
+		if s, ok := lookupEnv(v.name); ok {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return envError(v.name, s, err)
+			}
+			*v.dst = d
+		}
+	}
+
+	bools := []struct {
+		name string
+		dst  *bool
+	}{
+		{"COMPRESSION", &config.Compression},
+		{"SOFT_DELETE", &config.SoftDelete},
+	}
+	for _, v := range bools {
+		if s, ok := lookupEnv(v.name); ok {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return envError(v.name, s, err)
+			}
+			*v.dst = b
+		}
+	}
+	return nil
+}
+
+// lookupEnv 返回EnvPrefix+name的值，没有设置或为空时返回false
+func lookupEnv(name string) (string, bool) {
+	s := strings.TrimSpace(os.Getenv(EnvPrefix + name))
+	return s, s != ""
+}
+
+// envError 返回环境变量的值无效的错误
+func envError(name, value string, err error) error {
+	return fmt.Errorf("invalid %s%s %q: %w", EnvPrefix, name, value, err)
+}
+
+// sizeUnits 字节数的单位，按1024换算
+var sizeUnits = []struct {
+	suffix string
+	shift  uint
+}{
+	{"TB", 40}, {"GB", 30}, {"MB", 20}, {"KB", 10},
+	{"T", 40}, {"G", 30}, {"M", 20}, {"K", 10},
+	{"B", 0},
+}
+
+// parseSize 解析可以带单位的字节数，例如"512MB"、"10G"、"1048576"
+func parseSize(s string) (int64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	shift := uint(0)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(upper, unit.suffix) {
+			upper = strings.TrimSpace(strings.TrimSuffix(upper, unit.suffix))
+			shift = unit.shift
+			break
+		}
+	}
+	n, err := strconv.ParseInt(upper, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || n > (1<<63-1)>>shift {
+		return 0, fmt.Errorf("size out of range")
+	}
+	return n << shift, nil
+}
@@ -0,0 +1,92 @@
+package filecache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestApplyEnv(t *testing.T) {
+	t.Run("overrides", func(t *testing.T) {
+		t.Setenv("EDGEORIGIN_DATA_DIR", "/data/cache")
+		t.Setenv("EDGEORIGIN_MAX_CACHE_SIZE", "10GB")
+		t.Setenv("EDGEORIGIN_MAX_ENTRY_SIZE", "512m")
+		t.Setenv("EDGEORIGIN_DEFAULT_TTL", "2h")
+		t.Setenv("EDGEORIGIN_STALE_RETENTION", "10m")
+		t.Setenv("EDGEORIGIN_COMPRESSION", "false")
+		t.Setenv("EDGEORIGIN_SOFT_DELETE", "")
+		t.Setenv("EDGEORIGIN_EVICT_HIGH_WATERMARK", "0.9")
+		t.Setenv("EDGEORIGIN_EVICT_INTERVAL", "30s")
+
+		config, err := LoadConfigFromEnv()
+		if err != nil {
+			t.Fatalf("Failed to load config from env: %v", err)
+		}
+		if config.DataDir != "/data/cache" || config.MaxCacheSize != 10<<30 || config.MaxEntrySize != 512<<20 {
+			t.Errorf("Unexpected overrides: %+v", config)
+		}
+		if config.DefaultTTL != 2*time.Hour || config.StaleRetention != 10*time.Minute || config.Compression {
+			t.Errorf("Unexpected overrides: %+v", config)
+		}
+		if config.EvictHighWatermark != 0.9 || config.EvictInterval != 30*time.Second {
+			t.Errorf("Unexpected eviction overrides: %+v", config)
+		}
+		if config.CleanupInterval != time.Hour || config.SoftDelete {
+			t.Errorf("Expected unset variables to keep defaults, got %+v", config)
+		}
+	})
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache.yaml")
+		if err := os.WriteFile(path, []byte("data_dir: /from/file\ndefault_ttl: 1h\n"), 0644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		t.Setenv("EDGEORIGIN_DEFAULT_TTL", "5m")
+
+		config, err := LoadConfigFromFile(path)
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if config.DataDir != "/from/file" || config.DefaultTTL != 5*time.Minute {
+			t.Errorf("Expected env to override file, got data dir %q TTL %v", config.DataDir, config.DefaultTTL)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for name, value := range map[string]string{
+			"EDGEORIGIN_MAX_CACHE_SIZE":      "lots",
+			"EDGEORIGIN_DEFAULT_TTL":         "1 day",
+			"EDGEORIGIN_COMPRESSION":         "maybe",
+			"EDGEORIGIN_MMAP_MIN_ACCESSES":   "two",
+			"EDGEORIGIN_EVICT_LOW_WATERMARK": "80%",
+		} {
+			t.Run(name, func(t *testing.T) {
+				t.Setenv(name, value)
+				if _, err := LoadConfigFromEnv(); err == nil {
+					t.Errorf("Expected error for %s=%q", name, value)
+				}
+			})
+		}
+	})
+}
+
+func TestParseSize(t *testing.T) {
+	for s, expected := range map[string]int64{
+		"1048576": 1 << 20,
+		"64K":     64 << 10,
+		"512 MB":  512 << 20,
+		"10gb":    10 << 30,
+		"2T":      2 << 40,
+		"100B":    100,
+	} {
+		if n, err := parseSize(s); err != nil || n != expected {
+			t.Errorf("Expected %q to be %d, got %d (%v)", s, expected, n, err)
+		}
+	}
+	for _, s := range []string{"", "GB", "-1", "1.5G", "9999999999T"} {
+		if _, err := parseSize(s); err == nil {
+			t.Errorf("Expected error for %q", s)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package filecache
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotFound 文件不存在
+	ErrNotFound = errors.New("file not found")
+
+	// ErrEntryTooLarge 单个文件超过 Config.MaxEntrySize
+	ErrEntryTooLarge = errors.New("entry too large")
+
+	// ErrQuotaExceeded 命名空间超出配额，具体信息见 QuotaError
+	ErrQuotaExceeded = errors.New("namespace quota exceeded")
+
+	// ErrCacheClosed 缓存已关闭
+	ErrCacheClosed = errors.New("cache closed")
+
+	// ErrExists 文件已经存在，例如从回收站恢复时同名的文件已经重新写入
+	ErrExists = errors.New("file already exists")
+
+	// ErrExpired 文件已过期但尚未被清理，errors.Is(err, ErrNotFound) 同样成立
+	ErrExpired error = expiredError{}
+)
+
+// expiredError ErrExpired的类型，使过期的文件对只关心是否存在的调用方表现为不存在
+type expiredError struct{}
+
+func (expiredError) Error() string {
+	return "file expired"
+}
+
+// Is 使 errors.Is(err, ErrNotFound) 成立
+func (expiredError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// QuotaError 命名空间配额错误
+type QuotaError struct {
+	Namespace string // 命名空间的完整路径
+	Quota     int64  // 配额（字节）
+	Used      int64  // 淘汰后仍占用的字节数
+	Size      int64  // 待写入文件的大小
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("namespace %q quota exceeded: used %d + size %d > quota %d", e.Namespace, e.Used, e.Size, e.Quota)
+}
+
+// Is 使 errors.Is(err, ErrQuotaExceeded) 成立
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
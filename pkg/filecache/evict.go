@@ -0,0 +1,199 @@
+package filecache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	// defaultEvictInterval 默认检查水位的间隔
+	defaultEvictInterval = 10 * time.Second
+	// defaultEvictGap 没有设置低水位时，低水位比高水位低的比例
+	defaultEvictGap = 0.1
+)
+
+// evictWatermarks 返回按水位淘汰的高低水位，没有启用时返回false
+func (c *Config) evictWatermarks() (high, low float64, ok bool) {
+	if c.EvictHighWatermark <= 0 {
+		return 0, 0, false
+	}
+	low = c.EvictLowWatermark
+	if low <= 0 {
+		low = c.EvictHighWatermark - defaultEvictGap
+	}
+	return c.EvictHighWatermark, low, true
+}
+
+// evictInterval 返回检查水位的间隔
+func (c *Config) evictInterval() time.Duration {
+	if c.EvictInterval > 0 {
+		return c.EvictInterval
+	}
+	return defaultEvictInterval
+}
+
+// watermarkExcess 返回降到低水位需要释放的字节数：缓存大小或磁盘使用量达到高水位时，取两者中超出低水位更多的一个，
+// 都没有达到高水位时返回0。淘汰只能释放缓存自己占用的空间，结果不超过used。
+// capacity或diskTotal不大于0时不检查对应的一项
+func watermarkExcess(high, low float64, used, capacity, diskUsed, diskTotal int64) int64 {
+	var need int64
+	for _, u := range []struct{ used, total int64 }{{used, capacity}, {diskUsed, diskTotal}} {
+		if u.total <= 0 || float64(u.used) < high*float64(u.total) {
+			continue
+		}
+		if n := u.used - int64(low*float64(u.total)); n > need {
+			need = n
+		}
+	}
+	if need > used {
+		need = used
+	}
+	return need
+}
+
+// startEvictRoutine 定期检查水位，超过高水位时淘汰到低水位，ctx取消后退出
+func (c *badgerCache) startEvictRoutine(ctx context.Context) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.config.Load().evictInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// 记录错误但不中断淘汰协程，淘汰的文件数见Stats.Evictions
+			_, _, _ = c.evictToWatermark(ctx)
+		}
+	}
+}
+
+// evictToWatermark 缓存大小（所有命名空间统计信息中的总大小之和）达到MaxCacheSize的高水位，或者数据目录所在磁盘的
+// 使用量达到容量的高水位时，淘汰所有命名空间中的文件直到降到低水位，返回淘汰的文件数和字节数。
+// 磁盘使用量按文件系统实际占用计算，统计信息与实际占用有偏差、或者磁盘上还有其他数据时也能及时淘汰
+func (c *badgerCache) evictToWatermark(ctx context.Context) (int64, int64, error) {
+	config := c.config.Load()
+	if _, _, ok := config.evictWatermarks(); !ok {
+		return 0, 0, nil
+	}
+	total, free, err := diskSpace(config.DataDir)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get disk space: %w", err)
+	}
+	return c.evictForDisk(ctx, total-free, total)
+}
+
+// evictForDisk 按磁盘使用量diskUsed和容量diskTotal检查水位并淘汰。按磁盘水位淘汰后记录当时的使用量，
+// 淘汰释放的空间在文件系统上体现出来（使用量低于记录的值）之前不再按磁盘水位淘汰：Badger的value log可能还没有回收，
+// 磁盘也可能被其他数据占满，继续淘汰只会在每次检查时清空新写入的文件。缓存大小的水位不受影响
+func (c *badgerCache) evictForDisk(ctx context.Context, diskUsed, diskTotal int64) (int64, int64, error) {
+	config := c.config.Load()
+	high, low, ok := config.evictWatermarks()
+	if !ok {
+		return 0, 0, nil
+	}
+
+	caches, err := c.allNamespaces()
+	if err != nil {
+		return 0, 0, err
+	}
+	var used int64
+	for _, ns := range caches {
+		ns.mu.RLock()
+		used += ns.stats.TotalSize
+		ns.mu.RUnlock()
+	}
+	if mark := c.evictMark.Load(); mark > 0 && diskUsed >= mark {
+		diskTotal = 0
+	} else {
+		c.evictMark.Store(0)
+	}
+	need := watermarkExcess(high, low, used, config.MaxCacheSize, diskUsed, diskTotal)
+	if need <= 0 {
+		return 0, 0, nil
+	}
+	if diskTotal > 0 && float64(diskUsed) >= high*float64(diskTotal) {
+		c.evictMark.Store(diskUsed)
+	}
+	return c.evictBytes(ctx, caches, need)
+}
+
+// evictBytes 按过期优先、最后访问时间从旧到新的顺序淘汰caches中的文件，直到释放need字节；
+// Badger中的文件数据在value log重写后才释放磁盘空间，淘汰后回收一次
+func (c *badgerCache) evictBytes(ctx context.Context, caches []*badgerCache, need int64) (int64, int64, error) {
+	type candidate struct {
+		cache *badgerCache
+		info  *FileInfo
+	}
+	var candidates []candidate
+	for _, ns := range caches {
+		files, err := ns.List(ctx)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, file := range files {
+			candidates = append(candidates, candidate{ns, file})
+		}
+	}
+	now := time.Now()
+	sort.Slice(candidates, func(i, j int) bool {
+		return evictBefore(candidates[i].info, candidates[j].info, now)
+	})
+
+	var evicted, freed int64
+	for _, e := range candidates {
+		if freed >= need {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return evicted, freed, err
+		}
+		if err := e.cache.remove(e.info.Key); err != nil {
+			return evicted, freed, err
+		}
+		evicted++
+		freed += e.info.Size
+
+		e.cache.mu.Lock()
+		e.cache.stats.Evictions++
+		e.cache.mu.Unlock()
+	}
+	if evicted == 0 || c.blobs != nil {
+		return evicted, freed, nil
+	}
+	err := c.store.withDB(func(db *badger.DB) error {
+		return reclaimValueLog(ctx, db)
+	})
+	return evicted, freed, err
+}
+
+// evictBefore 淘汰顺序：已过期的文件优先，其次按最后访问时间从旧到新
+func evictBefore(a, b *FileInfo, now time.Time) bool {
+	aExpired, bExpired := now.After(a.ExpiresAt), now.After(b.ExpiresAt)
+	if aExpired != bExpired {
+		return aExpired
+	}
+	return a.LastAccess.Before(b.LastAccess)
+}
+
+// allNamespaces 返回当前缓存及其所有子命名空间
+func (c *badgerCache) allNamespaces() ([]*badgerCache, error) {
+	caches := []*badgerCache{c}
+	names, err := c.registeredNamespaces()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		children, err := c.Namespace(name).(*badgerCache).allNamespaces()
+		if err != nil {
+			return nil, err
+		}
+		caches = append(caches, children...)
+	}
+	return caches, nil
+}
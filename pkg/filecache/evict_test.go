@@ -0,0 +1,167 @@
+package filecache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatermarkExcess(t *testing.T) {
+	tests := []struct {
+		name                                string
+		used, capacity, diskUsed, diskTotal int64
+		want                                int64
+	}{
+		{"below", 800, 1000, 10, 100, 0},
+		{"cache", 900, 1000, 10, 100, 400},
+		{"disk", 100, 1000, 95, 100, 45},
+		{"both", 950, 1000, 95, 100, 450},
+		{"no capacity", 900, 0, 0, 0, 0},
+		{"disk beyond cache", 30, 1000, 95, 100, 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := watermarkExcess(0.9, 0.5, tt.used, tt.capacity, tt.diskUsed, tt.diskTotal); got != tt.want {
+				t.Errorf("Expected %d bytes to be freed, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEvictToWatermark(t *testing.T) {
+	ctx := context.Background()
+	data := strings.Repeat("x", 200)
+
+	t.Run("Namespaces", func(t *testing.T) {
+		cache, err := NewBadgerCache(&Config{
+			DataDir:            t.TempDir(),
+			MaxCacheSize:       1000,
+			DefaultTTL:         time.Hour,
+			CleanupInterval:    time.Hour,
+			EvictHighWatermark: 0.9,
+			EvictLowWatermark:  0.5,
+			EvictInterval:      time.Hour,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		defer cache.Close()
+
+		root := cache.(*badgerCache)
+		tenant := cache.Namespace("tenant")
+		for _, e := range []struct {
+			cache Cache
+			key   string
+		}{{cache, "a"}, {cache, "b"}, {tenant, "c"}, {tenant, "d"}} {
+			if err := e.cache.Set(ctx, e.key, strings.NewReader(data), "text/plain", time.Hour); err != nil {
+				t.Fatalf("Failed to set %s: %v", e.key, err)
+			}
+		}
+		readString(t, cache, "a")
+
+		if evicted, _, err := root.evictToWatermark(ctx); err != nil || evicted != 0 {
+			t.Fatalf("Expected nothing to be evicted below the high watermark, got %d %v", evicted, err)
+		}
+
+		if err := tenant.Set(ctx, "e", strings.NewReader(data), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		evicted, freed, err := root.evictToWatermark(ctx)
+		if err != nil {
+			t.Fatalf("Failed to evict: %v", err)
+		}
+		if evicted != 3 || freed != 600 {
+			t.Errorf("Expected 3 files to be evicted down to the low watermark, got %d (%d bytes)", evicted, freed)
+		}
+		for _, e := range []struct {
+			cache  Cache
+			key    string
+			exists bool
+		}{{cache, "a", true}, {cache, "b", false}, {tenant, "c", false}, {tenant, "d", false}, {tenant, "e", true}} {
+			if ok, _ := e.cache.Exists(ctx, e.key); ok != e.exists {
+				t.Errorf("Expected %s to exist: %v, got %v", e.key, e.exists, ok)
+			}
+		}
+		rootStats, _ := cache.Stats()
+		tenantStats, _ := tenant.Stats()
+		if rootStats.Evictions != 1 || tenantStats.Evictions != 2 || rootStats.TotalSize+tenantStats.TotalSize != 400 {
+			t.Errorf("Expected evictions to be counted per namespace, got %+v %+v", rootStats, tenantStats)
+		}
+	})
+
+	t.Run("DiskStaysFull", func(t *testing.T) {
+		cache, err := NewBadgerCache(&Config{
+			DataDir:            t.TempDir(),
+			MaxCacheSize:       1024 * 1024,
+			DefaultTTL:         time.Hour,
+			CleanupInterval:    time.Hour,
+			EvictHighWatermark: 0.9,
+			EvictLowWatermark:  0.88,
+			EvictInterval:      time.Hour,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		defer cache.Close()
+
+		root := cache.(*badgerCache)
+		set := func(keys ...string) {
+			for _, key := range keys {
+				if err := cache.Set(ctx, key, strings.NewReader(data), "text/plain", time.Hour); err != nil {
+					t.Fatalf("Failed to set %s: %v", key, err)
+				}
+			}
+		}
+		set("a", "b", "c", "d", "e")
+		if evicted, freed, err := root.evictForDisk(ctx, 9500, 10000); err != nil || evicted != 4 || freed != 800 {
+			t.Fatalf("Expected 4 files to be evicted for the disk watermark, got %d (%d bytes) %v", evicted, freed, err)
+		}
+
+		// 磁盘被其他数据占满，淘汰释放的空间没有体现出来，新写入的文件不再被淘汰
+		set("f", "g", "h")
+		if evicted, _, err := root.evictForDisk(ctx, 9500, 10000); err != nil || evicted != 0 {
+			t.Errorf("Expected nothing to be evicted while the disk usage has not dropped, got %d %v", evicted, err)
+		}
+		if stats, _ := cache.Stats(); stats.TotalFiles != 4 {
+			t.Errorf("Expected new files to be kept, got %d files", stats.TotalFiles)
+		}
+
+		// 使用量下降后恢复按磁盘水位淘汰
+		if evicted, freed, err := root.evictForDisk(ctx, 9400, 10000); err != nil || evicted != 3 || freed != 600 {
+			t.Errorf("Expected eviction to resume once the disk usage drops, got %d (%d bytes) %v", evicted, freed, err)
+		}
+	})
+
+	t.Run("Background", func(t *testing.T) {
+		cache, err := NewBadgerCache(&Config{
+			DataDir:            t.TempDir(),
+			MaxCacheSize:       1000,
+			DefaultTTL:         time.Hour,
+			CleanupInterval:    time.Hour,
+			EvictHighWatermark: 0.9,
+			EvictInterval:      10 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		defer cache.Close()
+
+		for _, key := range []string{"a", "b", "c", "d", "e"} {
+			if err := cache.Set(ctx, key, strings.NewReader(data), "text/plain", time.Hour); err != nil {
+				t.Fatalf("Failed to set %s: %v", key, err)
+			}
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			stats, _ := cache.Stats()
+			if stats.TotalSize <= 800 && stats.Evictions > 0 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected the evictor to free space down to the low watermark, got %+v", stats)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
@@ -0,0 +1,222 @@
+package filecache
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// exportManifestName 导出包中第一个条目的名称，用于识别格式
+	exportManifestName = ".edgeorigin-export.json"
+	// exportVersion 导出格式版本
+	exportVersion = 1
+
+	// 保存FileInfo的PAX扩展记录
+	paxMimeType    = "EDGEORIGIN.mime_type"
+	paxExpiresAt   = "EDGEORIGIN.expires_at"
+	paxAccessCount = "EDGEORIGIN.access_count"
+)
+
+// ExportOptions 导出选项，零值导出全部未过期的文件
+type ExportOptions struct {
+	// Prefix 只导出键以Prefix开头的文件
+	Prefix string
+
+	// MinAccessCount 只导出访问次数不少于这个值的文件，例如只用热点文件预热新节点
+	MinAccessCount int64
+}
+
+// ImportOptions 导入选项，零值导入导出包中全部未过期的文件
+type ImportOptions struct {
+	// Prefix 只导入键以Prefix开头的文件
+	Prefix string
+
+	// MinAccessCount 只导入导出时访问次数不少于这个值的文件；旧的导出包没有记录访问次数，视为0
+	MinAccessCount int64
+
+	// SkipExisting 跳过缓存中已经存在的文件，不覆盖节点自己回源得到的较新内容
+	SkipExisting bool
+}
+
+// exportManifest 导出包描述
+type exportManifest struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+// Export 把缓存当前命名空间中所有未过期的文件写入tar流，返回导出的文件数
+// 每个文件对应一个tar条目，条目名为缓存键，修改时间为创建时间，MIME类型、过期时间和访问次数保存在PAX扩展记录中
+func Export(ctx context.Context, cache Cache, w io.Writer) (int, error) {
+	return ExportWithOptions(ctx, cache, w, ExportOptions{})
+}
+
+// ExportWithOptions 与Export相同，只导出符合opts的文件
+func ExportWithOptions(ctx context.Context, cache Cache, w io.Writer, opts ExportOptions) (int, error) {
+	files, err := cache.List(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	tw := tar.NewWriter(w)
+	manifest, err := json.Marshal(exportManifest{Version: exportVersion, ExportedAt: time.Now()})
+	if err != nil {
+		return 0, err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     exportManifestName,
+		Mode:     0644,
+		Size:     int64(len(manifest)),
+		ModTime:  time.Now(),
+	}); err != nil {
+		return 0, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if _, err := tw.Write(manifest); err != nil {
+		return 0, fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	exported := 0
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return exported, err
+		}
+		if time.Now().After(file.ExpiresAt) || !strings.HasPrefix(file.Key, opts.Prefix) || file.AccessCount < opts.MinAccessCount {
+			continue
+		}
+
+		ok, err := exportFile(ctx, cache, tw, file)
+		if err != nil {
+			return exported, err
+		}
+		if ok {
+			exported++
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return exported, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return exported, nil
+}
+
+// exportFile 写入单个文件，记录的访问次数取自列出时的file；文件在列出后被删除或过期时返回false
+// 缓存实现Peeker时只读地读取文件，导出不影响访问次数、命中率和淘汰顺序
+func exportFile(ctx context.Context, cache Cache, tw *tar.Writer, file *FileInfo) (bool, error) {
+	key := file.Key
+	get := cache.Get
+	if peeker, ok := cache.(Peeker); ok {
+		get = peeker.Peek
+	}
+	reader, info, err := get(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		// 列出后被删除，或已过期（ErrExpired）
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %q: %w", key, err)
+	}
+	defer reader.Close()
+	if time.Now().After(info.ExpiresAt) {
+		return false, nil
+	}
+
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     key,
+		Mode:     0644,
+		Size:     info.Size,
+		ModTime:  info.CreatedAt,
+		Format:   tar.FormatPAX,
+		PAXRecords: map[string]string{
+			paxMimeType:    info.MimeType,
+			paxExpiresAt:   info.ExpiresAt.UTC().Format(time.RFC3339Nano),
+			paxAccessCount: strconv.FormatInt(file.AccessCount, 10),
+		},
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return false, fmt.Errorf("failed to write header for %q: %w", key, err)
+	}
+	if _, err := io.CopyN(tw, reader, info.Size); err != nil {
+		return false, fmt.Errorf("failed to write %q: %w", key, err)
+	}
+	return true, nil
+}
+
+// Import 从Export生成的tar流中恢复文件到缓存，保留剩余TTL，已过期的文件被跳过，返回导入的文件数
+func Import(ctx context.Context, cache Cache, r io.Reader) (int, error) {
+	return ImportWithOptions(ctx, cache, r, ImportOptions{})
+}
+
+// ImportWithOptions 与Import相同，只导入符合opts的文件；访问次数不随文件导入，从0开始计数
+func ImportWithOptions(ctx context.Context, cache Cache, r io.Reader, opts ImportOptions) (int, error) {
+	tr := tar.NewReader(r)
+
+	header, err := tr.Next()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var manifest exportManifest
+	if header.Name != exportManifestName {
+		return 0, fmt.Errorf("not a cache export: missing manifest")
+	}
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return 0, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if manifest.Version != exportVersion {
+		return 0, fmt.Errorf("unsupported export version %d", manifest.Version)
+	}
+
+	imported := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return imported, err
+		}
+
+		header, err := tr.Next()
+		if err == io.EOF {
+			return imported, nil
+		}
+		if err != nil {
+			return imported, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg || !strings.HasPrefix(header.Name, opts.Prefix) {
+			continue
+		}
+		if opts.MinAccessCount > 0 {
+			accesses, _ := strconv.ParseInt(header.PAXRecords[paxAccessCount], 10, 64)
+			if accesses < opts.MinAccessCount {
+				continue
+			}
+		}
+
+		expiresAt, err := time.Parse(time.RFC3339Nano, header.PAXRecords[paxExpiresAt])
+		if err != nil {
+			return imported, fmt.Errorf("invalid expiry for %q: %w", header.Name, err)
+		}
+		ttl, ok := remainingTTL(expiresAt)
+		if !ok {
+			continue
+		}
+		if opts.SkipExisting {
+			exists, err := cache.Exists(ctx, header.Name)
+			if err != nil {
+				return imported, fmt.Errorf("failed to check %q: %w", header.Name, err)
+			}
+			if exists {
+				continue
+			}
+		}
+
+		if err := cache.Set(ctx, header.Name, tr, header.PAXRecords[paxMimeType], ttl); err != nil {
+			return imported, fmt.Errorf("failed to import %q: %w", header.Name, err)
+		}
+		imported++
+	}
+}
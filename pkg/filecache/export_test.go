@@ -0,0 +1,149 @@
+package filecache
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+
+	src := newTestBadgerCache(t)
+	defer src.Close()
+
+	files := map[string]string{
+		"index.html":       "<html></html>",
+		"img/logo.png":     "png data",
+		"path/with spaces": "spaces",
+	}
+	for key, data := range files {
+		if err := src.Set(ctx, key, strings.NewReader(data), "text/plain", 2*time.Hour); err != nil {
+			t.Fatalf("Failed to set %s: %v", key, err)
+		}
+	}
+	if err := src.Set(ctx, "expired", strings.NewReader("old"), "text/plain", 10*time.Millisecond); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	var buf bytes.Buffer
+	exported, err := Export(ctx, src, &buf)
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	if exported != len(files) {
+		t.Errorf("Expected %d exported files, got %d", len(files), exported)
+	}
+
+	// 导出包是标准tar，可以用常规工具查看
+	tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, header.Name)
+	}
+	if len(names) != len(files)+1 || names[0] != exportManifestName {
+		t.Errorf("Unexpected archive entries: %v", names)
+	}
+
+	dst := NewMemoryCache(1024 * 1024)
+	imported, err := Import(ctx, dst, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if imported != len(files) {
+		t.Errorf("Expected %d imported files, got %d", len(files), imported)
+	}
+
+	for key, data := range files {
+		if got := readString(t, dst, key); got != data {
+			t.Errorf("Expected %q for %s, got %q", data, key, got)
+		}
+	}
+	info, err := dst.GetInfo(ctx, "img/logo.png")
+	if err != nil {
+		t.Fatalf("Failed to get info: %v", err)
+	}
+	if info.MimeType != "text/plain" || time.Until(info.ExpiresAt) < 119*time.Minute {
+		t.Errorf("Expected FileInfo to be preserved, got %+v", info)
+	}
+
+	t.Run("Filters", func(t *testing.T) {
+		// 使用新的缓存，访问次数只来自下面的读取
+		src := newTestBadgerCache(t)
+		defer src.Close()
+		for key, data := range files {
+			if err := src.Set(ctx, key, strings.NewReader(data), "text/plain", time.Hour); err != nil {
+				t.Fatalf("Failed to set %s: %v", key, err)
+			}
+		}
+		for i := 0; i < 3; i++ {
+			readString(t, src, "img/logo.png")
+		}
+		readString(t, src, "index.html")
+
+		var buf bytes.Buffer
+		exported, err := ExportWithOptions(ctx, src, &buf, ExportOptions{MinAccessCount: 1})
+		if err != nil || exported != 2 {
+			t.Fatalf("Expected 2 files read at least once, got %d %v", exported, err)
+		}
+		archive := buf.Bytes()
+
+		// 导出只读地读取文件，不计入访问次数
+		if info, err := src.GetInfo(ctx, "img/logo.png"); err != nil || info.AccessCount != 3 {
+			t.Errorf("Expected export not to count as an access, got %+v (%v)", info, err)
+		}
+
+		for _, c := range []struct {
+			name string
+			opts ImportOptions
+			want []string
+		}{
+			{"Prefix", ImportOptions{Prefix: "img/"}, []string{"img/logo.png"}},
+			{"MinAccessCount", ImportOptions{MinAccessCount: 3}, []string{"img/logo.png"}},
+			{"All", ImportOptions{}, []string{"img/logo.png", "index.html"}},
+		} {
+			dst := NewMemoryCache(1024 * 1024)
+			imported, err := ImportWithOptions(ctx, dst, bytes.NewReader(archive), c.opts)
+			files, _ := dst.List(ctx)
+			var keys []string
+			for _, f := range files {
+				keys = append(keys, f.Key)
+			}
+			sort.Strings(keys)
+			if err != nil || imported != len(c.want) || strings.Join(keys, ",") != strings.Join(c.want, ",") {
+				t.Errorf("%s: expected %v, got %v (%d imported, %v)", c.name, c.want, keys, imported, err)
+			}
+		}
+
+		// 已存在的文件不被覆盖
+		dst := NewMemoryCache(1024 * 1024)
+		if err := dst.Set(ctx, "index.html", strings.NewReader("fresh"), "text/html", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		imported, err := ImportWithOptions(ctx, dst, bytes.NewReader(archive), ImportOptions{SkipExisting: true})
+		if err != nil || imported != 1 || readString(t, dst, "index.html") != "fresh" {
+			t.Errorf("Expected existing files to be kept, got %d imported, %v", imported, err)
+		}
+	})
+
+	t.Run("InvalidArchive", func(t *testing.T) {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		tw.WriteHeader(&tar.Header{Name: "random.txt", Mode: 0644, Size: 1})
+		tw.Write([]byte("x"))
+		tw.Close()
+
+		if _, err := Import(ctx, dst, &buf); err == nil {
+			t.Error("Expected error for archive without manifest")
+		}
+	})
+}
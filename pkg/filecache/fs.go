@@ -0,0 +1,225 @@
+package filecache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FS 返回基于缓存的只读文件系统，实现fs.FS、fs.StatFS、fs.ReadDirFS和fs.ReadFileFS；
+// 缓存键中的"/"对应目录层级，目录由键的前缀推导出来，列出目录需要调用List。
+// 配合http.FS可以用于http.FileServer：
+//
+//	http.Handle("/", http.FileServer(http.FS(filecache.FS(cache))))
+func FS(cache Cache) fs.FS {
+	return &cacheFS{cache: cache}
+}
+
+// cacheFS 基于缓存的只读文件系统
+type cacheFS struct {
+	cache Cache
+}
+
+// Open 打开文件或目录，文件内容在第一次读取时才从缓存获取
+func (c *cacheFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	ctx := context.Background()
+
+	if name != "." {
+		info, err := c.cache.GetInfo(ctx, name)
+		if err == nil && !time.Now().After(info.ExpiresAt) {
+			return &fsFile{
+				info:   info,
+				reader: &lazySeeker{ctx: ctx, cache: c.cache, key: name, size: info.Size},
+			}, nil
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+	}
+
+	entries, modTime, err := c.children(ctx, name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if name != "." && len(entries) == 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &fsDir{info: &fsFileInfo{name: path.Base(name), modTime: modTime, dir: true}, entries: entries}, nil
+}
+
+// children 列出目录下按名称排序的直接子项，返回其中最新文件的创建时间
+func (c *cacheFS) children(ctx context.Context, dir string) ([]fs.DirEntry, time.Time, error) {
+	files, err := c.cache.List(ctx)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	prefix := ""
+	if dir != "." {
+		prefix = dir + "/"
+	}
+	now := time.Now()
+	var modTime time.Time
+	byName := make(map[string]*fsFileInfo)
+	for _, info := range files {
+		rest, ok := strings.CutPrefix(info.Key, prefix)
+		if !ok || rest == "" || now.After(info.ExpiresAt) {
+			continue
+		}
+		if info.CreatedAt.After(modTime) {
+			modTime = info.CreatedAt
+		}
+
+		name, _, nested := strings.Cut(rest, "/")
+		if !fs.ValidPath(name) {
+			continue
+		}
+		entry, ok := byName[name]
+		if !ok {
+			entry = &fsFileInfo{name: name, dir: nested}
+			byName[name] = entry
+		}
+		switch {
+		case !nested:
+			// 同名的文件和目录同时存在时按文件处理，与Open一致
+			entry.dir, entry.size, entry.modTime, entry.mimeType = false, info.Size, info.CreatedAt, info.MimeType
+		case entry.dir && info.CreatedAt.After(entry.modTime):
+			entry.modTime = info.CreatedAt
+		}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(byName))
+	for _, info := range byName {
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, modTime, nil
+}
+
+// Stat 返回文件或目录的信息
+func (c *cacheFS) Stat(name string) (fs.FileInfo, error) {
+	f, err := c.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+// ReadDir 返回目录下按名称排序的子项
+func (c *cacheFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, err := c.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dir, ok := f.(*fsDir)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return dir.ReadDir(-1)
+}
+
+// ReadFile 读取整个文件
+func (c *cacheFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+	reader, _, err := c.cache.Get(context.Background(), name)
+	if errors.Is(err, ErrNotFound) {
+		err = fs.ErrNotExist
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// fsFileInfo 实现fs.FileInfo
+type fsFileInfo struct {
+	name     string
+	size     int64
+	modTime  time.Time
+	mimeType string
+	dir      bool
+}
+
+func (fi *fsFileInfo) Name() string       { return fi.name }
+func (fi *fsFileInfo) Size() int64        { return fi.size }
+func (fi *fsFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fsFileInfo) IsDir() bool        { return fi.dir }
+
+// Sys 文件返回缓存中的MIME类型
+func (fi *fsFileInfo) Sys() interface{} {
+	if fi.dir {
+		return nil
+	}
+	return fi.mimeType
+}
+
+func (fi *fsFileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+// fsFile 缓存中的文件，支持Seek，可以用于http.ServeContent
+type fsFile struct {
+	info   *FileInfo
+	reader *lazySeeker
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) {
+	return &fsFileInfo{
+		name:     path.Base(f.info.Key),
+		size:     f.info.Size,
+		modTime:  f.info.CreatedAt,
+		mimeType: f.info.MimeType,
+	}, nil
+}
+
+func (f *fsFile) Read(p []byte) (int, error)                   { return f.reader.Read(p) }
+func (f *fsFile) Seek(offset int64, whence int) (int64, error) { return f.reader.Seek(offset, whence) }
+func (f *fsFile) Close() error                                 { return f.reader.Close() }
+
+// fsDir 目录，子项在打开时列出
+type fsDir struct {
+	info    *fsFileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *fsDir) Close() error               { return nil }
+
+func (d *fsDir) Read(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")}
+}
+
+// ReadDir 按fs.ReadDirFile的约定返回子项
+func (d *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+	return rest[:n], nil
+}
@@ -0,0 +1,79 @@
+package filecache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestFS(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemoryCache(1 << 20)
+	defer cache.Close()
+	for key, content := range map[string]string{
+		"index.html":         "<h1>home</h1>",
+		"css/site.css":       "body{}",
+		"img/logo.png":       "png data",
+		"img/icons/home.svg": "<svg/>",
+	} {
+		if err := cache.Set(ctx, key, strings.NewReader(content), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+	}
+	fsys := FS(cache)
+
+	t.Run("TestFS", func(t *testing.T) {
+		if err := fstest.TestFS(fsys, "index.html", "css/site.css", "img/logo.png", "img/icons/home.svg"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("ReadDir", func(t *testing.T) {
+		entries, err := fs.ReadDir(fsys, "img")
+		if err != nil {
+			t.Fatalf("Failed to read dir: %v", err)
+		}
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		if strings.Join(names, ",") != "icons,logo.png" || !entries[0].IsDir() {
+			t.Errorf("Expected icons/ and logo.png, got %v", names)
+		}
+		if _, err := fs.Stat(fsys, "missing"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Expected ErrNotExist, got %v", err)
+		}
+	})
+
+	t.Run("FileServer", func(t *testing.T) {
+		srv := httptest.NewServer(http.FileServer(http.FS(fsys)))
+		defer srv.Close()
+
+		resp, err := http.Get(srv.URL + "/img/logo.png")
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(data) != "png data" {
+			t.Errorf("Expected 200 with 'png data', got %d with %q", resp.StatusCode, data)
+		}
+
+		resp, err = http.Get(srv.URL + "/")
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		data, _ = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(data) != "<h1>home</h1>" {
+			t.Errorf("Expected index.html to be served, got %q", data)
+		}
+	})
+}
@@ -0,0 +1,203 @@
+package filecache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// blobLockStripes 键锁的分段数，不同的键可能共用一把锁
+const blobLockStripes = 256
+
+// blobStore 文件系统上的文件数据存储
+// 布局为 <dir>/<命名空间目录>/<哈希前2位>/<哈希3-4位>/<哈希>，回收站文件位于命名空间目录下的trash子目录
+type blobStore struct {
+	dir   string
+	maps  *mmapRegistry               // 非空时访问频繁的大文件通过共享的内存映射读取
+	locks [blobLockStripes]sync.Mutex // 按键分段的锁，见lockBlob
+}
+
+// NewFSBlobCache 创建文件数据存放在文件系统、FileInfo存放在Badger中的文件缓存
+// 适合大体积媒体文件：写入时流式落盘，Get返回*os.File，可直接用于sendfile等高效传输
+// 设置MmapMinSize时，访问频繁的大文件改为返回读取共享内存映射的reader，见Config.MmapMinSize。
+// 文件数据不在Badger中，因此不支持EncryptionKey
+func NewFSBlobCache(config *Config) (Cache, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if config.EncryptionKey != "" {
+		return nil, fmt.Errorf("encryption is not supported by the filesystem blob backend")
+	}
+	if config.Backup != nil {
+		return nil, fmt.Errorf("backups are not supported by the filesystem blob backend")
+	}
+	if config.MmapMinSize < 0 || config.MmapMinAccesses < 0 {
+		return nil, fmt.Errorf("mmap thresholds cannot be negative")
+	}
+
+	blobs := &blobStore{dir: filepath.Join(config.DataDir, "blobs")}
+	if config.MmapMinSize > 0 {
+		blobs.maps = newMmapRegistry(config.MmapMinSize, config.MmapMinAccesses)
+	}
+	if err := os.MkdirAll(blobs.tmpDir(), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+
+	return newBadgerCache(config, blobs)
+}
+
+// blobPath 返回文件数据路径
+func (c *badgerCache) blobPath(key string) string {
+	return c.blobs.path(c.prefix, key, false)
+}
+
+// trashBlobPath 返回回收站文件数据路径
+func (c *badgerCache) trashBlobPath(key string) string {
+	return c.blobs.path(c.prefix, key, true)
+}
+
+// lockBlob 锁定键并返回解锁函数。文件系统后端中文件数据和FileInfo不在同一个事务里，
+// 同一个键的写入、删除和回收站移动在移动文件到提交FileInfo之间持有这把锁，避免并发操作交错后
+// FileInfo描述的是另一次写入的文件；Badger存储中两者在同一个事务里，不需要加锁。
+// 持有时不能再锁定其他键，不同的键可能共用一把锁
+func (c *badgerCache) lockBlob(key string) func() {
+	if c.blobs == nil {
+		return func() {}
+	}
+	h := fnv.New32a()
+	h.Write([]byte(c.prefix))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	mu := &c.blobs.locks[h.Sum32()%blobLockStripes]
+	mu.Lock()
+	return mu.Unlock
+}
+
+// removeBlob 删除批量删除的条目的文件（trash为true时为回收站中的文件），在键锁内确认记录仍然不存在，
+// 批量事务提交之后同一个键被重新写入时保留新的文件
+func (c *badgerCache) removeBlob(key string, trash bool) {
+	defer c.lockBlob(key)()
+
+	infoKey, path := c.infoKey(key), c.blobPath(key)
+	if trash {
+		infoKey, path = c.trashInfoKey(key), c.trashBlobPath(key)
+	}
+	err := c.store.view(func(txn *badger.Txn) error {
+		_, err := txn.Get(infoKey)
+		return err
+	})
+	if err == badger.ErrKeyNotFound {
+		c.blobs.remove(path)
+	}
+}
+
+// tmpDir 返回临时文件目录
+func (b *blobStore) tmpDir() string {
+	return filepath.Join(b.dir, "tmp")
+}
+
+// namespaceDir 返回命名空间的文件目录
+func (b *blobStore) namespaceDir(prefix string) string {
+	if prefix == "" {
+		return filepath.Join(b.dir, "root")
+	}
+	sum := sha256.Sum256([]byte(prefix))
+	return filepath.Join(b.dir, "ns-"+hex.EncodeToString(sum[:8]))
+}
+
+// path 根据键的哈希计算文件路径
+func (b *blobStore) path(prefix, key string, trash bool) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+
+	dir := b.namespaceDir(prefix)
+	if trash {
+		dir = filepath.Join(dir, "trash")
+	}
+	return filepath.Join(dir, name[0:2], name[2:4], name)
+}
+
+// open 打开文件数据，info满足内存映射的条件时返回读取共享映射的reader，映射失败时直接打开文件
+func (b *blobStore) open(path string, info *FileInfo) (io.ReadCloser, error) {
+	if b.maps.eligible(info) {
+		reader, err := b.maps.open(path)
+		if err == nil || os.IsNotExist(err) {
+			return reader, err
+		}
+	}
+	return os.Open(path)
+}
+
+// writeTemp 将数据写入临时文件，超过maxSize时返回ErrEntryTooLarge
+func (b *blobStore) writeTemp(r io.Reader, maxSize int64) (string, int64, error) {
+	file, err := os.CreateTemp(b.tmpDir(), "blob-*")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create temp file: %w", err)
+	}
+
+	size, err := io.Copy(file, io.LimitReader(r, maxSize+1))
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", 0, fmt.Errorf("failed to write data: %w", err)
+	}
+
+	if size > maxSize {
+		os.Remove(file.Name())
+		return "", 0, fmt.Errorf("%w: file size exceeds max entry size %d", ErrEntryTooLarge, maxSize)
+	}
+	return file.Name(), size, nil
+}
+
+// commit 将临时文件原子地移动到最终路径
+func (b *blobStore) commit(tmp, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// move 移动文件，源文件不存在时忽略
+func (b *blobStore) move(from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(from, to); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// remove 删除文件，失败时留给孤立文件清理处理
+func (b *blobStore) remove(path string) {
+	os.Remove(path)
+}
+
+// removeNamespace 删除命名空间的所有文件，先将目录改名再删除，删除期间新写入的文件落在重新创建的目录中。
+// 改名与Flush删除FileInfo不是原子的：与Flush并发的写入可能在改名前落盘、在删除记录后提交FileInfo，
+// 留下没有文件数据的FileInfo，由Cleanup的孤立记录清理删除
+func (b *blobStore) removeNamespace(prefix string) error {
+	dir := b.namespaceDir(prefix)
+	doomed, err := os.MkdirTemp(b.tmpDir(), "flush-*")
+	if err != nil {
+		return err
+	}
+	target := filepath.Join(doomed, "ns")
+	if err := os.Rename(dir, target); err != nil && !os.IsNotExist(err) {
+		os.Remove(doomed)
+		return err
+	}
+	return os.RemoveAll(doomed)
+}
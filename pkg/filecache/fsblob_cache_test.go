@@ -0,0 +1,254 @@
+package filecache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFSBlobCache(t *testing.T) {
+	config := &Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1024 * 1024,
+		MaxEntrySize:    1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+		SoftDelete:      true,
+	}
+	cache, err := NewFSBlobCache(config)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	blobCache := cache.(*badgerCache)
+
+	t.Run("SetAndGet", func(t *testing.T) {
+		if err := cache.Set(ctx, "video.mp4", strings.NewReader("frames"), "video/mp4", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+
+		reader, info, err := cache.Get(ctx, "video.mp4")
+		if err != nil {
+			t.Fatalf("Failed to get file: %v", err)
+		}
+		reader.Close()
+		if _, ok := reader.(*os.File); !ok {
+			t.Errorf("Expected *os.File, got %T", reader)
+		}
+		if info.Size != 6 {
+			t.Errorf("Expected size 6, got %d", info.Size)
+		}
+		if got := readString(t, cache, "video.mp4"); got != "frames" {
+			t.Errorf("Expected 'frames', got '%s'", got)
+		}
+		if _, err := os.Stat(blobCache.blobPath("video.mp4")); err != nil {
+			t.Errorf("Expected blob on disk: %v", err)
+		}
+	})
+
+	t.Run("MaxEntrySize", func(t *testing.T) {
+		err := cache.Set(ctx, "huge.mp4", strings.NewReader(strings.Repeat("x", 1025)), "video/mp4", time.Hour)
+		if !errors.Is(err, ErrEntryTooLarge) {
+			t.Errorf("Expected ErrEntryTooLarge, got %v", err)
+		}
+		entries, _ := os.ReadDir(blobCache.blobs.tmpDir())
+		if len(entries) != 0 {
+			t.Errorf("Expected temp files to be removed, found %d", len(entries))
+		}
+	})
+
+	t.Run("SoftDeleteMovesBlob", func(t *testing.T) {
+		if err := cache.Delete(ctx, "video.mp4"); err != nil {
+			t.Fatalf("Failed to delete file: %v", err)
+		}
+		if _, err := os.Stat(blobCache.blobPath("video.mp4")); !os.IsNotExist(err) {
+			t.Errorf("Expected blob to leave its live path, got %v", err)
+		}
+		if err := cache.(SoftDeleter).Restore(ctx, "video.mp4"); err != nil {
+			t.Fatalf("Failed to restore file: %v", err)
+		}
+		if got := readString(t, cache, "video.mp4"); got != "frames" {
+			t.Errorf("Expected 'frames' after restore, got '%s'", got)
+		}
+	})
+
+	t.Run("NamespaceFlush", func(t *testing.T) {
+		site := cache.Namespace("site")
+		if err := site.Set(ctx, "video.mp4", strings.NewReader("site frames"), "video/mp4", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		if err := site.Flush(ctx); err != nil {
+			t.Fatalf("Failed to flush namespace: %v", err)
+		}
+		if _, err := os.Stat(site.(*badgerCache).blobPath("video.mp4")); !os.IsNotExist(err) {
+			t.Errorf("Expected namespace blob to be removed, got %v", err)
+		}
+		if got := readString(t, cache, "video.mp4"); got != "frames" {
+			t.Errorf("Expected root entry to survive, got '%s'", got)
+		}
+	})
+
+	t.Run("EncryptionUnsupported", func(t *testing.T) {
+		encrypted := *config
+		encrypted.DataDir = t.TempDir()
+		encrypted.EncryptionKey = strings.Repeat("ab", 16)
+		if c, err := NewFSBlobCache(&encrypted); err == nil {
+			c.Close()
+			t.Error("Expected encryption to be rejected")
+		}
+	})
+}
+
+func TestFSBlobCacheConcurrentWrites(t *testing.T) {
+	cache, err := NewFSBlobCache(&Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	// 同一个键的并发写入和删除交错时，FileInfo必须描述最后落盘的文件
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 1; i <= 8; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if n%4 == 0 {
+					cache.Delete(ctx, "shared")
+					continue
+				}
+				if err := cache.Set(ctx, "shared", strings.NewReader(strings.Repeat("x", n*10)), "text/plain", time.Hour); err != nil {
+					t.Errorf("Failed to set file: %v", err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if reader, info, err := cache.Get(ctx, "shared"); err == nil {
+		data, _ := io.ReadAll(reader)
+		reader.Close()
+		if int64(len(data)) != info.Size {
+			t.Errorf("Expected FileInfo to match the data, got %d bytes for size %d", len(data), info.Size)
+		}
+	} else if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Failed to get file: %v", err)
+	}
+	report, err := cache.(Verifier).Verify(ctx, VerifyOptions{})
+	if err != nil || len(report.Problems) != 0 {
+		t.Errorf("Expected no problems after concurrent writes, got %+v %v", report, err)
+	}
+}
+
+func TestFSBlobCacheMmap(t *testing.T) {
+	cache, err := NewFSBlobCache(&Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+		MmapMinSize:     8,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+	ctx := context.Background()
+	blobCache := cache.(*badgerCache)
+	cache.Set(ctx, "hot.mp4", strings.NewReader("hot video frames"), "video/mp4", time.Hour)
+	cache.Set(ctx, "small.txt", strings.NewReader("tiny"), "text/plain", time.Hour)
+
+	get := func(key string) io.ReadCloser {
+		t.Helper()
+		reader, _, err := cache.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Failed to get %s: %v", key, err)
+		}
+		return reader
+	}
+
+	t.Run("Threshold", func(t *testing.T) {
+		first := get("hot.mp4")
+		first.Close()
+		if _, ok := first.(*os.File); !ok {
+			t.Errorf("Expected first access to read the file, got %T", first)
+		}
+		for i := 0; i < 2; i++ {
+			small := get("small.txt")
+			small.Close()
+			if _, ok := small.(*os.File); !ok {
+				t.Errorf("Expected small file to be read directly, got %T", small)
+			}
+		}
+	})
+
+	t.Run("Shared", func(t *testing.T) {
+		a, b := get("hot.mp4"), get("hot.mp4")
+		ra, ok := a.(*mmapReader)
+		rb, _ := b.(*mmapReader)
+		if !ok || rb == nil || ra.mapping != rb.mapping {
+			t.Fatalf("Expected concurrent readers to share one mapping, got %T and %T", a, b)
+		}
+		buf := make([]byte, 3)
+		if _, err := b.(io.ReaderAt).ReadAt(buf, 4); err != nil || string(buf) != "vid" {
+			t.Errorf("Expected ReadAt from the mapping, got %q %v", buf, err)
+		}
+		data, _ := io.ReadAll(a)
+		if string(data) != "hot video frames" {
+			t.Errorf("Expected mapped content, got %q", data)
+		}
+		a.Close()
+		if n, _ := a.Read(buf); n != 0 {
+			t.Error("Expected no data after close")
+		}
+		if len(blobCache.blobs.maps.maps) != 1 {
+			t.Error("Expected mapping to stay while a reader holds it")
+		}
+		b.Close()
+		b.Close()
+		if len(blobCache.blobs.maps.maps) != 0 {
+			t.Error("Expected mapping to be released after the last reader closed")
+		}
+	})
+
+	t.Run("Replaced", func(t *testing.T) {
+		old := get("hot.mp4")
+		defer old.Close()
+		if err := cache.Set(ctx, "hot.mp4", strings.NewReader("replaced frames!"), "video/mp4", time.Hour); err != nil {
+			t.Fatalf("Failed to replace file: %v", err)
+		}
+		get("hot.mp4").Close()
+		if got := readString(t, cache, "hot.mp4"); got != "replaced frames!" {
+			t.Errorf("Expected new content after replace, got %q", got)
+		}
+		if data, _ := io.ReadAll(old); string(data) != "hot video frames" {
+			t.Errorf("Expected open reader to keep the old content, got %q", data)
+		}
+	})
+
+	t.Run("Deleted", func(t *testing.T) {
+		reader := get("hot.mp4")
+		defer reader.Close()
+		if err := cache.Delete(ctx, "hot.mp4"); err != nil {
+			t.Fatalf("Failed to delete file: %v", err)
+		}
+		if _, _, err := cache.Get(ctx, "hot.mp4"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound after delete, got %v", err)
+		}
+		if data, _ := io.ReadAll(reader); len(data) != 16 {
+			t.Errorf("Expected open reader to finish after delete, got %q", data)
+		}
+	})
+}
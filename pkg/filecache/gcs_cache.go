@@ -0,0 +1,368 @@
+package filecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/internal/gcsclient"
+)
+
+const (
+	// GCS对象自定义元数据键
+	gcsMetaCreatedAt = "created-at"
+	gcsMetaExpiresAt = "expires-at"
+
+	// gcsGetAttempts Get时元数据与内容版本不一致（并发覆盖）的重试次数
+	gcsGetAttempts = 3
+)
+
+// GCSConfig GCS缓存配置
+type GCSConfig struct {
+	Endpoint     string        `json:"endpoint"`       // 服务地址，默认 https://storage.googleapis.com，可指向模拟器
+	Bucket       string        `json:"bucket"`         // 存储桶
+	Token        string        `json:"token"`          // 固定的OAuth2访问令牌，为空时使用TokenSource
+	Prefix       string        `json:"prefix"`         // 对象名前缀，多个缓存共用存储桶时使用
+	DefaultTTL   time.Duration `json:"default_ttl"`    // 默认TTL
+	MaxEntrySize int64         `json:"max_entry_size"` // 单个文件最大大小（字节），0表示不限制
+
+	// TokenSource 访问令牌来源，Token和TokenSource都为空时从GCE/GKE元数据服务获取
+	TokenSource func(ctx context.Context) (string, error) `json:"-"`
+
+	HTTPClient *http.Client `json:"-"` // 自定义HTTP客户端
+}
+
+// gcsCache Google Cloud Storage文件缓存实现
+// 文件数据存放在 <Prefix>data/<key>，FileInfo存放在对象自定义元数据中，
+// 对象generation作为FileInfo.Version，用于SetNX和CompareAndSwap
+type gcsCache struct {
+	client *gcsclient.Client
+	cfg    GCSConfig
+	prefix string // 当前命名空间的对象名前缀
+
+	stats objectStats
+
+	namespaces map[string]*gcsCache
+	nsMu       sync.Mutex
+}
+
+// NewGCSCache 创建基于Google Cloud Storage的文件缓存
+func NewGCSCache(cfg GCSConfig) (Cache, error) {
+	tokenSource := gcsclient.TokenSource(cfg.TokenSource)
+	if cfg.Token != "" {
+		tokenSource = gcsclient.StaticToken(cfg.Token)
+	}
+
+	client, err := gcsclient.New(gcsclient.Config{
+		Endpoint:    cfg.Endpoint,
+		Bucket:      cfg.Bucket,
+		TokenSource: tokenSource,
+		HTTPClient:  cfg.HTTPClient,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cfg.DefaultTTL <= 0 {
+		cfg.DefaultTTL = DefaultConfig().DefaultTTL
+	}
+
+	return &gcsCache{
+		client:     client,
+		cfg:        cfg,
+		prefix:     cfg.Prefix,
+		namespaces: make(map[string]*gcsCache),
+	}, nil
+}
+
+// objectName 返回缓存键对应的对象名
+func (c *gcsCache) objectName(key string) string {
+	return c.dataPrefix() + key
+}
+
+// dataPrefix 返回当前命名空间文件数据的对象名前缀
+func (c *gcsCache) dataPrefix() string {
+	return c.prefix + "data/"
+}
+
+// Set 存储文件到缓存
+func (c *gcsCache) Set(ctx context.Context, key string, data io.Reader, mimeType string, ttl time.Duration) error {
+	_, err := c.insert(ctx, key, data, mimeType, ttl, gcsclient.Conditions{})
+	return err
+}
+
+// SetNX 仅在键不存在或已过期时写入
+func (c *gcsCache) SetNX(ctx context.Context, key string, data io.Reader, mimeType string, ttl time.Duration) (bool, error) {
+	var generation int64
+	obj, err := c.client.Get(ctx, c.objectName(key))
+	switch {
+	case errors.Is(err, gcsclient.ErrNotFound):
+	case err != nil:
+		return false, err
+	case time.Now().After(c.fileInfo(obj).ExpiresAt):
+		// 过期对象视为不存在，以其generation为条件覆盖
+		generation = obj.Generation
+	default:
+		return false, nil
+	}
+
+	return c.insert(ctx, key, data, mimeType, ttl, gcsclient.Conditions{GenerationMatch: &generation})
+}
+
+// CompareAndSwap 仅在对象generation等于version时写入，version为0表示对象不存在
+func (c *gcsCache) CompareAndSwap(ctx context.Context, key string, version int64, data io.Reader, mimeType string, ttl time.Duration) (bool, error) {
+	return c.insert(ctx, key, data, mimeType, ttl, gcsclient.Conditions{GenerationMatch: &version})
+}
+
+// insert 上传对象，条件不满足时返回false
+func (c *gcsCache) insert(ctx context.Context, key string, data io.Reader, mimeType string, ttl time.Duration, cond gcsclient.Conditions) (bool, error) {
+	if ttl <= 0 && ttl != NoExpiry {
+		ttl = c.cfg.DefaultTTL
+	}
+
+	body, size, err := spool(data, c.cfg.MaxEntrySize)
+	if err != nil {
+		return false, err
+	}
+	defer body.Close()
+
+	now := time.Now()
+	obj := &gcsclient.Object{
+		Name:        c.objectName(key),
+		ContentType: mimeType,
+		Metadata: map[string]string{
+			gcsMetaCreatedAt: now.UTC().Format(time.RFC3339Nano),
+			gcsMetaExpiresAt: expiryFor(now, ttl).UTC().Format(time.RFC3339Nano),
+		},
+	}
+
+	// 覆盖写入时统计信息只加上大小之差；获取失败时按新文件计算，下次List时按实际对象刷新
+	old, _ := c.GetInfo(ctx, key)
+	_, err = c.client.Insert(ctx, obj, body, size, cond)
+	if errors.Is(err, gcsclient.ErrPreconditionFailed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to store file: %w", err)
+	}
+
+	c.stats.added(size, old)
+	return true, nil
+}
+
+// Get 从缓存获取文件
+func (c *gcsCache) Get(ctx context.Context, key string) (io.ReadCloser, *FileInfo, error) {
+	name := c.objectName(key)
+	for attempt := 0; attempt < gcsGetAttempts; attempt++ {
+		obj, err := c.client.Get(ctx, name)
+		if errors.Is(err, gcsclient.ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		info := c.fileInfo(obj)
+		if time.Now().After(info.ExpiresAt) {
+			return nil, nil, ErrExpired
+		}
+
+		// 按generation下载，保证内容与元数据一致
+		resp, err := c.client.Download(ctx, name, obj.Generation, nil)
+		if errors.Is(err, gcsclient.ErrNotFound) {
+			// 读取期间被覆盖或删除
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		c.stats.hit()
+		return resp.Body, info, nil
+	}
+
+	c.stats.miss()
+	return nil, nil, ErrNotFound
+}
+
+// Exists 检查文件是否存在
+func (c *gcsCache) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := c.client.Get(ctx, c.objectName(key))
+	if errors.Is(err, gcsclient.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete 删除文件，先获取对象大小用于更新统计信息
+func (c *gcsCache) Delete(ctx context.Context, key string) error {
+	old, _ := c.GetInfo(ctx, key)
+	err := c.client.Delete(ctx, c.objectName(key), gcsclient.Conditions{})
+	if errors.Is(err, gcsclient.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	if old != nil {
+		c.stats.removed(old)
+	}
+	return nil
+}
+
+// List 列出当前命名空间的所有缓存文件
+func (c *gcsCache) List(ctx context.Context) ([]*FileInfo, error) {
+	objects, err := c.listObjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*FileInfo, len(objects))
+	for i, obj := range objects {
+		files[i] = c.fileInfo(obj)
+	}
+
+	c.stats.refresh(files)
+	return files, nil
+}
+
+// GetInfo 获取文件信息
+func (c *gcsCache) GetInfo(ctx context.Context, key string) (*FileInfo, error) {
+	obj, err := c.client.Get(ctx, c.objectName(key))
+	if errors.Is(err, gcsclient.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.fileInfo(obj), nil
+}
+
+// Cleanup 清理当前命名空间的过期文件
+// 以generation为条件删除，避免误删清理期间被重新写入的对象
+func (c *gcsCache) Cleanup(ctx context.Context) error {
+	now := time.Now()
+	objects, err := c.listObjects(ctx)
+	if err != nil {
+		return err
+	}
+
+	var removed int64
+	var kept []*FileInfo
+	for _, obj := range objects {
+		info := c.fileInfo(obj)
+		if !now.After(info.ExpiresAt) {
+			kept = append(kept, info)
+			continue
+		}
+		generation := obj.Generation
+		err := c.client.Delete(ctx, obj.Name, gcsclient.Conditions{GenerationMatch: &generation})
+		if errors.Is(err, gcsclient.ErrNotFound) || errors.Is(err, gcsclient.ErrPreconditionFailed) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete expired file: %w", err)
+		}
+		removed++
+	}
+
+	c.stats.refresh(kept)
+	c.stats.cleaned(removed, now)
+	return nil
+}
+
+// Flush 删除当前命名空间的所有文件并重置统计信息
+func (c *gcsCache) Flush(ctx context.Context) error {
+	objects, err := c.listObjects(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		err := c.client.Delete(ctx, obj.Name, gcsclient.Conditions{})
+		if err != nil && !errors.Is(err, gcsclient.ErrNotFound) {
+			return fmt.Errorf("failed to flush cache: %w", err)
+		}
+	}
+
+	c.stats.reset()
+	return nil
+}
+
+// Namespace 返回对象名前缀为 <prefix>ns/<name>/ 的子缓存
+func (c *gcsCache) Namespace(name string) Cache {
+	if name == "" {
+		return c
+	}
+
+	c.nsMu.Lock()
+	defer c.nsMu.Unlock()
+
+	if ns, ok := c.namespaces[name]; ok {
+		return ns
+	}
+	ns := &gcsCache{
+		client:     c.client,
+		cfg:        c.cfg,
+		prefix:     c.prefix + "ns/" + url.PathEscape(name) + "/",
+		namespaces: make(map[string]*gcsCache),
+	}
+	c.namespaces[name] = ns
+	return ns
+}
+
+// Close 关闭缓存
+func (c *gcsCache) Close() error {
+	return nil
+}
+
+// Stats 获取缓存统计信息
+func (c *gcsCache) Stats() (*Stats, error) {
+	return c.stats.snapshot(), nil
+}
+
+// listObjects 分页列出当前命名空间的所有数据对象
+func (c *gcsCache) listObjects(ctx context.Context) ([]*gcsclient.Object, error) {
+	var objects []*gcsclient.Object
+	token := ""
+	for {
+		result, err := c.client.List(ctx, c.dataPrefix(), token)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+		objects = append(objects, result.Items...)
+		if result.NextPageToken == "" {
+			return objects, nil
+		}
+		token = result.NextPageToken
+	}
+}
+
+// fileInfo 从对象元数据解析文件信息
+func (c *gcsCache) fileInfo(obj *gcsclient.Object) *FileInfo {
+	info := &FileInfo{
+		Key:       obj.Name[len(c.dataPrefix()):],
+		Size:      obj.Size,
+		MimeType:  obj.ContentType,
+		CreatedAt: obj.TimeCreated,
+		Version:   obj.Generation,
+	}
+	if t, err := time.Parse(time.RFC3339Nano, obj.Metadata[gcsMetaCreatedAt]); err == nil {
+		info.CreatedAt = t
+	}
+	if t, err := time.Parse(time.RFC3339Nano, obj.Metadata[gcsMetaExpiresAt]); err == nil {
+		info.ExpiresAt = t
+	} else {
+		// 没有过期时间的对象视为永不过期，由存储桶生命周期规则管理
+		info.ExpiresAt = neverExpires
+	}
+	info.LastAccess = info.CreatedAt
+	return info
+}
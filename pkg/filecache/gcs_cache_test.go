@@ -0,0 +1,207 @@
+package filecache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/internal/gcstest"
+)
+
+func TestGCSCache(t *testing.T) {
+	server := gcstest.NewServer("edge-cache")
+	defer server.Close()
+
+	cache, err := NewGCSCache(GCSConfig{
+		Endpoint:     server.URL,
+		Bucket:       "edge-cache",
+		Token:        "test",
+		Prefix:       "edge/",
+		DefaultTTL:   time.Hour,
+		MaxEntrySize: 1024,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	conditional := cache.(ConditionalSetter)
+
+	t.Run("SetAndGet", func(t *testing.T) {
+		if err := cache.Set(ctx, "img/logo 1.png", strings.NewReader("png data"), "image/png", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+
+		if got := readString(t, cache, "img/logo 1.png"); got != "png data" {
+			t.Errorf("Expected 'png data', got %q", got)
+		}
+
+		info, err := cache.GetInfo(ctx, "img/logo 1.png")
+		if err != nil {
+			t.Fatalf("Failed to get info: %v", err)
+		}
+		if info.MimeType != "image/png" || info.Size != 8 || info.Version == 0 {
+			t.Errorf("Unexpected info: %+v", info)
+		}
+
+		names := server.Names("edge-cache")
+		if len(names) != 1 || names[0] != "edge/data/img/logo 1.png" {
+			t.Errorf("Unexpected object names: %v", names)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		if _, _, err := cache.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+		if exists, err := cache.Exists(ctx, "missing"); err != nil || exists {
+			t.Errorf("Expected missing file, got %v, %v", exists, err)
+		}
+		if err := cache.Delete(ctx, "missing"); err != nil {
+			t.Errorf("Failed to delete missing file: %v", err)
+		}
+	})
+
+	t.Run("MaxEntrySize", func(t *testing.T) {
+		err := cache.Set(ctx, "huge", strings.NewReader(strings.Repeat("x", 1025)), "text/plain", time.Hour)
+		if !errors.Is(err, ErrEntryTooLarge) {
+			t.Errorf("Expected ErrEntryTooLarge, got %v", err)
+		}
+	})
+
+	t.Run("SetNX", func(t *testing.T) {
+		ok, err := conditional.SetNX(ctx, "lock", strings.NewReader("first"), "text/plain", time.Hour)
+		if err != nil || !ok {
+			t.Fatalf("Expected first SetNX to succeed, got %v, %v", ok, err)
+		}
+		ok, err = conditional.SetNX(ctx, "lock", strings.NewReader("second"), "text/plain", time.Hour)
+		if err != nil || ok {
+			t.Fatalf("Expected second SetNX to fail, got %v, %v", ok, err)
+		}
+		if got := readString(t, cache, "lock"); got != "first" {
+			t.Errorf("Expected 'first', got %q", got)
+		}
+
+		// 过期条目视为不存在
+		if err := cache.Set(ctx, "stale", strings.NewReader("old"), "text/plain", 50*time.Millisecond); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		ok, err = conditional.SetNX(ctx, "stale", strings.NewReader("new"), "text/plain", time.Hour)
+		if err != nil || !ok {
+			t.Fatalf("Expected SetNX over expired entry to succeed, got %v, %v", ok, err)
+		}
+	})
+
+	t.Run("CompareAndSwap", func(t *testing.T) {
+		info, err := cache.GetInfo(ctx, "lock")
+		if err != nil {
+			t.Fatalf("Failed to get info: %v", err)
+		}
+
+		ok, err := conditional.CompareAndSwap(ctx, "lock", info.Version, strings.NewReader("swapped"), "text/plain", time.Hour)
+		if err != nil || !ok {
+			t.Fatalf("Expected CompareAndSwap to succeed, got %v, %v", ok, err)
+		}
+		// 旧版本不能再次写入
+		ok, err = conditional.CompareAndSwap(ctx, "lock", info.Version, strings.NewReader("lost"), "text/plain", time.Hour)
+		if err != nil || ok {
+			t.Fatalf("Expected stale CompareAndSwap to fail, got %v, %v", ok, err)
+		}
+		if got := readString(t, cache, "lock"); got != "swapped" {
+			t.Errorf("Expected 'swapped', got %q", got)
+		}
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		if err := cache.Set(ctx, "short", strings.NewReader("data"), "text/plain", 50*time.Millisecond); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+
+		if _, _, err := cache.Get(ctx, "short"); !errors.Is(err, ErrExpired) {
+			t.Errorf("Expected ErrExpired, got %v", err)
+		}
+		if err := cache.Cleanup(ctx); err != nil {
+			t.Fatalf("Failed to cleanup: %v", err)
+		}
+		if exists, _ := cache.Exists(ctx, "short"); exists {
+			t.Error("Expected expired file to be removed")
+		}
+
+		stats, err := cache.Stats()
+		if err != nil {
+			t.Fatalf("Failed to get stats: %v", err)
+		}
+		if stats.ExpiredFiles != 1 || stats.TotalFiles != 3 {
+			t.Errorf("Unexpected stats: %+v", stats)
+		}
+	})
+
+	t.Run("Namespace", func(t *testing.T) {
+		ns := cache.Namespace("site-a")
+		if err := ns.Set(ctx, "img/logo 1.png", strings.NewReader("site a"), "image/png", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		if got := readString(t, ns, "img/logo 1.png"); got != "site a" {
+			t.Errorf("Expected 'site a', got %q", got)
+		}
+
+		files, err := ns.List(ctx)
+		if err != nil {
+			t.Fatalf("Failed to list files: %v", err)
+		}
+		if len(files) != 1 || files[0].Key != "img/logo 1.png" {
+			t.Errorf("Unexpected namespace files: %v", files)
+		}
+
+		if err := ns.Flush(ctx); err != nil {
+			t.Fatalf("Failed to flush namespace: %v", err)
+		}
+		if exists, _ := ns.Exists(ctx, "img/logo 1.png"); exists {
+			t.Error("Expected namespace to be empty")
+		}
+		if got := readString(t, cache, "img/logo 1.png"); got != "png data" {
+			t.Errorf("Expected 'png data', got %q", got)
+		}
+	})
+}
+
+func TestGCSCacheStats(t *testing.T) {
+	server := gcstest.NewServer("edge-cache")
+	defer server.Close()
+
+	cache, err := NewGCSCache(GCSConfig{
+		Endpoint:   server.URL,
+		Bucket:     "edge-cache",
+		Token:      "test",
+		DefaultTTL: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	for _, e := range []struct{ key, data string }{{"a", "png data"}, {"a", "png"}, {"b", "hello"}} {
+		if err := cache.Set(ctx, e.key, strings.NewReader(e.data), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set %s: %v", e.key, err)
+		}
+	}
+	if stats, _ := cache.Stats(); stats.TotalFiles != 2 || stats.TotalSize != 8 {
+		t.Errorf("Expected overwrites to be counted by the size difference, got %d files %d bytes", stats.TotalFiles, stats.TotalSize)
+	}
+
+	// 删除不存在的对象不改变统计信息
+	for _, key := range []string{"a", "a"} {
+		if err := cache.Delete(ctx, key); err != nil {
+			t.Fatalf("Failed to delete %s: %v", key, err)
+		}
+	}
+	if stats, _ := cache.Stats(); stats.TotalFiles != 1 || stats.TotalSize != 5 {
+		t.Errorf("Expected deletes to be subtracted, got %d files %d bytes", stats.TotalFiles, stats.TotalSize)
+	}
+}
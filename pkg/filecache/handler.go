@@ -0,0 +1,205 @@
+package filecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HandlerOptions Handler选项
+type HandlerOptions struct {
+	// StripPrefix 映射为缓存键前从URL路径去掉的前缀
+	StripPrefix string
+
+	// IndexFile 以"/"结尾的路径追加的文件名，例如 index.html
+	IndexFile string
+
+	// KeyFunc 自定义URL到缓存键的映射，设置后忽略StripPrefix和IndexFile
+	KeyFunc func(r *http.Request) string
+
+	// CacheControl 响应的Cache-Control，为空时按文件剩余的TTL生成 public, max-age=N
+	CacheControl string
+
+	// NotFound 未命中时调用，可以用于回源，默认返回404
+	NotFound http.Handler
+}
+
+// Handler 返回直接从缓存提供文件的http.Handler，支持GET、HEAD、Range和条件请求，
+// 响应带有Content-Type、Content-Length、ETag、Last-Modified和表示命中与否的X-Cache头；
+// 命中和未命中计入缓存的统计信息
+func Handler(cache Cache, opts HandlerOptions) http.Handler {
+	return &cacheHandler{cache: cache, opts: opts}
+}
+
+// cacheHandler 从缓存提供文件
+type cacheHandler struct {
+	cache Cache
+	opts  HandlerOptions
+}
+
+// ServeHTTP 返回URL对应的缓存文件
+func (h *cacheHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key := h.key(r)
+	if key == "" {
+		h.miss(w, r)
+		return
+	}
+	reader, info, err := h.cache.Get(r.Context(), key)
+	if errors.Is(err, ErrNotFound) {
+		// 包括已过期的文件（ErrExpired），按未命中处理
+		h.miss(w, r)
+		return
+	}
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, ErrCacheClosed) {
+			code = http.StatusServiceUnavailable
+		}
+		http.Error(w, http.StatusText(code), code)
+		return
+	}
+
+	// 支持Seek的reader（文件系统后端的*os.File）直接交给ServeContent，明文HTTP/1.1连接上由sendfile发送，
+	// 不经过用户态缓冲；其他reader由lazySeeker提供Seek
+	var content io.ReadSeeker
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		defer reader.Close()
+		content = seeker
+	} else {
+		lazy := &lazySeeker{ctx: r.Context(), cache: h.cache, key: key, size: info.Size, reader: reader}
+		defer lazy.Close()
+		content = lazy
+	}
+
+	header := w.Header()
+	header.Set("X-Cache", "HIT")
+	header.Set("ETag", fileETag(info))
+	if info.MimeType != "" {
+		header.Set("Content-Type", info.MimeType)
+	}
+	if h.opts.CacheControl != "" {
+		header.Set("Cache-Control", h.opts.CacheControl)
+	} else if info.NeverExpires() {
+		header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else if ttl := time.Until(info.ExpiresAt); ttl > 0 {
+		header.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int64(ttl/time.Second)))
+	}
+	http.ServeContent(w, r, key, info.CreatedAt, content)
+}
+
+// key 返回请求对应的缓存键
+func (h *cacheHandler) key(r *http.Request) string {
+	if h.opts.KeyFunc != nil {
+		return h.opts.KeyFunc(r)
+	}
+	path, ok := strings.CutPrefix(r.URL.Path, h.opts.StripPrefix)
+	if !ok {
+		return ""
+	}
+	if h.opts.IndexFile != "" && (path == "" || strings.HasSuffix(path, "/")) {
+		path += h.opts.IndexFile
+	}
+	return strings.TrimPrefix(path, "/")
+}
+
+// miss 处理未命中的请求
+func (h *cacheHandler) miss(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Cache", "MISS")
+	if h.opts.NotFound != nil {
+		h.opts.NotFound.ServeHTTP(w, r)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// fileETag 根据创建时间和大小生成ETag，文件重新写入后改变
+func fileETag(info *FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.CreatedAt.UnixNano(), info.Size)
+}
+
+// lazySeeker 为http.ServeContent提供Seek，后端的reader不支持Seek时在位置改变后重新获取文件
+type lazySeeker struct {
+	ctx   context.Context
+	cache Cache
+	key   string
+	size  int64
+
+	pos       int64
+	reader    io.ReadCloser
+	readerPos int64
+}
+
+func (s *lazySeeker) Read(p []byte) (int, error) {
+	if s.pos >= s.size {
+		return 0, io.EOF
+	}
+	if s.reader == nil || s.readerPos != s.pos {
+		if err := s.reopen(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := s.reader.Read(p)
+	s.pos += int64(n)
+	s.readerPos += int64(n)
+	return n, err
+}
+
+// reopen 定位到当前位置，优先使用reader自身的Seek
+func (s *lazySeeker) reopen() error {
+	if seeker, ok := s.reader.(io.Seeker); ok {
+		if _, err := seeker.Seek(s.pos, io.SeekStart); err == nil {
+			s.readerPos = s.pos
+			return nil
+		}
+	}
+
+	if s.reader != nil {
+		s.reader.Close()
+		s.reader = nil
+	}
+	reader, _, err := s.cache.Get(s.ctx, s.key)
+	if err != nil {
+		return err
+	}
+	if _, err := io.CopyN(io.Discard, reader, s.pos); err != nil {
+		reader.Close()
+		return err
+	}
+	s.reader, s.readerPos = reader, s.pos
+	return nil
+}
+
+// Seek 只记录位置，下次读取时生效
+func (s *lazySeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.pos
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative position %d", offset)
+	}
+	s.pos = offset
+	return offset, nil
+}
+
+func (s *lazySeeker) Close() error {
+	if s.reader == nil {
+		return nil
+	}
+	return s.reader.Close()
+}
@@ -0,0 +1,185 @@
+package filecache
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// nonSeekingCache 返回不支持Seek的reader，模拟远程后端
+type nonSeekingCache struct {
+	Cache
+}
+
+func (c nonSeekingCache) Get(ctx context.Context, key string) (io.ReadCloser, *FileInfo, error) {
+	reader, info, err := c.Cache.Get(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return struct{ io.ReadCloser }{reader}, info, nil
+}
+
+// readFromRecorder 记录ReadFrom收到的是否为文件，与net包判断能否用sendfile的方式相同：
+// *os.File和io.Copy包装它的类型都实现syscall.Conn
+type readFromRecorder struct {
+	*httptest.ResponseRecorder
+	file bool
+}
+
+func (w *readFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	inner := src
+	if limited, ok := src.(*io.LimitedReader); ok {
+		inner = limited.R
+	}
+	_, w.file = inner.(syscall.Conn)
+	return io.Copy(w.ResponseRecorder, src)
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemoryCache(1 << 20)
+	defer cache.Close()
+	cache.Set(ctx, "index.html", strings.NewReader("<h1>home</h1>"), "text/html", time.Hour)
+	cache.Set(ctx, "docs/index.html", strings.NewReader("<h1>docs</h1>"), "text/html", time.Hour)
+	cache.Set(ctx, "video.bin", strings.NewReader("0123456789"), "application/octet-stream", time.Hour)
+
+	fallback := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	srv := httptest.NewServer(Handler(nonSeekingCache{cache}, HandlerOptions{
+		StripPrefix: "/static",
+		IndexFile:   "index.html",
+	}))
+	defer srv.Close()
+
+	get := func(t *testing.T, method, path string, header map[string]string) (*http.Response, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, nil)
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp, string(data)
+	}
+
+	t.Run("Hit", func(t *testing.T) {
+		resp, body := get(t, http.MethodGet, "/static/docs/", nil)
+		if resp.StatusCode != http.StatusOK || body != "<h1>docs</h1>" {
+			t.Fatalf("Expected 200 with docs index, got %d with %q", resp.StatusCode, body)
+		}
+		for name, want := range map[string]string{"Content-Type": "text/html", "Content-Length": "13", "X-Cache": "HIT"} {
+			if got := resp.Header.Get(name); got != want {
+				t.Errorf("Expected %s %q, got %q", name, want, got)
+			}
+		}
+		if resp.Header.Get("ETag") == "" || resp.Header.Get("Last-Modified") == "" {
+			t.Error("Expected ETag and Last-Modified")
+		}
+		if cc := resp.Header.Get("Cache-Control"); cc != "public, max-age=3599" && cc != "public, max-age=3600" {
+			t.Errorf("Expected max-age from remaining TTL, got %q", cc)
+		}
+
+		resp, body = get(t, http.MethodHead, "/static/", nil)
+		if resp.StatusCode != http.StatusOK || body != "" || resp.Header.Get("Content-Length") != "13" {
+			t.Errorf("Expected HEAD without body, got %d with %q", resp.StatusCode, body)
+		}
+	})
+
+	t.Run("Range", func(t *testing.T) {
+		resp, body := get(t, http.MethodGet, "/static/video.bin", map[string]string{"Range": "bytes=3-5"})
+		if resp.StatusCode != http.StatusPartialContent || body != "345" {
+			t.Errorf("Expected 206 with '345', got %d with %q", resp.StatusCode, body)
+		}
+		resp, body = get(t, http.MethodGet, "/static/video.bin", map[string]string{"Range": "bytes=-2"})
+		if resp.StatusCode != http.StatusPartialContent || body != "89" {
+			t.Errorf("Expected 206 with '89', got %d with %q", resp.StatusCode, body)
+		}
+	})
+
+	t.Run("Conditional", func(t *testing.T) {
+		resp, _ := get(t, http.MethodGet, "/static/video.bin", nil)
+		resp, _ = get(t, http.MethodGet, "/static/video.bin", map[string]string{"If-None-Match": resp.Header.Get("ETag")})
+		if resp.StatusCode != http.StatusNotModified {
+			t.Errorf("Expected 304, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Miss", func(t *testing.T) {
+		resp, _ := get(t, http.MethodGet, "/static/missing.css", nil)
+		if resp.StatusCode != http.StatusNotFound || resp.Header.Get("X-Cache") != "MISS" {
+			t.Errorf("Expected 404 MISS, got %d %s", resp.StatusCode, resp.Header.Get("X-Cache"))
+		}
+		if resp, _ := get(t, http.MethodPost, "/static/", nil); resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("Expected 405, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		cache.Set(ctx, "short.css", strings.NewReader("body{}"), "text/css", 10*time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+		resp, _ := get(t, http.MethodGet, "/static/short.css", nil)
+		if resp.StatusCode != http.StatusNotFound || resp.Header.Get("X-Cache") != "MISS" {
+			t.Errorf("Expected 404 MISS, got %d %s", resp.StatusCode, resp.Header.Get("X-Cache"))
+		}
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		ns := cache.Namespace("site")
+		ns.Set(ctx, "a.txt", strings.NewReader("a"), "text/plain", time.Hour)
+		h := Handler(ns, HandlerOptions{})
+		for _, path := range []string{"/a.txt", "/b.txt"} {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}
+		stats, err := ns.Stats()
+		if err != nil {
+			t.Fatalf("Failed to get stats: %v", err)
+		}
+		if stats.HitRate != 0.5 {
+			t.Errorf("Expected hit rate 0.5, got %v", stats.HitRate)
+		}
+	})
+
+	t.Run("Sendfile", func(t *testing.T) {
+		blobs, err := NewFSBlobCache(&Config{DataDir: t.TempDir(), MaxCacheSize: 1 << 20, MaxEntrySize: 1 << 20, DefaultTTL: time.Hour, CleanupInterval: time.Hour})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		defer blobs.Close()
+		blobs.Set(ctx, "video.bin", strings.NewReader("0123456789"), "application/octet-stream", time.Hour)
+
+		for _, rng := range []string{"", "bytes=2-5"} {
+			rec := &readFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+			req := httptest.NewRequest(http.MethodGet, "/video.bin", nil)
+			if rng != "" {
+				req.Header.Set("Range", rng)
+			}
+			Handler(blobs, HandlerOptions{}).ServeHTTP(rec, req)
+			want := "0123456789"
+			if rng != "" {
+				want = "2345"
+			}
+			if rec.Body.String() != want || !rec.file {
+				t.Errorf("Expected %q copied from the file for range %q, got %q (file %v)", want, rng, rec.Body.String(), rec.file)
+			}
+		}
+	})
+
+	t.Run("NotFoundHandler", func(t *testing.T) {
+		h := Handler(cache, HandlerOptions{NotFound: fallback})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+		if rec.Code != http.StatusBadGateway {
+			t.Errorf("Expected fallback handler, got %d", rec.Code)
+		}
+	})
+}
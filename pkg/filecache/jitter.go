@@ -0,0 +1,53 @@
+package filecache
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// ttlJitter 随机缩短TTL的设置
+type ttlJitter struct {
+	fraction float64
+	max      time.Duration
+}
+
+// ttlJitter 返回配置中的TTL抖动设置
+func (c *Config) ttlJitter() ttlJitter {
+	return ttlJitter{fraction: c.TTLJitter, max: c.TTLJitterMax}
+}
+
+// validate 检查抖动设置
+func (j ttlJitter) validate() error {
+	if j.fraction < 0 || j.fraction >= 1 {
+		return fmt.Errorf("ttl jitter must be in [0, 1)")
+	}
+	if j.max < 0 {
+		return fmt.Errorf("ttl jitter max cannot be negative")
+	}
+	return nil
+}
+
+// apply 返回在窗口内随机缩短的ttl：窗口为ttl乘以比例，不超过max；
+// 只设置max时窗口为max，最多为ttl的一半。TTL只会缩短，不会超过调用方给出的值
+func (j ttlJitter) apply(ttl time.Duration) time.Duration {
+	if ttl <= 0 || (j.fraction == 0 && j.max == 0) {
+		return ttl
+	}
+	var window time.Duration
+	if j.fraction > 0 {
+		window = time.Duration(float64(ttl) * j.fraction)
+		if j.max > 0 && window > j.max {
+			window = j.max
+		}
+	} else {
+		window = j.max
+		if window > ttl/2 {
+			window = ttl / 2
+		}
+	}
+	if window <= 0 {
+		return ttl
+	}
+	return ttl - time.Duration(rand.Int63n(int64(window)+1))
+}
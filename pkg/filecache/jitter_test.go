@@ -0,0 +1,91 @@
+package filecache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTTLJitter(t *testing.T) {
+	t.Run("apply", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			jitter ttlJitter
+			ttl    time.Duration
+			min    time.Duration
+		}{
+			{"fraction", ttlJitter{fraction: 0.1}, time.Hour, 54 * time.Minute},
+			{"fraction capped", ttlJitter{fraction: 0.5, max: time.Minute}, time.Hour, 59 * time.Minute},
+			{"duration", ttlJitter{max: 5 * time.Minute}, time.Hour, 55 * time.Minute},
+			{"duration capped at half", ttlJitter{max: time.Hour}, 10 * time.Minute, 5 * time.Minute},
+			{"disabled", ttlJitter{}, time.Hour, time.Hour},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				seen := make(map[time.Duration]bool)
+				for i := 0; i < 200; i++ {
+					got := tt.jitter.apply(tt.ttl)
+					if got < tt.min || got > tt.ttl {
+						t.Fatalf("Expected ttl in [%v, %v], got %v", tt.min, tt.ttl, got)
+					}
+					seen[got] = true
+				}
+				if tt.min < tt.ttl && len(seen) < 100 {
+					t.Errorf("Expected ttls to be spread out, got %d distinct values", len(seen))
+				}
+			})
+		}
+	})
+
+	t.Run("set", func(t *testing.T) {
+		cache, err := NewBadgerCache(&Config{
+			DataDir:         t.TempDir(),
+			MaxCacheSize:    1 << 20,
+			DefaultTTL:      time.Hour,
+			CleanupInterval: time.Hour,
+			TTLJitter:       0.2,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		defer cache.Close()
+
+		ctx := context.Background()
+		expiries := make(map[time.Time]bool)
+		for i := 0; i < 20; i++ {
+			key := fmt.Sprintf("warm-%d", i)
+			start := time.Now()
+			if err := cache.Set(ctx, key, strings.NewReader("x"), "text/plain", 0); err != nil {
+				t.Fatalf("Failed to set %s: %v", key, err)
+			}
+			info, err := cache.GetInfo(ctx, key)
+			if err != nil {
+				t.Fatalf("Failed to get info: %v", err)
+			}
+			if ttl := info.ExpiresAt.Sub(start); ttl < 47*time.Minute || ttl > time.Hour+time.Second {
+				t.Errorf("Expected ttl within the jitter window, got %v", ttl)
+			}
+			expiries[info.ExpiresAt.Truncate(time.Second)] = true
+		}
+		if len(expiries) < 10 {
+			t.Errorf("Expected entries written together to expire at different times, got %d distinct expiries", len(expiries))
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, config := range []*Config{
+			{DataDir: t.TempDir(), MaxCacheSize: 1 << 20, DefaultTTL: time.Hour, CleanupInterval: time.Hour, TTLJitter: 1},
+			{DataDir: t.TempDir(), MaxCacheSize: 1 << 20, DefaultTTL: time.Hour, CleanupInterval: time.Hour, TTLJitterMax: -time.Second},
+		} {
+			if err := ValidateConfig(config); err == nil {
+				t.Errorf("Expected ValidateConfig to reject jitter %v/%v", config.TTLJitter, config.TTLJitterMax)
+			}
+			if c, err := NewBadgerCache(config); err == nil {
+				c.Close()
+				t.Errorf("Expected NewBadgerCache to reject jitter %v/%v", config.TTLJitter, config.TTLJitterMax)
+			}
+		}
+	})
+}
@@ -0,0 +1,471 @@
+package filecache
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// defaultMemoryTTL 内存缓存的默认TTL
+const defaultMemoryTTL = 24 * time.Hour
+
+// memoryEntry 内存缓存条目
+type memoryEntry struct {
+	key   string       // 带命名空间前缀的完整键
+	owner *memoryCache // 条目所属的命名空间
+	data  []byte
+	info  FileInfo
+}
+
+// memoryStore 根缓存与各命名空间共享的LRU存储
+type memoryStore struct {
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List // 队首为最近访问
+	size       int64
+	maxBytes   int64
+	maxEntry   int64
+	defaultTTL time.Duration
+	ttls       *TTLPolicySet
+	jitter     ttlJitter
+}
+
+// memoryCache 有容量上限的内存文件缓存实现，按LRU淘汰
+// 空间不足时先丢弃已过期的条目，再淘汰最久未访问的条目
+type memoryCache struct {
+	store  *memoryStore
+	prefix string
+
+	mu           sync.RWMutex // 保护统计信息
+	stats        *Stats
+	hits, misses int64
+
+	namespaces map[string]*memoryCache
+	nsMu       sync.Mutex
+
+	cancel    context.CancelFunc // 停止后台清理，仅根缓存持有
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewMemoryCache 创建容量上限为maxBytes的内存文件缓存
+// 过期条目在读取时判断，在Cleanup或空间不足时删除
+func NewMemoryCache(maxBytes int64) Cache {
+	return newMemoryCache(maxBytes, maxBytes, defaultMemoryTTL)
+}
+
+// NewMemoryCacheWithConfig 使用配置创建内存文件缓存，并按CleanupInterval在后台清理过期文件
+// 使用MaxCacheSize、MaxEntrySize、DefaultTTL、TTLPolicies、TTLJitter、TTLJitterMax和CleanupInterval，
+// 其余与磁盘相关的选项被忽略
+func NewMemoryCacheWithConfig(config *Config) (Cache, error) {
+	if config == nil {
+		return nil, fmt.Errorf("config cannot be nil")
+	}
+	if config.MaxCacheSize <= 0 {
+		return nil, fmt.Errorf("max cache size must be positive")
+	}
+	if config.MaxEntrySize < 0 || config.MaxEntrySize > config.MaxCacheSize {
+		return nil, fmt.Errorf("invalid max entry size %d", config.MaxEntrySize)
+	}
+	if config.DefaultTTL <= 0 {
+		return nil, fmt.Errorf("default TTL must be positive")
+	}
+	if config.CleanupInterval <= 0 {
+		return nil, fmt.Errorf("cleanup interval must be positive")
+	}
+
+	ttls, err := NewTTLPolicySet(config.TTLPolicies)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.ttlJitter().validate(); err != nil {
+		return nil, err
+	}
+
+	cache := newMemoryCache(config.MaxCacheSize, config.maxEntrySize(), config.DefaultTTL)
+	cache.store.ttls = ttls
+	cache.store.jitter = config.ttlJitter()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cache.cancel = cancel
+	cache.wg.Add(1)
+	go cache.startCleanupRoutine(ctx, config.CleanupInterval)
+
+	return cache, nil
+}
+
+func newMemoryCache(maxBytes, maxEntry int64, defaultTTL time.Duration) *memoryCache {
+	return &memoryCache{
+		store: &memoryStore{
+			entries:    make(map[string]*list.Element),
+			lru:        list.New(),
+			maxBytes:   maxBytes,
+			maxEntry:   maxEntry,
+			defaultTTL: defaultTTL,
+		},
+		stats:      &Stats{},
+		namespaces: make(map[string]*memoryCache),
+	}
+}
+
+// Set 存储文件到缓存
+func (c *memoryCache) Set(ctx context.Context, key string, data io.Reader, mimeType string, ttl time.Duration) error {
+	c.store.mu.Lock()
+	defaultTTL, ttls, jitter := c.store.defaultTTL, c.store.ttls, c.store.jitter
+	c.store.mu.Unlock()
+	if ttl <= 0 && ttl != NoExpiry {
+		ttl = defaultTTL
+		if policyTTL, ok := ttls.TTL(key, mimeType); ok {
+			ttl = policyTTL
+		}
+	}
+
+	// 多读一个字节即可判断是否超出限制，无需读完超大的数据
+	dataBytes, err := io.ReadAll(io.LimitReader(data, c.store.maxEntry+1))
+	if err != nil {
+		return fmt.Errorf("failed to read data: %w", err)
+	}
+
+	size := int64(len(dataBytes))
+	if size > c.store.maxEntry {
+		return fmt.Errorf("%w: file size exceeds max entry size %d", ErrEntryTooLarge, c.store.maxEntry)
+	}
+
+	now := time.Now()
+	entry := &memoryEntry{
+		key:   c.prefix + key,
+		owner: c,
+		data:  dataBytes,
+		info: FileInfo{
+			Key:        key,
+			Size:       size,
+			MimeType:   mimeType,
+			CreatedAt:  now,
+			ExpiresAt:  expiryFor(now, jitter.apply(ttl)),
+			LastAccess: now,
+		},
+	}
+
+	s := c.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[entry.key]; ok {
+		s.removeElement(elem)
+	}
+	if s.size+size > s.maxBytes {
+		s.removeExpired(now, nil)
+	}
+	for s.size+size > s.maxBytes {
+		s.evictOldest()
+	}
+
+	s.entries[entry.key] = s.lru.PushFront(entry)
+	s.size += size
+	c.updateStatsAfterSet(size)
+
+	return nil
+}
+
+// Get 从缓存获取文件
+func (c *memoryCache) Get(ctx context.Context, key string) (io.ReadCloser, *FileInfo, error) {
+	return c.get(key, false, true)
+}
+
+// get 获取文件，allowExpired为true时也返回已过期但尚未清理的文件，track为false时不更新命中率、访问信息和LRU顺序
+func (c *memoryCache) get(key string, allowExpired, track bool) (io.ReadCloser, *FileInfo, error) {
+	s := c.store
+	s.mu.Lock()
+	elem, ok := s.entries[c.prefix+key]
+	if !ok {
+		s.mu.Unlock()
+		if track {
+			c.recordMiss()
+		}
+		return nil, nil, ErrNotFound
+	}
+
+	entry := elem.Value.(*memoryEntry)
+	if !allowExpired && time.Now().After(entry.info.ExpiresAt) {
+		s.mu.Unlock()
+		return nil, nil, ErrExpired
+	}
+
+	if track {
+		s.lru.MoveToFront(elem)
+		entry.info.AccessCount++
+		entry.info.LastAccess = time.Now()
+	}
+	info := entry.info
+	data := entry.data
+	s.mu.Unlock()
+
+	if track {
+		c.recordHit()
+	}
+	return newReadCloser(data), &info, nil
+}
+
+// Exists 检查文件是否存在
+func (c *memoryCache) Exists(ctx context.Context, key string) (bool, error) {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	_, ok := c.store.entries[c.prefix+key]
+	return ok, nil
+}
+
+// Delete 删除文件
+func (c *memoryCache) Delete(ctx context.Context, key string) error {
+	s := c.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[c.prefix+key]; ok {
+		s.removeElement(elem)
+	}
+	return nil
+}
+
+// List 列出当前命名空间的所有缓存文件
+func (c *memoryCache) List(ctx context.Context) ([]*FileInfo, error) {
+	s := c.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var files []*FileInfo
+	for elem := s.lru.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*memoryEntry)
+		if entry.owner != c {
+			continue
+		}
+		info := entry.info
+		files = append(files, &info)
+	}
+	return files, nil
+}
+
+// GetInfo 获取文件信息
+func (c *memoryCache) GetInfo(ctx context.Context, key string) (*FileInfo, error) {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	elem, ok := c.store.entries[c.prefix+key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	info := elem.Value.(*memoryEntry).info
+	return &info, nil
+}
+
+// Cleanup 清理当前命名空间的过期文件
+func (c *memoryCache) Cleanup(ctx context.Context) error {
+	now := time.Now()
+
+	c.store.mu.Lock()
+	expired := c.store.removeExpired(now, c)
+	c.store.mu.Unlock()
+
+	c.mu.Lock()
+	c.stats.ExpiredFiles = expired
+	c.stats.LastCleanup = now
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Flush 删除当前命名空间的所有文件并重置统计信息
+func (c *memoryCache) Flush(ctx context.Context) error {
+	s := c.store
+	s.mu.Lock()
+	for elem := s.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if elem.Value.(*memoryEntry).owner == c {
+			s.removeElement(elem)
+		}
+		elem = next
+	}
+	s.mu.Unlock()
+
+	c.mu.Lock()
+	c.stats = &Stats{}
+	c.hits, c.misses = 0, 0
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Namespace 返回键空间和统计信息相互隔离的子缓存，与根缓存共享容量上限
+func (c *memoryCache) Namespace(name string) Cache {
+	if name == "" {
+		return c
+	}
+
+	c.nsMu.Lock()
+	defer c.nsMu.Unlock()
+
+	if ns, ok := c.namespaces[name]; ok {
+		return ns
+	}
+	ns := &memoryCache{
+		store:      c.store,
+		prefix:     c.prefix + namespacePrefix + url.QueryEscape(name) + ":",
+		stats:      &Stats{},
+		namespaces: make(map[string]*memoryCache),
+	}
+	c.namespaces[name] = ns
+	return ns
+}
+
+// Close 关闭缓存，根缓存停止后台清理并释放所有条目，命名空间的Close不做任何事
+func (c *memoryCache) Close() error {
+	if c.prefix != "" {
+		return nil
+	}
+
+	c.closeOnce.Do(func() {
+		if c.cancel != nil {
+			c.cancel()
+			c.wg.Wait()
+		}
+
+		s := c.store
+		s.mu.Lock()
+		for elem := s.lru.Front(); elem != nil; elem = s.lru.Front() {
+			s.removeElement(elem)
+		}
+		s.mu.Unlock()
+	})
+	return nil
+}
+
+// Stats 获取缓存统计信息
+func (c *memoryCache) Stats() (*Stats, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	stats := *c.stats
+	return &stats, nil
+}
+
+// removeElement 移除条目并更新所属命名空间的统计，调用方需持有s.mu
+func (s *memoryStore) removeElement(elem *list.Element) {
+	entry := s.lru.Remove(elem).(*memoryEntry)
+	delete(s.entries, entry.key)
+	s.size -= entry.info.Size
+	entry.owner.updateStatsAfterDelete(entry.info.Size)
+}
+
+// removeExpired 删除owner的过期条目，owner为nil时删除所有命名空间的过期条目，调用方需持有s.mu
+func (s *memoryStore) removeExpired(now time.Time, owner *memoryCache) int64 {
+	var removed int64
+	for elem := s.lru.Front(); elem != nil; {
+		next := elem.Next()
+		entry := elem.Value.(*memoryEntry)
+		if (owner == nil || entry.owner == owner) && now.After(entry.info.ExpiresAt) {
+			s.removeElement(elem)
+			removed++
+		}
+		elem = next
+	}
+	return removed
+}
+
+// evictOldest 淘汰最久未访问的条目，调用方需持有s.mu
+func (s *memoryStore) evictOldest() {
+	elem := s.lru.Back()
+	if elem == nil {
+		return
+	}
+	owner := elem.Value.(*memoryEntry).owner
+	s.removeElement(elem)
+
+	owner.mu.Lock()
+	owner.stats.Evictions++
+	owner.mu.Unlock()
+}
+
+// cleanupAll 清理当前缓存及所有命名空间的过期文件
+func (c *memoryCache) cleanupAll(ctx context.Context) error {
+	if err := c.Cleanup(ctx); err != nil {
+		return err
+	}
+
+	c.nsMu.Lock()
+	namespaces := make([]*memoryCache, 0, len(c.namespaces))
+	for _, ns := range c.namespaces {
+		namespaces = append(namespaces, ns)
+	}
+	c.nsMu.Unlock()
+
+	for _, ns := range namespaces {
+		if err := ns.cleanupAll(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startCleanupRoutine 定期清理过期文件
+func (c *memoryCache) startCleanupRoutine(ctx context.Context, interval time.Duration) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = c.cleanupAll(ctx)
+		}
+	}
+}
+
+// updateStatsAfterSet 设置文件后更新统计
+func (c *memoryCache) updateStatsAfterSet(size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.TotalFiles++
+	c.stats.TotalSize += size
+}
+
+// updateStatsAfterDelete 删除文件后更新统计
+func (c *memoryCache) updateStatsAfterDelete(size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.TotalFiles--
+	c.stats.TotalSize -= size
+}
+
+// recordHit 记录一次命中
+func (c *memoryCache) recordHit() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hits++
+	c.updateHitRate()
+}
+
+// recordMiss 记录一次未命中
+func (c *memoryCache) recordMiss() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.misses++
+	c.updateHitRate()
+}
+
+// updateHitRate 根据命中和未命中次数计算命中率，调用方需持有c.mu
+func (c *memoryCache) updateHitRate() {
+	total := float64(c.hits + c.misses)
+	c.stats.HitRate = float64(c.hits) / total
+	c.stats.MissRate = float64(c.misses) / total
+}
@@ -0,0 +1,144 @@
+package filecache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache(t *testing.T) {
+	cache := NewMemoryCache(10)
+	defer cache.Close()
+
+	ctx := context.Background()
+	for _, key := range []string{"a", "b"} {
+		if err := cache.Set(ctx, key, strings.NewReader("1234"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set %s: %v", key, err)
+		}
+	}
+
+	// 访问a后写入c，淘汰最久未访问的b
+	if got := readString(t, cache, "a"); got != "1234" {
+		t.Errorf("Expected '1234', got '%s'", got)
+	}
+	if err := cache.Set(ctx, "c", strings.NewReader("1234"), "text/plain", time.Hour); err != nil {
+		t.Fatalf("Failed to set c: %v", err)
+	}
+	if exists, _ := cache.Exists(ctx, "b"); exists {
+		t.Error("Expected b to be evicted")
+	}
+	if exists, _ := cache.Exists(ctx, "a"); !exists {
+		t.Error("Expected a to survive eviction")
+	}
+
+	stats, _ := cache.Stats()
+	if stats.TotalFiles != 2 || stats.TotalSize != 8 || stats.Evictions != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	if err := cache.Set(ctx, "huge", strings.NewReader("0123456789a"), "text/plain", time.Hour); !errors.Is(err, ErrEntryTooLarge) {
+		t.Errorf("Expected ErrEntryTooLarge, got %v", err)
+	}
+	if _, _, err := cache.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestMemoryCacheWithConfig(t *testing.T) {
+	cache, err := NewMemoryCacheWithConfig(&Config{
+		MaxCacheSize:    16,
+		MaxEntrySize:    8,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+
+	t.Run("MaxEntrySize", func(t *testing.T) {
+		err := cache.Set(ctx, "big", strings.NewReader("123456789"), "text/plain", 0)
+		if !errors.Is(err, ErrEntryTooLarge) {
+			t.Errorf("Expected ErrEntryTooLarge, got %v", err)
+		}
+	})
+
+	t.Run("DefaultTTL", func(t *testing.T) {
+		if err := cache.Set(ctx, "a", strings.NewReader("1234"), "text/plain", 0); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		info, err := cache.GetInfo(ctx, "a")
+		if err != nil {
+			t.Fatalf("Failed to get info: %v", err)
+		}
+		if ttl := info.ExpiresAt.Sub(info.CreatedAt); ttl != time.Hour {
+			t.Errorf("Expected 1h TTL, got %v", ttl)
+		}
+	})
+
+	t.Run("ExpiredFirstEviction", func(t *testing.T) {
+		// 空间不足时优先丢弃过期条目，而不是最久未访问的a
+		if err := cache.Set(ctx, "short", strings.NewReader("12345678"), "text/plain", time.Millisecond); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+		if err := cache.Set(ctx, "b", strings.NewReader("12345678"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		if exists, _ := cache.Exists(ctx, "a"); !exists {
+			t.Error("Expected a to survive")
+		}
+		if exists, _ := cache.Exists(ctx, "short"); exists {
+			t.Error("Expected expired entry to be dropped")
+		}
+
+		stats, _ := cache.Stats()
+		if stats.Evictions != 0 {
+			t.Errorf("Expected no evictions, got %d", stats.Evictions)
+		}
+	})
+
+	t.Run("BackgroundCleanup", func(t *testing.T) {
+		ns := cache.Namespace("preview")
+		if err := ns.Set(ctx, "tmp", strings.NewReader("1"), "text/plain", time.Millisecond); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			if exists, _ := ns.Exists(ctx, "tmp"); !exists {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("Expected background cleanup to remove expired namespace entry")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		if err := cache.Close(); err != nil {
+			t.Fatalf("Failed to close cache: %v", err)
+		}
+		if err := cache.Close(); err != nil {
+			t.Fatalf("Failed to close cache twice: %v", err)
+		}
+		files, _ := cache.List(ctx)
+		if len(files) != 0 {
+			t.Errorf("Expected entries to be released, got %d", len(files))
+		}
+	})
+
+	t.Run("InvalidConfig", func(t *testing.T) {
+		if _, err := NewMemoryCacheWithConfig(&Config{MaxCacheSize: 10, MaxEntrySize: 20, DefaultTTL: time.Hour, CleanupInterval: time.Hour}); err == nil {
+			t.Error("Expected error for max entry size above max cache size")
+		}
+		if _, err := NewMemoryCacheWithConfig(nil); err == nil {
+			t.Error("Expected error for nil config")
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package filecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultMigrateConcurrency 默认并发迁移数
+const defaultMigrateConcurrency = 4
+
+// MigrateOptions 迁移选项
+type MigrateOptions struct {
+	Concurrency     int                  // 并发复制的文件数，默认4
+	SkipExisting    bool                 // 目标中已存在的文件不再复制
+	ContinueOnError bool                 // 单个文件失败时继续迁移，错误记录在结果中
+	Filter          func(*FileInfo) bool // 返回false的文件不迁移，为nil时迁移全部
+	Progress        func(*MigrateResult) // 每处理完一个文件后回调，回调是串行的
+}
+
+// MigrateResult 迁移结果
+type MigrateResult struct {
+	Total   int64   `json:"total"`   // 源缓存中的文件数
+	Copied  int64   `json:"copied"`  // 已复制的文件数
+	Skipped int64   `json:"skipped"` // 已过期、被过滤或已存在而跳过的文件数
+	Failed  int64   `json:"failed"`  // 复制失败的文件数
+	Bytes   int64   `json:"bytes"`   // 已复制的字节数
+	Errors  []error `json:"-"`       // ContinueOnError时记录的错误
+}
+
+// Migrate 把src中所有未过期的文件流式复制到dst，保留MIME类型和剩余TTL
+// src实现Peeker时只读地读取文件，迁移不影响src的命中率和淘汰顺序；列出之后被删除或过期的文件计为跳过。
+// 只迁移src当前命名空间的文件，迁移其他命名空间需分别传入 src.Namespace(name) 和 dst.Namespace(name)
+func Migrate(ctx context.Context, src, dst Cache, opts MigrateOptions) (*MigrateResult, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultMigrateConcurrency
+	}
+
+	files, err := src.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source files: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	result := &MigrateResult{Total: int64(len(files))}
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	work := make(chan *FileInfo)
+
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range work {
+				copied, size, err := migrateFile(ctx, src, dst, file, opts)
+
+				mu.Lock()
+				switch {
+				case err != nil:
+					result.Failed++
+					err = fmt.Errorf("failed to migrate %q: %w", file.Key, err)
+					if opts.ContinueOnError {
+						result.Errors = append(result.Errors, err)
+					} else if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+				case copied:
+					result.Copied++
+					result.Bytes += size
+				default:
+					result.Skipped++
+				}
+				if opts.Progress != nil {
+					snapshot := *result
+					opts.Progress(&snapshot)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, file := range files {
+		select {
+		case work <- file:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	if firstErr != nil {
+		return result, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// migrateFile 复制单个文件，返回是否复制以及复制的字节数
+func migrateFile(ctx context.Context, src, dst Cache, file *FileInfo, opts MigrateOptions) (bool, int64, error) {
+	if time.Now().After(file.ExpiresAt) {
+		return false, 0, nil
+	}
+	if opts.Filter != nil && !opts.Filter(file) {
+		return false, 0, nil
+	}
+	if opts.SkipExisting {
+		exists, err := dst.Exists(ctx, file.Key)
+		if err != nil {
+			return false, 0, err
+		}
+		if exists {
+			return false, 0, nil
+		}
+	}
+
+	get := src.Get
+	if peeker, ok := src.(Peeker); ok {
+		get = peeker.Peek
+	}
+	reader, info, err := get(ctx, file.Key)
+	if errors.Is(err, ErrNotFound) {
+		// 列出后被删除，或已过期（ErrExpired）
+		return false, 0, nil
+	}
+	if err != nil {
+		return false, 0, err
+	}
+	defer reader.Close()
+
+	ttl, ok := info.RemainingTTL()
+	if !ok {
+		return false, 0, nil
+	}
+	if err := dst.Set(ctx, file.Key, reader, info.MimeType, ttl); err != nil {
+		return false, 0, err
+	}
+	return true, info.Size, nil
+}
@@ -0,0 +1,131 @@
+package filecache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+
+	src := newTestBadgerCache(t)
+	defer src.Close()
+	dst, err := NewFSBlobCache(&Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create destination cache: %v", err)
+	}
+	defer dst.Close()
+
+	for _, key := range []string{"a.txt", "b.txt", "c.txt", "skip.log"} {
+		if err := src.Set(ctx, key, strings.NewReader("data-"+key), "text/plain", 2*time.Hour); err != nil {
+			t.Fatalf("Failed to set %s: %v", key, err)
+		}
+	}
+	if err := src.Set(ctx, "expired.txt", strings.NewReader("old"), "text/plain", 10*time.Millisecond); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	// 目标中已存在的文件不会被覆盖
+	if err := dst.Set(ctx, "c.txt", strings.NewReader("existing"), "text/plain", time.Hour); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+
+	var progress int
+	result, err := Migrate(ctx, src, dst, MigrateOptions{
+		Concurrency:  2,
+		SkipExisting: true,
+		Filter: func(info *FileInfo) bool {
+			return !strings.HasSuffix(info.Key, ".log")
+		},
+		Progress: func(*MigrateResult) {
+			progress++
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	if result.Total != 5 || result.Copied != 2 || result.Skipped != 3 || result.Failed != 0 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if progress != 5 {
+		t.Errorf("Expected 5 progress callbacks, got %d", progress)
+	}
+
+	if got := readString(t, dst, "a.txt"); got != "data-a.txt" {
+		t.Errorf("Expected 'data-a.txt', got %q", got)
+	}
+	if got := readString(t, dst, "c.txt"); got != "existing" {
+		t.Errorf("Expected existing file to be kept, got %q", got)
+	}
+	for _, key := range []string{"skip.log", "expired.txt"} {
+		if exists, _ := dst.Exists(ctx, key); exists {
+			t.Errorf("Expected %s not to be migrated", key)
+		}
+	}
+
+	// 保留剩余TTL而不是使用目标的默认TTL
+	info, err := dst.GetInfo(ctx, "b.txt")
+	if err != nil {
+		t.Fatalf("Failed to get info: %v", err)
+	}
+	if ttl := time.Until(info.ExpiresAt); ttl < 119*time.Minute {
+		t.Errorf("Expected TTL to be preserved, got %v", ttl)
+	}
+	if info.MimeType != "text/plain" {
+		t.Errorf("Expected MIME type to be preserved, got %q", info.MimeType)
+	}
+}
+
+func TestMigrateExpiredDuringCopy(t *testing.T) {
+	ctx := context.Background()
+
+	badger := newTestBadgerCache(t)
+	defer badger.Close()
+	// 只实现Cache接口，Migrate只能使用Get
+	getOnly := struct{ Cache }{NewMemoryCache(1 << 20)}
+
+	for _, src := range []Cache{badger, getOnly} {
+		for _, key := range []string{"keep.txt", "short.txt"} {
+			ttl := time.Hour
+			if key == "short.txt" {
+				ttl = 50 * time.Millisecond
+			}
+			if err := src.Set(ctx, key, strings.NewReader("data"), "text/plain", ttl); err != nil {
+				t.Fatalf("Failed to set %s: %v", key, err)
+			}
+		}
+
+		// 列出时未过期，读取时已过期
+		dst := NewMemoryCache(1 << 20)
+		result, err := Migrate(ctx, src, dst, MigrateOptions{
+			Concurrency: 1,
+			Filter: func(info *FileInfo) bool {
+				if info.Key == "short.txt" {
+					time.Sleep(100 * time.Millisecond)
+				}
+				return true
+			},
+		})
+		dst.Close()
+		if err != nil {
+			t.Fatalf("Failed to migrate: %v", err)
+		}
+		if result.Copied != 1 || result.Skipped != 1 || result.Failed != 0 {
+			t.Errorf("Expected the expired file to be skipped, got %+v", result)
+		}
+	}
+
+	// 只读地读取源缓存
+	if info, _ := badger.GetInfo(ctx, "keep.txt"); info.AccessCount != 0 {
+		t.Errorf("Expected the source access count to be unchanged, got %d", info.AccessCount)
+	}
+}
@@ -0,0 +1,128 @@
+package filecache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// defaultMmapMinAccesses 文件使用内存映射读取前默认的访问次数
+const defaultMmapMinAccesses = 2
+
+// errMmapUnsupported 当前平台不支持内存映射
+var errMmapUnsupported = errors.New("mmap is not supported on this platform")
+
+// mmapRegistry 按路径共享的只读内存映射：同一个文件的并发读取使用同一个映射，直接读取页缓存，
+// 不需要各自通过read系统调用复制数据。最后一个读取者关闭后解除映射，
+// 被删除或替换的文件不会因为映射而继续占用磁盘空间
+type mmapRegistry struct {
+	minSize     int64
+	minAccesses int64
+
+	mu   sync.Mutex
+	maps map[string]*mapping
+}
+
+// mapping 一个文件的内存映射
+type mapping struct {
+	data []byte
+	stat os.FileInfo // 映射时的文件信息，用于判断路径上的文件是否已被替换
+	refs int
+}
+
+// newMmapRegistry 创建内存映射表，minAccesses为0时使用默认值
+func newMmapRegistry(minSize, minAccesses int64) *mmapRegistry {
+	if minAccesses == 0 {
+		minAccesses = defaultMmapMinAccesses
+	}
+	return &mmapRegistry{minSize: minSize, minAccesses: minAccesses, maps: make(map[string]*mapping)}
+}
+
+// eligible 判断文件是否使用内存映射读取：足够大，并且加上本次访问达到访问次数
+func (r *mmapRegistry) eligible(info *FileInfo) bool {
+	return r != nil && info.Size > 0 && info.Size >= r.minSize && info.AccessCount+1 >= r.minAccesses
+}
+
+// open 返回读取path的reader，与已有的映射是同一个文件时共享映射，否则创建新的映射
+func (r *mmapRegistry) open(path string) (io.ReadCloser, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := r.maps[path]
+	if m == nil || !sameFile(m.stat, stat) {
+		if m, err = mapFile(path); err != nil {
+			return nil, err
+		}
+		// 旧的映射由仍在读取的reader持有，它们关闭后解除
+		r.maps[path] = m
+	}
+	m.refs++
+	return &mmapReader{Reader: bytes.NewReader(m.data), registry: r, path: path, mapping: m}, nil
+}
+
+// release 释放一个引用，最后一个引用释放时解除映射
+func (r *mmapRegistry) release(path string, m *mapping) {
+	r.mu.Lock()
+	m.refs--
+	last := m.refs == 0
+	if last && r.maps[path] == m {
+		delete(r.maps, path)
+	}
+	r.mu.Unlock()
+	if last {
+		munmap(m.data)
+	}
+}
+
+// sameFile 判断两次Stat是否为同一个未修改的文件
+func sameFile(a, b os.FileInfo) bool {
+	return os.SameFile(a, b) && a.Size() == b.Size() && a.ModTime().Equal(b.ModTime())
+}
+
+// mapFile 只读映射整个文件
+func mapFile(path string) (*mapping, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := stat.Size()
+	if size == 0 || int64(int(size)) != size {
+		return nil, fmt.Errorf("cannot map %d bytes", size)
+	}
+	data, err := mmap(file, int(size))
+	if err != nil {
+		return nil, fmt.Errorf("failed to map blob: %w", err)
+	}
+	return &mapping{data: data, stat: stat}, nil
+}
+
+// mmapReader 读取共享映射的reader，实现io.ReadSeeker、io.ReaderAt和io.WriterTo；
+// 关闭后不能再读取
+type mmapReader struct {
+	*bytes.Reader
+	registry *mmapRegistry
+	path     string
+	mapping  *mapping
+	once     sync.Once
+}
+
+// Close 释放映射的引用
+func (r *mmapReader) Close() error {
+	r.once.Do(func() {
+		r.Reader = bytes.NewReader(nil)
+		r.registry.release(r.path, r.mapping)
+	})
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package filecache
+
+import "os"
+
+// mmap 当前平台不支持，调用方改为直接读取文件
+func mmap(file *os.File, size int) ([]byte, error) {
+	return nil, errMmapUnsupported
+}
+
+// munmap 当前平台不支持
+func munmap(data []byte) error {
+	return errMmapUnsupported
+}
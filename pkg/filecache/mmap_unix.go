@@ -0,0 +1,19 @@
+//go:build unix
+
+package filecache
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// mmap 只读、共享地映射file的前size个字节
+func mmap(file *os.File, size int) ([]byte, error) {
+	return unix.Mmap(int(file.Fd()), 0, size, unix.PROT_READ, unix.MAP_SHARED)
+}
+
+// munmap 解除映射
+func munmap(data []byte) error {
+	return unix.Munmap(data)
+}
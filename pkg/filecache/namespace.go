@@ -0,0 +1,165 @@
+package filecache
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	// 命名空间键前缀，子命名空间的键形如 ns:<name>:info:<key>
+	namespacePrefix = "ns:"
+	// 命名空间注册表前缀，用于后台清理时找回所有命名空间
+	namespaceRegistryPrefix = "nsreg:"
+)
+
+// dataKey 返回文件数据键
+func (c *badgerCache) dataKey(key string) []byte {
+	return []byte(c.prefix + fileDataPrefix + key)
+}
+
+// infoKey 返回文件信息键
+func (c *badgerCache) infoKey(key string) []byte {
+	return []byte(c.prefix + fileInfoPrefix + key)
+}
+
+// trashDataKey 返回回收站文件数据键
+func (c *badgerCache) trashDataKey(key string) []byte {
+	return []byte(c.prefix + trashDataPrefix + key)
+}
+
+// trashInfoKey 返回回收站文件信息键
+func (c *badgerCache) trashInfoKey(key string) []byte {
+	return []byte(c.prefix + trashInfoPrefix + key)
+}
+
+// Namespace 返回共享同一个Badger实例、但键空间和统计信息相互隔离的子缓存
+// 同名命名空间返回同一个实例；name为空时返回当前缓存
+func (c *badgerCache) Namespace(name string) Cache {
+	if name == "" {
+		return c
+	}
+
+	c.nsMu.Lock()
+	defer c.nsMu.Unlock()
+
+	if ns, ok := c.namespaces[name]; ok {
+		return ns
+	}
+
+	escaped := url.QueryEscape(name)
+	path := name
+	if c.name != "" {
+		path = c.name + "/" + name
+	}
+	ns := &badgerCache{
+		store:      c.store,
+		blobs:      c.blobs,
+		config:     c.config,
+		ttls:       c.ttls,
+		stats:      &Stats{},
+		prefix:     c.prefix + namespacePrefix + escaped + ":",
+		name:       path,
+		namespaces: make(map[string]*badgerCache),
+	}
+	if err := ns.loadStats(); err != nil {
+		ns.stats = &Stats{}
+	}
+
+	// 记录到注册表，进程重启后后台清理仍能覆盖该命名空间
+	c.store.update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(c.prefix+namespaceRegistryPrefix+escaped), []byte(name))
+	})
+
+	c.namespaces[name] = ns
+	return ns
+}
+
+// registeredNamespaces 返回注册表中当前缓存的所有直接子命名空间
+func (c *badgerCache) registeredNamespaces() ([]string, error) {
+	var names []string
+
+	err := c.store.view(func(txn *badger.Txn) error {
+		prefix := []byte(c.prefix + namespaceRegistryPrefix)
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.ValidForPrefix(prefix); it.Next() {
+			name, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			names = append(names, string(name))
+		}
+		return nil
+	})
+
+	return names, err
+}
+
+// Namespaces 返回注册表中的直接子命名空间，按转义后的名称排序
+func (c *badgerCache) Namespaces(ctx context.Context) ([]string, error) {
+	return c.registeredNamespaces()
+}
+
+// cleanupAll 清理当前缓存及其所有子命名空间
+func (c *badgerCache) cleanupAll(ctx context.Context) error {
+	if err := c.Cleanup(ctx); err != nil {
+		return err
+	}
+
+	names, err := c.registeredNamespaces()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		ns := c.Namespace(name).(*badgerCache)
+		if err := ns.cleanupAll(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveAllStats 保存当前缓存及已打开的子命名空间的统计信息
+func (c *badgerCache) saveAllStats() error {
+	err := c.saveStats()
+
+	c.nsMu.Lock()
+	namespaces := make([]*badgerCache, 0, len(c.namespaces))
+	for _, ns := range c.namespaces {
+		namespaces = append(namespaces, ns)
+	}
+	c.nsMu.Unlock()
+
+	for _, ns := range namespaces {
+		if nsErr := ns.saveAllStats(); nsErr != nil && err == nil {
+			err = nsErr
+		}
+	}
+	return err
+}
+
+// reloadAllStats 从数据库重新加载当前缓存及已打开的子命名空间的统计信息
+func (c *badgerCache) reloadAllStats() error {
+	c.mu.Lock()
+	err := c.loadStats()
+	c.mu.Unlock()
+
+	c.nsMu.Lock()
+	namespaces := make([]*badgerCache, 0, len(c.namespaces))
+	for _, ns := range c.namespaces {
+		namespaces = append(namespaces, ns)
+	}
+	c.nsMu.Unlock()
+
+	for _, ns := range namespaces {
+		if nsErr := ns.reloadAllStats(); nsErr != nil && err == nil {
+			err = nsErr
+		}
+	}
+	return err
+}
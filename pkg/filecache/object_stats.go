@@ -0,0 +1,98 @@
+package filecache
+
+import (
+	"sync"
+	"time"
+)
+
+// objectStats 对象存储后端的统计信息
+// 对象存储无法低成本地维护全局计数，这里只记录当前进程的观察结果，总量在列举时刷新
+type objectStats struct {
+	mu           sync.RWMutex
+	stats        Stats
+	hits, misses int64
+}
+
+// hit 记录一次命中
+func (s *objectStats) hit() {
+	s.mu.Lock()
+	s.hits++
+	s.mu.Unlock()
+}
+
+// miss 记录一次未命中
+func (s *objectStats) miss() {
+	s.mu.Lock()
+	s.misses++
+	s.mu.Unlock()
+}
+
+// added 记录一次写入，old为被覆盖的对象，为nil时计为新文件
+func (s *objectStats) added(size int64, old *FileInfo) {
+	s.mu.Lock()
+	if old == nil {
+		s.stats.TotalFiles++
+	} else {
+		size -= old.Size
+	}
+	s.stats.TotalSize += size
+	if s.stats.TotalSize < 0 {
+		s.stats.TotalSize = 0
+	}
+	s.mu.Unlock()
+}
+
+// removed 记录删除了对象old
+func (s *objectStats) removed(old *FileInfo) {
+	s.mu.Lock()
+	if s.stats.TotalFiles > 0 {
+		s.stats.TotalFiles--
+	}
+	s.stats.TotalSize -= old.Size
+	if s.stats.TotalSize < 0 {
+		s.stats.TotalSize = 0
+	}
+	s.mu.Unlock()
+}
+
+// refresh 按列举结果刷新总量
+func (s *objectStats) refresh(files []*FileInfo) {
+	var size int64
+	for _, file := range files {
+		size += file.Size
+	}
+
+	s.mu.Lock()
+	s.stats.TotalFiles = int64(len(files))
+	s.stats.TotalSize = size
+	s.mu.Unlock()
+}
+
+// cleaned 记录一次清理，总量由调用方按剩余文件refresh
+func (s *objectStats) cleaned(removed int64, at time.Time) {
+	s.mu.Lock()
+	s.stats.ExpiredFiles = removed
+	s.stats.LastCleanup = at
+	s.mu.Unlock()
+}
+
+// reset 重置统计信息
+func (s *objectStats) reset() {
+	s.mu.Lock()
+	s.stats = Stats{}
+	s.hits, s.misses = 0, 0
+	s.mu.Unlock()
+}
+
+// snapshot 返回统计信息副本
+func (s *objectStats) snapshot() *Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := s.stats
+	if total := float64(s.hits + s.misses); total > 0 {
+		stats.HitRate = float64(s.hits) / total
+		stats.MissRate = float64(s.misses) / total
+	}
+	return &stats
+}
@@ -0,0 +1,254 @@
+package filecache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// orphanRecord 扫描发现的不成对记录
+type orphanRecord struct {
+	key   string
+	trash bool
+	info  bool // 为true时是没有文件数据的FileInfo，否则是没有FileInfo的文件数据
+}
+
+// removeOrphans 删除当前命名空间中不成对的记录：崩溃可能留下没有FileInfo的文件数据，它们不出现在List中、
+// 也永远不会过期；没有文件数据的FileInfo则无法读取。回收站中的记录同样检查，文件系统后端中没有记录的文件
+// 超过verifyOrphanAge未修改才删除。只读取键，不读取文件数据，返回删除的记录和文件数
+func (c *badgerCache) removeOrphans(ctx context.Context) (int64, error) {
+	var removed int64
+	known := make(map[string]bool)
+	for _, trash := range []bool{false, true} {
+		orphans, err := c.findOrphans(ctx, trash, known)
+		if err != nil {
+			return removed, fmt.Errorf("failed to scan for orphaned records: %w", err)
+		}
+		for start := 0; start < len(orphans); start += defaultCleanupBatchSize {
+			end := start + defaultCleanupBatchSize
+			if end > len(orphans) {
+				end = len(orphans)
+			}
+			n, err := c.deleteOrphans(orphans[start:end])
+			removed += n
+			if err != nil {
+				return removed, fmt.Errorf("failed to remove orphaned records: %w", err)
+			}
+		}
+	}
+	if c.blobs == nil {
+		return removed, nil
+	}
+
+	err := c.walkOrphanBlobs(ctx, known, func(path, _ string) error {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+// findOrphans 在只读事务中找出不成对的记录（trash为true时为回收站记录）。Badger中的FileInfo和文件数据
+// 按相同的键排序，同时遍历两个前缀即可配对；文件系统后端检查每条FileInfo的文件是否存在，并把文件路径记入known
+func (c *badgerCache) findOrphans(ctx context.Context, trash bool, known map[string]bool) ([]orphanRecord, error) {
+	infoPrefix, dataPrefix := []byte(c.prefix+fileInfoPrefix), []byte(c.prefix+fileDataPrefix)
+	if trash {
+		infoPrefix, dataPrefix = []byte(c.prefix+trashInfoPrefix), []byte(c.prefix+trashDataPrefix)
+	}
+
+	var orphans []orphanRecord
+	err := c.store.view(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = infoPrefix
+		infos := txn.NewIterator(opts)
+		defer infos.Close()
+
+		if c.blobs != nil {
+			for infos.Rewind(); infos.ValidForPrefix(infoPrefix); infos.Next() {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				key := string(infos.Item().Key()[len(infoPrefix):])
+				_, _, path := c.entryKeys(key, trash)
+				known[path] = true
+				if _, err := os.Stat(path); os.IsNotExist(err) {
+					orphans = append(orphans, orphanRecord{key: key, trash: trash, info: true})
+				}
+			}
+			return nil
+		}
+
+		opts.Prefix = dataPrefix
+		data := txn.NewIterator(opts)
+		defer data.Close()
+		infos.Rewind()
+		data.Rewind()
+		for infos.ValidForPrefix(infoPrefix) || data.ValidForPrefix(dataPrefix) {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var infoKey, dataKey []byte
+			if infos.ValidForPrefix(infoPrefix) {
+				infoKey = infos.Item().Key()[len(infoPrefix):]
+			}
+			if data.ValidForPrefix(dataPrefix) {
+				dataKey = data.Item().Key()[len(dataPrefix):]
+			}
+			switch {
+			case dataKey == nil || (infoKey != nil && bytes.Compare(infoKey, dataKey) < 0):
+				orphans = append(orphans, orphanRecord{key: string(infoKey), trash: trash, info: true})
+				infos.Next()
+			case infoKey == nil || bytes.Compare(dataKey, infoKey) < 0:
+				orphans = append(orphans, orphanRecord{key: string(dataKey), trash: trash})
+				data.Next()
+			default:
+				infos.Next()
+				data.Next()
+			}
+		}
+		return nil
+	})
+	return orphans, err
+}
+
+// deleteOrphans 在单个事务中删除一批不成对的记录，删除前重新检查，不会误删扫描之后被写入的条目
+func (c *badgerCache) deleteOrphans(orphans []orphanRecord) (int64, error) {
+	var removed int64
+	var sizes []int64 // 被删除的FileInfo记录的大小，用于修正统计信息
+	err := c.store.update(func(txn *badger.Txn) error {
+		for _, o := range orphans {
+			infoKey, dataKey, path := c.entryKeys(o.key, o.trash)
+			info, err := txn.Get(infoKey)
+			if err != nil && err != badger.ErrKeyNotFound {
+				return err
+			}
+			hasInfo := err == nil
+
+			hasData := false
+			if path != "" {
+				_, err = os.Stat(path)
+				hasData = !os.IsNotExist(err)
+			} else {
+				_, err = txn.Get(dataKey)
+				if err != nil && err != badger.ErrKeyNotFound {
+					return err
+				}
+				hasData = err == nil
+			}
+			if !o.info {
+				if hasInfo || !hasData {
+					continue
+				}
+				if err := txn.Delete(dataKey); err != nil {
+					return err
+				}
+				removed++
+				continue
+			}
+
+			if !hasInfo || hasData {
+				continue
+			}
+			// 无法解析的FileInfo按大小为0处理，Verify会报告统计信息的偏差
+			var size int64
+			_ = info.Value(func(val []byte) error {
+				fileInfo := &FileInfo{}
+				if err := json.Unmarshal(val, fileInfo); err != nil {
+					return err
+				}
+				size = fileInfo.Size
+				return nil
+			})
+			if err := txn.Delete(infoKey); err != nil {
+				return err
+			}
+			removed++
+			if !o.trash {
+				sizes = append(sizes, size)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, size := range sizes {
+		c.updateStatsAfterDelete(size)
+	}
+	return removed, nil
+}
+
+// entryKeys 返回条目（trash为true时为回收站条目）的FileInfo键、文件数据键和文件系统后端中的文件路径
+func (c *badgerCache) entryKeys(key string, trash bool) (info, data []byte, path string) {
+	if trash {
+		info, data = c.trashInfoKey(key), c.trashDataKey(key)
+		if c.blobs != nil {
+			path = c.trashBlobPath(key)
+		}
+		return info, data, path
+	}
+	info, data = c.infoKey(key), c.dataKey(key)
+	if c.blobs != nil {
+		path = c.blobPath(key)
+	}
+	return info, data, path
+}
+
+// walkOrphanBlobs 对文件系统后端中当前命名空间目录下不在known中、超过verifyOrphanAge未修改的文件调用fn，
+// 在根命名空间上还包括写入中断留下的临时文件；what说明文件的类型
+func (c *badgerCache) walkOrphanBlobs(ctx context.Context, known map[string]bool, fn func(path, what string) error) error {
+	cutoff := time.Now().Add(-verifyOrphanAge)
+	orphan := func(path string, entry fs.DirEntry, what string) error {
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(cutoff) {
+			return nil
+		}
+		return fn(path, what)
+	}
+
+	err := filepath.WalkDir(c.blobs.namespaceDir(c.prefix), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() || known[path] {
+			return nil
+		}
+		return orphan(path, entry, "file without FileInfo:")
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan blobs: %w", err)
+	}
+	if c.prefix != "" {
+		return nil
+	}
+
+	entries, err := os.ReadDir(c.blobs.tmpDir())
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to scan blobs: %w", err)
+	}
+	for _, entry := range entries {
+		if err := orphan(filepath.Join(c.blobs.tmpDir(), entry.Name()), entry, "temporary file left by an interrupted write:"); err != nil {
+			return fmt.Errorf("failed to scan blobs: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package filecache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestCleanupOrphans(t *testing.T) {
+	cache, err := NewBadgerCache(&Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+		SoftDelete:      true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	root := cache.(*badgerCache)
+	tenant := cache.Namespace("tenant").(*badgerCache)
+	for _, c := range []*badgerCache{root, tenant} {
+		for _, key := range []string{"ok", "missing", "trashed"} {
+			if err := c.Set(ctx, key, strings.NewReader("hello"), "text/plain", time.Hour); err != nil {
+				t.Fatalf("Failed to set %s: %v", key, err)
+			}
+		}
+		if err := c.Delete(ctx, "trashed"); err != nil {
+			t.Fatalf("Failed to delete file: %v", err)
+		}
+	}
+
+	// 模拟崩溃留下的不成对记录
+	err = root.store.update(func(txn *badger.Txn) error {
+		if err := txn.Delete(root.dataKey("missing")); err != nil {
+			return err
+		}
+		if err := txn.Set(root.dataKey("orphan"), []byte("data")); err != nil {
+			return err
+		}
+		if err := txn.Delete(root.trashInfoKey("trashed")); err != nil {
+			return err
+		}
+		return txn.Delete(tenant.dataKey("missing"))
+	})
+	if err != nil {
+		t.Fatalf("Failed to damage cache: %v", err)
+	}
+
+	t.Run("Partial", func(t *testing.T) {
+		result, err := root.CleanupWithOptions(ctx, CleanupOptions{BatchSize: 1, MaxEntries: 1})
+		if err != nil {
+			t.Fatalf("Failed to clean up: %v", err)
+		}
+		if result.Done || result.Orphans != 0 {
+			t.Errorf("Expected orphans to be left until a full pass, got %+v", result)
+		}
+	})
+
+	t.Run("Root", func(t *testing.T) {
+		result, err := root.CleanupWithOptions(ctx, CleanupOptions{})
+		if err != nil {
+			t.Fatalf("Failed to clean up: %v", err)
+		}
+		if !result.Done || result.Orphans != 3 {
+			t.Errorf("Expected 3 orphaned records to be removed, got %+v", result)
+		}
+		if ok, _ := root.Exists(ctx, "missing"); ok {
+			t.Error("Expected FileInfo without data to be removed")
+		}
+		if ok, _ := root.Exists(ctx, "ok"); !ok {
+			t.Error("Expected healthy entries to be kept")
+		}
+		stats, _ := root.Stats()
+		if stats.TotalFiles != 1 || stats.TotalSize != 5 {
+			t.Errorf("Expected stats to be updated, got %d files %d bytes", stats.TotalFiles, stats.TotalSize)
+		}
+
+		// 命名空间中的不成对记录由命名空间自己的清理处理
+		report, err := cache.(Verifier).Verify(ctx, VerifyOptions{})
+		if err != nil {
+			t.Fatalf("Failed to verify: %v", err)
+		}
+		if got := strings.Join(problemKinds(report), ","); got != "tenant/missing:missing_data" {
+			t.Errorf("Expected only the namespace to be left, got %s", got)
+		}
+	})
+
+	t.Run("Namespace", func(t *testing.T) {
+		result, err := tenant.CleanupWithOptions(ctx, CleanupOptions{})
+		if err != nil {
+			t.Fatalf("Failed to clean up: %v", err)
+		}
+		if result.Orphans != 1 {
+			t.Errorf("Expected 1 orphaned record to be removed, got %+v", result)
+		}
+		report, err := cache.(Verifier).Verify(ctx, VerifyOptions{})
+		if err != nil || len(report.Problems) != 0 {
+			t.Errorf("Expected no problems after cleanup, got %+v %v", report, err)
+		}
+
+		result, err = tenant.CleanupWithOptions(ctx, CleanupOptions{})
+		if err != nil || result.Orphans != 0 {
+			t.Errorf("Expected nothing left to remove, got %+v %v", result, err)
+		}
+	})
+}
+
+func TestCleanupOrphansFSBlob(t *testing.T) {
+	cache, err := NewFSBlobCache(&Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	c := cache.(*badgerCache)
+	for _, key := range []string{"ok", "missing"} {
+		if err := cache.Set(ctx, key, strings.NewReader("hello"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set %s: %v", key, err)
+		}
+	}
+	if err := os.Remove(c.blobPath("missing")); err != nil {
+		t.Fatalf("Failed to remove blob: %v", err)
+	}
+	// 没有FileInfo的文件可能正在写入，只有足够旧时才删除
+	old := time.Now().Add(-2 * verifyOrphanAge)
+	orphan := c.blobPath("orphan")
+	leftover := filepath.Join(c.blobs.tmpDir(), "blob-1")
+	recent := c.blobPath("recent")
+	for _, path := range []string{orphan, leftover, recent} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	for _, path := range []string{orphan, leftover} {
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatalf("Failed to change file times: %v", err)
+		}
+	}
+
+	result, err := cache.(IncrementalCleaner).CleanupWithOptions(ctx, CleanupOptions{})
+	if err != nil {
+		t.Fatalf("Failed to clean up: %v", err)
+	}
+	if result.Orphans != 3 {
+		t.Errorf("Expected 3 orphaned records and files to be removed, got %+v", result)
+	}
+	for path, exists := range map[string]bool{c.blobPath("ok"): true, orphan: false, leftover: false, recent: true} {
+		if _, err := os.Stat(path); (err == nil) != exists {
+			t.Errorf("Expected %s to exist: %v, got %v", path, exists, err)
+		}
+	}
+	if ok, _ := cache.Exists(ctx, "missing"); ok {
+		t.Error("Expected FileInfo without a blob to be removed")
+	}
+	stats, _ := cache.Stats()
+	if stats.TotalFiles != 1 || stats.TotalSize != 5 {
+		t.Errorf("Expected stats to be updated, got %d files %d bytes", stats.TotalFiles, stats.TotalSize)
+	}
+}
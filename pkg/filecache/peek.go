@@ -0,0 +1,16 @@
+package filecache
+
+import (
+	"context"
+	"io"
+)
+
+// Peek 获取文件，不更新访问统计；已过期但尚未被清理的文件也会返回
+func (c *badgerCache) Peek(ctx context.Context, key string) (io.ReadCloser, *FileInfo, error) {
+	return c.get(key, true, false)
+}
+
+// Peek 获取文件，不更新访问统计和LRU顺序；已过期但尚未被清理的文件也会返回
+func (c *memoryCache) Peek(ctx context.Context, key string) (io.ReadCloser, *FileInfo, error) {
+	return c.get(key, true, false)
+}
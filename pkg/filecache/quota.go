@@ -0,0 +1,77 @@
+package filecache
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// quota 返回当前命名空间的配额，按完整路径查找，不同父命名空间下的同名子命名空间互不影响
+func (c *badgerCache) quota() (int64, bool) {
+	quota, ok := c.config.Load().NamespaceQuotas[c.name]
+	return quota, ok && quota > 0
+}
+
+// ensureQuota 确保写入size字节后不超出配额
+// 先淘汰本命名空间中已过期的文件，再按最后访问时间从旧到新淘汰，仍放不下时返回QuotaError
+func (c *badgerCache) ensureQuota(ctx context.Context, key string, size, quota int64) error {
+	c.mu.RLock()
+	used := c.stats.TotalSize
+	c.mu.RUnlock()
+
+	// 覆盖写入时旧文件的空间可以复用
+	var oldSize int64
+	err := c.store.view(func(txn *badger.Txn) error {
+		var err error
+		oldSize, err = c.sizeInTxn(txn, key)
+		return err
+	})
+	if err != nil && err != badger.ErrKeyNotFound {
+		return err
+	}
+	used -= oldSize
+
+	if size > quota {
+		return &QuotaError{Namespace: c.name, Quota: quota, Used: used, Size: size}
+	}
+	if used+size <= quota {
+		return nil
+	}
+
+	files, err := c.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	sort.Slice(files, func(i, j int) bool {
+		return evictBefore(files[i], files[j], now)
+	})
+
+	for _, file := range files {
+		if used+size <= quota {
+			break
+		}
+		if file.Key == key {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := c.remove(file.Key); err != nil {
+			return err
+		}
+		used -= file.Size
+
+		c.mu.Lock()
+		c.stats.Evictions++
+		c.mu.Unlock()
+	}
+
+	if used+size > quota {
+		return &QuotaError{Namespace: c.name, Quota: quota, Used: used, Size: size}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package filecache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// minWriteBurstBytes 字节令牌桶的最小容量，也是一次等待令牌后读取的上限
+const minWriteBurstBytes = 64 << 10
+
+// WriteLimit 缓存写入的速率限制，用于避免批量预热或个别租户占满Badger的写入吞吐、拉高读取延迟
+type WriteLimit struct {
+	OpsPerSec    float64 // 每秒Set次数，0表示不限制
+	BytesPerSec  int64   // 每秒写入的字节数，0表示不限制
+	Burst        int     // 允许突发的Set次数，默认为一秒的次数，至少1
+	BurstBytes   int64   // 允许突发的字节数，默认为0.1秒的字节数，至少64KB
+	PerNamespace bool    // 每个命名空间使用独立的令牌桶，为false时所有命名空间共享
+}
+
+// validate 检查速率
+func (l *WriteLimit) validate() error {
+	if l.OpsPerSec < 0 || l.BytesPerSec < 0 || l.Burst < 0 || l.BurstBytes < 0 {
+		return fmt.Errorf("write limits cannot be negative")
+	}
+	return nil
+}
+
+// newLimiters 按速率创建令牌桶，不限制的一项为nil
+func (l *WriteLimit) newLimiters() (ops, bytes *rate.Limiter) {
+	if l.OpsPerSec > 0 {
+		burst := l.Burst
+		if burst == 0 {
+			burst = int(l.OpsPerSec)
+		}
+		if burst < 1 {
+			burst = 1
+		}
+		ops = rate.NewLimiter(rate.Limit(l.OpsPerSec), burst)
+	}
+	if l.BytesPerSec > 0 {
+		burst := l.BurstBytes
+		if burst == 0 {
+			burst = l.BytesPerSec / 10
+		}
+		if burst < minWriteBurstBytes {
+			burst = minWriteBurstBytes
+		}
+		bytes = rate.NewLimiter(rate.Limit(l.BytesPerSec), int(burst))
+	}
+	return ops, bytes
+}
+
+// rateLimitedCache 限制Set速率的缓存，其他操作直接交给底层缓存
+type rateLimitedCache struct {
+	Cache
+	limit WriteLimit
+	ops   *rate.Limiter
+	bytes *rate.Limiter
+
+	namespaces map[string]*rateLimitedCache
+	nsMu       sync.Mutex
+}
+
+// NewRateLimitedCache 返回限制cache写入速率的缓存，关闭时会同时关闭cache。
+// Set先等待次数令牌，再按读取data的进度等待字节令牌，ctx取消时返回；OpsPerSec和BytesPerSec都为0时直接返回cache
+func NewRateLimitedCache(cache Cache, limit WriteLimit) (Cache, error) {
+	if err := limit.validate(); err != nil {
+		return nil, err
+	}
+	if limit.OpsPerSec == 0 && limit.BytesPerSec == 0 {
+		return cache, nil
+	}
+	c := &rateLimitedCache{Cache: cache, limit: limit, namespaces: make(map[string]*rateLimitedCache)}
+	c.ops, c.bytes = limit.newLimiters()
+	return c, nil
+}
+
+// Set 取得令牌后写入，读取data的速度不超过BytesPerSec
+func (c *rateLimitedCache) Set(ctx context.Context, key string, data io.Reader, mimeType string, ttl time.Duration) error {
+	if c.ops != nil {
+		if err := c.ops.Wait(ctx); err != nil {
+			return fmt.Errorf("failed to wait for write limit: %w", err)
+		}
+	}
+	if c.bytes != nil {
+		data = &limitedWriteReader{ctx: ctx, r: data, limiter: c.bytes}
+	}
+	return c.Cache.Set(ctx, key, data, mimeType, ttl)
+}
+
+// Namespace 返回底层缓存同名命名空间的限速缓存，PerNamespace时使用独立的令牌桶
+func (c *rateLimitedCache) Namespace(name string) Cache {
+	if name == "" {
+		return c
+	}
+
+	c.nsMu.Lock()
+	defer c.nsMu.Unlock()
+
+	if ns, ok := c.namespaces[name]; ok {
+		return ns
+	}
+	ns := &rateLimitedCache{
+		Cache:      c.Cache.Namespace(name),
+		limit:      c.limit,
+		ops:        c.ops,
+		bytes:      c.bytes,
+		namespaces: make(map[string]*rateLimitedCache),
+	}
+	if c.limit.PerNamespace {
+		ns.ops, ns.bytes = c.limit.newLimiters()
+	}
+	c.namespaces[name] = ns
+	return ns
+}
+
+// Capabilities 返回底层缓存的能力
+func (c *rateLimitedCache) Capabilities() Capabilities {
+	return CapabilitiesOf(c.Cache)
+}
+
+// limitedWriteReader 每次读取后等待与读到的字节数相同的令牌
+type limitedWriteReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+// Read 读取不超过令牌桶容量的数据并等待令牌
+func (r *limitedWriteReader) Read(p []byte) (int, error) {
+	if burst := r.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.limiter.WaitN(r.ctx, n); werr != nil {
+			return n, fmt.Errorf("failed to wait for write limit: %w", werr)
+		}
+	}
+	return n, err
+}
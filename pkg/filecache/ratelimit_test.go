@@ -0,0 +1,125 @@
+package filecache
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimitedCache(t *testing.T) {
+	ctx := context.Background()
+
+	// setWithin 在timeout内写入key，返回错误
+	setWithin := func(cache Cache, key string, timeout time.Duration) error {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return cache.Set(ctx, key, strings.NewReader("data"), "text/plain", time.Hour)
+	}
+
+	t.Run("Ops", func(t *testing.T) {
+		inner := NewMemoryCache(1 << 20)
+		cache, err := NewRateLimitedCache(inner, WriteLimit{OpsPerSec: 20, Burst: 1})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		defer cache.Close()
+
+		start := time.Now()
+		for i := 0; i < 5; i++ {
+			if err := cache.Set(ctx, "file.txt", strings.NewReader("data"), "text/plain", time.Hour); err != nil {
+				t.Fatalf("Failed to set file: %v", err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+			t.Errorf("Expected 5 sets at 20/s to take at least 200ms, took %v", elapsed)
+		}
+		if got := readString(t, cache, "file.txt"); got != "data" {
+			t.Errorf("Expected reads to pass through, got '%s'", got)
+		}
+	})
+
+	t.Run("Bytes", func(t *testing.T) {
+		cache, err := NewRateLimitedCache(NewMemoryCache(1<<20), WriteLimit{BytesPerSec: 256 << 10, BurstBytes: 64 << 10})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		defer cache.Close()
+
+		data := bytes.Repeat([]byte("x"), 192<<10)
+		start := time.Now()
+		if err := cache.Set(ctx, "large.bin", bytes.NewReader(data), "application/octet-stream", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		// 64KB突发之后剩下的128KB按256KB/s写入
+		if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+			t.Errorf("Expected 192KB at 256KB/s to take at least 500ms, took %v", elapsed)
+		}
+		if got := readString(t, cache, "large.bin"); len(got) != len(data) {
+			t.Errorf("Expected %d bytes, got %d", len(data), len(got))
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		cache, err := NewRateLimitedCache(NewMemoryCache(1<<20), WriteLimit{OpsPerSec: 0.1, Burst: 1})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		defer cache.Close()
+
+		if err := setWithin(cache, "first.txt", time.Second); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		if err := setWithin(cache, "second.txt", 20*time.Millisecond); err == nil {
+			t.Error("Expected error when the context ends before a token is available")
+		}
+		if exists, _ := cache.Exists(ctx, "second.txt"); exists {
+			t.Error("Expected rejected write not to be stored")
+		}
+	})
+
+	t.Run("Namespaces", func(t *testing.T) {
+		shared, err := NewRateLimitedCache(NewMemoryCache(1<<20), WriteLimit{OpsPerSec: 0.1, Burst: 1})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		defer shared.Close()
+		if err := setWithin(shared.Namespace("site-a"), "a.txt", time.Second); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		if err := setWithin(shared.Namespace("site-b"), "b.txt", 20*time.Millisecond); err == nil {
+			t.Error("Expected namespaces to share the limit by default")
+		}
+
+		separate, err := NewRateLimitedCache(NewMemoryCache(1<<20), WriteLimit{OpsPerSec: 0.1, Burst: 1, PerNamespace: true})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		defer separate.Close()
+		if err := setWithin(separate.Namespace("site-a"), "a.txt", time.Second); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		if err := setWithin(separate.Namespace("site-b"), "b.txt", 20*time.Millisecond); err != nil {
+			t.Errorf("Expected each namespace to have its own limit, got %v", err)
+		}
+		if err := setWithin(separate.Namespace("site-a"), "a2.txt", 20*time.Millisecond); err == nil {
+			t.Error("Expected namespace to be limited")
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		inner := NewMemoryCache(1 << 20)
+		defer inner.Close()
+		if cache, err := NewRateLimitedCache(inner, WriteLimit{}); err != nil || cache != inner {
+			t.Errorf("Expected cache without limits to be returned as is, got %v", err)
+		}
+		if _, err := NewRateLimitedCache(inner, WriteLimit{BytesPerSec: -1}); err == nil {
+			t.Error("Expected error for negative rate")
+		}
+		limited, _ := NewRateLimitedCache(inner, WriteLimit{OpsPerSec: 1})
+		if CapabilitiesOf(limited) != CapabilitiesOf(inner) {
+			t.Error("Expected capabilities of the underlying cache")
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package filecache
+
+import "fmt"
+
+// Reconfigurer 可以在运行中更新配置的缓存，已缓存的文件和内存中的数据都保留
+// 只应用可以安全修改的字段：DefaultTTL、TTLPolicies、TTLJitter、TTLJitterMax、MaxEntrySize、NamespaceQuotas、SoftDelete、TrashRetention和StaleRetention；
+// DataDir、MaxCacheSize、压缩、加密、备份和按水位淘汰等需要重新打开缓存的字段被忽略。
+// 配置由根缓存和所有命名空间共享，在任意命名空间上调用效果相同
+type Reconfigurer interface {
+	Reconfigure(config *Config) error
+}
+
+// Reconfigure 检查并替换可以在运行中修改的字段，新的设置对之后的写入、配额检查和清理生效
+func (c *badgerCache) Reconfigure(config *Config) error {
+	if config == nil {
+		return fmt.Errorf("config cannot be nil")
+	}
+	next := *c.config.Load()
+	next.DefaultTTL = config.DefaultTTL
+	next.TTLPolicies = append([]TTLPolicy(nil), config.TTLPolicies...)
+	next.TTLJitter = config.TTLJitter
+	next.TTLJitterMax = config.TTLJitterMax
+	next.MaxEntrySize = config.MaxEntrySize
+	next.SoftDelete = config.SoftDelete
+	next.TrashRetention = config.TrashRetention
+	next.StaleRetention = config.StaleRetention
+	next.NamespaceQuotas = nil
+	if config.NamespaceQuotas != nil {
+		next.NamespaceQuotas = make(map[string]int64, len(config.NamespaceQuotas))
+		for name, quota := range config.NamespaceQuotas {
+			next.NamespaceQuotas[name] = quota
+		}
+	}
+	if err := ValidateConfig(&next); err != nil {
+		return err
+	}
+	ttls, err := NewTTLPolicySet(next.TTLPolicies)
+	if err != nil {
+		return err
+	}
+	c.config.Store(&next)
+	c.ttls.Store(ttls)
+	return nil
+}
+
+// Reconfigure 按分片平均分配配额后更新每个分片
+func (c *shardedCache) Reconfigure(config *Config) error {
+	if err := ValidateConfig(config); err != nil {
+		return err
+	}
+	for i, shard := range c.shards {
+		r, ok := shard.(Reconfigurer)
+		if !ok {
+			continue
+		}
+		if err := r.Reconfigure(shardConfig(config, "", i, len(c.shards))); err != nil {
+			return fmt.Errorf("failed to reconfigure shard %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Reconfigure 只应用DefaultTTL、TTLPolicies和TTL抖动，内存缓存的容量在创建时确定
+func (c *memoryCache) Reconfigure(config *Config) error {
+	if config == nil {
+		return fmt.Errorf("config cannot be nil")
+	}
+	if config.DefaultTTL <= 0 {
+		return fmt.Errorf("default TTL must be positive")
+	}
+	ttls, err := NewTTLPolicySet(config.TTLPolicies)
+	if err != nil {
+		return err
+	}
+	if err := config.ttlJitter().validate(); err != nil {
+		return err
+	}
+	c.store.mu.Lock()
+	c.store.defaultTTL = config.DefaultTTL
+	c.store.ttls = ttls
+	c.store.jitter = config.ttlJitter()
+	c.store.mu.Unlock()
+	return nil
+}
+
+// Reconfigure 依次更新两级缓存中支持Reconfigurer的一层
+func (c *tieredCache) Reconfigure(config *Config) error {
+	return reconfigureAll(config, c.l1, c.l2)
+}
+
+// Reconfigure 依次更新链中支持Reconfigurer的每一层
+func (c *chainCache) Reconfigure(config *Config) error {
+	return reconfigureAll(config, c.tiers...)
+}
+
+// Reconfigure 更新底层缓存
+func (c *rateLimitedCache) Reconfigure(config *Config) error {
+	return reconfigureAll(config, c.Cache)
+}
+
+// reconfigureAll 更新caches中支持Reconfigurer的缓存，遇到错误时停止
+func reconfigureAll(config *Config, caches ...Cache) error {
+	for _, cache := range caches {
+		if r, ok := cache.(Reconfigurer); ok {
+			if err := r.Reconfigure(config); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package filecache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReconfigure(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("badger", func(t *testing.T) {
+		config := &Config{
+			DataDir:         t.TempDir(),
+			MaxCacheSize:    1024 * 1024,
+			DefaultTTL:      time.Hour,
+			CleanupInterval: time.Hour,
+		}
+		cache, err := NewBadgerCache(config)
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		defer cache.Close()
+		tenant := cache.Namespace("tenant")
+		if err := tenant.Set(ctx, "warm", strings.NewReader("kept"), "text/plain", 0); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+
+		next := *config
+		next.DefaultTTL = time.Minute
+		next.NamespaceQuotas = map[string]int64{"tenant": 10}
+		next.MaxCacheSize = 1 // 运行中不能修改，被忽略
+		if err := tenant.(Reconfigurer).Reconfigure(&next); err != nil {
+			t.Fatalf("Failed to reconfigure: %v", err)
+		}
+
+		start := time.Now()
+		if err := cache.Set(ctx, "fresh", strings.NewReader("x"), "text/plain", 0); err != nil {
+			t.Fatalf("Failed to set file after reconfigure: %v", err)
+		}
+		_, info, err := cache.Get(ctx, "fresh")
+		if err != nil {
+			t.Fatalf("Failed to get file: %v", err)
+		}
+		if ttl := info.ExpiresAt.Sub(start); ttl > 2*time.Minute {
+			t.Errorf("Expected new default TTL to apply, got %v", ttl)
+		}
+		if err := tenant.Set(ctx, "big", strings.NewReader(strings.Repeat("x", 20)), "text/plain", 0); !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("Expected new quota to apply, got %v", err)
+		}
+		if got := readString(t, tenant, "warm"); got != "kept" {
+			t.Errorf("Expected existing entries to survive, got %q", got)
+		}
+
+		invalid := next
+		invalid.DefaultTTL = 0
+		if err := cache.(Reconfigurer).Reconfigure(&invalid); err == nil {
+			t.Error("Expected invalid config to be rejected")
+		}
+		if err := tenant.Set(ctx, "big", strings.NewReader(strings.Repeat("x", 20)), "text/plain", 0); err == nil {
+			t.Error("Expected rejected config to leave the previous quota in place")
+		}
+	})
+
+	t.Run("sharded", func(t *testing.T) {
+		config := DefaultConfig()
+		cache, err := NewShardedCache([]string{t.TempDir(), t.TempDir()}, config)
+		if err != nil {
+			t.Fatalf("Failed to create sharded cache: %v", err)
+		}
+		defer cache.Close()
+		next := *config
+		next.NamespaceQuotas = map[string]int64{"tenant": 1000}
+		if err := cache.(Reconfigurer).Reconfigure(&next); err != nil {
+			t.Fatalf("Failed to reconfigure: %v", err)
+		}
+		for i, shard := range cache.(*shardedCache).shards {
+			if quota := shard.(*badgerCache).config.Load().NamespaceQuotas["tenant"]; quota != 500 {
+				t.Errorf("Expected shard %d quota of 500, got %d", i, quota)
+			}
+		}
+	})
+
+	t.Run("tiered", func(t *testing.T) {
+		l1 := NewMemoryCache(1024)
+		cache := NewTieredCache(l1, newTestBadgerCache(t))
+		defer cache.Close()
+		config := DefaultConfig()
+		config.DefaultTTL = time.Minute
+		if err := cache.(Reconfigurer).Reconfigure(config); err != nil {
+			t.Fatalf("Failed to reconfigure: %v", err)
+		}
+		if ttl := l1.(*memoryCache).store.defaultTTL; ttl != time.Minute {
+			t.Errorf("Expected memory tier TTL to be updated, got %v", ttl)
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package filecache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"mime"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// sniffLen http.DetectContentType最多检查的字节数
+const sniffLen = 512
+
+// Repair 与Verify做相同的检查，但能够完整读取文件数据的条目按数据重建FileInfo，而不是删除；
+// 回收站中的条目和文件系统后端中没有FileInfo的文件（文件名是键的哈希，无法还原键）仍然删除
+func (c *badgerCache) Repair(ctx context.Context, opts RepairOptions) (*RepairReport, error) {
+	report, err := c.verify(ctx, VerifyOptions{Repair: !opts.DryRun}, &opts)
+	return newRepairReport(report, opts.DryRun), err
+}
+
+// Repair 依次修复所有分片，问题的描述前加上分片序号
+func (c *shardedCache) Repair(ctx context.Context, opts RepairOptions) (*RepairReport, error) {
+	report := &VerifyReport{}
+	for i, shard := range c.shards {
+		repairer, ok := shard.(Repairer)
+		if !ok {
+			return newRepairReport(report, opts.DryRun), fmt.Errorf("repair is not supported")
+		}
+		r, err := repairer.Repair(ctx, opts)
+		if r != nil {
+			report.addShard(i, &r.VerifyReport)
+		}
+		if err != nil {
+			return newRepairReport(report, opts.DryRun), fmt.Errorf("failed to repair shard %d: %w", i, err)
+		}
+	}
+	return newRepairReport(report, opts.DryRun), nil
+}
+
+// newRepairReport 按问题的修复方式统计修复结果，dryRun时统计将要执行的修复
+func newRepairReport(report *VerifyReport, dryRun bool) *RepairReport {
+	r := &RepairReport{VerifyReport: *report}
+	for _, p := range report.Problems {
+		if !p.Repaired && !dryRun {
+			continue
+		}
+		switch p.Action {
+		case RepairRebuild:
+			r.Rebuilt++
+		case RepairResize:
+			r.Resized++
+		case RepairRemove:
+			r.Removed++
+		}
+	}
+	return r
+}
+
+// readForRebuild 需要重建FileInfo时完整读取文件数据，数据缺失、无法读取或者是回收站中的条目时返回nil
+func (v *verifier) readForRebuild(txn *badger.Txn, key string, trash bool) *dataDigest {
+	if v.rebuild == nil || trash {
+		return nil
+	}
+	d := &dataDigest{hash: sha256.New()}
+	if _, err := v.readData(txn, key, trash, d); err != nil {
+		return nil
+	}
+	v.report.Bytes += d.size
+	return d
+}
+
+// rebuildInfo 返回按文件数据重建FileInfo的修复，counted为true时条目已经计入文件数（FileInfo存在但无法解析）；
+// 扫描之后被写入或删除的条目不重建，避免覆盖并发写入的FileInfo
+func (v *verifier) rebuildInfo(txn *badger.Txn, key string, d *dataDigest, counted bool) *verifyRepair {
+	infoKey, _, _ := v.c.entryKeys(key, false)
+	unchanged := v.unchanged(txn, key, false)
+	mimeType := d.mimeType(key)
+	ttl := v.rebuild.TTL
+	if ttl <= 0 && ttl != NoExpiry {
+		ttl = v.c.defaultTTL(key, mimeType)
+	}
+	repair := &verifyRepair{action: RepairRebuild, files: 1, size: d.size, apply: func() error {
+		now := time.Now()
+		data, err := json.Marshal(&FileInfo{
+			Key:        key,
+			Size:       d.size,
+			MimeType:   mimeType,
+			CreatedAt:  now,
+			ExpiresAt:  expiryFor(now, ttl),
+			LastAccess: now,
+		})
+		if err != nil {
+			return err
+		}
+		return v.c.store.update(func(txn *badger.Txn) error {
+			if err := unchanged(txn); err != nil {
+				return err
+			}
+			return txn.Set(infoKey, data)
+		})
+	}}
+	if counted {
+		repair.files = 0
+	}
+	return repair
+}
+
+// dataDigest 读取文件数据时记录大小、开头用于判断MIME类型的内容和SHA-256
+type dataDigest struct {
+	size int64
+	head []byte
+	hash hash.Hash
+}
+
+func (d *dataDigest) Write(p []byte) (int, error) {
+	if n := sniffLen - len(d.head); n > 0 {
+		if n > len(p) {
+			n = len(p)
+		}
+		d.head = append(d.head, p[:n]...)
+	}
+	d.size += int64(len(p))
+	return d.hash.Write(p)
+}
+
+// mimeType 按键的扩展名判断MIME类型，无法判断时检查数据开头的内容
+func (d *dataDigest) mimeType(key string) string {
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return http.DetectContentType(d.head)
+}
+
+// describe 返回报告中的数据描述
+func (d *dataDigest) describe(key string) string {
+	return fmt.Sprintf("data has %d bytes of %s, sha256 %x", d.size, d.mimeType(key), d.hash.Sum(nil))
+}
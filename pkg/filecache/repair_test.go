@@ -0,0 +1,238 @@
+package filecache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestRepair(t *testing.T) {
+	cache, err := NewBadgerCache(&Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+		SoftDelete:      true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	root := cache.(*badgerCache)
+	tenant := cache.Namespace("tenant").(*badgerCache)
+	for _, key := range []string{"ok", "missing", "resized", "corrupt.css", "trashed"} {
+		if err := root.Set(ctx, key, strings.NewReader("hello"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set %s: %v", key, err)
+		}
+	}
+	if err := root.Delete(ctx, "trashed"); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+
+	// 模拟崩溃留下的不一致记录
+	err = root.store.update(func(txn *badger.Txn) error {
+		if err := txn.Delete(root.dataKey("missing")); err != nil {
+			return err
+		}
+		if err := txn.Set(root.dataKey("resized"), []byte("hello, world")); err != nil {
+			return err
+		}
+		if err := txn.Set(root.infoKey("corrupt.css"), []byte("{")); err != nil {
+			return err
+		}
+		if err := txn.Set(root.dataKey("orphan"), []byte("<html><body>orphan</body></html>")); err != nil {
+			return err
+		}
+		if err := txn.Delete(root.trashInfoKey("trashed")); err != nil {
+			return err
+		}
+		return txn.Set(tenant.dataKey("lost"), []byte("lost"))
+	})
+	if err != nil {
+		t.Fatalf("Failed to damage cache: %v", err)
+	}
+
+	want := []string{
+		"/:stats_mismatch",
+		"/corrupt.css:corrupt_info",
+		"/missing:missing_data",
+		"/orphan:orphaned_data",
+		"/resized:size_mismatch",
+		"/trashed:orphaned_data",
+		"tenant/lost:orphaned_data",
+	}
+
+	t.Run("DryRun", func(t *testing.T) {
+		report, err := cache.(Repairer).Repair(ctx, RepairOptions{DryRun: true})
+		if err != nil {
+			t.Fatalf("Failed to repair: %v", err)
+		}
+		if got := problemKinds(&report.VerifyReport); strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Expected problems %v, got %v", want, got)
+		}
+		if report.Unrepaired() != len(want) || report.Rebuilt != 3 || report.Resized != 1 || report.Removed != 2 {
+			t.Errorf("Expected repairs to be planned only, got %+v", report)
+		}
+		if ok, _ := cache.Exists(ctx, "orphan"); ok {
+			t.Error("Expected a dry run to keep the cache unchanged")
+		}
+	})
+
+	t.Run("Repair", func(t *testing.T) {
+		report, err := cache.(Repairer).Repair(ctx, RepairOptions{TTL: NoExpiry})
+		if err != nil {
+			t.Fatalf("Failed to repair: %v", err)
+		}
+		if report.Unrepaired() != 0 || report.Rebuilt != 3 || report.Resized != 1 || report.Removed != 2 {
+			t.Errorf("Expected all problems to be repaired, got %+v", report)
+		}
+		for _, p := range report.Problems {
+			if p.Key == "orphan" && (p.Action != RepairRebuild || !strings.Contains(p.Detail, "32 bytes of text/html")) {
+				t.Errorf("Expected the orphan to be rebuilt from its data, got %+v", p)
+			}
+		}
+
+		reader, info, err := cache.Get(ctx, "orphan")
+		if err != nil {
+			t.Fatalf("Expected the orphan to be readable, got %v", err)
+		}
+		data, _ := io.ReadAll(reader)
+		reader.Close()
+		if string(data) != "<html><body>orphan</body></html>" || info.Size != 32 || !strings.HasPrefix(info.MimeType, "text/html") || info.ExpiresAt != neverExpires {
+			t.Errorf("Expected FileInfo to be rebuilt from the data, got %+v", info)
+		}
+		if info, err := cache.GetInfo(ctx, "corrupt.css"); err != nil || info.Size != 5 || !strings.HasPrefix(info.MimeType, "text/css") {
+			t.Errorf("Expected FileInfo to be rebuilt by the extension, got %+v %v", info, err)
+		}
+		if info, err := tenant.GetInfo(ctx, "lost"); err != nil || info.Size != 4 {
+			t.Errorf("Expected FileInfo to be rebuilt in the namespace, got %+v %v", info, err)
+		}
+		if ok, _ := cache.Exists(ctx, "missing"); ok {
+			t.Error("Expected FileInfo without data to be removed")
+		}
+		stats, _ := cache.Stats()
+		if stats.TotalFiles != 4 || stats.TotalSize != 54 {
+			t.Errorf("Expected stats to be recomputed, got %d files %d bytes", stats.TotalFiles, stats.TotalSize)
+		}
+
+		report, err = cache.(Repairer).Repair(ctx, RepairOptions{})
+		if err != nil || len(report.Problems) != 0 {
+			t.Errorf("Expected no problems after repair, got %+v %v", report, err)
+		}
+	})
+}
+
+func TestRepairFSBlob(t *testing.T) {
+	cache, err := NewFSBlobCache(&Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	c := cache.(*badgerCache)
+	for _, key := range []string{"corrupt", "missing"} {
+		if err := cache.Set(ctx, key, strings.NewReader("hello"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set %s: %v", key, err)
+		}
+	}
+	if err := os.Remove(c.blobPath("missing")); err != nil {
+		t.Fatalf("Failed to remove blob: %v", err)
+	}
+	err = c.store.update(func(txn *badger.Txn) error {
+		return txn.Set(c.infoKey("corrupt"), []byte("{"))
+	})
+	if err != nil {
+		t.Fatalf("Failed to damage cache: %v", err)
+	}
+
+	report, err := cache.(Repairer).Repair(ctx, RepairOptions{})
+	if err != nil {
+		t.Fatalf("Failed to repair: %v", err)
+	}
+	if report.Unrepaired() != 0 || report.Rebuilt != 1 || report.Removed != 1 {
+		t.Errorf("Expected the readable blob to be rebuilt, got %+v", report)
+	}
+	if info, err := cache.GetInfo(ctx, "corrupt"); err != nil || info.Size != 5 || time.Until(info.ExpiresAt) <= 0 {
+		t.Errorf("Expected FileInfo to be rebuilt with the default TTL, got %+v %v", info, err)
+	}
+	stats, _ := cache.Stats()
+	if stats.TotalFiles != 1 || stats.TotalSize != 5 {
+		t.Errorf("Expected stats to be recomputed, got %d files %d bytes", stats.TotalFiles, stats.TotalSize)
+	}
+}
+
+func TestRepairSkipsChangedEntries(t *testing.T) {
+	cache, err := NewBadgerCache(&Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	c := cache.(*badgerCache)
+	if err := c.Set(ctx, "missing", strings.NewReader("hello"), "text/plain", time.Hour); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+	err = c.store.update(func(txn *badger.Txn) error {
+		if err := txn.Delete(c.dataKey("missing")); err != nil {
+			return err
+		}
+		return txn.Set(c.dataKey("orphan"), []byte("orphan"))
+	})
+	if err != nil {
+		t.Fatalf("Failed to damage cache: %v", err)
+	}
+
+	// 扫描时计划的修复，在执行之前两个条目都被重新写入
+	v := &verifier{c: c, report: &VerifyReport{}, rebuild: &RepairOptions{}}
+	var repairs []*verifyRepair
+	err = c.store.view(func(txn *badger.Txn) error {
+		d := v.readForRebuild(txn, "orphan", false)
+		if d == nil {
+			t.Fatal("Expected the orphaned data to be readable")
+		}
+		repairs = append(repairs, v.rebuildInfo(txn, "orphan", d, false), v.removeEntry(txn, "missing", false, 5))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to scan: %v", err)
+	}
+	for _, key := range []string{"orphan", "missing"} {
+		if err := c.Set(ctx, key, strings.NewReader("rewritten"), "application/json", time.Hour); err != nil {
+			t.Fatalf("Failed to set %s: %v", key, err)
+		}
+	}
+
+	for _, r := range repairs {
+		if err := r.apply(); !errors.Is(err, errEntryChanged) {
+			t.Errorf("Expected the repair to be skipped, got %v", err)
+		}
+	}
+	for _, key := range []string{"orphan", "missing"} {
+		if info, err := c.GetInfo(ctx, key); err != nil || info.Size != 9 || info.MimeType != "application/json" {
+			t.Errorf("Expected the concurrent write to %s to be kept, got %+v %v", key, info, err)
+		}
+		if data := readString(t, c, key); data != "rewritten" {
+			t.Errorf("Expected the data of %s to be kept, got %q", key, data)
+		}
+	}
+}
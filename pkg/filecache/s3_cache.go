@@ -0,0 +1,343 @@
+package filecache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/internal/s3client"
+)
+
+const (
+	// S3对象元数据头
+	s3MetaCreatedAt = "X-Amz-Meta-Created-At"
+	s3MetaExpiresAt = "X-Amz-Meta-Expires-At"
+
+	// s3HeadConcurrency List时并发获取对象元数据的请求数
+	s3HeadConcurrency = 8
+)
+
+// S3Config S3缓存配置
+type S3Config struct {
+	Endpoint        string        `json:"endpoint"`          // 服务地址，例如 https://s3.us-east-1.amazonaws.com 或 http://minio:9000
+	Region          string        `json:"region"`            // 区域，默认us-east-1
+	Bucket          string        `json:"bucket"`            // 存储桶
+	AccessKeyID     string        `json:"access_key_id"`     // 访问密钥ID
+	SecretAccessKey string        `json:"secret_access_key"` // 访问密钥
+	SessionToken    string        `json:"session_token"`     // 临时凭证的会话令牌
+	PathStyle       bool          `json:"path_style"`        // 使用路径风格访问，MinIO等通常需要开启
+	Prefix          string        `json:"prefix"`            // 对象键前缀，多个缓存共用存储桶时使用
+	DefaultTTL      time.Duration `json:"default_ttl"`       // 默认TTL
+	MaxEntrySize    int64         `json:"max_entry_size"`    // 单个文件最大大小（字节），0表示不限制
+	HTTPClient      *http.Client  `json:"-"`                 // 自定义HTTP客户端
+}
+
+// s3Cache S3兼容对象存储文件缓存实现
+// 文件数据存放在 <Prefix>data/<key>，FileInfo存放在对象元数据中；过期采用惰性检查，
+// Cleanup负责删除过期对象，也可以在存储桶上配置生命周期规则作为兜底
+type s3Cache struct {
+	client *s3client.Client
+	cfg    S3Config
+	prefix string // 当前命名空间的对象键前缀
+
+	stats objectStats
+
+	namespaces map[string]*s3Cache
+	nsMu       sync.Mutex
+}
+
+// NewS3Cache 创建基于S3兼容对象存储的文件缓存
+func NewS3Cache(cfg S3Config) (Cache, error) {
+	client, err := s3client.New(s3client.Config{
+		Endpoint:        cfg.Endpoint,
+		Region:          cfg.Region,
+		Bucket:          cfg.Bucket,
+		AccessKeyID:     cfg.AccessKeyID,
+		SecretAccessKey: cfg.SecretAccessKey,
+		SessionToken:    cfg.SessionToken,
+		PathStyle:       cfg.PathStyle,
+		HTTPClient:      cfg.HTTPClient,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cfg.DefaultTTL <= 0 {
+		cfg.DefaultTTL = DefaultConfig().DefaultTTL
+	}
+
+	return &s3Cache{
+		client:     client,
+		cfg:        cfg,
+		prefix:     cfg.Prefix,
+		namespaces: make(map[string]*s3Cache),
+	}, nil
+}
+
+// objectKey 返回缓存键对应的对象键
+func (c *s3Cache) objectKey(key string) string {
+	return c.dataPrefix() + key
+}
+
+// dataPrefix 返回当前命名空间文件数据的对象键前缀
+func (c *s3Cache) dataPrefix() string {
+	return c.prefix + "data/"
+}
+
+// Set 存储文件到缓存
+func (c *s3Cache) Set(ctx context.Context, key string, data io.Reader, mimeType string, ttl time.Duration) error {
+	if ttl <= 0 && ttl != NoExpiry {
+		ttl = c.cfg.DefaultTTL
+	}
+
+	body, size, err := spool(data, c.cfg.MaxEntrySize)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	now := time.Now()
+	header := http.Header{}
+	if mimeType != "" {
+		header.Set("Content-Type", mimeType)
+	}
+	header.Set(s3MetaCreatedAt, now.UTC().Format(time.RFC3339Nano))
+	header.Set(s3MetaExpiresAt, expiryFor(now, ttl).UTC().Format(time.RFC3339Nano))
+
+	// 覆盖写入时统计信息只加上大小之差；获取失败时按新文件计算，下次List时按实际对象刷新
+	old, _ := c.GetInfo(ctx, key)
+	if err := c.client.PutObject(ctx, c.objectKey(key), body, size, header); err != nil {
+		return fmt.Errorf("failed to store file: %w", err)
+	}
+
+	c.stats.added(size, old)
+	return nil
+}
+
+// Get 从缓存获取文件
+func (c *s3Cache) Get(ctx context.Context, key string) (io.ReadCloser, *FileInfo, error) {
+	resp, err := c.client.GetObject(ctx, c.objectKey(key), nil)
+	if errors.Is(err, s3client.ErrNotFound) {
+		c.stats.miss()
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info := s3FileInfo(key, resp.Header)
+	if time.Now().After(info.ExpiresAt) {
+		resp.Body.Close()
+		return nil, nil, ErrExpired
+	}
+
+	c.stats.hit()
+	return resp.Body, info, nil
+}
+
+// Exists 检查文件是否存在
+func (c *s3Cache) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := c.client.HeadObject(ctx, c.objectKey(key))
+	if errors.Is(err, s3client.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete 删除文件，S3删除不存在的对象也会成功，先获取对象大小用于更新统计信息
+func (c *s3Cache) Delete(ctx context.Context, key string) error {
+	old, _ := c.GetInfo(ctx, key)
+	if err := c.client.DeleteObject(ctx, c.objectKey(key)); err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	if old != nil {
+		c.stats.removed(old)
+	}
+	return nil
+}
+
+// List 列出当前命名空间的所有缓存文件，需要为每个对象单独获取元数据
+func (c *s3Cache) List(ctx context.Context) ([]*FileInfo, error) {
+	objects, err := c.listObjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*FileInfo, len(objects))
+	errs := make([]error, len(objects))
+	sem := make(chan struct{}, s3HeadConcurrency)
+	var wg sync.WaitGroup
+
+	for i, obj := range objects {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, obj s3client.Object) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			key := obj.Key[len(c.dataPrefix()):]
+			header, err := c.client.HeadObject(ctx, obj.Key)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			info := s3FileInfo(key, header)
+			info.Size = obj.Size
+			files[i] = info
+		}(i, obj)
+	}
+	wg.Wait()
+
+	var result []*FileInfo
+	for i, info := range files {
+		if errors.Is(errs[i], s3client.ErrNotFound) {
+			// 列出后被删除
+			continue
+		}
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		result = append(result, info)
+	}
+
+	c.stats.refresh(result)
+	return result, nil
+}
+
+// GetInfo 获取文件信息
+func (c *s3Cache) GetInfo(ctx context.Context, key string) (*FileInfo, error) {
+	header, err := c.client.HeadObject(ctx, c.objectKey(key))
+	if errors.Is(err, s3client.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s3FileInfo(key, header), nil
+}
+
+// Cleanup 清理当前命名空间的过期文件
+func (c *s3Cache) Cleanup(ctx context.Context) error {
+	now := time.Now()
+	files, err := c.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	var expired []string
+	var kept []*FileInfo
+	for _, file := range files {
+		if now.After(file.ExpiresAt) {
+			expired = append(expired, c.objectKey(file.Key))
+		} else {
+			kept = append(kept, file)
+		}
+	}
+	if err := c.client.DeleteObjects(ctx, expired); err != nil {
+		return fmt.Errorf("failed to delete expired files: %w", err)
+	}
+
+	c.stats.refresh(kept)
+	c.stats.cleaned(int64(len(expired)), now)
+	return nil
+}
+
+// Flush 删除当前命名空间的所有文件并重置统计信息
+func (c *s3Cache) Flush(ctx context.Context) error {
+	objects, err := c.listObjects(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, len(objects))
+	for i, obj := range objects {
+		keys[i] = obj.Key
+	}
+	if err := c.client.DeleteObjects(ctx, keys); err != nil {
+		return fmt.Errorf("failed to flush cache: %w", err)
+	}
+
+	c.stats.reset()
+	return nil
+}
+
+// Namespace 返回对象键前缀为 <prefix>ns/<name>/ 的子缓存
+func (c *s3Cache) Namespace(name string) Cache {
+	if name == "" {
+		return c
+	}
+
+	c.nsMu.Lock()
+	defer c.nsMu.Unlock()
+
+	if ns, ok := c.namespaces[name]; ok {
+		return ns
+	}
+	ns := &s3Cache{
+		client:     c.client,
+		cfg:        c.cfg,
+		prefix:     c.prefix + "ns/" + url.PathEscape(name) + "/",
+		namespaces: make(map[string]*s3Cache),
+	}
+	c.namespaces[name] = ns
+	return ns
+}
+
+// Close 关闭缓存
+func (c *s3Cache) Close() error {
+	return nil
+}
+
+// Stats 获取缓存统计信息
+func (c *s3Cache) Stats() (*Stats, error) {
+	return c.stats.snapshot(), nil
+}
+
+// listObjects 分页列出当前命名空间的所有数据对象
+func (c *s3Cache) listObjects(ctx context.Context) ([]s3client.Object, error) {
+	var objects []s3client.Object
+	token := ""
+	for {
+		result, err := c.client.ListObjectsV2(ctx, c.dataPrefix(), token, 1000)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+		objects = append(objects, result.Objects...)
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// s3FileInfo 从对象元数据解析文件信息
+func s3FileInfo(key string, header http.Header) *FileInfo {
+	info := &FileInfo{
+		Key:      key,
+		MimeType: header.Get("Content-Type"),
+	}
+	if size, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil {
+		info.Size = size
+	}
+	if t, err := time.Parse(time.RFC3339Nano, header.Get(s3MetaCreatedAt)); err == nil {
+		info.CreatedAt = t
+	} else if t, err := http.ParseTime(header.Get("Last-Modified")); err == nil {
+		info.CreatedAt = t
+	}
+	if t, err := time.Parse(time.RFC3339Nano, header.Get(s3MetaExpiresAt)); err == nil {
+		info.ExpiresAt = t
+	} else {
+		// 没有过期时间的对象视为永不过期，由存储桶生命周期规则管理
+		info.ExpiresAt = neverExpires
+	}
+	info.LastAccess = info.CreatedAt
+	return info
+}
@@ -0,0 +1,194 @@
+package filecache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/internal/s3test"
+)
+
+func TestS3Cache(t *testing.T) {
+	server := s3test.NewServer("edge-cache")
+	defer server.Close()
+
+	cache, err := NewS3Cache(S3Config{
+		Endpoint:        server.URL,
+		Bucket:          "edge-cache",
+		AccessKeyID:     "test",
+		SecretAccessKey: "secret",
+		PathStyle:       true,
+		Prefix:          "edge/",
+		DefaultTTL:      time.Hour,
+		MaxEntrySize:    1024,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+
+	t.Run("SetAndGet", func(t *testing.T) {
+		if err := cache.Set(ctx, "img/logo 1.png", strings.NewReader("png data"), "image/png", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+
+		if got := readString(t, cache, "img/logo 1.png"); got != "png data" {
+			t.Errorf("Expected 'png data', got %q", got)
+		}
+
+		info, err := cache.GetInfo(ctx, "img/logo 1.png")
+		if err != nil {
+			t.Fatalf("Failed to get info: %v", err)
+		}
+		if info.MimeType != "image/png" || info.Size != 8 {
+			t.Errorf("Unexpected info: %+v", info)
+		}
+		if time.Until(info.ExpiresAt) < 59*time.Minute {
+			t.Errorf("Unexpected expiry: %v", info.ExpiresAt)
+		}
+	})
+
+	t.Run("ObjectLayout", func(t *testing.T) {
+		keys := server.Keys("edge-cache")
+		if len(keys) != 1 || keys[0] != "edge/data/img/logo 1.png" {
+			t.Errorf("Unexpected object keys: %v", keys)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		if _, _, err := cache.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+		if _, err := cache.GetInfo(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+		exists, err := cache.Exists(ctx, "missing")
+		if err != nil || exists {
+			t.Errorf("Expected missing file, got %v, %v", exists, err)
+		}
+	})
+
+	t.Run("MaxEntrySize", func(t *testing.T) {
+		err := cache.Set(ctx, "huge", strings.NewReader(strings.Repeat("x", 1025)), "text/plain", time.Hour)
+		if !errors.Is(err, ErrEntryTooLarge) {
+			t.Errorf("Expected ErrEntryTooLarge, got %v", err)
+		}
+	})
+
+	t.Run("ExpiryAndCleanup", func(t *testing.T) {
+		if err := cache.Set(ctx, "short", strings.NewReader("data"), "text/plain", 50*time.Millisecond); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+
+		if _, _, err := cache.Get(ctx, "short"); !errors.Is(err, ErrExpired) {
+			t.Errorf("Expected ErrExpired, got %v", err)
+		}
+		if err := cache.Cleanup(ctx); err != nil {
+			t.Fatalf("Failed to cleanup: %v", err)
+		}
+		if exists, _ := cache.Exists(ctx, "short"); exists {
+			t.Error("Expected expired file to be removed")
+		}
+		if exists, _ := cache.Exists(ctx, "img/logo 1.png"); !exists {
+			t.Error("Expected valid file to be kept")
+		}
+	})
+
+	t.Run("Namespace", func(t *testing.T) {
+		ns := cache.Namespace("site-a")
+		if err := ns.Set(ctx, "img/logo 1.png", strings.NewReader("site a"), "image/png", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		if got := readString(t, ns, "img/logo 1.png"); got != "site a" {
+			t.Errorf("Expected 'site a', got %q", got)
+		}
+		if got := readString(t, cache, "img/logo 1.png"); got != "png data" {
+			t.Errorf("Expected 'png data', got %q", got)
+		}
+
+		files, err := ns.List(ctx)
+		if err != nil {
+			t.Fatalf("Failed to list files: %v", err)
+		}
+		if len(files) != 1 {
+			t.Errorf("Expected 1 file in namespace, got %d", len(files))
+		}
+
+		// 清空命名空间不影响根缓存
+		if err := ns.Flush(ctx); err != nil {
+			t.Fatalf("Failed to flush namespace: %v", err)
+		}
+		if exists, _ := ns.Exists(ctx, "img/logo 1.png"); exists {
+			t.Error("Expected namespace to be empty")
+		}
+		if exists, _ := cache.Exists(ctx, "img/logo 1.png"); !exists {
+			t.Error("Expected root file to be kept")
+		}
+	})
+
+	t.Run("DeleteAndStats", func(t *testing.T) {
+		if err := cache.Delete(ctx, "img/logo 1.png"); err != nil {
+			t.Fatalf("Failed to delete file: %v", err)
+		}
+		if exists, _ := cache.Exists(ctx, "img/logo 1.png"); exists {
+			t.Error("Expected file to be deleted")
+		}
+		if _, err := cache.List(ctx); err != nil {
+			t.Fatalf("Failed to list files: %v", err)
+		}
+
+		stats, err := cache.Stats()
+		if err != nil {
+			t.Fatalf("Failed to get stats: %v", err)
+		}
+		if stats.TotalFiles != 0 {
+			t.Errorf("Expected 0 files, got %d", stats.TotalFiles)
+		}
+		if stats.HitRate <= 0 {
+			t.Errorf("Expected positive hit rate, got %f", stats.HitRate)
+		}
+	})
+}
+
+func TestS3CacheStats(t *testing.T) {
+	server := s3test.NewServer("edge-cache")
+	defer server.Close()
+
+	cache, err := NewS3Cache(S3Config{
+		Endpoint:        server.URL,
+		Bucket:          "edge-cache",
+		AccessKeyID:     "test",
+		SecretAccessKey: "secret",
+		PathStyle:       true,
+		DefaultTTL:      time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	for _, e := range []struct{ key, data string }{{"a", "png data"}, {"a", "png"}, {"b", "hello"}} {
+		if err := cache.Set(ctx, e.key, strings.NewReader(e.data), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set %s: %v", e.key, err)
+		}
+	}
+	if stats, _ := cache.Stats(); stats.TotalFiles != 2 || stats.TotalSize != 8 {
+		t.Errorf("Expected overwrites to be counted by the size difference, got %d files %d bytes", stats.TotalFiles, stats.TotalSize)
+	}
+
+	// 删除不存在的对象不改变统计信息
+	for _, key := range []string{"a", "a"} {
+		if err := cache.Delete(ctx, key); err != nil {
+			t.Fatalf("Failed to delete %s: %v", key, err)
+		}
+	}
+	if stats, _ := cache.Stats(); stats.TotalFiles != 1 || stats.TotalSize != 5 {
+		t.Errorf("Expected deletes to be subtracted, got %d files %d bytes", stats.TotalFiles, stats.TotalSize)
+	}
+}
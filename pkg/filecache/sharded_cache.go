@@ -0,0 +1,381 @@
+package filecache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// shardMarkerFile 记录分片序号的文件，防止目录顺序或分片数变化后键被路由到错误的分片
+const shardMarkerFile = "shard.json"
+
+// shardMarker 分片标记
+type shardMarker struct {
+	Index int `json:"index"` // 分片序号
+	Count int `json:"count"` // 分片总数
+}
+
+// shardedCache 按键哈希把文件分布到多个Badger实例的缓存，每个实例通常位于不同的磁盘
+// 各分片独立压缩和清理，单键操作只访问一个分片，List、Cleanup、Stats汇总所有分片
+type shardedCache struct {
+	shards []Cache
+
+	mu           sync.RWMutex // 保护命中统计
+	hits, misses int64
+
+	root       bool // 根缓存关闭时关闭所有分片
+	namespaces map[string]*shardedCache
+	nsMu       sync.Mutex
+}
+
+// NewShardedCache 在dirs中的每个目录上创建一个Badger分片，键按哈希选择分片
+// config.DataDir被忽略；MaxCacheSize和NamespaceQuotas为所有分片合计，平均分配给各分片；
+// 配置了备份时每个分片备份到目标位置下的 shard-<序号>/ 中
+// 目录中会写入分片标记，之后必须以相同的目录顺序和数量打开
+func NewShardedCache(dirs []string, config *Config) (Cache, error) {
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("sharded cache requires at least one directory")
+	}
+	if config == nil {
+		config = DefaultConfig()
+	}
+
+	c := &shardedCache{
+		root:       true,
+		namespaces: make(map[string]*shardedCache),
+	}
+	for i, dir := range dirs {
+		shard, err := openShard(dir, i, len(dirs), config)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("failed to open shard %d: %w", i, err)
+		}
+		c.shards = append(c.shards, shard)
+	}
+	return c, nil
+}
+
+// openShard 检查分片标记并打开一个分片
+func openShard(dir string, index, count int, config *Config) (Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	want := shardMarker{Index: index, Count: count}
+	path := filepath.Join(dir, shardMarkerFile)
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		var got shardMarker
+		if err := json.Unmarshal(data, &got); err != nil {
+			return nil, fmt.Errorf("failed to parse shard marker: %w", err)
+		}
+		if got != want {
+			return nil, fmt.Errorf("directory %s belongs to shard %d of %d, expected shard %d of %d", dir, got.Index, got.Count, index, count)
+		}
+	case os.IsNotExist(err):
+		data, _ := json.Marshal(want)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write shard marker: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("failed to read shard marker: %w", err)
+	}
+
+	return NewBadgerCache(shardConfig(config, dir, index, count))
+}
+
+// shardConfig 返回第index个分片的配置，容量和配额平均分配
+func shardConfig(config *Config, dir string, index, count int) *Config {
+	shard := *config
+	shard.DataDir = dir
+	shard.MaxCacheSize = config.MaxCacheSize / int64(count)
+	if config.MaxEntrySize > shard.MaxCacheSize {
+		shard.MaxEntrySize = shard.MaxCacheSize
+	}
+	if config.NamespaceQuotas != nil {
+		shard.NamespaceQuotas = make(map[string]int64, len(config.NamespaceQuotas))
+		for name, quota := range config.NamespaceQuotas {
+			shard.NamespaceQuotas[name] = quota / int64(count)
+		}
+	}
+	if config.Backup != nil {
+		backup := *config.Backup
+		backup.Destination = fmt.Sprintf("%s/shard-%d/", strings.TrimRight(backup.Destination, "/"), index)
+		shard.Backup = &backup
+	}
+	return &shard
+}
+
+// shard 返回键所在的分片
+func (c *shardedCache) shard(key string) Cache {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return c.shards[h.Sum64()%uint64(len(c.shards))]
+}
+
+// Set 存储文件到缓存
+func (c *shardedCache) Set(ctx context.Context, key string, data io.Reader, mimeType string, ttl time.Duration) error {
+	return c.shard(key).Set(ctx, key, data, mimeType, ttl)
+}
+
+// Get 从缓存获取文件
+func (c *shardedCache) Get(ctx context.Context, key string) (io.ReadCloser, *FileInfo, error) {
+	reader, info, err := c.shard(key).Get(ctx, key)
+	if err != nil {
+		c.recordMiss()
+		return nil, nil, err
+	}
+	c.recordHit()
+	return reader, info, nil
+}
+
+// Exists 检查文件是否存在
+func (c *shardedCache) Exists(ctx context.Context, key string) (bool, error) {
+	return c.shard(key).Exists(ctx, key)
+}
+
+// Delete 删除文件
+func (c *shardedCache) Delete(ctx context.Context, key string) error {
+	return c.shard(key).Delete(ctx, key)
+}
+
+// GetInfo 获取文件信息
+func (c *shardedCache) GetInfo(ctx context.Context, key string) (*FileInfo, error) {
+	return c.shard(key).GetInfo(ctx, key)
+}
+
+// List 并行列出所有分片中的文件，结果按键排序
+func (c *shardedCache) List(ctx context.Context) ([]*FileInfo, error) {
+	lists := make([][]*FileInfo, len(c.shards))
+	err := c.each(func(i int, shard Cache) error {
+		files, err := shard.List(ctx)
+		lists[i] = files
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*FileInfo
+	for _, list := range lists {
+		files = append(files, list...)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Key < files[j].Key })
+	return files, nil
+}
+
+// Cleanup 并行清理所有分片
+func (c *shardedCache) Cleanup(ctx context.Context) error {
+	return c.each(func(_ int, shard Cache) error {
+		return shard.Cleanup(ctx)
+	})
+}
+
+// Flush 清空所有分片
+func (c *shardedCache) Flush(ctx context.Context) error {
+	err := c.each(func(_ int, shard Cache) error {
+		return shard.Flush(ctx)
+	})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.hits, c.misses = 0, 0
+	c.mu.Unlock()
+	return nil
+}
+
+// Namespace 返回由各分片的同名命名空间组成的分片缓存
+func (c *shardedCache) Namespace(name string) Cache {
+	if name == "" {
+		return c
+	}
+
+	c.nsMu.Lock()
+	defer c.nsMu.Unlock()
+
+	if ns, ok := c.namespaces[name]; ok {
+		return ns
+	}
+	ns := &shardedCache{namespaces: make(map[string]*shardedCache)}
+	for _, shard := range c.shards {
+		ns.shards = append(ns.shards, shard.Namespace(name))
+	}
+	c.namespaces[name] = ns
+	return ns
+}
+
+// Namespaces 返回各分片子命名空间的并集，按名称排序
+func (c *shardedCache) Namespaces(ctx context.Context) ([]string, error) {
+	seen := make(map[string]bool)
+	var names []string
+	for i, shard := range c.shards {
+		lister, ok := shard.(NamespaceLister)
+		if !ok {
+			return nil, fmt.Errorf("listing namespaces is not supported")
+		}
+		shardNames, err := lister.Namespaces(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list namespaces of shard %d: %w", i, err)
+		}
+		for _, name := range shardNames {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Close 关闭所有分片，命名空间的Close不做任何事
+func (c *shardedCache) Close() error {
+	if !c.root {
+		return nil
+	}
+
+	var firstErr error
+	for _, shard := range c.shards {
+		if err := shard.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Stats 汇总各分片的统计信息，命中率按分片缓存整体计算
+// LastCleanup取最近一次，LastBackup取最早一次（所有分片都完成备份的时间）
+func (c *shardedCache) Stats() (*Stats, error) {
+	all := make([]*Stats, len(c.shards))
+	err := c.each(func(i int, shard Cache) error {
+		stats, err := shard.Stats()
+		all[i] = stats
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{}
+	for i, s := range all {
+		stats.TotalFiles += s.TotalFiles
+		stats.TotalSize += s.TotalSize
+		stats.ExpiredFiles += s.ExpiredFiles
+		stats.Evictions += s.Evictions
+		if s.LastCleanup.After(stats.LastCleanup) {
+			stats.LastCleanup = s.LastCleanup
+		}
+		if i == 0 || s.LastBackup.Before(stats.LastBackup) {
+			stats.LastBackup = s.LastBackup
+		}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if total := float64(c.hits + c.misses); total > 0 {
+		stats.HitRate = float64(c.hits) / total
+		stats.MissRate = float64(c.misses) / total
+	}
+	return stats, nil
+}
+
+// Restore 从键所在分片的回收站恢复文件
+func (c *shardedCache) Restore(ctx context.Context, key string) error {
+	deleter, ok := c.shard(key).(SoftDeleter)
+	if !ok {
+		return fmt.Errorf("soft delete is not supported")
+	}
+	return deleter.Restore(ctx, key)
+}
+
+// ListTrash 列出所有分片回收站中的文件
+func (c *shardedCache) ListTrash(ctx context.Context) ([]*TrashInfo, error) {
+	lists := make([][]*TrashInfo, len(c.shards))
+	err := c.each(func(i int, shard Cache) error {
+		deleter, ok := shard.(SoftDeleter)
+		if !ok {
+			return fmt.Errorf("soft delete is not supported")
+		}
+		trash, err := deleter.ListTrash(ctx)
+		lists[i] = trash
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var trash []*TrashInfo
+	for _, list := range lists {
+		trash = append(trash, list...)
+	}
+	sort.Slice(trash, func(i, j int) bool { return trash[i].Key < trash[j].Key })
+	return trash, nil
+}
+
+// RotateEncryptionKey 依次轮换所有分片的加密密钥
+func (c *shardedCache) RotateEncryptionKey(ctx context.Context, newKey string) error {
+	for i, shard := range c.shards {
+		rotator, ok := shard.(KeyRotator)
+		if !ok {
+			return fmt.Errorf("key rotation is not supported")
+		}
+		if err := rotator.RotateEncryptionKey(ctx, newKey); err != nil {
+			return fmt.Errorf("failed to rotate key of shard %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Capabilities 返回分片缓存的能力，与单个分片相同
+func (c *shardedCache) Capabilities() Capabilities {
+	return CapabilitiesOf(c.shards[0])
+}
+
+// each 并行对每个分片执行fn，返回第一个错误
+func (c *shardedCache) each(fn func(i int, shard Cache) error) error {
+	errs := make([]error, len(c.shards))
+	var wg sync.WaitGroup
+	for i, shard := range c.shards {
+		wg.Add(1)
+		go func(i int, shard Cache) {
+			defer wg.Done()
+			errs[i] = fn(i, shard)
+		}(i, shard)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordHit 记录一次命中
+func (c *shardedCache) recordHit() {
+	c.mu.Lock()
+	c.hits++
+	c.mu.Unlock()
+}
+
+// recordMiss 记录一次未命中
+func (c *shardedCache) recordMiss() {
+	c.mu.Lock()
+	c.misses++
+	c.mu.Unlock()
+}
@@ -0,0 +1,151 @@
+package filecache
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShardedCache(t *testing.T) {
+	ctx := context.Background()
+
+	base := t.TempDir()
+	dirs := []string{filepath.Join(base, "nvme0"), filepath.Join(base, "nvme1"), filepath.Join(base, "nvme2")}
+	config := &Config{
+		MaxCacheSize:    3 * 1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+	}
+
+	cache, err := NewShardedCache(dirs, config)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	keys := make([]string, 30)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("file-%02d", i)
+		if err := cache.Set(ctx, keys[i], strings.NewReader(keys[i]), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+	}
+
+	t.Run("Distribution", func(t *testing.T) {
+		for i, shard := range cache.(*shardedCache).shards {
+			files, err := shard.List(ctx)
+			if err != nil {
+				t.Fatalf("Failed to list shard: %v", err)
+			}
+			if len(files) == 0 || len(files) == len(keys) {
+				t.Errorf("Expected keys to be spread across shards, shard %d has %d", i, len(files))
+			}
+		}
+	})
+
+	t.Run("GetAndDelete", func(t *testing.T) {
+		if got := readString(t, cache, "file-07"); got != "file-07" {
+			t.Errorf("Expected 'file-07', got %q", got)
+		}
+		if err := cache.Delete(ctx, "file-07"); err != nil {
+			t.Fatalf("Failed to delete file: %v", err)
+		}
+		if exists, _ := cache.Exists(ctx, "file-07"); exists {
+			t.Error("Expected file to be deleted")
+		}
+	})
+
+	t.Run("DiskUsage", func(t *testing.T) {
+		usage, err := cache.(DiskUsageReporter).DiskUsage()
+		if err != nil {
+			t.Fatalf("Failed to get disk usage: %v", err)
+		}
+		shard, _ := cache.(*shardedCache).shards[0].(DiskUsageReporter).DiskUsage()
+		if runtime.GOOS == "linux" && (shard.DiskTotal == 0 || usage.DiskTotal != 3*shard.DiskTotal) {
+			t.Errorf("Expected disk capacity of all shards, got %+v for shard %+v", usage, shard)
+		}
+	})
+
+	t.Run("ListAndStats", func(t *testing.T) {
+		files, err := cache.List(ctx)
+		if err != nil {
+			t.Fatalf("Failed to list files: %v", err)
+		}
+		if len(files) != len(keys)-1 || files[0].Key != "file-00" {
+			t.Errorf("Expected %d sorted files, got %d", len(keys)-1, len(files))
+		}
+
+		stats, err := cache.Stats()
+		if err != nil {
+			t.Fatalf("Failed to get stats: %v", err)
+		}
+		if stats.TotalFiles != int64(len(keys)-1) || stats.TotalSize != int64(7*(len(keys)-1)) {
+			t.Errorf("Unexpected aggregated stats: %+v", stats)
+		}
+		if stats.HitRate != 1 {
+			t.Errorf("Expected hit rate 1, got %f", stats.HitRate)
+		}
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		if err := cache.Set(ctx, "short", strings.NewReader("x"), "text/plain", 10*time.Millisecond); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+		if err := cache.Cleanup(ctx); err != nil {
+			t.Fatalf("Failed to cleanup: %v", err)
+		}
+		stats, err := cache.Stats()
+		if err != nil {
+			t.Fatalf("Failed to get stats: %v", err)
+		}
+		if stats.ExpiredFiles != 1 || stats.LastCleanup.IsZero() {
+			t.Errorf("Unexpected stats after cleanup: %+v", stats)
+		}
+	})
+
+	t.Run("Namespace", func(t *testing.T) {
+		ns := cache.Namespace("site-a")
+		if err := ns.Set(ctx, "file-00", strings.NewReader("ns"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		files, err := ns.List(ctx)
+		if err != nil {
+			t.Fatalf("Failed to list files: %v", err)
+		}
+		if len(files) != 1 {
+			t.Errorf("Expected 1 file in namespace, got %d", len(files))
+		}
+		if got := readString(t, cache, "file-00"); got != "file-00" {
+			t.Errorf("Expected root file to be unaffected, got %q", got)
+		}
+	})
+
+	if err := cache.Close(); err != nil {
+		t.Fatalf("Failed to close cache: %v", err)
+	}
+
+	t.Run("Reopen", func(t *testing.T) {
+		reopened, err := NewShardedCache(dirs, config)
+		if err != nil {
+			t.Fatalf("Failed to reopen cache: %v", err)
+		}
+		defer reopened.Close()
+		if got := readString(t, reopened, "file-12"); got != "file-12" {
+			t.Errorf("Expected 'file-12' after reopen, got %q", got)
+		}
+	})
+
+	t.Run("ReorderedDirs", func(t *testing.T) {
+		reordered := []string{dirs[1], dirs[0], dirs[2]}
+		if _, err := NewShardedCache(reordered, config); err == nil {
+			t.Error("Expected error for reordered shard directories")
+		}
+		if _, err := NewShardedCache(dirs[:2], config); err == nil {
+			t.Error("Expected error for changed shard count")
+		}
+	})
+}
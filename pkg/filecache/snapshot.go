@@ -0,0 +1,177 @@
+package filecache
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/pb"
+)
+
+const (
+	// snapshotMaxPendingWrites 恢复快照时允许同时提交的写批次数
+	snapshotMaxPendingWrites = 256
+	// snapshotMaxRecord 快照中单条记录的最大长度，超过时视为损坏
+	snapshotMaxRecord = 1 << 31
+)
+
+// Snapshot 使用Badger的备份流把版本号大于since的数据写入w，可以在缓存服务期间执行
+// 快照基于一致的读时间点，包含所有命名空间，只能在根缓存上调用
+func (c *badgerCache) Snapshot(ctx context.Context, w io.Writer, since uint64) (uint64, error) {
+	if err := c.checkSnapshotSupported(); err != nil {
+		return 0, err
+	}
+	// 统计信息只在内存中累计，先写入数据库使快照包含最新的统计
+	if err := c.saveAllStats(); err != nil {
+		return 0, fmt.Errorf("failed to save stats: %w", err)
+	}
+
+	var last uint64
+	err := c.store.withDB(func(db *badger.DB) error {
+		var err error
+		last, err = db.Backup(&ctxWriter{ctx: ctx, w: w}, since)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	// Backup返回写出的最大版本号，增量快照只包含版本号大于since的数据；没有新数据时保持原来的起点
+	if last < since {
+		return since, nil
+	}
+	return last, nil
+}
+
+// RestoreSnapshot 检查快照完整后清空缓存并加载快照，恢复期间其他操作会等待恢复完成
+func (c *badgerCache) RestoreSnapshot(ctx context.Context, r io.Reader) error {
+	if err := c.checkSnapshotSupported(); err != nil {
+		return err
+	}
+
+	if err := c.loadSnapshot(ctx, r, true); err != nil {
+		return err
+	}
+	return c.reloadAllStats()
+}
+
+// loadSnapshot 把快照写入数据库，dropAll为true时先清空数据库，增量快照叠加在已有数据上。
+// 快照先完整读入数据目录下的临时文件并检查格式，截断、损坏或者读取中途取消的快照不会清空已有的数据
+func (c *badgerCache) loadSnapshot(ctx context.Context, r io.Reader, dropAll bool) error {
+	staged, err := stageSnapshot(ctx, r, c.config.Load().DataDir)
+	if err != nil {
+		return fmt.Errorf("failed to restore snapshot: %w", err)
+	}
+	defer func() {
+		staged.Close()
+		os.Remove(staged.Name())
+	}()
+
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	if dropAll {
+		c.store.drops.Add(1)
+		if err := c.store.db.DropAll(); err != nil {
+			return fmt.Errorf("failed to restore snapshot: %w", err)
+		}
+	}
+	if err := c.store.db.Load(staged, snapshotMaxPendingWrites); err != nil {
+		return fmt.Errorf("failed to restore snapshot: %w", err)
+	}
+	return nil
+}
+
+// stageSnapshot 把快照复制到dir下的临时文件，复制时逐条解析Badger备份流的记录（8字节小端长度和KVList），
+// 全部记录有效时返回定位到开头的文件，调用方负责关闭并删除
+func stageSnapshot(ctx context.Context, r io.Reader, dir string) (*os.File, error) {
+	f, err := os.CreateTemp(dir, ".snapshot-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	if err := copySnapshot(f, bufio.NewReader(&ctxReader{ctx: ctx, r: r})); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
+}
+
+// copySnapshot 校验并复制快照的每条记录，流只能在记录之间结束
+func copySnapshot(w io.Writer, r io.Reader) error {
+	var header [8]byte
+	var buf []byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("truncated snapshot: %w", err)
+		}
+		size := binary.LittleEndian.Uint64(header[:])
+		if size > snapshotMaxRecord {
+			return fmt.Errorf("invalid snapshot record size %d", size)
+		}
+		if uint64(cap(buf)) < size {
+			buf = make([]byte, size)
+		}
+		buf = buf[:size]
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return fmt.Errorf("truncated snapshot: %w", err)
+		}
+		list := &pb.KVList{}
+		if err := list.Unmarshal(buf); err != nil {
+			return fmt.Errorf("corrupt snapshot: %w", err)
+		}
+		if _, err := w.Write(header[:]); err != nil {
+			return err
+		}
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+}
+
+// checkSnapshotSupported 快照覆盖整个数据库，且不包含文件系统后端的数据文件
+func (c *badgerCache) checkSnapshotSupported() error {
+	if c.prefix != "" {
+		return fmt.Errorf("snapshots are only supported on the root cache")
+	}
+	if c.blobs != nil {
+		return fmt.Errorf("snapshots are not supported by the filesystem blob backend")
+	}
+	return nil
+}
+
+// ctxWriter 在上下文取消后停止写入
+type ctxWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (w *ctxWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
+
+// ctxReader 在上下文取消后停止读取
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,110 @@
+package filecache
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+
+	src := newTestBadgerCache(t)
+	defer src.Close()
+	snapshotter := src.(Snapshotter)
+
+	if err := src.Set(ctx, "a", strings.NewReader("aaa"), "text/plain", time.Hour); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+	if err := src.Namespace("site-a").Set(ctx, "b", strings.NewReader("bbb"), "text/plain", time.Hour); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+
+	var full bytes.Buffer
+	since, err := snapshotter.Snapshot(ctx, &full, 0)
+	if err != nil {
+		t.Fatalf("Failed to take snapshot: %v", err)
+	}
+	if since == 0 {
+		t.Error("Expected non-zero version for next snapshot")
+	}
+
+	// 增量快照只包含之后写入的数据
+	if err := src.Set(ctx, "c", strings.NewReader("ccc"), "text/plain", time.Hour); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+	var incremental bytes.Buffer
+	if _, err := snapshotter.Snapshot(ctx, &incremental, since); err != nil {
+		t.Fatalf("Failed to take incremental snapshot: %v", err)
+	}
+	if incremental.Len() == 0 || incremental.Len() >= full.Len() {
+		t.Errorf("Unexpected incremental snapshot size %d", incremental.Len())
+	}
+
+	dst := newTestBadgerCache(t)
+	defer dst.Close()
+	if err := dst.Set(ctx, "stale", strings.NewReader("x"), "text/plain", time.Hour); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+
+	if err := dst.(Snapshotter).RestoreSnapshot(ctx, &full); err != nil {
+		t.Fatalf("Failed to restore snapshot: %v", err)
+	}
+
+	if got := readString(t, dst, "a"); got != "aaa" {
+		t.Errorf("Expected 'aaa', got %q", got)
+	}
+	if got := readString(t, dst.Namespace("site-a"), "b"); got != "bbb" {
+		t.Errorf("Expected 'bbb', got %q", got)
+	}
+	for _, key := range []string{"stale", "c"} {
+		if exists, _ := dst.Exists(ctx, key); exists {
+			t.Errorf("Expected %s to be absent after restore", key)
+		}
+	}
+
+	stats, err := dst.Stats()
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	if stats.TotalFiles != 1 {
+		t.Errorf("Expected restored stats with 1 file, got %d", stats.TotalFiles)
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		var snapshot bytes.Buffer
+		if _, err := snapshotter.Snapshot(ctx, &snapshot, 0); err != nil {
+			t.Fatalf("Failed to take snapshot: %v", err)
+		}
+		corrupt := append([]byte(nil), snapshot.Bytes()...)
+		for i := 8; i < len(corrupt) && i < 64; i++ {
+			corrupt[i] = 0xff
+		}
+		for name, data := range map[string][]byte{
+			"Truncated": snapshot.Bytes()[:snapshot.Len()-1],
+			"Corrupt":   corrupt,
+			"Garbage":   []byte("not a snapshot"),
+		} {
+			if err := dst.(Snapshotter).RestoreSnapshot(ctx, bytes.NewReader(data)); err == nil {
+				t.Errorf("Expected error for %s snapshot", name)
+			}
+			if got := readString(t, dst, "a"); got != "aaa" {
+				t.Errorf("Expected existing data to be kept after a %s snapshot, got %q", name, got)
+			}
+		}
+		files, _ := filepath.Glob(filepath.Join(dst.(*badgerCache).config.Load().DataDir, ".snapshot-*"))
+		if len(files) != 0 {
+			t.Errorf("Expected staged snapshots to be removed, got %v", files)
+		}
+	})
+
+	t.Run("Namespace", func(t *testing.T) {
+		var buf bytes.Buffer
+		if _, err := src.Namespace("site-a").(Snapshotter).Snapshot(ctx, &buf, 0); err == nil {
+			t.Error("Expected error for namespace snapshot")
+		}
+	})
+}
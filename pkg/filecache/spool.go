@@ -0,0 +1,82 @@
+package filecache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// spoolMemoryLimit 超过该大小的数据缓冲到临时文件
+const spoolMemoryLimit = 8 << 20 // 8MB
+
+// spooled 已缓冲的数据，可以Seek回开头重复读取，Close时清理临时文件
+type spooled struct {
+	io.ReadSeeker
+	file *os.File
+}
+
+// rewind 回到数据开头
+func (s *spooled) rewind() error {
+	_, err := s.Seek(0, io.SeekStart)
+	return err
+}
+
+func (s *spooled) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
+
+// spool 读取全部数据以获得长度，小数据留在内存，大数据缓冲到临时文件
+// maxSize大于0时超出限制返回ErrEntryTooLarge
+func spool(r io.Reader, maxSize int64) (*spooled, int64, error) {
+	buf := &bytes.Buffer{}
+	n, err := io.CopyN(buf, r, spoolMemoryLimit+1)
+	if err != nil && err != io.EOF {
+		return nil, 0, fmt.Errorf("failed to read data: %w", err)
+	}
+	if maxSize > 0 && n > maxSize {
+		return nil, 0, fmt.Errorf("%w: file size exceeds max entry size %d", ErrEntryTooLarge, maxSize)
+	}
+	if n <= spoolMemoryLimit {
+		return &spooled{ReadSeeker: bytes.NewReader(buf.Bytes())}, n, nil
+	}
+
+	file, err := os.CreateTemp("", "filecache-spool-*")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	s := &spooled{file: file}
+
+	limit := int64(-1)
+	if maxSize > 0 {
+		limit = maxSize + 1 - n
+	}
+	var rest int64
+	if _, err = buf.WriteTo(file); err == nil {
+		if limit >= 0 {
+			rest, err = io.Copy(file, io.LimitReader(r, limit))
+		} else {
+			rest, err = io.Copy(file, r)
+		}
+	}
+	if err != nil {
+		s.Close()
+		return nil, 0, fmt.Errorf("failed to read data: %w", err)
+	}
+
+	size := n + rest
+	if maxSize > 0 && size > maxSize {
+		s.Close()
+		return nil, 0, fmt.Errorf("%w: file size exceeds max entry size %d", ErrEntryTooLarge, maxSize)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		s.Close()
+		return nil, 0, err
+	}
+	s.ReadSeeker = file
+	return s, size, nil
+}
@@ -0,0 +1,17 @@
+package filecache
+
+import (
+	"context"
+	"io"
+)
+
+// GetStale 获取文件，已过期但尚未被清理的文件也会返回；
+// 过期文件在Config.StaleRetention内不会被Cleanup删除
+func (c *badgerCache) GetStale(ctx context.Context, key string) (io.ReadCloser, *FileInfo, error) {
+	return c.get(key, true, true)
+}
+
+// GetStale 获取文件，已过期但尚未被清理的文件也会返回
+func (c *memoryCache) GetStale(ctx context.Context, key string) (io.ReadCloser, *FileInfo, error) {
+	return c.get(key, true, true)
+}
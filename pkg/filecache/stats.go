@@ -10,8 +10,8 @@ import (
 
 // loadStats 加载统计信息
 func (c *badgerCache) loadStats() error {
-	return c.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(statsKey))
+	return c.store.view(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(c.prefix + statsKey))
 		if err != nil {
 			if err == badger.ErrKeyNotFound {
 				// 使用默认统计信息
@@ -38,8 +38,8 @@ func (c *badgerCache) saveStats() error {
 		return err
 	}
 
-	return c.db.Update(func(txn *badger.Txn) error {
-		return txn.Set([]byte(statsKey), statsBytes)
+	return c.store.update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(c.prefix+statsKey), statsBytes)
 	})
 }
 
@@ -109,22 +109,27 @@ func (c *badgerCache) updateFileAccess(key string, fileInfo *FileInfo) {
 		return
 	}
 
-	c.db.Update(func(txn *badger.Txn) error {
-		return txn.Set([]byte(fileInfoPrefix+key), infoBytes)
+	c.store.update(func(txn *badger.Txn) error {
+		return txn.Set(c.infoKey(key), infoBytes)
 	})
 }
 
-// startCleanupRoutine 启动清理协程
-func (c *badgerCache) startCleanupRoutine() {
-	ticker := time.NewTicker(c.config.CleanupInterval)
+// startCleanupRoutine 启动清理协程，ctx取消后退出
+func (c *badgerCache) startCleanupRoutine(ctx context.Context) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.config.Load().CleanupInterval)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-		if err := c.Cleanup(ctx); err != nil {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cleanupCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 			// 记录错误但不中断清理协程
-			continue
+			_ = c.cleanupAll(cleanupCtx)
+			cancel()
 		}
-		cancel()
 	}
 }
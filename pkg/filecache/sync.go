@@ -0,0 +1,44 @@
+package filecache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SyncResult 增量同步结果
+type SyncResult struct {
+	MigrateResult
+	Watermark time.Time `json:"watermark"` // peer中最新的写入时间，作为下一次同步的since
+}
+
+// SyncSince 把peer中在since之后写入的文件复制到cache，用于边缘节点定期追赶源站屏蔽层
+// 每次Set都会刷新FileInfo.CreatedAt，它就是条目的最后写入时间，只有这些条目的数据会被传输
+// 返回的Watermark取自peer的时钟，不受两端时钟偏差影响；同步失败时Watermark保持为since，下一次同步会重试整个区间
+// 删除不会被同步，peer中已删除的文件在cache中按自己的TTL过期
+func SyncSince(ctx context.Context, cache, peer Cache, since time.Time) (*SyncResult, error) {
+	var (
+		mu        sync.Mutex
+		watermark = since
+	)
+	result, err := Migrate(ctx, peer, cache, MigrateOptions{
+		Filter: func(file *FileInfo) bool {
+			mu.Lock()
+			if file.CreatedAt.After(watermark) {
+				watermark = file.CreatedAt
+			}
+			mu.Unlock()
+
+			return file.CreatedAt.After(since)
+		},
+	})
+	if result == nil {
+		return nil, err
+	}
+
+	synced := &SyncResult{MigrateResult: *result, Watermark: watermark}
+	if err != nil {
+		synced.Watermark = since
+	}
+	return synced, err
+}
@@ -0,0 +1,77 @@
+package filecache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyncSince(t *testing.T) {
+	ctx := context.Background()
+
+	shield := newTestBadgerCache(t)
+	defer shield.Close()
+	edge := NewMemoryCache(1024 * 1024)
+	defer edge.Close()
+
+	set := func(key, value string) {
+		t.Helper()
+		if err := shield.Set(ctx, key, strings.NewReader(value), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set %s: %v", key, err)
+		}
+	}
+	set("a", "aaa")
+	set("b", "bbb")
+
+	result, err := SyncSince(ctx, edge, shield, time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to sync: %v", err)
+	}
+	if result.Copied != 2 || result.Watermark.IsZero() {
+		t.Errorf("Unexpected initial sync result: %+v", result)
+	}
+
+	t.Run("OnlyChanged", func(t *testing.T) {
+		time.Sleep(5 * time.Millisecond)
+		set("b", "bbb v2")
+		set("c", "ccc")
+
+		next, err := SyncSince(ctx, edge, shield, result.Watermark)
+		if err != nil {
+			t.Fatalf("Failed to sync: %v", err)
+		}
+		if next.Total != 3 || next.Copied != 2 || next.Skipped != 1 {
+			t.Errorf("Expected only changed entries to be copied, got %+v", next)
+		}
+		if !next.Watermark.After(result.Watermark) {
+			t.Errorf("Expected watermark to advance, got %v", next.Watermark)
+		}
+		if got := readString(t, edge, "b"); got != "bbb v2" {
+			t.Errorf("Expected 'bbb v2', got %q", got)
+		}
+
+		// 没有新的写入时什么也不传输，水位保持不变
+		idle, err := SyncSince(ctx, edge, shield, next.Watermark)
+		if err != nil {
+			t.Fatalf("Failed to sync: %v", err)
+		}
+		if idle.Copied != 0 || !idle.Watermark.Equal(next.Watermark) {
+			t.Errorf("Unexpected idle sync result: %+v", idle)
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		since := time.Now().Add(-time.Hour)
+		result, err := SyncSince(canceled, edge, shield, since)
+		if err == nil {
+			t.Fatal("Expected error for canceled context")
+		}
+		if result != nil && !result.Watermark.Equal(since) {
+			t.Errorf("Expected watermark to stay at since after failure, got %v", result.Watermark)
+		}
+	})
+}
@@ -0,0 +1,206 @@
+package filecache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// defaultMaxPromoteSize 默认可放入L1的最大文件大小
+const defaultMaxPromoteSize = 1 << 20 // 1MB
+
+// TieredOptions 两级缓存选项
+type TieredOptions struct {
+	// MaxPromoteSize 可放入L1的最大文件大小（字节），更大的文件只存放在L2，默认1MB
+	MaxPromoteSize int64
+}
+
+// tieredCache 两级缓存，L1通常为内存缓存，L2为Badger等持久化缓存
+// 写入采用写穿透，L2是权威数据源；L2命中的小文件会提升到L1
+type tieredCache struct {
+	l1, l2 Cache
+	opts   TieredOptions
+
+	mu           sync.RWMutex // 保护命中统计
+	hits, misses int64
+
+	namespaces map[string]*tieredCache
+	nsMu       sync.Mutex
+}
+
+// NewTieredCache 创建两级缓存，关闭时会同时关闭l1和l2
+func NewTieredCache(l1, l2 Cache) Cache {
+	return NewTieredCacheWithOptions(l1, l2, TieredOptions{})
+}
+
+// NewTieredCacheWithOptions 使用选项创建两级缓存
+func NewTieredCacheWithOptions(l1, l2 Cache, opts TieredOptions) Cache {
+	if opts.MaxPromoteSize <= 0 {
+		opts.MaxPromoteSize = defaultMaxPromoteSize
+	}
+	return &tieredCache{
+		l1:         l1,
+		l2:         l2,
+		opts:       opts,
+		namespaces: make(map[string]*tieredCache),
+	}
+}
+
+// Set 写穿透：先写L2，再写L1；过大的文件只写L2并使L1中的旧版本失效
+func (c *tieredCache) Set(ctx context.Context, key string, data io.Reader, mimeType string, ttl time.Duration) error {
+	// 最多缓冲MaxPromoteSize+1字节，用于判断是否能放入L1
+	buf := &bytes.Buffer{}
+	n, err := io.CopyN(buf, data, c.opts.MaxPromoteSize+1)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read data: %w", err)
+	}
+	promote := n <= c.opts.MaxPromoteSize
+
+	var body io.Reader = bytes.NewReader(buf.Bytes())
+	if !promote {
+		body = io.MultiReader(body, data)
+	}
+	if err := c.l2.Set(ctx, key, body, mimeType, ttl); err != nil {
+		return err
+	}
+
+	if !promote {
+		return c.l1.Delete(ctx, key)
+	}
+	if err := c.l1.Set(ctx, key, bytes.NewReader(buf.Bytes()), mimeType, ttl); err != nil {
+		// L1写入失败不影响结果，但不能留下旧版本
+		return c.l1.Delete(ctx, key)
+	}
+	return nil
+}
+
+// Get 优先从L1读取，未命中时回落到L2并提升到L1
+func (c *tieredCache) Get(ctx context.Context, key string) (io.ReadCloser, *FileInfo, error) {
+	if reader, info, err := c.l1.Get(ctx, key); err == nil {
+		c.recordHit()
+		return reader, info, nil
+	}
+
+	reader, info, err := c.l2.Get(ctx, key)
+	if err != nil {
+		c.recordMiss()
+		return nil, nil, err
+	}
+	c.recordHit()
+
+	ttl, ok := info.RemainingTTL()
+	if info.Size > c.opts.MaxPromoteSize || !ok {
+		return reader, info, nil
+	}
+
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read data: %w", err)
+	}
+	// 提升失败不影响本次读取
+	_ = c.l1.Set(ctx, key, bytes.NewReader(data), info.MimeType, ttl)
+
+	return newReadCloser(data), info, nil
+}
+
+// Exists 检查文件是否存在
+func (c *tieredCache) Exists(ctx context.Context, key string) (bool, error) {
+	if exists, err := c.l1.Exists(ctx, key); err == nil && exists {
+		return true, nil
+	}
+	return c.l2.Exists(ctx, key)
+}
+
+// Delete 删除文件，L2删除成功后使L1失效
+func (c *tieredCache) Delete(ctx context.Context, key string) error {
+	if err := c.l2.Delete(ctx, key); err != nil {
+		return err
+	}
+	return c.l1.Delete(ctx, key)
+}
+
+// List 列出所有缓存文件，以L2为准
+func (c *tieredCache) List(ctx context.Context) ([]*FileInfo, error) {
+	return c.l2.List(ctx)
+}
+
+// GetInfo 获取文件信息，以L2为准
+func (c *tieredCache) GetInfo(ctx context.Context, key string) (*FileInfo, error) {
+	return c.l2.GetInfo(ctx, key)
+}
+
+// Cleanup 清理两级缓存中的过期文件
+func (c *tieredCache) Cleanup(ctx context.Context) error {
+	if err := c.l1.Cleanup(ctx); err != nil {
+		return err
+	}
+	return c.l2.Cleanup(ctx)
+}
+
+// Flush 清空两级缓存
+func (c *tieredCache) Flush(ctx context.Context) error {
+	if err := c.l2.Flush(ctx); err != nil {
+		return err
+	}
+	return c.l1.Flush(ctx)
+}
+
+// Namespace 返回由两级缓存各自的同名命名空间组成的两级缓存
+func (c *tieredCache) Namespace(name string) Cache {
+	if name == "" {
+		return c
+	}
+
+	c.nsMu.Lock()
+	defer c.nsMu.Unlock()
+
+	if ns, ok := c.namespaces[name]; ok {
+		return ns
+	}
+	ns := NewTieredCacheWithOptions(c.l1.Namespace(name), c.l2.Namespace(name), c.opts).(*tieredCache)
+	c.namespaces[name] = ns
+	return ns
+}
+
+// Close 关闭两级缓存
+func (c *tieredCache) Close() error {
+	err := c.l1.Close()
+	if l2Err := c.l2.Close(); l2Err != nil {
+		err = l2Err
+	}
+	return err
+}
+
+// Stats 返回L2的容量统计，命中率按两级缓存整体计算
+func (c *tieredCache) Stats() (*Stats, error) {
+	stats, err := c.l2.Stats()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if total := float64(c.hits + c.misses); total > 0 {
+		stats.HitRate = float64(c.hits) / total
+		stats.MissRate = float64(c.misses) / total
+	}
+	return stats, nil
+}
+
+// recordHit 记录一次命中
+func (c *tieredCache) recordHit() {
+	c.mu.Lock()
+	c.hits++
+	c.mu.Unlock()
+}
+
+// recordMiss 记录一次未命中
+func (c *tieredCache) recordMiss() {
+	c.mu.Lock()
+	c.misses++
+	c.mu.Unlock()
+}
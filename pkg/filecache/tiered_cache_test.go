@@ -0,0 +1,100 @@
+package filecache
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestBadgerCache 创建测试用的Badger缓存，测试结束时自动关闭
+func newTestBadgerCache(t *testing.T) Cache {
+	t.Helper()
+
+	cache, err := NewBadgerCache(&Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	return cache
+}
+
+// readString 读取缓存文件内容
+func readString(t *testing.T, cache Cache, key string) string {
+	t.Helper()
+
+	reader, _, err := cache.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Failed to get %s: %v", key, err)
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", key, err)
+	}
+	return string(content)
+}
+
+func TestTieredCache(t *testing.T) {
+	l1 := NewMemoryCache(1024)
+	l2 := newTestBadgerCache(t)
+	cache := NewTieredCacheWithOptions(l1, l2, TieredOptions{MaxPromoteSize: 16})
+	defer cache.Close()
+
+	ctx := context.Background()
+
+	t.Run("WriteThrough", func(t *testing.T) {
+		if err := cache.Set(ctx, "hot.json", strings.NewReader(`{"a":1}`), "application/json", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		if got := readString(t, l1, "hot.json"); got != `{"a":1}` {
+			t.Errorf("Expected L1 to hold the entry, got '%s'", got)
+		}
+		if got := readString(t, l2, "hot.json"); got != `{"a":1}` {
+			t.Errorf("Expected L2 to hold the entry, got '%s'", got)
+		}
+	})
+
+	t.Run("LargeEntriesSkipL1", func(t *testing.T) {
+		large := strings.Repeat("x", 32)
+		if err := cache.Set(ctx, "large.bin", strings.NewReader(large), "application/octet-stream", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		if exists, _ := l1.Exists(ctx, "large.bin"); exists {
+			t.Error("Expected large entry to skip L1")
+		}
+		if got := readString(t, cache, "large.bin"); got != large {
+			t.Errorf("Expected large entry from L2, got '%s'", got)
+		}
+	})
+
+	t.Run("PromoteOnL2Hit", func(t *testing.T) {
+		if err := l2.Set(ctx, "cold.txt", strings.NewReader("cold"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		if got := readString(t, cache, "cold.txt"); got != "cold" {
+			t.Errorf("Expected 'cold', got '%s'", got)
+		}
+		if exists, _ := l1.Exists(ctx, "cold.txt"); !exists {
+			t.Error("Expected L2 hit to be promoted to L1")
+		}
+	})
+
+	t.Run("DeleteInvalidatesL1", func(t *testing.T) {
+		if err := cache.Delete(ctx, "hot.json"); err != nil {
+			t.Fatalf("Failed to delete file: %v", err)
+		}
+		if exists, _ := l1.Exists(ctx, "hot.json"); exists {
+			t.Error("Expected L1 entry to be invalidated")
+		}
+		if _, _, err := cache.Get(ctx, "hot.json"); err == nil {
+			t.Error("Expected deleted entry to be gone")
+		}
+	})
+}
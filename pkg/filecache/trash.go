@@ -0,0 +1,314 @@
+package filecache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	// 回收站键前缀
+	trashPrefix           = "trash:"
+	trashDataPrefix       = trashPrefix + fileDataPrefix
+	trashInfoPrefix       = trashPrefix + fileInfoPrefix
+	defaultTrashRetention = 24 * time.Hour
+)
+
+// trashRetention 返回回收站保留时间
+func (c *badgerCache) trashRetention() time.Duration {
+	if retention := c.config.Load().TrashRetention; retention > 0 {
+		return retention
+	}
+	return defaultTrashRetention
+}
+
+// softDelete 将文件移入回收站
+func (c *badgerCache) softDelete(key string) error {
+	defer c.lockBlob(key)()
+
+	var size int64
+	found := false
+
+	err := c.store.update(func(txn *badger.Txn) error {
+		infoItem, err := txn.Get(c.infoKey(key))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		record := &TrashInfo{DeletedAt: now, PurgeAt: now.Add(c.trashRetention())}
+		if err := infoItem.Value(func(val []byte) error {
+			return json.Unmarshal(val, &record.FileInfo)
+		}); err != nil {
+			return err
+		}
+		record.Key = key
+
+		recordBytes, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+
+		if err := c.moveDataInTxn(txn, c.dataKey(key), c.trashDataKey(key)); err != nil {
+			return err
+		}
+		if err := txn.Set(c.trashInfoKey(key), recordBytes); err != nil {
+			return err
+		}
+		if err := txn.Delete(c.infoKey(key)); err != nil {
+			return err
+		}
+
+		size = record.Size
+		found = true
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to move file to trash: %w", err)
+	}
+
+	if found {
+		if c.blobs != nil {
+			if err := c.blobs.move(c.blobPath(key), c.trashBlobPath(key)); err != nil {
+				return fmt.Errorf("failed to move blob to trash: %w", err)
+			}
+		}
+		c.updateStatsAfterDelete(size)
+	}
+	return nil
+}
+
+// moveDataInTxn 在事务中移动文件数据，文件数据在文件系统上时由调用方在提交后移动
+func (c *badgerCache) moveDataInTxn(txn *badger.Txn, from, to []byte) error {
+	if c.blobs != nil {
+		return nil
+	}
+
+	item, err := txn.Get(from)
+	if err != nil {
+		return err
+	}
+	data, err := item.ValueCopy(nil)
+	if err != nil {
+		return err
+	}
+	if err := txn.Set(to, data); err != nil {
+		return err
+	}
+	return txn.Delete(from)
+}
+
+// Restore 从回收站恢复文件，恢复后保留原有的过期时间；同名的文件已经存在时返回ErrExists
+func (c *badgerCache) Restore(ctx context.Context, key string) error {
+	defer c.lockBlob(key)()
+
+	var size int64
+
+	err := c.store.update(func(txn *badger.Txn) error {
+		infoItem, err := txn.Get(c.trashInfoKey(key))
+		if err != nil {
+			return err
+		}
+		// 同名的文件已经重新写入时拒绝恢复，不覆盖更新的数据，也不重复计入统计信息
+		if _, err := txn.Get(c.infoKey(key)); err == nil {
+			return ErrExists
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+
+		record := &TrashInfo{}
+		if err := infoItem.Value(func(val []byte) error {
+			return json.Unmarshal(val, record)
+		}); err != nil {
+			return err
+		}
+
+		infoBytes, err := json.Marshal(&record.FileInfo)
+		if err != nil {
+			return err
+		}
+
+		if err := c.moveDataInTxn(txn, c.trashDataKey(key), c.dataKey(key)); err != nil {
+			return err
+		}
+		if err := txn.Set(c.infoKey(key), infoBytes); err != nil {
+			return err
+		}
+		if err := txn.Delete(c.trashInfoKey(key)); err != nil {
+			return err
+		}
+
+		size = record.Size
+		return nil
+	})
+	if err == badger.ErrKeyNotFound {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore file: %w", err)
+	}
+
+	if c.blobs != nil {
+		if err := c.blobs.move(c.trashBlobPath(key), c.blobPath(key)); err != nil {
+			return fmt.Errorf("failed to restore blob: %w", err)
+		}
+	}
+	c.updateStatsAfterSet(size)
+	return nil
+}
+
+// ListTrash 列出回收站中的文件
+func (c *badgerCache) ListTrash(ctx context.Context) ([]*TrashInfo, error) {
+	var records []*TrashInfo
+
+	err := c.store.view(func(txn *badger.Txn) error {
+		prefix := []byte(c.prefix + trashInfoPrefix)
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			err := item.Value(func(val []byte) error {
+				record := &TrashInfo{}
+				if err := json.Unmarshal(val, record); err != nil {
+					return err
+				}
+				record.Key = string(item.Key()[len(prefix):])
+				records = append(records, record)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return records, err
+}
+
+// purgeTrash 永久删除超过保留时间的回收站条目，与过期清理一样按批扫描和删除，每批最多batchSize条，
+// 回收站条目很多时也不会一次读入内存或放进一个事务
+func (c *badgerCache) purgeTrash(ctx context.Context, batchSize int) (int64, error) {
+	var purged int64
+	cursor := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return purged, err
+		}
+		keys, next, done, err := c.scanTrash(cursor, batchSize)
+		if err != nil {
+			return purged, err
+		}
+		if len(keys) > 0 {
+			n, err := c.deleteTrash(keys)
+			purged += n
+			if err != nil {
+				return purged, err
+			}
+		}
+		if done {
+			return purged, nil
+		}
+		cursor = next
+	}
+}
+
+// scanTrash 从cursor之后扫描回收站记录，返回最多batchSize个超过保留时间的键和下一批的起点，扫描到末尾时done为true
+func (c *badgerCache) scanTrash(cursor string, batchSize int) (keys []string, next string, done bool, err error) {
+	now := time.Now()
+	next = cursor
+	prefix := []byte(c.prefix + trashInfoPrefix)
+
+	err = c.store.view(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		seek := append(append([]byte{}, prefix...), cursor...)
+		for it.Seek(seek); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			key := string(item.Key()[len(prefix):])
+			if cursor != "" && key == cursor {
+				continue
+			}
+			if len(keys) >= batchSize {
+				return nil
+			}
+
+			next = key
+			err := item.Value(func(val []byte) error {
+				record := &TrashInfo{}
+				if err := json.Unmarshal(val, record); err != nil {
+					return err
+				}
+				if !now.Before(record.PurgeAt) {
+					keys = append(keys, key)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		done = true
+		return nil
+	})
+	return keys, next, done, err
+}
+
+// deleteTrash 在一个事务中永久删除回收站条目，扫描之后重新移入回收站、还没有到期的条目保留
+func (c *badgerCache) deleteTrash(keys []string) (int64, error) {
+	now := time.Now()
+	var deleted []string
+	err := c.store.update(func(txn *badger.Txn) error {
+		for _, key := range keys {
+			item, err := txn.Get(c.trashInfoKey(key))
+			if err == badger.ErrKeyNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			record := &TrashInfo{}
+			if err := item.Value(func(val []byte) error {
+				return json.Unmarshal(val, record)
+			}); err != nil {
+				return err
+			}
+			if now.Before(record.PurgeAt) {
+				continue
+			}
+
+			if c.blobs == nil {
+				if err := txn.Delete(c.trashDataKey(key)); err != nil {
+					return err
+				}
+			}
+			if err := txn.Delete(c.trashInfoKey(key)); err != nil {
+				return err
+			}
+			deleted = append(deleted, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if c.blobs != nil {
+		for _, key := range deleted {
+			c.removeBlob(key, true)
+		}
+	}
+	return int64(len(deleted)), nil
+}
@@ -0,0 +1,130 @@
+package filecache
+
+import (
+	"fmt"
+	"mime"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// TTLPolicy 按键或MIME类型选择TTL的规则，设置的条件都满足时匹配
+type TTLPolicy struct {
+	// Glob path.Match语法的键模式；不含"/"时匹配键的最后一段，例如"*.css"匹配"assets/app.css"，
+	// 含"/"时匹配整个键（忽略开头的"/"）
+	Glob string `json:"glob,omitempty"`
+
+	// Regex 匹配键的正则表达式，匹配键的任意部分，需要整体匹配时加上^和$
+	Regex string `json:"regex,omitempty"`
+
+	// MimeType 匹配的MIME类型，忽略参数和大小写；"text/*"匹配一类
+	MimeType string `json:"mime_type,omitempty"`
+
+	// TTL 匹配的文件使用的TTL
+	TTL time.Duration `json:"ttl"`
+}
+
+// TTLPolicySet 编译后的TTL策略表，按顺序使用第一条匹配的规则，可以并发使用
+type TTLPolicySet struct {
+	rules []ttlRule
+}
+
+// ttlRule 编译后的一条规则
+type ttlRule struct {
+	glob     string
+	baseOnly bool // glob不含"/"，只匹配键的最后一段
+	regex    *regexp.Regexp
+	major    string // MimeType的主类型
+	minor    string // MimeType的子类型，"*"匹配任意子类型
+	ttl      time.Duration
+}
+
+// NewTTLPolicySet 检查并编译policies，policies为空时返回nil，nil的TTLPolicySet不匹配任何文件
+func NewTTLPolicySet(policies []TTLPolicy) (*TTLPolicySet, error) {
+	if len(policies) == 0 {
+		return nil, nil
+	}
+	set := &TTLPolicySet{rules: make([]ttlRule, 0, len(policies))}
+	for i, p := range policies {
+		if p.Glob == "" && p.Regex == "" && p.MimeType == "" {
+			return nil, fmt.Errorf("ttl policy %d requires a glob, regex or mime type", i)
+		}
+		if p.TTL <= 0 {
+			return nil, fmt.Errorf("ttl policy %d: ttl must be positive", i)
+		}
+		rule := ttlRule{ttl: p.TTL}
+		if p.Glob != "" {
+			rule.glob = strings.TrimPrefix(p.Glob, "/")
+			rule.baseOnly = !strings.Contains(rule.glob, "/")
+			if _, err := path.Match(rule.glob, ""); err != nil {
+				return nil, fmt.Errorf("ttl policy %d: invalid glob %q: %w", i, p.Glob, err)
+			}
+		}
+		if p.Regex != "" {
+			re, err := regexp.Compile(p.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("ttl policy %d: invalid regex: %w", i, err)
+			}
+			rule.regex = re
+		}
+		if p.MimeType != "" {
+			major, minor, ok := strings.Cut(mediaType(p.MimeType), "/")
+			if !ok || major == "" || minor == "" {
+				return nil, fmt.Errorf("ttl policy %d: invalid mime type %q", i, p.MimeType)
+			}
+			rule.major, rule.minor = major, minor
+		}
+		set.rules = append(set.rules, rule)
+	}
+	return set, nil
+}
+
+// TTL 返回第一条匹配key和mimeType的规则的TTL，没有匹配时返回false
+func (s *TTLPolicySet) TTL(key, mimeType string) (time.Duration, bool) {
+	if s == nil {
+		return 0, false
+	}
+	trimmed := strings.TrimPrefix(key, "/")
+	base := path.Base(trimmed)
+	var major, minor string
+	if mimeType != "" {
+		major, minor, _ = strings.Cut(mediaType(mimeType), "/")
+	}
+	for _, rule := range s.rules {
+		if rule.glob != "" {
+			name := trimmed
+			if rule.baseOnly {
+				name = base
+			}
+			if ok, _ := path.Match(rule.glob, name); !ok {
+				continue
+			}
+		}
+		if rule.regex != nil && !rule.regex.MatchString(key) {
+			continue
+		}
+		if rule.major != "" && (major != rule.major || (rule.minor != "*" && minor != rule.minor)) {
+			continue
+		}
+		return rule.ttl, true
+	}
+	return 0, false
+}
+
+// defaultTTL 返回写入时没有指定TTL的文件的TTL：第一条匹配的策略，都不匹配时为DefaultTTL
+func (c *badgerCache) defaultTTL(key, mimeType string) time.Duration {
+	if ttl, ok := c.ttls.Load().TTL(key, mimeType); ok {
+		return ttl
+	}
+	return c.config.Load().DefaultTTL
+}
+
+// mediaType 返回去掉参数的小写MIME类型
+func mediaType(mimeType string) string {
+	if t, _, err := mime.ParseMediaType(mimeType); err == nil {
+		return t
+	}
+	t, _, _ := strings.Cut(mimeType, ";")
+	return strings.ToLower(strings.TrimSpace(t))
+}
@@ -0,0 +1,142 @@
+package filecache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTTLPolicySet(t *testing.T) {
+	set, err := NewTTLPolicySet([]TTLPolicy{
+		{Glob: "*.css", TTL: 7 * 24 * time.Hour},
+		{Glob: "*.js", TTL: 7 * 24 * time.Hour},
+		{Glob: "/static/fonts/*", TTL: 30 * 24 * time.Hour},
+		{Regex: `^/api/v[0-9]+/`, MimeType: "application/json", TTL: 30 * time.Second},
+		{MimeType: "image/*", TTL: 24 * time.Hour},
+	})
+	if err != nil {
+		t.Fatalf("Failed to compile policies: %v", err)
+	}
+
+	tests := []struct {
+		key      string
+		mimeType string
+		want     time.Duration
+		match    bool
+	}{
+		{"assets/app.css", "text/css", 7 * 24 * time.Hour, true},
+		{"/bundle.js", "", 7 * 24 * time.Hour, true},
+		{"static/fonts/a.woff2", "font/woff2", 30 * 24 * time.Hour, true},
+		{"static/fonts/sub/a.woff2", "font/woff2", 0, false},
+		{"/api/v2/users", "application/json; charset=utf-8", 30 * time.Second, true},
+		{"/api/v2/users", "text/html", 0, false},
+		{"/logo.png", "IMAGE/PNG", 24 * time.Hour, true},
+		{"/index.html", "text/html", 0, false},
+	}
+	for _, tt := range tests {
+		ttl, ok := set.TTL(tt.key, tt.mimeType)
+		if ok != tt.match || ttl != tt.want {
+			t.Errorf("Expected %s (%s) to get %v/%v, got %v/%v", tt.key, tt.mimeType, tt.want, tt.match, ttl, ok)
+		}
+	}
+
+	var empty *TTLPolicySet
+	if _, ok := empty.TTL("a.css", "text/css"); ok {
+		t.Error("Expected nil policy set to match nothing")
+	}
+
+	for _, policies := range [][]TTLPolicy{
+		{{TTL: time.Hour}},
+		{{Glob: "*.css"}},
+		{{Glob: "[", TTL: time.Hour}},
+		{{Regex: "(", TTL: time.Hour}},
+		{{MimeType: "text", TTL: time.Hour}},
+	} {
+		if _, err := NewTTLPolicySet(policies); err == nil {
+			t.Errorf("Expected error for %+v", policies)
+		}
+	}
+}
+
+func TestTTLPolicies(t *testing.T) {
+	ctx := context.Background()
+	policies := []TTLPolicy{
+		{Glob: "*.css", TTL: 7 * 24 * time.Hour},
+		{MimeType: "application/json", TTL: 30 * time.Second},
+	}
+	expiresIn := func(t *testing.T, cache Cache, key, mimeType string, ttl time.Duration) time.Duration {
+		t.Helper()
+		start := time.Now()
+		if err := cache.Set(ctx, key, strings.NewReader("x"), mimeType, ttl); err != nil {
+			t.Fatalf("Failed to set %s: %v", key, err)
+		}
+		_, info, err := cache.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Failed to get %s: %v", key, err)
+		}
+		return info.ExpiresAt.Sub(start).Round(time.Second)
+	}
+
+	config := &Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1 << 20,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+		TTLPolicies:     policies,
+	}
+	badger, err := NewBadgerCache(config)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer badger.Close()
+	memory, err := NewMemoryCacheWithConfig(config)
+	if err != nil {
+		t.Fatalf("Failed to create memory cache: %v", err)
+	}
+	defer memory.Close()
+
+	for name, cache := range map[string]Cache{"badger": badger, "memory": memory} {
+		t.Run(name, func(t *testing.T) {
+			if got := expiresIn(t, cache, "assets/app.css", "text/css", 0); got != 7*24*time.Hour {
+				t.Errorf("Expected css policy, got %v", got)
+			}
+			if got := expiresIn(t, cache.Namespace("api"), "users", "application/json", 0); got != 30*time.Second {
+				t.Errorf("Expected json policy in namespace, got %v", got)
+			}
+			if got := expiresIn(t, cache, "index.html", "text/html", 0); got != time.Hour {
+				t.Errorf("Expected default TTL, got %v", got)
+			}
+			if got := expiresIn(t, cache, "explicit.css", "text/css", time.Minute); got != time.Minute {
+				t.Errorf("Expected explicit TTL to win, got %v", got)
+			}
+		})
+	}
+
+	t.Run("reconfigure", func(t *testing.T) {
+		next := *config
+		next.TTLPolicies = []TTLPolicy{{Glob: "*.html", TTL: time.Minute}}
+		if err := badger.(Reconfigurer).Reconfigure(&next); err != nil {
+			t.Fatalf("Failed to reconfigure: %v", err)
+		}
+		if got := expiresIn(t, badger, "index.html", "text/html", 0); got != time.Minute {
+			t.Errorf("Expected new policy, got %v", got)
+		}
+		if got := expiresIn(t, badger, "app.css", "text/css", 0); got != time.Hour {
+			t.Errorf("Expected removed policy to no longer apply, got %v", got)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		invalid := *config
+		invalid.DataDir = t.TempDir()
+		invalid.TTLPolicies = []TTLPolicy{{Regex: "(", TTL: time.Hour}}
+		if err := ValidateConfig(&invalid); err == nil {
+			t.Error("Expected ValidateConfig to reject invalid policy")
+		}
+		if c, err := NewBadgerCache(&invalid); err == nil {
+			c.Close()
+			t.Error("Expected NewBadgerCache to reject invalid policy")
+		}
+	})
+}
@@ -0,0 +1,42 @@
+package filecache
+
+import (
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// DiskUsage 返回Badger的LSM和value log大小以及数据目录所在文件系统的容量
+func (c *badgerCache) DiskUsage() (*DiskUsage, error) {
+	usage := &DiskUsage{}
+	c.store.withDB(func(db *badger.DB) error {
+		usage.LSM, usage.ValueLog = db.Size()
+		return nil
+	})
+	total, free, err := diskSpace(c.config.Load().DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get disk space: %w", err)
+	}
+	usage.DiskTotal, usage.DiskFree = total, free
+	return usage, nil
+}
+
+// DiskUsage 返回所有分片的磁盘占用之和，分片通常位于不同的磁盘上，容量和可用空间也相加
+func (c *shardedCache) DiskUsage() (*DiskUsage, error) {
+	total := &DiskUsage{}
+	for i, shard := range c.shards {
+		reporter, ok := shard.(DiskUsageReporter)
+		if !ok {
+			return nil, fmt.Errorf("disk usage is not supported")
+		}
+		usage, err := reporter.DiskUsage()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get disk usage of shard %d: %w", i, err)
+		}
+		total.LSM += usage.LSM
+		total.ValueLog += usage.ValueLog
+		total.DiskTotal += usage.DiskTotal
+		total.DiskFree += usage.DiskFree
+	}
+	return total, nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd
+
+package filecache
+
+// diskSpace 当前平台不支持，返回0
+func diskSpace(dir string) (total, free int64, err error) {
+	return 0, 0, nil
+}
@@ -0,0 +1,14 @@
+//go:build linux || darwin || freebsd
+
+package filecache
+
+import "golang.org/x/sys/unix"
+
+// diskSpace 返回dir所在文件系统的容量和非特权用户可用的空间
+func diskSpace(dir string) (total, free int64, err error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return 0, 0, err
+	}
+	return int64(st.Blocks) * int64(st.Bsize), int64(st.Bavail) * int64(st.Bsize), nil
+}
@@ -0,0 +1,482 @@
+package filecache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// verifyOrphanAge 文件系统后端中没有FileInfo的文件超过这个时间未修改才视为孤立文件，
+// 文件数据先于FileInfo落盘，避免把正在写入的文件误判为孤立文件
+const verifyOrphanAge = time.Hour
+
+// errEntryChanged 条目在扫描之后被修改，修复被跳过
+var errEntryChanged = errors.New("entry changed since it was scanned")
+
+// verifyRepair 扫描时记录、扫描结束后执行的修复
+type verifyRepair struct {
+	problem int          // report.Problems中的下标
+	action  RepairAction // 修复方式
+	apply   func() error // 执行修复
+	files   int64        // 修复成功后文件数的变化
+	size    int64        // 修复成功后总大小的变化
+}
+
+// verifier 检查单个命名空间
+type verifier struct {
+	c       *badgerCache
+	path    string
+	opts    VerifyOptions
+	report  *VerifyReport
+	repairs []verifyRepair
+	files   int64           // FileInfo记录的文件数，与统计信息比较
+	size    int64           // FileInfo记录的总大小，与统计信息比较
+	blobs   map[string]bool // 文件系统后端中有记录对应的文件路径
+	rebuild *RepairOptions  // 非空时能够读取文件数据的条目按数据重建FileInfo，而不是删除
+}
+
+// Verify 检查当前命名空间及其所有子命名空间，在根命名空间上调用时还校验Badger的所有SST文件
+// 可以在线运行，但检查期间被修改的条目可能被误报；修复会删除数据，建议在节点停止后进行
+func (c *badgerCache) Verify(ctx context.Context, opts VerifyOptions) (*VerifyReport, error) {
+	return c.verify(ctx, opts, nil)
+}
+
+// verify 执行Verify和Repair的检查，rebuild非空时按文件数据重建FileInfo
+func (c *badgerCache) verify(ctx context.Context, opts VerifyOptions, rebuild *RepairOptions) (*VerifyReport, error) {
+	report := &VerifyReport{}
+	if c.prefix == "" {
+		err := c.store.withDB(func(db *badger.DB) error {
+			return db.VerifyChecksum()
+		})
+		if err != nil {
+			report.Problems = append(report.Problems, VerifyProblem{Kind: ProblemChecksumFailure, Detail: err.Error()})
+		}
+	}
+	if err := c.verifyAll(ctx, "", opts, rebuild, report); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// verifyAll 检查当前命名空间及其所有子命名空间，path为报告中的命名空间
+func (c *badgerCache) verifyAll(ctx context.Context, path string, opts VerifyOptions, rebuild *RepairOptions, report *VerifyReport) error {
+	v := &verifier{c: c, path: path, opts: opts, report: report, blobs: make(map[string]bool), rebuild: rebuild}
+	if err := v.run(ctx); err != nil {
+		return err
+	}
+
+	names, err := c.registeredNamespaces()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		child := name
+		if path != "" {
+			child = path + "/" + name
+		}
+		if err := c.Namespace(name).(*badgerCache).verifyAll(ctx, child, opts, rebuild, report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run 在只读事务中扫描记录，检查文件系统上的孤立文件，然后执行修复并核对统计信息
+func (v *verifier) run(ctx context.Context) error {
+	c := v.c
+	err := c.store.view(func(txn *badger.Txn) error {
+		for _, trash := range []bool{false, true} {
+			if err := v.scanInfo(ctx, txn, trash); err != nil {
+				return err
+			}
+			if c.blobs == nil {
+				if err := v.scanData(ctx, txn, trash); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan entries: %w", err)
+	}
+	if c.blobs != nil {
+		if err := v.scanBlobs(ctx); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range v.repairs {
+		problem := &v.report.Problems[r.problem]
+		if err := r.apply(); errors.Is(err, errEntryChanged) {
+			problem.Detail += "; repair skipped: " + err.Error()
+			continue
+		} else if err != nil {
+			problem.Detail += "; repair failed: " + err.Error()
+			continue
+		}
+		problem.Repaired = true
+		v.files += r.files
+		v.size += r.size
+	}
+	return v.verifyStats()
+}
+
+// problem 记录问题，repair非空且需要修复时在扫描结束后执行
+func (v *verifier) problem(key string, trash bool, kind ProblemKind, detail string, repair *verifyRepair) {
+	p := VerifyProblem{
+		Namespace: v.path,
+		Key:       key,
+		Trash:     trash,
+		Kind:      kind,
+		Detail:    detail,
+	}
+	if repair != nil {
+		p.Action = repair.action
+	}
+	v.report.Problems = append(v.report.Problems, p)
+	if repair != nil && v.opts.Repair {
+		repair.problem = len(v.report.Problems) - 1
+		v.repairs = append(v.repairs, *repair)
+	}
+}
+
+// scanInfo 检查每条FileInfo（trash为true时为回收站记录）都能解析，并且有完整可读、大小一致的文件数据
+func (v *verifier) scanInfo(ctx context.Context, txn *badger.Txn, trash bool) error {
+	prefix := []byte(v.c.prefix + fileInfoPrefix)
+	if trash {
+		prefix = []byte(v.c.prefix + trashInfoPrefix)
+	}
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Rewind(); it.ValidForPrefix(prefix); it.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		key := string(it.Item().Key()[len(prefix):])
+		v.report.Entries++
+		if _, _, path := v.c.entryKeys(key, trash); path != "" {
+			v.blobs[path] = true
+		}
+
+		// 回收站记录内嵌FileInfo，字段相同
+		info := &FileInfo{}
+		if err := it.Item().Value(func(val []byte) error {
+			return json.Unmarshal(val, info)
+		}); err != nil {
+			v.count(trash, 1, 0)
+			detail, repair := err.Error(), v.removeEntry(txn, key, trash, 0)
+			if d := v.readForRebuild(txn, key, trash); d != nil {
+				detail += "; " + d.describe(key)
+				repair = v.rebuildInfo(txn, key, d, true)
+			}
+			v.problem(key, trash, ProblemCorruptInfo, detail, repair)
+			continue
+		}
+		v.count(trash, 1, info.Size)
+
+		size, err := v.readData(txn, key, trash, io.Discard)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			v.problem(key, trash, ProblemMissingData, "FileInfo without file data", v.removeEntry(txn, key, trash, info.Size))
+		case err != nil:
+			v.problem(key, trash, ProblemUnreadableData, err.Error(), v.removeEntry(txn, key, trash, info.Size))
+		case size != info.Size:
+			v.report.Bytes += size
+			v.problem(key, trash, ProblemSizeMismatch, fmt.Sprintf("FileInfo records %d bytes, data has %d", info.Size, size), v.fixSize(txn, key, trash, info.Size, size))
+		default:
+			v.report.Bytes += size
+		}
+	}
+	return nil
+}
+
+// count 累计FileInfo记录的文件数和大小，回收站中的条目不计入统计信息
+func (v *verifier) count(trash bool, files, size int64) {
+	if !trash {
+		v.files += files
+		v.size += size
+	}
+}
+
+// readData 把文件数据完整写入w并返回大小，文件数据不存在时返回ErrNotFound
+// Badger中的数据在读取时校验CRC，文件系统上的数据没有校验和，只检查能否完整读取
+func (v *verifier) readData(txn *badger.Txn, key string, trash bool, w io.Writer) (int64, error) {
+	_, dataKey, path := v.c.entryKeys(key, trash)
+	if path != "" {
+		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			return 0, ErrNotFound
+		}
+		if err != nil {
+			return 0, err
+		}
+		defer file.Close()
+		return io.Copy(w, file)
+	}
+
+	item, err := txn.Get(dataKey)
+	if err == badger.ErrKeyNotFound {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	err = item.Value(func(val []byte) error {
+		n, err := w.Write(val)
+		size = int64(n)
+		return err
+	})
+	return size, err
+}
+
+// scanData 检查Badger中的每份文件数据（trash为true时为回收站中的数据）都有对应的FileInfo
+func (v *verifier) scanData(ctx context.Context, txn *badger.Txn, trash bool) error {
+	prefix := []byte(v.c.prefix + fileDataPrefix)
+	if trash {
+		prefix = []byte(v.c.prefix + trashDataPrefix)
+	}
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Rewind(); it.ValidForPrefix(prefix); it.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		key := string(it.Item().Key()[len(prefix):])
+		infoKey, dataKey, _ := v.c.entryKeys(key, trash)
+		_, err := txn.Get(infoKey)
+		if err == nil {
+			continue
+		}
+		if err != badger.ErrKeyNotFound {
+			return err
+		}
+		unchanged := v.unchanged(txn, key, trash)
+		detail, repair := "file data without FileInfo", &verifyRepair{action: RepairRemove, apply: func() error {
+			return v.c.store.update(func(txn *badger.Txn) error {
+				if err := unchanged(txn); err != nil {
+					return err
+				}
+				return txn.Delete(dataKey)
+			})
+		}}
+		if d := v.readForRebuild(txn, key, trash); d != nil {
+			detail += "; " + d.describe(key)
+			repair = v.rebuildInfo(txn, key, d, false)
+		}
+		v.problem(key, trash, ProblemOrphanedData, detail, repair)
+	}
+	return nil
+}
+
+// scanBlobs 检查文件系统后端中当前命名空间目录下的文件都有对应的记录，
+// 在根命名空间上还检查写入中断留下的临时文件
+func (v *verifier) scanBlobs(ctx context.Context) error {
+	return v.c.walkOrphanBlobs(ctx, v.blobs, func(path, what string) error {
+		v.problem("", false, ProblemOrphanedData, fmt.Sprintf("%s %s", what, path), &verifyRepair{action: RepairRemove, apply: func() error {
+			return os.RemoveAll(path)
+		}})
+		return nil
+	})
+}
+
+// removeEntry 返回删除条目的FileInfo和文件数据的修复，size为FileInfo记录的大小
+func (v *verifier) removeEntry(txn *badger.Txn, key string, trash bool, size int64) *verifyRepair {
+	infoKey, dataKey, path := v.c.entryKeys(key, trash)
+	unchanged := v.unchanged(txn, key, trash)
+	repair := &verifyRepair{action: RepairRemove, apply: func() error {
+		defer v.c.lockBlob(key)()
+		err := v.c.store.update(func(txn *badger.Txn) error {
+			if err := unchanged(txn); err != nil {
+				return err
+			}
+			if path == "" {
+				if err := txn.Delete(dataKey); err != nil {
+					return err
+				}
+			}
+			return txn.Delete(infoKey)
+		})
+		if err != nil || path == "" {
+			return err
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}}
+	if !trash {
+		repair.files, repair.size = -1, -size
+	}
+	return repair
+}
+
+// fixSize 返回按实际数据大小重写FileInfo的修复
+func (v *verifier) fixSize(txn *badger.Txn, key string, trash bool, recorded, actual int64) *verifyRepair {
+	infoKey, _, _ := v.c.entryKeys(key, trash)
+	unchanged := v.unchanged(txn, key, trash)
+	repair := &verifyRepair{action: RepairResize, apply: func() error {
+		return v.c.store.update(func(txn *badger.Txn) error {
+			if err := unchanged(txn); err != nil {
+				return err
+			}
+			item, err := txn.Get(infoKey)
+			if err != nil {
+				return err
+			}
+			// 回收站记录需要保留删除时间和永久删除时间
+			var record interface{}
+			var info *FileInfo
+			if trash {
+				r := &TrashInfo{}
+				record, info = r, &r.FileInfo
+			} else {
+				info = &FileInfo{}
+				record = info
+			}
+			if err := item.Value(func(val []byte) error {
+				return json.Unmarshal(val, record)
+			}); err != nil {
+				return err
+			}
+			info.Size = actual
+			data, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			return txn.Set(infoKey, data)
+		})
+	}}
+	if !trash {
+		repair.size = actual - recorded
+	}
+	return repair
+}
+
+// entryState 条目的FileInfo和文件数据的版本
+type entryState struct {
+	info, data uint64    // Badger中记录的版本，0表示不存在
+	blob       time.Time // 文件系统后端中文件的修改时间，不存在时为零值
+}
+
+// entryState 在txn中读取条目的FileInfo和文件数据的版本
+func (c *badgerCache) entryState(txn *badger.Txn, key string, trash bool) (entryState, error) {
+	infoKey, dataKey, path := c.entryKeys(key, trash)
+	var state entryState
+	var err error
+	if state.info, err = itemVersion(txn, infoKey); err != nil {
+		return state, err
+	}
+	if path == "" {
+		state.data, err = itemVersion(txn, dataKey)
+		return state, err
+	}
+	stat, err := os.Stat(path)
+	if err == nil {
+		state.blob = stat.ModTime()
+	} else if !os.IsNotExist(err) {
+		return state, err
+	}
+	return state, nil
+}
+
+// itemVersion 返回键的版本，不存在时返回0
+func itemVersion(txn *badger.Txn, key []byte) (uint64, error) {
+	item, err := txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return item.Version(), nil
+}
+
+// unchanged 记录条目在扫描事务txn中的状态，返回的函数在修复的写事务中重新读取，
+// 条目在扫描之后被写入、删除或移入回收站时返回errEntryChanged，与deleteOrphans一样不修改并发写入的条目
+func (v *verifier) unchanged(txn *badger.Txn, key string, trash bool) func(*badger.Txn) error {
+	scanned, scanErr := v.c.entryState(txn, key, trash)
+	return func(txn *badger.Txn) error {
+		if scanErr != nil {
+			return scanErr
+		}
+		current, err := v.c.entryState(txn, key, trash)
+		if err != nil {
+			return err
+		}
+		if current.info != scanned.info || current.data != scanned.data || !current.blob.Equal(scanned.blob) {
+			return errEntryChanged
+		}
+		return nil
+	}
+}
+
+// verifyStats 比较统计信息与FileInfo记录的文件数和总大小，需要修复时按实际值重写
+func (v *verifier) verifyStats() error {
+	c := v.c
+	c.mu.RLock()
+	files, size := c.stats.TotalFiles, c.stats.TotalSize
+	c.mu.RUnlock()
+	if files == v.files && size == v.size {
+		return nil
+	}
+
+	v.problem("", false, ProblemStatsMismatch,
+		fmt.Sprintf("stats record %d files (%d bytes), found %d files (%d bytes)", files, size, v.files, v.size), nil)
+	v.report.Problems[len(v.report.Problems)-1].Action = RepairRecount
+	if !v.opts.Repair {
+		return nil
+	}
+	c.mu.Lock()
+	c.stats.TotalFiles = v.files
+	c.stats.TotalSize = v.size
+	c.mu.Unlock()
+	if err := c.saveStats(); err != nil {
+		return fmt.Errorf("failed to save stats: %w", err)
+	}
+	v.report.Problems[len(v.report.Problems)-1].Repaired = true
+	return nil
+}
+
+// Verify 依次检查所有分片，问题的描述前加上分片序号
+func (c *shardedCache) Verify(ctx context.Context, opts VerifyOptions) (*VerifyReport, error) {
+	report := &VerifyReport{}
+	for i, shard := range c.shards {
+		verifier, ok := shard.(Verifier)
+		if !ok {
+			return report, fmt.Errorf("verification is not supported")
+		}
+		r, err := verifier.Verify(ctx, opts)
+		if r != nil {
+			report.addShard(i, r)
+		}
+		if err != nil {
+			return report, fmt.Errorf("failed to verify shard %d: %w", i, err)
+		}
+	}
+	return report, nil
+}
+
+// addShard 合并分片的检查结果，问题的描述前加上分片序号
+func (r *VerifyReport) addShard(shard int, other *VerifyReport) {
+	r.Entries += other.Entries
+	r.Bytes += other.Bytes
+	for _, p := range other.Problems {
+		p.Detail = fmt.Sprintf("shard %d: %s", shard, p.Detail)
+		r.Problems = append(r.Problems, p)
+	}
+}
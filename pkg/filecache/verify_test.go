@@ -0,0 +1,204 @@
+package filecache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// problemKinds 返回报告中按命名空间、键排序后的问题类型，形如 tenant/key:kind
+func problemKinds(report *VerifyReport) []string {
+	var kinds []string
+	for _, p := range report.Problems {
+		kinds = append(kinds, p.Namespace+"/"+p.Key+":"+string(p.Kind))
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func TestVerify(t *testing.T) {
+	cache, err := NewBadgerCache(&Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+		SoftDelete:      true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	root := cache.(*badgerCache)
+	tenant := cache.Namespace("tenant").(*badgerCache)
+	for _, c := range []*badgerCache{root, tenant} {
+		for _, key := range []string{"ok", "missing", "resized", "corrupt", "trashed"} {
+			if err := c.Set(ctx, key, strings.NewReader("hello"), "text/plain", time.Hour); err != nil {
+				t.Fatalf("Failed to set %s: %v", key, err)
+			}
+		}
+		if err := c.Delete(ctx, "trashed"); err != nil {
+			t.Fatalf("Failed to delete file: %v", err)
+		}
+	}
+
+	t.Run("Healthy", func(t *testing.T) {
+		report, err := cache.(Verifier).Verify(ctx, VerifyOptions{})
+		if err != nil {
+			t.Fatalf("Failed to verify: %v", err)
+		}
+		if len(report.Problems) != 0 || report.Entries != 10 || report.Bytes != 50 {
+			t.Errorf("Expected 10 healthy entries, got %+v", report)
+		}
+	})
+
+	// 模拟崩溃留下的不一致记录
+	err = root.store.update(func(txn *badger.Txn) error {
+		if err := txn.Delete(root.dataKey("missing")); err != nil {
+			return err
+		}
+		if err := txn.Set(root.dataKey("resized"), []byte("hello, world")); err != nil {
+			return err
+		}
+		if err := txn.Set(root.infoKey("corrupt"), []byte("{")); err != nil {
+			return err
+		}
+		if err := txn.Set(root.dataKey("orphan"), []byte("data")); err != nil {
+			return err
+		}
+		if err := txn.Delete(root.trashInfoKey("trashed")); err != nil {
+			return err
+		}
+		return txn.Delete(tenant.dataKey("missing"))
+	})
+	if err != nil {
+		t.Fatalf("Failed to damage cache: %v", err)
+	}
+	tenant.updateStatsAfterSet(100)
+
+	want := []string{
+		"/:stats_mismatch",
+		"/corrupt:corrupt_info",
+		"/missing:missing_data",
+		"/orphan:orphaned_data",
+		"/resized:size_mismatch",
+		"/trashed:orphaned_data",
+		"tenant/:stats_mismatch",
+		"tenant/missing:missing_data",
+	}
+
+	t.Run("Report", func(t *testing.T) {
+		report, err := cache.(Verifier).Verify(ctx, VerifyOptions{})
+		if err != nil {
+			t.Fatalf("Failed to verify: %v", err)
+		}
+		if got := problemKinds(report); strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Expected problems %v, got %v", want, got)
+		}
+		if report.Unrepaired() != len(want) {
+			t.Errorf("Expected nothing to be repaired, got %d unrepaired", report.Unrepaired())
+		}
+		if ok, _ := cache.Exists(ctx, "missing"); !ok {
+			t.Error("Expected verify without repair to keep entries")
+		}
+	})
+
+	t.Run("Repair", func(t *testing.T) {
+		report, err := cache.(Verifier).Verify(ctx, VerifyOptions{Repair: true})
+		if err != nil {
+			t.Fatalf("Failed to repair: %v", err)
+		}
+		if got := problemKinds(report); strings.Join(got, ",") != strings.Join(want, ",") || report.Unrepaired() != 0 {
+			t.Errorf("Expected all problems to be repaired, got %+v", report.Problems)
+		}
+
+		info, err := cache.GetInfo(ctx, "resized")
+		if err != nil || info.Size != 12 {
+			t.Errorf("Expected FileInfo to be resized to 12 bytes, got %+v %v", info, err)
+		}
+		for _, key := range []string{"missing", "corrupt"} {
+			if ok, _ := cache.Exists(ctx, key); ok {
+				t.Errorf("Expected %s to be removed", key)
+			}
+		}
+		stats, _ := cache.Stats()
+		if stats.TotalFiles != 2 || stats.TotalSize != 17 {
+			t.Errorf("Expected stats to be recomputed, got %d files %d bytes", stats.TotalFiles, stats.TotalSize)
+		}
+
+		report, err = cache.(Verifier).Verify(ctx, VerifyOptions{})
+		if err != nil || len(report.Problems) != 0 {
+			t.Errorf("Expected no problems after repair, got %+v %v", report, err)
+		}
+	})
+}
+
+func TestVerifyFSBlob(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewFSBlobCache(&Config{
+		DataDir:         dir,
+		MaxCacheSize:    1024 * 1024,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	c := cache.(*badgerCache)
+	for _, key := range []string{"ok", "missing", "truncated"} {
+		if err := cache.Set(ctx, key, strings.NewReader("hello"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set %s: %v", key, err)
+		}
+	}
+	if err := os.Remove(c.blobPath("missing")); err != nil {
+		t.Fatalf("Failed to remove blob: %v", err)
+	}
+	if err := os.Truncate(c.blobPath("truncated"), 2); err != nil {
+		t.Fatalf("Failed to truncate blob: %v", err)
+	}
+	// 没有FileInfo的文件只有足够旧时才视为孤立文件
+	old := time.Now().Add(-2 * verifyOrphanAge)
+	orphan := c.blobPath("orphan")
+	leftover := filepath.Join(c.blobs.tmpDir(), "blob-1")
+	recent := filepath.Join(c.blobs.tmpDir(), "blob-2")
+	for _, path := range []string{orphan, leftover, recent} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	for _, path := range []string{orphan, leftover} {
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatalf("Failed to change file times: %v", err)
+		}
+	}
+
+	report, err := cache.(Verifier).Verify(ctx, VerifyOptions{Repair: true})
+	if err != nil {
+		t.Fatalf("Failed to verify: %v", err)
+	}
+	want := []string{"/:orphaned_data", "/:orphaned_data", "/:stats_mismatch", "/missing:missing_data", "/truncated:size_mismatch"}
+	if got := problemKinds(report); strings.Join(got, ",") != strings.Join(want, ",") || report.Unrepaired() != 0 {
+		t.Errorf("Expected problems %v to be repaired, got %+v", want, report.Problems)
+	}
+	for path, exists := range map[string]bool{orphan: false, leftover: false, recent: true} {
+		if _, err := os.Stat(path); (err == nil) != exists {
+			t.Errorf("Expected %s to exist: %v, got %v", path, exists, err)
+		}
+	}
+	if info, err := cache.GetInfo(ctx, "truncated"); err != nil || info.Size != 2 {
+		t.Errorf("Expected FileInfo to be resized to 2 bytes, got %+v %v", info, err)
+	}
+}
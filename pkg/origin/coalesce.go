@@ -0,0 +1,139 @@
+package origin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// fetchResult 回源结果，响应体在后台写入body
+type fetchResult struct {
+	entry   *entry
+	body    *spool
+	size    int64  // 响应体长度，上游没有给出时为-1
+	status  string // X-Cache头的值
+	variant string // 响应在缓存中对应的键，带Vary时为发起请求的变体键
+	shared  bool   // 响应可缓存，可以返回给等待同一个键的其他请求
+}
+
+// length 返回响应体长度，上游没有给出时等待响应体写完
+func (res *fetchResult) length() (int64, error) {
+	if res.size >= 0 {
+		return res.size, nil
+	}
+	return res.body.wait()
+}
+
+// flight 一次进行中的回源，同一个键的并发未命中共享它的结果
+type flight struct {
+	done chan struct{} // 收到响应头后关闭
+	res  *fetchResult
+	err  error
+	refs int // 仍在使用结果的请求数，加上flights中的一个引用，由Proxy.mu保护
+}
+
+// fetch 回源并返回响应。同一个缓存键的并发未命中只回源一次，其他请求等待并共享可缓存的结果，
+// 同时读取正在下载的响应体；结果不可缓存、上游出错或Vary对应的变体不同时，等待的请求各自回源。
+// Range请求只与范围相同的请求共享回源
+func (p *Proxy) fetch(w http.ResponseWriter, r *http.Request, key string) {
+	flightKey := key
+	if rng := r.Header.Get("Range"); rng != "" {
+		flightKey += "\x00" + rng + "\x00" + r.Header.Get("If-Range")
+	}
+	res, joined, release, err := p.coalesce(r.Context(), flightKey, func() (*fetchResult, error) {
+		return p.pull(r, key)
+	})
+	defer release()
+	if joined {
+		if r.Context().Err() != nil {
+			return
+		}
+		if err != nil || !res.shared || res.variant != p.variant(key, res.entry, r.Header) {
+			if res, err = p.pull(r, key); err == nil {
+				defer res.body.release()
+			}
+		}
+	}
+	if err != nil {
+		if errors.Is(err, ErrCircuitOpen) && p.serveUnavailable(w, r, key) {
+			return
+		}
+		p.error(w, r, err)
+		return
+	}
+	p.writeResult(w, r, res)
+}
+
+// coalesce 同一个flightKey同时只执行一次pull：第一个请求执行，其他请求等待它的结果，joined为true；
+// 可缓存的响应体写完之前到达的请求也共享这次回源。等待期间ctx结束时返回ctx的错误。调用方用完结果后调用release
+func (p *Proxy) coalesce(ctx context.Context, flightKey string, pull func() (*fetchResult, error)) (res *fetchResult, joined bool, release func(), err error) {
+	f, leader := p.joinFlight(flightKey)
+	release = func() { p.releaseFlight(f) }
+	if leader {
+		f.res, f.err = pull()
+		p.finishFlight(flightKey, f)
+		return f.res, false, release, f.err
+	}
+	select {
+	case <-f.done:
+		return f.res, true, release, f.err
+	case <-ctx.Done():
+		return nil, true, release, ctx.Err()
+	}
+}
+
+// variant 返回请求头h对应的响应在缓存中的键
+func (p *Proxy) variant(key string, e *entry, h http.Header) string {
+	if vary, _ := varyHeaders(e.Header); len(vary) > 0 {
+		return variantKey(key, vary, h)
+	}
+	return key
+}
+
+// joinFlight 加入键上进行中的回源，没有时创建一个并返回true，由调用方回源
+func (p *Proxy) joinFlight(key string) (*flight, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if f, ok := p.flights[key]; ok {
+		f.refs++
+		return f, false
+	}
+	f := &flight{done: make(chan struct{}), refs: 2}
+	p.flights[key] = f
+	return f, true
+}
+
+// finishFlight 收到响应头，唤醒等待的请求。可缓存的响应在响应体写完（已写入缓存）后才从flights中移除，
+// 之后到达的请求命中缓存；其他结果立即移除，之后到达的请求重新回源
+func (p *Proxy) finishFlight(key string, f *flight) {
+	close(f.done)
+	if f.err != nil || !f.res.shared {
+		p.removeFlight(key, f)
+		return
+	}
+	go func() {
+		f.res.body.wait()
+		p.removeFlight(key, f)
+	}()
+}
+
+// removeFlight 从flights中移除并释放它持有的引用
+func (p *Proxy) removeFlight(key string, f *flight) {
+	p.mu.Lock()
+	if p.flights[key] == f {
+		delete(p.flights, key)
+	}
+	p.mu.Unlock()
+	p.releaseFlight(f)
+}
+
+// releaseFlight 释放一个引用，最后一个引用释放响应体
+func (p *Proxy) releaseFlight(f *flight) {
+	p.mu.Lock()
+	f.refs--
+	last := f.refs == 0
+	p.mu.Unlock()
+	if last && f.res != nil {
+		f.res.body.release()
+	}
+}
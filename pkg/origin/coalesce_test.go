@@ -0,0 +1,88 @@
+package origin
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestCoalesce(t *testing.T) {
+	const clients = 50
+	var gate atomic.Value // 关闭后上游才返回响应
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		<-gate.Load().(chan struct{})
+		if r.URL.Path == "/private" {
+			w.Header().Set("Cache-Control", "max-age=0")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		io.WriteString(w, "payload "+r.URL.Path)
+	})
+
+	// concurrent 并发请求target，等所有请求都在等待同一次回源后放行上游
+	concurrent := func(t *testing.T, proxy *Proxy, target string) {
+		t.Helper()
+		release := make(chan struct{})
+		gate.Store(release)
+		var wg sync.WaitGroup
+		for i := 0; i < clients; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, body := get(t, proxy, http.MethodGet, target)
+				if resp.StatusCode != http.StatusOK || body != "payload "+target {
+					t.Errorf("Expected payload, got %d %q", resp.StatusCode, body)
+				}
+			}()
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			proxy.mu.Lock()
+			f := proxy.flights[target]
+			joined := f != nil && f.refs == clients+1 // 加上flights持有的引用
+			proxy.mu.Unlock()
+			if joined {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("Timed out waiting for clients to join the fetch")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		close(release)
+		wg.Wait()
+	}
+
+	t.Run("Cacheable", func(t *testing.T) {
+		cache := filecache.NewMemoryCache(1 << 20)
+		defer cache.Close()
+		proxy, err := NewProxy(cache, Options{Upstream: upstream.URL})
+		if err != nil {
+			t.Fatalf("Failed to create proxy: %v", err)
+		}
+		before := upstream.requests.Load()
+		concurrent(t, proxy, "/shared")
+		if n := upstream.requests.Load() - before; n != 1 {
+			t.Errorf("Expected 1 upstream request, got %d", n)
+		}
+	})
+
+	t.Run("Uncacheable", func(t *testing.T) {
+		cache := filecache.NewMemoryCache(1 << 20)
+		defer cache.Close()
+		proxy, err := NewProxy(cache, Options{Upstream: upstream.URL})
+		if err != nil {
+			t.Fatalf("Failed to create proxy: %v", err)
+		}
+		before := upstream.requests.Load()
+		concurrent(t, proxy, "/private")
+		if n := upstream.requests.Load() - before; n != clients {
+			t.Errorf("Expected uncacheable response not to be shared, got %d upstream requests", n)
+		}
+	})
+}
@@ -0,0 +1,301 @@
+package origin
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// encodingSeparator 压缩变体键 = 原始条目的键 + encodingSeparator + 编码名称
+const encodingSeparator = "#enc:"
+
+const (
+	defaultCompressMinSize = 1 << 10
+	defaultCompressMaxSize = 32 << 20
+)
+
+// defaultCompressTypes 默认压缩的Content-Type
+var defaultCompressTypes = []string{
+	"text/*",
+	"application/javascript",
+	"application/json",
+	"application/manifest+json",
+	"application/wasm",
+	"application/xml",
+	"application/xhtml+xml",
+	"application/rss+xml",
+	"application/atom+xml",
+	"image/svg+xml",
+	"font/otf",
+	"font/ttf",
+}
+
+// Encoder 返回把压缩结果写入w的Writer，Close时写出剩余的数据
+type Encoder func(w io.Writer) (io.WriteCloser, error)
+
+// Compression 在边缘压缩可压缩的响应：命中时按客户端的Accept-Encoding选择编码，
+// 压缩结果作为变体与原始响应一起缓存，之后的请求直接返回，不再重复压缩
+type Compression struct {
+	// Encodings 按偏好排序的编码，默认["gzip"]；gzip以外的编码（例如br）需要在Encoders中提供实现
+	Encodings []string `json:"encodings,omitempty"`
+
+	// Types 压缩的Content-Type，以"*"结尾时按前缀匹配，默认为常见的文本类型
+	Types []string `json:"types,omitempty"`
+
+	// MinSize 小于这个长度的响应不压缩，默认1KB
+	MinSize int64 `json:"min_size,omitempty"`
+
+	// MaxSize 大于这个长度的响应不压缩，默认32MB
+	MaxSize int64 `json:"max_size,omitempty"`
+
+	// Level gzip压缩级别，0表示默认级别
+	Level int `json:"level,omitempty"`
+
+	// Encoders 编码名称到实现的映射，覆盖内置的gzip
+	Encoders map[string]Encoder `json:"-"`
+}
+
+// validate 检查选项并填充默认值
+func (c *Compression) validate() error {
+	if len(c.Encodings) == 0 {
+		c.Encodings = []string{"gzip"}
+	}
+	if len(c.Types) == 0 {
+		c.Types = defaultCompressTypes
+	}
+	if c.MinSize < 0 || c.MaxSize < 0 {
+		return fmt.Errorf("compression size cannot be negative")
+	}
+	if c.MinSize == 0 {
+		c.MinSize = defaultCompressMinSize
+	}
+	if c.MaxSize == 0 {
+		c.MaxSize = defaultCompressMaxSize
+	}
+	if c.Level != 0 {
+		if _, err := gzip.NewWriterLevel(io.Discard, c.Level); err != nil {
+			return fmt.Errorf("invalid gzip level %d", c.Level)
+		}
+	}
+	for i, encoding := range c.Encodings {
+		encoding = strings.ToLower(encoding)
+		c.Encodings[i] = encoding
+		if encoding == "identity" || encoding == "*" {
+			return fmt.Errorf("invalid compression encoding %q", encoding)
+		}
+		if c.encoder(encoding) == nil {
+			return fmt.Errorf("no encoder for compression encoding %q", encoding)
+		}
+	}
+	return nil
+}
+
+// encoder 返回编码的实现，没有时返回nil
+func (c *Compression) encoder(encoding string) Encoder {
+	if encoder, ok := c.Encoders[encoding]; ok {
+		return encoder
+	}
+	if encoding == "gzip" {
+		level := c.Level
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriterLevel(w, level)
+		}
+	}
+	return nil
+}
+
+// compressible 返回条目是否应该压缩：未编码的200响应、类型匹配、长度在范围内且没有no-transform
+func (c *Compression) compressible(e *entry, size int64) bool {
+	if e.Status != http.StatusOK || size < c.MinSize || size > c.MaxSize {
+		return false
+	}
+	if encoding := e.Header.Get("Content-Encoding"); encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return false
+	}
+	if _, ok := parseCacheControl(e.Header)["no-transform"]; ok {
+		return false
+	}
+	mimeType, _, err := mime.ParseMediaType(e.Header.Get("Content-Type"))
+	return err == nil && matchParam(c.Types, mimeType)
+}
+
+// compressible 返回条目是否在边缘压缩；需要处理ESI的HTML在返回时才拼接，不压缩
+func (p *Proxy) compressible(e *entry, size int64) bool {
+	if p.opts.ESI != nil && p.opts.ESI.applies(e, size) {
+		return false
+	}
+	return p.opts.Compression != nil && p.opts.Compression.compressible(e, size)
+}
+
+// negotiate 按客户端的Accept-Encoding选择q值最高的编码，q值相同时按Encodings的顺序，都不接受时返回空
+func (c *Compression) negotiate(h http.Header) string {
+	accepted := make(map[string]float64)
+	for _, v := range h.Values("Accept-Encoding") {
+		for _, part := range strings.Split(v, ",") {
+			coding, params, _ := strings.Cut(part, ";")
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if coding != "" {
+				accepted[coding] = quality(params)
+			}
+		}
+	}
+	best, bestQ := "", 0.0
+	for _, encoding := range c.Encodings {
+		q, ok := accepted[encoding]
+		if !ok {
+			q = accepted["*"]
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// quality 返回参数中的q值，没有时为1
+func quality(params string) float64 {
+	for _, param := range strings.Split(params, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if strings.EqualFold(name, "q") {
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				return 0
+			}
+			return q
+		}
+	}
+	return 1
+}
+
+// addVary 在响应头的Vary中加入name
+func addVary(h http.Header, name string) {
+	for _, line := range h.Values("Vary") {
+		for _, v := range strings.Split(line, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), name) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", name)
+}
+
+// encodedETag 返回压缩变体的ETag，与原始响应的ETag区分
+func encodedETag(etag, encoding string) string {
+	if etag == "" || !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return etag[:len(etag)-1] + "-" + encoding + `"`
+}
+
+// serveEncoded 客户端接受压缩时返回key上条目的压缩变体，变体不存在或已过时时先压缩并写入缓存；
+// 不需要压缩、其他请求正在压缩或压缩失败时返回false，由调用方返回原始响应
+func (p *Proxy) serveEncoded(w http.ResponseWriter, r *http.Request, key string, e *entry, size int64) bool {
+	c := p.opts.Compression
+	if c == nil || r.Header.Get("Range") != "" || !p.compressible(e, size) {
+		return false
+	}
+	encoding := c.negotiate(r.Header)
+	if encoding == "" {
+		return false
+	}
+	encodedKey := key + encodingSeparator + encoding
+	if p.serveEncodedEntry(w, r, encodedKey, e) {
+		return true
+	}
+
+	p.mu.Lock()
+	if p.compressing[encodedKey] {
+		p.mu.Unlock()
+		return false
+	}
+	p.compressing[encodedKey] = true
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.compressing, encodedKey)
+		p.mu.Unlock()
+	}()
+
+	if err := p.compress(r.Context(), key, encodedKey, encoding); err != nil {
+		return false
+	}
+	return p.serveEncodedEntry(w, r, encodedKey, e)
+}
+
+// serveEncodedEntry 返回缓存中的压缩变体，变体不存在或不是由原始条目e压缩的时返回false
+func (p *Proxy) serveEncodedEntry(w http.ResponseWriter, r *http.Request, encodedKey string, e *entry) bool {
+	reader, info, err := p.cache.Get(r.Context(), encodedKey)
+	if err != nil {
+		return false
+	}
+	defer reader.Close()
+	encoded, body, headerSize, err := readEntry(reader)
+	if err != nil || encoded == nil || !encoded.StoredAt.Equal(e.StoredAt) {
+		return false
+	}
+	p.writeEntry(w, r, encoded, body, info.Size-headerSize, "HIT")
+	return true
+}
+
+// compress 读取key上的原始条目，压缩后写入encodedKey，TTL与原始条目剩余的TTL相同
+func (p *Proxy) compress(ctx context.Context, key, encodedKey, encoding string) error {
+	reader, info, err := p.cache.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	e, body, _, err := readEntry(reader)
+	if err != nil {
+		return err
+	}
+	if e == nil {
+		return fmt.Errorf("entry %s was not written by the proxy", key)
+	}
+	ttl, ok := info.RemainingTTL()
+	if !ok {
+		return fmt.Errorf("entry %s has expired", key)
+	}
+
+	header := e.Header.Clone()
+	header.Set("Content-Encoding", encoding)
+	addVary(header, "Accept-Encoding")
+	if etag := header.Get("ETag"); etag != "" {
+		header.Set("ETag", encodedETag(etag, encoding))
+	}
+	encoded := &entry{
+		Status:     e.Status,
+		Header:     header,
+		StoredAt:   e.StoredAt,
+		InitialAge: e.InitialAge,
+		Tags:       e.Tags,
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		zw, err := p.opts.Compression.encoder(encoding)(pw)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		if _, err := io.Copy(zw, body); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(zw.Close())
+	}()
+	err = p.store(ctx, encodedKey, encoded, pr, ttl)
+	// 写入缓存提前失败时让压缩的goroutine退出，之后才能关闭原始条目的reader
+	pr.CloseWithError(err)
+	<-done
+	return err
+}
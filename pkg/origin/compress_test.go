@@ -0,0 +1,204 @@
+package origin
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// upperWriter 测试用的编码，把内容转为大写
+type upperWriter struct{ w io.Writer }
+
+func (u upperWriter) Write(p []byte) (int, error) {
+	return u.w.Write([]byte(strings.ToUpper(string(p))))
+}
+
+func (u upperWriter) Close() error { return nil }
+
+func TestNegotiateEncoding(t *testing.T) {
+	c := &Compression{
+		Encodings: []string{"br", "gzip"},
+		Encoders:  map[string]Encoder{"br": func(w io.Writer) (io.WriteCloser, error) { return upperWriter{w}, nil }},
+	}
+	if err := c.validate(); err != nil {
+		t.Fatalf("Failed to validate compression: %v", err)
+	}
+	tests := []struct {
+		accept, want string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"br;q=0, gzip;q=0", ""},
+		{"*", "br"},
+		{"*;q=0.1, gzip;q=0.5", "gzip"},
+		{"GZIP", "gzip"},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		if tt.accept != "" {
+			h.Set("Accept-Encoding", tt.accept)
+		}
+		if got := c.negotiate(h); got != tt.want {
+			t.Errorf("Expected %q for Accept-Encoding %q, got %q", tt.want, tt.accept, got)
+		}
+	}
+
+	for _, invalid := range []*Compression{
+		{Encodings: []string{"br"}},
+		{Encodings: []string{"identity"}},
+		{Level: 42},
+		{MinSize: -1},
+	} {
+		if err := invalid.validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", invalid)
+		}
+	}
+}
+
+func TestCompression(t *testing.T) {
+	script := strings.Repeat("console.log('hello');\n", 200)
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app.js":
+			w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+			w.Header().Set("ETag", `"v1"`)
+			io.WriteString(w, script)
+		case "/small.js":
+			w.Header().Set("Content-Type", "application/javascript")
+			io.WriteString(w, "1")
+		case "/image.png":
+			w.Header().Set("Content-Type", "image/png")
+			io.WriteString(w, script)
+		}
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{
+		Upstream:   upstream.URL,
+		DefaultTTL: time.Hour,
+		Compression: &Compression{
+			Encodings: []string{"br", "gzip"},
+			Encoders:  map[string]Encoder{"br": func(w io.Writer) (io.WriteCloser, error) { return upperWriter{w}, nil }},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	request := func(target string, header ...string) (*http.Response, []byte) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		resp := rec.Result()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, body
+	}
+
+	// 未命中时返回原始响应
+	resp, body := request("/app.js", "Accept-Encoding", "gzip")
+	if resp.Header.Get("X-Cache") != "MISS" || resp.Header.Get("Content-Encoding") != "" || string(body) != script {
+		t.Fatalf("Expected identity MISS, got %q %q", resp.Header.Get("X-Cache"), resp.Header.Get("Content-Encoding"))
+	}
+	if resp.Header.Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Expected Vary: Accept-Encoding, got %q", resp.Header.Get("Vary"))
+	}
+
+	t.Run("Gzip", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			resp, body := request("/app.js", "Accept-Encoding", "gzip, deflate")
+			if resp.Header.Get("X-Cache") != "HIT" || resp.Header.Get("Content-Encoding") != "gzip" {
+				t.Fatalf("Expected gzip HIT, got %q %q", resp.Header.Get("X-Cache"), resp.Header.Get("Content-Encoding"))
+			}
+			if resp.Header.Get("ETag") != `"v1-gzip"` || resp.Header.Get("Vary") != "Accept-Encoding" {
+				t.Errorf("Expected encoded ETag and Vary, got %v", resp.Header)
+			}
+			if resp.ContentLength != int64(len(body)) || len(body) >= len(script) {
+				t.Errorf("Expected compressed Content-Length %d, got %d", len(body), resp.ContentLength)
+			}
+			zr, err := gzip.NewReader(strings.NewReader(string(body)))
+			if err != nil {
+				t.Fatalf("Failed to read gzip body: %v", err)
+			}
+			if plain, _ := io.ReadAll(zr); string(plain) != script {
+				t.Error("Expected gzip body to decompress to the original")
+			}
+		}
+		if exists, _ := cache.Exists(context.Background(), "/app.js#enc:gzip"); !exists {
+			t.Error("Expected gzip variant to be cached")
+		}
+	})
+
+	t.Run("Preferred", func(t *testing.T) {
+		resp, body := request("/app.js", "Accept-Encoding", "gzip, br")
+		if resp.Header.Get("Content-Encoding") != "br" || string(body) != strings.ToUpper(script) {
+			t.Errorf("Expected br variant, got %q", resp.Header.Get("Content-Encoding"))
+		}
+	})
+
+	t.Run("Identity", func(t *testing.T) {
+		resp, body := request("/app.js")
+		if resp.Header.Get("X-Cache") != "HIT" || resp.Header.Get("Content-Encoding") != "" || string(body) != script {
+			t.Errorf("Expected identity HIT, got %q %q", resp.Header.Get("X-Cache"), resp.Header.Get("Content-Encoding"))
+		}
+		if resp.Header.Get("Vary") != "Accept-Encoding" || resp.Header.Get("ETag") != `"v1"` {
+			t.Errorf("Expected identity ETag and Vary, got %v", resp.Header)
+		}
+	})
+
+	t.Run("Conditional", func(t *testing.T) {
+		resp, _ := request("/app.js", "Accept-Encoding", "gzip", "If-None-Match", `"v1-gzip"`)
+		if resp.StatusCode != http.StatusNotModified {
+			t.Errorf("Expected 304 for encoded ETag, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Range", func(t *testing.T) {
+		resp, body := request("/app.js", "Accept-Encoding", "gzip", "Range", "bytes=0-6")
+		if resp.StatusCode != http.StatusPartialContent || resp.Header.Get("Content-Encoding") != "" || string(body) != "console" {
+			t.Errorf("Expected identity range, got %d %q %q", resp.StatusCode, resp.Header.Get("Content-Encoding"), body)
+		}
+	})
+
+	t.Run("NotCompressible", func(t *testing.T) {
+		for _, target := range []string{"/small.js", "/image.png"} {
+			request(target, "Accept-Encoding", "gzip")
+			resp, _ := request(target, "Accept-Encoding", "gzip")
+			if resp.Header.Get("X-Cache") != "HIT" || resp.Header.Get("Content-Encoding") != "" || resp.Header.Get("Vary") != "" {
+				t.Errorf("Expected %s not to be compressed, got %v", target, resp.Header)
+			}
+		}
+	})
+
+	t.Run("Stale", func(t *testing.T) {
+		// 原始条目被替换后重新压缩
+		e := &entry{Status: http.StatusOK, Header: http.Header{"Content-Type": {"text/plain"}}, StoredAt: time.Now()}
+		if err := proxy.store(context.Background(), "/app.js", e, strings.NewReader(strings.Repeat("b", 2048)), time.Hour); err != nil {
+			t.Fatalf("Failed to store entry: %v", err)
+		}
+		resp, body := request("/app.js", "Accept-Encoding", "gzip")
+		zr, err := gzip.NewReader(strings.NewReader(string(body)))
+		if err != nil {
+			t.Fatalf("Failed to read gzip body: %v", err)
+		}
+		if plain, _ := io.ReadAll(zr); string(plain) != strings.Repeat("b", 2048) || resp.Header.Get("Content-Type") != "text/plain" {
+			t.Error("Expected gzip variant to be recompressed from the new entry")
+		}
+	})
+
+	if n := upstream.requests.Load(); n != 3 {
+		t.Errorf("Expected 3 upstream requests, got %d", n)
+	}
+}
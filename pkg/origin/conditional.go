@@ -0,0 +1,70 @@
+package origin
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// conditionalHeaders 客户端的条件请求头，回源时去掉，由代理根据缓存的校验器自行判断
+var conditionalHeaders = []string{"If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since", "If-Range"}
+
+// notModifiedHeaders 304响应中保留的响应头（RFC 9110第15.4.5节）
+var notModifiedHeaders = []string{"Cache-Control", "Content-Location", "Date", "ETag", "Expires", "Last-Modified", "Vary"}
+
+// stripConditional 去掉条件请求头
+func stripConditional(h http.Header) {
+	for _, name := range conditionalHeaders {
+		h.Del(name)
+	}
+}
+
+// notModified 按RFC 9110第13.2.2节判断缓存的响应是否满足客户端的条件请求：
+// If-None-Match优先，其次是If-Modified-Since
+func notModified(r *http.Request, e *entry) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead || e.Status != http.StatusOK {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatch(inm, e.Header.Get("ETag"))
+	}
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lastModified, err := http.ParseTime(e.Header.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(ims)
+}
+
+// etagMatch 按弱比较判断If-None-Match列表是否包含etag
+func etagMatch(list, etag string) bool {
+	if strings.TrimSpace(list) == "*" {
+		return etag != ""
+	}
+	if etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(list, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeNotModified 返回304，只保留与校验和缓存相关的响应头
+func (p *Proxy) writeNotModified(w http.ResponseWriter, e *entry, status string) {
+	header := w.Header()
+	for _, name := range notModifiedHeaders {
+		if values := e.Header.Values(name); len(values) > 0 {
+			header[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+		}
+	}
+	header.Set("Age", formatAge(e))
+	header.Set("X-Cache", status)
+	w.WriteHeader(http.StatusNotModified)
+}
@@ -0,0 +1,93 @@
+package origin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestConditionalResponses(t *testing.T) {
+	lastModified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+			t.Errorf("Expected conditional headers not to be forwarded, got %v", r.Header)
+		}
+		w.Header().Set("ETag", `"abc"`)
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+		w.Header().Set("Cache-Control", "max-age=600")
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("hello"))
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{Upstream: upstream.URL})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	conditional := func(name, value string) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/file.txt", nil)
+		req.Header.Set(name, value)
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+
+	// 未命中时回源取完整响应，再按缓存的校验器返回304
+	resp := conditional("If-None-Match", `"abc"`)
+	if resp.StatusCode != http.StatusNotModified || resp.Header.Get("X-Cache") != "MISS" {
+		t.Errorf("Expected 304 MISS, got %d %q", resp.StatusCode, resp.Header.Get("X-Cache"))
+	}
+	if exists, _ := cache.Exists(context.Background(), "/file.txt"); !exists {
+		t.Error("Expected full response to be cached")
+	}
+
+	tests := []struct {
+		name, header, value string
+		want                int
+	}{
+		{"ETagMatch", "If-None-Match", `"abc"`, http.StatusNotModified},
+		{"WeakETagMatch", "If-None-Match", `"x", W/"abc"`, http.StatusNotModified},
+		{"Wildcard", "If-None-Match", "*", http.StatusNotModified},
+		{"ETagMismatch", "If-None-Match", `"def"`, http.StatusOK},
+		{"NotModifiedSince", "If-Modified-Since", lastModified.Format(http.TimeFormat), http.StatusNotModified},
+		{"ModifiedSince", "If-Modified-Since", lastModified.Add(-time.Hour).Format(http.TimeFormat), http.StatusOK},
+		{"InvalidDate", "If-Modified-Since", "yesterday", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := conditional(tt.header, tt.value)
+			if resp.StatusCode != tt.want || resp.Header.Get("X-Cache") != "HIT" {
+				t.Fatalf("Expected %d HIT, got %d %q", tt.want, resp.StatusCode, resp.Header.Get("X-Cache"))
+			}
+			if tt.want == http.StatusNotModified {
+				if resp.Header.Get("ETag") != `"abc"` || resp.Header.Get("Cache-Control") != "max-age=600" {
+					t.Errorf("Expected validators in 304, got %v", resp.Header)
+				}
+				if resp.Header.Get("Content-Type") != "" || resp.Header.Get("Content-Length") != "" {
+					t.Errorf("Expected no representation headers in 304, got %v", resp.Header)
+				}
+			}
+		})
+	}
+
+	t.Run("IfNoneMatchWins", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/file.txt", nil)
+		req.Header.Set("If-None-Match", `"def"`)
+		req.Header.Set("If-Modified-Since", lastModified.Format(http.TimeFormat))
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected If-None-Match to take precedence, got %d", rec.Code)
+		}
+	})
+
+	if n := upstream.requests.Load(); n != 1 {
+		t.Errorf("Expected 1 upstream request, got %d", n)
+	}
+}
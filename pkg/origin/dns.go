@@ -0,0 +1,171 @@
+package origin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultDNSTTL 默认缓存解析结果的时间
+const defaultDNSTTL = time.Minute
+
+// DNSOptions 回源时解析上游主机名的方式：解析结果缓存TTL，不必每次建立连接都查询解析器；
+// 缓存的地址都无法连接时重新解析一次；解析失败时继续使用过期的结果
+type DNSOptions struct {
+	// TTL 解析结果的缓存时间，默认1分钟
+	TTL time.Duration `json:"ttl,omitempty"`
+
+	// Hosts 固定解析的主机名和IP，不查询解析器，用于内外网解析结果不同的上游
+	Hosts map[string][]string `json:"hosts,omitempty"`
+
+	// Resolver 查询使用的解析器，默认net.DefaultResolver
+	Resolver *net.Resolver `json:"-"`
+}
+
+// newCache 检查选项并创建解析缓存
+func (o *DNSOptions) newCache() (*dnsCache, error) {
+	if o.TTL < 0 {
+		return nil, fmt.Errorf("dns ttl cannot be negative")
+	}
+	c := &dnsCache{
+		ttl:      o.TTL,
+		hosts:    make(map[string][]string, len(o.Hosts)),
+		lookup:   net.DefaultResolver.LookupHost,
+		entries:  make(map[string]*dnsEntry),
+		inflight: make(map[string]*dnsLookup),
+	}
+	if c.ttl == 0 {
+		c.ttl = defaultDNSTTL
+	}
+	if o.Resolver != nil {
+		c.lookup = o.Resolver.LookupHost
+	}
+	for host, ips := range o.Hosts {
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("no addresses for pinned host %q", host)
+		}
+		for _, ip := range ips {
+			if net.ParseIP(ip) == nil {
+				return nil, fmt.Errorf("invalid address %q for pinned host %q", ip, host)
+			}
+		}
+		c.hosts[strings.ToLower(strings.TrimSuffix(host, "."))] = append([]string(nil), ips...)
+	}
+	return c, nil
+}
+
+// dnsLookupTimeout 一次查询解析器的超时
+const dnsLookupTimeout = 10 * time.Second
+
+// dnsEntry 缓存的解析结果
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// dnsLookup 一次进行中的查询，同一个主机名的并发查询共享它的结果
+type dnsLookup struct {
+	done  chan struct{}
+	addrs []string
+	err   error
+}
+
+// dnsCache 缓存上游主机名的解析结果
+type dnsCache struct {
+	ttl    time.Duration
+	hosts  map[string][]string
+	lookup func(ctx context.Context, host string) ([]string, error)
+
+	mu       sync.Mutex
+	entries  map[string]*dnsEntry
+	inflight map[string]*dnsLookup
+}
+
+// resolve 返回主机名的地址：固定解析的直接返回，缓存未过期且refresh为false时返回缓存，否则查询解析器。
+// 查询失败但有过期的缓存时返回过期的地址
+func (c *dnsCache) resolve(ctx context.Context, host string, refresh bool) ([]string, error) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if ips, ok := c.hosts[host]; ok {
+		return ips, nil
+	}
+	c.mu.Lock()
+	cached := c.entries[host]
+	if cached != nil && !refresh && time.Now().Before(cached.expires) {
+		c.mu.Unlock()
+		return cached.addrs, nil
+	}
+	l, running := c.inflight[host]
+	if !running {
+		l = &dnsLookup{done: make(chan struct{})}
+		c.inflight[host] = l
+	}
+	c.mu.Unlock()
+
+	if !running {
+		// 查询结果由所有等待的请求共享，不随发起查询的请求取消
+		lookupCtx, cancel := context.WithTimeout(context.Background(), dnsLookupTimeout)
+		l.addrs, l.err = c.lookup(lookupCtx, host)
+		cancel()
+		if l.err == nil && len(l.addrs) == 0 {
+			l.err = fmt.Errorf("no addresses for %s", host)
+		}
+		c.mu.Lock()
+		if l.err == nil {
+			c.entries[host] = &dnsEntry{addrs: l.addrs, expires: time.Now().Add(c.ttl)}
+		}
+		delete(c.inflight, host)
+		c.mu.Unlock()
+		close(l.done)
+	}
+	select {
+	case <-l.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if l.err != nil {
+		if cached != nil {
+			return cached.addrs, nil
+		}
+		return nil, l.err
+	}
+	return l.addrs, nil
+}
+
+// dialContext 返回使用解析缓存建立连接的DialContext：依次尝试主机名的每个地址，
+// 都无法连接时重新解析，尝试新出现的地址
+func (c *dnsCache) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		tried := make(map[string]bool)
+		var errs []error
+		for _, refresh := range []bool{false, true} {
+			addrs, err := c.resolve(ctx, host, refresh)
+			if err != nil {
+				errs = append(errs, err)
+				break
+			}
+			for _, ip := range addrs {
+				if tried[ip] {
+					continue
+				}
+				tried[ip] = true
+				conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+				if err == nil {
+					return conn, nil
+				}
+				errs = append(errs, err)
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, errors.Join(errs...)
+	}
+}
@@ -0,0 +1,123 @@
+package origin
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestDNS(t *testing.T) {
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write([]byte("host " + r.Host))
+	})
+	u, _ := url.Parse(upstream.URL)
+	_, port, _ := net.SplitHostPort(u.Host)
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+
+	t.Run("pinned", func(t *testing.T) {
+		proxy, err := NewProxy(cache.Namespace("pinned"), Options{
+			Upstream: "http://origin.invalid:" + port,
+			DNS:      &DNSOptions{Hosts: map[string][]string{"Origin.invalid": {"127.0.0.1"}}},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create proxy: %v", err)
+		}
+		if _, body := get(t, proxy, http.MethodGet, "/pinned"); body != "host origin.invalid:"+port {
+			t.Errorf("Expected pinned address with original Host, got %q", body)
+		}
+	})
+
+	t.Run("cached", func(t *testing.T) {
+		dns, err := (&DNSOptions{TTL: 50 * time.Millisecond}).newCache()
+		if err != nil {
+			t.Fatalf("Failed to create dns cache: %v", err)
+		}
+		var lookups atomic.Int32
+		dns.lookup = func(ctx context.Context, host string) ([]string, error) {
+			lookups.Add(1)
+			return []string{"127.0.0.1"}, nil
+		}
+		dial := dns.dialContext(&net.Dialer{})
+		for i := 0; i < 3; i++ {
+			conn, err := dial(context.Background(), "tcp", "origin.test:"+port)
+			if err != nil {
+				t.Fatalf("Failed to dial: %v", err)
+			}
+			conn.Close()
+		}
+		if lookups.Load() != 1 {
+			t.Errorf("Expected 1 lookup within ttl, got %d", lookups.Load())
+		}
+		time.Sleep(60 * time.Millisecond)
+		if conn, err := dial(context.Background(), "tcp", "origin.test:"+port); err == nil {
+			conn.Close()
+		}
+		if lookups.Load() != 2 {
+			t.Errorf("Expected lookup after ttl, got %d", lookups.Load())
+		}
+	})
+
+	t.Run("re-resolve", func(t *testing.T) {
+		dns, err := (&DNSOptions{TTL: time.Hour}).newCache()
+		if err != nil {
+			t.Fatalf("Failed to create dns cache: %v", err)
+		}
+		var lookups atomic.Int32
+		dns.lookup = func(ctx context.Context, host string) ([]string, error) {
+			if lookups.Add(1) == 1 {
+				// 127.0.0.2上没有监听，连接被拒绝
+				return []string{"127.0.0.2"}, nil
+			}
+			return []string{"127.0.0.1"}, nil
+		}
+		conn, err := dns.dialContext(&net.Dialer{})(context.Background(), "tcp", "origin.test:"+port)
+		if err != nil {
+			t.Fatalf("Expected dial to succeed after re-resolving, got %v", err)
+		}
+		conn.Close()
+		if lookups.Load() != 2 {
+			t.Errorf("Expected 2 lookups, got %d", lookups.Load())
+		}
+	})
+
+	t.Run("stale on lookup failure", func(t *testing.T) {
+		dns, err := (&DNSOptions{TTL: time.Millisecond}).newCache()
+		if err != nil {
+			t.Fatalf("Failed to create dns cache: %v", err)
+		}
+		fail := errors.New("resolver down")
+		dns.lookup = func(ctx context.Context, host string) ([]string, error) { return []string{"127.0.0.1"}, nil }
+		if _, err := dns.resolve(context.Background(), "origin.test", false); err != nil {
+			t.Fatalf("Failed to resolve: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+		dns.lookup = func(ctx context.Context, host string) ([]string, error) { return nil, fail }
+		if addrs, err := dns.resolve(context.Background(), "origin.test", false); err != nil || len(addrs) != 1 {
+			t.Errorf("Expected expired addresses when lookup fails, got %v %v", addrs, err)
+		}
+		if _, err := dns.resolve(context.Background(), "other.test", false); !errors.Is(err, fail) {
+			t.Errorf("Expected lookup error without cached addresses, got %v", err)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, dns := range []*DNSOptions{
+			{TTL: -1},
+			{Hosts: map[string][]string{"origin.test": nil}},
+			{Hosts: map[string][]string{"origin.test": {"origin"}}},
+		} {
+			if _, err := NewProxy(cache, Options{Upstream: upstream.URL, DNS: dns}); err == nil {
+				t.Errorf("Expected error for %+v", dns)
+			}
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package origin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// entryMagic 代理写入的缓存条目以此开头，后面是4字节的元数据长度、JSON元数据和响应体
+const entryMagic = "EOR1"
+
+// maxEntryHeader 元数据的最大长度，超过时按损坏的条目处理
+const maxEntryHeader = 1 << 20
+
+// entry 缓存条目的元数据：上游响应的状态码和响应头
+type entry struct {
+	Status     int           `json:"status"`
+	Header     http.Header   `json:"header"`
+	StoredAt   time.Time     `json:"stored_at"`
+	InitialAge time.Duration `json:"initial_age,omitempty"` // 写入缓存时响应已有的年龄
+	Negative   bool          `json:"negative,omitempty"`    // 按Options.NegativeTTL或StatusTTL缓存的错误响应
+	Tags       []string      `json:"tags,omitempty"`        // 上游Surrogate-Key和Cache-Tag头中的缓存标签
+
+	// Vary 不为空时条目是变体标记，没有响应体，实际的响应按这些请求头存放在变体键上
+	Vary []string `json:"vary,omitempty"`
+}
+
+// age 返回响应当前的年龄
+func (e *entry) age(now time.Time) time.Duration {
+	age := e.InitialAge + now.Sub(e.StoredAt)
+	if age < 0 {
+		return 0
+	}
+	return age
+}
+
+// formatAge 返回Age头的值（秒）
+func formatAge(e *entry) string {
+	return strconv.FormatInt(int64(e.age(time.Now())/time.Second), 10)
+}
+
+// encode 返回写在响应体之前的元数据
+func (e *entry) encode() ([]byte, error) {
+	meta, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode entry: %w", err)
+	}
+	buf := make([]byte, 0, len(entryMagic)+4+len(meta))
+	buf = append(buf, entryMagic...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(meta)))
+	return append(buf, meta...), nil
+}
+
+// readEntry 读取缓存条目的元数据，返回元数据、响应体和元数据占用的字节数；
+// 不是代理写入的条目（例如通过管理接口直接写入的文件）返回nil元数据，整个内容作为响应体
+func readEntry(r io.Reader) (*entry, io.Reader, int64, error) {
+	prefix := make([]byte, len(entryMagic)+4)
+	n, err := io.ReadFull(r, prefix)
+	if err == io.EOF || err == io.ErrUnexpectedEOF || (err == nil && string(prefix[:len(entryMagic)]) != entryMagic) {
+		// 支持Seek时回到开头，*os.File仍然可以由sendfile发送
+		if seeker, ok := r.(io.Seeker); ok {
+			if _, err := seeker.Seek(0, io.SeekStart); err == nil {
+				return nil, r, 0, nil
+			}
+		}
+		return nil, io.MultiReader(bytes.NewReader(prefix[:n]), r), 0, nil
+	}
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	size := binary.BigEndian.Uint32(prefix[len(entryMagic):])
+	if size > maxEntryHeader {
+		return nil, nil, 0, fmt.Errorf("entry header too large: %d", size)
+	}
+	meta := make([]byte, size)
+	if _, err := io.ReadFull(r, meta); err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to read entry header: %w", err)
+	}
+	e := &entry{}
+	if err := json.Unmarshal(meta, e); err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to decode entry header: %w", err)
+	}
+	return e, r, int64(len(prefix)) + int64(size), nil
+}
@@ -0,0 +1,180 @@
+package origin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultESIMaxDepth    = 3
+	defaultESIMaxIncludes = 32
+	defaultESIMaxSize     = 1 << 20
+)
+
+var (
+	// esiTag 匹配ESI指令：<esi:include .../>、<esi:remove>...</esi:remove>和<!--esi ...-->
+	esiTag = regexp.MustCompile(`(?s)<esi:include\b([^>]*?)/?>(?:\s*</esi:include>)?|<esi:remove>.*?</esi:remove>|<!--esi(.*?)-->`)
+
+	// esiAttr 匹配标签的属性
+	esiAttr = regexp.MustCompile(`(\w+)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+)
+
+// esiDepthKey 请求上下文中记录ESI片段嵌套深度的键
+type esiDepthKey struct{}
+
+// ESIOptions 返回HTML时处理Edge Side Includes：<esi:include src="..."/>替换为片段的内容，
+// 片段经过缓存获取，按各自的缓存头和TTL缓存，大部分静态、只有一小块个性化内容的页面也可以缓存
+type ESIOptions struct {
+	// MaxDepth 片段中再包含片段的最大深度，默认3
+	MaxDepth int `json:"max_depth,omitempty"`
+
+	// MaxIncludes 一个页面最多处理的include数，超出的替换为空，默认32
+	MaxIncludes int `json:"max_includes,omitempty"`
+
+	// MaxSize 处理的页面和片段的最大长度，更大的页面原样返回，默认1MB
+	MaxSize int64 `json:"max_size,omitempty"`
+}
+
+// validate 检查选项并填充默认值
+func (o *ESIOptions) validate() error {
+	if o.MaxDepth < 0 || o.MaxIncludes < 0 || o.MaxSize < 0 {
+		return fmt.Errorf("esi options cannot be negative")
+	}
+	if o.MaxDepth == 0 {
+		o.MaxDepth = defaultESIMaxDepth
+	}
+	if o.MaxIncludes == 0 {
+		o.MaxIncludes = defaultESIMaxIncludes
+	}
+	if o.MaxSize == 0 {
+		o.MaxSize = defaultESIMaxSize
+	}
+	return nil
+}
+
+// applies 返回是否需要检查条目中的ESI指令：未编码的200 HTML响应，长度不超过MaxSize
+func (o *ESIOptions) applies(e *entry, size int64) bool {
+	if e.Status != http.StatusOK || size > o.MaxSize || e.Header.Get("Content-Encoding") != "" {
+		return false
+	}
+	mimeType, _, err := mime.ParseMediaType(e.Header.Get("Content-Type"))
+	return err == nil && mimeType == "text/html"
+}
+
+// writeESI 读取HTML页面，包含ESI指令时处理后返回200并返回true；页面中没有ESI指令时返回读到的内容，
+// 由调用方按普通响应返回。处理后的页面随片段变化，不再带有ETag和Last-Modified，也不支持条件请求和Range
+func (p *Proxy) writeESI(w http.ResponseWriter, r *http.Request, e *entry, body io.Reader, status string) (io.Reader, bool) {
+	page, err := io.ReadAll(body)
+	if err != nil || !esiTag.Match(page) {
+		return bytes.NewReader(page), false
+	}
+	page = p.processESI(r, page)
+
+	header := w.Header()
+	copyHeader(header, e.Header)
+	for _, name := range []string{"ETag", "Last-Modified", "Surrogate-Control"} {
+		header.Del(name)
+	}
+	header.Set("Content-Length", strconv.Itoa(len(page)))
+	header.Set("Age", formatAge(e))
+	header.Set("X-Cache", status)
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		w.Write(page)
+	}
+	return nil, true
+}
+
+// processESI 并发获取页面中include的片段，替换ESI指令
+func (p *Proxy) processESI(r *http.Request, page []byte) []byte {
+	depth, _ := r.Context().Value(esiDepthKey{}).(int)
+	matches := esiTag.FindAllSubmatchIndex(page, -1)
+	fragments := make([][]byte, len(matches))
+
+	var wg sync.WaitGroup
+	includes := 0
+	for i, m := range matches {
+		switch {
+		case bytes.HasPrefix(page[m[0]:], []byte("<!--esi")):
+			// 不支持ESI的缓存把它当作注释，支持ESI时去掉注释标记，保留其中的内容
+			fragments[i] = page[m[4]:m[5]]
+		case bytes.HasPrefix(page[m[0]:], []byte("<esi:include")):
+			includes++
+			if depth >= p.opts.ESI.MaxDepth || includes > p.opts.ESI.MaxIncludes {
+				continue
+			}
+			attrs := esiAttrs(page[m[2]:m[3]])
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				fragment, ok := p.fetchFragment(r, attrs["src"], depth+1)
+				if !ok && attrs["alt"] != "" {
+					fragment, _ = p.fetchFragment(r, attrs["alt"], depth+1)
+				}
+				fragments[i] = fragment
+			}(i)
+		}
+	}
+	wg.Wait()
+
+	var out bytes.Buffer
+	last := 0
+	for i, m := range matches {
+		out.Write(page[last:m[0]])
+		out.Write(fragments[i])
+		last = m[1]
+	}
+	out.Write(page[last:])
+	return out.Bytes()
+}
+
+// esiAttrs 解析标签的属性
+func esiAttrs(tag []byte) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range esiAttr.FindAllSubmatch(tag, -1) {
+		value := m[2]
+		if value == nil {
+			value = m[3]
+		}
+		attrs[strings.ToLower(string(m[1]))] = string(value)
+	}
+	return attrs
+}
+
+// fetchFragment 经过缓存获取片段，src是相对于页面的路径，不能指向其他主机；
+// 片段请求带有客户端的请求头（例如Cookie），不是200时返回false
+func (p *Proxy) fetchFragment(r *http.Request, src string, depth int) ([]byte, bool) {
+	ref, err := url.Parse(src)
+	if err != nil || src == "" || ref.Scheme != "" || ref.Host != "" {
+		return nil, false
+	}
+	sub := r.Clone(context.WithValue(r.Context(), esiDepthKey{}, depth))
+	sub.Method = http.MethodGet
+	base := *r.URL
+	base.Scheme, base.Host = "", ""
+	sub.URL = base.ResolveReference(ref)
+	if len(p.opts.Rewrites) > 0 {
+		sub = p.rewrite(sub)
+	}
+	sub.RequestURI = sub.URL.RequestURI()
+	sub.Header.Del("Range")
+	// 片段按原样拼接到页面中，不能是压缩后的变体
+	sub.Header.Del("Accept-Encoding")
+	stripConditional(sub.Header)
+
+	buf := &responseBuffer{header: http.Header{}, limit: p.opts.ESI.MaxSize}
+	p.serve(buf, sub)
+	if buf.err != nil || buf.status != http.StatusOK {
+		return nil, false
+	}
+	return buf.body.Bytes(), true
+}
@@ -0,0 +1,99 @@
+package origin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestESI(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		requested = make(map[string]int)
+	)
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested[r.URL.Path]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "public, max-age=600")
+		switch r.URL.Path {
+		case "/page.html":
+			w.Header().Set("ETag", `"page"`)
+			w.Header().Set("Surrogate-Control", `content="ESI/1.0"`)
+			io.WriteString(w, `<p><esi:include src="/frag/user" /></p>`+
+				`<nav><esi:include src="frag/nav"></esi:include></nav>`+
+				`<esi:include src="/frag/missing" alt="/frag/nav"/>`+
+				`<esi:include src="http://attacker.example/x"/>`+
+				`<esi:include src='/frag/loop'/>`+
+				`<esi:remove>no esi</esi:remove><!--esi <b>edge</b>-->`)
+		case "/frag/user":
+			w.Header().Set("Cache-Control", "max-age=0")
+			cookie, _ := r.Cookie("user")
+			io.WriteString(w, "hello "+cookie.Value)
+		case "/frag/nav":
+			io.WriteString(w, "nav")
+		case "/frag/loop":
+			io.WriteString(w, `[<esi:include src="/frag/loop"/>]`)
+		case "/static.html":
+			io.WriteString(w, "<p>static</p>")
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{
+		Upstream:    upstream.URL,
+		ESI:         &ESIOptions{},
+		Compression: &Compression{MinSize: 1},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	request := func(target, user string) (*http.Response, string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Cookie", "user="+user)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		body, _ := io.ReadAll(rec.Result().Body)
+		return rec.Result(), string(body)
+	}
+
+	for i, user := range []string{"alice", "bob"} {
+		resp, body := request("/page.html", user)
+		expected := `<p>hello ` + user + `</p><nav>nav</nav>nav[[[]]] <b>edge</b>`
+		if body != expected {
+			t.Errorf("Expected %q, got %q", expected, body)
+		}
+		if resp.Header.Get("Content-Length") != strconv.Itoa(len(body)) || resp.Header.Get("Content-Encoding") != "" {
+			t.Errorf("Expected uncompressed assembled page, got %v", resp.Header)
+		}
+		if resp.Header.Get("ETag") != "" || resp.Header.Get("Surrogate-Control") != "" {
+			t.Errorf("Expected ETag and Surrogate-Control to be removed, got %v", resp.Header)
+		}
+		wantCache := []string{"MISS", "HIT"}[i]
+		if resp.Header.Get("X-Cache") != wantCache {
+			t.Errorf("Expected page %s, got %q", wantCache, resp.Header.Get("X-Cache"))
+		}
+	}
+
+	if _, body := request("/static.html", "alice"); body != "<p>static</p>" {
+		t.Errorf("Expected HTML without ESI to be unchanged, got %q", body)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for path, n := range map[string]int{"/page.html": 1, "/frag/nav": 1, "/frag/user": 2} {
+		if requested[path] != n {
+			t.Errorf("Expected %s to be fetched %d times, got %d", path, n, requested[path])
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package origin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultFailoverStatus 默认换下一个上游重试的状态码
+var defaultFailoverStatus = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// UpstreamTarget 上游池中的一个上游
+type UpstreamTarget struct {
+	// URL 上游地址，与Options.Upstream的格式相同
+	URL string `json:"url"`
+
+	// Priority 优先级，越小越优先；只有同一优先级的上游都失败时才使用下一优先级
+	Priority int `json:"priority,omitempty"`
+
+	// Weight 同一优先级的上游之间按权重分配请求，默认1
+	Weight int `json:"weight,omitempty"`
+
+	// Timeouts 这个上游的超时，未设置的字段使用FailoverOptions.Timeouts；设置了Fetcher时不使用
+	Timeouts *Timeouts `json:"timeouts,omitempty"`
+
+	// Fetcher 不为空时用它回源，不使用URL，例如备用的S3存储桶
+	Fetcher Fetcher `json:"-"`
+}
+
+// FailoverOptions 上游池的选项
+type FailoverOptions struct {
+	// Transport 发送HTTP上游请求，默认http.DefaultTransport
+	Transport http.RoundTripper
+
+	// Timeout 等待一个上游响应头的时间，超时后换下一个上游，0表示不限制
+	Timeout time.Duration
+
+	// Timeouts 所有HTTP上游的连接、TLS握手、响应头和响应体超时，见Timeouts
+	Timeouts *Timeouts
+
+	// ForwardProxy HTTP上游经过的出口代理，见ForwardProxy
+	ForwardProxy *ForwardProxy
+
+	// DNS HTTP上游的解析缓存和固定解析，所有上游共享，见DNSOptions
+	DNS *DNSOptions
+
+	// Pool HTTP上游的连接复用设置，见ConnectionPool
+	Pool *ConnectionPool
+
+	// FailoverStatus 换下一个上游重试的状态码，默认502、503和504
+	FailoverStatus []int
+
+	// HealthCheck 不为空时对每个上游熔断，并按Interval主动探测
+	HealthCheck *HealthCheck
+}
+
+// upstreamTarget 上游池中的上游
+type upstreamTarget struct {
+	name     string
+	fetcher  Fetcher
+	priority int
+	weight   int
+	breaker  breaker
+}
+
+// failoverFetcher 按优先级故障转移、同一优先级按权重负载均衡的上游池
+type failoverFetcher struct {
+	targets []*upstreamTarget // 按优先级排序
+	opts    FailoverOptions
+
+	stop      chan struct{} // 关闭时停止主动探测
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewFailoverFetcher 创建上游池：每次回源按优先级依次尝试，同一优先级的上游按权重随机排序；
+// 上游出错、超时或返回FailoverStatus中的状态码时尝试下一个，都失败时返回最后一个上游的结果。
+// 带有请求体的请求无法重放，只发送到第一个上游。设置了HealthCheck.Interval时需要调用Close停止探测
+func NewFailoverFetcher(targets []UpstreamTarget, opts FailoverOptions) (Fetcher, error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no upstreams configured")
+	}
+	if opts.Timeout < 0 {
+		return nil, fmt.Errorf("upstream timeout cannot be negative")
+	}
+	if len(opts.FailoverStatus) == 0 {
+		opts.FailoverStatus = defaultFailoverStatus
+	}
+	if opts.HealthCheck != nil {
+		health := *opts.HealthCheck
+		if err := health.validate(); err != nil {
+			return nil, err
+		}
+		opts.HealthCheck = &health
+	}
+	var proxy func(*http.Request) (*url.URL, error)
+	if opts.ForwardProxy != nil {
+		var err error
+		if proxy, err = opts.ForwardProxy.proxyFunc(); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Pool != nil {
+		if err := opts.Pool.validate(); err != nil {
+			return nil, err
+		}
+	}
+	var dns *dnsCache
+	if opts.DNS != nil {
+		var err error
+		if dns, err = opts.DNS.newCache(); err != nil {
+			return nil, err
+		}
+	}
+	f := &failoverFetcher{opts: opts, stop: make(chan struct{})}
+	for i, t := range targets {
+		if t.Weight < 0 {
+			return nil, fmt.Errorf("upstream %d weight cannot be negative", i)
+		}
+		target := &upstreamTarget{name: t.URL, fetcher: t.Fetcher, priority: t.Priority, weight: t.Weight}
+		if target.weight == 0 {
+			target.weight = 1
+		}
+		if target.fetcher == nil {
+			timeouts := t.Timeouts.merge(opts.Timeouts)
+			if timeouts != nil {
+				if err := timeouts.validate(); err != nil {
+					return nil, err
+				}
+			}
+			fetcher, err := newUpstreamFetcher(t.URL, transportConfig{transport: opts.Transport, timeouts: timeouts, proxy: proxy, dns: dns, pool: opts.Pool})
+			if err != nil {
+				return nil, err
+			}
+			target.fetcher = fetcher
+		} else if target.name == "" {
+			target.name = fmt.Sprintf("upstream %d", i)
+		}
+		f.targets = append(f.targets, target)
+	}
+	sort.SliceStable(f.targets, func(i, j int) bool { return f.targets[i].priority < f.targets[j].priority })
+	if opts.HealthCheck != nil && opts.HealthCheck.Interval > 0 {
+		for _, target := range f.targets {
+			f.wg.Add(1)
+			go f.probe(target)
+		}
+	}
+	return f, nil
+}
+
+// order 返回本次回源尝试上游的顺序：按优先级，同一优先级按权重随机排序
+func (f *failoverFetcher) order() []*upstreamTarget {
+	out := make([]*upstreamTarget, 0, len(f.targets))
+	for start := 0; start < len(f.targets); {
+		end := start
+		for end < len(f.targets) && f.targets[end].priority == f.targets[start].priority {
+			end++
+		}
+		group := append([]*upstreamTarget(nil), f.targets[start:end]...)
+		for len(group) > 0 {
+			total := 0
+			for _, t := range group {
+				total += t.weight
+			}
+			n := rand.Intn(total)
+			i := 0
+			for ; n >= group[i].weight; i++ {
+				n -= group[i].weight
+			}
+			out = append(out, group[i])
+			group = append(group[:i], group[i+1:]...)
+		}
+		start = end
+	}
+	return out
+}
+
+// Fetch 依次尝试未熔断的上游直到得到不需要故障转移的响应，所有上游都熔断时返回ErrCircuitOpen
+func (f *failoverFetcher) Fetch(ctx context.Context, req *http.Request) (*http.Response, error) {
+	replayable := req.Body == nil || req.Body == http.NoBody
+	var (
+		resp     *http.Response
+		err      error
+		attempts int
+	)
+	for _, target := range f.order() {
+		if attempts > 0 && !replayable {
+			break
+		}
+		if f.opts.HealthCheck != nil && !target.breaker.allow(time.Now()) {
+			continue
+		}
+		if attempts > 0 && err == nil {
+			resp.Body.Close()
+		}
+		attempts++
+		resp, err = f.attempt(ctx, target, req)
+		if f.opts.HealthCheck != nil {
+			target.breaker.record(f.outcome(ctx, resp, err), f.opts.HealthCheck.Threshold, f.opts.HealthCheck.Cooldown)
+		}
+		if ctx.Err() != nil || err == nil && !f.failover(resp.StatusCode) {
+			break
+		}
+	}
+	if attempts == 0 {
+		return nil, ErrCircuitOpen
+	}
+	return resp, err
+}
+
+// outcome 返回一次回源的结果，调用方取消的请求不计入
+func (f *failoverFetcher) outcome(ctx context.Context, resp *http.Response, err error) outcome {
+	switch {
+	case err != nil && ctx.Err() != nil:
+		return outcomeNone
+	case err != nil || f.failover(resp.StatusCode):
+		return outcomeFailure
+	}
+	return outcomeSuccess
+}
+
+// failover 返回状态码是否需要换下一个上游
+func (f *failoverFetcher) failover(status int) bool {
+	for _, s := range f.opts.FailoverStatus {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// attempt 向一个上游发送请求，Timeout内没有收到响应头时取消请求并返回超时错误
+func (f *failoverFetcher) attempt(ctx context.Context, target *upstreamTarget, req *http.Request) (*http.Response, error) {
+	if f.opts.Timeout <= 0 {
+		return target.fetcher.Fetch(ctx, req)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	timer := time.AfterFunc(f.opts.Timeout, cancel)
+	resp, err := target.fetcher.Fetch(ctx, req.WithContext(ctx))
+	if !timer.Stop() {
+		if err == nil {
+			resp.Body.Close()
+		}
+		cancel()
+		return nil, fmt.Errorf("%s did not respond within %v: %w", target.name, f.opts.Timeout, context.DeadlineExceeded)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// 收到响应头后不再限制时间，响应体读完或关闭时释放ctx
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody 关闭时取消请求的ctx
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close 关闭响应体并取消ctx
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,146 @@
+package origin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestFailover(t *testing.T) {
+	primary := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/missing":
+			http.NotFound(w, r)
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+			io.WriteString(w, "primary")
+		default:
+			io.WriteString(w, "primary")
+		}
+	})
+	secondary := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "secondary")
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{
+		Upstreams: []UpstreamTarget{
+			{URL: secondary.URL, Priority: 1},
+			{URL: primary.URL},
+		},
+		UpstreamTimeout: 50 * time.Millisecond,
+		DefaultTTL:      time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	t.Run("primary first", func(t *testing.T) {
+		_, body := get(t, proxy, http.MethodGet, "/ok")
+		if body != "primary" || secondary.requests.Load() != 0 {
+			t.Errorf("Expected primary to serve, got %q with %d secondary requests", body, secondary.requests.Load())
+		}
+	})
+
+	t.Run("failover on status", func(t *testing.T) {
+		resp, body := get(t, proxy, http.MethodGet, "/down")
+		if resp.StatusCode != http.StatusOK || body != "secondary" {
+			t.Errorf("Expected secondary after 503, got %d %q", resp.StatusCode, body)
+		}
+	})
+
+	t.Run("no failover on 404", func(t *testing.T) {
+		before := secondary.requests.Load()
+		resp, _ := get(t, proxy, http.MethodGet, "/missing")
+		if resp.StatusCode != http.StatusNotFound || secondary.requests.Load() != before {
+			t.Errorf("Expected primary 404 without failover, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("failover on timeout", func(t *testing.T) {
+		resp, body := get(t, proxy, http.MethodGet, "/slow")
+		if resp.StatusCode != http.StatusOK || body != "secondary" {
+			t.Errorf("Expected secondary after timeout, got %d %q", resp.StatusCode, body)
+		}
+	})
+
+	t.Run("request body not replayed", func(t *testing.T) {
+		before := secondary.requests.Load()
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/down", strings.NewReader("form")))
+		if rec.Code != http.StatusServiceUnavailable || secondary.requests.Load() != before {
+			t.Errorf("Expected POST to reach only the primary, got %d", rec.Code)
+		}
+	})
+
+	t.Run("all upstreams down", func(t *testing.T) {
+		closed := httptest.NewServer(http.NotFoundHandler())
+		closed.Close()
+		proxy, err := NewProxy(cache, Options{Upstreams: []UpstreamTarget{{URL: closed.URL}, {URL: closed.URL, Priority: 1}}})
+		if err != nil {
+			t.Fatalf("Failed to create proxy: %v", err)
+		}
+		resp, _ := get(t, proxy, http.MethodGet, "/x")
+		if resp.StatusCode != http.StatusBadGateway {
+			t.Errorf("Expected 502, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		if _, err := NewProxy(cache, Options{Upstream: primary.URL, Upstreams: []UpstreamTarget{{URL: secondary.URL}}}); err == nil {
+			t.Error("Expected error for both upstream and upstreams")
+		}
+		if _, err := NewProxy(cache, Options{Upstreams: []UpstreamTarget{{URL: primary.URL, Weight: -1}}}); err == nil {
+			t.Error("Expected error for negative weight")
+		}
+		if _, err := NewProxy(cache, Options{Upstreams: []UpstreamTarget{{URL: "ftp://origin"}}}); err == nil {
+			t.Error("Expected error for invalid upstream url")
+		}
+	})
+}
+
+func TestFailoverWeights(t *testing.T) {
+	var counts [2]int
+	target := func(i int) Fetcher {
+		return fetcherFunc(func(ctx context.Context, req *http.Request) (*http.Response, error) {
+			counts[i]++
+			return statusResponse(req, http.StatusOK), nil
+		})
+	}
+	f, err := NewFailoverFetcher([]UpstreamTarget{{Fetcher: target(0), Weight: 3}, {Fetcher: target(1)}}, FailoverOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create fetcher: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		resp, err := f.Fetch(context.Background(), httptest.NewRequest(http.MethodGet, "/", nil))
+		if err != nil {
+			t.Fatalf("Failed to fetch: %v", err)
+		}
+		resp.Body.Close()
+	}
+	if counts[0] < 650 || counts[0] > 850 {
+		t.Errorf("Expected about 750 requests to the weight 3 upstream, got %v", counts)
+	}
+
+	failing := fetcherFunc(func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})
+	f, err = NewFailoverFetcher([]UpstreamTarget{{Fetcher: failing}, {Fetcher: target(1), Priority: 1}}, FailoverOptions{})
+	if err != nil {
+		t.Fatalf("Failed to create fetcher: %v", err)
+	}
+	resp, err := f.Fetch(context.Background(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected failover after error, got %v", err)
+	}
+	resp.Body.Close()
+}
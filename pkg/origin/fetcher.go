@@ -0,0 +1,253 @@
+package origin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	pathpkg "path"
+	"strconv"
+	"strings"
+
+	"github.com/seraphico/EdgeOrigin/internal/s3client"
+)
+
+// Fetcher 从上游获取请求对应的响应，调用方负责关闭响应体。req的URL是客户端请求的路径和查询参数，
+// 请求头已去掉逐跳头并带有X-Forwarded-For和X-Forwarded-Host，Range和条件请求头由Fetcher转发给上游。
+// 上游对象不存在时应返回404响应而不是错误，以便按NegativeTTL缓存；返回错误时代理返回502或504
+type Fetcher interface {
+	Fetch(ctx context.Context, req *http.Request) (*http.Response, error)
+}
+
+// httpFetcher 回源到HTTP(S)上游
+type httpFetcher struct {
+	upstream  *url.URL
+	transport http.RoundTripper
+}
+
+// NewHTTPFetcher 创建回源到HTTP(S)上游的Fetcher，请求路径追加在upstream的路径之后；
+// transport为nil时使用http.DefaultTransport
+func NewHTTPFetcher(upstream string, transport http.RoundTripper) (Fetcher, error) {
+	u, err := parseUpstream(upstream)
+	if err != nil {
+		return nil, err
+	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &httpFetcher{upstream: u, transport: transport}, nil
+}
+
+// parseUpstream 解析上游地址，必须是http或https的绝对URL
+func parseUpstream(upstream string) (*url.URL, error) {
+	u, err := url.Parse(upstream)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream %q: %w", upstream, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid upstream %q: must be an absolute http or https url", upstream)
+	}
+	return u, nil
+}
+
+// Fetch 把请求发送到上游
+func (f *httpFetcher) Fetch(ctx context.Context, req *http.Request) (*http.Response, error) {
+	out := req.Clone(ctx)
+	out.URL.Scheme = f.upstream.Scheme
+	out.URL.Host = f.upstream.Host
+	out.URL.Path = joinPath(f.upstream.Path, req.URL.Path)
+	out.URL.RawPath = ""
+	switch {
+	case f.upstream.RawQuery == "":
+	case req.URL.RawQuery == "":
+		out.URL.RawQuery = f.upstream.RawQuery
+	default:
+		out.URL.RawQuery = f.upstream.RawQuery + "&" + req.URL.RawQuery
+	}
+	out.Host = f.upstream.Host
+	if _, ok := out.Header["Accept-Encoding"]; !ok {
+		// 阻止Transport自动请求gzip并透明解压，缓存的响应与上游一致
+		out.Header.Set("Accept-Encoding", "identity")
+	}
+	return f.transport.RoundTrip(out)
+}
+
+// s3Forwarded 转发给对象存储的请求头
+var s3Forwarded = []string{"Range", "If-Range", "If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since"}
+
+// S3Origin 作为上游的S3兼容存储桶，请求路径（去掉开头的"/"）加上Prefix作为对象键
+type S3Origin struct {
+	Endpoint        string       `json:"endpoint"`          // 服务地址，例如 https://s3.us-east-1.amazonaws.com 或 http://minio:9000
+	Region          string       `json:"region"`            // 区域，默认us-east-1
+	Bucket          string       `json:"bucket"`            // 存储桶
+	Prefix          string       `json:"prefix"`            // 对象键前缀
+	AccessKeyID     string       `json:"access_key_id"`     // 访问密钥ID
+	SecretAccessKey string       `json:"secret_access_key"` // 访问密钥
+	SessionToken    string       `json:"session_token"`     // 临时凭证的会话令牌
+	PathStyle       bool         `json:"path_style"`        // 使用路径风格访问，MinIO等通常需要开启
+	HTTPClient      *http.Client `json:"-"`                 // 自定义HTTP客户端
+}
+
+// s3Fetcher 回源到S3兼容存储桶
+type s3Fetcher struct {
+	client *s3client.Client
+	prefix string
+}
+
+// NewS3Fetcher 创建回源到S3兼容存储桶的Fetcher，只支持GET和HEAD，其他方法返回405
+func NewS3Fetcher(cfg S3Origin) (Fetcher, error) {
+	client, err := s3client.New(s3client.Config{
+		Endpoint:        cfg.Endpoint,
+		Region:          cfg.Region,
+		Bucket:          cfg.Bucket,
+		AccessKeyID:     cfg.AccessKeyID,
+		SecretAccessKey: cfg.SecretAccessKey,
+		SessionToken:    cfg.SessionToken,
+		PathStyle:       cfg.PathStyle,
+		HTTPClient:      cfg.HTTPClient,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s3Fetcher{client: client, prefix: cfg.Prefix}, nil
+}
+
+// Fetch 下载请求路径对应的对象；对象不存在返回404，存储返回的其他错误转换为对应状态码的响应
+func (f *s3Fetcher) Fetch(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return statusResponse(req, http.StatusMethodNotAllowed), nil
+	}
+	name := strings.TrimPrefix(req.URL.Path, "/")
+	if name == "" || strings.HasSuffix(name, "/") {
+		return statusResponse(req, http.StatusNotFound), nil
+	}
+	header := http.Header{}
+	for _, h := range s3Forwarded {
+		if v := req.Header.Get(h); v != "" {
+			header.Set(h, v)
+		}
+	}
+
+	resp, err := f.client.GetObject(ctx, f.prefix+name, header)
+	var s3Err *s3client.Error
+	switch {
+	case errors.Is(err, s3client.ErrNotFound):
+		return statusResponse(req, http.StatusNotFound), nil
+	case errors.As(err, &s3Err):
+		return statusResponse(req, s3Err.StatusCode), nil
+	case err != nil:
+		return nil, err
+	}
+	// 去掉存储的请求ID等内部响应头，保留用户元数据
+	for name := range resp.Header {
+		if strings.HasPrefix(name, "X-Amz-") && !strings.HasPrefix(name, "X-Amz-Meta-") {
+			delete(resp.Header, name)
+		}
+	}
+	return resp, nil
+}
+
+// dirFetcher 回源到本地目录
+type dirFetcher struct {
+	root http.FileSystem
+}
+
+// NewDirFetcher 创建回源到本地目录的Fetcher，请求路径映射为root下的文件，不能访问root之外的文件；
+// 响应带有按扩展名推断的Content-Type、Last-Modified和ETag，支持Range和条件请求，目录返回404
+func NewDirFetcher(root string) Fetcher {
+	return &dirFetcher{root: http.Dir(root)}
+}
+
+// Fetch 打开请求路径对应的文件
+func (f *dirFetcher) Fetch(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return statusResponse(req, http.StatusMethodNotAllowed), nil
+	}
+	file, err := f.root.Open(pathpkg.Clean("/" + req.URL.Path))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return statusResponse(req, http.StatusNotFound), nil
+	case errors.Is(err, fs.ErrPermission):
+		return statusResponse(req, http.StatusForbidden), nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to open %s: %w", req.URL.Path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat %s: %w", req.URL.Path, err)
+	}
+	if info.IsDir() {
+		file.Close()
+		return statusResponse(req, http.StatusNotFound), nil
+	}
+
+	size := info.Size()
+	mimeType := mime.TypeByExtension(pathpkg.Ext(info.Name()))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	header := http.Header{
+		"Content-Type":  {mimeType},
+		"Etag":          {fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), size)},
+		"Last-Modified": {info.ModTime().UTC().Format(http.TimeFormat)},
+		"Accept-Ranges": {"bytes"},
+	}
+	if notModified(req, &entry{Status: http.StatusOK, Header: header}) {
+		file.Close()
+		return newResponse(req, http.StatusNotModified, header, nil, 0), nil
+	}
+
+	var rng *byteRange
+	if ifRangeMatches(req, header) {
+		rng, err = parseRange(req.Header.Get("Range"), size)
+	}
+	switch {
+	case errors.Is(err, errRangeNotSatisfiable):
+		file.Close()
+		resp := statusResponse(req, http.StatusRequestedRangeNotSatisfiable)
+		resp.Header.Set("Content-Range", "bytes */"+strconv.FormatInt(size, 10))
+		return resp, nil
+	case err != nil || rng == nil:
+		return newResponse(req, http.StatusOK, header, file, size), nil
+	}
+	if _, err := file.Seek(rng.Start, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to seek %s: %w", req.URL.Path, err)
+	}
+	header.Set("Content-Range", rng.contentRange(size))
+	body := struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(file, rng.length()), file}
+	return newResponse(req, http.StatusPartialContent, header, body, rng.length()), nil
+}
+
+// newResponse 返回Fetcher合成的响应，body为nil时响应体为空
+func newResponse(req *http.Request, status int, header http.Header, body io.ReadCloser, size int64) *http.Response {
+	if body == nil {
+		body = http.NoBody
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: size,
+		Request:       req,
+	}
+}
+
+// statusResponse 返回只带状态文本的响应
+func statusResponse(req *http.Request, status int) *http.Response {
+	text := http.StatusText(status) + "\n"
+	header := http.Header{"Content-Type": {"text/plain; charset=utf-8"}}
+	return newResponse(req, status, header, io.NopCloser(strings.NewReader(text)), int64(len(text)))
+}
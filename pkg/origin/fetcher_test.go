@@ -0,0 +1,163 @@
+package origin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/internal/s3client"
+	"github.com/seraphico/EdgeOrigin/internal/s3test"
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// fetcherFunc 把函数用作Fetcher
+type fetcherFunc func(ctx context.Context, req *http.Request) (*http.Response, error)
+
+func (f fetcherFunc) Fetch(ctx context.Context, req *http.Request) (*http.Response, error) {
+	return f(ctx, req)
+}
+
+func TestCustomFetcher(t *testing.T) {
+	var paths []string
+	fetcher := fetcherFunc(func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		paths = append(paths, req.URL.RequestURI())
+		if req.Header.Get("X-Forwarded-Host") != "example.com" {
+			t.Errorf("Expected X-Forwarded-Host, got %v", req.Header)
+		}
+		return newResponse(req, http.StatusOK, http.Header{"Content-Type": {"text/plain"}}, io.NopCloser(strings.NewReader("custom")), 6), nil
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{Fetcher: fetcher, DefaultTTL: time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to create proxy without upstream: %v", err)
+	}
+	for _, want := range []string{"MISS", "HIT"} {
+		resp, body := get(t, proxy, http.MethodGet, "/a?b=c")
+		if resp.Header.Get("X-Cache") != want || body != "custom" {
+			t.Errorf("Expected %s custom, got %q %q", want, resp.Header.Get("X-Cache"), body)
+		}
+	}
+	if len(paths) != 1 || paths[0] != "/a?b=c" {
+		t.Errorf("Expected one fetch of the client path, got %v", paths)
+	}
+}
+
+func TestDirFetcher(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "docs"), 0o755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "docs", "readme.txt"), []byte("hello world"), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	fetcher := NewDirFetcher(root)
+	fetch := func(method, target string, header ...string) (*http.Response, string) {
+		t.Helper()
+		req := httptest.NewRequest(method, target, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := fetcher.Fetch(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Failed to fetch %s: %v", target, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	resp, body := fetch(http.MethodGet, "/docs/readme.txt")
+	if resp.StatusCode != http.StatusOK || body != "hello world" || resp.ContentLength != 11 {
+		t.Fatalf("Expected file contents, got %d %q", resp.StatusCode, body)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") || resp.Header.Get("ETag") == "" {
+		t.Errorf("Expected Content-Type and ETag, got %v", resp.Header)
+	}
+	etag := resp.Header.Get("ETag")
+
+	tests := []struct {
+		name, method, target string
+		header               []string
+		status               int
+		body                 string
+	}{
+		{"Range", http.MethodGet, "/docs/readme.txt", []string{"Range", "bytes=6-"}, http.StatusPartialContent, "world"},
+		{"IfRangeMismatch", http.MethodGet, "/docs/readme.txt", []string{"Range", "bytes=6-", "If-Range", `"old"`}, http.StatusOK, "hello world"},
+		{"NotSatisfiable", http.MethodGet, "/docs/readme.txt", []string{"Range", "bytes=20-"}, http.StatusRequestedRangeNotSatisfiable, ""},
+		{"NotModified", http.MethodGet, "/docs/readme.txt", []string{"If-None-Match", etag}, http.StatusNotModified, ""},
+		{"Missing", http.MethodGet, "/docs/missing.txt", nil, http.StatusNotFound, ""},
+		{"Directory", http.MethodGet, "/docs", nil, http.StatusNotFound, ""},
+		{"Traversal", http.MethodGet, "/../" + filepath.Base(root) + "/docs/readme.txt", nil, http.StatusNotFound, ""},
+		{"Method", http.MethodPost, "/docs/readme.txt", nil, http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := fetch(tt.method, tt.target, tt.header...)
+			if resp.StatusCode != tt.status || tt.body != "" && body != tt.body {
+				t.Errorf("Expected %d %q, got %d %q", tt.status, tt.body, resp.StatusCode, body)
+			}
+		})
+	}
+}
+
+func TestS3Fetcher(t *testing.T) {
+	server := s3test.NewServer("origin")
+	defer server.Close()
+	client, err := s3client.New(s3client.Config{Endpoint: server.URL, Bucket: "origin", AccessKeyID: "key", SecretAccessKey: "secret", PathStyle: true})
+	if err != nil {
+		t.Fatalf("Failed to create s3 client: %v", err)
+	}
+	header := http.Header{"Content-Type": {"text/html"}}
+	if err := client.PutObject(context.Background(), "site/index.html", strings.NewReader("<h1>home</h1>"), 13, header); err != nil {
+		t.Fatalf("Failed to put object: %v", err)
+	}
+
+	fetcher, err := NewS3Fetcher(S3Origin{Endpoint: server.URL, Bucket: "origin", Prefix: "site/", AccessKeyID: "key", SecretAccessKey: "secret", PathStyle: true})
+	if err != nil {
+		t.Fatalf("Failed to create s3 fetcher: %v", err)
+	}
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{Fetcher: fetcher, DefaultTTL: time.Hour, NegativeTTL: map[int]time.Duration{http.StatusNotFound: time.Minute}})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	for _, want := range []string{"MISS", "HIT"} {
+		resp, body := get(t, proxy, http.MethodGet, "/index.html")
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Cache") != want || body != "<h1>home</h1>" {
+			t.Errorf("Expected 200 %s, got %d %q %q", want, resp.StatusCode, resp.Header.Get("X-Cache"), body)
+		}
+		if resp.Header.Get("Content-Type") != "text/html" || resp.Header.Get("ETag") == "" {
+			t.Errorf("Expected object headers, got %v", resp.Header)
+		}
+	}
+	for _, want := range []string{"MISS", "HIT"} {
+		if resp, _ := get(t, proxy, http.MethodGet, "/missing.html"); resp.StatusCode != http.StatusNotFound || resp.Header.Get("X-Cache") != want {
+			t.Errorf("Expected 404 %s, got %d %q", want, resp.StatusCode, resp.Header.Get("X-Cache"))
+		}
+	}
+	req := httptest.NewRequest(http.MethodGet, "/index.html", nil)
+	req.Header.Set("Range", "bytes=4-7")
+	resp, err := fetcher.Fetch(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Failed to fetch range: %v", err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); resp.StatusCode != http.StatusPartialContent || string(body) != "home" {
+		t.Errorf("Expected 206 home, got %d %q", resp.StatusCode, body)
+	}
+
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/index.html", strings.NewReader("x")))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for PUT, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,191 @@
+package origin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// HeaderRule 按请求路径修改请求头和响应头的规则，匹配的规则按顺序全部应用
+type HeaderRule struct {
+	// Path 匹配改写后的请求路径：以"*"结尾时按前缀匹配，否则只匹配相同的路径；为空时匹配所有请求
+	Path string `json:"path,omitempty"`
+
+	// Request 修改发送到上游的请求头，例如带上固定的认证头
+	Request *HeaderActions `json:"request,omitempty"`
+
+	// Upstream 在判断是否可缓存和写入缓存之前修改上游的响应头，例如去掉Set-Cookie、改写Cache-Control
+	Upstream *HeaderActions `json:"upstream,omitempty"`
+
+	// Response 修改返回给客户端的响应头，包括缓存命中的响应，例如加上Strict-Transport-Security
+	Response *HeaderActions `json:"response,omitempty"`
+}
+
+// HeaderActions 对一组请求头或响应头的修改，按Remove、Set、Add的顺序执行
+type HeaderActions struct {
+	// Set 设置请求头，替换已有的值
+	Set map[string]string `json:"set,omitempty"`
+
+	// Add 追加请求头，保留已有的值
+	Add map[string]string `json:"add,omitempty"`
+
+	// Remove 删除的请求头，以"*"结尾时删除以"*"之前部分开头的所有请求头，例如"X-Amz-*"
+	Remove []string `json:"remove,omitempty"`
+}
+
+// validate 检查规则
+func (rule *HeaderRule) validate() error {
+	if rule.Path != "" && !strings.HasPrefix(rule.Path, "/") {
+		return fmt.Errorf("invalid header rule path %q: must start with /", rule.Path)
+	}
+	for _, actions := range []*HeaderActions{rule.Request, rule.Upstream, rule.Response} {
+		if actions == nil {
+			continue
+		}
+		for _, names := range [][]string{keys(actions.Set), keys(actions.Add), actions.Remove} {
+			for _, name := range names {
+				if strings.TrimSuffix(name, "*") == "" || strings.ContainsAny(name, " \t:\r\n") {
+					return fmt.Errorf("invalid header name %q", name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// matches 返回规则是否匹配请求路径
+func (rule *HeaderRule) matches(path string) bool {
+	_, ok := matchPath(rule.Path, path)
+	return rule.Path == "" || ok
+}
+
+// apply 修改h
+func (a *HeaderActions) apply(h http.Header) {
+	if len(a.Remove) > 0 {
+		for name := range h {
+			for _, pattern := range a.Remove {
+				if matchParam([]string{http.CanonicalHeaderKey(pattern)}, name) {
+					delete(h, name)
+					break
+				}
+			}
+		}
+	}
+	for name, value := range a.Set {
+		h.Set(name, value)
+	}
+	for name, value := range a.Add {
+		h.Add(name, value)
+	}
+}
+
+// keys 返回map的键
+func keys(m map[string]string) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	return names
+}
+
+// matchPath 按前缀规则匹配路径：pattern以"*"结尾时按前缀匹配并返回剩余部分，否则只匹配相同的路径
+func matchPath(pattern, path string) (string, bool) {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		if !strings.HasPrefix(path, prefix) {
+			return "", false
+		}
+		return path[len(prefix):], true
+	}
+	return "", path == pattern
+}
+
+// headerActions 返回匹配路径的规则中选出的修改
+func headerActions(rules []HeaderRule, path string, pick func(*HeaderRule) *HeaderActions) []*HeaderActions {
+	var actions []*HeaderActions
+	for i := range rules {
+		if a := pick(&rules[i]); a != nil && rules[i].matches(path) {
+			actions = append(actions, a)
+		}
+	}
+	return actions
+}
+
+// headerFetcher 按规则修改发送到上游的请求头和上游的响应头
+type headerFetcher struct {
+	next  Fetcher
+	rules []HeaderRule
+}
+
+// Fetch 修改请求头后交给next，再修改响应头
+func (f *headerFetcher) Fetch(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if actions := headerActions(f.rules, req.URL.Path, func(rule *HeaderRule) *HeaderActions { return rule.Request }); len(actions) > 0 {
+		req = req.Clone(ctx)
+		for _, a := range actions {
+			a.apply(req.Header)
+		}
+	}
+	resp, err := f.next.Fetch(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range headerActions(f.rules, req.URL.Path, func(rule *HeaderRule) *HeaderActions { return rule.Upstream }) {
+		a.apply(resp.Header)
+	}
+	return resp, nil
+}
+
+// Close 关闭next
+func (f *headerFetcher) Close() error {
+	if closer, ok := f.next.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// headerWriter 写入响应头之前按规则修改
+type headerWriter struct {
+	http.ResponseWriter
+	actions     []*HeaderActions
+	wroteHeader bool
+}
+
+// WriteHeader 修改响应头后写入
+func (w *headerWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		for _, a := range w.actions {
+			a.apply(w.Header())
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write 写入响应体，没有写入响应头时先写入200
+func (w *headerWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// ReadFrom 交给底层的ResponseWriter，缓存中的*os.File仍然可以由sendfile发送
+func (w *headerWriter) ReadFrom(src io.Reader) (int64, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return io.Copy(w.ResponseWriter, src)
+}
+
+// Flush 底层的ResponseWriter支持时发送已写入的数据
+func (w *headerWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap 返回底层的ResponseWriter，供http.ResponseController使用
+func (w *headerWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
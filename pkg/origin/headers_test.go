@@ -0,0 +1,83 @@
+package origin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestHeaderRules(t *testing.T) {
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=1")
+		w.Header().Set("X-Internal-Debug", "host-7")
+		w.Header().Set("X-Internal-Trace", "abc")
+		w.Header().Set("X-Auth", r.Header.Get("Authorization"))
+		w.Header().Set("X-Cookie", r.Header.Get("Cookie"))
+		io.WriteString(w, "ok")
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{
+		Upstream:   upstream.URL,
+		DefaultTTL: time.Hour,
+		Headers: []HeaderRule{
+			{
+				Request:  &HeaderActions{Set: map[string]string{"Authorization": "Bearer origin-token"}, Remove: []string{"Cookie"}},
+				Upstream: &HeaderActions{Remove: []string{"Set-Cookie", "x-internal-*"}},
+				Response: &HeaderActions{Set: map[string]string{"Strict-Transport-Security": "max-age=63072000"}},
+			},
+			{Path: "/api/*", Response: &HeaderActions{Add: map[string]string{"X-Route": "api"}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	request := func(target string) *http.Response {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Cookie", "tracking=1")
+		proxy.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+
+	for _, want := range []string{"MISS", "HIT"} {
+		resp := request("/page")
+		if resp.Header.Get("X-Cache") != want {
+			t.Fatalf("Expected %s, got %q", want, resp.Header.Get("X-Cache"))
+		}
+		if resp.Header.Get("Strict-Transport-Security") == "" {
+			t.Errorf("Expected HSTS on %s response", want)
+		}
+		if resp.Header.Get("Set-Cookie") != "" || resp.Header.Get("X-Internal-Debug") != "" || resp.Header.Get("X-Internal-Trace") != "" {
+			t.Errorf("Expected upstream headers to be stripped before caching, got %v", resp.Header)
+		}
+		if resp.Header.Get("X-Auth") != "Bearer origin-token" || resp.Header.Get("X-Cookie") != "" {
+			t.Errorf("Expected origin auth header without client cookie, got %q %q", resp.Header.Get("X-Auth"), resp.Header.Get("X-Cookie"))
+		}
+	}
+
+	if resp := request("/api/items"); resp.Header.Get("X-Route") != "api" {
+		t.Errorf("Expected route header on /api/items, got %v", resp.Header)
+	}
+	if resp := request("/page"); resp.Header.Get("X-Route") != "" {
+		t.Errorf("Expected no route header on /page, got %q", resp.Header.Get("X-Route"))
+	}
+
+	t.Run("invalid rules", func(t *testing.T) {
+		for _, rule := range []HeaderRule{
+			{Path: "api/*"},
+			{Response: &HeaderActions{Set: map[string]string{"Bad Name": "x"}}},
+			{Upstream: &HeaderActions{Remove: []string{"*"}}},
+		} {
+			if _, err := NewProxy(cache, Options{Upstream: upstream.URL, Headers: []HeaderRule{rule}}); err == nil {
+				t.Errorf("Expected error for rule %+v", rule)
+			}
+		}
+	})
+}
@@ -0,0 +1,207 @@
+package origin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHealthPath      = "/"
+	defaultHealthTimeout   = 5 * time.Second
+	defaultHealthThreshold = 3
+	defaultHealthCooldown  = 10 * time.Second
+)
+
+// ErrCircuitOpen 所有上游的熔断器都处于打开状态，请求没有发送到上游
+var ErrCircuitOpen = errors.New("all upstreams are unavailable")
+
+// HealthCheck 上游的健康检查和熔断：连续失败Threshold次（回源出错、超时或返回需要故障转移的状态码，
+// 或主动探测失败）后熔断该上游，Cooldown内不再发送请求；Cooldown后进入半开状态，
+// 只放行一个请求或探测，成功时恢复，失败时再熔断Cooldown。所有上游都熔断时立即返回ErrCircuitOpen，
+// 代理有过期的缓存条目时返回旧内容，否则返回503，不必等待连接超时
+type HealthCheck struct {
+	// Path 主动探测的路径，默认"/"
+	Path string `json:"path,omitempty"`
+
+	// Interval 主动探测的间隔，0表示不主动探测，只按回源的结果熔断
+	Interval time.Duration `json:"interval,omitempty"`
+
+	// Timeout 探测等待响应的时间，默认5秒
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// Threshold 熔断前连续失败的次数，默认3
+	Threshold int `json:"threshold,omitempty"`
+
+	// Cooldown 熔断后进入半开状态之前的时间，默认10秒
+	Cooldown time.Duration `json:"cooldown,omitempty"`
+}
+
+// validate 检查选项并填充默认值
+func (c *HealthCheck) validate() error {
+	if c.Interval < 0 || c.Timeout < 0 || c.Threshold < 0 || c.Cooldown < 0 {
+		return errors.New("health check options cannot be negative")
+	}
+	if c.Path == "" {
+		c.Path = defaultHealthPath
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultHealthTimeout
+	}
+	if c.Threshold == 0 {
+		c.Threshold = defaultHealthThreshold
+	}
+	if c.Cooldown == 0 {
+		c.Cooldown = defaultHealthCooldown
+	}
+	return nil
+}
+
+// outcome 一次请求或探测的结果
+type outcome int
+
+const (
+	outcomeNone    outcome = iota // 调用方取消，不计入
+	outcomeSuccess                // 上游正常响应
+	outcomeFailure                // 上游出错、超时或返回需要故障转移的状态码
+)
+
+// breaker 一个上游的熔断器
+type breaker struct {
+	mu        sync.Mutex
+	failures  int       // 连续失败次数
+	openUntil time.Time // 熔断结束的时间，零值表示未熔断
+	trial     bool      // 半开状态下已放行一个请求，等待它的结果
+}
+
+// allow 返回是否可以向上游发送请求；熔断结束后只放行一个请求，由它的结果决定是否恢复
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.openUntil.IsZero():
+		return true
+	case now.Before(b.openUntil) || b.trial:
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// record 记录请求或探测的结果：成功时恢复；失败次数达到threshold或半开状态下失败时熔断cooldown
+func (b *breaker) record(result outcome, threshold int, cooldown time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	switch result {
+	case outcomeSuccess:
+		b.failures, b.openUntil = 0, time.Time{}
+	case outcomeFailure:
+		b.failures++
+		if b.failures >= threshold || !b.openUntil.IsZero() {
+			b.openUntil = time.Now().Add(cooldown)
+		}
+	}
+}
+
+// probe 每Interval向每个上游发送一次探测，直到stop关闭
+func (f *failoverFetcher) probe(target *upstreamTarget) {
+	defer f.wg.Done()
+	ticker := time.NewTicker(f.opts.HealthCheck.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			target.breaker.record(f.check(target), f.opts.HealthCheck.Threshold, f.opts.HealthCheck.Cooldown)
+		}
+	}
+}
+
+// check 向上游发送一次探测，不是5xx且不是需要故障转移的状态码时成功
+func (f *failoverFetcher) check(target *upstreamTarget) outcome {
+	ctx, cancel := context.WithTimeout(context.Background(), f.opts.HealthCheck.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.opts.HealthCheck.Path, nil)
+	if err != nil {
+		return outcomeFailure
+	}
+	resp, err := target.fetcher.Fetch(ctx, req)
+	if err != nil {
+		return outcomeFailure
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError || f.failover(resp.StatusCode) {
+		return outcomeFailure
+	}
+	return outcomeSuccess
+}
+
+// Close 停止主动探测
+func (f *failoverFetcher) Close() error {
+	f.closeOnce.Do(func() { close(f.stop) })
+	f.wg.Wait()
+	return nil
+}
+
+// UpstreamHealth 一个上游的健康状态
+type UpstreamHealth struct {
+	Site      string    `json:"site,omitempty"`       // 上游所属的站点（Router中第一个主机名）
+	Name      string    `json:"name"`                 // 上游地址，设置了Fetcher的上游为"upstream N"
+	Priority  int       `json:"priority"`             // 优先级
+	Healthy   bool      `json:"healthy"`              // 熔断器没有打开
+	Failures  int       `json:"failures"`             // 连续失败次数
+	OpenUntil time.Time `json:"open_until,omitempty"` // 熔断结束的时间，之后进入半开状态
+}
+
+// healthReporter 能够报告上游健康状态的Fetcher
+type healthReporter interface {
+	UpstreamHealth() []UpstreamHealth
+}
+
+// state 返回熔断器的状态
+func (b *breaker) state() (failures int, openUntil time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures, b.openUntil
+}
+
+// UpstreamHealth 返回每个上游的健康状态，按优先级排列
+func (f *failoverFetcher) UpstreamHealth() []UpstreamHealth {
+	health := make([]UpstreamHealth, 0, len(f.targets))
+	for _, target := range f.targets {
+		failures, openUntil := target.breaker.state()
+		health = append(health, UpstreamHealth{
+			Name:      target.name,
+			Priority:  target.priority,
+			Healthy:   openUntil.IsZero(),
+			Failures:  failures,
+			OpenUntil: openUntil,
+		})
+	}
+	return health
+}
+
+// UpstreamHealth 返回上游的健康状态；只有使用Upstreams或HealthCheck（或Fetcher由NewFailoverFetcher创建）时才有，否则为空
+func (p *Proxy) UpstreamHealth() []UpstreamHealth {
+	if p.health == nil {
+		return nil
+	}
+	return p.health.UpstreamHealth()
+}
+
+// UpstreamHealth 返回所有站点的上游健康状态，Site为站点的第一个主机名
+func (rt *Router) UpstreamHealth() []UpstreamHealth {
+	var health []UpstreamHealth
+	table := rt.routes.Load()
+	for i, proxy := range table.proxies {
+		for _, h := range proxy.UpstreamHealth() {
+			h.Site = table.sites[i]
+			health = append(health, h)
+		}
+	}
+	return health
+}
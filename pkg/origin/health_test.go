@@ -0,0 +1,162 @@
+package origin
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var down atomic.Bool
+	var pulls atomic.Int64
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			if down.Load() {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+		pulls.Add(1)
+		if down.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=1")
+		io.WriteString(w, "ok")
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+
+	t.Run("trip and half-open", func(t *testing.T) {
+		proxy, err := NewProxy(cache, Options{
+			Upstream:    upstream.URL,
+			HealthCheck: &HealthCheck{Threshold: 2, Cooldown: 200 * time.Millisecond},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create proxy: %v", err)
+		}
+		defer proxy.Close()
+		down.Store(true)
+		defer down.Store(false)
+		before := pulls.Load()
+		for i := 0; i < 2; i++ {
+			if resp, _ := get(t, proxy, http.MethodGet, "/trip"); resp.StatusCode != http.StatusBadGateway {
+				t.Fatalf("Expected upstream 502, got %d", resp.StatusCode)
+			}
+		}
+		resp, _ := get(t, proxy, http.MethodGet, "/trip")
+		if resp.StatusCode != http.StatusServiceUnavailable || pulls.Load() != before+2 {
+			t.Fatalf("Expected fast 503 without pulling, got %d after %d pulls", resp.StatusCode, pulls.Load()-before)
+		}
+		if health := proxy.UpstreamHealth(); len(health) != 1 || health[0].Healthy || health[0].Failures != 2 || health[0].Name != upstream.URL {
+			t.Errorf("Expected open breaker in upstream health, got %+v", health)
+		}
+
+		down.Store(false)
+		time.Sleep(250 * time.Millisecond)
+		if resp, body := get(t, proxy, http.MethodGet, "/trip"); resp.StatusCode != http.StatusOK || body != "ok" {
+			t.Fatalf("Expected half-open request to recover, got %d %q", resp.StatusCode, body)
+		}
+		if resp, _ := get(t, proxy, http.MethodGet, "/other"); resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected closed breaker after recovery, got %d", resp.StatusCode)
+		}
+		if health := proxy.UpstreamHealth(); len(health) != 1 || !health[0].Healthy || health[0].Failures != 0 {
+			t.Errorf("Expected healthy upstream after recovery, got %+v", health)
+		}
+	})
+
+	t.Run("serve stale while open", func(t *testing.T) {
+		proxy, err := NewProxy(cache, Options{
+			Upstream:    upstream.URL,
+			HealthCheck: &HealthCheck{Threshold: 1, Cooldown: time.Minute},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create proxy: %v", err)
+		}
+		defer proxy.Close()
+		if _, body := get(t, proxy, http.MethodGet, "/page"); body != "ok" {
+			t.Fatalf("Expected ok, got %q", body)
+		}
+		down.Store(true)
+		defer down.Store(false)
+		time.Sleep(1100 * time.Millisecond)
+		get(t, proxy, http.MethodGet, "/page")
+		resp, body := get(t, proxy, http.MethodGet, "/page")
+		if resp.StatusCode != http.StatusOK || body != "ok" || resp.Header.Get("X-Cache") != "STALE" {
+			t.Errorf("Expected stale ok while open, got %d %q %q", resp.StatusCode, body, resp.Header.Get("X-Cache"))
+		}
+	})
+
+	t.Run("active probing", func(t *testing.T) {
+		proxy, err := NewProxy(cache, Options{
+			Upstream:    upstream.URL,
+			HealthCheck: &HealthCheck{Path: "/healthz", Interval: 10 * time.Millisecond, Threshold: 2, Cooldown: time.Minute},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create proxy: %v", err)
+		}
+		defer proxy.Close()
+		down.Store(true)
+		defer down.Store(false)
+		waitStatus(t, proxy, "/probe", http.StatusServiceUnavailable)
+		before := pulls.Load()
+		get(t, proxy, http.MethodGet, "/probe")
+		if pulls.Load() != before {
+			t.Errorf("Expected no pulls while open, got %d", pulls.Load()-before)
+		}
+		// 探测成功时不等Cooldown结束就恢复
+		down.Store(false)
+		waitStatus(t, proxy, "/probe", http.StatusOK)
+	})
+
+	t.Run("skip open upstream", func(t *testing.T) {
+		secondary := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "secondary")
+		})
+		proxy, err := NewProxy(cache, Options{
+			Upstreams:   []UpstreamTarget{{URL: upstream.URL}, {URL: secondary.URL, Priority: 1}},
+			HealthCheck: &HealthCheck{Threshold: 1, Cooldown: time.Minute},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create proxy: %v", err)
+		}
+		defer proxy.Close()
+		down.Store(true)
+		defer down.Store(false)
+		before := pulls.Load()
+		for _, path := range []string{"/s1", "/s2", "/s3"} {
+			if _, body := get(t, proxy, http.MethodGet, path); body != "secondary" {
+				t.Fatalf("Expected secondary, got %q", body)
+			}
+		}
+		if pulls.Load() != before+1 {
+			t.Errorf("Expected one pull from the primary before it opened, got %d", pulls.Load()-before)
+		}
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		if _, err := NewProxy(cache, Options{Upstream: upstream.URL, HealthCheck: &HealthCheck{Threshold: -1}}); err == nil {
+			t.Error("Expected error for negative threshold")
+		}
+	})
+}
+
+// waitStatus 轮询直到请求返回status
+func waitStatus(t *testing.T, h http.Handler, target string, status int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		resp, _ := get(t, h, http.MethodGet, target)
+		if resp.StatusCode == status {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected status %d, got %d", status, resp.StatusCode)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
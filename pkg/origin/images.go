@@ -0,0 +1,476 @@
+package origin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 转换后图片的缓存键：原图的键加imageSeparator和规范化的转换参数；
+// 原图已缓存的转换参数列表在原图的键后加imageIndexSuffix
+const (
+	imageSeparator   = "#image:"
+	imageIndexSuffix = "#images"
+)
+
+const (
+	defaultImageMaxDimension  = 4096
+	defaultImageQuality       = 85
+	defaultImageDerivatives   = 16
+	defaultImageMaxSourceSize = 20 << 20
+
+	// maxImagePixels 原图的最大像素数，避免很小的文件解码出巨大的图片
+	maxImagePixels = 50_000_000
+)
+
+// imageParams 图片转换的查询参数：宽、高、输出格式和质量
+var imageParams = []string{"w", "h", "format", "q"}
+
+var (
+	errImageTooLarge    = errors.New("image too large to transform")
+	errDerivativeQuota  = errors.New("derivative quota exceeded")
+	errUnsupportedImage = errors.New("unsupported image")
+)
+
+// ImageEncoder 把图片按quality（1-100，格式不支持时忽略）编码写入w
+type ImageEncoder func(w io.Writer, img image.Image, quality int) error
+
+// ImageOptions 按查询参数转换缓存中的图片，例如?w=300&format=webp：宽高不超过原图并保持比例，
+// 每种转换结果作为原图的派生条目单独缓存，TTL与原图剩余的TTL相同
+type ImageOptions struct {
+	// MaxWidth 和 MaxHeight 允许请求的最大宽高，默认4096
+	MaxWidth  int `json:"max_width,omitempty"`
+	MaxHeight int `json:"max_height,omitempty"`
+
+	// Quality 请求没有q参数时的质量，默认85
+	Quality int `json:"quality,omitempty"`
+
+	// MaxDerivatives 每张原图最多缓存的转换结果数，默认16；超出时照常转换但不缓存，
+	// 避免客户端枚举参数占满缓存
+	MaxDerivatives int `json:"max_derivatives,omitempty"`
+
+	// MaxSourceSize 可以转换的原图的最大长度，默认20MB
+	MaxSourceSize int64 `json:"max_source_size,omitempty"`
+
+	// MaxConcurrent 同时进行的转换数，默认为CPU数
+	MaxConcurrent int `json:"max_concurrent,omitempty"`
+
+	// Encoders 输出格式到编码实现的映射，覆盖内置的jpeg、png和gif，例如提供webp；
+	// 读取其他格式的原图需要用image.RegisterFormat注册解码器
+	Encoders map[string]ImageEncoder `json:"-"`
+}
+
+// validate 检查选项并填充默认值
+func (o *ImageOptions) validate() error {
+	if o.MaxWidth < 0 || o.MaxHeight < 0 || o.MaxDerivatives < 0 || o.MaxSourceSize < 0 || o.MaxConcurrent < 0 {
+		return fmt.Errorf("image options cannot be negative")
+	}
+	if o.Quality < 0 || o.Quality > 100 {
+		return fmt.Errorf("invalid image quality %d", o.Quality)
+	}
+	if o.MaxWidth == 0 {
+		o.MaxWidth = defaultImageMaxDimension
+	}
+	if o.MaxHeight == 0 {
+		o.MaxHeight = defaultImageMaxDimension
+	}
+	if o.Quality == 0 {
+		o.Quality = defaultImageQuality
+	}
+	if o.MaxDerivatives == 0 {
+		o.MaxDerivatives = defaultImageDerivatives
+	}
+	if o.MaxSourceSize == 0 {
+		o.MaxSourceSize = defaultImageMaxSourceSize
+	}
+	if o.MaxConcurrent == 0 {
+		o.MaxConcurrent = runtime.GOMAXPROCS(0)
+	}
+	return nil
+}
+
+// encoder 返回输出格式的编码实现，没有时返回nil
+func (o *ImageOptions) encoder(format string) ImageEncoder {
+	if encoder, ok := o.Encoders[format]; ok {
+		return encoder
+	}
+	switch format {
+	case "jpeg":
+		return func(w io.Writer, img image.Image, quality int) error {
+			return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+		}
+	case "png":
+		return func(w io.Writer, img image.Image, _ int) error { return png.Encode(w, img) }
+	case "gif":
+		return func(w io.Writer, img image.Image, _ int) error { return gif.Encode(w, img, nil) }
+	}
+	return nil
+}
+
+// requested 返回请求是否带有图片转换参数
+func (o *ImageOptions) requested(query url.Values) bool {
+	for _, name := range imageParams {
+		if query.Has(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// imageSpec 规范化的转换参数，Format为空时输出与原图相同的格式
+type imageSpec struct {
+	Width, Height, Quality int
+	Format                 string
+}
+
+// String 返回转换参数在缓存键中的形式
+func (s imageSpec) String() string {
+	format := s.Format
+	if format == "" {
+		format = "auto"
+	}
+	return fmt.Sprintf("w%d,h%d,q%d,%s", s.Width, s.Height, s.Quality, format)
+}
+
+// parse 解析并检查转换参数
+func (o *ImageOptions) parse(query url.Values) (imageSpec, error) {
+	spec := imageSpec{Quality: o.Quality}
+	dimension := func(name string, limit int) (int, error) {
+		v := query.Get(name)
+		if v == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > limit {
+			return 0, fmt.Errorf("invalid image %s %q: must be between 1 and %d", name, v, limit)
+		}
+		return n, nil
+	}
+	var err error
+	if spec.Width, err = dimension("w", o.MaxWidth); err != nil {
+		return spec, err
+	}
+	if spec.Height, err = dimension("h", o.MaxHeight); err != nil {
+		return spec, err
+	}
+	if q := query.Get("q"); q != "" {
+		if spec.Quality, err = strconv.Atoi(q); err != nil || spec.Quality < 1 || spec.Quality > 100 {
+			return spec, fmt.Errorf("invalid image quality %q: must be between 1 and 100", q)
+		}
+	}
+	if format := strings.ToLower(query.Get("format")); format != "" {
+		if format == "jpg" {
+			format = "jpeg"
+		}
+		if o.encoder(format) == nil {
+			return spec, fmt.Errorf("unsupported image format %q", format)
+		}
+		spec.Format = format
+	}
+	return spec, nil
+}
+
+// fitSize 返回在width×height内保持比例的尺寸，0表示不限制该方向；不放大原图
+func fitSize(srcWidth, srcHeight, width, height int) (int, int) {
+	scale := 1.0
+	if width > 0 && float64(width)/float64(srcWidth) < scale {
+		scale = float64(width) / float64(srcWidth)
+	}
+	if height > 0 && float64(height)/float64(srcHeight) < scale {
+		scale = float64(height) / float64(srcHeight)
+	}
+	w, h := int(float64(srcWidth)*scale+0.5), int(float64(srcHeight)*scale+0.5)
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return w, h
+}
+
+// resize 用区域平均把图片缩小到width×height
+func resize(src image.Image, width, height int) image.Image {
+	bounds := src.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	if width == srcWidth && height == srcHeight {
+		return src
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, srcWidth, srcHeight))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*srcHeight/height, (y+1)*srcHeight/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0, x1 := x*srcWidth/width, (x+1)*srcWidth/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var sum [4]uint64
+			for sy := y0; sy < y1; sy++ {
+				offset := rgba.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += uint64(rgba.Pix[offset+c])
+					}
+					offset += 4
+				}
+			}
+			n := uint64((y1 - y0) * (x1 - x0))
+			offset := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[offset+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
+
+// transform 解码原图，按spec缩放并编码，返回结果和Content-Type
+func (o *ImageOptions) transform(data []byte, spec imageSpec) ([]byte, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", errUnsupportedImage
+	}
+	if int64(config.Width)*int64(config.Height) > maxImagePixels {
+		return nil, "", errImageTooLarge
+	}
+	if spec.Format != "" {
+		format = spec.Format
+	}
+	encoder := o.encoder(format)
+	if encoder == nil {
+		return nil, "", errUnsupportedImage
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", errUnsupportedImage
+	}
+	width, height := fitSize(config.Width, config.Height, spec.Width, spec.Height)
+	var out bytes.Buffer
+	if err := encoder(&out, resize(img, width, height), spec.Quality); err != nil {
+		return nil, "", fmt.Errorf("failed to encode %s image: %w", format, err)
+	}
+	return out.Bytes(), "image/" + format, nil
+}
+
+// responseBuffer 在内存中接收响应，用于读取要转换的原图，超过limit时写入失败
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	limit  int64
+	err    error
+}
+
+// Header 返回响应头
+func (b *responseBuffer) Header() http.Header {
+	return b.header
+}
+
+// WriteHeader 记录状态码
+func (b *responseBuffer) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+// Write 写入响应体
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	if b.err != nil {
+		return 0, b.err
+	}
+	if int64(b.body.Len()+len(p)) > b.limit {
+		b.err = errImageTooLarge
+		return 0, b.err
+	}
+	return b.body.Write(p)
+}
+
+// Flush 实现http.Flusher：回源的原图可缓存时等写入缓存完成后再返回，之后可以按原图缓存转换结果
+func (b *responseBuffer) Flush() {}
+
+// writeTo 把缓冲的响应原样返回给客户端
+func (b *responseBuffer) writeTo(w http.ResponseWriter, r *http.Request) {
+	copyHeader(w.Header(), b.header)
+	w.WriteHeader(b.status)
+	if r.Method == http.MethodGet {
+		w.Write(b.body.Bytes())
+	}
+}
+
+// sourceEntry 返回key上原图条目的元数据、实际的键（有Vary时为变体键）和剩余的TTL，未缓存时返回nil
+func (p *Proxy) sourceEntry(ctx context.Context, key string, h http.Header) (*entry, string, time.Duration) {
+	reader, info, err := p.cache.Get(ctx, key)
+	if err != nil {
+		return nil, "", 0
+	}
+	e, _, _, err := readEntry(reader)
+	reader.Close()
+	if err != nil || e == nil {
+		return nil, "", 0
+	}
+	if len(e.Vary) > 0 {
+		return p.sourceEntry(ctx, variantKey(key, e.Vary, h), h)
+	}
+	ttl, _ := info.RemainingTTL()
+	return e, key, ttl
+}
+
+// serveImage 返回按查询参数转换后的图片：缓存中有与当前原图对应的转换结果时直接返回，
+// 否则经过缓存读取原图并转换；原图不是可转换的图片时原样返回
+func (p *Proxy) serveImage(w http.ResponseWriter, r *http.Request) {
+	o := p.opts.Images
+	spec, err := o.parse(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	src := r.Clone(r.Context())
+	src.Method = http.MethodGet
+	src.URL.RawQuery = removeParams(r.URL.RawQuery, imageParams...)
+	src.RequestURI = src.URL.RequestURI()
+	src.Header.Del("Range")
+	stripConditional(src.Header)
+	key := p.key(src)
+
+	if source, sourceKey, _ := p.sourceEntry(r.Context(), key, src.Header); source != nil {
+		if p.serveDerivative(w, r, sourceKey+imageSeparator+spec.String(), source) {
+			return
+		}
+	}
+
+	buf := &responseBuffer{header: http.Header{}, limit: o.MaxSourceSize}
+	p.serve(buf, src)
+	if buf.err != nil {
+		http.Error(w, buf.err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if buf.status != http.StatusOK {
+		buf.writeTo(w, r)
+		return
+	}
+	if _, ok := parseCacheControl(buf.header)["no-transform"]; ok {
+		buf.writeTo(w, r)
+		return
+	}
+
+	select {
+	case p.transformSem <- struct{}{}:
+	case <-r.Context().Done():
+		return
+	}
+	data, mimeType, err := o.transform(buf.body.Bytes(), spec)
+	<-p.transformSem
+	switch {
+	case errors.Is(err, errUnsupportedImage):
+		buf.writeTo(w, r)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	header := buf.header.Clone()
+	for _, name := range []string{"Accept-Ranges", "Age", "Content-Length", "Content-Encoding", "X-Cache"} {
+		header.Del(name)
+	}
+	header.Set("Content-Type", mimeType)
+	if etag := header.Get("ETag"); etag != "" {
+		header.Set("ETag", encodedETag(etag, spec.String()))
+	}
+	e := &entry{Status: http.StatusOK, Header: header, StoredAt: time.Now()}
+	status := "BYPASS"
+	if source, sourceKey, ttl := p.sourceEntry(r.Context(), key, src.Header); source != nil {
+		e.StoredAt, e.InitialAge, e.Tags = source.StoredAt, source.InitialAge, source.Tags
+		if p.storeDerivative(r.Context(), sourceKey, spec.String(), e, data, ttl) == nil {
+			status = "MISS"
+		}
+	}
+	p.writeEntry(w, r, e, bytes.NewReader(data), int64(len(data)), status)
+}
+
+// serveDerivative 返回缓存中的转换结果，不存在或不是由当前的原图转换的时返回false
+func (p *Proxy) serveDerivative(w http.ResponseWriter, r *http.Request, derivedKey string, source *entry) bool {
+	reader, info, err := p.cache.Get(r.Context(), derivedKey)
+	if err != nil {
+		return false
+	}
+	defer reader.Close()
+	e, body, headerSize, err := readEntry(reader)
+	if err != nil || e == nil || !e.StoredAt.Equal(source.StoredAt) {
+		return false
+	}
+	p.writeEntry(w, r, e, body, info.Size-headerSize, "HIT")
+	return true
+}
+
+// readDerivatives 返回原图已缓存的转换参数
+func (p *Proxy) readDerivatives(ctx context.Context, sourceKey string) []string {
+	reader, _, err := p.cache.Get(ctx, sourceKey+imageIndexSuffix)
+	if err != nil {
+		return nil
+	}
+	defer reader.Close()
+	var specs []string
+	if err := json.NewDecoder(reader).Decode(&specs); err != nil {
+		return nil
+	}
+	return specs
+}
+
+// storeDerivative 缓存转换结果并记录到原图的索引，原图的转换结果达到MaxDerivatives时返回errDerivativeQuota
+func (p *Proxy) storeDerivative(ctx context.Context, sourceKey, spec string, e *entry, data []byte, ttl time.Duration) error {
+	if ttl == 0 {
+		return fmt.Errorf("source %s has expired", sourceKey)
+	}
+	p.imageMu.Lock()
+	defer p.imageMu.Unlock()
+
+	// 已经过期或被清除的转换结果不计入配额
+	var specs []string
+	known := false
+	for _, s := range p.readDerivatives(ctx, sourceKey) {
+		if s == spec {
+			known = true
+			specs = append(specs, s)
+		} else if exists, _ := p.cache.Exists(ctx, sourceKey+imageSeparator+s); exists {
+			specs = append(specs, s)
+		}
+	}
+	if !known {
+		if len(specs) >= p.opts.Images.MaxDerivatives {
+			return errDerivativeQuota
+		}
+		specs = append(specs, spec)
+	}
+
+	if err := p.store(ctx, sourceKey+imageSeparator+spec, e, bytes.NewReader(data), ttl); err != nil {
+		return err
+	}
+	index, err := json.Marshal(specs)
+	if err != nil {
+		return fmt.Errorf("failed to encode image index: %w", err)
+	}
+	return p.cache.Set(ctx, sourceKey+imageIndexSuffix, bytes.NewReader(index), "application/json", ttl)
+}
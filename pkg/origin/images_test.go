@@ -0,0 +1,159 @@
+package origin
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestFitSize(t *testing.T) {
+	tests := []struct {
+		width, height, wantWidth, wantHeight int
+	}{
+		{0, 0, 200, 100},
+		{50, 0, 50, 25},
+		{0, 50, 100, 50},
+		{100, 10, 20, 10},
+		{400, 0, 200, 100},
+		{1, 0, 1, 1},
+	}
+	for _, tt := range tests {
+		if w, h := fitSize(200, 100, tt.width, tt.height); w != tt.wantWidth || h != tt.wantHeight {
+			t.Errorf("Expected %dx%d for %dx%d, got %dx%d", tt.wantWidth, tt.wantHeight, tt.width, tt.height, w, h)
+		}
+	}
+}
+
+func TestResize(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 2; y++ {
+			if x%2 == 0 {
+				src.Set(x, y, color.White)
+			} else {
+				src.Set(x, y, color.Black)
+			}
+		}
+	}
+	dst := resize(src, 2, 1)
+	if dst.Bounds().Dx() != 2 || dst.Bounds().Dy() != 1 {
+		t.Fatalf("Expected 2x1 image, got %v", dst.Bounds())
+	}
+	if r, _, _, a := dst.At(0, 0).RGBA(); r>>8 != 127 || a>>8 != 255 {
+		t.Errorf("Expected averaged gray pixel, got r=%d a=%d", r>>8, a>>8)
+	}
+}
+
+func TestImageTransforms(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for x := 0; x < 200; x++ {
+		for y := 0; y < 100; y++ {
+			src.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, src); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/photo.png":
+			if r.URL.RawQuery != "" {
+				t.Errorf("Expected transform params not to be forwarded, got %q", r.URL.RawQuery)
+			}
+			w.Header().Set("Content-Type", "image/png")
+			w.Header().Set("ETag", `"img1"`)
+			w.Write(encoded.Bytes())
+		case "/notes.txt":
+			io.WriteString(w, "not an image")
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	cache := filecache.NewMemoryCache(1 << 22)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{
+		Upstream:   upstream.URL,
+		DefaultTTL: time.Hour,
+		Images:     &ImageOptions{MaxWidth: 1000, MaxDerivatives: 2},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	decode := func(body string) (image.Config, string) {
+		t.Helper()
+		config, format, err := image.DecodeConfig(bytes.NewReader([]byte(body)))
+		if err != nil {
+			t.Fatalf("Failed to decode response image: %v", err)
+		}
+		return config, format
+	}
+
+	t.Run("Resize", func(t *testing.T) {
+		for _, want := range []string{"MISS", "HIT"} {
+			resp, body := get(t, proxy, http.MethodGet, "/photo.png?w=50")
+			if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Cache") != want {
+				t.Fatalf("Expected 200 %s, got %d %q", want, resp.StatusCode, resp.Header.Get("X-Cache"))
+			}
+			if config, format := decode(body); format != "png" || config.Width != 50 || config.Height != 25 {
+				t.Errorf("Expected 50x25 png, got %dx%d %s", config.Width, config.Height, format)
+			}
+			if resp.Header.Get("Content-Type") != "image/png" || resp.Header.Get("ETag") != `"img1-w50,h0,q85,auto"` {
+				t.Errorf("Expected derivative headers, got %v", resp.Header)
+			}
+		}
+		if exists, _ := cache.Exists(context.Background(), "/photo.png#image:w50,h0,q85,auto"); !exists {
+			t.Error("Expected derivative to be cached under its own key")
+		}
+	})
+
+	t.Run("Format", func(t *testing.T) {
+		resp, body := get(t, proxy, http.MethodGet, "/photo.png?h=1000&format=jpg&q=60")
+		if resp.Header.Get("Content-Type") != "image/jpeg" {
+			t.Fatalf("Expected image/jpeg, got %q", resp.Header.Get("Content-Type"))
+		}
+		if config, format := decode(body); format != "jpeg" || config.Width != 200 || config.Height != 100 {
+			t.Errorf("Expected 200x100 jpeg without upscaling, got %dx%d %s", config.Width, config.Height, format)
+		}
+	})
+
+	t.Run("Quota", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			if resp, _ := get(t, proxy, http.MethodGet, "/photo.png?w=10"); resp.Header.Get("X-Cache") != "BYPASS" {
+				t.Errorf("Expected derivative over quota not to be cached, got %q", resp.Header.Get("X-Cache"))
+			}
+		}
+		if resp, _ := get(t, proxy, http.MethodGet, "/photo.png?w=50"); resp.Header.Get("X-Cache") != "HIT" {
+			t.Errorf("Expected cached derivative to remain, got %q", resp.Header.Get("X-Cache"))
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, target := range []string{"/photo.png?w=0", "/photo.png?w=2000", "/photo.png?q=101", "/photo.png?format=tiff"} {
+			if resp, _ := get(t, proxy, http.MethodGet, target); resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("Expected 400 for %s, got %d", target, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("PassThrough", func(t *testing.T) {
+		if resp, body := get(t, proxy, http.MethodGet, "/notes.txt?w=10"); resp.StatusCode != http.StatusOK || body != "not an image" {
+			t.Errorf("Expected non-image to be returned unchanged, got %d %q", resp.StatusCode, body)
+		}
+		if resp, _ := get(t, proxy, http.MethodGet, "/missing.png?w=10"); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected 404 to be passed through, got %d", resp.StatusCode)
+		}
+	})
+
+	if n := upstream.requests.Load(); n != 3 {
+		t.Errorf("Expected 3 upstream requests, got %d", n)
+	}
+}
@@ -0,0 +1,131 @@
+package origin
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// MarketingParams 常见的营销跟踪参数，KeyRules.IgnoreMarketing为true时从缓存键中去掉；以"*"结尾的按前缀匹配
+var MarketingParams = []string{"utm_*", "gclid", "gclsrc", "dclid", "fbclid", "msclkid", "yclid", "mc_cid", "mc_eid", "_ga"}
+
+// KeyRules 缓存键的规范化规则，让只在无关查询参数上不同的请求共享同一个缓存条目
+type KeyRules struct {
+	// IncludeHost 缓存键以请求的Host开头（转为小写，去掉末尾的"."），同一个代理服务多个域名时使用
+	IncludeHost bool `json:"include_host"`
+
+	// IgnoreQuery 缓存键不包含查询参数
+	IgnoreQuery bool `json:"ignore_query"`
+
+	// QueryAllow 不为空时只保留这些查询参数，以"*"结尾的按前缀匹配
+	QueryAllow []string `json:"query_allow,omitempty"`
+
+	// QueryDeny 去掉这些查询参数，以"*"结尾的按前缀匹配
+	QueryDeny []string `json:"query_deny,omitempty"`
+
+	// IgnoreMarketing 去掉MarketingParams中的营销跟踪参数
+	IgnoreMarketing bool `json:"ignore_marketing"`
+
+	// SortQuery 按参数名排序查询参数，参数顺序不同的请求使用同一个缓存键
+	SortQuery bool `json:"sort_query"`
+
+	// Headers 缓存键包含这些请求头的值，内容随请求头变化但上游没有返回Vary时使用
+	Headers []string `json:"headers,omitempty"`
+}
+
+// validate 检查规则
+func (k *KeyRules) validate() error {
+	for _, patterns := range [][]string{k.QueryAllow, k.QueryDeny} {
+		for _, pattern := range patterns {
+			if pattern == "" || pattern == "*" {
+				return fmt.Errorf("invalid query parameter pattern %q", pattern)
+			}
+		}
+	}
+	for _, name := range k.Headers {
+		if name == "" || strings.ContainsAny(name, " \t:") {
+			return fmt.Errorf("invalid key header %q", name)
+		}
+	}
+	return nil
+}
+
+// key 按规则返回请求的缓存键：[主机]路径[?查询参数][#header:请求头]
+func (k *KeyRules) key(r *http.Request) string {
+	var b strings.Builder
+	if k.IncludeHost {
+		b.WriteString(normalizeHost(r.Host))
+	}
+	b.WriteString(r.URL.EscapedPath())
+	if query := k.query(r.URL.RawQuery); query != "" {
+		b.WriteByte('?')
+		b.WriteString(query)
+	}
+	if len(k.Headers) > 0 {
+		values := make(url.Values, len(k.Headers))
+		for _, name := range k.Headers {
+			name = http.CanonicalHeaderKey(name)
+			values[name] = []string{normalizeHeader(name, r.Header.Values(name))}
+		}
+		b.WriteString("#header:")
+		b.WriteString(values.Encode())
+	}
+	return b.String()
+}
+
+// query 按规则过滤和排序查询参数，保留参数原有的编码
+func (k *KeyRules) query(raw string) string {
+	if k.IgnoreQuery || raw == "" {
+		return ""
+	}
+	var params []string
+	for _, param := range strings.Split(raw, "&") {
+		if param == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(param, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if len(k.QueryAllow) > 0 && !matchParam(k.QueryAllow, name) ||
+			matchParam(k.QueryDeny, name) ||
+			k.IgnoreMarketing && matchParam(MarketingParams, name) {
+			continue
+		}
+		params = append(params, param)
+	}
+	if k.SortQuery {
+		sort.SliceStable(params, func(i, j int) bool {
+			a, _, _ := strings.Cut(params[i], "=")
+			b, _, _ := strings.Cut(params[j], "=")
+			return a < b
+		})
+	}
+	return strings.Join(params, "&")
+}
+
+// matchParam 返回参数名是否匹配任一模式，以"*"结尾的模式按前缀匹配
+func matchParam(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHost 返回小写、去掉末尾"."的主机名，保留端口
+func normalizeHost(host string) string {
+	host = strings.ToLower(host)
+	if hostname, port, err := net.SplitHostPort(host); err == nil {
+		return net.JoinHostPort(strings.TrimSuffix(hostname, "."), port)
+	}
+	return strings.TrimSuffix(host, ".")
+}
@@ -0,0 +1,85 @@
+package origin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestKeyRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		rules  KeyRules
+		target string
+		header http.Header
+		want   string
+	}{
+		{"Default", KeyRules{}, "/a.js?v=1&b=2", nil, "/a.js?v=1&b=2"},
+		{"IgnoreQuery", KeyRules{IgnoreQuery: true}, "/a.js?v=1", nil, "/a.js"},
+		{"SortQuery", KeyRules{SortQuery: true}, "/a.js?b=2&a=1&a=0", nil, "/a.js?a=1&a=0&b=2"},
+		{"QueryAllow", KeyRules{QueryAllow: []string{"v", "w*"}}, "/a.js?x=1&v=2&width=3", nil, "/a.js?v=2&width=3"},
+		{"QueryDeny", KeyRules{QueryDeny: []string{"session", "cb*"}}, "/a.js?session=1&v=2&cb_1=3", nil, "/a.js?v=2"},
+		{"IgnoreMarketing", KeyRules{IgnoreMarketing: true}, "/a.js?utm_source=x&v=2&fbclid=y&utm_medium=z", nil, "/a.js?v=2"},
+		{"EscapedName", KeyRules{QueryDeny: []string{"utm_source"}}, "/a.js?utm%5Fsource=x&v=%2F", nil, "/a.js?v=%2F"},
+		{"AllParamsRemoved", KeyRules{IgnoreMarketing: true}, "/a.js?utm_source=x", nil, "/a.js"},
+		{"IncludeHost", KeyRules{IncludeHost: true}, "http://CDN.Example.COM./a.js", nil, "cdn.example.com/a.js"},
+		{"IncludeHostPort", KeyRules{IncludeHost: true}, "http://CDN.example.com:8080/a.js", nil, "cdn.example.com:8080/a.js"},
+		{"Headers", KeyRules{Headers: []string{"x-device"}}, "/a.js", http.Header{"X-Device": {" Mobile "}}, "/a.js#header:X-Device=mobile"},
+		{"MissingHeader", KeyRules{Headers: []string{"X-Device"}}, "/a.js", nil, "/a.js#header:X-Device="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			for name, values := range tt.header {
+				r.Header[name] = values
+			}
+			if got := tt.rules.key(r); got != tt.want {
+				t.Errorf("Expected key %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, rules := range []KeyRules{{QueryAllow: []string{""}}, {QueryDeny: []string{"*"}}, {Headers: []string{"X Device"}}} {
+			if err := rules.validate(); err == nil {
+				t.Errorf("Expected error for rules %+v", rules)
+			}
+		}
+	})
+}
+
+func TestProxyKeyRules(t *testing.T) {
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("app"))
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{
+		Upstream:   upstream.URL,
+		DefaultTTL: time.Hour,
+		Key:        &KeyRules{IgnoreMarketing: true, SortQuery: true},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	get(t, proxy, http.MethodGet, "/app.js?v=1&lang=en&utm_source=mail")
+	resp, _ := get(t, proxy, http.MethodGet, "/app.js?lang=en&v=1&utm_campaign=spring")
+	if resp.Header.Get("X-Cache") != "HIT" {
+		t.Errorf("Expected normalized key to hit, got %q", resp.Header.Get("X-Cache"))
+	}
+	// 回源时保留客户端原始的查询参数
+	if got := upstream.last.Load().(*http.Request).URL.RawQuery; got != "v=1&lang=en&utm_source=mail" {
+		t.Errorf("Expected original query upstream, got %q", got)
+	}
+	if n := upstream.requests.Load(); n != 1 {
+		t.Errorf("Expected 1 upstream request, got %d", n)
+	}
+
+	if _, err := NewProxy(cache, Options{Upstream: upstream.URL, Key: &KeyRules{QueryAllow: []string{""}}}); err == nil {
+		t.Error("Expected error for invalid key rules")
+	}
+}
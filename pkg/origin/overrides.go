@@ -0,0 +1,101 @@
+package origin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CacheOverride 按请求路径覆盖上游缓存头的规则，用于缓存头不正确的上游，例如对可缓存的静态资源返回no-cache
+type CacheOverride struct {
+	// Path 匹配改写后的请求路径：以"*"结尾时按前缀匹配，否则只匹配相同的路径；为空时匹配所有请求
+	Path string `json:"path,omitempty"`
+
+	// TTL 大于0时忽略上游的缓存头，200和206响应按这个TTL缓存，优先于TTLPolicies、StatusTTL和流媒体策略；
+	// 带有no-store、private或Set-Cookie的响应仍然不缓存，需要同时设置IgnoreDirectives或StripSetCookie；
+	// 带有Authorization或Cookie的请求的响应也要求上游返回public、s-maxage或must-revalidate
+	TTL time.Duration `json:"ttl,omitempty"`
+
+	// IgnoreDirectives 判断能否缓存和计算TTL时忽略的上游Cache-Control指令，不区分大小写，
+	// 例如"no-cache"、"max-age"，以及默认禁止缓存的"no-store"、"private"；
+	// "expires"表示忽略Expires头。返回给客户端的响应头不变
+	IgnoreDirectives []string `json:"ignore_directives,omitempty"`
+
+	// StripSetCookie 为true时在写入缓存之前去掉上游响应的Set-Cookie，返回给客户端的响应也不带；
+	// 带有Set-Cookie的响应默认不缓存
+	StripSetCookie bool `json:"strip_set_cookie,omitempty"`
+}
+
+// validate 检查规则
+func (o *CacheOverride) validate() error {
+	if o.Path != "" && !strings.HasPrefix(o.Path, "/") {
+		return fmt.Errorf("invalid cache override path %q: must start with /", o.Path)
+	}
+	if o.TTL < 0 {
+		return fmt.Errorf("cache override ttl for %q cannot be negative", o.Path)
+	}
+	for _, name := range o.IgnoreDirectives {
+		if strings.TrimSpace(name) == "" || strings.ContainsAny(name, " \t,=\"") {
+			return fmt.Errorf("invalid cache directive %q", name)
+		}
+	}
+	return nil
+}
+
+// ignores 返回是否忽略指令name
+func (o *CacheOverride) ignores(name string) bool {
+	for _, ignored := range o.IgnoreDirectives {
+		if strings.EqualFold(ignored, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// header 返回计算TTL使用的响应头：去掉忽略的Cache-Control指令和Expires头，没有需要去掉的内容时返回h本身
+func (o *CacheOverride) header(h http.Header) http.Header {
+	if o == nil || len(o.IgnoreDirectives) == 0 {
+		return h
+	}
+	var kept []string
+	changed := false
+	for _, line := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			part = strings.TrimSpace(part)
+			name, _, _ := strings.Cut(part, "=")
+			if o.ignores(strings.TrimSpace(name)) {
+				changed = true
+				continue
+			}
+			if part != "" {
+				kept = append(kept, part)
+			}
+		}
+	}
+	expires := h.Get("Expires") != "" && o.ignores("expires")
+	if !changed && !expires {
+		return h
+	}
+
+	filtered := h.Clone()
+	filtered.Del("Cache-Control")
+	if len(kept) > 0 {
+		filtered.Set("Cache-Control", strings.Join(kept, ", "))
+	}
+	if expires {
+		filtered.Del("Expires")
+	}
+	return filtered
+}
+
+// override 返回第一个匹配请求路径的Options.CacheOverrides规则，没有时返回nil
+func (p *Proxy) override(path string) *CacheOverride {
+	for i := range p.opts.CacheOverrides {
+		o := &p.opts.CacheOverrides[i]
+		if _, ok := matchPath(o.Path, path); o.Path == "" || ok {
+			return o
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package origin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestCacheOverrides(t *testing.T) {
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/fixed/"):
+			w.Header().Set("Cache-Control", "max-age=5")
+		case strings.HasPrefix(r.URL.Path, "/cookie/"):
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Set-Cookie", "session=1")
+		case strings.HasPrefix(r.URL.Path, "/expires/"):
+			w.Header().Set("Expires", "0")
+		default:
+			w.Header().Set("Cache-Control", "public, no-cache")
+		}
+		io.WriteString(w, "data")
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{
+		Upstream:   upstream.URL,
+		DefaultTTL: time.Hour,
+		CacheOverrides: []CacheOverride{
+			{Path: "/assets/*", IgnoreDirectives: []string{"No-Cache"}},
+			{Path: "/fixed/*", TTL: 24 * time.Hour},
+			{Path: "/cookie/*", StripSetCookie: true},
+			{Path: "/expires/*", IgnoreDirectives: []string{"expires"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want time.Duration // 0表示不缓存
+	}{
+		{"/assets/app.css", time.Hour},
+		{"/page", 0},
+		{"/fixed/app.js", 24 * time.Hour},
+		{"/cookie/app.js", time.Minute},
+		{"/expires/app.js", time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			get(t, proxy, http.MethodGet, tt.path)
+			info, err := cache.GetInfo(context.Background(), tt.path)
+			if tt.want == 0 {
+				if err == nil {
+					t.Errorf("Expected %s not to be cached", tt.path)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to get info for %s: %v", tt.path, err)
+			}
+			if ttl := info.ExpiresAt.Sub(info.CreatedAt); ttl < tt.want-2*time.Second || ttl > tt.want+time.Second {
+				t.Errorf("Expected %s to be cached for %v, got %v", tt.path, tt.want, ttl)
+			}
+		})
+	}
+
+	t.Run("ClientHeaders", func(t *testing.T) {
+		resp, _ := get(t, proxy, http.MethodGet, "/assets/app.css")
+		if resp.Header.Get("X-Cache") != "HIT" || resp.Header.Get("Cache-Control") != "public, no-cache" {
+			t.Errorf("Expected HIT with the upstream Cache-Control, got %q %q", resp.Header.Get("X-Cache"), resp.Header.Get("Cache-Control"))
+		}
+		for i := 0; i < 2; i++ {
+			resp, _ := get(t, proxy, http.MethodGet, "/cookie/other.js")
+			if resp.Header.Get("Set-Cookie") != "" {
+				t.Errorf("Expected Set-Cookie to be stripped, got %q", resp.Header.Get("Set-Cookie"))
+			}
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, override := range []CacheOverride{
+			{Path: "assets/*"},
+			{TTL: -time.Second},
+			{IgnoreDirectives: []string{"max-age=5"}},
+			{IgnoreDirectives: []string{""}},
+		} {
+			if _, err := NewProxy(cache, Options{Upstream: upstream.URL, CacheOverrides: []CacheOverride{override}}); err == nil {
+				t.Errorf("Expected error for override %+v", override)
+			}
+		}
+	})
+}
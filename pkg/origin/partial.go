@@ -0,0 +1,174 @@
+package origin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 部分缓存的键：索引在基础键后加partialIndexSuffix，每段响应体在基础键后加partialPieceSeparator和范围
+const (
+	partialIndexSuffix    = "#ranges"
+	partialPieceSeparator = "#range:"
+)
+
+// maxPartialPieces 一个对象最多缓存的片段数，超出时丢弃最早的片段
+const maxPartialPieces = 64
+
+// partialIndex 部分缓存的对象：完整长度、校验器和已缓存的片段
+type partialIndex struct {
+	Size         int64       `json:"size"`
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"last_modified,omitempty"`
+	Pieces       []byteRange `json:"pieces"`
+}
+
+// sameObject 返回206响应是否与索引属于同一个版本的对象
+func (idx *partialIndex) sameObject(size int64, h http.Header) bool {
+	return idx.Size == size && idx.ETag == h.Get("ETag") && idx.LastModified == h.Get("Last-Modified")
+}
+
+// pieceKey 返回片段的缓存键
+func pieceKey(key string, rng byteRange) string {
+	return key + partialPieceSeparator + strconv.FormatInt(rng.Start, 10) + "-" + strconv.FormatInt(rng.End, 10)
+}
+
+// readIndex 读取部分缓存的索引，不存在、已过期或损坏时返回nil
+func (p *Proxy) readIndex(ctx context.Context, key string) *partialIndex {
+	reader, _, err := p.cache.Get(ctx, key+partialIndexSuffix)
+	if err != nil {
+		return nil
+	}
+	defer reader.Close()
+	idx := &partialIndex{}
+	if err := json.NewDecoder(reader).Decode(idx); err != nil {
+		return nil
+	}
+	return idx
+}
+
+// storePartial 把上游的206响应作为一个片段写入缓存并记录到索引；对象的长度或校验器改变时丢弃旧的索引
+func (p *Proxy) storePartial(ctx context.Context, key string, e *entry, body io.Reader, ttl time.Duration) error {
+	rng, size, ok := parseContentRange(e.Header.Get("Content-Range"))
+	if !ok {
+		return fmt.Errorf("invalid content range %q", e.Header.Get("Content-Range"))
+	}
+	if err := p.store(ctx, pieceKey(key, rng), e, body, ttl); err != nil {
+		return err
+	}
+
+	// 同一个对象的片段依次更新索引，避免并发写入时丢失片段
+	p.partialMu.Lock()
+	defer p.partialMu.Unlock()
+	idx := p.readIndex(ctx, key)
+	if idx == nil || !idx.sameObject(size, e.Header) {
+		idx = &partialIndex{Size: size, ETag: e.Header.Get("ETag"), LastModified: e.Header.Get("Last-Modified")}
+	}
+	for _, piece := range idx.Pieces {
+		if piece == rng {
+			return nil
+		}
+	}
+	idx.Pieces = append(idx.Pieces, rng)
+	if len(idx.Pieces) > maxPartialPieces {
+		idx.Pieces = idx.Pieces[len(idx.Pieces)-maxPartialPieces:]
+	}
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("failed to encode partial index: %w", err)
+	}
+	return p.cache.Set(ctx, key+partialIndexSuffix, bytes.NewReader(data), "application/json", ttl)
+}
+
+// servePartial 用缓存的片段返回Range请求，片段不能完整覆盖请求的范围时返回false，由调用方回源
+func (p *Proxy) servePartial(w http.ResponseWriter, r *http.Request, key string) bool {
+	idx := p.readIndex(r.Context(), key)
+	if idx == nil || !ifRangeMatches(r, http.Header{"Etag": {idx.ETag}, "Last-Modified": {idx.LastModified}}) {
+		return false
+	}
+	rng, err := parseRange(r.Header.Get("Range"), idx.Size)
+	if err != nil {
+		writeNotSatisfiable(w, idx.Size, "HIT")
+		return true
+	}
+	if rng == nil {
+		return false
+	}
+	pieces := cover(idx.Pieces, *rng)
+	if pieces == nil {
+		return false
+	}
+
+	var (
+		first   *entry
+		readers []io.Reader
+	)
+	for i, piece := range pieces {
+		reader, _, err := p.cache.Get(r.Context(), pieceKey(key, piece))
+		if err != nil {
+			return false
+		}
+		defer reader.Close()
+		e, body, _, err := readEntry(reader)
+		if err != nil || e == nil || e.Status != http.StatusPartialContent {
+			return false
+		}
+		// 第一个片段从请求的起点开始，之后的片段从上一个片段的终点之后开始
+		from, to := piece.Start, piece.End
+		if i == 0 {
+			from = rng.Start
+		} else {
+			from = pieces[i-1].End + 1
+		}
+		if to > rng.End {
+			to = rng.End
+		}
+		if err := skip(body, from-piece.Start); err != nil {
+			return false
+		}
+		readers = append(readers, io.LimitReader(body, to-from+1))
+		if first == nil {
+			first = e
+		}
+	}
+
+	header := w.Header()
+	copyHeader(header, first.Header)
+	header.Set("Accept-Ranges", "bytes")
+	header.Set("Content-Range", rng.contentRange(idx.Size))
+	header.Set("Content-Length", strconv.FormatInt(rng.length(), 10))
+	header.Set("Age", formatAge(first))
+	header.Set("X-Cache", "HIT")
+	w.WriteHeader(http.StatusPartialContent)
+	if r.Method == http.MethodGet {
+		io.Copy(w, io.MultiReader(readers...))
+	}
+	return true
+}
+
+// cover 返回依次覆盖rng的片段，每一步选择覆盖当前位置且延伸最远的片段，不能完整覆盖时返回nil
+func cover(pieces []byteRange, rng byteRange) []byteRange {
+	sorted := append([]byteRange(nil), pieces...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+	var out []byteRange
+	for pos := rng.Start; pos <= rng.End; {
+		best := -1
+		for i, piece := range sorted {
+			if piece.Start <= pos && piece.End >= pos && (best < 0 || piece.End > sorted[best].End) {
+				best = i
+			}
+		}
+		if best < 0 {
+			return nil
+		}
+		out = append(out, sorted[best])
+		pos = sorted[best].End + 1
+	}
+	return out
+}
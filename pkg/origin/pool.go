@@ -0,0 +1,107 @@
+package origin
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ConnectionPool 回源连接的复用。代理自己创建Transport时未设置的字段使用默认值；
+// 使用Options.Transport时只对*http.Transport生效，且只应用设置了的字段
+type ConnectionPool struct {
+	// MaxIdleConns 所有上游一共保留的空闲连接数，默认100
+	MaxIdleConns int `json:"max_idle_conns,omitempty"`
+
+	// MaxIdleConnsPerHost 每个上游保留的空闲连接数，默认32。http.DefaultTransport只保留2个，
+	// 并发回源后多出的连接被关闭，之后的回源又要重新建立连接和TLS握手
+	MaxIdleConnsPerHost int `json:"max_idle_conns_per_host,omitempty"`
+
+	// MaxConnsPerHost 每个上游的最大连接数（包括使用中的），达到后新的回源等待空闲连接，0表示不限制
+	MaxConnsPerHost int `json:"max_conns_per_host,omitempty"`
+
+	// IdleConnTimeout 空闲连接保留的时间，默认90秒；应小于上游或中间负载均衡的keep-alive超时
+	IdleConnTimeout time.Duration `json:"idle_conn_timeout,omitempty"`
+
+	// KeepAlive TCP keep-alive探测的间隔，默认30秒，负数表示不发送
+	KeepAlive time.Duration `json:"keep_alive,omitempty"`
+
+	// TLSSessionCacheSize 每个上游缓存的TLS会话数，新连接用会话恢复代替完整握手，默认64，负数表示不缓存
+	TLSSessionCacheSize int `json:"tls_session_cache_size,omitempty"`
+}
+
+// validate 检查连接数和超时
+func (p *ConnectionPool) validate() error {
+	if p.MaxIdleConns < 0 || p.MaxIdleConnsPerHost < 0 || p.MaxConnsPerHost < 0 {
+		return fmt.Errorf("connection pool limits cannot be negative")
+	}
+	if p.IdleConnTimeout < 0 {
+		return fmt.Errorf("idle connection timeout cannot be negative")
+	}
+	return nil
+}
+
+// merge 返回p中未设置的字段取自base的结果，p和base都可以为nil
+func (p *ConnectionPool) merge(base *ConnectionPool) *ConnectionPool {
+	switch {
+	case p == nil:
+		return base
+	case base == nil:
+		return p
+	}
+	merged := *p
+	if merged.MaxIdleConns == 0 {
+		merged.MaxIdleConns = base.MaxIdleConns
+	}
+	if merged.MaxIdleConnsPerHost == 0 {
+		merged.MaxIdleConnsPerHost = base.MaxIdleConnsPerHost
+	}
+	if merged.MaxConnsPerHost == 0 {
+		merged.MaxConnsPerHost = base.MaxConnsPerHost
+	}
+	if merged.IdleConnTimeout == 0 {
+		merged.IdleConnTimeout = base.IdleConnTimeout
+	}
+	if merged.KeepAlive == 0 {
+		merged.KeepAlive = base.KeepAlive
+	}
+	if merged.TLSSessionCacheSize == 0 {
+		merged.TLSSessionCacheSize = base.TLSSessionCacheSize
+	}
+	return &merged
+}
+
+// apply 把设置了的字段应用到tr，tr已经是复制的Transport
+func (p *ConnectionPool) apply(tr *http.Transport) {
+	if p.MaxIdleConns > 0 {
+		tr.MaxIdleConns = p.MaxIdleConns
+	}
+	if p.MaxIdleConnsPerHost > 0 {
+		tr.MaxIdleConnsPerHost = p.MaxIdleConnsPerHost
+	}
+	if p.MaxConnsPerHost > 0 {
+		tr.MaxConnsPerHost = p.MaxConnsPerHost
+	}
+	if p.IdleConnTimeout > 0 {
+		tr.IdleConnTimeout = p.IdleConnTimeout
+	}
+	if p.TLSSessionCacheSize > 0 {
+		if tr.TLSClientConfig == nil {
+			tr.TLSClientConfig = &tls.Config{}
+		} else {
+			tr.TLSClientConfig = tr.TLSClientConfig.Clone()
+		}
+		if tr.TLSClientConfig.ClientSessionCache == nil {
+			tr.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(p.TLSSessionCacheSize)
+		}
+	}
+}
+
+// defaultPool 代理自己创建Transport时使用的连接复用设置
+var defaultPool = ConnectionPool{
+	MaxIdleConns:        100,
+	MaxIdleConnsPerHost: 32,
+	IdleConnTimeout:     90 * time.Second,
+	KeepAlive:           30 * time.Second,
+	TLSSessionCacheSize: 64,
+}
@@ -0,0 +1,145 @@
+package origin
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// poolTransport 返回Fetcher使用的*http.Transport
+func poolTransport(t *testing.T, f Fetcher) *http.Transport {
+	t.Helper()
+	if body, ok := f.(*bodyTimeoutFetcher); ok {
+		f = body.next
+	}
+	tr, ok := f.(*httpFetcher).transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Expected *http.Transport, got %T", f.(*httpFetcher).transport)
+	}
+	return tr
+}
+
+func TestConnectionPool(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		f, err := newUpstreamFetcher("http://origin.test", transportConfig{pool: &ConnectionPool{MaxConnsPerHost: 10}})
+		if err != nil {
+			t.Fatalf("Failed to create fetcher: %v", err)
+		}
+		tr := poolTransport(t, f)
+		if tr.MaxIdleConnsPerHost != 32 || tr.MaxConnsPerHost != 10 || tr.IdleConnTimeout != 90*time.Second || tr.TLSClientConfig.ClientSessionCache == nil {
+			t.Errorf("Expected default pool settings, got %d idle per host, %d per host, %v idle timeout",
+				tr.MaxIdleConnsPerHost, tr.MaxConnsPerHost, tr.IdleConnTimeout)
+		}
+	})
+
+	t.Run("custom transport", func(t *testing.T) {
+		base := &http.Transport{MaxIdleConnsPerHost: 5}
+		f, err := newUpstreamFetcher("http://origin.test", transportConfig{transport: base, pool: &ConnectionPool{IdleConnTimeout: time.Minute}})
+		if err != nil {
+			t.Fatalf("Failed to create fetcher: %v", err)
+		}
+		tr := poolTransport(t, f)
+		if tr == base || tr.MaxIdleConnsPerHost != 5 || tr.IdleConnTimeout != time.Minute || tr.TLSClientConfig != nil && tr.TLSClientConfig.ClientSessionCache != nil {
+			t.Errorf("Expected only set fields on a copy of the transport, got %d idle per host, %v idle timeout",
+				tr.MaxIdleConnsPerHost, tr.IdleConnTimeout)
+		}
+	})
+
+	t.Run("reuse", func(t *testing.T) {
+		const concurrency = 8
+		var conns atomic.Int32
+		arrived := make(chan struct{}, concurrency)
+		release := make(chan struct{})
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			arrived <- struct{}{}
+			<-release
+			io.WriteString(w, "ok")
+		}))
+		srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				conns.Add(1)
+			}
+		}
+		srv.Start()
+		defer srv.Close()
+
+		f, err := newUpstreamFetcher(srv.URL, transportConfig{})
+		if err != nil {
+			t.Fatalf("Failed to create fetcher: %v", err)
+		}
+		for wave := 0; wave < 2; wave++ {
+			var wg sync.WaitGroup
+			for i := 0; i < concurrency; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					resp, err := f.Fetch(context.Background(), httptest.NewRequest(http.MethodGet, "/", nil))
+					if err != nil {
+						t.Errorf("Failed to fetch: %v", err)
+						return
+					}
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}()
+			}
+			for i := 0; i < concurrency; i++ {
+				<-arrived
+			}
+			for i := 0; i < concurrency; i++ {
+				release <- struct{}{}
+			}
+			wg.Wait()
+			time.Sleep(50 * time.Millisecond)
+		}
+		if n := conns.Load(); n != concurrency {
+			t.Errorf("Expected %d connections to be reused by the second wave, got %d", concurrency, n)
+		}
+	})
+
+	t.Run("tls session resumption", func(t *testing.T) {
+		var resumed []bool
+		var mu sync.Mutex
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			resumed = append(resumed, r.TLS.DidResume)
+			mu.Unlock()
+		}))
+		defer srv.Close()
+		base := srv.Client().Transport.(*http.Transport).Clone()
+		base.DisableKeepAlives = true
+
+		f, err := newUpstreamFetcher(srv.URL, transportConfig{transport: base, pool: &ConnectionPool{TLSSessionCacheSize: 8}})
+		if err != nil {
+			t.Fatalf("Failed to create fetcher: %v", err)
+		}
+		for i := 0; i < 2; i++ {
+			resp, err := f.Fetch(context.Background(), httptest.NewRequest(http.MethodGet, "/", nil))
+			if err != nil {
+				t.Fatalf("Failed to fetch: %v", err)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if len(resumed) != 2 || resumed[0] || !resumed[1] {
+			t.Errorf("Expected second connection to resume the TLS session, got %v", resumed)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if err := (&ConnectionPool{MaxIdleConnsPerHost: -1}).validate(); err == nil {
+			t.Error("Expected error for negative idle connections")
+		}
+		if err := (&ConnectionPool{IdleConnTimeout: -time.Second}).validate(); err == nil {
+			t.Error("Expected error for negative idle timeout")
+		}
+		if err := (&ConnectionPool{KeepAlive: -1, TLSSessionCacheSize: -1}).validate(); err != nil {
+			t.Errorf("Expected negative keep-alive and session cache to disable them, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,672 @@
+// Package origin 实现回源反向代理：请求未命中缓存时从上游拉取，把响应连同状态码和响应头写入缓存后返回，
+// 之后相同的请求直接由缓存提供
+package origin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// Options 回源代理选项
+type Options struct {
+	// Upstream 上游地址，例如 https://origin.example.com/assets，请求路径追加在其后；设置了Fetcher时不使用
+	Upstream string `json:"upstream"`
+
+	// Upstreams 多个上游，按优先级故障转移、同一优先级按权重分配请求，见NewFailoverFetcher；
+	// 不能与Upstream同时设置，设置了Fetcher时不使用
+	Upstreams []UpstreamTarget `json:"upstreams,omitempty"`
+
+	// UpstreamTimeout 使用Upstreams时等待一个上游响应头的时间，超时后换下一个上游，0表示不限制
+	UpstreamTimeout time.Duration `json:"upstream_timeout,omitempty"`
+
+	// HealthCheck 不为空时对Upstreams或Upstream中的每个上游熔断，并可以主动探测；设置了Fetcher时不使用
+	HealthCheck *HealthCheck `json:"health_check,omitempty"`
+
+	// Retry 不为空时回源失败（出错、超时或返回502、503、504）后按退避时间重试幂等的请求
+	Retry *RetryPolicy `json:"retry,omitempty"`
+
+	// DefaultTTL 上游响应没有Cache-Control的max-age、s-maxage或Expires时的TTL，0表示使用缓存的默认TTL
+	DefaultTTL time.Duration `json:"default_ttl"`
+
+	// MinTTL 由上游缓存头计算出的TTL的下限，0表示不限制；设置后max-age=0的响应也会缓存MinTTL
+	MinTTL time.Duration `json:"min_ttl"`
+
+	// MaxTTL 由上游缓存头计算出的TTL的上限，0表示不限制
+	MaxTTL time.Duration `json:"max_ttl"`
+
+	// TTLPolicies 上游响应没有缓存头时按请求路径和Content-Type选择TTL的规则，第一条匹配的规则优先于DefaultTTL，
+	// 例如.css/.js文件7天、application/json 30秒
+	TTLPolicies []filecache.TTLPolicy `json:"ttl_policies,omitempty"`
+
+	// StatusTTL 上游响应没有缓存头时按状态码选择TTL，键为状态码（"301"）或状态类（"5xx"），状态码优先于状态类，
+	// 0表示不缓存，见DefaultStatusTTL。200和206响应的TTLPolicies优先，未列出时使用DefaultTTL；
+	// 列出的其他状态码（304除外）也会缓存，有缓存头时按缓存头计算。NegativeTTL中的状态码以NegativeTTL为准
+	StatusTTL map[string]time.Duration `json:"status_ttl,omitempty"`
+
+	// CacheOverrides 按路径覆盖上游缓存头的规则：固定TTL、忽略指定的Cache-Control指令或去掉Set-Cookie，
+	// 使用第一个匹配的规则
+	CacheOverrides []CacheOverride `json:"cache_overrides,omitempty"`
+
+	// StaleWhileRevalidate 条目过期后仍直接返回旧内容、同时在后台刷新的时间窗口，
+	// 上游响应的Cache-Control: stale-while-revalidate=N优先。需要缓存实现filecache.StaleGetter，
+	// 且过期条目在窗口内不被清理（filecache.Config.StaleRetention不小于该窗口）
+	StaleWhileRevalidate time.Duration `json:"stale_while_revalidate"`
+
+	// MaxRevalidations 同时进行的后台刷新数，默认16，超出时本次不刷新
+	MaxRevalidations int `json:"max_revalidations"`
+
+	// SliceSize 大于0时把对象分成这个大小的分片，每个分片用上游的Range请求按需回源、单独缓存，
+	// 返回时拼接；大文件只有被访问的部分驻留在缓存中。需要上游支持Range
+	SliceSize int64 `json:"slice_size"`
+
+	// Transport 发送上游请求，默认http.DefaultTransport；设置了Fetcher时不使用
+	Transport http.RoundTripper `json:"-"`
+
+	// Timeouts 回源的连接、TLS握手、响应头和响应体超时，Upstreams中的上游可以单独设置；
+	// 为空且没有设置Transport时使用默认超时，见Timeouts。设置了Fetcher时不使用
+	Timeouts *Timeouts `json:"timeouts,omitempty"`
+
+	// ForwardProxy 回源经过的出口代理，为空时按HTTP_PROXY、HTTPS_PROXY和NO_PROXY环境变量；
+	// 设置了Transport时只对*http.Transport生效，设置了Fetcher时不使用
+	ForwardProxy *ForwardProxy `json:"forward_proxy,omitempty"`
+
+	// DNS 缓存上游主机名的解析结果，也可以为主机名固定IP；为空时每次建立连接都查询解析器。
+	// 设置了Transport时只对*http.Transport生效，设置了Fetcher时不使用
+	DNS *DNSOptions `json:"dns,omitempty"`
+
+	// Pool 回源连接的复用：空闲连接数、空闲超时、TCP keep-alive和TLS会话恢复；
+	// 为空且没有设置Transport时使用默认值，见ConnectionPool。设置了Fetcher时不使用
+	Pool *ConnectionPool `json:"pool,omitempty"`
+
+	// Fetcher 自定义回源，例如NewS3Fetcher、NewDirFetcher，为空时用Upstreams或Upstream和Transport创建
+	Fetcher Fetcher `json:"-"`
+
+	// Rewrites 在计算缓存键和回源之前改写请求路径的规则，使用第一个匹配的规则
+	Rewrites []RewriteRule `json:"rewrites,omitempty"`
+
+	// Headers 按路径修改回源请求头、上游响应头（缓存之前）和返回给客户端的响应头的规则
+	Headers []HeaderRule `json:"headers,omitempty"`
+
+	// Key 缓存键的规范化规则，例如去掉营销跟踪参数、排序查询参数，为空时使用请求的路径和查询参数
+	Key *KeyRules `json:"key,omitempty"`
+
+	// Signing 不为空时所有请求必须带有有效的签名URL参数，否则返回403，
+	// 受保护的内容仍然缓存，但不会返回给没有签名的客户端
+	Signing *SignedURLs `json:"signing,omitempty"`
+
+	// Purge 不为空时在服务端口上接受PURGE请求和按前缀清除的请求，供部署流水线清除缓存
+	Purge *PurgeOptions `json:"purge,omitempty"`
+
+	// Compression 不为空时在边缘压缩可压缩的响应，压缩结果与原始响应一起缓存，按Accept-Encoding返回
+	Compression *Compression `json:"compression,omitempty"`
+
+	// Images 不为空时按w、h、format、q查询参数转换缓存中的图片，每种转换结果单独缓存
+	Images *ImageOptions `json:"images,omitempty"`
+
+	// Streaming 不为空时识别HLS/DASH的播放列表和分段，分别使用短TTL和长TTL，并可以预取后续分段
+	Streaming *StreamingPolicy `json:"streaming,omitempty"`
+
+	// ESI 不为空时处理HTML中的<esi:include>，片段经过缓存获取，按各自的TTL缓存
+	ESI *ESIOptions `json:"esi,omitempty"`
+
+	// KeyFunc 自定义请求到缓存键的映射，优先于Key
+	KeyFunc func(r *http.Request) string `json:"-"`
+
+	// NegativeTTL 按状态码缓存上游错误响应的TTL，例如{404: time.Minute, 410: time.Hour}，
+	// 避免缺失的文件每次都回源；上游缓存头给出的TTL更短时使用上游的，未列出的状态码不缓存
+	NegativeTTL map[int]time.Duration `json:"negative_ttl,omitempty"`
+
+	// ErrorHandler 上游请求失败时调用，默认返回502
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error) `json:"-"`
+}
+
+// Proxy 回源反向代理，GET和HEAD请求经过缓存，其他请求直接转发到上游
+type Proxy struct {
+	cache   filecache.Cache
+	opts    Options
+	fetcher Fetcher
+	health  healthReporter // 上游池的健康状态，没有上游池时为nil
+	ttls    *filecache.TTLPolicySet
+	status  *statusTTLs
+
+	mu           sync.Mutex
+	refreshing   map[string]bool    // 正在后台刷新的键
+	compressing  map[string]bool    // 正在压缩的变体键
+	refreshSem   chan struct{}      // 限制后台刷新的并发数
+	flights      map[string]*flight // 正在回源的键
+	partialMu    sync.Mutex         // 串行更新部分缓存的索引
+	imageMu      sync.Mutex         // 串行更新转换结果的索引
+	transformSem chan struct{}      // 限制图片转换的并发数
+	prefetchSem  chan struct{}      // 限制分段预取的并发数
+	tags         tagIndex
+}
+
+// NewProxy 创建回源代理，设置了HealthCheck.Interval时需要调用Close停止探测
+func NewProxy(cache filecache.Cache, opts Options) (*Proxy, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Purge != nil {
+		purge := *opts.Purge
+		if err := purge.validate(); err != nil {
+			return nil, err
+		}
+		opts.Purge = &purge
+	}
+	if opts.Compression != nil {
+		compression := *opts.Compression
+		compression.Encodings = append([]string(nil), compression.Encodings...)
+		if err := compression.validate(); err != nil {
+			return nil, err
+		}
+		opts.Compression = &compression
+	}
+	if opts.Images != nil {
+		images := *opts.Images
+		if err := images.validate(); err != nil {
+			return nil, err
+		}
+		opts.Images = &images
+	}
+	if opts.Streaming != nil {
+		streaming := *opts.Streaming
+		if err := streaming.validate(); err != nil {
+			return nil, err
+		}
+		opts.Streaming = &streaming
+	}
+	if opts.ESI != nil {
+		esi := *opts.ESI
+		if err := esi.validate(); err != nil {
+			return nil, err
+		}
+		opts.ESI = &esi
+	}
+	if len(opts.Rewrites) > 0 {
+		opts.Rewrites = append([]RewriteRule(nil), opts.Rewrites...)
+		for i := range opts.Rewrites {
+			if err := opts.Rewrites[i].compile(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	transport := transportConfig{transport: opts.Transport, timeouts: opts.Timeouts, pool: opts.Pool}
+	if opts.ForwardProxy != nil {
+		proxy, err := opts.ForwardProxy.proxyFunc()
+		if err != nil {
+			return nil, err
+		}
+		transport.proxy = proxy
+	}
+	if opts.DNS != nil {
+		dns, err := opts.DNS.newCache()
+		if err != nil {
+			return nil, err
+		}
+		transport.dns = dns
+	}
+	opts.Headers = append([]HeaderRule(nil), opts.Headers...)
+	opts.CacheOverrides = append([]CacheOverride(nil), opts.CacheOverrides...)
+	if opts.Retry != nil {
+		retry := *opts.Retry
+		if err := retry.validate(); err != nil {
+			return nil, err
+		}
+		opts.Retry = &retry
+	}
+	ttls, err := filecache.NewTTLPolicySet(opts.TTLPolicies)
+	if err != nil {
+		return nil, err
+	}
+	status, err := newStatusTTLs(opts.StatusTTL)
+	if err != nil {
+		return nil, err
+	}
+	if opts.MaxRevalidations <= 0 {
+		opts.MaxRevalidations = defaultMaxRevalidations
+	}
+
+	fetcher := opts.Fetcher
+	switch {
+	case fetcher != nil:
+	case len(opts.Upstreams) > 0 && opts.Upstream != "":
+		return nil, fmt.Errorf("upstream and upstreams cannot both be set")
+	case len(opts.Upstreams) > 0 || opts.HealthCheck != nil:
+		targets := opts.Upstreams
+		if len(targets) == 0 {
+			targets = []UpstreamTarget{{URL: opts.Upstream}}
+		}
+		fetcher, err = NewFailoverFetcher(targets, FailoverOptions{
+			Transport:    opts.Transport,
+			Timeout:      opts.UpstreamTimeout,
+			Timeouts:     opts.Timeouts,
+			ForwardProxy: opts.ForwardProxy,
+			DNS:          opts.DNS,
+			Pool:         opts.Pool,
+			HealthCheck:  opts.HealthCheck,
+		})
+	default:
+		fetcher, err = newUpstreamFetcher(opts.Upstream, transport)
+	}
+	if err != nil {
+		return nil, err
+	}
+	health, _ := fetcher.(healthReporter)
+	if origins := rewriteOrigins(opts.Rewrites); len(origins) > 0 {
+		rewriter := &rewriteFetcher{next: fetcher, origins: make(map[string]Fetcher)}
+		for _, origin := range origins {
+			// 改写的目标已经检查过，不会失败
+			rewriter.origins[origin], _ = newUpstreamFetcher(origin, transport)
+		}
+		fetcher = rewriter
+	}
+	if len(opts.Headers) > 0 {
+		fetcher = &headerFetcher{next: fetcher, rules: opts.Headers}
+	}
+	if opts.Retry != nil {
+		fetcher = &retryFetcher{next: fetcher, policy: *opts.Retry}
+	}
+
+	var transformSem chan struct{}
+	if opts.Images != nil {
+		transformSem = make(chan struct{}, opts.Images.MaxConcurrent)
+	}
+	return &Proxy{
+		cache:        cache,
+		opts:         opts,
+		fetcher:      fetcher,
+		health:       health,
+		ttls:         ttls,
+		status:       status,
+		refreshing:   make(map[string]bool),
+		compressing:  make(map[string]bool),
+		refreshSem:   make(chan struct{}, opts.MaxRevalidations),
+		flights:      make(map[string]*flight),
+		transformSem: transformSem,
+		prefetchSem:  make(chan struct{}, maxPrefetches),
+	}, nil
+}
+
+// Close 停止上游的主动探测；Options.Fetcher由调用方关闭
+func (p *Proxy) Close() error {
+	if closer, ok := p.fetcher.(io.Closer); ok && p.opts.Fetcher == nil {
+		return closer.Close()
+	}
+	return nil
+}
+
+// ServeHTTP 命中时从缓存返回响应，未命中时回源
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if p.isPurge(r) {
+		p.servePurge(w, r)
+		return
+	}
+	if p.opts.Signing != nil {
+		if err := p.opts.Signing.verify(r.URL, time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		r = p.opts.Signing.strip(r)
+	}
+	if len(p.opts.Rewrites) > 0 {
+		r = p.rewrite(r)
+	}
+	if actions := headerActions(p.opts.Headers, r.URL.Path, func(rule *HeaderRule) *HeaderActions { return rule.Response }); len(actions) > 0 {
+		w = &headerWriter{ResponseWriter: w, actions: actions}
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		p.forward(w, r)
+		return
+	}
+	if p.opts.Images != nil && p.opts.Images.requested(r.URL.Query()) {
+		p.serveImage(w, r)
+		return
+	}
+	p.serve(w, r)
+}
+
+// serve 经过缓存返回GET和HEAD请求
+func (p *Proxy) serve(w http.ResponseWriter, r *http.Request) {
+	if s := p.opts.Streaming; s != nil && s.Prefetch > 0 && r.Method == http.MethodGet && s.classify(r.URL.Path, nil) == streamSegment {
+		p.prefetch(r)
+	}
+	key := p.key(r)
+	if p.serveCached(w, r, key) {
+		return
+	}
+	if p.opts.SliceSize > 0 {
+		p.serveSliced(w, r, key)
+		return
+	}
+	if r.Header.Get("Range") != "" && p.servePartial(w, r, key) {
+		return
+	}
+	p.fetch(w, r, key)
+}
+
+// key 返回请求对应的缓存键
+func (p *Proxy) key(r *http.Request) string {
+	if p.opts.KeyFunc != nil {
+		return p.opts.KeyFunc(r)
+	}
+	var key string
+	if p.opts.Key != nil {
+		key = p.opts.Key.key(r)
+	} else {
+		key = r.URL.RequestURI()
+	}
+	if len(p.opts.Rewrites) > 0 && r.URL.Host != "" {
+		// 改写到其他上游的请求，与默认上游上的相同路径区分
+		key = "//" + r.URL.Host + key
+	}
+	return key
+}
+
+// serveCached 从缓存返回响应，未命中或缓存出错时返回false，由调用方回源
+func (p *Proxy) serveCached(w http.ResponseWriter, r *http.Request, key string) bool {
+	reader, info, err := p.cache.Get(r.Context(), key)
+	if err != nil {
+		return p.serveStale(w, r, key)
+	}
+	defer reader.Close()
+
+	e, body, headerSize, err := readEntry(reader)
+	if err != nil {
+		return false
+	}
+	if e != nil && len(e.Vary) > 0 {
+		return p.serveCached(w, r, variantKey(key, e.Vary, r.Header))
+	}
+	if e == nil {
+		// 不是代理写入的条目，按普通文件返回
+		e = &entry{
+			Status: http.StatusOK,
+			Header: http.Header{
+				"Content-Type":  {info.MimeType},
+				"Etag":          {fmt.Sprintf(`"%x-%x"`, info.CreatedAt.UnixNano(), info.Size)},
+				"Last-Modified": {info.CreatedAt.UTC().Format(http.TimeFormat)},
+			},
+			StoredAt: info.CreatedAt,
+		}
+	}
+	if headerSize > 0 && p.serveEncoded(w, r, key, e, info.Size-headerSize) {
+		return true
+	}
+	p.writeEntry(w, r, e, body, info.Size-headerSize, "HIT")
+	return true
+}
+
+// writeEntry 返回缓存的响应，满足客户端的条件请求时返回304，Range请求返回206；status为X-Cache头的值
+func (p *Proxy) writeEntry(w http.ResponseWriter, r *http.Request, e *entry, body io.Reader, size int64, status string) {
+	if p.opts.ESI != nil && p.opts.ESI.applies(e, size) {
+		var processed bool
+		if body, processed = p.writeESI(w, r, e, body, status); processed {
+			return
+		}
+	}
+	if p.compressible(e, size) {
+		// 同一个URL对接受压缩的客户端返回压缩变体
+		varied := *e
+		varied.Header = e.Header.Clone()
+		addVary(varied.Header, "Accept-Encoding")
+		e = &varied
+	}
+	if notModified(r, e) {
+		p.writeNotModified(w, e, status)
+		return
+	}
+	if p.writeRange(w, r, e, body, size, status) {
+		return
+	}
+	header := w.Header()
+	copyHeader(header, e.Header)
+	if e.Status == http.StatusOK {
+		header.Set("Accept-Ranges", "bytes")
+	}
+	header.Set("Content-Length", strconv.FormatInt(size, 10))
+	header.Set("Age", formatAge(e))
+	header.Set("X-Cache", status)
+	w.WriteHeader(e.Status)
+	if r.Method == http.MethodGet {
+		io.Copy(w, body)
+	}
+}
+
+// pull 回源，HEAD请求以GET回源以便缓存响应体。收到响应头后立即返回，响应体在后台写入spool，
+// 可缓存时同时写入缓存，客户端不需要等整个响应体下载完。
+// 缓存中还有过期的条目时带上它的校验器回源，上游返回304时沿用旧的响应体，只更新元数据和TTL。
+// 回源结果可能被多个请求共享，因此不随发起请求的客户端断开而取消
+func (p *Proxy) pull(r *http.Request, key string) (*fetchResult, error) {
+	ctx := context.Background()
+	out := p.upstreamRequest(r.WithContext(ctx))
+	out.Method = http.MethodGet
+	stripConditional(out.Header)
+	if ifRange := r.Header.Get("If-Range"); ifRange != "" && out.Header.Get("Range") != "" {
+		// 由上游判断If-Range，不匹配时上游返回完整响应
+		out.Header.Set("If-Range", ifRange)
+	}
+	stale := p.openStale(ctx, key, r.Header)
+	if stale != nil {
+		setValidators(out.Header, stale.entry)
+	}
+	start := time.Now()
+	resp, err := p.fetcher.Fetch(ctx, out)
+	addUpstreamTime(r.Context(), start)
+	if err != nil {
+		if stale != nil {
+			stale.Close()
+		}
+		return nil, err
+	}
+	done := func() {
+		resp.Body.Close()
+		if stale != nil {
+			stale.Close()
+		}
+	}
+
+	responseTime := time.Now()
+	e, src, size, status := newEntry(resp, responseTime), io.Reader(resp.Body), resp.ContentLength, "MISS"
+	if resp.StatusCode == http.StatusNotModified && stale != nil {
+		e, src, size, status = stale.freshen(resp, responseTime), stale.body, stale.size, "REVALIDATED"
+	}
+	ttl, cacheable := p.cacheTTL(e, r.URL.Path, r.Header, responseTime)
+
+	var store func(io.Reader) error
+	switch {
+	case !cacheable:
+	case e.Status == http.StatusPartialContent:
+		// Range请求只回源请求的范围，作为片段缓存，之后被片段覆盖的范围由缓存返回
+		store = func(body io.Reader) error { return p.storePartial(ctx, key, e, body, ttl) }
+	default:
+		store = func(body io.Reader) error { return p.storeResponse(ctx, key, r.Header, e, body, ttl) }
+	}
+	body, err := stream(src, store, done)
+	if err != nil {
+		done()
+		return nil, err
+	}
+
+	res := &fetchResult{entry: e, body: body, size: size, status: status, variant: key, shared: cacheable}
+	if vary, _ := varyHeaders(e.Header); len(vary) > 0 {
+		res.variant = variantKey(key, vary, r.Header)
+	}
+	return res, nil
+}
+
+// writeResult 一边接收上游响应体一边返回给客户端；上游没有给出长度时等响应体完整后再返回。
+// 响应可缓存时，没有读取响应体的请求（HEAD、304等）在返回后等待写入缓存完成，之后的请求可以命中缓存
+func (p *Proxy) writeResult(w http.ResponseWriter, r *http.Request, res *fetchResult) {
+	size, err := res.length()
+	if err != nil {
+		p.error(w, r, err)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		p.writeEntry(w, r, res.entry, res.body.reader(), size, res.status)
+		return
+	}
+	p.writeEntry(flushWriter{w, flusher}, r, res.entry, res.body.reader(), size, res.status)
+	if res.shared {
+		flusher.Flush()
+		res.body.wait()
+	}
+}
+
+// newEntry 返回上游响应的缓存元数据
+func newEntry(resp *http.Response, responseTime time.Time) *entry {
+	header := endToEndHeader(resp.Header)
+	return &entry{
+		Status:     resp.StatusCode,
+		Header:     header,
+		StoredAt:   responseTime,
+		InitialAge: initialAge(resp.Header, responseTime),
+		Tags:       takeTags(header),
+	}
+}
+
+// store 把元数据和响应体写入缓存
+func (p *Proxy) store(ctx context.Context, key string, e *entry, body io.Reader, ttl time.Duration) error {
+	meta, err := e.encode()
+	if err != nil {
+		return err
+	}
+	mimeType := e.Header.Get("Content-Type")
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	if err := p.cache.Set(ctx, key, io.MultiReader(bytes.NewReader(meta), body), mimeType, ttl); err != nil {
+		return err
+	}
+	if len(e.Tags) > 0 {
+		p.tags.add(key, e.Tags)
+	}
+	return nil
+}
+
+// forward 把不经过缓存的请求转发到上游
+func (p *Proxy) forward(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	resp, err := p.fetcher.Fetch(r.Context(), p.upstreamRequest(r))
+	addUpstreamTime(r.Context(), start)
+	if err != nil {
+		p.error(w, r, err)
+		return
+	}
+	defer resp.Body.Close()
+	p.copyResponse(w, r, resp, "BYPASS")
+}
+
+// copyResponse 把上游响应原样返回给客户端
+func (p *Proxy) copyResponse(w http.ResponseWriter, r *http.Request, resp *http.Response, status string) {
+	header := w.Header()
+	copyHeader(header, endToEndHeader(resp.Header))
+	takeTags(header)
+	if resp.ContentLength >= 0 {
+		header.Set("Content-Length", strconv.FormatInt(resp.ContentLength, 10))
+	}
+	header.Set("X-Cache", status)
+	w.WriteHeader(resp.StatusCode)
+	if r.Method != http.MethodHead {
+		io.Copy(w, resp.Body)
+	}
+}
+
+// upstreamRequest 把客户端请求转换为回源请求，URL仍是客户端请求的路径和查询参数，由Fetcher映射到上游
+func (p *Proxy) upstreamRequest(r *http.Request) *http.Request {
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	if r.ContentLength == 0 {
+		out.Body = nil
+	}
+
+	out.Header = endToEndHeader(r.Header)
+	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		if prior := r.Header.Get("X-Forwarded-For"); prior != "" {
+			ip = prior + ", " + ip
+		}
+		out.Header.Set("X-Forwarded-For", ip)
+	}
+	out.Header.Set("X-Forwarded-Host", r.Host)
+	return out
+}
+
+// error 处理上游请求失败
+func (p *Proxy) error(w http.ResponseWriter, r *http.Request, err error) {
+	if p.opts.ErrorHandler != nil {
+		p.opts.ErrorHandler(w, r, err)
+		return
+	}
+	code := http.StatusBadGateway
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		code = http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		code = http.StatusGatewayTimeout
+	}
+	http.Error(w, http.StatusText(code), code)
+}
+
+// joinPath 拼接上游路径和请求路径
+func joinPath(base, path string) string {
+	switch {
+	case base == "":
+		return path
+	case strings.HasSuffix(base, "/") && strings.HasPrefix(path, "/"):
+		return base + path[1:]
+	case !strings.HasSuffix(base, "/") && !strings.HasPrefix(path, "/"):
+		return base + "/" + path
+	default:
+		return base + path
+	}
+}
+
+// hopHeaders 逐跳头，不转发也不缓存
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// endToEndHeader 返回去掉逐跳头后的副本，包括Connection中列出的头
+func endToEndHeader(h http.Header) http.Header {
+	out := h.Clone()
+	if out == nil {
+		return http.Header{}
+	}
+	for _, v := range h["Connection"] {
+		for _, name := range strings.Split(v, ",") {
+			out.Del(strings.TrimSpace(name))
+		}
+	}
+	for _, name := range hopHeaders {
+		out.Del(name)
+	}
+	out.Del("Content-Length")
+	return out
+}
+
+// copyHeader 把src中的头复制到dst
+func copyHeader(dst, src http.Header) {
+	for name, values := range src {
+		dst[name] = append([]string(nil), values...)
+	}
+}
@@ -0,0 +1,258 @@
+package origin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// testUpstream 记录请求次数的上游
+type testUpstream struct {
+	*httptest.Server
+	requests atomic.Int64
+	last     atomic.Value // 最后一个请求
+}
+
+func newTestUpstream(t *testing.T, handler http.HandlerFunc) *testUpstream {
+	t.Helper()
+	u := &testUpstream{}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.requests.Add(1)
+		u.last.Store(r.Clone(context.Background()))
+		handler(w, r)
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+// get 发送请求，返回响应和响应体
+func get(t *testing.T, h http.Handler, method, target string) (*http.Response, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	resp := rec.Result()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+func TestProxy(t *testing.T) {
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/assets/app.css":
+			w.Header().Set("Content-Type", "text/css")
+			w.Header().Set("X-Origin", "yes")
+			w.Header().Set("Connection", "X-Hop")
+			w.Header().Set("X-Hop", "drop me")
+			io.WriteString(w, "body{}")
+		case "/assets/submit":
+			io.WriteString(w, r.Method)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{Upstream: upstream.URL + "/assets", DefaultTTL: time.Minute})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	t.Run("MissThenHit", func(t *testing.T) {
+		resp, body := get(t, proxy, http.MethodGet, "/app.css")
+		if resp.StatusCode != http.StatusOK || body != "body{}" || resp.Header.Get("X-Cache") != "MISS" {
+			t.Fatalf("Expected MISS with body, got %d %q %q", resp.StatusCode, body, resp.Header.Get("X-Cache"))
+		}
+		if resp.Header.Get("X-Hop") != "" {
+			t.Error("Expected headers listed in Connection to be dropped")
+		}
+
+		resp, body = get(t, proxy, http.MethodGet, "/app.css")
+		if body != "body{}" || resp.Header.Get("X-Cache") != "HIT" {
+			t.Errorf("Expected HIT with body, got %q %q", body, resp.Header.Get("X-Cache"))
+		}
+		if resp.Header.Get("Content-Type") != "text/css" || resp.Header.Get("X-Origin") != "yes" {
+			t.Errorf("Expected upstream headers to be cached, got %v", resp.Header)
+		}
+		if resp.Header.Get("Content-Length") != "6" || resp.Header.Get("Age") == "" {
+			t.Errorf("Expected Content-Length and Age, got %v", resp.Header)
+		}
+
+		resp, body = get(t, proxy, http.MethodHead, "/app.css")
+		if body != "" || resp.Header.Get("X-Cache") != "HIT" || resp.Header.Get("Content-Length") != "6" {
+			t.Errorf("Expected HEAD HIT without body, got %q %v", body, resp.Header)
+		}
+		if n := upstream.requests.Load(); n != 1 {
+			t.Errorf("Expected 1 upstream request, got %d", n)
+		}
+		if info, err := cache.GetInfo(context.Background(), "/app.css"); err != nil || info.MimeType != "text/css" {
+			t.Errorf("Expected entry with text/css, got %+v, %v", info, err)
+		}
+	})
+
+	t.Run("ErrorsNotCached", func(t *testing.T) {
+		before := upstream.requests.Load()
+		for i := 0; i < 2; i++ {
+			resp, _ := get(t, proxy, http.MethodGet, "/missing.css")
+			if resp.StatusCode != http.StatusNotFound || resp.Header.Get("X-Cache") != "MISS" {
+				t.Errorf("Expected 404 MISS, got %d %q", resp.StatusCode, resp.Header.Get("X-Cache"))
+			}
+		}
+		if n := upstream.requests.Load() - before; n != 2 {
+			t.Errorf("Expected 2 upstream requests, got %d", n)
+		}
+	})
+
+	t.Run("ForwardOtherMethods", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/submit?x=1", strings.NewReader("data"))
+		req.Header.Set("X-Forwarded-For", "10.0.0.1")
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		if rec.Body.String() != http.MethodPost || rec.Header().Get("X-Cache") != "BYPASS" {
+			t.Errorf("Expected POST to be forwarded, got %q %q", rec.Body.String(), rec.Header().Get("X-Cache"))
+		}
+		last := upstream.last.Load().(*http.Request)
+		if last.URL.RawQuery != "x=1" || last.Header.Get("X-Forwarded-For") != "10.0.0.1, 192.0.2.1" {
+			t.Errorf("Unexpected upstream request: %s %v", last.URL, last.Header)
+		}
+		if exists, _ := cache.Exists(context.Background(), "/submit?x=1"); exists {
+			t.Error("Expected POST response not to be cached")
+		}
+	})
+
+	t.Run("PlainEntry", func(t *testing.T) {
+		cache.Set(context.Background(), "/warm.txt", strings.NewReader("warm"), "text/plain", time.Minute)
+		resp, body := get(t, proxy, http.MethodGet, "/warm.txt")
+		if body != "warm" || resp.Header.Get("Content-Type") != "text/plain" || resp.Header.Get("X-Cache") != "HIT" {
+			t.Errorf("Expected plain entry to be served, got %q %v", body, resp.Header)
+		}
+	})
+}
+
+func TestProxyUpstreamErrors(t *testing.T) {
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+
+	proxy, err := NewProxy(cache, Options{Upstream: "http://127.0.0.1:1"})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	if resp, _ := get(t, proxy, http.MethodGet, "/a"); resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected 502, got %d", resp.StatusCode)
+	}
+
+	var handled error
+	proxy, _ = NewProxy(cache, Options{
+		Upstream: "http://127.0.0.1:1",
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			handled = err
+			w.WriteHeader(http.StatusServiceUnavailable)
+		},
+	})
+	if resp, _ := get(t, proxy, http.MethodGet, "/a"); resp.StatusCode != http.StatusServiceUnavailable || handled == nil {
+		t.Errorf("Expected custom error handler, got %d", resp.StatusCode)
+	}
+
+	for _, upstream := range []string{"", "origin.example.com", "ftp://origin.example.com", "http://"} {
+		if _, err := NewProxy(cache, Options{Upstream: upstream}); err == nil {
+			t.Errorf("Expected error for upstream %q", upstream)
+		}
+	}
+	if _, err := NewProxy(cache, Options{Upstream: "http://a", DefaultTTL: -time.Second}); err == nil {
+		t.Error("Expected error for negative ttl")
+	}
+}
+
+func TestReadEntry(t *testing.T) {
+	e := &entry{Status: http.StatusOK, Header: http.Header{"Etag": {`"v1"`}}, StoredAt: time.Now()}
+	meta, err := e.encode()
+	if err != nil {
+		t.Fatalf("Failed to encode entry: %v", err)
+	}
+	got, body, n, err := readEntry(strings.NewReader(string(meta) + "payload"))
+	if err != nil || got == nil {
+		t.Fatalf("Failed to read entry: %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	if got.Header.Get("ETag") != `"v1"` || string(data) != "payload" || n != int64(len(meta)) {
+		t.Errorf("Unexpected entry: %+v, body %q, header size %d", got, data, n)
+	}
+
+	for _, raw := range []string{"", "ab", "plain file content"} {
+		got, body, n, err := readEntry(strings.NewReader(raw))
+		data, _ := io.ReadAll(body)
+		if err != nil || got != nil || n != 0 || string(data) != raw {
+			t.Errorf("Expected %q to be read as plain content, got %+v %q %v", raw, got, data, err)
+		}
+	}
+	if _, _, _, err := readEntry(strings.NewReader(entryMagic + "\x00\x00\x00\x05{bad")); err == nil {
+		t.Error("Expected error for truncated header")
+	}
+}
+
+// readFromRecorder 记录ReadFrom收到的是否为文件，与net包判断能否用sendfile的方式相同：
+// *os.File和io.Copy包装它的类型都实现syscall.Conn
+type readFromRecorder struct {
+	*httptest.ResponseRecorder
+	file bool
+}
+
+func (w *readFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	inner := src
+	if limited, ok := src.(*io.LimitedReader); ok {
+		inner = limited.R
+	}
+	_, w.file = inner.(syscall.Conn)
+	return io.Copy(w.ResponseRecorder, src)
+}
+
+func TestSendfile(t *testing.T) {
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		io.WriteString(w, "large object body")
+	})
+	cache, err := filecache.NewFSBlobCache(&filecache.Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1 << 20,
+		MaxEntrySize:    1 << 20,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+	cache.Set(context.Background(), "/plain.bin", strings.NewReader("plain file"), "application/octet-stream", time.Hour)
+	proxy, err := NewProxy(cache, Options{
+		Upstream: upstream.URL,
+		Headers:  []HeaderRule{{Path: "/*", Response: &HeaderActions{Set: map[string]string{"X-Edge": "1"}}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	get(t, proxy, http.MethodGet, "/video.mp4")
+
+	for _, tc := range []struct{ target, rng, want string }{
+		{"/video.mp4", "", "large object body"},
+		{"/video.mp4", "bytes=6-11", "object"},
+		{"/plain.bin", "", "plain file"},
+	} {
+		rec := &readFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+		req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		if tc.rng != "" {
+			req.Header.Set("Range", tc.rng)
+		}
+		proxy.ServeHTTP(rec, req)
+		if rec.Body.String() != tc.want || !rec.file || rec.Header().Get("X-Cache") != "HIT" || rec.Header().Get("X-Edge") != "1" {
+			t.Errorf("Expected %q copied from the file for %s %q, got %q (file %v, X-Cache %q)",
+				tc.want, tc.target, tc.rng, rec.Body.String(), rec.file, rec.Header().Get("X-Cache"))
+		}
+	}
+}
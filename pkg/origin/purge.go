@@ -0,0 +1,169 @@
+package origin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// defaultPurgePath 服务端口上按前缀清除的默认路径
+const defaultPurgePath = "/purge"
+
+// PurgeOptions 服务端口上的清除接口，与Varnish的用法相同：PURGE <url> 删除该URL的缓存，
+// POST <Path>?prefix= 删除键以prefix开头的缓存。请求携带Tokens中的令牌或来自AllowedIPs时允许
+type PurgeOptions struct {
+	// Tokens 请求携带 Authorization: Bearer <token> 时允许
+	Tokens []string `json:"tokens,omitempty"`
+
+	// AllowedIPs 允许的客户端地址，IP或CIDR，例如部署流水线所在的网段
+	AllowedIPs []string `json:"allowed_ips,omitempty"`
+
+	// Path 按前缀清除的路径，默认"/purge"；这个路径上的POST请求不再转发到上游
+	Path string `json:"path,omitempty"`
+
+	networks []*net.IPNet
+}
+
+// validate 检查选项并解析AllowedIPs
+func (o *PurgeOptions) validate() error {
+	if len(o.Tokens) == 0 && len(o.AllowedIPs) == 0 {
+		return fmt.Errorf("purge requires tokens or allowed ips")
+	}
+	for _, token := range o.Tokens {
+		if token == "" {
+			return fmt.Errorf("purge token cannot be empty")
+		}
+	}
+	o.networks = nil
+	for _, addr := range o.AllowedIPs {
+		if !strings.Contains(addr, "/") {
+			ip := net.ParseIP(addr)
+			if ip == nil {
+				return fmt.Errorf("invalid purge allowed ip %q", addr)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			o.networks = append(o.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(addr)
+		if err != nil {
+			return fmt.Errorf("invalid purge allowed ip %q: %w", addr, err)
+		}
+		o.networks = append(o.networks, network)
+	}
+	if o.Path == "" {
+		o.Path = defaultPurgePath
+	}
+	return nil
+}
+
+// allowed 返回请求是否携带有效的令牌或来自允许的地址
+func (o *PurgeOptions) allowed(r *http.Request) bool {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && token != "" {
+		for _, t := range o.Tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+				return true
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	for _, network := range o.networks {
+		if ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPurge 返回请求是否是清除请求
+func (p *Proxy) isPurge(r *http.Request) bool {
+	return p.opts.Purge != nil && (r.Method == "PURGE" || r.Method == http.MethodPost && r.URL.Path == p.opts.Purge.Path)
+}
+
+// servePurge 处理清除请求，返回{"purged": n}
+func (p *Proxy) servePurge(w http.ResponseWriter, r *http.Request) {
+	if !p.opts.Purge.allowed(r) {
+		writePurgeResult(w, http.StatusForbidden, map[string]string{"error": "purge not allowed"})
+		return
+	}
+
+	var (
+		purged int
+		err    error
+	)
+	if r.Method == "PURGE" {
+		if p.opts.Signing != nil {
+			r = p.opts.Signing.strip(r)
+		}
+		if len(p.opts.Rewrites) > 0 {
+			r = p.rewrite(r)
+		}
+		purged, err = p.purgeKey(r.Context(), p.key(r))
+	} else {
+		prefix := r.URL.Query().Get("prefix")
+		if prefix == "" {
+			writePurgeResult(w, http.StatusBadRequest, map[string]string{"error": "prefix is required"})
+			return
+		}
+		purged, err = p.purgePrefix(r.Context(), prefix)
+	}
+	if err != nil {
+		writePurgeResult(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writePurgeResult(w, http.StatusOK, map[string]int{"purged": purged})
+}
+
+// purgeKey 删除键上的条目，以及它的变体、片段和分片（键后加"#"的派生键）
+func (p *Proxy) purgeKey(ctx context.Context, key string) (int, error) {
+	return p.purgeMatching(ctx, func(k string) bool {
+		return k == key || strings.HasPrefix(k, key+"#")
+	})
+}
+
+// purgePrefix 删除键以prefix开头的所有条目
+func (p *Proxy) purgePrefix(ctx context.Context, prefix string) (int, error) {
+	return p.purgeMatching(ctx, func(k string) bool {
+		return strings.HasPrefix(k, prefix)
+	})
+}
+
+// purgeMatching 删除键满足match的所有条目，返回删除的条目数
+func (p *Proxy) purgeMatching(ctx context.Context, match func(key string) bool) (int, error) {
+	files, err := p.cache.List(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list cache: %w", err)
+	}
+	purged := 0
+	for _, info := range files {
+		if !match(info.Key) {
+			continue
+		}
+		if err := p.cache.Delete(ctx, info.Key); err != nil && !errors.Is(err, filecache.ErrNotFound) {
+			return purged, fmt.Errorf("failed to delete %s: %w", info.Key, err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// writePurgeResult 返回JSON结果
+func writePurgeResult(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
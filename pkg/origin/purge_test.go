@@ -0,0 +1,146 @@
+package origin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestPurgeOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts PurgeOptions
+	}{
+		{"Empty", PurgeOptions{}},
+		{"EmptyToken", PurgeOptions{Tokens: []string{""}}},
+		{"InvalidIP", PurgeOptions{AllowedIPs: []string{"10.0.0"}}},
+		{"InvalidCIDR", PurgeOptions{AllowedIPs: []string{"10.0.0.0/33"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewProxy(filecache.NewMemoryCache(1<<20), Options{Upstream: "http://127.0.0.1", Purge: &tt.opts}); err == nil {
+				t.Error("Expected invalid purge options to be rejected")
+			}
+		})
+	}
+
+	opts := PurgeOptions{Tokens: []string{"secret"}, AllowedIPs: []string{"10.0.0.0/8", "2001:db8::1"}}
+	if err := opts.validate(); err != nil {
+		t.Fatalf("Failed to validate purge options: %v", err)
+	}
+	allowed := []struct {
+		remote, auth string
+		want         bool
+	}{
+		{"10.1.2.3:4000", "", true},
+		{"[2001:db8::1]:4000", "", true},
+		{"[2001:db8::2]:4000", "", false},
+		{"192.0.2.1:4000", "Bearer secret", true},
+		{"192.0.2.1:4000", "Bearer wrong", false},
+		{"192.0.2.1:4000", "secret", false},
+		{"192.0.2.1:4000", "", false},
+	}
+	for _, tt := range allowed {
+		req := httptest.NewRequest("PURGE", "/", nil)
+		req.RemoteAddr = tt.remote
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		if got := opts.allowed(req); got != tt.want {
+			t.Errorf("Expected allowed(%s, %q) to be %v, got %v", tt.remote, tt.auth, tt.want, got)
+		}
+	}
+}
+
+func TestPurgeEndpoint(t *testing.T) {
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/lang" {
+			w.Header().Set("Vary", "Accept-Language")
+		}
+		io.WriteString(w, r.URL.Path)
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{
+		Upstream:   upstream.URL,
+		DefaultTTL: time.Hour,
+		Purge:      &PurgeOptions{Tokens: []string{"secret"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	exists := func(key string) bool {
+		ok, _ := cache.Exists(context.Background(), key)
+		return ok
+	}
+	purge := func(method, target, token string) (int, map[string]interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(method, target, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		var result map[string]interface{}
+		if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+			t.Fatalf("Failed to decode purge response: %v", err)
+		}
+		return rec.Code, result
+	}
+
+	for _, target := range []string{"/assets/a.css", "/assets/b.css", "/lang", "/index.html"} {
+		get(t, proxy, http.MethodGet, target)
+	}
+
+	t.Run("Forbidden", func(t *testing.T) {
+		if code, _ := purge("PURGE", "/index.html", ""); code != http.StatusForbidden {
+			t.Errorf("Expected 403 without token, got %d", code)
+		}
+		if code, _ := purge("PURGE", "/index.html", "wrong"); code != http.StatusForbidden {
+			t.Errorf("Expected 403 with wrong token, got %d", code)
+		}
+		if !exists("/index.html") {
+			t.Error("Expected entry to survive unauthorized purge")
+		}
+	})
+
+	t.Run("URL", func(t *testing.T) {
+		code, result := purge("PURGE", "/lang", "secret")
+		if code != http.StatusOK || result["purged"] != float64(2) {
+			t.Errorf("Expected marker and variant to be purged, got %d %v", code, result)
+		}
+		if exists("/lang") {
+			t.Error("Expected /lang to be purged")
+		}
+		if code, result := purge("PURGE", "/lang", "secret"); code != http.StatusOK || result["purged"] != float64(0) {
+			t.Errorf("Expected nothing to purge, got %d %v", code, result)
+		}
+	})
+
+	t.Run("Prefix", func(t *testing.T) {
+		if code, _ := purge(http.MethodPost, "/purge", "secret"); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 without prefix, got %d", code)
+		}
+		code, result := purge(http.MethodPost, "/purge?prefix=/assets/", "secret")
+		if code != http.StatusOK || result["purged"] != float64(2) {
+			t.Errorf("Expected 2 entries to be purged, got %d %v", code, result)
+		}
+		if exists("/assets/a.css") || exists("/assets/b.css") || !exists("/index.html") {
+			t.Error("Expected only entries under /assets/ to be purged")
+		}
+	})
+
+	before := upstream.requests.Load()
+	if resp, body := get(t, proxy, http.MethodGet, "/assets/a.css"); resp.Header.Get("X-Cache") != "MISS" || body != "/assets/a.css" {
+		t.Errorf("Expected purged entry to be fetched again, got %q %q", resp.Header.Get("X-Cache"), body)
+	}
+	if n := upstream.requests.Load() - before; n != 1 {
+		t.Errorf("Expected 1 upstream request after purge, got %d", n)
+	}
+}
@@ -0,0 +1,158 @@
+package origin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// errRangeNotSatisfiable 请求的范围超出响应体
+var errRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// byteRange 闭区间[Start, End]的字节范围
+type byteRange struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// length 返回范围的字节数
+func (b byteRange) length() int64 {
+	return b.End - b.Start + 1
+}
+
+// contentRange 返回Content-Range头的值
+func (b byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", b.Start, b.End, size)
+}
+
+// parseRange 按响应体长度size解析Range头。只支持单个范围，没有Range、多个范围或格式错误时返回nil，
+// 按RFC 9110第14.2节忽略Range返回完整响应；范围超出响应体时返回errRangeNotSatisfiable
+func parseRange(header string, size int64) (*byteRange, error) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return nil, nil
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return nil, nil
+	}
+	if first == "" {
+		// 后缀范围：最后n个字节
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return nil, nil
+		}
+		if n == 0 || size == 0 {
+			return nil, errRangeNotSatisfiable
+		}
+		if n > size {
+			n = size
+		}
+		return &byteRange{Start: size - n, End: size - 1}, nil
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return nil, nil
+	}
+	end := size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return nil, nil
+		}
+	}
+	if start >= size {
+		return nil, errRangeNotSatisfiable
+	}
+	if end >= size {
+		end = size - 1
+	}
+	return &byteRange{Start: start, End: end}, nil
+}
+
+// parseContentRange 解析206响应的Content-Range头，返回范围和完整响应体的长度，长度未知时返回false
+func parseContentRange(header string) (byteRange, int64, bool) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes ")
+	if !ok {
+		return byteRange{}, 0, false
+	}
+	rng, total, ok := strings.Cut(spec, "/")
+	if !ok {
+		return byteRange{}, 0, false
+	}
+	first, last, ok := strings.Cut(rng, "-")
+	if !ok {
+		return byteRange{}, 0, false
+	}
+	start, err1 := strconv.ParseInt(first, 10, 64)
+	end, err2 := strconv.ParseInt(last, 10, 64)
+	size, err3 := strconv.ParseInt(total, 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil || start < 0 || end < start || end >= size {
+		return byteRange{}, 0, false
+	}
+	return byteRange{Start: start, End: end}, size, true
+}
+
+// ifRangeMatches 按RFC 9110第13.1.5节判断If-Range：ETag按强比较，日期必须与Last-Modified相同；
+// 没有If-Range时返回true
+func ifRangeMatches(r *http.Request, h http.Header) bool {
+	ifRange := r.Header.Get("If-Range")
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		etag := h.Get("ETag")
+		return etag != "" && !strings.HasPrefix(etag, "W/") && ifRange == etag
+	}
+	return ifRange == h.Get("Last-Modified")
+}
+
+// writeRange 处理对完整响应的Range请求：返回206或416，不是有效的单个范围时返回false，由调用方返回完整响应
+func (p *Proxy) writeRange(w http.ResponseWriter, r *http.Request, e *entry, body io.Reader, size int64, status string) bool {
+	if e.Status != http.StatusOK || r.Header.Get("Range") == "" || !ifRangeMatches(r, e.Header) {
+		return false
+	}
+	rng, err := parseRange(r.Header.Get("Range"), size)
+	if err != nil {
+		writeNotSatisfiable(w, size, status)
+		return true
+	}
+	if rng == nil {
+		return false
+	}
+
+	header := w.Header()
+	copyHeader(header, e.Header)
+	header.Set("Accept-Ranges", "bytes")
+	header.Set("Content-Range", rng.contentRange(size))
+	header.Set("Content-Length", strconv.FormatInt(rng.length(), 10))
+	header.Set("Age", formatAge(e))
+	header.Set("X-Cache", status)
+	w.WriteHeader(http.StatusPartialContent)
+	if r.Method == http.MethodGet {
+		if skip(body, rng.Start) == nil {
+			io.CopyN(w, body, rng.length())
+		}
+	}
+	return true
+}
+
+// writeNotSatisfiable 返回416
+func writeNotSatisfiable(w http.ResponseWriter, size int64, status string) {
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+	w.Header().Set("X-Cache", status)
+	http.Error(w, errRangeNotSatisfiable.Error(), http.StatusRequestedRangeNotSatisfiable)
+}
+
+// skip 跳过body的前n个字节，body支持Seek时直接定位
+func skip(body io.Reader, n int64) error {
+	if seeker, ok := body.(io.Seeker); ok {
+		if _, err := seeker.Seek(n, io.SeekCurrent); err == nil {
+			return nil
+		}
+	}
+	_, err := io.CopyN(io.Discard, body, n)
+	return err
+}
@@ -0,0 +1,142 @@
+package origin
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header string
+		want   *byteRange
+		err    error
+	}{
+		{"bytes=0-99", &byteRange{0, 99}, nil},
+		{"bytes=100-", &byteRange{100, 999}, nil},
+		{"bytes=-100", &byteRange{900, 999}, nil},
+		{"bytes=-2000", &byteRange{0, 999}, nil},
+		{"bytes=900-5000", &byteRange{900, 999}, nil},
+		{"bytes=1000-", nil, errRangeNotSatisfiable},
+		{"bytes=-0", nil, errRangeNotSatisfiable},
+		{"bytes=0-1,5-6", nil, nil},
+		{"bytes=5-1", nil, nil},
+		{"items=0-1", nil, nil},
+		{"bytes=abc", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			got, err := parseRange(tt.header, 1000)
+			if err != tt.err || (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+				t.Errorf("Expected %v %v, got %v %v", tt.want, tt.err, got, err)
+			}
+		})
+	}
+}
+
+func TestRangeRequests(t *testing.T) {
+	content := strings.Repeat("0123456789", 1000)
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "max-age=600")
+		http.ServeContent(w, r, "video.mp4", modTime, strings.NewReader(content))
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{Upstream: upstream.URL})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	rangeGet := func(target, rng string, header ...string) (*http.Response, string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Range", rng)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		resp := rec.Result()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	t.Run("Partial", func(t *testing.T) {
+		before := upstream.requests.Load()
+		resp, body := rangeGet("/partial.mp4", "bytes=0-999")
+		if resp.StatusCode != http.StatusPartialContent || body != content[:1000] || resp.Header.Get("X-Cache") != "MISS" {
+			t.Fatalf("Expected 206 MISS, got %d %q len %d", resp.StatusCode, resp.Header.Get("X-Cache"), len(body))
+		}
+		if got := upstream.last.Load().(*http.Request).Header.Get("Range"); got != "bytes=0-999" {
+			t.Errorf("Expected only the requested range upstream, got %q", got)
+		}
+
+		resp, body = rangeGet("/partial.mp4", "bytes=100-199")
+		if resp.StatusCode != http.StatusPartialContent || body != content[100:200] || resp.Header.Get("X-Cache") != "HIT" {
+			t.Errorf("Expected 206 HIT inside cached piece, got %d %q %q", resp.StatusCode, resp.Header.Get("X-Cache"), body)
+		}
+		if got := resp.Header.Get("Content-Range"); got != "bytes 100-199/10000" {
+			t.Errorf("Expected content range of the request, got %q", got)
+		}
+
+		rangeGet("/partial.mp4", "bytes=1000-1999")
+		resp, body = rangeGet("/partial.mp4", "bytes=500-1499")
+		if resp.StatusCode != http.StatusPartialContent || body != content[500:1500] || resp.Header.Get("X-Cache") != "HIT" {
+			t.Errorf("Expected 206 HIT spanning pieces, got %d %q len %d", resp.StatusCode, resp.Header.Get("X-Cache"), len(body))
+		}
+		if n := upstream.requests.Load() - before; n != 2 {
+			t.Errorf("Expected 2 upstream requests, got %d", n)
+		}
+
+		resp, _ = rangeGet("/partial.mp4", "bytes=1500-2499")
+		if resp.Header.Get("X-Cache") != "MISS" {
+			t.Errorf("Expected uncovered range to miss, got %q", resp.Header.Get("X-Cache"))
+		}
+		if resp, _ = rangeGet("/partial.mp4", "bytes=20000-"); resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+			t.Errorf("Expected 416, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("FullEntry", func(t *testing.T) {
+		if resp, body := get(t, proxy, http.MethodGet, "/full.mp4"); resp.StatusCode != http.StatusOK || body != content {
+			t.Fatalf("Expected full response, got %d", resp.StatusCode)
+		}
+		before := upstream.requests.Load()
+		tests := []struct {
+			name, rng string
+			header    []string
+			want      int
+			body      string
+		}{
+			{"Range", "bytes=10-19", nil, http.StatusPartialContent, content[10:20]},
+			{"Suffix", "bytes=-5", nil, http.StatusPartialContent, content[len(content)-5:]},
+			{"Unsatisfiable", "bytes=10000-", nil, http.StatusRequestedRangeNotSatisfiable, ""},
+			{"MultipleRanges", "bytes=0-1,5-6", nil, http.StatusOK, content},
+			{"IfRangeMatch", "bytes=10-19", []string{"If-Range", `"v1"`}, http.StatusPartialContent, content[10:20]},
+			{"IfRangeMismatch", "bytes=10-19", []string{"If-Range", `"v0"`}, http.StatusOK, content},
+			{"IfRangeDate", "bytes=10-19", []string{"If-Range", modTime.Format(http.TimeFormat)}, http.StatusPartialContent, content[10:20]},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				resp, body := rangeGet("/full.mp4", tt.rng, tt.header...)
+				if resp.StatusCode != tt.want || resp.Header.Get("X-Cache") != "HIT" {
+					t.Fatalf("Expected %d HIT, got %d %q", tt.want, resp.StatusCode, resp.Header.Get("X-Cache"))
+				}
+				if tt.body != "" && !bytes.Equal([]byte(body), []byte(tt.body)) {
+					t.Errorf("Expected body of length %d, got %d", len(tt.body), len(body))
+				}
+			})
+		}
+		if n := upstream.requests.Load() - before; n != 0 {
+			t.Errorf("Expected ranges to be served from the full entry, got %d upstream requests", n)
+		}
+	})
+}
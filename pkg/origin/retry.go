@@ -0,0 +1,183 @@
+package origin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMaxRetries = 2
+	defaultBackoff    = 100 * time.Millisecond
+	defaultMaxBackoff = 2 * time.Second
+)
+
+// RetryPolicy 回源失败时的重试策略：上游出错、超时或返回RetryStatus中的状态码时等待后重试，
+// 等待时间从Backoff开始每次翻倍（加上随机抖动），不超过MaxBackoff；上游返回Retry-After时按它等待。
+// 只重试幂等的请求（GET、HEAD、OPTIONS、PUT、DELETE，或带有Idempotency-Key头的请求），
+// 请求体无法重放的请求不重试；所有上游都熔断时（ErrCircuitOpen）不重试
+type RetryPolicy struct {
+	// MaxRetries 最多重试的次数，默认2
+	MaxRetries int `json:"max_retries,omitempty"`
+
+	// Backoff 第一次重试前的等待时间，默认100毫秒
+	Backoff time.Duration `json:"backoff,omitempty"`
+
+	// MaxBackoff 两次尝试之间的最长等待时间，默认2秒；Retry-After更长时不再重试
+	MaxBackoff time.Duration `json:"max_backoff,omitempty"`
+
+	// RetryStatus 需要重试的状态码，默认502、503和504
+	RetryStatus []int `json:"retry_status,omitempty"`
+
+	// RetryNonIdempotent 也重试POST等非幂等的请求，只在上游能正确处理重复请求时开启
+	RetryNonIdempotent bool `json:"retry_non_idempotent,omitempty"`
+}
+
+// validate 检查选项并填充默认值
+func (p *RetryPolicy) validate() error {
+	if p.MaxRetries < 0 || p.Backoff < 0 || p.MaxBackoff < 0 {
+		return fmt.Errorf("retry options cannot be negative")
+	}
+	if p.MaxRetries == 0 {
+		p.MaxRetries = defaultMaxRetries
+	}
+	if p.Backoff == 0 {
+		p.Backoff = defaultBackoff
+	}
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = defaultMaxBackoff
+	}
+	if p.Backoff > p.MaxBackoff {
+		return fmt.Errorf("retry backoff %v exceeds max backoff %v", p.Backoff, p.MaxBackoff)
+	}
+	if len(p.RetryStatus) == 0 {
+		p.RetryStatus = defaultFailoverStatus
+	}
+	return nil
+}
+
+// retryable 返回请求是否可以重试
+func (p *RetryPolicy) retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	if _, ok := req.Header["Idempotency-Key"]; ok {
+		return true
+	}
+	return p.RetryNonIdempotent
+}
+
+// retryStatus 返回状态码是否需要重试
+func (p *RetryPolicy) retryStatus(status int) bool {
+	for _, s := range p.RetryStatus {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff 返回第n次重试（从0开始）前的等待时间：Backoff*2^n，在一半到全部之间随机，不超过MaxBackoff
+func (p *RetryPolicy) backoff(n int) time.Duration {
+	d := p.Backoff
+	for i := 0; i < n && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// retryFetcher 按RetryPolicy重试的Fetcher
+type retryFetcher struct {
+	next   Fetcher
+	policy RetryPolicy
+}
+
+// NewRetryFetcher 创建按policy重试next的Fetcher，next是NewFailoverFetcher时每次重试重新选择上游
+func NewRetryFetcher(next Fetcher, policy RetryPolicy) (Fetcher, error) {
+	policy.RetryStatus = append([]int(nil), policy.RetryStatus...)
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+	return &retryFetcher{next: next, policy: policy}, nil
+}
+
+// Fetch 发送请求，失败时等待后重试，返回最后一次尝试的结果
+func (f *retryFetcher) Fetch(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if !f.policy.retryable(req) {
+		return f.next.Fetch(ctx, req)
+	}
+	for n := 0; ; n++ {
+		if n > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to replay request body: %w", err)
+			}
+			req = req.WithContext(ctx)
+			req.Body = body
+		}
+		resp, err := f.next.Fetch(ctx, req)
+		if n == f.policy.MaxRetries || ctx.Err() != nil || errors.Is(err, ErrCircuitOpen) {
+			return resp, err
+		}
+		wait := f.policy.backoff(n)
+		if err == nil {
+			if !f.policy.retryStatus(resp.StatusCode) {
+				return resp, nil
+			}
+			if after, ok := retryAfter(resp.Header); ok {
+				if after > f.policy.MaxBackoff {
+					return resp, nil
+				}
+				wait = after
+			}
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Close 关闭next
+func (f *retryFetcher) Close() error {
+	if closer, ok := f.next.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// retryAfter 解析Retry-After响应头，支持秒数和HTTP日期
+func retryAfter(h http.Header) (time.Duration, bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
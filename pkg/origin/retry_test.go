@@ -0,0 +1,115 @@
+package origin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestRetry(t *testing.T) {
+	var failures atomic.Int64
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/later":
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case failures.Add(-1) >= 0:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			io.WriteString(w, "ok")
+		}
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{
+		Upstream: upstream.URL,
+		Retry:    &RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		header   string
+		failures int64
+		requests int64
+		status   int
+	}{
+		{"transient failures", http.MethodGet, "/a", "", 2, 3, http.StatusOK},
+		{"retries exhausted", http.MethodGet, "/b", "", 5, 3, http.StatusBadGateway},
+		{"non-idempotent", http.MethodPost, "/c", "", 1, 1, http.StatusBadGateway},
+		{"idempotency key", http.MethodPost, "/d", "Idempotency-Key", 1, 2, http.StatusOK},
+		{"retry after too long", http.MethodGet, "/later", "", 0, 1, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failures.Store(tt.failures)
+			before := upstream.requests.Load()
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, "k1")
+			}
+			rec := httptest.NewRecorder()
+			proxy.ServeHTTP(rec, req)
+			if rec.Code != tt.status || upstream.requests.Load()-before != tt.requests {
+				t.Errorf("Expected %d after %d requests, got %d after %d", tt.status, tt.requests, rec.Code, upstream.requests.Load()-before)
+			}
+		})
+	}
+
+	t.Run("invalid options", func(t *testing.T) {
+		if _, err := NewProxy(cache, Options{Upstream: upstream.URL, Retry: &RetryPolicy{Backoff: time.Second, MaxBackoff: time.Millisecond}}); err == nil {
+			t.Error("Expected error for backoff above max backoff")
+		}
+	})
+}
+
+func TestRetryFetcher(t *testing.T) {
+	var calls int
+	next := fetcherFunc(func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("connection reset")
+		}
+		return statusResponse(req, http.StatusOK), nil
+	})
+	f, err := NewRetryFetcher(next, RetryPolicy{Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create fetcher: %v", err)
+	}
+	resp, err := f.Fetch(context.Background(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if err != nil || resp.StatusCode != http.StatusOK || calls != 2 {
+		t.Fatalf("Expected retry after error, got %v after %d calls", err, calls)
+	}
+	resp.Body.Close()
+
+	calls = 0
+	open := fetcherFunc(func(ctx context.Context, req *http.Request) (*http.Response, error) {
+		calls++
+		return nil, ErrCircuitOpen
+	})
+	f, _ = NewRetryFetcher(open, RetryPolicy{Backoff: time.Millisecond})
+	if _, err := f.Fetch(context.Background(), httptest.NewRequest(http.MethodGet, "/", nil)); !errors.Is(err, ErrCircuitOpen) || calls != 1 {
+		t.Errorf("Expected no retry while circuit is open, got %v after %d calls", err, calls)
+	}
+
+	policy := RetryPolicy{}
+	if err := policy.validate(); err != nil {
+		t.Fatalf("Failed to validate default policy: %v", err)
+	}
+	for n, max := range map[int]time.Duration{0: 100 * time.Millisecond, 1: 200 * time.Millisecond, 3: 800 * time.Millisecond, 20: 2 * time.Second} {
+		if d := policy.backoff(n); d < max/2 || d > max {
+			t.Errorf("Expected backoff %d between %v and %v, got %v", n, max/2, max, d)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package origin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// staleEntry 已过期但尚未清理的缓存条目，用于stale-while-revalidate和条件回源
+type staleEntry struct {
+	*entry
+	info   *filecache.FileInfo
+	body   io.Reader
+	size   int64 // 响应体长度
+	reader io.ReadCloser
+}
+
+// Close 关闭条目的reader
+func (s *staleEntry) Close() error {
+	return s.reader.Close()
+}
+
+// openStale 读取代理写入的条目，不论是否过期，基础键上是变体标记时按请求头h读取对应的变体；
+// 缓存不支持filecache.StaleGetter、条目不存在或不是代理写入的条目时返回nil
+func (p *Proxy) openStale(ctx context.Context, key string, h http.Header) *staleEntry {
+	getter, ok := p.cache.(filecache.StaleGetter)
+	if !ok {
+		return nil
+	}
+	reader, info, err := getter.GetStale(ctx, key)
+	if err != nil {
+		return nil
+	}
+	e, body, headerSize, err := readEntry(reader)
+	if err != nil || e == nil {
+		reader.Close()
+		return nil
+	}
+	if len(e.Vary) > 0 {
+		reader.Close()
+		return p.openStale(ctx, variantKey(key, e.Vary, h), h)
+	}
+	return &staleEntry{entry: e, info: info, body: body, size: info.Size - headerSize, reader: reader}
+}
+
+// setValidators 用缓存条目的ETag和Last-Modified设置条件回源请求头
+func setValidators(h http.Header, e *entry) {
+	if etag := e.Header.Get("ETag"); etag != "" {
+		h.Set("If-None-Match", etag)
+	}
+	if lastModified := e.Header.Get("Last-Modified"); lastModified != "" {
+		h.Set("If-Modified-Since", lastModified)
+	}
+}
+
+// freshen 按RFC 9111第4.3.4节用304响应更新条目：响应中的头替换条目中的同名头，响应体不变
+func (e *entry) freshen(resp *http.Response, responseTime time.Time) *entry {
+	header := e.Header.Clone()
+	// 旧的Age和Date不再适用，304中没有时按收到响应的时间计算
+	header.Del("Age")
+	header.Set("Date", responseTime.UTC().Format(http.TimeFormat))
+	updated := endToEndHeader(resp.Header)
+	tags := takeTags(updated)
+	if tags == nil {
+		tags = e.Tags
+	}
+	for name, values := range updated {
+		header[name] = values
+	}
+	return &entry{
+		Status:     e.Status,
+		Header:     header,
+		StoredAt:   responseTime,
+		InitialAge: initialAge(resp.Header, responseTime),
+		Tags:       tags,
+	}
+}
@@ -0,0 +1,68 @@
+package origin
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestConditionalRevalidation(t *testing.T) {
+	var etag atomic.Value
+	etag.Store(`"v1"`)
+	var notModified, full atomic.Int64
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		current := etag.Load().(string)
+		w.Header().Set("ETag", current)
+		if r.Header.Get("If-None-Match") == current {
+			notModified.Add(1)
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full.Add(1)
+		w.Header().Set("Cache-Control", "max-age=1")
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("content " + current))
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{Upstream: upstream.URL})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	get(t, proxy, http.MethodGet, "/a")
+	time.Sleep(1100 * time.Millisecond)
+
+	resp, body := get(t, proxy, http.MethodGet, "/a")
+	if body != `content "v1"` || resp.Header.Get("X-Cache") != "REVALIDATED" || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected revalidated body, got %d %q %q", resp.StatusCode, body, resp.Header.Get("X-Cache"))
+	}
+	if resp.Header.Get("Content-Type") != "text/plain" || resp.Header.Get("Cache-Control") != "max-age=60" {
+		t.Errorf("Expected stored headers updated by the 304, got %v", resp.Header)
+	}
+	if notModified.Load() != 1 || full.Load() != 1 {
+		t.Errorf("Expected 1 full and 1 conditional fetch, got %d and %d", full.Load(), notModified.Load())
+	}
+	info, _ := cache.GetInfo(context.Background(), "/a")
+	if ttl := time.Until(info.ExpiresAt); ttl < 55*time.Second {
+		t.Errorf("Expected ttl extended from the 304, got %v", ttl)
+	}
+	if resp, _ := get(t, proxy, http.MethodGet, "/a"); resp.Header.Get("X-Cache") != "HIT" {
+		t.Errorf("Expected HIT after revalidation, got %q", resp.Header.Get("X-Cache"))
+	}
+
+	t.Run("Changed", func(t *testing.T) {
+		get(t, proxy, http.MethodGet, "/b")
+		etag.Store(`"v2"`)
+		time.Sleep(1100 * time.Millisecond)
+		resp, body := get(t, proxy, http.MethodGet, "/b")
+		if body != `content "v2"` || resp.Header.Get("X-Cache") != "MISS" {
+			t.Errorf("Expected new content, got %q %q", body, resp.Header.Get("X-Cache"))
+		}
+	})
+}
@@ -0,0 +1,149 @@
+package origin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// RewriteRule 在计算缓存键和回源之前改写请求路径，可以改写到其他上游
+type RewriteRule struct {
+	// Path 前缀规则：以"*"结尾时匹配以"*"之前部分开头的路径，To中的"*"替换为路径的剩余部分；
+	// 不以"*"结尾时只匹配相同的路径
+	Path string `json:"path,omitempty"`
+
+	// Regex 正则规则，匹配请求路径（不含查询参数），整个路径替换为To，To中可以用$1、${name}引用分组
+	Regex string `json:"regex,omitempty"`
+
+	// To 改写后的路径，可以带有查询参数（加在请求的查询参数之前）；
+	// 是带有协议和主机的绝对URL时回源到该主机，例如 https://bucket.s3.amazonaws.com/assets/*
+	To string `json:"to"`
+
+	re     *regexp.Regexp
+	origin string // To是绝对URL时的协议和主机
+	to     string // 去掉协议和主机的To
+}
+
+// compile 检查规则并编译正则
+func (rule *RewriteRule) compile() error {
+	switch {
+	case (rule.Path == "") == (rule.Regex == ""):
+		return fmt.Errorf("rewrite rule must have exactly one of path and regex")
+	case rule.Path != "" && !strings.HasPrefix(rule.Path, "/"):
+		return fmt.Errorf("invalid rewrite path %q: must start with /", rule.Path)
+	case rule.Regex != "":
+		re, err := regexp.Compile(rule.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid rewrite regex %q: %w", rule.Regex, err)
+		}
+		rule.re = re
+	}
+
+	rule.to = rule.To
+	if !strings.HasPrefix(rule.To, "/") {
+		u, err := url.Parse(rule.To)
+		if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" || u.User != nil {
+			return fmt.Errorf("invalid rewrite target %q: must be a path or an absolute http or https url", rule.To)
+		}
+		rule.origin = u.Scheme + "://" + u.Host
+		rule.to = rule.To[strings.Index(rule.To, u.Host)+len(u.Host):]
+		if !strings.HasPrefix(rule.to, "/") {
+			rule.to = "/" + rule.to
+		}
+	}
+	return nil
+}
+
+// apply 返回改写后的路径和查询参数，不匹配时返回false
+func (rule *RewriteRule) apply(path string) (string, bool) {
+	if rule.re != nil {
+		m := rule.re.FindStringSubmatchIndex(path)
+		if m == nil {
+			return "", false
+		}
+		return string(rule.re.ExpandString(nil, rule.to, path, m)), true
+	}
+	rest, ok := matchPath(rule.Path, path)
+	if !ok {
+		return "", false
+	}
+	if strings.HasSuffix(rule.Path, "*") {
+		return strings.Replace(rule.to, "*", rest, 1), true
+	}
+	return rule.to, true
+}
+
+// rewrite 按第一个匹配的规则改写请求，返回改写后的请求副本；改写到其他上游时URL带有该上游的协议和主机。
+// 客户端以绝对URL发送的请求忽略其中的主机，只有改写规则可以选择上游
+func (p *Proxy) rewrite(r *http.Request) *http.Request {
+	u := *r.URL
+	u.Scheme, u.Host = "", ""
+	for i := range p.opts.Rewrites {
+		rule := &p.opts.Rewrites[i]
+		target, ok := rule.apply(r.URL.Path)
+		if !ok {
+			continue
+		}
+		path, query, _ := strings.Cut(target, "?")
+		u.Path, u.RawPath = path, ""
+		switch {
+		case query == "":
+		case u.RawQuery == "":
+			u.RawQuery = query
+		default:
+			u.RawQuery = query + "&" + u.RawQuery
+		}
+		if rule.origin != "" {
+			u.Scheme, u.Host, _ = strings.Cut(rule.origin, "://")
+		}
+		break
+	}
+	out := r.WithContext(r.Context())
+	out.URL = &u
+	return out
+}
+
+// rewriteOrigins 返回改写规则中的其他上游，不重复
+func rewriteOrigins(rules []RewriteRule) []string {
+	var origins []string
+	for _, rule := range rules {
+		if rule.origin != "" && !hasString(origins, rule.origin) {
+			origins = append(origins, rule.origin)
+		}
+	}
+	return origins
+}
+
+// rewriteFetcher 把改写到其他上游的请求交给该上游的Fetcher，其他请求交给next
+type rewriteFetcher struct {
+	next    Fetcher
+	origins map[string]Fetcher // 协议和主机到Fetcher
+}
+
+// Fetch 按请求URL中的协议和主机选择上游
+func (f *rewriteFetcher) Fetch(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if req.URL.Host == "" {
+		return f.next.Fetch(ctx, req)
+	}
+	fetcher, ok := f.origins[req.URL.Scheme+"://"+req.URL.Host]
+	if !ok {
+		return nil, fmt.Errorf("no upstream for %s://%s", req.URL.Scheme, req.URL.Host)
+	}
+	u := *req.URL
+	u.Scheme, u.Host = "", ""
+	out := req.WithContext(ctx)
+	out.URL = &u
+	return fetcher.Fetch(ctx, out)
+}
+
+// Close 关闭next
+func (f *rewriteFetcher) Close() error {
+	if closer, ok := f.next.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
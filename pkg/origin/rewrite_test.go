@@ -0,0 +1,94 @@
+package origin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestRewrite(t *testing.T) {
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "origin:"+r.URL.RequestURI())
+	})
+	bucket := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "bucket:"+r.URL.RequestURI())
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{
+		Upstream:   upstream.URL,
+		DefaultTTL: time.Hour,
+		Rewrites: []RewriteRule{
+			{Path: "/static/*", To: bucket.URL + "/assets/*"},
+			{Regex: `^/p/(?P<id>\d+)$`, To: "/product?id=${id}"},
+			{Path: "/old", To: "/new"},
+		},
+		Purge: &PurgeOptions{Tokens: []string{"secret"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	t.Run("rewrite targets", func(t *testing.T) {
+		tests := []struct {
+			target string
+			want   string
+		}{
+			{"/static/css/app.css", "bucket:/assets/css/app.css"},
+			{"/p/42?ref=home", "origin:/product?id=42&ref=home"},
+			{"/p/abc", "origin:/p/abc"},
+			{"/old", "origin:/new"},
+			{"/old/page", "origin:/old/page"},
+		}
+		for _, tt := range tests {
+			if _, body := get(t, proxy, http.MethodGet, tt.target); body != tt.want {
+				t.Errorf("Expected %s to fetch %q, got %q", tt.target, tt.want, body)
+			}
+		}
+	})
+
+	t.Run("cache key after rewrite", func(t *testing.T) {
+		resp, body := get(t, proxy, http.MethodGet, "/new")
+		if resp.Header.Get("X-Cache") != "HIT" || body != "origin:/new" {
+			t.Errorf("Expected /new to share the rewritten /old entry, got %q %q", resp.Header.Get("X-Cache"), body)
+		}
+		key := "//" + strings.TrimPrefix(bucket.URL, "http://") + "/assets/css/app.css"
+		if exists, _ := cache.Exists(context.Background(), key); !exists {
+			t.Errorf("Expected entry at %s", key)
+		}
+		if exists, _ := cache.Exists(context.Background(), "/assets/css/app.css"); exists {
+			t.Error("Expected other upstream entries not to share keys with the default upstream")
+		}
+	})
+
+	t.Run("purge rewritten url", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("PURGE", "/static/css/app.css", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		proxy.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"purged":1`) {
+			t.Errorf("Expected rewritten entry to be purged, got %d %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("invalid rules", func(t *testing.T) {
+		for _, rule := range []RewriteRule{
+			{To: "/x"},
+			{Path: "/a", Regex: "b", To: "/x"},
+			{Path: "a/*", To: "/x"},
+			{Regex: "(", To: "/x"},
+			{Path: "/a", To: "ftp://host/x"},
+			{Path: "/a", To: "relative"},
+		} {
+			if _, err := NewProxy(cache, Options{Upstream: upstream.URL, Rewrites: []RewriteRule{rule}}); err == nil {
+				t.Errorf("Expected error for rule %+v", rule)
+			}
+		}
+	})
+}
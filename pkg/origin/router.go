@@ -0,0 +1,198 @@
+package origin
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// Site 按Host路由的一个站点，有自己的上游、TTL、缓存键规则等选项和独立的缓存命名空间
+type Site struct {
+	// Hosts 站点的主机名，不区分大小写，忽略端口；"*.example.com"匹配example.com的所有子域名，
+	// "*"匹配其他站点都不匹配的请求
+	Hosts []string `json:"hosts"`
+
+	// Namespace 站点使用的缓存命名空间，默认为第一个主机名；多个站点可以共用一个命名空间
+	Namespace string `json:"namespace,omitempty"`
+
+	// Options 站点的回源代理选项
+	Options Options `json:"options"`
+}
+
+// Router 按请求的Host（没有时按TLS的SNI）把请求交给对应站点的Proxy，一个实例可以同时服务多个站点；
+// 没有匹配的站点时返回421。站点可以通过Update在运行中整体替换
+type Router struct {
+	cache  filecache.Cache
+	routes atomic.Pointer[routes]
+	mu     sync.Mutex // 串行化Update和Close
+}
+
+// routes 一组站点的路由表，创建后不再修改
+type routes struct {
+	exact    map[string]*Proxy
+	wildcard []wildcardSite // 按后缀从长到短排序
+	fallback *Proxy
+	proxies  []*Proxy
+	sites    []string // 与proxies对应的站点名称（第一个主机名）
+}
+
+// wildcardSite "*.example.com"形式的主机名
+type wildcardSite struct {
+	suffix string // ".example.com"
+	proxy  *Proxy
+}
+
+// NewRouter 为每个站点在cache的命名空间上创建Proxy
+func NewRouter(cache filecache.Cache, sites []Site) (*Router, error) {
+	table, err := newRoutes(cache, sites)
+	if err != nil {
+		return nil, err
+	}
+	rt := &Router{cache: cache}
+	rt.routes.Store(table)
+	return rt, nil
+}
+
+// newRoutes 为每个站点创建Proxy并建立路由表，失败时关闭已创建的Proxy
+func newRoutes(cache filecache.Cache, sites []Site) (*routes, error) {
+	if err := ValidateSites(sites); err != nil {
+		return nil, err
+	}
+	rt := &routes{exact: make(map[string]*Proxy)}
+	for i, site := range sites {
+		if len(site.Hosts) == 0 {
+			rt.close()
+			return nil, fmt.Errorf("site %d has no hosts", i)
+		}
+		namespace := site.Namespace
+		if namespace == "" {
+			namespace = strings.ToLower(site.Hosts[0])
+		}
+		proxy, err := NewProxy(cache.Namespace(namespace), site.Options)
+		if err != nil {
+			rt.close()
+			return nil, fmt.Errorf("site %s: %w", site.Hosts[0], err)
+		}
+		rt.proxies = append(rt.proxies, proxy)
+		rt.sites = append(rt.sites, site.Hosts[0])
+		for _, host := range site.Hosts {
+			if err := rt.add(strings.ToLower(host), proxy); err != nil {
+				rt.close()
+				return nil, err
+			}
+		}
+	}
+	return rt, nil
+}
+
+// Update 用sites替换所有站点：新的路由表建立成功后才替换，之后的请求按新的站点路由，旧站点的Proxy随后关闭，
+// 已经在处理的请求继续完成；失败时保留原来的站点。缓存命名空间不变，已缓存的文件继续有效
+func (rt *Router) Update(sites []Site) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	table, err := newRoutes(rt.cache, sites)
+	if err != nil {
+		return err
+	}
+	old := rt.routes.Swap(table)
+	old.close()
+	return nil
+}
+
+// add 注册主机名，同一个主机名只能属于一个站点
+func (rt *routes) add(host string, proxy *Proxy) error {
+	switch {
+	case host == "*":
+		if rt.fallback != nil {
+			return fmt.Errorf("duplicate fallback site")
+		}
+		rt.fallback = proxy
+	case strings.HasPrefix(host, "*."):
+		suffix := host[1:]
+		i := 0
+		for ; i < len(rt.wildcard); i++ {
+			if rt.wildcard[i].suffix == suffix {
+				return fmt.Errorf("duplicate host %q", host)
+			}
+			if len(rt.wildcard[i].suffix) < len(suffix) {
+				break
+			}
+		}
+		rt.wildcard = append(rt.wildcard, wildcardSite{})
+		copy(rt.wildcard[i+1:], rt.wildcard[i:])
+		rt.wildcard[i] = wildcardSite{suffix: suffix, proxy: proxy}
+	case host == "" || strings.Contains(host, "*"):
+		return fmt.Errorf("invalid host %q", host)
+	default:
+		if _, ok := rt.exact[host]; ok {
+			return fmt.Errorf("duplicate host %q", host)
+		}
+		rt.exact[host] = proxy
+	}
+	return nil
+}
+
+// route 返回主机名对应的Proxy，没有匹配的站点时返回nil
+func (rt *routes) route(host string) *Proxy {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if proxy, ok := rt.exact[host]; ok {
+		return proxy
+	}
+	for _, w := range rt.wildcard {
+		if strings.HasSuffix(host, w.suffix) {
+			return w.proxy
+		}
+	}
+	return rt.fallback
+}
+
+// ServeHTTP 把请求交给Host对应站点的Proxy
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if host == "" && r.TLS != nil {
+		host = r.TLS.ServerName
+	}
+	proxy := rt.routes.Load().route(host)
+	if proxy == nil {
+		http.Error(w, "unknown host", http.StatusMisdirectedRequest)
+		return
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// PurgeTag 删除所有站点中带有tag的条目，返回删除的条目数
+func (rt *Router) PurgeTag(ctx context.Context, tag string) (int, error) {
+	total := 0
+	for _, proxy := range rt.routes.Load().proxies {
+		n, err := proxy.PurgeTag(ctx, tag)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// Close 关闭所有站点的Proxy
+func (rt *Router) Close() error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.routes.Load().close()
+}
+
+// close 关闭路由表中的Proxy
+func (rt *routes) close() error {
+	for _, proxy := range rt.proxies {
+		proxy.Close()
+	}
+	return nil
+}
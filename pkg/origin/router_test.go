@@ -0,0 +1,158 @@
+package origin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestRouter(t *testing.T) {
+	siteA := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "a:"+r.URL.RequestURI())
+	})
+	siteB := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "b:"+r.URL.RequestURI())
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	router, err := NewRouter(cache, []Site{
+		{Hosts: []string{"a.example.com", "www.a.example.com"}, Options: Options{
+			Upstream: siteA.URL, DefaultTTL: time.Hour, Key: &KeyRules{QueryDeny: []string{"cb"}},
+		}},
+		{Hosts: []string{"*.b.example.com"}, Namespace: "b", Options: Options{Upstream: siteB.URL, DefaultTTL: time.Hour}},
+		{Hosts: []string{"*"}, Namespace: "default", Options: Options{Upstream: siteB.URL}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+	defer router.Close()
+
+	request := func(host, target string) (*http.Response, string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Host = host
+		router.ServeHTTP(rec, req)
+		resp := rec.Result()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	t.Run("route by host", func(t *testing.T) {
+		tests := []struct {
+			host string
+			want string
+		}{
+			{"a.example.com", "a:/x?cb=1"},
+			{"WWW.A.EXAMPLE.COM:8443", "a:/x?cb=1"},
+			{"img.b.example.com", "b:/x?cb=1"},
+			{"other.example.net", "b:/x?cb=1"},
+		}
+		for _, tt := range tests {
+			if _, body := request(tt.host, "/x?cb=1"); body != tt.want {
+				t.Errorf("Expected %s to route to %q, got %q", tt.host, tt.want, body)
+			}
+		}
+	})
+
+	t.Run("per site key rules and namespace", func(t *testing.T) {
+		resp, body := request("a.example.com", "/x?cb=2")
+		if resp.Header.Get("X-Cache") != "HIT" || body != "a:/x?cb=1" {
+			t.Errorf("Expected site key rules to ignore cb, got %q %q", resp.Header.Get("X-Cache"), body)
+		}
+		if exists, _ := cache.Namespace("a.example.com").Exists(context.Background(), "/x"); !exists {
+			t.Error("Expected site a entry in its namespace")
+		}
+		if exists, _ := cache.Namespace("b").Exists(context.Background(), "/x?cb=1"); !exists {
+			t.Error("Expected site b entry in its namespace")
+		}
+		if exists, _ := cache.Exists(context.Background(), "/x"); exists {
+			t.Error("Expected no entries in the root namespace")
+		}
+	})
+
+	t.Run("upstream health", func(t *testing.T) {
+		rt, err := NewRouter(cache, []Site{
+			{Hosts: []string{"a.example.com"}, Options: Options{Upstream: siteA.URL}},
+			{Hosts: []string{"b.example.com"}, Options: Options{Upstreams: []UpstreamTarget{{URL: siteB.URL}, {URL: siteA.URL, Priority: 1}}}},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create router: %v", err)
+		}
+		defer rt.Close()
+		health := rt.UpstreamHealth()
+		if len(health) != 2 || health[0].Site != "b.example.com" || health[0].Name != siteB.URL || health[1].Priority != 1 || !health[1].Healthy {
+			t.Errorf("Expected health of the upstream pool, got %+v", health)
+		}
+	})
+
+	t.Run("unknown host", func(t *testing.T) {
+		router, err := NewRouter(cache, []Site{{Hosts: []string{"a.example.com"}, Options: Options{Upstream: siteA.URL}}})
+		if err != nil {
+			t.Fatalf("Failed to create router: %v", err)
+		}
+		defer router.Close()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Host = "b.example.com"
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusMisdirectedRequest {
+			t.Errorf("Expected 421, got %d", rec.Code)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		router, err := NewRouter(cache, []Site{{Hosts: []string{"a.example.com"}, Options: Options{Upstream: siteA.URL, DefaultTTL: time.Hour}}})
+		if err != nil {
+			t.Fatalf("Failed to create router: %v", err)
+		}
+		defer router.Close()
+		serve := func(host string) (string, string) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/update", nil)
+			req.Host = host
+			router.ServeHTTP(rec, req)
+			return rec.Header().Get("X-Cache"), rec.Body.String()
+		}
+		serve("a.example.com")
+
+		err = router.Update([]Site{
+			{Hosts: []string{"a.example.com"}, Options: Options{Upstream: siteB.URL, DefaultTTL: time.Hour}},
+			{Hosts: []string{"c.example.com"}, Options: Options{Upstream: siteB.URL}},
+		})
+		if err != nil {
+			t.Fatalf("Failed to update sites: %v", err)
+		}
+		if status, body := serve("a.example.com"); status != "HIT" || body != "a:/update" {
+			t.Errorf("Expected cached entry to survive the update, got %s %q", status, body)
+		}
+		if _, body := serve("c.example.com"); body != "b:/update" {
+			t.Errorf("Expected new site to be routed, got %q", body)
+		}
+
+		if err := router.Update([]Site{{Options: Options{Upstream: siteA.URL}}}); err == nil {
+			t.Error("Expected invalid sites to be rejected")
+		}
+		if _, body := serve("c.example.com"); body != "b:/update" {
+			t.Errorf("Expected failed update to keep the previous sites, got %q", body)
+		}
+	})
+
+	t.Run("invalid sites", func(t *testing.T) {
+		for _, sites := range [][]Site{
+			{{Options: Options{Upstream: siteA.URL}}},
+			{{Hosts: []string{"a.example.com"}, Options: Options{Upstream: siteA.URL}}, {Hosts: []string{"A.example.com"}, Options: Options{Upstream: siteB.URL}}},
+			{{Hosts: []string{"a*.example.com"}, Options: Options{Upstream: siteA.URL}}},
+			{{Hosts: []string{"a.example.com"}, Options: Options{}}},
+		} {
+			if _, err := NewRouter(cache, sites); err == nil {
+				t.Errorf("Expected error for sites %+v", sites)
+			}
+		}
+	})
+}
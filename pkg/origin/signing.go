@@ -0,0 +1,149 @@
+package origin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 签名URL查询参数的默认名称
+const (
+	defaultExpiresParam   = "expires"
+	defaultSignatureParam = "signature"
+)
+
+// SignedURLs 签名URL校验选项。签名URL带有过期时间（Unix秒）和签名两个查询参数，
+// 签名为base64url(HMAC-SHA256(key, 路径 + "\n" + 规范化的查询参数 + "\n" + 过期时间))，
+// 规范化的查询参数是去掉这两个参数后按名称排序并重新编码的其他参数，改动任何参数都会使签名失效。
+// 校验通过后去掉这两个参数再查缓存和回源，同一个URL的不同签名共享缓存条目
+type SignedURLs struct {
+	// Keys HMAC密钥，第一个用于Sign，校验时依次尝试，轮换密钥期间同时配置新旧密钥
+	Keys []string `json:"keys"`
+
+	// ExpiresParam 过期时间参数名，默认"expires"
+	ExpiresParam string `json:"expires_param,omitempty"`
+
+	// SignatureParam 签名参数名，默认"signature"
+	SignatureParam string `json:"signature_param,omitempty"`
+}
+
+var (
+	errMissingSignature = errors.New("missing signature")
+	errSignatureExpired = errors.New("signed url expired")
+	errInvalidSignature = errors.New("invalid signature")
+)
+
+// validate 检查选项
+func (s *SignedURLs) validate() error {
+	if len(s.Keys) == 0 {
+		return fmt.Errorf("signed urls require at least one key")
+	}
+	for _, key := range s.Keys {
+		if key == "" {
+			return fmt.Errorf("signing key cannot be empty")
+		}
+	}
+	if s.expiresParam() == s.signatureParam() {
+		return fmt.Errorf("expires and signature params must differ")
+	}
+	return nil
+}
+
+func (s *SignedURLs) expiresParam() string {
+	if s.ExpiresParam != "" {
+		return s.ExpiresParam
+	}
+	return defaultExpiresParam
+}
+
+func (s *SignedURLs) signatureParam() string {
+	if s.SignatureParam != "" {
+		return s.SignatureParam
+	}
+	return defaultSignatureParam
+}
+
+// Sign 用第一个密钥为u签名，返回带有过期时间和签名参数的URL，u的其他查询参数一起签名
+func (s *SignedURLs) Sign(u *url.URL, expires time.Time) *url.URL {
+	signed := *u
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	query := removeParams(u.RawQuery, s.expiresParam(), s.signatureParam())
+	if query != "" {
+		query += "&"
+	}
+	signed.RawQuery = query + url.QueryEscape(s.expiresParam()) + "=" + exp +
+		"&" + url.QueryEscape(s.signatureParam()) + "=" + signature(s.Keys[0], u.EscapedPath(), s.canonicalQuery(u), exp)
+	return &signed
+}
+
+// verify 校验请求URL的过期时间和签名，签名覆盖路径和其他查询参数
+func (s *SignedURLs) verify(u *url.URL, now time.Time) error {
+	query := u.Query()
+	exp, sig := query.Get(s.expiresParam()), query.Get(s.signatureParam())
+	if exp == "" || sig == "" {
+		return errMissingSignature
+	}
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return errInvalidSignature
+	}
+	canonical := s.canonicalQuery(u)
+	for _, key := range s.Keys {
+		if hmac.Equal([]byte(sig), []byte(signature(key, u.EscapedPath(), canonical, exp))) {
+			if now.Unix() > expires {
+				return errSignatureExpired
+			}
+			return nil
+		}
+	}
+	return errInvalidSignature
+}
+
+// strip 返回去掉签名参数的请求
+func (s *SignedURLs) strip(r *http.Request) *http.Request {
+	out := r.Clone(r.Context())
+	out.URL.RawQuery = removeParams(r.URL.RawQuery, s.expiresParam(), s.signatureParam())
+	out.RequestURI = out.URL.RequestURI()
+	return out
+}
+
+// canonicalQuery 返回参与签名的查询参数：去掉过期时间和签名参数，按名称排序后重新编码，
+// 参数的顺序和编码方式不影响签名
+func (s *SignedURLs) canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	query.Del(s.expiresParam())
+	query.Del(s.signatureParam())
+	return query.Encode()
+}
+
+// signature 返回路径、规范化的查询参数和过期时间的签名
+func signature(key, path, query, expires string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(path + "\n" + query + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// removeParams 从原始查询字符串中去掉指定名称的参数，保留其他参数原有的编码和顺序
+func removeParams(raw string, names ...string) string {
+	if raw == "" {
+		return ""
+	}
+	var params []string
+	for _, param := range strings.Split(raw, "&") {
+		name, _, _ := strings.Cut(param, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if param != "" && !matchParam(names, name) {
+			params = append(params, param)
+		}
+	}
+	return strings.Join(params, "&")
+}
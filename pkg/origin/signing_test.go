@@ -0,0 +1,100 @@
+package origin
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestSignedURLs(t *testing.T) {
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	signing := &SignedURLs{Keys: []string{"new-key", "old-key"}}
+	proxy, err := NewProxy(cache, Options{Upstream: upstream.URL, DefaultTTL: time.Hour, Signing: signing})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	sign := func(s *SignedURLs, target string, expires time.Time) string {
+		return s.Sign(mustParse(t, target), expires).String()
+	}
+	future := time.Now().Add(time.Hour)
+
+	t.Run("Valid", func(t *testing.T) {
+		resp, body := get(t, proxy, http.MethodGet, sign(signing, "/video.mp4?quality=hd", future))
+		if resp.StatusCode != http.StatusOK || body != "secret" {
+			t.Fatalf("Expected 200 with body, got %d %q", resp.StatusCode, body)
+		}
+		last := upstream.last.Load().(*http.Request)
+		if got := last.URL.RawQuery; got != "quality=hd" {
+			t.Errorf("Expected signature params to be stripped upstream, got %q", got)
+		}
+		// 不同的签名URL共享同一个缓存条目
+		resp, _ = get(t, proxy, http.MethodGet, sign(signing, "/video.mp4?quality=hd", future.Add(time.Minute)))
+		if resp.Header.Get("X-Cache") != "HIT" {
+			t.Errorf("Expected HIT for another signed url, got %q", resp.Header.Get("X-Cache"))
+		}
+	})
+
+	t.Run("RotatedKey", func(t *testing.T) {
+		old := &SignedURLs{Keys: []string{"old-key"}}
+		if resp, _ := get(t, proxy, http.MethodGet, sign(old, "/video.mp4", future)); resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected old key to be accepted, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("ReorderedQuery", func(t *testing.T) {
+		signed := mustParse(t, sign(signing, "/video.mp4?b=2&a=1", future)).Query()
+		target := "/video.mp4?a=1&b=2&expires=" + signed.Get("expires") + "&signature=" + signed.Get("signature")
+		if resp, _ := get(t, proxy, http.MethodGet, target); resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected query order not to affect the signature, got %d", resp.StatusCode)
+		}
+	})
+
+	hd := sign(signing, "/video.mp4?quality=hd", future)
+	tests := []struct {
+		name, target string
+	}{
+		{"Unsigned", "/video.mp4"},
+		{"Expired", sign(signing, "/video.mp4", time.Now().Add(-time.Minute))},
+		{"WrongKey", sign(&SignedURLs{Keys: []string{"other"}}, "/video.mp4", future)},
+		{"OtherPath", "/other.mp4?" + mustParse(t, sign(signing, "/video.mp4", future)).RawQuery},
+		{"TamperedQuery", strings.Replace(hd, "quality=hd", "quality=4k", 1)},
+		{"AddedParam", hd + "&admin=1"},
+		{"RemovedParam", strings.Replace(hd, "quality=hd&", "", 1)},
+		{"TamperedExpiry", "/video.mp4?expires=9999999999&signature=" + mustParse(t, sign(signing, "/video.mp4", future)).Query().Get("signature")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := upstream.requests.Load()
+			if resp, _ := get(t, proxy, http.MethodGet, tt.target); resp.StatusCode != http.StatusForbidden {
+				t.Errorf("Expected 403, got %d", resp.StatusCode)
+			}
+			if upstream.requests.Load() != before {
+				t.Error("Expected rejected request not to reach upstream")
+			}
+		})
+	}
+
+	for _, s := range []*SignedURLs{{}, {Keys: []string{""}}, {Keys: []string{"k"}, ExpiresParam: "sig", SignatureParam: "sig"}} {
+		if _, err := NewProxy(cache, Options{Upstream: upstream.URL, Signing: s}); err == nil {
+			t.Errorf("Expected error for signing options %+v", s)
+		}
+	}
+}
+
+func mustParse(t *testing.T, target string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(target)
+	if err != nil {
+		t.Fatalf("Failed to parse url: %v", err)
+	}
+	return u
+}
@@ -0,0 +1,264 @@
+package origin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sliceSeparator 分片键 = 基础键 + sliceSeparator + 分片序号
+const sliceSeparator = "#slice:"
+
+// errObjectChanged 分片之间对象的长度或校验器不一致，上游的对象在读取过程中被替换
+var errObjectChanged = errors.New("upstream object changed between slices")
+
+// sliceKey 返回第index个分片的缓存键
+func sliceKey(key string, index int64) string {
+	return key + sliceSeparator + strconv.FormatInt(index, 10)
+}
+
+// slice 打开的分片，body位于offset处
+type slice struct {
+	entry  *entry
+	rng    byteRange // 分片在对象中的范围，entry不是206时为空
+	total  int64     // 对象的完整长度
+	body   io.Reader
+	size   int64 // 响应体长度
+	offset int64
+	status string // X-Cache头的值
+	close  func()
+}
+
+// serveSliced 按Options.SliceSize把对象分成固定大小的分片，每个分片用上游的Range请求单独回源和缓存，
+// 返回时按需读取覆盖请求范围的分片并拼接。上游不支持Range或返回错误时按普通回源结果返回
+func (p *Proxy) serveSliced(w http.ResponseWriter, r *http.Request, key string) {
+	if flusher, ok := w.(http.Flusher); ok {
+		// 回源的分片边下载边返回
+		w = flushWriter{w, flusher}
+	}
+	first, err := p.openSlice(r, key, rangeStart(r)/p.opts.SliceSize, false)
+	if err != nil {
+		p.error(w, r, err)
+		return
+	}
+	if first.entry.Status != http.StatusPartialContent {
+		defer first.close()
+		p.writeEntry(w, r, first.entry, first.body, first.size, first.status)
+		return
+	}
+
+	// 以完整对象的200响应返回，由writeEntry处理条件请求和Range
+	header := first.entry.Header.Clone()
+	header.Del("Content-Range")
+	e := &entry{Status: http.StatusOK, Header: header, StoredAt: first.entry.StoredAt, InitialAge: first.entry.InitialAge}
+	body := &sliceReader{p: p, r: r, key: key, size: first.total, etag: header.Get("ETag"), cur: first}
+	defer body.Close()
+	p.writeEntry(w, r, e, body, first.total, first.status)
+}
+
+// rangeStart 返回Range请求明确给出的起点，用于选择第一个分片；没有起点时返回0
+func rangeStart(r *http.Request) int64 {
+	spec, ok := strings.CutPrefix(r.Header.Get("Range"), "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0
+	}
+	first, _, _ := strings.Cut(spec, "-")
+	start, err := strconv.ParseInt(strings.TrimSpace(first), 10, 64)
+	if err != nil || start < 0 {
+		return 0
+	}
+	return start
+}
+
+// openSlice 打开第index个分片：从缓存读取，未命中或fresh为true时回源，同一个分片的并发回源只进行一次
+func (p *Proxy) openSlice(r *http.Request, key string, index int64, fresh bool) (*slice, error) {
+	if !fresh {
+		if s := p.cachedSlice(r.Context(), sliceKey(key, index)); s != nil {
+			return s, nil
+		}
+	}
+
+	res, joined, release, err := p.coalesce(r.Context(), sliceKey(key, index), func() (*fetchResult, error) {
+		return p.fetchSlice(r, key, index)
+	})
+	if joined && r.Context().Err() == nil && (err != nil || !res.shared) {
+		release()
+		release = func() {}
+		if res, err = p.fetchSlice(r, key, index); err == nil {
+			release = res.body.release
+		}
+	}
+	if err != nil {
+		release()
+		return nil, err
+	}
+	// 关闭分片时等待它写入缓存，之后的请求可以命中
+	s := &slice{entry: res.entry, body: res.body.reader(), status: res.status, close: func() {
+		if res.shared {
+			res.body.wait()
+		}
+		release()
+	}}
+	if res.entry.Status != http.StatusPartialContent {
+		if s.size, err = res.length(); err != nil {
+			s.close()
+			return nil, err
+		}
+		return s, nil
+	}
+	rng, total, ok := parseContentRange(res.entry.Header.Get("Content-Range"))
+	if !ok {
+		s.close()
+		return nil, fmt.Errorf("invalid content range %q", res.entry.Header.Get("Content-Range"))
+	}
+	s.rng, s.total, s.size, s.offset = rng, total, rng.length(), rng.Start
+	return s, nil
+}
+
+// cachedSlice 从缓存读取分片，不存在或不是有效的分片时返回nil
+func (p *Proxy) cachedSlice(ctx context.Context, key string) *slice {
+	reader, _, err := p.cache.Get(ctx, key)
+	if err != nil {
+		return nil
+	}
+	e, body, _, err := readEntry(reader)
+	if err == nil && e != nil && e.Status == http.StatusPartialContent {
+		if rng, total, ok := parseContentRange(e.Header.Get("Content-Range")); ok {
+			return &slice{entry: e, rng: rng, total: total, body: body, size: rng.length(), offset: rng.Start, status: "HIT", close: func() {
+				reader.Close()
+			}}
+		}
+	}
+	reader.Close()
+	return nil
+}
+
+// fetchSlice 用Range请求回源第index个分片并写入分片键；上游忽略Range返回的完整响应和按NegativeTTL、StatusTTL缓存的其他响应
+// 按普通响应写入基础键，之后由serveCached返回
+func (p *Proxy) fetchSlice(r *http.Request, key string, index int64) (*fetchResult, error) {
+	ctx := context.Background()
+	out := p.upstreamRequest(r.WithContext(ctx))
+	out.Method = http.MethodGet
+	stripConditional(out.Header)
+	start := index * p.opts.SliceSize
+	out.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, start+p.opts.SliceSize-1))
+	fetchStart := time.Now()
+	resp, err := p.fetcher.Fetch(ctx, out)
+	addUpstreamTime(r.Context(), fetchStart)
+	if err != nil {
+		return nil, err
+	}
+
+	responseTime := time.Now()
+	e := newEntry(resp, responseTime)
+	ttl, cacheable := p.cacheTTL(e, r.URL.Path, r.Header, responseTime)
+	var store func(io.Reader) error
+	switch {
+	case !cacheable:
+	case e.Status == http.StatusPartialContent:
+		store = func(body io.Reader) error { return p.store(ctx, sliceKey(key, index), e, body, ttl) }
+	default:
+		store = func(body io.Reader) error { return p.storeResponse(ctx, key, r.Header, e, body, ttl) }
+	}
+	body, err := stream(resp.Body, store, func() { resp.Body.Close() })
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return &fetchResult{entry: e, body: body, size: resp.ContentLength, status: "MISS", variant: sliceKey(key, index), shared: cacheable}, nil
+}
+
+// sliceReader 按读取位置依次打开分片，把分片拼接为完整的对象；支持Seek，Range请求只打开覆盖的分片
+type sliceReader struct {
+	p    *Proxy
+	r    *http.Request
+	key  string
+	size int64  // 对象的完整长度
+	etag string // 第一个分片的ETag，后续分片不一致时说明对象已改变
+
+	pos int64
+	cur *slice
+}
+
+func (s *sliceReader) Read(b []byte) (int, error) {
+	if s.pos >= s.size {
+		return 0, io.EOF
+	}
+	if s.cur == nil || s.pos < s.cur.offset || s.pos > s.cur.rng.End {
+		if err := s.open(); err != nil {
+			return 0, err
+		}
+	}
+	if s.pos > s.cur.offset {
+		if err := skip(s.cur.body, s.pos-s.cur.offset); err != nil {
+			return 0, err
+		}
+		s.cur.offset = s.pos
+	}
+	if remaining := s.cur.rng.End - s.pos + 1; int64(len(b)) > remaining {
+		b = b[:remaining]
+	}
+	n, err := s.cur.body.Read(b)
+	s.pos += int64(n)
+	s.cur.offset += int64(n)
+	if err == io.EOF {
+		if s.pos <= s.cur.rng.End {
+			return n, io.ErrUnexpectedEOF
+		}
+		err = nil
+	}
+	return n, err
+}
+
+// open 打开覆盖当前位置的分片；缓存的分片与第一个分片不一致时重新回源，仍不一致时返回errObjectChanged
+func (s *sliceReader) open() error {
+	s.Close()
+	for _, fresh := range []bool{false, true} {
+		next, err := s.p.openSlice(s.r, s.key, s.pos/s.p.opts.SliceSize, fresh)
+		if err != nil {
+			return err
+		}
+		if next.entry.Status == http.StatusPartialContent && next.total == s.size && next.entry.Header.Get("ETag") == s.etag &&
+			next.rng.Start <= s.pos && s.pos <= next.rng.End {
+			s.cur = next
+			return nil
+		}
+		next.close()
+		if next.status != "HIT" {
+			break
+		}
+	}
+	return errObjectChanged
+}
+
+// Seek 只记录位置，下次读取时生效
+func (s *sliceReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.pos
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative position %d", offset)
+	}
+	s.pos = offset
+	return offset, nil
+}
+
+// Close 关闭当前分片
+func (s *sliceReader) Close() error {
+	if s.cur != nil {
+		s.cur.close()
+		s.cur = nil
+	}
+	return nil
+}
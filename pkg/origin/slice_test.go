@@ -0,0 +1,124 @@
+package origin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestSlices(t *testing.T) {
+	content := strings.Repeat("abcdefghij", 1000)
+	var version atomic.Int64
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", fmt.Sprintf(`"v%d"`, version.Load()))
+		w.Header().Set("Cache-Control", "max-age=600")
+		if r.URL.Path == "/norange.bin" {
+			w.Write([]byte(content))
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(content))
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{Upstream: upstream.URL, SliceSize: 1000})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	rangeGet := func(target, rng string) (*http.Response, string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Range", rng)
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		resp := rec.Result()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+	exists := func(key string) bool {
+		ok, _ := cache.Exists(context.Background(), key)
+		return ok
+	}
+
+	t.Run("Full", func(t *testing.T) {
+		before := upstream.requests.Load()
+		resp, body := get(t, proxy, http.MethodGet, "/full.bin")
+		if resp.StatusCode != http.StatusOK || body != content || resp.Header.Get("X-Cache") != "MISS" {
+			t.Fatalf("Expected 200 MISS with full body, got %d %q len %d", resp.StatusCode, resp.Header.Get("X-Cache"), len(body))
+		}
+		if n := upstream.requests.Load() - before; n != 10 {
+			t.Errorf("Expected 10 slice requests, got %d", n)
+		}
+		if exists("/full.bin") || !exists(sliceKey("/full.bin", 9)) {
+			t.Error("Expected the object to be cached as slices")
+		}
+
+		resp, body = get(t, proxy, http.MethodGet, "/full.bin")
+		if body != content || resp.Header.Get("X-Cache") != "HIT" || resp.Header.Get("Content-Length") != "10000" {
+			t.Errorf("Expected reassembled HIT, got %q %q len %d", resp.Header.Get("X-Cache"), resp.Header.Get("Content-Length"), len(body))
+		}
+		if n := upstream.requests.Load() - before; n != 10 {
+			t.Errorf("Expected no more upstream requests, got %d", n-10)
+		}
+	})
+
+	t.Run("Range", func(t *testing.T) {
+		before := upstream.requests.Load()
+		resp, body := rangeGet("/seek.bin", "bytes=5500-5599")
+		if resp.StatusCode != http.StatusPartialContent || body != content[5500:5600] {
+			t.Fatalf("Expected 206 with range body, got %d %q", resp.StatusCode, body)
+		}
+		if got := resp.Header.Get("Content-Range"); got != "bytes 5500-5599/10000" {
+			t.Errorf("Expected content range, got %q", got)
+		}
+		if n := upstream.requests.Load() - before; n != 1 || !exists(sliceKey("/seek.bin", 5)) || exists(sliceKey("/seek.bin", 0)) {
+			t.Errorf("Expected only slice 5 to be fetched, got %d requests", n)
+		}
+
+		resp, body = rangeGet("/seek.bin", "bytes=5900-6099")
+		if resp.StatusCode != http.StatusPartialContent || body != content[5900:6100] {
+			t.Errorf("Expected range across slices, got %d %q", resp.StatusCode, body)
+		}
+		if n := upstream.requests.Load() - before; n != 2 {
+			t.Errorf("Expected slice 6 to be fetched, got %d requests", n)
+		}
+
+		resp, body = rangeGet("/seek.bin", "bytes=-50")
+		if resp.StatusCode != http.StatusPartialContent || body != content[len(content)-50:] {
+			t.Errorf("Expected suffix range, got %d %q", resp.StatusCode, body)
+		}
+	})
+
+	t.Run("UpstreamIgnoresRange", func(t *testing.T) {
+		resp, body := get(t, proxy, http.MethodGet, "/norange.bin")
+		if resp.StatusCode != http.StatusOK || body != content {
+			t.Fatalf("Expected full response, got %d len %d", resp.StatusCode, len(body))
+		}
+		resp, body = rangeGet("/norange.bin", "bytes=0-9")
+		if resp.StatusCode != http.StatusPartialContent || body != content[:10] || resp.Header.Get("X-Cache") != "HIT" {
+			t.Errorf("Expected range from the full entry, got %d %q %q", resp.StatusCode, resp.Header.Get("X-Cache"), body)
+		}
+	})
+
+	t.Run("ObjectChanged", func(t *testing.T) {
+		rangeGet("/changed.bin", "bytes=0-99")
+		version.Add(1)
+		defer version.Add(-1)
+		// 第一个分片来自旧版本，之后的分片与它不一致，响应在第一个分片之后中断
+		if _, body := get(t, proxy, http.MethodGet, "/changed.bin"); len(body) != 1000 {
+			t.Errorf("Expected response to stop after the first slice, got %d bytes", len(body))
+		}
+	})
+
+	if _, err := NewProxy(cache, Options{Upstream: upstream.URL, SliceSize: -1}); err == nil {
+		t.Error("Expected error for negative slice size")
+	}
+}
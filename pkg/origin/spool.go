@@ -0,0 +1,192 @@
+package origin
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// spool 回源时边写边读的响应体临时文件：后台goroutine把上游响应体写入，
+// 多个reader同时读取已经写入的部分，读到尚未写入的位置时等待，不需要等整个响应体下载完
+type spool struct {
+	file *os.File
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	size int64 // 已写入的长度
+	done bool
+	err  error // 上游响应体读取失败时的错误
+	refs int
+}
+
+// newSpool 创建临时文件，引用数为1，由写入方release
+func newSpool() (*spool, error) {
+	file, err := os.CreateTemp("", "edgeorigin-fetch-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	s := &spool{file: file, refs: 1}
+	s.cond = sync.NewCond(&s.mu)
+	return s, nil
+}
+
+// Write 追加响应体并唤醒等待的reader，只能由一个goroutine调用
+func (s *spool) Write(b []byte) (int, error) {
+	n, err := s.file.Write(b)
+	s.mu.Lock()
+	s.size += int64(n)
+	s.mu.Unlock()
+	s.cond.Broadcast()
+	return n, err
+}
+
+// finish 写入结束；err不为nil时上游中断，reader读完已写入的部分后收到err
+func (s *spool) finish(err error) {
+	s.mu.Lock()
+	s.done, s.err = true, err
+	s.mu.Unlock()
+	s.cond.Broadcast()
+}
+
+// wait 等待写入结束，返回响应体长度
+func (s *spool) wait() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for !s.done {
+		s.cond.Wait()
+	}
+	if s.err != nil {
+		return 0, fmt.Errorf("failed to read upstream response: %w", s.err)
+	}
+	return s.size, nil
+}
+
+// acquire 增加引用
+func (s *spool) acquire() {
+	s.mu.Lock()
+	s.refs++
+	s.mu.Unlock()
+}
+
+// release 减少引用，最后一个引用删除临时文件
+func (s *spool) release() {
+	s.mu.Lock()
+	s.refs--
+	last := s.refs == 0
+	s.mu.Unlock()
+	if last {
+		s.file.Close()
+		os.Remove(s.file.Name())
+	}
+}
+
+// reader 返回从头读取的reader，读取期间调用方必须持有引用
+func (s *spool) reader() *spoolReader {
+	return &spoolReader{s: s}
+}
+
+// spoolReader 读取spool，支持Seek
+type spoolReader struct {
+	s   *spool
+	pos int64
+}
+
+func (r *spoolReader) Read(b []byte) (int, error) {
+	s := r.s
+	s.mu.Lock()
+	for r.pos >= s.size && !s.done {
+		s.cond.Wait()
+	}
+	size, err := s.size, s.err
+	s.mu.Unlock()
+	if r.pos >= size {
+		if err != nil {
+			return 0, fmt.Errorf("failed to read upstream response: %w", err)
+		}
+		return 0, io.EOF
+	}
+
+	if remaining := size - r.pos; int64(len(b)) > remaining {
+		b = b[:remaining]
+	}
+	n, err := s.file.ReadAt(b, r.pos)
+	r.pos += int64(n)
+	if err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+// Seek 只记录位置，读取时等待数据写到该位置
+func (r *spoolReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		size, err := r.s.wait()
+		if err != nil {
+			return 0, err
+		}
+		offset += size
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative position %d", offset)
+	}
+	r.pos = offset
+	return offset, nil
+}
+
+// stream 在后台把src写入新的spool，store不为nil时同时把src写入缓存，结束后调用done。
+// 写入缓存失败时剩余的响应体继续写入spool，客户端不受影响；上游中断时缓存不会写入不完整的响应体。
+// 返回的spool已经为调用方增加了引用
+func stream(src io.Reader, store func(io.Reader) error, done func()) (*spool, error) {
+	s, err := newSpool()
+	if err != nil {
+		return nil, err
+	}
+	s.acquire()
+	go func() {
+		defer done()
+		defer s.release()
+		// 上游的读取错误只出现一次，记录下来以免写入缓存失败后继续读取时丢失
+		src := &stickyReader{r: src}
+		if store != nil {
+			store(io.TeeReader(src, s))
+		}
+		_, err := io.Copy(s, src)
+		s.finish(err)
+	}()
+	return s, nil
+}
+
+// flushWriter 每次写入后立即发送给客户端，响应体不会停留在http.Server的缓冲区中
+type flushWriter struct {
+	http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (w flushWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.flusher.Flush()
+	return n, err
+}
+
+// stickyReader 读取出错后一直返回同一个错误
+type stickyReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *stickyReader) Read(b []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.r.Read(b)
+	r.err = err
+	return n, err
+}
@@ -0,0 +1,87 @@
+package origin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestStreaming(t *testing.T) {
+	release := make(chan struct{})
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow.bin":
+			w.Header().Set("Content-Length", "10")
+			io.WriteString(w, "first")
+			w.(http.Flusher).Flush()
+			<-release
+			io.WriteString(w, "-last")
+		case "/truncated.bin":
+			w.Header().Set("Content-Length", "100")
+			io.WriteString(w, "partial")
+			w.(http.Flusher).Flush()
+			// 不写完声明的长度就断开连接
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+		}
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{Upstream: upstream.URL, DefaultTTL: time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	t.Run("FirstByteBeforeUpstreamFinishes", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/slow.bin")
+		if err != nil {
+			close(release)
+			t.Fatalf("Failed to get: %v", err)
+		}
+		defer resp.Body.Close()
+		first := make([]byte, 5)
+		_, err = io.ReadFull(resp.Body, first)
+		close(release)
+		if err != nil || string(first) != "first" {
+			t.Fatalf("Expected first bytes while upstream is still sending, got %q %v", first, err)
+		}
+		rest, _ := io.ReadAll(resp.Body)
+		if string(rest) != "-last" {
+			t.Errorf("Expected rest of body, got %q", rest)
+		}
+
+		deadline := time.Now().Add(5 * time.Second)
+		for exists, _ := cache.Exists(context.Background(), "/slow.bin"); !exists; exists, _ = cache.Exists(context.Background(), "/slow.bin") {
+			if time.Now().After(deadline) {
+				t.Fatal("Expected streamed response to be cached")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if resp, body := get(t, proxy, http.MethodGet, "/slow.bin"); body != "first-last" || resp.Header.Get("X-Cache") != "HIT" {
+			t.Errorf("Expected cached body, got %q %q", resp.Header.Get("X-Cache"), body)
+		}
+	})
+
+	t.Run("UpstreamAbort", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/truncated.bin")
+		if err != nil {
+			t.Fatalf("Failed to get: %v", err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err == nil || !strings.HasPrefix(string(body), "partial") || len(body) == 100 {
+			t.Errorf("Expected truncated body with error, got %d bytes %v", len(body), err)
+		}
+		if exists, _ := cache.Exists(context.Background(), "/truncated.bin"); exists {
+			t.Error("Expected truncated response not to be cached")
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package origin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// defaultMaxRevalidations 默认同时进行的后台刷新数
+const defaultMaxRevalidations = 16
+
+// staleWindow 返回条目过期后仍可返回的时间窗口，上游的stale-while-revalidate指令优先于配置
+func (p *Proxy) staleWindow(e *entry) time.Duration {
+	if window, ok := parseCacheControl(e.Header).seconds("stale-while-revalidate"); ok {
+		return window
+	}
+	return p.opts.StaleWhileRevalidate
+}
+
+// serveStale 条目过期但仍在stale-while-revalidate窗口内时返回旧内容并在后台刷新，否则返回false
+func (p *Proxy) serveStale(w http.ResponseWriter, r *http.Request, key string) bool {
+	stale := p.openStale(r.Context(), key, r.Header)
+	if stale == nil {
+		return false
+	}
+	defer stale.Close()
+	if time.Now().After(stale.info.ExpiresAt.Add(p.staleWindow(stale.entry))) {
+		return false
+	}
+
+	p.revalidate(r, key)
+	p.writeEntry(w, r, stale.entry, stale.body, stale.size, "STALE")
+	return true
+}
+
+// serveUnavailable 上游都已熔断时返回过期的条目，不论是否超过stale-while-revalidate窗口；没有条目时返回false
+func (p *Proxy) serveUnavailable(w http.ResponseWriter, r *http.Request, key string) bool {
+	stale := p.openStale(r.Context(), key, r.Header)
+	if stale == nil {
+		return false
+	}
+	defer stale.Close()
+	p.writeEntry(w, r, stale.entry, stale.body, stale.size, "STALE")
+	return true
+}
+
+// revalidate 在后台回源刷新条目，同一个键同时只有一个刷新，并发数达到上限时放弃本次刷新
+func (p *Proxy) revalidate(r *http.Request, key string) {
+	p.mu.Lock()
+	if p.refreshing[key] {
+		p.mu.Unlock()
+		return
+	}
+	select {
+	case p.refreshSem <- struct{}{}:
+	default:
+		p.mu.Unlock()
+		return
+	}
+	p.refreshing[key] = true
+	p.mu.Unlock()
+
+	// 客户端断开后刷新继续进行
+	out := p.upstreamRequest(r.WithContext(context.Background()))
+	go func() {
+		defer func() {
+			p.mu.Lock()
+			delete(p.refreshing, key)
+			p.mu.Unlock()
+			<-p.refreshSem
+		}()
+		p.refresh(out, key)
+	}()
+}
+
+// refresh 回源并用可缓存的响应替换缓存中的条目，上游返回304时只更新元数据和TTL，失败时保留旧条目
+func (p *Proxy) refresh(out *http.Request, key string) {
+	out.Method = http.MethodGet
+	out.Header.Del("Range")
+	stripConditional(out.Header)
+	reqHeader := out.Header.Clone()
+	stale := p.openStale(out.Context(), key, reqHeader)
+	if stale != nil {
+		defer stale.Close()
+		setValidators(out.Header, stale.entry)
+	}
+	resp, err := p.fetcher.Fetch(out.Context(), out)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	responseTime := time.Now()
+	e, body := newEntry(resp, responseTime), io.Reader(resp.Body)
+	if resp.StatusCode == http.StatusNotModified && stale != nil {
+		e, body = stale.freshen(resp, responseTime), stale.body
+	}
+	ttl, cacheable := p.cacheTTL(e, out.URL.Path, reqHeader, responseTime)
+	if !cacheable {
+		return
+	}
+	p.storeResponse(out.Context(), key, reqHeader, e, body, ttl)
+}
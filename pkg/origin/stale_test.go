@@ -0,0 +1,76 @@
+package origin
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	var version atomic.Int64
+	release := make(chan struct{})
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		v := version.Add(1)
+		if v > 1 {
+			<-release
+		}
+		switch {
+		case r.URL.Path == "/directive":
+			w.Header().Set("Cache-Control", "max-age=1, stale-while-revalidate=0")
+		case v == 1:
+			w.Header().Set("Cache-Control", "max-age=1")
+		default:
+			// Date只精确到秒，刷新后的条目用较长的TTL，避免轮询期间再次过期
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		fmt.Fprintf(w, "v%d", v)
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{Upstream: upstream.URL, StaleWhileRevalidate: time.Minute})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	if _, body := get(t, proxy, http.MethodGet, "/a"); body != "v1" {
+		t.Fatalf("Expected v1, got %q", body)
+	}
+	time.Sleep(1100 * time.Millisecond)
+
+	// 过期后立即返回旧内容，只触发一次后台刷新
+	for i := 0; i < 3; i++ {
+		resp, body := get(t, proxy, http.MethodGet, "/a")
+		if body != "v1" || resp.Header.Get("X-Cache") != "STALE" {
+			t.Errorf("Expected stale v1, got %q %q", body, resp.Header.Get("X-Cache"))
+		}
+	}
+	close(release)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, body := get(t, proxy, http.MethodGet, "/a")
+		if body == "v2" && resp.Header.Get("X-Cache") == "HIT" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected refreshed v2, got %q %q", body, resp.Header.Get("X-Cache"))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := upstream.requests.Load(); n != 2 {
+		t.Errorf("Expected 2 upstream requests, got %d", n)
+	}
+
+	t.Run("UpstreamDirective", func(t *testing.T) {
+		get(t, proxy, http.MethodGet, "/directive")
+		time.Sleep(1100 * time.Millisecond)
+		resp, _ := get(t, proxy, http.MethodGet, "/directive")
+		if resp.Header.Get("X-Cache") != "MISS" {
+			t.Errorf("Expected stale-while-revalidate=0 to disable stale serving, got %q", resp.Header.Get("X-Cache"))
+		}
+	})
+}
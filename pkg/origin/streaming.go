@@ -0,0 +1,192 @@
+package origin
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	pathpkg "path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultManifestTTL = 2 * time.Second
+	defaultSegmentTTL  = 24 * time.Hour
+
+	// maxPrefetch 每个分段请求最多预取的后续分段数
+	maxPrefetch = 32
+
+	// maxPrefetches 同时进行的预取数，超出时本次不预取
+	maxPrefetches = 16
+)
+
+var (
+	defaultManifestExtensions = []string{".m3u8", ".mpd"}
+	defaultSegmentExtensions  = []string{".ts", ".m4s", ".m4v", ".m4a", ".aac", ".cmfv", ".cmfa"}
+
+	manifestTypes = []string{"application/vnd.apple.mpegurl", "application/x-mpegurl", "audio/mpegurl", "application/dash+xml"}
+	segmentTypes  = []string{"video/mp2t", "video/iso.segment"}
+)
+
+// streamClass 流媒体资源的类型
+type streamClass int
+
+const (
+	streamNone streamClass = iota
+	streamManifest
+	streamSegment
+)
+
+// StreamingPolicy HLS/DASH的缓存策略：按扩展名或Content-Type识别播放列表和分段，
+// 播放列表（直播时频繁更新）使用很短的TTL，分段（内容不变）使用很长的TTL，
+// 请求分段时可以在后台预取之后的分段
+type StreamingPolicy struct {
+	// ManifestTTL 播放列表（.m3u8、.mpd）的TTL，默认2秒；点播的播放列表不变，可以设置得更长
+	ManifestTTL time.Duration `json:"manifest_ttl,omitempty"`
+
+	// SegmentTTL 分段（.ts、.m4s等）的TTL，默认24小时
+	SegmentTTL time.Duration `json:"segment_ttl,omitempty"`
+
+	// Prefetch 请求分段时在后台预取的后续分段数，0表示不预取；
+	// 后续分段的名称由文件名中最后一组数字加一得到，例如seg-00042.ts之后是seg-00043.ts
+	Prefetch int `json:"prefetch,omitempty"`
+
+	// ManifestExtensions 播放列表的扩展名，默认.m3u8和.mpd
+	ManifestExtensions []string `json:"manifest_extensions,omitempty"`
+
+	// SegmentExtensions 分段的扩展名，默认.ts、.m4s、.m4v、.m4a、.aac、.cmfv和.cmfa
+	SegmentExtensions []string `json:"segment_extensions,omitempty"`
+}
+
+// validate 检查选项并填充默认值
+func (s *StreamingPolicy) validate() error {
+	if s.ManifestTTL < 0 || s.SegmentTTL < 0 {
+		return fmt.Errorf("streaming ttl cannot be negative")
+	}
+	if s.Prefetch < 0 || s.Prefetch > maxPrefetch {
+		return fmt.Errorf("streaming prefetch must be between 0 and %d", maxPrefetch)
+	}
+	if s.ManifestTTL == 0 {
+		s.ManifestTTL = defaultManifestTTL
+	}
+	if s.SegmentTTL == 0 {
+		s.SegmentTTL = defaultSegmentTTL
+	}
+	if len(s.ManifestExtensions) == 0 {
+		s.ManifestExtensions = defaultManifestExtensions
+	}
+	if len(s.SegmentExtensions) == 0 {
+		s.SegmentExtensions = defaultSegmentExtensions
+	}
+	return nil
+}
+
+// classify 按请求路径的扩展名识别资源类型，扩展名无法识别时按响应的Content-Type（h可以为nil）
+func (s *StreamingPolicy) classify(path string, h http.Header) streamClass {
+	ext := strings.ToLower(pathpkg.Ext(path))
+	switch {
+	case ext != "" && hasString(s.ManifestExtensions, ext):
+		return streamManifest
+	case ext != "" && hasString(s.SegmentExtensions, ext):
+		return streamSegment
+	case h == nil:
+		return streamNone
+	}
+	mimeType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	switch {
+	case err != nil:
+		return streamNone
+	case hasString(manifestTypes, mimeType):
+		return streamManifest
+	case hasString(segmentTypes, mimeType):
+		return streamSegment
+	}
+	return streamNone
+}
+
+// ttl 返回资源类型对应的TTL
+func (s *StreamingPolicy) ttl(class streamClass) time.Duration {
+	if class == streamManifest {
+		return s.ManifestTTL
+	}
+	return s.SegmentTTL
+}
+
+// hasString 返回list中是否有不区分大小写等于s的元素
+func hasString(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextSegment 返回文件名（不含扩展名）中最后一组数字加n后的路径，保留数字的位数；没有数字时返回false
+func nextSegment(path string, n int) (string, bool) {
+	dir, file := pathpkg.Split(path)
+	ext := pathpkg.Ext(file)
+	file = strings.TrimSuffix(file, ext)
+	end := strings.LastIndexAny(file, "0123456789")
+	if end < 0 {
+		return "", false
+	}
+	start := end
+	for start > 0 && file[start-1] >= '0' && file[start-1] <= '9' {
+		start--
+	}
+	digits := file[start : end+1]
+	seq, err := strconv.ParseUint(digits, 10, 63)
+	if err != nil {
+		return "", false
+	}
+	next := strconv.FormatUint(seq+uint64(n), 10)
+	if len(next) < len(digits) {
+		next = strings.Repeat("0", len(digits)-len(next)) + next
+	}
+	return dir + file[:start] + next + file[end+1:] + ext, true
+}
+
+// prefetch 在后台预取分段请求之后的Prefetch个分段，已缓存或正在回源的分段跳过；
+// 预取与客户端的请求共享回源，客户端请求正在预取的分段时等待同一次回源
+func (p *Proxy) prefetch(r *http.Request) {
+	for i := 1; i <= p.opts.Streaming.Prefetch; i++ {
+		path, ok := nextSegment(r.URL.Path, i)
+		if !ok {
+			return
+		}
+		out := r.Clone(context.Background())
+		out.URL.Path, out.URL.RawPath = path, ""
+		out.RequestURI = out.URL.RequestURI()
+		out.Header.Del("Range")
+		key := p.key(out)
+		if exists, _ := p.cache.Exists(out.Context(), key); exists {
+			continue
+		}
+
+		p.mu.Lock()
+		_, inFlight := p.flights[key]
+		p.mu.Unlock()
+		if inFlight {
+			continue
+		}
+		select {
+		case p.prefetchSem <- struct{}{}:
+		default:
+			return
+		}
+		go func() {
+			defer func() { <-p.prefetchSem }()
+			res, _, release, err := p.coalesce(out.Context(), key, func() (*fetchResult, error) {
+				return p.pull(out, key)
+			})
+			defer release()
+			if err == nil {
+				// 等待响应体写入缓存
+				res.body.wait()
+			}
+		}()
+	}
+}
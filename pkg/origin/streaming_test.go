@@ -0,0 +1,148 @@
+package origin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestNextSegment(t *testing.T) {
+	tests := []struct {
+		path string
+		n    int
+		want string
+		ok   bool
+	}{
+		{"/live/seg-00042.ts", 1, "/live/seg-00043.ts", true},
+		{"/live/seg-00099.ts", 2, "/live/seg-00101.ts", true},
+		{"/live/seg-99.ts", 1, "/live/seg-100.ts", true},
+		{"/v1/720p/chunk_7_audio.m4s", 1, "/v1/720p/chunk_8_audio.m4s", true},
+		{"/v1/720p/init.mp4", 1, "", false},
+		{"/v1/segment.ts", 1, "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := nextSegment(tt.path, tt.n); got != tt.want || ok != tt.ok {
+			t.Errorf("Expected nextSegment(%q, %d) = %q %v, got %q %v", tt.path, tt.n, tt.want, tt.ok, got, ok)
+		}
+	}
+}
+
+func TestStreamingClassify(t *testing.T) {
+	s := &StreamingPolicy{}
+	if err := s.validate(); err != nil {
+		t.Fatalf("Failed to validate streaming policy: %v", err)
+	}
+	tests := []struct {
+		path, contentType string
+		want              streamClass
+	}{
+		{"/live/index.m3u8", "", streamManifest},
+		{"/vod/manifest.MPD", "", streamManifest},
+		{"/live/seg-1.ts", "", streamSegment},
+		{"/vod/chunk-1.m4s", "application/octet-stream", streamSegment},
+		{"/live/playlist", "application/vnd.apple.mpegurl", streamManifest},
+		{"/live/segment?id=1", "video/MP2T", streamSegment},
+		{"/index.html", "text/html", streamNone},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		if tt.contentType != "" {
+			h.Set("Content-Type", tt.contentType)
+		}
+		if got := s.classify(tt.path, h); got != tt.want {
+			t.Errorf("Expected %q (%s) to be class %d, got %d", tt.path, tt.contentType, tt.want, got)
+		}
+	}
+	if (&StreamingPolicy{Prefetch: maxPrefetch + 1}).validate() == nil {
+		t.Error("Expected prefetch above limit to be rejected")
+	}
+}
+
+func TestStreamingPolicy(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		requested = make(map[string]int)
+	)
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/live/index.m3u8":
+			w.Header().Set("Cache-Control", "max-age=3600")
+			io.WriteString(w, "#EXTM3U\nseg-001.ts\nseg-002.ts\nseg-003.ts\n")
+		case "/live/seg-001.ts", "/live/seg-002.ts", "/live/seg-003.ts":
+			w.Header().Set("Cache-Control", "max-age=1")
+			io.WriteString(w, r.URL.Path)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{
+		Upstream:    upstream.URL,
+		NegativeTTL: map[int]time.Duration{http.StatusNotFound: time.Minute},
+		Streaming:   &StreamingPolicy{ManifestTTL: time.Second, SegmentTTL: time.Hour, Prefetch: 2},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+	ttl := func(key string) time.Duration {
+		t.Helper()
+		info, err := cache.GetInfo(context.Background(), key)
+		if err != nil {
+			t.Fatalf("Failed to get %s: %v", key, err)
+		}
+		return time.Until(info.ExpiresAt)
+	}
+	// waitRequests 等待后台预取完成
+	waitRequests := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for upstream.requests.Load() < int64(n) || len(proxy.prefetchSem) > 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for %d upstream requests, got %d", n, upstream.requests.Load())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	get(t, proxy, http.MethodGet, "/live/index.m3u8")
+	if d := ttl("/live/index.m3u8"); d > time.Second {
+		t.Errorf("Expected manifest TTL of at most 1s, got %v", d)
+	}
+
+	if resp, body := get(t, proxy, http.MethodGet, "/live/seg-001.ts"); resp.Header.Get("X-Cache") != "MISS" || body != "/live/seg-001.ts" {
+		t.Fatalf("Expected segment MISS, got %q %q", resp.Header.Get("X-Cache"), body)
+	}
+	if d := ttl("/live/seg-001.ts"); d < 59*time.Minute {
+		t.Errorf("Expected segment TTL of 1h, got %v", d)
+	}
+	waitRequests(4)
+	for _, target := range []string{"/live/seg-002.ts", "/live/seg-003.ts"} {
+		if resp, _ := get(t, proxy, http.MethodGet, target); resp.Header.Get("X-Cache") != "HIT" {
+			t.Errorf("Expected prefetched %s to be a HIT, got %q", target, resp.Header.Get("X-Cache"))
+		}
+	}
+
+	// seg-003之后的分段还不存在，404不缓存
+	waitRequests(6)
+	for _, key := range []string{"/live/seg-004.ts", "/live/seg-005.ts"} {
+		if exists, _ := cache.Exists(context.Background(), key); exists {
+			t.Errorf("Expected missing segment %s not to be cached", key)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for path, n := range requested {
+		if n != 1 {
+			t.Errorf("Expected %s to be fetched once, got %d", path, n)
+		}
+	}
+}
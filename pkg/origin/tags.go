@@ -0,0 +1,134 @@
+package origin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// tagHeaders 上游响应中列出缓存标签的响应头：Fastly的Surrogate-Key用空格分隔，Cloudflare的Cache-Tag用逗号分隔。
+// 标签保存在条目的元数据中，返回给客户端时去掉这些头
+var tagHeaders = []string{"Surrogate-Key", "Cache-Tag"}
+
+// takeTags 从响应头中取出缓存标签（排序去重）并删除这些头，没有标签时返回nil
+func takeTags(h http.Header) []string {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, name := range tagHeaders {
+		for _, line := range h.Values(name) {
+			for _, tag := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+				if !seen[tag] {
+					seen[tag] = true
+					tags = append(tags, tag)
+				}
+			}
+		}
+		h.Del(name)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// tagIndex 标签到缓存键的内存索引，第一次按标签清除时扫描缓存建立，之后在写入条目时更新
+type tagIndex struct {
+	mu     sync.Mutex
+	loaded bool
+	keys   map[string]map[string]bool // 标签 -> 缓存键
+}
+
+// add 记录键上的标签
+func (idx *tagIndex) add(key string, tags []string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.keys == nil {
+		idx.keys = make(map[string]map[string]bool)
+	}
+	for _, tag := range tags {
+		if idx.keys[tag] == nil {
+			idx.keys[tag] = make(map[string]bool)
+		}
+		idx.keys[tag][key] = true
+	}
+}
+
+// loadTags 第一次调用时扫描缓存中代理写入的条目，建立标签索引
+func (p *Proxy) loadTags(ctx context.Context) error {
+	p.tags.mu.Lock()
+	loaded := p.tags.loaded
+	p.tags.mu.Unlock()
+	if loaded {
+		return nil
+	}
+
+	files, err := p.cache.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list cache: %w", err)
+	}
+	for _, info := range files {
+		if e := p.readMeta(ctx, info.Key); e != nil && len(e.Tags) > 0 {
+			p.tags.add(info.Key, e.Tags)
+		}
+	}
+	p.tags.mu.Lock()
+	p.tags.loaded = true
+	p.tags.mu.Unlock()
+	return nil
+}
+
+// readMeta 读取条目的元数据，不存在或不是代理写入的条目时返回nil
+func (p *Proxy) readMeta(ctx context.Context, key string) *entry {
+	getter, ok := p.cache.(filecache.StaleGetter)
+	get := p.cache.Get
+	if ok {
+		// 过期但尚未清理的条目仍可能用于stale-while-revalidate和条件回源，同样需要清除
+		get = getter.GetStale
+	}
+	reader, _, err := get(ctx, key)
+	if err != nil {
+		return nil
+	}
+	defer reader.Close()
+	e, _, _, err := readEntry(reader)
+	if err != nil {
+		return nil
+	}
+	return e
+}
+
+// PurgeTag 删除带有tag的所有条目（包括各个变体、片段和分片），返回删除的条目数；
+// 实现admin.TagPurger，可通过管理接口的POST /purge?tag=调用
+func (p *Proxy) PurgeTag(ctx context.Context, tag string) (int, error) {
+	if err := p.loadTags(ctx); err != nil {
+		return 0, err
+	}
+	p.tags.mu.Lock()
+	keys := p.tags.keys[tag]
+	delete(p.tags.keys, tag)
+	p.tags.mu.Unlock()
+
+	purged := 0
+	for key := range keys {
+		// 键上的条目可能已被没有该标签的新响应替换
+		e := p.readMeta(ctx, key)
+		if e == nil || !hasTag(e.Tags, tag) {
+			continue
+		}
+		if err := p.cache.Delete(ctx, key); err != nil && !errors.Is(err, filecache.ErrNotFound) {
+			return purged, fmt.Errorf("failed to delete %s: %w", key, err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// hasTag 返回排序的tags中是否有tag
+func hasTag(tags []string, tag string) bool {
+	i := sort.SearchStrings(tags, tag)
+	return i < len(tags) && tags[i] == tag
+}
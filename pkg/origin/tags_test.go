@@ -0,0 +1,86 @@
+package origin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestTakeTags(t *testing.T) {
+	h := http.Header{
+		"Surrogate-Key": {"product-42  home", "home"},
+		"Cache-Tag":     {"product-42,category-7"},
+		"Content-Type":  {"text/html"},
+	}
+	if got, want := takeTags(h), []string{"category-7", "home", "product-42"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected tags %v, got %v", want, got)
+	}
+	if h.Get("Surrogate-Key") != "" || h.Get("Cache-Tag") != "" || h.Get("Content-Type") == "" {
+		t.Errorf("Expected only tag headers to be removed, got %v", h)
+	}
+	if tags := takeTags(http.Header{}); tags != nil {
+		t.Errorf("Expected nil tags, got %v", tags)
+	}
+}
+
+func TestPurgeTag(t *testing.T) {
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/p/42":
+			w.Header().Set("Surrogate-Key", "product-42 home")
+		case "/p/42.json":
+			w.Header().Set("Cache-Tag", "product-42")
+		case "/p/43":
+			w.Header().Set("Surrogate-Key", "product-43 home")
+		}
+		io.WriteString(w, r.URL.Path)
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	newProxy := func() *Proxy {
+		proxy, err := NewProxy(cache, Options{Upstream: upstream.URL, DefaultTTL: time.Hour})
+		if err != nil {
+			t.Fatalf("Failed to create proxy: %v", err)
+		}
+		return proxy
+	}
+	proxy := newProxy()
+	exists := func(key string) bool {
+		ok, _ := cache.Exists(context.Background(), key)
+		return ok
+	}
+
+	for _, target := range []string{"/p/42", "/p/42.json", "/p/43"} {
+		resp, _ := get(t, proxy, http.MethodGet, target)
+		if resp.Header.Get("Surrogate-Key") != "" || resp.Header.Get("Cache-Tag") != "" {
+			t.Errorf("Expected tag headers to be hidden from clients, got %v", resp.Header)
+		}
+	}
+
+	purged, err := proxy.PurgeTag(context.Background(), "product-42")
+	if err != nil {
+		t.Fatalf("Failed to purge tag: %v", err)
+	}
+	if purged != 2 || exists("/p/42") || exists("/p/42.json") || !exists("/p/43") {
+		t.Errorf("Expected only product-42 entries to be purged, got %d", purged)
+	}
+	if resp, _ := get(t, proxy, http.MethodGet, "/p/42"); resp.Header.Get("X-Cache") != "MISS" {
+		t.Errorf("Expected purged entry to be fetched again, got %q", resp.Header.Get("X-Cache"))
+	}
+
+	t.Run("RebuildIndex", func(t *testing.T) {
+		// 新的代理没有内存索引，扫描缓存中的条目
+		purged, err := newProxy().PurgeTag(context.Background(), "home")
+		if err != nil {
+			t.Fatalf("Failed to purge tag: %v", err)
+		}
+		if purged != 2 || exists("/p/42") || exists("/p/43") {
+			t.Errorf("Expected 2 entries tagged home to be purged, got %d", purged)
+		}
+	})
+}
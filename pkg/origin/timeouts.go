@@ -0,0 +1,130 @@
+package origin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultConnectTimeout        = 10 * time.Second
+	defaultTLSHandshakeTimeout   = 10 * time.Second
+	defaultResponseHeaderTimeout = 60 * time.Second
+	defaultBodyTimeout           = 60 * time.Second
+)
+
+// Timeouts 回源的超时，避免挂起的上游长时间占用连接和goroutine。
+// 代理自己创建Transport时未设置的字段使用默认值；使用Options.Transport时只应用设置了的字段，
+// 且Connect、TLSHandshake和ResponseHeader只对*http.Transport生效
+type Timeouts struct {
+	// Connect 建立TCP连接的超时，默认10秒
+	Connect time.Duration `json:"connect,omitempty"`
+
+	// TLSHandshake TLS握手的超时，默认10秒
+	TLSHandshake time.Duration `json:"tls_handshake,omitempty"`
+
+	// ResponseHeader 发送请求后等待响应头的超时，默认60秒
+	ResponseHeader time.Duration `json:"response_header,omitempty"`
+
+	// Body 读取响应体时等待上游数据的超时，每收到一次数据重新计时，默认60秒；
+	// 只计算等待上游的时间，客户端读取缓慢不会触发
+	Body time.Duration `json:"body,omitempty"`
+}
+
+// validate 检查超时
+func (t *Timeouts) validate() error {
+	if t.Connect < 0 || t.TLSHandshake < 0 || t.ResponseHeader < 0 || t.Body < 0 {
+		return fmt.Errorf("timeouts cannot be negative")
+	}
+	return nil
+}
+
+// merge 返回t中未设置的字段取自base的结果，t和base都可以为nil
+func (t *Timeouts) merge(base *Timeouts) *Timeouts {
+	switch {
+	case t == nil:
+		return base
+	case base == nil:
+		return t
+	}
+	merged := *t
+	if merged.Connect == 0 {
+		merged.Connect = base.Connect
+	}
+	if merged.TLSHandshake == 0 {
+		merged.TLSHandshake = base.TLSHandshake
+	}
+	if merged.ResponseHeader == 0 {
+		merged.ResponseHeader = base.ResponseHeader
+	}
+	if merged.Body == 0 {
+		merged.Body = base.Body
+	}
+	return &merged
+}
+
+// defaultTimeouts 代理自己创建Transport时使用的超时
+var defaultTimeouts = Timeouts{
+	Connect:        defaultConnectTimeout,
+	TLSHandshake:   defaultTLSHandshakeTimeout,
+	ResponseHeader: defaultResponseHeaderTimeout,
+	Body:           defaultBodyTimeout,
+}
+
+// bodyTimeoutFetcher 读取响应体时等待上游数据超过timeout后取消请求
+type bodyTimeoutFetcher struct {
+	next    Fetcher
+	timeout time.Duration
+}
+
+// Fetch 发送请求，响应体的每次读取都有超时
+func (f *bodyTimeoutFetcher) Fetch(ctx context.Context, req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	resp, err := f.next.Fetch(ctx, req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	body := &timeoutBody{ReadCloser: resp.Body, cancel: cancel, timeout: f.timeout}
+	body.timer = time.AfterFunc(f.timeout, body.expire)
+	body.timer.Stop()
+	resp.Body = body
+	return resp, nil
+}
+
+// timeoutBody 每次读取都有超时的响应体
+type timeoutBody struct {
+	io.ReadCloser
+	cancel  context.CancelFunc
+	timeout time.Duration
+	timer   *time.Timer
+	expired atomic.Bool
+}
+
+// expire 超时时取消请求，阻塞的Read随之返回
+func (b *timeoutBody) expire() {
+	b.expired.Store(true)
+	b.cancel()
+}
+
+// Read 读取响应体，timeout内没有收到数据时返回超时错误
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	b.timer.Reset(b.timeout)
+	n, err := b.ReadCloser.Read(p)
+	b.timer.Stop()
+	if err != nil && err != io.EOF && b.expired.Load() {
+		err = fmt.Errorf("upstream sent no data for %v: %w", b.timeout, context.DeadlineExceeded)
+	}
+	return n, err
+}
+
+// Close 关闭响应体并释放请求的ctx
+func (b *timeoutBody) Close() error {
+	b.timer.Stop()
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,113 @@
+package origin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestTimeouts(t *testing.T) {
+	release := make(chan struct{})
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/hang":
+			<-release
+		case "/stall":
+			io.WriteString(w, "partial")
+			w.(http.Flusher).Flush()
+			<-release
+		default:
+			io.WriteString(w, "complete body")
+		}
+	})
+	t.Cleanup(func() { close(release) })
+
+	t.Run("response header", func(t *testing.T) {
+		cache := filecache.NewMemoryCache(1 << 20)
+		defer cache.Close()
+		proxy, err := NewProxy(cache, Options{Upstream: upstream.URL, Timeouts: &Timeouts{ResponseHeader: 50 * time.Millisecond}})
+		if err != nil {
+			t.Fatalf("Failed to create proxy: %v", err)
+		}
+		start := time.Now()
+		resp, _ := get(t, proxy, http.MethodGet, "/hang")
+		if resp.StatusCode != http.StatusGatewayTimeout || time.Since(start) > time.Second {
+			t.Errorf("Expected fast 504, got %d after %v", resp.StatusCode, time.Since(start))
+		}
+	})
+
+	t.Run("body", func(t *testing.T) {
+		fetcher, err := newUpstreamFetcher(upstream.URL, transportConfig{timeouts: &Timeouts{Body: 50 * time.Millisecond}})
+		if err != nil {
+			t.Fatalf("Failed to create fetcher: %v", err)
+		}
+		resp, err := fetcher.Fetch(context.Background(), httptest.NewRequest(http.MethodGet, "/stall", nil))
+		if err != nil {
+			t.Fatalf("Failed to fetch: %v", err)
+		}
+		defer resp.Body.Close()
+		start := time.Now()
+		body, err := io.ReadAll(resp.Body)
+		if !errors.Is(err, context.DeadlineExceeded) || string(body) != "partial" || time.Since(start) > time.Second {
+			t.Errorf("Expected body timeout after partial data, got %q %v after %v", body, err, time.Since(start))
+		}
+	})
+
+	t.Run("slow reader", func(t *testing.T) {
+		fetcher, err := newUpstreamFetcher(upstream.URL, transportConfig{timeouts: &Timeouts{Body: 50 * time.Millisecond}})
+		if err != nil {
+			t.Fatalf("Failed to create fetcher: %v", err)
+		}
+		resp, err := fetcher.Fetch(context.Background(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+		if err != nil {
+			t.Fatalf("Failed to fetch: %v", err)
+		}
+		defer resp.Body.Close()
+		buf := make([]byte, 4)
+		if _, err := io.ReadFull(resp.Body, buf); err != nil {
+			t.Fatalf("Failed to read: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		rest, err := io.ReadAll(resp.Body)
+		if err != nil || string(buf)+string(rest) != "complete body" {
+			t.Errorf("Expected slow reader to get the whole body, got %q %v", rest, err)
+		}
+	})
+
+	t.Run("per upstream", func(t *testing.T) {
+		merged := (&Timeouts{Body: time.Second}).merge(&Timeouts{Connect: time.Minute, Body: time.Hour})
+		if merged.Connect != time.Minute || merged.Body != time.Second {
+			t.Errorf("Expected upstream timeouts to override defaults, got %+v", merged)
+		}
+		cache := filecache.NewMemoryCache(1 << 20)
+		defer cache.Close()
+		proxy, err := NewProxy(cache, Options{
+			Upstreams: []UpstreamTarget{{URL: upstream.URL, Timeouts: &Timeouts{ResponseHeader: 50 * time.Millisecond}}},
+			Timeouts:  &Timeouts{ResponseHeader: time.Minute},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create proxy: %v", err)
+		}
+		start := time.Now()
+		if resp, _ := get(t, proxy, http.MethodGet, "/hang"); resp.StatusCode != http.StatusGatewayTimeout || time.Since(start) > time.Second {
+			t.Errorf("Expected upstream timeout to apply, got %d after %v", resp.StatusCode, time.Since(start))
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		cache := filecache.NewMemoryCache(1 << 20)
+		defer cache.Close()
+		if _, err := NewProxy(cache, Options{Upstream: upstream.URL, Timeouts: &Timeouts{Body: -1}}); err == nil {
+			t.Error("Expected error for negative timeout")
+		}
+		if _, err := NewProxy(cache, Options{Upstreams: []UpstreamTarget{{URL: upstream.URL, Timeouts: &Timeouts{Connect: -1}}}}); err == nil {
+			t.Error("Expected error for negative upstream timeout")
+		}
+	})
+}
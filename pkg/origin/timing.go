@@ -0,0 +1,34 @@
+package origin
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// timingKey Timing在context中的键
+type timingKey struct{}
+
+// Timing 一个请求等待上游的时间，访问日志等中间件用WithTiming放入请求的context，请求处理完后读取
+type Timing struct {
+	upstream atomic.Int64
+}
+
+// WithTiming 返回带有新Timing的context，代理处理使用这个context的请求时记录回源时间
+func WithTiming(ctx context.Context) (context.Context, *Timing) {
+	t := &Timing{}
+	return context.WithValue(ctx, timingKey{}, t), t
+}
+
+// Upstream 返回从发出回源请求到收到上游响应头的时间，多次回源（例如分片和ESI片段）时累加；
+// 没有回源或等待其他请求的回源结果时为0
+func (t *Timing) Upstream() time.Duration {
+	return time.Duration(t.upstream.Load())
+}
+
+// addUpstreamTime 把从start开始的回源时间记录到ctx中的Timing
+func addUpstreamTime(ctx context.Context, start time.Time) {
+	if t, ok := ctx.Value(timingKey{}).(*Timing); ok {
+		t.upstream.Add(int64(time.Since(start)))
+	}
+}
@@ -0,0 +1,116 @@
+package origin
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// ForwardProxy 回源经过的出口代理
+type ForwardProxy struct {
+	// URL 代理地址，支持http://、https://和socks5://；为空时使用HTTP_PROXY、HTTPS_PROXY和NO_PROXY环境变量
+	URL string `json:"url,omitempty"`
+
+	// Username 代理认证的用户名，也可以写在URL中
+	Username string `json:"username,omitempty"`
+
+	// Password 代理认证的密码
+	Password string `json:"password,omitempty"`
+
+	// NoProxy 直接连接、不经过代理的上游，格式与NO_PROXY环境变量相同：
+	// 主机名（匹配它和它的子域名）、".example.com"（只匹配子域名）、IP或CIDR，可以带端口，"*"表示所有上游
+	NoProxy []string `json:"no_proxy,omitempty"`
+}
+
+// proxyFunc 返回Transport.Proxy使用的函数
+func (p *ForwardProxy) proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	cfg := httpproxy.FromEnvironment()
+	if p.URL != "" {
+		u, err := url.Parse(p.URL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid forward proxy %q", p.URL)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("invalid forward proxy %q: scheme must be http, https or socks5", p.URL)
+		}
+		cfg.HTTPProxy, cfg.HTTPSProxy = p.URL, p.URL
+		cfg.NoProxy = ""
+	}
+	if len(p.NoProxy) > 0 {
+		cfg.NoProxy = strings.Join(append(strings.Split(cfg.NoProxy, ","), p.NoProxy...), ",")
+	}
+	if (p.Username != "" || p.Password != "") && cfg.HTTPProxy == "" && cfg.HTTPSProxy == "" {
+		return nil, fmt.Errorf("forward proxy credentials set without a proxy url")
+	}
+	proxyURL := cfg.ProxyFunc()
+	return func(r *http.Request) (*url.URL, error) {
+		u, err := proxyURL(r.URL)
+		if u == nil || err != nil || p.Username == "" && p.Password == "" {
+			return u, err
+		}
+		withAuth := *u
+		withAuth.User = url.UserPassword(p.Username, p.Password)
+		return &withAuth, nil
+	}, nil
+}
+
+// transportConfig 回源Transport的配置
+type transportConfig struct {
+	transport http.RoundTripper // Options.Transport，为nil时复制http.DefaultTransport
+	timeouts  *Timeouts
+	proxy     func(*http.Request) (*url.URL, error) // 不为nil时替换Transport.Proxy
+	dns       *dnsCache                             // 不为nil时用它解析上游和出口代理的主机名
+	pool      *ConnectionPool
+}
+
+// newUpstreamFetcher 创建HTTP上游的Fetcher：cfg.transport为nil时复制http.DefaultTransport，
+// 未设置的超时和连接复用设置使用默认值；cfg.transport是*http.Transport时复制后应用设置了的超时、
+// 出口代理、解析缓存和连接复用设置
+func newUpstreamFetcher(upstream string, cfg transportConfig) (Fetcher, error) {
+	transport, timeouts, pool := cfg.transport, cfg.timeouts, cfg.pool
+	if transport == nil {
+		timeouts = timeouts.merge(&defaultTimeouts)
+		pool = pool.merge(&defaultPool)
+		transport = http.DefaultTransport
+	}
+	if base, ok := transport.(*http.Transport); ok && (timeouts != nil || cfg.proxy != nil || cfg.dns != nil || pool != nil) {
+		tr := base.Clone()
+		if cfg.proxy != nil {
+			tr.Proxy = cfg.proxy
+		}
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		if timeouts != nil && timeouts.Connect > 0 {
+			dialer.Timeout = timeouts.Connect
+			tr.DialContext = dialer.DialContext
+		}
+		if pool != nil && pool.KeepAlive != 0 {
+			dialer.KeepAlive = pool.KeepAlive
+			tr.DialContext = dialer.DialContext
+		}
+		if pool != nil {
+			pool.apply(tr)
+		}
+		if cfg.dns != nil {
+			tr.DialContext = cfg.dns.dialContext(dialer)
+		}
+		if timeouts != nil && timeouts.TLSHandshake > 0 {
+			tr.TLSHandshakeTimeout = timeouts.TLSHandshake
+		}
+		if timeouts != nil && timeouts.ResponseHeader > 0 {
+			tr.ResponseHeaderTimeout = timeouts.ResponseHeader
+		}
+		transport = tr
+	}
+	fetcher, err := NewHTTPFetcher(upstream, transport)
+	if err != nil || timeouts == nil || timeouts.Body == 0 {
+		return fetcher, err
+	}
+	return &bodyTimeoutFetcher{next: fetcher, timeout: timeouts.Body}, nil
+}
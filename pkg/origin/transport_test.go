@@ -0,0 +1,83 @@
+package origin
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestForwardProxy(t *testing.T) {
+	var auth atomic.Value
+	forward := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		auth.Store(r.Header.Get("Proxy-Authorization"))
+		if !r.URL.IsAbs() {
+			http.Error(w, "not a proxy request", http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, "via proxy "+r.URL.String())
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	credentials := "Basic " + base64.StdEncoding.EncodeToString([]byte("edge:s3cret"))
+
+	t.Run("explicit", func(t *testing.T) {
+		proxy, err := NewProxy(cache.Namespace("explicit"), Options{
+			Upstream:     "http://origin.test/assets",
+			ForwardProxy: &ForwardProxy{URL: forward.URL, Username: "edge", Password: "s3cret"},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create proxy: %v", err)
+		}
+		_, body := get(t, proxy, http.MethodGet, "/app.css")
+		if body != "via proxy http://origin.test/assets/app.css" || auth.Load() != credentials {
+			t.Errorf("Expected authenticated proxy request, got %q with %q", body, auth.Load())
+		}
+	})
+
+	t.Run("environment", func(t *testing.T) {
+		t.Setenv("HTTP_PROXY", forward.URL)
+		t.Setenv("NO_PROXY", "")
+		proxy, err := NewProxy(cache.Namespace("environment"), Options{
+			Upstreams:    []UpstreamTarget{{URL: "http://origin.test"}},
+			ForwardProxy: &ForwardProxy{Username: "edge", Password: "s3cret"},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create proxy: %v", err)
+		}
+		if _, body := get(t, proxy, http.MethodGet, "/env"); body != "via proxy http://origin.test/env" {
+			t.Errorf("Expected request through HTTP_PROXY, got %q", body)
+		}
+	})
+
+	t.Run("no proxy", func(t *testing.T) {
+		before := forward.requests.Load()
+		proxy, err := NewProxy(cache.Namespace("direct"), Options{
+			Upstream:     "http://origin.invalid",
+			ForwardProxy: &ForwardProxy{URL: forward.URL, NoProxy: []string{"origin.invalid"}},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create proxy: %v", err)
+		}
+		if resp, _ := get(t, proxy, http.MethodGet, "/direct"); resp.StatusCode != http.StatusBadGateway || forward.requests.Load() != before {
+			t.Errorf("Expected direct connection to fail without the proxy, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Setenv("HTTP_PROXY", "")
+		t.Setenv("HTTPS_PROXY", "")
+		for _, fp := range []*ForwardProxy{
+			{URL: "ftp://proxy:21"},
+			{URL: "proxy"},
+			{Username: "edge"},
+		} {
+			if _, err := NewProxy(cache, Options{Upstream: "http://origin.test", ForwardProxy: fp}); err == nil {
+				t.Errorf("Expected error for %+v", fp)
+			}
+		}
+	})
+}
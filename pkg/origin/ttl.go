@@ -0,0 +1,298 @@
+package origin
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cacheControl 解析后的Cache-Control指令，键为小写的指令名，值去掉了引号
+type cacheControl map[string]string
+
+// parseCacheControl 解析Cache-Control头，重复的指令以第一个为准
+func parseCacheControl(h http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, line := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if _, ok := cc[name]; !ok {
+				cc[name] = strings.Trim(strings.TrimSpace(value), `"`)
+			}
+		}
+	}
+	return cc
+}
+
+// has 返回是否包含指令
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+// seconds 返回以秒为单位的指令值，不存在或格式错误时返回false
+func (cc cacheControl) seconds(name string) (time.Duration, bool) {
+	v, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// freshnessLifetime 按RFC 9111第4.2.1节计算响应的新鲜期：s-maxage优先于max-age，其次是Expires减去Date；
+// 不带字段名的no-cache要求每次使用前重新验证，新鲜期为0。没有这些头时返回false
+func freshnessLifetime(h http.Header, responseTime time.Time) (time.Duration, bool) {
+	cc := parseCacheControl(h)
+	if fields, ok := cc["no-cache"]; ok && fields == "" {
+		return 0, true
+	}
+	if lifetime, ok := cc.seconds("s-maxage"); ok {
+		return lifetime, true
+	}
+	if lifetime, ok := cc.seconds("max-age"); ok {
+		return lifetime, true
+	}
+	if v := h.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			// 无效的Expires（例如"0"）表示已经过期
+			return 0, true
+		}
+		date := responseDate(h, responseTime)
+		if lifetime := expires.Sub(date); lifetime > 0 {
+			return lifetime, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// storable 按RFC 9111第3节判断响应能否写入共享缓存：带有no-store或private指令的响应不缓存，
+// 带有Set-Cookie的响应也不缓存，避免把一个用户的会话返回给其他用户。
+// 按第3.5节，authenticated为true（请求带有认证信息）时，只有响应带有public、s-maxage或must-revalidate才缓存，
+// 否则认证用户看到的内容可能返回给匿名用户
+func storable(h http.Header, authenticated bool) bool {
+	cc := parseCacheControl(h)
+	if cc.has("no-store") || cc.has("private") || len(h.Values("Set-Cookie")) > 0 {
+		return false
+	}
+	return !authenticated || cc.has("public") || cc.has("s-maxage") || cc.has("must-revalidate")
+}
+
+// credentialHeaders 上游可能按其返回不同内容的客户端认证头
+var credentialHeaders = []string{"Authorization", "Cookie"}
+
+// authenticated 返回客户端请求头reqHeader中的Authorization或Cookie是否会发送到上游，
+// 被Options.Headers中的Request规则删除或替换的不算，例如去掉Cookie、改用固定的回源认证头
+func (p *Proxy) authenticated(path string, reqHeader http.Header) bool {
+	sent := http.Header{}
+	for _, name := range credentialHeaders {
+		if values := reqHeader.Values(name); len(values) > 0 {
+			sent[name] = append([]string(nil), values...)
+		}
+	}
+	if len(sent) == 0 {
+		return false
+	}
+	for _, a := range headerActions(p.opts.Headers, path, func(rule *HeaderRule) *HeaderActions { return rule.Request }) {
+		a.apply(sent)
+	}
+	for _, name := range credentialHeaders {
+		for _, value := range reqHeader.Values(name) {
+			for _, v := range sent.Values(name) {
+				if v == value {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// initialAge 按RFC 9111第4.2.3节计算收到响应时的年龄，取Date推算的年龄和上游Age头中较大的一个
+func initialAge(h http.Header, responseTime time.Time) time.Duration {
+	age := responseTime.Sub(responseDate(h, responseTime))
+	if age < 0 {
+		age = 0
+	}
+	if v, err := strconv.ParseInt(strings.TrimSpace(h.Get("Age")), 10, 64); err == nil && v >= 0 {
+		if corrected := time.Duration(v) * time.Second; corrected > age {
+			age = corrected
+		}
+	}
+	return age
+}
+
+// responseDate 返回响应的Date头，缺少或格式错误时使用收到响应的时间
+func responseDate(h http.Header, responseTime time.Time) time.Time {
+	if date, err := http.ParseTime(h.Get("Date")); err == nil {
+		return date
+	}
+	return responseTime
+}
+
+// ttl 返回缓存响应的TTL：新鲜期减去已有的年龄，再按MinTTL和MaxTTL限制；
+// 上游没有缓存头时使用DefaultTTL。响应已经不新鲜时返回false，不缓存
+func (p *Proxy) ttl(h http.Header, responseTime time.Time) (time.Duration, bool) {
+	lifetime, ok := freshnessLifetime(h, responseTime)
+	if !ok {
+		return p.opts.DefaultTTL, true
+	}
+
+	ttl := lifetime - initialAge(h, responseTime)
+	if p.opts.MinTTL > 0 && ttl < p.opts.MinTTL {
+		ttl = p.opts.MinTTL
+	}
+	if p.opts.MaxTTL > 0 && ttl > p.opts.MaxTTL {
+		ttl = p.opts.MaxTTL
+	}
+	return ttl, ttl > 0
+}
+
+// policyTTL 上游响应没有缓存头时返回Options.TTLPolicies中第一条匹配请求路径和Content-Type的规则的TTL
+func (p *Proxy) policyTTL(path string, h http.Header, responseTime time.Time) (time.Duration, bool) {
+	if p.ttls == nil {
+		return 0, false
+	}
+	if _, ok := freshnessLifetime(h, responseTime); ok {
+		return 0, false
+	}
+	return p.ttls.TTL(path, h.Get("Content-Type"))
+}
+
+// statusTTLs 解析后的Options.StatusTTL
+type statusTTLs struct {
+	codes   map[int]time.Duration
+	classes map[int]time.Duration // 键为状态码的百位，5表示5xx
+}
+
+// DefaultStatusTTL 返回常用的按状态码的TTL，可以作为Options.StatusTTL的起点：
+// 200为1小时，301为1天，404为30秒，5xx不缓存
+func DefaultStatusTTL() map[string]time.Duration {
+	return map[string]time.Duration{
+		"200": time.Hour,
+		"301": 24 * time.Hour,
+		"404": 30 * time.Second,
+		"5xx": 0,
+	}
+}
+
+// newStatusTTLs 解析Options.StatusTTL，为空时返回nil
+func newStatusTTLs(ttls map[string]time.Duration) (*statusTTLs, error) {
+	if len(ttls) == 0 {
+		return nil, nil
+	}
+	s := &statusTTLs{codes: make(map[int]time.Duration), classes: make(map[int]time.Duration)}
+	for key, ttl := range ttls {
+		if ttl < 0 {
+			return nil, fmt.Errorf("status ttl for %q cannot be negative", key)
+		}
+		name := strings.ToLower(strings.TrimSpace(key))
+		if len(name) == 3 && name[1:] == "xx" && name[0] >= '2' && name[0] <= '5' {
+			s.classes[int(name[0]-'0')] = ttl
+			continue
+		}
+		code, err := strconv.Atoi(name)
+		if err != nil || code < 200 || code > 599 {
+			return nil, fmt.Errorf("invalid status ttl key %q: must be a status code between 200 and 599 or a class such as 5xx", key)
+		}
+		s.codes[code] = ttl
+	}
+	return s, nil
+}
+
+// lookup 返回状态码的TTL，状态码优先于状态类；没有匹配或状态码为304时返回false
+func (s *statusTTLs) lookup(status int) (time.Duration, bool) {
+	if s == nil || status == http.StatusNotModified {
+		return 0, false
+	}
+	if ttl, ok := s.codes[status]; ok {
+		return ttl, true
+	}
+	ttl, ok := s.classes[status/100]
+	return ttl, ok
+}
+
+// statusTTL 按Options.StatusTTL计算状态码不是200或206的响应的TTL：上游有缓存头时按ttl计算，
+// 没有时使用配置的TTL；状态码未列出时返回false
+func (p *Proxy) statusTTL(status int, h http.Header, responseTime time.Time) (time.Duration, bool) {
+	ttl, ok := p.status.lookup(status)
+	if !ok {
+		return 0, false
+	}
+	if _, fresh := freshnessLifetime(h, responseTime); fresh {
+		return p.ttl(h, responseTime)
+	}
+	return ttl, ttl > 0
+}
+
+// cacheTTL 返回请求路径为path的响应写入缓存的TTL，不可缓存时返回false：200和206响应按ttl计算，
+// 上游没有缓存头时依次使用Options.TTLPolicies、StatusTTL和DefaultTTL；
+// Options.NegativeTTL中列出的错误状态码按negativeTTL计算，StatusTTL中列出的其他状态码按statusTTL计算，
+// 缓存的错误响应会标记条目，其他状态码和Vary: *的响应不缓存；
+// 片段不区分变体，带Vary的206响应也不缓存。设置了Options.Streaming时播放列表和分段使用策略中的TTL。
+// 不满足storable的响应不缓存，客户端请求头reqHeader用于判断请求是否带有认证信息。匹配的Options.CacheOverrides规则可以固定TTL、忽略部分缓存头，
+// 并会从e中去掉Set-Cookie
+func (p *Proxy) cacheTTL(e *entry, path string, reqHeader http.Header, responseTime time.Time) (time.Duration, bool) {
+	override := p.override(path)
+	if override != nil && override.StripSetCookie {
+		e.Header.Del("Set-Cookie")
+	}
+	h := override.header(e.Header)
+
+	vary, ok := varyHeaders(e.Header)
+	class := streamNone
+	if p.opts.Streaming != nil {
+		class = p.opts.Streaming.classify(path, e.Header)
+	}
+	switch {
+	case !ok, e.Status == http.StatusPartialContent && len(vary) > 0, !storable(h, p.authenticated(path, reqHeader)):
+		return 0, false
+	case override != nil && override.TTL > 0 && (e.Status == http.StatusOK || e.Status == http.StatusPartialContent):
+		return override.TTL, true
+	case class != streamNone && (e.Status == http.StatusOK || e.Status == http.StatusPartialContent):
+		return p.opts.Streaming.ttl(class), true
+	case e.Status == http.StatusOK, e.Status == http.StatusPartialContent:
+		if ttl, ok := p.policyTTL(path, h, responseTime); ok {
+			return ttl, true
+		}
+		if ttl, ok := p.status.lookup(e.Status); ok {
+			if _, fresh := freshnessLifetime(h, responseTime); !fresh {
+				return ttl, ttl > 0
+			}
+		}
+		return p.ttl(h, responseTime)
+	case class != streamNone:
+		// 直播的播放列表可能引用稍后才出现的分段，流媒体资源的错误响应不缓存
+		return 0, false
+	}
+	ttl, ok := p.negativeTTL(e.Status, h, responseTime)
+	if _, listed := p.opts.NegativeTTL[e.Status]; !listed {
+		ttl, ok = p.statusTTL(e.Status, h, responseTime)
+	}
+	e.Negative = ok && e.Status >= http.StatusBadRequest
+	return ttl, ok
+}
+
+// negativeTTL 返回错误响应的TTL：配置的TTL，上游缓存头给出的剩余新鲜期更短时取后者
+func (p *Proxy) negativeTTL(status int, h http.Header, responseTime time.Time) (time.Duration, bool) {
+	ttl, ok := p.opts.NegativeTTL[status]
+	if !ok {
+		return 0, false
+	}
+	if lifetime, ok := freshnessLifetime(h, responseTime); ok {
+		if remaining := lifetime - initialAge(h, responseTime); remaining < ttl {
+			ttl = remaining
+		}
+	}
+	return ttl, ttl > 0
+}
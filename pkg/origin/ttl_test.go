@@ -0,0 +1,432 @@
+package origin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestTTL(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	date := now.Format(http.TimeFormat)
+	p := &Proxy{opts: Options{DefaultTTL: time.Hour}}
+
+	tests := []struct {
+		name      string
+		header    http.Header
+		want      time.Duration
+		cacheable bool
+	}{
+		{"NoHeaders", http.Header{}, time.Hour, true},
+		{"MaxAge", http.Header{"Cache-Control": {"public, max-age=600"}}, 10 * time.Minute, true},
+		{"SMaxAgeWins", http.Header{"Cache-Control": {"max-age=600, s-maxage=60"}}, time.Minute, true},
+		{"QuotedValue", http.Header{"Cache-Control": {`max-age="120"`}}, 2 * time.Minute, true},
+		{"AgeSubtracted", http.Header{"Cache-Control": {"max-age=600"}, "Age": {"100"}}, 500 * time.Second, true},
+		{"DateSubtracted", http.Header{"Cache-Control": {"max-age=600"}, "Date": {now.Add(-time.Minute).Format(http.TimeFormat)}}, 9 * time.Minute, true},
+		{"Expires", http.Header{"Date": {date}, "Expires": {now.Add(30 * time.Minute).Format(http.TimeFormat)}}, 30 * time.Minute, true},
+		{"MaxAgeOverExpires", http.Header{"Cache-Control": {"max-age=60"}, "Expires": {now.Add(time.Hour).Format(http.TimeFormat)}}, time.Minute, true},
+		{"InvalidExpires", http.Header{"Expires": {"0"}}, 0, false},
+		{"MaxAgeZero", http.Header{"Cache-Control": {"max-age=0"}}, 0, false},
+		{"AlreadyStale", http.Header{"Cache-Control": {"max-age=60"}, "Age": {"120"}}, -time.Minute, false},
+		{"InvalidMaxAge", http.Header{"Cache-Control": {"max-age=abc"}}, time.Hour, true},
+		{"NoCache", http.Header{"Cache-Control": {"no-cache, max-age=600"}}, 0, false},
+		{"NoCacheFields", http.Header{"Cache-Control": {`no-cache="Set-Cookie", max-age=600`}}, 10 * time.Minute, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, cacheable := p.ttl(tt.header, now)
+			if got != tt.want || cacheable != tt.cacheable {
+				t.Errorf("Expected %v (cacheable %v), got %v (cacheable %v)", tt.want, tt.cacheable, got, cacheable)
+			}
+		})
+	}
+
+	t.Run("Clamps", func(t *testing.T) {
+		p := &Proxy{opts: Options{MinTTL: time.Minute, MaxTTL: time.Hour}}
+		if got, ok := p.ttl(http.Header{"Cache-Control": {"max-age=0"}}, now); !ok || got != time.Minute {
+			t.Errorf("Expected min ttl, got %v", got)
+		}
+		if got, _ := p.ttl(http.Header{"Cache-Control": {"max-age=86400"}}, now); got != time.Hour {
+			t.Errorf("Expected max ttl, got %v", got)
+		}
+	})
+}
+
+func TestProxyTTL(t *testing.T) {
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/short":
+			w.Header().Set("Cache-Control", "max-age=120")
+			w.Header().Set("Age", "20")
+		case "/nocache":
+			w.Header().Set("Cache-Control", "max-age=0")
+		}
+		w.Write([]byte("data"))
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{Upstream: upstream.URL, DefaultTTL: time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	get(t, proxy, http.MethodGet, "/short")
+	info, err := cache.GetInfo(context.Background(), "/short")
+	if err != nil {
+		t.Fatalf("Failed to get info: %v", err)
+	}
+	if ttl := info.ExpiresAt.Sub(info.CreatedAt); ttl < 99*time.Second || ttl > 101*time.Second {
+		t.Errorf("Expected ttl of 100s, got %v", ttl)
+	}
+	resp, _ := get(t, proxy, http.MethodGet, "/short")
+	if resp.Header.Get("Age") != "20" {
+		t.Errorf("Expected Age to include upstream age, got %q", resp.Header.Get("Age"))
+	}
+
+	get(t, proxy, http.MethodGet, "/nocache")
+	if exists, _ := cache.Exists(context.Background(), "/nocache"); exists {
+		t.Error("Expected max-age=0 response not to be cached")
+	}
+
+	if _, err := NewProxy(cache, Options{Upstream: upstream.URL, MinTTL: time.Hour, MaxTTL: time.Minute}); err == nil {
+		t.Error("Expected error when min ttl exceeds max ttl")
+	}
+}
+
+func TestTTLPolicies(t *testing.T) {
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/users":
+			w.Header().Set("Content-Type", "application/json")
+		case "/app.css":
+			w.Header().Set("Content-Type", "text/css")
+		case "/headers.css":
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		w.Write([]byte("data"))
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{Upstream: upstream.URL, DefaultTTL: time.Hour, TTLPolicies: []filecache.TTLPolicy{
+		{Glob: "*.css", TTL: 7 * 24 * time.Hour},
+		{MimeType: "application/json", TTL: 30 * time.Second},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want time.Duration
+	}{
+		{"/app.css", 7 * 24 * time.Hour},
+		{"/api/users", 30 * time.Second},
+		{"/index.html", time.Hour},
+		{"/headers.css", time.Minute},
+	}
+	for _, tt := range tests {
+		get(t, proxy, http.MethodGet, tt.path)
+		info, err := cache.GetInfo(context.Background(), tt.path)
+		if err != nil {
+			t.Fatalf("Failed to get info for %s: %v", tt.path, err)
+		}
+		// Date头精确到秒，上游缓存头计算出的TTL可能少1秒
+		if ttl := info.ExpiresAt.Sub(info.CreatedAt); ttl < tt.want-2*time.Second || ttl > tt.want+time.Second {
+			t.Errorf("Expected %s to be cached for %v, got %v", tt.path, tt.want, ttl)
+		}
+	}
+
+	if _, err := NewProxy(cache, Options{Upstream: upstream.URL, TTLPolicies: []filecache.TTLPolicy{{Glob: "*.css"}}}); err == nil {
+		t.Error("Expected error for policy without ttl")
+	}
+}
+
+func TestStatusTTL(t *testing.T) {
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		case "/temporary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			http.Redirect(w, r, "/new", http.StatusFound)
+		case "/missing":
+			http.NotFound(w, r)
+		case "/broken":
+			http.Error(w, "broken", http.StatusInternalServerError)
+		case "/headers":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Write([]byte("data"))
+		default:
+			w.Write([]byte("data"))
+		}
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{Upstream: upstream.URL, DefaultTTL: time.Minute, StatusTTL: DefaultStatusTTL()})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	tests := []struct {
+		path   string
+		status int
+		want   time.Duration // 0表示不缓存
+	}{
+		{"/page", http.StatusOK, time.Hour},
+		{"/headers", http.StatusOK, time.Minute},
+		{"/old", http.StatusMovedPermanently, 24 * time.Hour},
+		{"/temporary", http.StatusFound, 0},
+		{"/missing", http.StatusNotFound, 30 * time.Second},
+		{"/broken", http.StatusInternalServerError, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if resp, _ := get(t, proxy, http.MethodGet, tt.path); resp.StatusCode != tt.status {
+				t.Fatalf("Expected %d, got %d", tt.status, resp.StatusCode)
+			}
+			info, err := cache.GetInfo(context.Background(), tt.path)
+			if tt.want == 0 {
+				if err == nil {
+					t.Errorf("Expected %s not to be cached", tt.path)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to get info for %s: %v", tt.path, err)
+			}
+			if ttl := info.ExpiresAt.Sub(info.CreatedAt); ttl < tt.want-2*time.Second || ttl > tt.want+time.Second {
+				t.Errorf("Expected %s to be cached for %v, got %v", tt.path, tt.want, ttl)
+			}
+			resp, _ := get(t, proxy, http.MethodGet, tt.path)
+			if resp.StatusCode != tt.status || resp.Header.Get("X-Cache") != "HIT" {
+				t.Errorf("Expected cached %d, got %d %q", tt.status, resp.StatusCode, resp.Header.Get("X-Cache"))
+			}
+		})
+	}
+
+	t.Run("Location", func(t *testing.T) {
+		resp, _ := get(t, proxy, http.MethodGet, "/old")
+		if resp.Header.Get("Location") != "/new" {
+			t.Errorf("Expected cached redirect to /new, got %q", resp.Header.Get("Location"))
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, statusTTL := range []map[string]time.Duration{
+			{"6xx": time.Minute},
+			{"1xx": time.Minute},
+			{"199": time.Minute},
+			{"ok": time.Minute},
+			{"404": -time.Second},
+		} {
+			if _, err := NewProxy(cache, Options{Upstream: upstream.URL, StatusTTL: statusTTL}); err == nil {
+				t.Errorf("Expected error for status ttl %v", statusTTL)
+			}
+		}
+	})
+}
+
+func TestUnstorableResponses(t *testing.T) {
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch path.Base(r.URL.Path) {
+		case "no-store":
+			w.Header().Set("Cache-Control", "no-store, max-age=60")
+		case "private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		case "cookie":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Set-Cookie", "session=1")
+		}
+		w.Write([]byte("data"))
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{
+		Upstream:   upstream.URL,
+		DefaultTTL: time.Hour,
+		MinTTL:     time.Minute,
+		CacheOverrides: []CacheOverride{
+			{Path: "/forced/*", TTL: time.Hour},
+			{Path: "/ignored/*", IgnoreDirectives: []string{"no-store", "private"}, StripSetCookie: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	tests := []struct {
+		path   string
+		cached bool
+	}{
+		{"/no-store", false},
+		{"/private", false},
+		{"/cookie", false},
+		{"/forced/no-store", false},
+		{"/forced/cookie", false},
+		{"/ignored/no-store", true},
+		{"/ignored/private", true},
+		{"/ignored/cookie", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			before := upstream.requests.Load()
+			get(t, proxy, http.MethodGet, tt.path)
+			resp, _ := get(t, proxy, http.MethodGet, tt.path)
+			if cached := resp.Header.Get("X-Cache") == "HIT"; cached != tt.cached {
+				t.Errorf("Expected cached %v, got X-Cache %q", tt.cached, resp.Header.Get("X-Cache"))
+			}
+			want := int64(2)
+			if tt.cached {
+				want = 1
+			}
+			if n := upstream.requests.Load() - before; n != want {
+				t.Errorf("Expected %d upstream requests, got %d", want, n)
+			}
+		})
+	}
+
+	t.Run("SetCookieForwarded", func(t *testing.T) {
+		if resp, _ := get(t, proxy, http.MethodGet, "/cookie"); resp.Header.Get("Set-Cookie") != "session=1" {
+			t.Errorf("Expected uncached response to keep Set-Cookie, got %q", resp.Header.Get("Set-Cookie"))
+		}
+	})
+}
+
+func TestAuthenticatedRequests(t *testing.T) {
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch path.Base(r.URL.Path) {
+		case "public":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		case "s-maxage":
+			w.Header().Set("Cache-Control", "s-maxage=60")
+		default:
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		w.Write([]byte("user data"))
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{
+		Upstream:   upstream.URL,
+		DefaultTTL: time.Hour,
+		Headers: []HeaderRule{
+			{Path: "/stripped/*", Request: &HeaderActions{Remove: []string{"Cookie"}}},
+			{Path: "/origin-auth/*", Request: &HeaderActions{Set: map[string]string{"Authorization": "Bearer origin-token"}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	tests := []struct {
+		path   string
+		header string
+		cached bool
+	}{
+		{"/stripped/plain", "Cookie", true},
+		{"/origin-auth/plain", "Authorization", true},
+		{"/auth/plain", "Authorization", false},
+		{"/cookie/plain", "Cookie", false},
+		{"/auth/public", "Authorization", true},
+		{"/auth/s-maxage", "Authorization", true},
+		{"/cookie/public", "Cookie", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set(tt.header, "secret")
+			proxy.ServeHTTP(httptest.NewRecorder(), req)
+
+			// 之后的匿名请求只能命中明确允许共享的响应
+			before := upstream.requests.Load()
+			resp, _ := get(t, proxy, http.MethodGet, tt.path)
+			if cached := resp.Header.Get("X-Cache") == "HIT"; cached != tt.cached {
+				t.Errorf("Expected cached %v, got X-Cache %q", tt.cached, resp.Header.Get("X-Cache"))
+			}
+			want := int64(1)
+			if tt.cached {
+				want = 0
+			}
+			if n := upstream.requests.Load() - before; n != want {
+				t.Errorf("Expected %d upstream requests, got %d", want, n)
+			}
+		})
+	}
+}
+
+func TestNegativeCaching(t *testing.T) {
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gone":
+			w.Header().Set("Cache-Control", "max-age=30")
+			http.Error(w, "gone", http.StatusGone)
+		case "/broken":
+			http.Error(w, "broken", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{
+		Upstream:    upstream.URL,
+		NegativeTTL: map[int]time.Duration{http.StatusNotFound: time.Minute, http.StatusGone: time.Hour},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	t.Run("NotFound", func(t *testing.T) {
+		before := upstream.requests.Load()
+		resp, _ := get(t, proxy, http.MethodGet, "/missing.png")
+		if resp.StatusCode != http.StatusNotFound || resp.Header.Get("X-Cache") != "MISS" {
+			t.Fatalf("Expected 404 MISS, got %d %q", resp.StatusCode, resp.Header.Get("X-Cache"))
+		}
+		resp, body := get(t, proxy, http.MethodGet, "/missing.png")
+		if resp.StatusCode != http.StatusNotFound || resp.Header.Get("X-Cache") != "HIT" || body != "404 page not found\n" {
+			t.Errorf("Expected cached 404, got %d %q %q", resp.StatusCode, resp.Header.Get("X-Cache"), body)
+		}
+		if n := upstream.requests.Load() - before; n != 1 {
+			t.Errorf("Expected 1 upstream request, got %d", n)
+		}
+		info, err := cache.GetInfo(context.Background(), "/missing.png")
+		if err != nil {
+			t.Fatalf("Failed to get info: %v", err)
+		}
+		if ttl := info.ExpiresAt.Sub(info.CreatedAt); ttl < 59*time.Second || ttl > 61*time.Second {
+			t.Errorf("Expected negative ttl of 1m, got %v", ttl)
+		}
+	})
+
+	t.Run("UpstreamShorter", func(t *testing.T) {
+		get(t, proxy, http.MethodGet, "/gone")
+		info, err := cache.GetInfo(context.Background(), "/gone")
+		if err != nil {
+			t.Fatalf("Failed to get info: %v", err)
+		}
+		if ttl := info.ExpiresAt.Sub(info.CreatedAt); ttl < 29*time.Second || ttl > 31*time.Second {
+			t.Errorf("Expected upstream ttl of 30s, got %v", ttl)
+		}
+	})
+
+	t.Run("UnlistedStatus", func(t *testing.T) {
+		resp, _ := get(t, proxy, http.MethodGet, "/broken")
+		if resp.StatusCode != http.StatusInternalServerError {
+			t.Fatalf("Expected 500, got %d", resp.StatusCode)
+		}
+		if exists, _ := cache.Exists(context.Background(), "/broken"); exists {
+			t.Error("Expected unlisted status not to be cached")
+		}
+	})
+
+	for _, ttl := range []map[int]time.Duration{{http.StatusOK: time.Minute}, {http.StatusNotFound: 0}} {
+		if _, err := NewProxy(cache, Options{Upstream: upstream.URL, NegativeTTL: ttl}); err == nil {
+			t.Errorf("Expected error for negative ttl %v", ttl)
+		}
+	}
+}
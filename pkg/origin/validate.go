@@ -0,0 +1,168 @@
+package origin
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/seraphico/EdgeOrigin/internal/validate"
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// Validate 检查选项，不建立回源连接也不启动健康检查，供CI和命令行在部署前检查配置；
+// 返回的错误列出所有问题，每个问题以字段路径开头，例如"cache_overrides[1].path: ..."。
+// NewProxy创建代理之前先调用它
+func (o *Options) Validate() error {
+	var errs validate.Errors
+	if o.DefaultTTL < 0 {
+		errs.Addf("default_ttl", "cannot be negative")
+	}
+	if o.MinTTL < 0 {
+		errs.Addf("min_ttl", "cannot be negative")
+	}
+	if o.MaxTTL < 0 {
+		errs.Addf("max_ttl", "cannot be negative")
+	}
+	if o.MaxTTL > 0 && o.MinTTL > o.MaxTTL {
+		errs.Addf("min_ttl", "%v exceeds max ttl %v", o.MinTTL, o.MaxTTL)
+	}
+	if o.SliceSize < 0 {
+		errs.Addf("slice_size", "cannot be negative")
+	}
+	if o.StaleWhileRevalidate < 0 {
+		errs.Addf("stale_while_revalidate", "cannot be negative")
+	}
+	o.validateUpstreams(&errs)
+
+	// 各部分的validate会填充默认值，在副本上检查
+	if o.Retry != nil {
+		retry := *o.Retry
+		errs.Add("retry", retry.validate())
+	}
+	if o.Timeouts != nil {
+		errs.Add("timeouts", o.Timeouts.validate())
+	}
+	if o.Pool != nil {
+		errs.Add("pool", o.Pool.validate())
+	}
+	if o.ForwardProxy != nil {
+		_, err := o.ForwardProxy.proxyFunc()
+		errs.Add("forward_proxy", err)
+	}
+	if o.DNS != nil {
+		_, err := o.DNS.newCache()
+		errs.Add("dns", err)
+	}
+	if o.Key != nil {
+		errs.Add("key", o.Key.validate())
+	}
+	if o.Signing != nil {
+		errs.Add("signing", o.Signing.validate())
+	}
+	if o.Purge != nil {
+		purge := *o.Purge
+		errs.Add("purge", purge.validate())
+	}
+	if o.Compression != nil {
+		compression := *o.Compression
+		compression.Encodings = append([]string(nil), compression.Encodings...)
+		errs.Add("compression", compression.validate())
+	}
+	if o.Images != nil {
+		images := *o.Images
+		errs.Add("images", images.validate())
+	}
+	if o.Streaming != nil {
+		streaming := *o.Streaming
+		errs.Add("streaming", streaming.validate())
+	}
+	if o.ESI != nil {
+		esi := *o.ESI
+		errs.Add("esi", esi.validate())
+	}
+	for i := range o.Rewrites {
+		rule := o.Rewrites[i]
+		errs.Add(validate.Index("rewrites", i), rule.compile())
+	}
+	for i := range o.Headers {
+		errs.Add(validate.Index("headers", i), o.Headers[i].validate())
+	}
+	for i := range o.CacheOverrides {
+		errs.Add(validate.Index("cache_overrides", i), o.CacheOverrides[i].validate())
+	}
+	if _, err := filecache.NewTTLPolicySet(o.TTLPolicies); err != nil {
+		errs.Add("ttl_policies", err)
+	}
+	if _, err := newStatusTTLs(o.StatusTTL); err != nil {
+		errs.Add("status_ttl", err)
+	}
+
+	statuses := make([]int, 0, len(o.NegativeTTL))
+	for status := range o.NegativeTTL {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		path := validate.Key("negative_ttl", status)
+		if status < 400 || status > 599 {
+			errs.Addf(path, "invalid status %d: must be 4xx or 5xx", status)
+		} else if o.NegativeTTL[status] <= 0 {
+			errs.Addf(path, "ttl must be positive")
+		}
+	}
+	return errs.Err()
+}
+
+// validateUpstreams 检查Upstream、Upstreams和健康检查，设置了Fetcher时不使用它们
+func (o *Options) validateUpstreams(errs *validate.Errors) {
+	if o.Fetcher != nil {
+		return
+	}
+	if o.UpstreamTimeout < 0 {
+		errs.Addf("upstream_timeout", "cannot be negative")
+	}
+	if o.HealthCheck != nil {
+		health := *o.HealthCheck
+		errs.Add("health_check", health.validate())
+	}
+	if len(o.Upstreams) == 0 {
+		if _, err := parseUpstream(o.Upstream); err != nil {
+			errs.Add("upstream", err)
+		}
+		return
+	}
+	if o.Upstream != "" {
+		errs.Addf("upstreams", "upstream and upstreams cannot both be set")
+	}
+	for i, target := range o.Upstreams {
+		path := validate.Index("upstreams", i)
+		if target.Weight < 0 {
+			errs.Addf(validate.Join(path, "weight"), "cannot be negative")
+		}
+		if target.Fetcher != nil {
+			continue
+		}
+		if _, err := parseUpstream(target.URL); err != nil {
+			errs.Add(validate.Join(path, "url"), err)
+		}
+		if timeouts := target.Timeouts.merge(o.Timeouts); timeouts != nil {
+			errs.Add(validate.Join(path, "timeouts"), timeouts.validate())
+		}
+	}
+}
+
+// ValidateSites 检查站点，不创建Proxy；返回的错误列出所有问题，路径相对于站点列表，例如"[1].options.upstream: ..."
+func ValidateSites(sites []Site) error {
+	var errs validate.Errors
+	table := &routes{exact: make(map[string]*Proxy)}
+	for i, site := range sites {
+		path := validate.Index("", i)
+		if len(site.Hosts) == 0 {
+			errs.Addf(validate.Join(path, "hosts"), "site has no hosts")
+		}
+		for j, host := range site.Hosts {
+			errs.Add(validate.Index(validate.Join(path, "hosts"), j), table.add(strings.ToLower(host), &Proxy{}))
+		}
+		errs.Add(validate.Join(path, "options"), site.Options.Validate())
+	}
+	return errs.Err()
+}
@@ -0,0 +1,110 @@
+package origin
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// variantSeparator 变体键 = 基础键 + variantSeparator + 请求头摘要；请求URI中不会出现"#"
+const variantSeparator = "#vary:"
+
+// varyHeaders 返回响应Vary头中的请求头名称（规范形式，排序去重），Vary: *时返回false
+func varyHeaders(h http.Header) ([]string, bool) {
+	seen := make(map[string]bool)
+	var names []string
+	for _, line := range h.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return nil, false
+			}
+			if name == "" {
+				continue
+			}
+			name = http.CanonicalHeaderKey(name)
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, true
+}
+
+// variantKey 返回请求在vary列出的请求头上对应的变体键
+func variantKey(key string, vary []string, h http.Header) string {
+	sum := sha256.New()
+	for _, name := range vary {
+		sum.Write([]byte(name))
+		sum.Write([]byte{0})
+		sum.Write([]byte(normalizeHeader(name, h.Values(name))))
+		sum.Write([]byte{0})
+	}
+	return key + variantSeparator + hex.EncodeToString(sum.Sum(nil)[:12])
+}
+
+// normalizeHeader 规范化请求头的值：去掉空白并转为小写；Accept-Encoding只保留接受的编码名称，
+// 排序后去掉q值等参数，避免客户端写法不同产生大量相同内容的变体
+func normalizeHeader(name string, values []string) string {
+	if name != "Accept-Encoding" {
+		normalized := make([]string, len(values))
+		for i, v := range values {
+			normalized[i] = strings.ToLower(strings.Join(strings.Fields(v), " "))
+		}
+		return strings.Join(normalized, ",")
+	}
+
+	var codings []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			coding, params, _ := strings.Cut(part, ";")
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if coding == "" || rejected(params) {
+				continue
+			}
+			codings = append(codings, coding)
+		}
+	}
+	sort.Strings(codings)
+	out := codings[:0]
+	for i, coding := range codings {
+		if i == 0 || coding != codings[i-1] {
+			out = append(out, coding)
+		}
+	}
+	return strings.Join(out, ",")
+}
+
+// rejected 返回参数中是否有q=0
+func rejected(params string) bool {
+	for _, param := range strings.Split(params, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if strings.EqualFold(name, "q") {
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			return err == nil && q == 0
+		}
+	}
+	return false
+}
+
+// storeResponse 写入响应；响应带有Vary时基础键上写入列出请求头的标记，响应写入请求对应的变体键
+func (p *Proxy) storeResponse(ctx context.Context, key string, reqHeader http.Header, e *entry, body io.Reader, ttl time.Duration) error {
+	vary, _ := varyHeaders(e.Header)
+	if len(vary) > 0 {
+		marker := &entry{Vary: vary, StoredAt: e.StoredAt}
+		if err := p.store(ctx, key, marker, bytes.NewReader(nil), ttl); err != nil {
+			return err
+		}
+		key = variantKey(key, vary, reqHeader)
+	}
+	return p.store(ctx, key, e, body, ttl)
+}
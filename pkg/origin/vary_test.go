@@ -0,0 +1,97 @@
+package origin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestVary(t *testing.T) {
+	upstream := newTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/any" {
+			w.Header().Set("Vary", "*")
+		} else {
+			w.Header().Set("Vary", "Accept-Encoding")
+		}
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write([]byte("gzipped"))
+			return
+		}
+		w.Write([]byte("plain"))
+	})
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := NewProxy(cache, Options{Upstream: upstream.URL})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	request := func(path, acceptEncoding string) (string, string) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		return rec.Body.String(), rec.Header().Get("X-Cache")
+	}
+
+	tests := []struct {
+		acceptEncoding, body, cache string
+	}{
+		{"gzip", "gzipped", "MISS"},
+		{"gzip", "gzipped", "HIT"},
+		{"", "plain", "MISS"},
+		{"", "plain", "HIT"},
+		{" GZIP ;q=1.0", "gzipped", "HIT"},
+		{"gzip;q=0", "plain", "HIT"},
+		{"gzip, br", "gzipped", "MISS"},
+		{"br,gzip", "gzipped", "HIT"},
+	}
+	for _, tt := range tests {
+		body, status := request("/app.js", tt.acceptEncoding)
+		if body != tt.body || status != tt.cache {
+			t.Errorf("Accept-Encoding %q: expected %q %s, got %q %s", tt.acceptEncoding, tt.body, tt.cache, body, status)
+		}
+	}
+	if n := upstream.requests.Load(); n != 3 {
+		t.Errorf("Expected 3 upstream requests, got %d", n)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, status := request("/any", "gzip"); status != "MISS" {
+			t.Errorf("Expected Vary: * not to be cached, got %s", status)
+		}
+	}
+	if exists, _ := cache.Exists(context.Background(), "/any"); exists {
+		t.Error("Expected no entry for Vary: *")
+	}
+}
+
+func TestNormalizeHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   string
+	}{
+		{"Accept-Encoding", []string{"gzip, deflate, br"}, "br,deflate,gzip"},
+		{"Accept-Encoding", []string{"br;q=1.0", "GZIP"}, "br,gzip"},
+		{"Accept-Encoding", []string{"gzip;q=0, identity"}, "identity"},
+		{"Accept-Encoding", []string{"gzip, gzip"}, "gzip"},
+		{"Accept-Language", []string{"en-US,  en;q=0.9"}, "en-us, en;q=0.9"},
+	}
+	for _, tt := range tests {
+		if got := normalizeHeader(tt.name, tt.values); got != tt.want {
+			t.Errorf("normalizeHeader(%s, %q): expected %q, got %q", tt.name, tt.values, tt.want, got)
+		}
+	}
+
+	if names, ok := varyHeaders(http.Header{"Vary": {"accept-encoding, Accept-Language", "Accept-Encoding"}}); !ok || strings.Join(names, ",") != "Accept-Encoding,Accept-Language" {
+		t.Errorf("Unexpected vary headers: %v", names)
+	}
+}
@@ -0,0 +1,289 @@
+package replication
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/seraphico/EdgeOrigin/internal/grpcwire"
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+const (
+	defaultTreeTTL = 30 * time.Second
+	// bucketsBatchSize 每次请求的叶子数
+	bucketsBatchSize = 256
+	// fetchBatchSize 每次拉取的文件数
+	fetchBatchSize = 100
+)
+
+// AntiEntropyOptions 反熵服务选项
+type AntiEntropyOptions struct {
+	TreeTTL   time.Duration // Merkle树的缓存时间，同一次比较中的多次请求共用一棵树，默认30秒
+	ChunkSize int           // 推送文件数据的分片大小，默认1MB
+}
+
+// AntiEntropyServer 向其他节点提供本地缓存的Merkle树和文件，供其比较并修复差异
+type AntiEntropyServer struct {
+	cache filecache.Cache
+	opts  AntiEntropyOptions
+
+	mu    sync.Mutex
+	trees map[string]*cachedTree
+}
+
+// cachedTree 缓存的Merkle树
+type cachedTree struct {
+	tree    *MerkleTree
+	builtAt time.Time
+}
+
+// NewAntiEntropyServer 创建反熵服务
+func NewAntiEntropyServer(cache filecache.Cache, opts AntiEntropyOptions) *AntiEntropyServer {
+	if opts.TreeTTL <= 0 {
+		opts.TreeTTL = defaultTreeTTL
+	}
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = defaultChunkSize
+	}
+	return &AntiEntropyServer{
+		cache: cache,
+		opts:  opts,
+		trees: make(map[string]*cachedTree),
+	}
+}
+
+// Register 在gRPC服务器上注册反熵服务
+func (s *AntiEntropyServer) Register(r grpc.ServiceRegistrar) {
+	r.RegisterService(&antiEntropyServiceDesc, s)
+}
+
+// tree 返回命名空间的Merkle树，超过TreeTTL后重新构建
+func (s *AntiEntropyServer) tree(ctx context.Context, path []string, depth int) (*MerkleTree, error) {
+	if depth < 0 || depth > MaxTreeDepth {
+		return nil, status.Errorf(codes.InvalidArgument, "merkle tree depth must be between 0 and %d", MaxTreeDepth)
+	}
+	key := namespaceKey(path) + "\x01" + strconv.Itoa(depth)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, cached := range s.trees {
+		if now.Sub(cached.builtAt) >= s.opts.TreeTTL {
+			delete(s.trees, k)
+		}
+	}
+	if cached, ok := s.trees[key]; ok {
+		return cached.tree, nil
+	}
+
+	tree, err := BuildMerkleTree(ctx, resolve(s.cache, path), depth)
+	if err != nil {
+		return nil, err
+	}
+	s.trees[key] = &cachedTree{tree: tree, builtAt: now}
+	return tree, nil
+}
+
+func (s *AntiEntropyServer) nodes(ctx context.Context, req *NodesRequest) (*NodesResponse, error) {
+	if req.Level < 0 || req.Level > req.Depth {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid merkle tree level %d", req.Level)
+	}
+	for _, index := range req.Indexes {
+		if index < 0 || index >= 1<<req.Level {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid merkle tree node %d at level %d", index, req.Level)
+		}
+	}
+	tree, err := s.tree(ctx, req.Namespace, req.Depth)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes, err := tree.nodes(ctx, req.Level, req.Indexes)
+	if err != nil {
+		return nil, err
+	}
+	return &NodesResponse{Hashes: hashes}, nil
+}
+
+func (s *AntiEntropyServer) buckets(ctx context.Context, req *BucketsRequest) (*BucketsResponse, error) {
+	tree, err := s.tree(ctx, req.Namespace, req.Depth)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &BucketsResponse{}
+	for _, leaf := range req.Leaves {
+		if leaf < 0 || leaf >= len(tree.buckets) {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid merkle tree leaf %d", leaf)
+		}
+		resp.Entries = append(resp.Entries, tree.Bucket(leaf)...)
+	}
+	return resp, nil
+}
+
+func (s *AntiEntropyServer) fetch(req *FetchRequest, ss grpc.ServerStream) error {
+	ctx := ss.Context()
+	cache := resolve(s.cache, req.Namespace)
+	for _, key := range req.Keys {
+		if err := sendFile(ctx, ss, cache, s.opts.ChunkSize, 0, req.Namespace, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RepairOptions 修复选项
+type RepairOptions struct {
+	Namespace []string // 要比较的命名空间路径，为空表示根命名空间
+	Depth     int      // Merkle树深度，两端必须一致，默认DefaultTreeDepth
+	KeepExtra bool     // 保留只在本地存在的文件，默认删除使本地与远端一致
+}
+
+// RepairResult 修复结果
+type RepairResult struct {
+	DivergentLeaves int `json:"divergent_leaves"` // 哈希不同的叶子数
+	Fetched         int `json:"fetched"`          // 从远端拉取的文件数
+	Deleted         int `json:"deleted"`          // 删除的本地文件数
+}
+
+// Repair 与远端节点比较Merkle树，只拉取哈希不同的键范围中缺失或内容不同的文件
+// 修复是单向的，local被修改为与远端一致；双向修复需要在两端各执行一次
+func Repair(ctx context.Context, local filecache.Cache, conn grpc.ClientConnInterface, opts RepairOptions) (*RepairResult, error) {
+	if opts.Depth == 0 {
+		opts.Depth = DefaultTreeDepth
+	}
+
+	cache := resolve(local, opts.Namespace)
+	tree, err := BuildMerkleTree(ctx, cache, opts.Depth)
+	if err != nil {
+		return nil, err
+	}
+
+	remote := &remoteTree{conn: conn, namespace: opts.Namespace, depth: opts.Depth}
+	leaves, err := divergentLeaves(ctx, tree, remote, opts.Depth)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RepairResult{DivergentLeaves: len(leaves)}
+	for start := 0; start < len(leaves); start += bucketsBatchSize {
+		end := start + bucketsBatchSize
+		if end > len(leaves) {
+			end = len(leaves)
+		}
+
+		remoteEntries, err := remote.buckets(ctx, leaves[start:end])
+		if err != nil {
+			return result, err
+		}
+		remoteDigests := make(map[string][]byte, len(remoteEntries))
+		for _, e := range remoteEntries {
+			remoteDigests[e.Key] = e.Digest
+		}
+
+		var missing []string
+		localKeys := make(map[string]bool)
+		for _, leaf := range leaves[start:end] {
+			for _, e := range tree.Bucket(leaf) {
+				localKeys[e.Key] = true
+				digest, ok := remoteDigests[e.Key]
+				switch {
+				case !ok && !opts.KeepExtra:
+					if err := cache.Delete(ctx, e.Key); err != nil && !errors.Is(err, filecache.ErrNotFound) {
+						return result, fmt.Errorf("failed to delete %s: %w", e.Key, err)
+					}
+					result.Deleted++
+				case ok && !bytes.Equal(digest, e.Digest):
+					missing = append(missing, e.Key)
+				}
+			}
+		}
+		for _, e := range remoteEntries {
+			if !localKeys[e.Key] {
+				missing = append(missing, e.Key)
+			}
+		}
+
+		fetched, err := remote.fetch(ctx, cache, missing)
+		result.Fetched += fetched
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// remoteTree 通过gRPC访问远端节点的Merkle树
+type remoteTree struct {
+	conn      grpc.ClientConnInterface
+	namespace []string
+	depth     int
+}
+
+func (r *remoteTree) nodes(ctx context.Context, level int, indexes []int) ([][]byte, error) {
+	req := &NodesRequest{Namespace: r.namespace, Depth: r.depth, Level: level, Indexes: indexes}
+	resp := new(NodesResponse)
+	if err := r.conn.Invoke(ctx, nodesMethodName, req, resp, grpcwire.CallOption()); err != nil {
+		return nil, fmt.Errorf("failed to read remote merkle tree: %w", err)
+	}
+	if len(resp.Hashes) != len(indexes) {
+		return nil, fmt.Errorf("remote returned %d hashes for %d nodes", len(resp.Hashes), len(indexes))
+	}
+	return resp.Hashes, nil
+}
+
+func (r *remoteTree) buckets(ctx context.Context, leaves []int) ([]TreeEntry, error) {
+	req := &BucketsRequest{Namespace: r.namespace, Depth: r.depth, Leaves: leaves}
+	resp := new(BucketsResponse)
+	if err := r.conn.Invoke(ctx, bucketsMethodName, req, resp, grpcwire.CallOption()); err != nil {
+		return nil, fmt.Errorf("failed to read remote merkle tree: %w", err)
+	}
+	return resp.Entries, nil
+}
+
+// fetch 从远端拉取文件写入cache，返回写入的文件数
+func (r *remoteTree) fetch(ctx context.Context, cache filecache.Cache, keys []string) (int, error) {
+	fetched := 0
+	for start := 0; start < len(keys); start += fetchBatchSize {
+		end := start + fetchBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		stream, err := r.conn.NewStream(ctx, &antiEntropyServiceDesc.Streams[0], fetchMethodName, grpcwire.CallOption())
+		if err != nil {
+			return fetched, fmt.Errorf("failed to fetch files: %w", err)
+		}
+		if err := stream.SendMsg(&FetchRequest{Namespace: r.namespace, Keys: keys[start:end]}); err != nil {
+			return fetched, fmt.Errorf("failed to fetch files: %w", err)
+		}
+		if err := stream.CloseSend(); err != nil {
+			return fetched, fmt.Errorf("failed to fetch files: %w", err)
+		}
+
+		err = recvOperations(stream, func(op *Operation) error {
+			if op.Type != OpSet {
+				return fmt.Errorf("unexpected operation %d in fetch", op.Type)
+			}
+			if err := applySet(ctx, cache, op); err != nil {
+				return err
+			}
+			fetched++
+			return nil
+		})
+		if err != nil {
+			return fetched, fmt.Errorf("failed to fetch files: %w", err)
+		}
+	}
+	return fetched, nil
+}
@@ -0,0 +1,175 @@
+package replication
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestMerkleTree(t *testing.T) {
+	ctx := context.Background()
+
+	a := filecache.NewMemoryCache(1 << 20)
+	defer a.Close()
+	b := filecache.NewMemoryCache(1 << 20)
+	defer b.Close()
+
+	for i := 0; i < 50; i++ {
+		key := "file-" + string(rune('a'+i%26)) + strings.Repeat("x", i)
+		for _, cache := range []filecache.Cache{a, b} {
+			if err := cache.Set(ctx, key, strings.NewReader(key), "text/plain", time.Hour); err != nil {
+				t.Fatalf("Failed to set file: %v", err)
+			}
+		}
+	}
+
+	build := func(cache filecache.Cache) *MerkleTree {
+		t.Helper()
+		tree, err := BuildMerkleTree(ctx, cache, 6)
+		if err != nil {
+			t.Fatalf("Failed to build tree: %v", err)
+		}
+		return tree
+	}
+
+	t.Run("Identical", func(t *testing.T) {
+		leaves, err := DiffLeaves(build(a), build(b))
+		if err != nil {
+			t.Fatalf("Failed to diff trees: %v", err)
+		}
+		if len(leaves) != 0 {
+			t.Errorf("Expected no divergent leaves, got %v", leaves)
+		}
+	})
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		build(a)
+		info, err := a.GetInfo(ctx, "file-a")
+		if err != nil {
+			t.Fatalf("Failed to get info: %v", err)
+		}
+		if info.AccessCount != 0 {
+			t.Errorf("Expected building the tree not to count as an access, got %d", info.AccessCount)
+		}
+		if stats, _ := a.Stats(); stats.HitRate != 0 || stats.MissRate != 0 {
+			t.Errorf("Expected building the tree not to change the hit rate, got %+v", stats)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		c := filecache.NewMemoryCache(1 << 20)
+		defer c.Close()
+		empty := build(c)
+		if err := c.Set(ctx, "short", strings.NewReader("data"), "text/plain", 10*time.Millisecond); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+		if leaves, _ := DiffLeaves(empty, build(c)); len(leaves) != 0 {
+			t.Errorf("Expected expired files to be left out, got divergent leaves %v", leaves)
+		}
+	})
+
+	t.Run("Divergent", func(t *testing.T) {
+		if err := b.Set(ctx, "file-a", strings.NewReader("changed"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		leaves, err := DiffLeaves(build(a), build(b))
+		if err != nil {
+			t.Fatalf("Failed to diff trees: %v", err)
+		}
+		if len(leaves) != 1 || leaves[0] != leafIndex("file-a", 6) {
+			t.Errorf("Expected only the leaf of file-a to diverge, got %v", leaves)
+		}
+	})
+
+	t.Run("DepthMismatch", func(t *testing.T) {
+		shallow, err := BuildMerkleTree(ctx, a, 2)
+		if err != nil {
+			t.Fatalf("Failed to build tree: %v", err)
+		}
+		if _, err := DiffLeaves(build(a), shallow); err == nil {
+			t.Error("Expected error for trees of different depth")
+		}
+	})
+}
+
+func TestRepair(t *testing.T) {
+	ctx := context.Background()
+
+	remoteCache := filecache.NewMemoryCache(1 << 20)
+	defer remoteCache.Close()
+	local := filecache.NewMemoryCache(1 << 20)
+	defer local.Close()
+
+	server := NewAntiEntropyServer(remoteCache, AntiEntropyOptions{ChunkSize: 3})
+	conn := startServer(t, server.Register)
+
+	set := func(cache filecache.Cache, key, value string) {
+		t.Helper()
+		if err := cache.Set(ctx, key, strings.NewReader(value), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+	}
+	for _, key := range []string{"a", "b", "c", "d"} {
+		set(remoteCache, key, key+key)
+		set(local, key, key+key)
+	}
+	set(remoteCache.Namespace("site-a"), "e", "eee")
+
+	// 网络分区期间两端产生了分歧
+	set(remoteCache, "changed", "remote version")
+	set(local, "changed", "local version")
+	set(remoteCache, "missing", "only on remote")
+	set(local, "extra", "only on local")
+
+	t.Run("Repair", func(t *testing.T) {
+		result, err := Repair(ctx, local, conn, RepairOptions{Depth: 8})
+		if err != nil {
+			t.Fatalf("Failed to repair: %v", err)
+		}
+		if result.Fetched != 2 || result.Deleted != 1 {
+			t.Errorf("Unexpected repair result: %+v", result)
+		}
+		if got := content(local, "changed"); got != "remote version" {
+			t.Errorf("Expected remote version, got %q", got)
+		}
+		if got := content(local, "missing"); got != "only on remote" {
+			t.Errorf("Expected missing file to be fetched, got %q", got)
+		}
+		if exists, _ := local.Exists(ctx, "extra"); exists {
+			t.Error("Expected extra file to be deleted")
+		}
+	})
+
+	t.Run("Converged", func(t *testing.T) {
+		result, err := Repair(ctx, local, conn, RepairOptions{Depth: 8})
+		if err != nil {
+			t.Fatalf("Failed to repair: %v", err)
+		}
+		if result.DivergentLeaves != 0 {
+			t.Errorf("Expected converged trees, got %+v", result)
+		}
+	})
+
+	t.Run("Namespace", func(t *testing.T) {
+		result, err := Repair(ctx, local, conn, RepairOptions{Namespace: []string{"site-a"}, KeepExtra: true})
+		if err != nil {
+			t.Fatalf("Failed to repair: %v", err)
+		}
+		if result.Fetched != 1 {
+			t.Errorf("Unexpected repair result: %+v", result)
+		}
+		if got := content(local.Namespace("site-a"), "e"); got != "eee" {
+			t.Errorf("Expected 'eee', got %q", got)
+		}
+	})
+
+	t.Run("InvalidDepth", func(t *testing.T) {
+		if _, err := Repair(ctx, local, conn, RepairOptions{Depth: MaxTreeDepth + 1}); err == nil {
+			t.Error("Expected error for invalid depth")
+		}
+	})
+}
@@ -0,0 +1,311 @@
+package replication
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/seraphico/EdgeOrigin/internal/grpcwire"
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+	"github.com/seraphico/EdgeOrigin/pkg/server/tlsutil"
+)
+
+const (
+	defaultRetryInterval    = time.Second
+	maxRetryInterval        = 30 * time.Second
+	defaultCursorSyncPeriod = time.Second
+)
+
+// FollowerOptions 从节点选项
+type FollowerOptions struct {
+	Name             string        // 从节点名称，上报给主节点
+	CursorFile       string        // 保存游标的文件，为空时游标只保存在内存中，重启后全量同步
+	CursorSyncPeriod time.Duration // 游标写入文件的最小间隔，默认1秒
+	RetryInterval    time.Duration // 断线后的初始重连间隔，每次失败翻倍，最长30秒，默认1秒
+	OnError          func(error)   // 同步中断时回调，可用于记录日志
+}
+
+// FollowerStatus 从节点状态
+type FollowerStatus struct {
+	Cursor      Cursor    `json:"cursor"`       // 当前游标
+	Connected   bool      `json:"connected"`    // 是否正在接收主节点推送
+	Applied     int64     `json:"applied"`      // 已应用的操作数
+	Resyncs     int64     `json:"resyncs"`      // 全量同步次数
+	LastApplied time.Time `json:"last_applied"` // 最后一次应用操作的时间
+}
+
+// Follower 从主节点拉取操作并应用到本地缓存
+type Follower struct {
+	cache filecache.Cache
+	conn  grpc.ClientConnInterface
+	opts  FollowerOptions
+
+	mu         sync.Mutex
+	status     FollowerStatus
+	savedAt    time.Time
+	namespaces map[string]filecache.Cache
+}
+
+// Dial 连接addr上的主节点，cfg不为空时使用TLS，设置CertFile和KeyFile时向主节点出示证书（双向TLS）；
+// 为空时使用不加密的连接。返回的连接可用于NewFollower和Repair
+func Dial(addr string, cfg *tlsutil.Config, dialOpts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if cfg != nil {
+		var err error
+		if creds, err = cfg.ClientCredentials(); err != nil {
+			return nil, fmt.Errorf("failed to configure tls: %w", err)
+		}
+	}
+	dialOpts = append([]grpc.DialOption{grpc.WithTransportCredentials(creds)}, dialOpts...)
+	conn, err := grpc.Dial(addr, dialOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial %s: %w", addr, err)
+	}
+	return conn, nil
+}
+
+// NewFollower 创建从节点，conn为连接到主节点的gRPC连接
+func NewFollower(cache filecache.Cache, conn grpc.ClientConnInterface, opts FollowerOptions) (*Follower, error) {
+	if opts.CursorSyncPeriod <= 0 {
+		opts.CursorSyncPeriod = defaultCursorSyncPeriod
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = defaultRetryInterval
+	}
+
+	f := &Follower{
+		cache:      cache,
+		conn:       conn,
+		opts:       opts,
+		namespaces: make(map[string]filecache.Cache),
+	}
+	if opts.CursorFile != "" {
+		data, err := os.ReadFile(opts.CursorFile)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read cursor file: %w", err)
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &f.status.Cursor); err != nil {
+				return nil, fmt.Errorf("failed to parse cursor file: %w", err)
+			}
+		}
+	}
+	return f, nil
+}
+
+// Status 返回从节点状态
+func (f *Follower) Status() FollowerStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.status
+}
+
+// Run 持续同步直到ctx取消，断线后按退避间隔重连并从游标处继续
+func (f *Follower) Run(ctx context.Context) error {
+	retry := f.opts.RetryInterval
+	for {
+		applied := f.Status().Applied
+		err := f.sync(ctx)
+		f.setConnected(false)
+		if saveErr := f.saveCursor(true); saveErr != nil && err == nil {
+			err = saveErr
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil && f.opts.OnError != nil {
+			f.opts.OnError(err)
+		}
+
+		// 本次连接有进展时重置退避间隔
+		if f.Status().Applied > applied {
+			retry = f.opts.RetryInterval
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(retry):
+		}
+		if retry *= 2; retry > maxRetryInterval {
+			retry = maxRetryInterval
+		}
+	}
+}
+
+// sync 建立一次推送流并应用收到的操作，直到流中断
+func (f *Follower) sync(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cursor := f.Status().Cursor
+	stream, err := f.conn.NewStream(ctx, &serviceDesc.Streams[0], streamMethodName, grpcwire.CallOption())
+	if err != nil {
+		return fmt.Errorf("failed to open replication stream: %w", err)
+	}
+	req := &StreamRequest{Epoch: cursor.Epoch, Seq: cursor.Seq, Follower: f.opts.Name}
+	if err := stream.SendMsg(req); err != nil {
+		return fmt.Errorf("failed to send replication request: %w", err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		return fmt.Errorf("failed to send replication request: %w", err)
+	}
+	f.setConnected(true)
+
+	err = recvOperations(stream, func(op *Operation) error {
+		return f.apply(ctx, op)
+	})
+	if err != nil {
+		return fmt.Errorf("replication stream interrupted: %w", err)
+	}
+	return nil
+}
+
+// recvOperations 接收操作直到流结束，分片的SET操作合并后才交给fn
+func recvOperations(stream grpc.ClientStream, fn func(op *Operation) error) error {
+	var pending *Operation // 正在接收分片的SET操作
+	var data bytes.Buffer
+	for {
+		op := new(Operation)
+		if err := stream.RecvMsg(op); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		if op.Type == OpSet {
+			if pending == nil {
+				pending = op
+				data.Reset()
+			}
+			data.Write(op.Data)
+			if op.More {
+				continue
+			}
+			op, pending = pending, nil
+			op.Data = data.Bytes()
+		}
+
+		if err := fn(op); err != nil {
+			return err
+		}
+	}
+}
+
+// applySet 写入SET操作携带的文件，已过期的文件跳过
+func applySet(ctx context.Context, cache filecache.Cache, op *Operation) error {
+	ttl := filecache.NoExpiry
+	if op.ExpiresAt != 0 {
+		if ttl = time.Until(time.Unix(0, op.ExpiresAt)); ttl <= 0 {
+			return nil
+		}
+	}
+	if err := cache.Set(ctx, op.Key, bytes.NewReader(op.Data), op.MimeType, ttl); err != nil {
+		return fmt.Errorf("failed to apply set %s: %w", op.Key, err)
+	}
+	return nil
+}
+
+// apply 应用一个操作并推进游标
+func (f *Follower) apply(ctx context.Context, op *Operation) error {
+	cache := f.namespace(op.Namespace)
+
+	switch op.Type {
+	case OpSet:
+		if err := applySet(ctx, cache, op); err != nil {
+			return err
+		}
+	case OpDelete:
+		if err := cache.Delete(ctx, op.Key); err != nil && !errors.Is(err, filecache.ErrNotFound) {
+			return fmt.Errorf("failed to apply delete %s: %w", op.Key, err)
+		}
+	case OpFlush:
+		if err := cache.Flush(ctx); err != nil {
+			return fmt.Errorf("failed to apply flush: %w", err)
+		}
+	case OpSynced:
+		f.mu.Lock()
+		f.status.Cursor = Cursor{Epoch: op.Epoch, Seq: op.Seq}
+		f.status.Resyncs++
+		f.mu.Unlock()
+		return f.saveCursor(true)
+	default:
+		return fmt.Errorf("unknown replication operation %d", op.Type)
+	}
+
+	f.mu.Lock()
+	// 全量同步中的操作序号为0，游标保持不变，中断后会重新全量同步
+	if op.Seq > 0 {
+		f.status.Cursor.Seq = op.Seq
+	}
+	f.status.Applied++
+	f.status.LastApplied = time.Now()
+	f.mu.Unlock()
+	return f.saveCursor(false)
+}
+
+// namespace 返回路径对应的本地命名空间
+func (f *Follower) namespace(path []string) filecache.Cache {
+	if len(path) == 0 {
+		return f.cache
+	}
+
+	key := namespaceKey(path)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ns, ok := f.namespaces[key]
+	if !ok {
+		ns = resolve(f.cache, path)
+		f.namespaces[key] = ns
+	}
+	return ns
+}
+
+func (f *Follower) setConnected(connected bool) {
+	f.mu.Lock()
+	f.status.Connected = connected
+	f.mu.Unlock()
+}
+
+// saveCursor 把游标写入文件，force为false时按CursorSyncPeriod限流
+// 游标落后于实际进度时只会重放少量操作，重放是幂等的
+func (f *Follower) saveCursor(force bool) error {
+	if f.opts.CursorFile == "" {
+		return nil
+	}
+
+	f.mu.Lock()
+	if !force && time.Since(f.savedAt) < f.opts.CursorSyncPeriod {
+		f.mu.Unlock()
+		return nil
+	}
+	f.savedAt = time.Now()
+	data, err := json.Marshal(f.status.Cursor)
+	f.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// 先写临时文件再改名，避免中途崩溃留下不完整的游标
+	tmp := f.opts.CursorFile + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(f.opts.CursorFile), 0755); err != nil {
+		return fmt.Errorf("failed to save cursor: %w", err)
+	}
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to save cursor: %w", err)
+	}
+	if err := os.Rename(tmp, f.opts.CursorFile); err != nil {
+		return fmt.Errorf("failed to save cursor: %w", err)
+	}
+	return nil
+}
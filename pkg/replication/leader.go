@@ -0,0 +1,360 @@
+// Package replication 把EdgeOrigin节点的写操作通过gRPC异步复制到一个或多个从节点
+// 主节点记录Set/Delete/Flush操作日志，从节点按游标拉取，断线重连后从游标处继续，
+// 游标失效（日志已被覆盖或主节点重启）时自动全量同步
+package replication
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+	"github.com/seraphico/EdgeOrigin/pkg/server/tlsutil"
+)
+
+const (
+	defaultLogSize   = 100000
+	defaultChunkSize = 1 << 20 // 1MB
+	streamBatchSize  = 1000
+)
+
+// LeaderOptions 主节点选项
+type LeaderOptions struct {
+	LogSize   int // 内存中保留的操作数，从节点落后超过该数量时需要全量同步，默认100000
+	ChunkSize int // 推送文件数据的分片大小，默认1MB
+
+	// TLS NewGRPCServer创建的服务器使用TLS，设置ClientCAFile时只有持有有效客户端证书的从节点才能复制（双向TLS）
+	TLS *tlsutil.Config
+}
+
+// Leader 记录写操作并推送给从节点的缓存，实现filecache.Cache
+// 写操作先写入底层缓存，成功后才记录日志，复制是异步的
+type Leader struct {
+	replicatedCache
+
+	epoch string
+	log   *opLog
+	opts  LeaderOptions
+
+	nsMu       sync.Mutex
+	namespaces map[string][]string // 出现过的命名空间路径，全量同步时逐个推送
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewLeader 包装cache，返回记录写操作的主节点
+func NewLeader(cache filecache.Cache, opts LeaderOptions) *Leader {
+	if opts.LogSize <= 0 {
+		opts.LogSize = defaultLogSize
+	}
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = defaultChunkSize
+	}
+
+	l := &Leader{
+		epoch:      newEpoch(),
+		log:        newOpLog(opts.LogSize),
+		opts:       opts,
+		namespaces: map[string][]string{"": nil},
+		done:       make(chan struct{}),
+	}
+	l.replicatedCache = replicatedCache{leader: l, cache: cache}
+	return l
+}
+
+// newEpoch 生成主节点实例标识，重启后的主节点不会接受旧的游标
+func newEpoch() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// Register 在gRPC服务器上注册复制服务
+func (l *Leader) Register(s grpc.ServiceRegistrar) {
+	s.RegisterService(&serviceDesc, l)
+}
+
+// NewGRPCServer 创建已注册复制服务的gRPC服务器，使用opts.TLS；同一个服务器上还可以注册反熵等其他服务
+func (l *Leader) NewGRPCServer(opts ...grpc.ServerOption) (*grpc.Server, error) {
+	if l.opts.TLS != nil {
+		creds, err := l.opts.TLS.ServerCredentials()
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure tls: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	server := grpc.NewServer(opts...)
+	l.Register(server)
+	return server, nil
+}
+
+// Cursor 返回当前的游标
+func (l *Leader) Cursor() Cursor {
+	return Cursor{Epoch: l.epoch, Seq: l.log.head()}
+}
+
+// Close 关闭底层缓存并结束所有推送
+func (l *Leader) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.cache.Close()
+}
+
+// Cursor 复制进度
+type Cursor struct {
+	Epoch string `json:"epoch"` // 主节点实例
+	Seq   uint64 `json:"seq"`   // 已应用的最后一个操作序号
+}
+
+// replicatedCache 记录写操作的缓存或命名空间
+type replicatedCache struct {
+	leader *Leader
+	cache  filecache.Cache
+	path   []string // 命名空间路径
+}
+
+// Set 存储文件到缓存
+func (c *replicatedCache) Set(ctx context.Context, key string, data io.Reader, mimeType string, ttl time.Duration) error {
+	if err := c.cache.Set(ctx, key, data, mimeType, ttl); err != nil {
+		return err
+	}
+	c.leader.log.append(OpSet, c.path, key)
+	return nil
+}
+
+// Get 从缓存获取文件
+func (c *replicatedCache) Get(ctx context.Context, key string) (io.ReadCloser, *filecache.FileInfo, error) {
+	return c.cache.Get(ctx, key)
+}
+
+// Peek 只读地获取文件，底层缓存未实现filecache.Peeker时使用Get
+func (c *replicatedCache) Peek(ctx context.Context, key string) (io.ReadCloser, *filecache.FileInfo, error) {
+	if peeker, ok := c.cache.(filecache.Peeker); ok {
+		return peeker.Peek(ctx, key)
+	}
+	return c.cache.Get(ctx, key)
+}
+
+// Exists 检查文件是否存在
+func (c *replicatedCache) Exists(ctx context.Context, key string) (bool, error) {
+	return c.cache.Exists(ctx, key)
+}
+
+// Delete 删除文件
+func (c *replicatedCache) Delete(ctx context.Context, key string) error {
+	if err := c.cache.Delete(ctx, key); err != nil {
+		return err
+	}
+	c.leader.log.append(OpDelete, c.path, key)
+	return nil
+}
+
+// List 列出所有缓存文件
+func (c *replicatedCache) List(ctx context.Context) ([]*filecache.FileInfo, error) {
+	return c.cache.List(ctx)
+}
+
+// GetInfo 获取文件信息
+func (c *replicatedCache) GetInfo(ctx context.Context, key string) (*filecache.FileInfo, error) {
+	return c.cache.GetInfo(ctx, key)
+}
+
+// Cleanup 清理过期文件，主从节点各自清理，不复制
+func (c *replicatedCache) Cleanup(ctx context.Context) error {
+	return c.cache.Cleanup(ctx)
+}
+
+// Flush 删除所有缓存文件并重置统计信息
+func (c *replicatedCache) Flush(ctx context.Context) error {
+	if err := c.cache.Flush(ctx); err != nil {
+		return err
+	}
+	c.leader.log.append(OpFlush, c.path, "")
+	return nil
+}
+
+// Namespace 返回同样记录写操作的子命名空间
+func (c *replicatedCache) Namespace(name string) filecache.Cache {
+	path := append(append([]string(nil), c.path...), name)
+
+	c.leader.nsMu.Lock()
+	c.leader.namespaces[namespaceKey(path)] = path
+	c.leader.nsMu.Unlock()
+
+	return &replicatedCache{leader: c.leader, cache: c.cache.Namespace(name), path: path}
+}
+
+// Close 关闭缓存
+func (c *replicatedCache) Close() error {
+	return c.cache.Close()
+}
+
+// Stats 获取缓存统计信息
+func (c *replicatedCache) Stats() (*filecache.Stats, error) {
+	return c.cache.Stats()
+}
+
+// namespaceKey 命名空间路径的唯一表示
+func namespaceKey(path []string) string {
+	return strings.Join(path, "\x00")
+}
+
+// resolve 返回路径对应的命名空间
+func resolve(cache filecache.Cache, path []string) filecache.Cache {
+	for _, name := range path {
+		cache = cache.Namespace(name)
+	}
+	return cache
+}
+
+// stream 处理从节点的同步请求
+func (l *Leader) stream(req *StreamRequest, ss grpc.ServerStream) error {
+	ctx := ss.Context()
+
+	seq := req.Seq
+	if req.Epoch != l.epoch {
+		var err error
+		if seq, err = l.resync(ctx, ss); err != nil {
+			return err
+		}
+	}
+
+	for {
+		entries, notify, ok := l.log.after(seq, streamBatchSize)
+		if !ok {
+			// 从节点落后太多，日志已被覆盖
+			var err error
+			if seq, err = l.resync(ctx, ss); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, e := range entries {
+			if err := l.send(ctx, ss, e); err != nil {
+				return err
+			}
+			seq = e.seq
+		}
+		if len(entries) > 0 {
+			continue
+		}
+
+		select {
+		case <-notify:
+		case <-l.done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// send 推送一个日志条目
+func (l *Leader) send(ctx context.Context, ss grpc.ServerStream, e logEntry) error {
+	switch e.op {
+	case OpSet:
+		return l.sendFile(ctx, ss, e.seq, e.namespace, e.key)
+	default:
+		return ss.SendMsg(&Operation{Type: e.op, Seq: e.seq, Namespace: e.namespace, Key: e.key})
+	}
+}
+
+// sendFile 分片推送文件的最新内容，文件已被删除或过期时跳过，对应的删除操作会随后推送
+func (l *Leader) sendFile(ctx context.Context, ss grpc.ServerStream, seq uint64, path []string, key string) error {
+	return sendFile(ctx, ss, resolve(l.cache, path), l.opts.ChunkSize, seq, path, key)
+}
+
+// sendFile 以SET操作分片发送文件，文件不存在或已过期时跳过
+// 缓存实现filecache.Peeker时只读地读取文件，同步不影响主节点的访问统计和淘汰顺序
+func sendFile(ctx context.Context, ss grpc.ServerStream, cache filecache.Cache, chunkSize int, seq uint64, path []string, key string) error {
+	get := cache.Get
+	if peeker, ok := cache.(filecache.Peeker); ok {
+		get = peeker.Peek
+	}
+	reader, info, err := get(ctx, key)
+	if errors.Is(err, filecache.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	defer reader.Close()
+	if time.Now().After(info.ExpiresAt) {
+		return nil
+	}
+
+	var expiresAt int64
+	if !info.NeverExpires() {
+		expiresAt = info.ExpiresAt.UnixNano()
+	}
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(reader, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("failed to read %s: %w", key, err)
+		}
+		more := err == nil
+
+		op := &Operation{
+			Type:      OpSet,
+			Seq:       seq,
+			Namespace: path,
+			Key:       key,
+			MimeType:  info.MimeType,
+			ExpiresAt: expiresAt,
+			Data:      buf[:n],
+			More:      more,
+		}
+		if err := ss.SendMsg(op); err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// resync 全量同步：逐个命名空间清空从节点并推送所有文件，返回新的起始序号
+// 起始序号在列出文件之前确定，同步期间的写操作随后会重放
+func (l *Leader) resync(ctx context.Context, ss grpc.ServerStream) (uint64, error) {
+	seq := l.log.head()
+
+	l.nsMu.Lock()
+	paths := make([][]string, 0, len(l.namespaces))
+	for _, path := range l.namespaces {
+		paths = append(paths, path)
+	}
+	l.nsMu.Unlock()
+
+	for _, path := range paths {
+		if err := ss.SendMsg(&Operation{Type: OpFlush, Namespace: path}); err != nil {
+			return 0, err
+		}
+
+		files, err := resolve(l.cache, path).List(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list files: %w", err)
+		}
+		for _, info := range files {
+			if err := l.sendFile(ctx, ss, 0, path, info.Key); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err := ss.SendMsg(&Operation{Type: OpSynced, Seq: seq, Epoch: l.epoch}); err != nil {
+		return 0, err
+	}
+	return seq, nil
+}
@@ -0,0 +1,76 @@
+package replication
+
+import "sync"
+
+// logEntry 操作日志条目，只记录键，推送SET时再从缓存读取最新数据
+type logEntry struct {
+	seq       uint64
+	op        OpType
+	namespace []string
+	key       string
+}
+
+// opLog 固定容量的内存操作日志，超出容量时丢弃最旧的条目
+type opLog struct {
+	mu       sync.Mutex
+	entries  []logEntry
+	capacity int
+	next     uint64        // 下一个操作的序号，从1开始
+	notify   chan struct{} // 追加条目时关闭并替换，用于唤醒等待的推送协程
+}
+
+func newOpLog(capacity int) *opLog {
+	return &opLog{
+		capacity: capacity,
+		next:     1,
+		notify:   make(chan struct{}),
+	}
+}
+
+// append 追加一个操作
+func (l *opLog) append(op OpType, namespace []string, key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = append(l.entries, logEntry{seq: l.next, op: op, namespace: namespace, key: key})
+	l.next++
+	// 超出两倍容量时才整体搬移，避免每次追加都复制
+	if len(l.entries) >= 2*l.capacity {
+		l.entries = append([]logEntry(nil), l.entries[len(l.entries)-l.capacity:]...)
+	}
+
+	close(l.notify)
+	l.notify = make(chan struct{})
+}
+
+// head 返回最后一个操作的序号
+func (l *opLog) head() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.next - 1
+}
+
+// after 返回序号大于seq的最多limit个操作，以及没有新操作时用于等待的通道
+// seq之后的操作已被丢弃或seq超出日志范围时ok为false，需要全量同步
+func (l *opLog) after(seq uint64, limit int) (entries []logEntry, notify <-chan struct{}, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if seq >= l.next {
+		return nil, nil, false
+	}
+	retained := l.entries
+	if len(retained) > l.capacity {
+		retained = retained[len(retained)-l.capacity:]
+	}
+
+	first := l.next - uint64(len(retained))
+	if seq+1 < first {
+		return nil, nil, false
+	}
+	entries = retained[seq+1-first:]
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return append([]logEntry(nil), entries...), l.notify, true
+}
@@ -0,0 +1,201 @@
+package replication
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+const (
+	// DefaultTreeDepth 默认的Merkle树深度，叶子数为2^10
+	DefaultTreeDepth = 10
+	// MaxTreeDepth 最大的Merkle树深度
+	MaxTreeDepth = 20
+)
+
+// TreeEntry 叶子中的一个文件
+type TreeEntry struct {
+	Key    string
+	Digest []byte // MIME类型和文件内容的SHA-256
+}
+
+// MerkleTree 按键哈希把键空间划分为2^depth个叶子的二叉Merkle树
+// 叶子哈希覆盖其中所有文件的键和摘要，两棵树的同一节点哈希相同时对应的键范围一致
+type MerkleTree struct {
+	depth   int
+	levels  [][][]byte    // levels[d]有2^d个节点，levels[depth]为叶子
+	buckets [][]TreeEntry // 每个叶子中的文件，按键排序
+}
+
+// BuildMerkleTree 读取缓存中所有未过期的文件构建Merkle树，只覆盖cache所在的命名空间。
+// 缓存实现filecache.Peeker时只读地读取文件，构建不影响最后访问时间和淘汰顺序；否则使用Get
+func BuildMerkleTree(ctx context.Context, cache filecache.Cache, depth int) (*MerkleTree, error) {
+	if depth < 0 || depth > MaxTreeDepth {
+		return nil, fmt.Errorf("merkle tree depth must be between 0 and %d", MaxTreeDepth)
+	}
+
+	files, err := cache.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	t := &MerkleTree{
+		depth:   depth,
+		buckets: make([][]TreeEntry, 1<<depth),
+	}
+	for _, info := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		digest, err := fileDigest(ctx, cache, info.Key)
+		if errors.Is(err, filecache.ErrNotFound) {
+			// 列出后被删除或过期（ErrExpired）
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		leaf := leafIndex(info.Key, depth)
+		t.buckets[leaf] = append(t.buckets[leaf], TreeEntry{Key: info.Key, Digest: digest})
+	}
+
+	t.levels = make([][][]byte, depth+1)
+	leaves := make([][]byte, len(t.buckets))
+	for i, bucket := range t.buckets {
+		sort.Slice(bucket, func(a, b int) bool { return bucket[a].Key < bucket[b].Key })
+		leaves[i] = bucketHash(bucket)
+	}
+	t.levels[depth] = leaves
+	for d := depth - 1; d >= 0; d-- {
+		children := t.levels[d+1]
+		nodes := make([][]byte, 1<<d)
+		for i := range nodes {
+			h := sha256.New()
+			h.Write(children[2*i])
+			h.Write(children[2*i+1])
+			nodes[i] = h.Sum(nil)
+		}
+		t.levels[d] = nodes
+	}
+	return t, nil
+}
+
+// Depth 返回树的深度
+func (t *MerkleTree) Depth() int {
+	return t.depth
+}
+
+// Root 返回根哈希
+func (t *MerkleTree) Root() []byte {
+	return t.levels[0][0]
+}
+
+// Bucket 返回叶子中的文件
+func (t *MerkleTree) Bucket(leaf int) []TreeEntry {
+	return t.buckets[leaf]
+}
+
+// DiffLeaves 从根开始逐层比较两棵深度相同的树，返回哈希不同的叶子
+func DiffLeaves(a, b *MerkleTree) ([]int, error) {
+	if a.depth != b.depth {
+		return nil, fmt.Errorf("merkle tree depths differ: %d and %d", a.depth, b.depth)
+	}
+	return divergentLeaves(context.Background(), a, b, a.depth)
+}
+
+// nodes 实现treeSource
+func (t *MerkleTree) nodes(_ context.Context, level int, indexes []int) ([][]byte, error) {
+	hashes := make([][]byte, len(indexes))
+	for i, index := range indexes {
+		hashes[i] = t.levels[level][index]
+	}
+	return hashes, nil
+}
+
+// treeSource 可以按层读取节点哈希的Merkle树，本地树和远端节点都实现该接口
+type treeSource interface {
+	nodes(ctx context.Context, level int, indexes []int) ([][]byte, error)
+}
+
+// divergentLeaves 逐层比较两棵树，只展开哈希不同节点的子节点
+func divergentLeaves(ctx context.Context, a, b treeSource, depth int) ([]int, error) {
+	candidates := []int{0}
+	for level := 0; ; level++ {
+		left, err := a.nodes(ctx, level, candidates)
+		if err != nil {
+			return nil, err
+		}
+		right, err := b.nodes(ctx, level, candidates)
+		if err != nil {
+			return nil, err
+		}
+
+		var diff []int
+		for i, index := range candidates {
+			if !bytes.Equal(left[i], right[i]) {
+				diff = append(diff, index)
+			}
+		}
+		if level == depth || len(diff) == 0 {
+			return diff, nil
+		}
+
+		next := make([]int, 0, 2*len(diff))
+		for _, index := range diff {
+			next = append(next, 2*index, 2*index+1)
+		}
+		candidates = next
+	}
+}
+
+// leafIndex 取键的SHA-256的高depth位作为叶子序号
+func leafIndex(key string, depth int) int {
+	if depth == 0 {
+		return 0
+	}
+	sum := sha256.Sum256([]byte(key))
+	return int(binary.BigEndian.Uint32(sum[:4]) >> (32 - depth))
+}
+
+// bucketHash 计算叶子哈希
+func bucketHash(entries []TreeEntry) []byte {
+	h := sha256.New()
+	for _, e := range entries {
+		h.Write([]byte(e.Key))
+		h.Write([]byte{0})
+		h.Write(e.Digest)
+	}
+	return h.Sum(nil)
+}
+
+// fileDigest 计算文件的摘要，过期时间在各节点上不完全一致，不参与比较；文件已过期时返回filecache.ErrExpired
+func fileDigest(ctx context.Context, cache filecache.Cache, key string) ([]byte, error) {
+	get := cache.Get
+	if peeker, ok := cache.(filecache.Peeker); ok {
+		get = peeker.Peek
+	}
+	reader, info, err := get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	if time.Now().After(info.ExpiresAt) {
+		return nil, filecache.ErrExpired
+	}
+
+	h := sha256.New()
+	h.Write([]byte(info.MimeType))
+	h.Write([]byte{0})
+	if _, err := io.Copy(h, reader); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,301 @@
+package replication
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/seraphico/EdgeOrigin/internal/grpcwire"
+)
+
+// OpType 操作类型，取值与replication.proto一致
+type OpType int32
+
+const (
+	OpSet    OpType = 0 // 写入文件
+	OpDelete OpType = 1 // 删除文件
+	OpFlush  OpType = 2 // 清空命名空间
+	OpSynced OpType = 3 // 全量同步完成
+)
+
+// StreamRequest 从节点发起同步的请求
+type StreamRequest struct {
+	Epoch    string // 游标所属的主节点实例，为空表示首次同步
+	Seq      uint64 // 已应用的最后一个操作序号
+	Follower string // 从节点名称
+}
+
+// MarshalWire 按protobuf线格式编码
+func (m *StreamRequest) MarshalWire() ([]byte, error) {
+	var b []byte
+	b = grpcwire.AppendString(b, 1, m.Epoch)
+	b = grpcwire.AppendVarint(b, 2, m.Seq)
+	b = grpcwire.AppendString(b, 3, m.Follower)
+	return b, nil
+}
+
+// UnmarshalWire 按protobuf线格式解码
+func (m *StreamRequest) UnmarshalWire(b []byte) error {
+	*m = StreamRequest{}
+	return grpcwire.Decode(b, func(f grpcwire.Field) error {
+		switch f.Num {
+		case 1:
+			m.Epoch = f.String()
+		case 2:
+			m.Seq = f.Varint
+		case 3:
+			m.Follower = f.String()
+		}
+		return nil
+	})
+}
+
+// Operation 主节点推送的操作
+type Operation struct {
+	Type      OpType
+	Seq       uint64   // 操作序号，全量同步中的操作为0
+	Namespace []string // 命名空间路径，根命名空间为空
+	Key       string
+	MimeType  string
+	ExpiresAt int64  // 过期时间，Unix纳秒，0表示永不过期
+	Data      []byte // 文件数据分片
+	More      bool   // 同一个SET后面还有数据分片
+	Epoch     string // 仅SYNCED操作携带
+}
+
+// MarshalWire 按protobuf线格式编码
+func (m *Operation) MarshalWire() ([]byte, error) {
+	b := make([]byte, 0, len(m.Data)+len(m.Key)+64)
+	b = grpcwire.AppendVarint(b, 1, uint64(m.Type))
+	b = grpcwire.AppendVarint(b, 2, m.Seq)
+	b = appendNamespace(b, 3, m.Namespace)
+	b = grpcwire.AppendString(b, 4, m.Key)
+	b = grpcwire.AppendString(b, 5, m.MimeType)
+	b = grpcwire.AppendVarint(b, 6, uint64(m.ExpiresAt))
+	b = grpcwire.AppendBytes(b, 7, m.Data)
+	if m.More {
+		b = grpcwire.AppendVarint(b, 8, 1)
+	}
+	b = grpcwire.AppendString(b, 9, m.Epoch)
+	return b, nil
+}
+
+// UnmarshalWire 按protobuf线格式解码
+func (m *Operation) UnmarshalWire(b []byte) error {
+	*m = Operation{}
+	return grpcwire.Decode(b, func(f grpcwire.Field) error {
+		switch f.Num {
+		case 1:
+			m.Type = OpType(f.Varint)
+		case 2:
+			m.Seq = f.Varint
+		case 3:
+			m.Namespace = append(m.Namespace, f.String())
+		case 4:
+			m.Key = f.String()
+		case 5:
+			m.MimeType = f.String()
+		case 6:
+			m.ExpiresAt = int64(f.Varint)
+		case 7:
+			m.Data = append([]byte(nil), f.Bytes...)
+		case 8:
+			m.More = f.Varint != 0
+		case 9:
+			m.Epoch = f.String()
+		}
+		return nil
+	})
+}
+
+// NodesRequest 读取Merkle树某一层节点哈希的请求
+type NodesRequest struct {
+	Namespace []string // 命名空间路径
+	Depth     int      // 树的深度
+	Level     int      // 层号，0为根
+	Indexes   []int    // 节点序号
+}
+
+// MarshalWire 按protobuf线格式编码
+func (m *NodesRequest) MarshalWire() ([]byte, error) {
+	var b []byte
+	b = appendNamespace(b, 1, m.Namespace)
+	b = grpcwire.AppendVarint(b, 2, uint64(m.Depth))
+	b = grpcwire.AppendVarint(b, 3, uint64(m.Level))
+	b = grpcwire.AppendPacked(b, 4, toUint64s(m.Indexes))
+	return b, nil
+}
+
+// UnmarshalWire 按protobuf线格式解码
+func (m *NodesRequest) UnmarshalWire(b []byte) error {
+	*m = NodesRequest{}
+	return grpcwire.Decode(b, func(f grpcwire.Field) error {
+		switch f.Num {
+		case 1:
+			m.Namespace = append(m.Namespace, f.String())
+		case 2:
+			m.Depth = int(f.Varint)
+		case 3:
+			m.Level = int(f.Varint)
+		case 4:
+			values, err := f.Varints()
+			if err != nil {
+				return err
+			}
+			for _, v := range values {
+				m.Indexes = append(m.Indexes, int(v))
+			}
+		}
+		return nil
+	})
+}
+
+// NodesResponse 节点哈希，顺序与请求中的序号一致
+type NodesResponse struct {
+	Hashes [][]byte
+}
+
+// MarshalWire 按protobuf线格式编码
+func (m *NodesResponse) MarshalWire() ([]byte, error) {
+	var b []byte
+	for _, h := range m.Hashes {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, h)
+	}
+	return b, nil
+}
+
+// UnmarshalWire 按protobuf线格式解码
+func (m *NodesResponse) UnmarshalWire(b []byte) error {
+	*m = NodesResponse{}
+	return grpcwire.Decode(b, func(f grpcwire.Field) error {
+		if f.Num == 1 {
+			m.Hashes = append(m.Hashes, append([]byte(nil), f.Bytes...))
+		}
+		return nil
+	})
+}
+
+// BucketsRequest 读取叶子中文件列表的请求
+type BucketsRequest struct {
+	Namespace []string // 命名空间路径
+	Depth     int      // 树的深度
+	Leaves    []int    // 叶子序号
+}
+
+// MarshalWire 按protobuf线格式编码
+func (m *BucketsRequest) MarshalWire() ([]byte, error) {
+	var b []byte
+	b = appendNamespace(b, 1, m.Namespace)
+	b = grpcwire.AppendVarint(b, 2, uint64(m.Depth))
+	b = grpcwire.AppendPacked(b, 3, toUint64s(m.Leaves))
+	return b, nil
+}
+
+// UnmarshalWire 按protobuf线格式解码
+func (m *BucketsRequest) UnmarshalWire(b []byte) error {
+	*m = BucketsRequest{}
+	return grpcwire.Decode(b, func(f grpcwire.Field) error {
+		switch f.Num {
+		case 1:
+			m.Namespace = append(m.Namespace, f.String())
+		case 2:
+			m.Depth = int(f.Varint)
+		case 3:
+			values, err := f.Varints()
+			if err != nil {
+				return err
+			}
+			for _, v := range values {
+				m.Leaves = append(m.Leaves, int(v))
+			}
+		}
+		return nil
+	})
+}
+
+// BucketsResponse 请求的叶子中的所有文件
+type BucketsResponse struct {
+	Entries []TreeEntry
+}
+
+// MarshalWire 按protobuf线格式编码
+func (m *BucketsResponse) MarshalWire() ([]byte, error) {
+	var b []byte
+	for _, e := range m.Entries {
+		var entry []byte
+		entry = grpcwire.AppendString(entry, 1, e.Key)
+		entry = grpcwire.AppendBytes(entry, 2, e.Digest)
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	return b, nil
+}
+
+// UnmarshalWire 按protobuf线格式解码
+func (m *BucketsResponse) UnmarshalWire(b []byte) error {
+	*m = BucketsResponse{}
+	return grpcwire.Decode(b, func(f grpcwire.Field) error {
+		if f.Num != 1 {
+			return nil
+		}
+		var e TreeEntry
+		err := grpcwire.Decode(f.Bytes, func(f grpcwire.Field) error {
+			switch f.Num {
+			case 1:
+				e.Key = f.String()
+			case 2:
+				e.Digest = append([]byte(nil), f.Bytes...)
+			}
+			return nil
+		})
+		m.Entries = append(m.Entries, e)
+		return err
+	})
+}
+
+// FetchRequest 拉取文件的请求
+type FetchRequest struct {
+	Namespace []string // 命名空间路径
+	Keys      []string
+}
+
+// MarshalWire 按protobuf线格式编码
+func (m *FetchRequest) MarshalWire() ([]byte, error) {
+	var b []byte
+	b = appendNamespace(b, 1, m.Namespace)
+	for _, key := range m.Keys {
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendString(b, key)
+	}
+	return b, nil
+}
+
+// UnmarshalWire 按protobuf线格式解码
+func (m *FetchRequest) UnmarshalWire(b []byte) error {
+	*m = FetchRequest{}
+	return grpcwire.Decode(b, func(f grpcwire.Field) error {
+		switch f.Num {
+		case 1:
+			m.Namespace = append(m.Namespace, f.String())
+		case 2:
+			m.Keys = append(m.Keys, f.String())
+		}
+		return nil
+	})
+}
+
+// appendNamespace 追加命名空间路径，repeated字段的空字符串也要保留
+func appendNamespace(b []byte, num protowire.Number, path []string) []byte {
+	for _, name := range path {
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendString(b, name)
+	}
+	return b
+}
+
+func toUint64s(values []int) []uint64 {
+	out := make([]uint64, len(values))
+	for i, v := range values {
+		out[i] = uint64(v)
+	}
+	return out
+}
@@ -0,0 +1,326 @@
+package replication
+
+import (
+	"context"
+	"crypto/x509"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/seraphico/EdgeOrigin/internal/tlstest"
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+	"github.com/seraphico/EdgeOrigin/pkg/server/tlsutil"
+)
+
+// startServer 在内存连接上启动gRPC服务，返回连接到它的客户端
+func startServer(t *testing.T, register func(grpc.ServiceRegistrar)) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	register(server)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial leader: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// runFollower 在后台运行从节点，返回停止函数
+func runFollower(t *testing.T, follower *Follower) (stop func()) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		follower.Run(ctx)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// eventually 等待条件成立
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// content 读取文件内容，不存在时返回空字符串
+func content(cache filecache.Cache, key string) string {
+	reader, _, err := cache.Get(context.Background(), key)
+	if err != nil {
+		return ""
+	}
+	defer reader.Close()
+	data, _ := io.ReadAll(reader)
+	return string(data)
+}
+
+func TestReplication(t *testing.T) {
+	ctx := context.Background()
+
+	leader := NewLeader(filecache.NewMemoryCache(1<<20), LeaderOptions{ChunkSize: 4})
+	defer leader.Close()
+	conn := startServer(t, leader.Register)
+
+	set := func(cache filecache.Cache, key, value string) {
+		t.Helper()
+		if err := cache.Set(ctx, key, strings.NewReader(value), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+	}
+
+	// 从节点连接之前写入的数据通过全量同步获得
+	set(leader, "before", "written before follower")
+	if err := leader.Set(ctx, "expired", strings.NewReader("expired"), "text/plain", time.Millisecond); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	local := filecache.NewMemoryCache(1 << 20)
+	defer local.Close()
+	set(local, "stale", "only on follower")
+
+	cursorFile := filepath.Join(t.TempDir(), "cursor.json")
+	follower, err := NewFollower(local, conn, FollowerOptions{Name: "standby", CursorFile: cursorFile, RetryInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create follower: %v", err)
+	}
+	stop := runFollower(t, follower)
+
+	t.Run("InitialSync", func(t *testing.T) {
+		eventually(t, "initial sync", func() bool { return content(local, "before") == "written before follower" })
+		if exists, _ := local.Exists(ctx, "stale"); exists {
+			t.Error("Expected full sync to remove stale files")
+		}
+		if exists, _ := local.Exists(ctx, "expired"); exists {
+			t.Error("Expected full sync to skip expired files")
+		}
+		// 全量同步只读地读取主节点的文件
+		if info, err := leader.GetInfo(ctx, "before"); err != nil || info.AccessCount != 0 {
+			t.Errorf("Expected full sync not to count as an access, got %+v (%v)", info, err)
+		}
+		if status := follower.Status(); status.Resyncs != 1 || status.Cursor.Epoch != leader.Cursor().Epoch {
+			t.Errorf("Unexpected status after initial sync: %+v", status)
+		}
+	})
+
+	t.Run("StreamOperations", func(t *testing.T) {
+		set(leader, "a", "chunked across several messages")
+		set(leader.Namespace("site-a"), "b", "bbb")
+		if err := leader.Delete(ctx, "before"); err != nil {
+			t.Fatalf("Failed to delete file: %v", err)
+		}
+
+		eventually(t, "operations", func() bool { return follower.Status().Cursor.Seq == leader.Cursor().Seq })
+		if got := content(local, "a"); got != "chunked across several messages" {
+			t.Errorf("Expected replicated content, got %q", got)
+		}
+		if got := content(local.Namespace("site-a"), "b"); got != "bbb" {
+			t.Errorf("Expected 'bbb' in namespace, got %q", got)
+		}
+		if exists, _ := local.Exists(ctx, "before"); exists {
+			t.Error("Expected delete to be replicated")
+		}
+
+		info, err := local.GetInfo(ctx, "a")
+		if err != nil {
+			t.Fatalf("Failed to get info: %v", err)
+		}
+		if info.MimeType != "text/plain" || time.Until(info.ExpiresAt) < 59*time.Minute {
+			t.Errorf("Unexpected replicated info: %+v", info)
+		}
+	})
+
+	t.Run("ResumeFromCursor", func(t *testing.T) {
+		stop()
+
+		set(leader, "c", "written while follower was down")
+		if err := leader.Namespace("site-a").Flush(ctx); err != nil {
+			t.Fatalf("Failed to flush namespace: %v", err)
+		}
+
+		// 新的从节点实例从游标文件恢复，不需要全量同步
+		resumed, err := NewFollower(local, conn, FollowerOptions{CursorFile: cursorFile, RetryInterval: 10 * time.Millisecond})
+		if err != nil {
+			t.Fatalf("Failed to create follower: %v", err)
+		}
+		stop = runFollower(t, resumed)
+
+		eventually(t, "catch-up", func() bool { return resumed.Status().Cursor.Seq == leader.Cursor().Seq })
+		if got := content(local, "c"); got != "written while follower was down" {
+			t.Errorf("Expected 'c' to be replicated, got %q", got)
+		}
+		if exists, _ := local.Namespace("site-a").Exists(ctx, "b"); exists {
+			t.Error("Expected flush to be replicated")
+		}
+		if resumed.Status().Resyncs != 0 {
+			t.Error("Expected follower to resume without a full sync")
+		}
+	})
+	stop()
+}
+
+func TestReplicationTLS(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	ca := tlstest.NewCA(t, dir, "edge-ca")
+	leaderCert, leaderKey := ca.Issue("leader", 2, x509.ExtKeyUsageServerAuth)
+	standbyCert, standbyKey := ca.Issue("standby", 3, x509.ExtKeyUsageClientAuth)
+
+	leader := NewLeader(filecache.NewMemoryCache(1<<20), LeaderOptions{TLS: &tlsutil.Config{
+		CertFile:     leaderCert,
+		KeyFile:      leaderKey,
+		ClientCAFile: ca.File,
+	}})
+	defer leader.Close()
+	if err := leader.Set(ctx, "a.txt", strings.NewReader("replicated over tls"), "text/plain", time.Hour); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+	server, err := leader.NewGRPCServer()
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go server.Serve(lis)
+	defer server.Stop()
+
+	follow := func(t *testing.T, cfg *tlsutil.Config) (filecache.Cache, chan error, func()) {
+		conn, err := Dial(lis.Addr().String(), cfg)
+		if err != nil {
+			t.Fatalf("Failed to dial leader: %v", err)
+		}
+		local := filecache.NewMemoryCache(1 << 20)
+		errs := make(chan error, 16)
+		follower, err := NewFollower(local, conn, FollowerOptions{
+			RetryInterval: 10 * time.Millisecond,
+			OnError: func(err error) {
+				select {
+				case errs <- err:
+				default:
+				}
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create follower: %v", err)
+		}
+		stop := runFollower(t, follower)
+		return local, errs, func() {
+			stop()
+			conn.Close()
+			local.Close()
+		}
+	}
+
+	t.Run("ClientCertificate", func(t *testing.T) {
+		local, _, stop := follow(t, &tlsutil.Config{CertFile: standbyCert, KeyFile: standbyKey, CAFile: ca.File, ServerName: "leader"})
+		defer stop()
+		eventually(t, "sync over tls", func() bool { return content(local, "a.txt") == "replicated over tls" })
+	})
+
+	t.Run("NoClientCertificate", func(t *testing.T) {
+		local, errs, stop := follow(t, &tlsutil.Config{CAFile: ca.File, ServerName: "leader"})
+		defer stop()
+		select {
+		case <-errs:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected follower without a certificate to be rejected")
+		}
+		if content(local, "a.txt") != "" {
+			t.Error("Expected nothing to be replicated")
+		}
+	})
+}
+
+func TestReplicationLogOverflow(t *testing.T) {
+	ctx := context.Background()
+
+	leader := NewLeader(filecache.NewMemoryCache(1<<20), LeaderOptions{LogSize: 2})
+	defer leader.Close()
+	conn := startServer(t, leader.Register)
+
+	local := filecache.NewMemoryCache(1 << 20)
+	defer local.Close()
+	follower, err := NewFollower(local, conn, FollowerOptions{RetryInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create follower: %v", err)
+	}
+
+	if err := leader.Set(ctx, "a", strings.NewReader("aaa"), "text/plain", time.Hour); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+	stop := runFollower(t, follower)
+	eventually(t, "initial sync", func() bool { return content(local, "a") == "aaa" })
+	stop()
+
+	// 从节点离线期间的操作超过日志容量
+	if err := leader.Delete(ctx, "a"); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+	for _, key := range []string{"b", "c", "d"} {
+		if err := leader.Set(ctx, key, strings.NewReader(key), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+	}
+
+	stop = runFollower(t, follower)
+	defer stop()
+	eventually(t, "resync", func() bool { return follower.Status().Resyncs == 2 })
+	eventually(t, "catch-up", func() bool { return content(local, "d") == "d" })
+	if exists, _ := local.Exists(ctx, "a"); exists {
+		t.Error("Expected resync to remove files deleted while the follower was behind")
+	}
+}
+
+func TestOperationWire(t *testing.T) {
+	op := &Operation{
+		Type:      OpSet,
+		Seq:       42,
+		Namespace: []string{"", "site-a"},
+		Key:       "img/logo.png",
+		MimeType:  "image/png",
+		ExpiresAt: time.Now().UnixNano(),
+		Data:      []byte("png"),
+		More:      true,
+	}
+	data, err := op.MarshalWire()
+	if err != nil {
+		t.Fatalf("Failed to marshal operation: %v", err)
+	}
+
+	var got Operation
+	if err := got.UnmarshalWire(data); err != nil {
+		t.Fatalf("Failed to unmarshal operation: %v", err)
+	}
+	if got.Seq != op.Seq || got.Key != op.Key || got.MimeType != op.MimeType || got.ExpiresAt != op.ExpiresAt ||
+		string(got.Data) != "png" || !got.More || len(got.Namespace) != 2 || got.Namespace[1] != "site-a" {
+		t.Errorf("Unexpected round trip: %+v", got)
+	}
+}
@@ -0,0 +1,104 @@
+package replication
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// 服务定义与replication.proto保持一致
+const (
+	serviceName      = "edgeorigin.replication.v1.Replication"
+	streamMethodName = "/" + serviceName + "/Stream"
+
+	antiEntropyServiceName = "edgeorigin.replication.v1.AntiEntropy"
+	nodesMethodName        = "/" + antiEntropyServiceName + "/Nodes"
+	bucketsMethodName      = "/" + antiEntropyServiceName + "/Buckets"
+	fetchMethodName        = "/" + antiEntropyServiceName + "/Fetch"
+)
+
+// replicationServer 复制服务的服务端接口
+type replicationServer interface {
+	stream(req *StreamRequest, ss grpc.ServerStream) error
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*replicationServer)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Stream",
+			Handler:       streamHandler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "replication.proto",
+}
+
+func streamHandler(srv interface{}, ss grpc.ServerStream) error {
+	req := new(StreamRequest)
+	if err := ss.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(replicationServer).stream(req, ss)
+}
+
+// antiEntropyServer 反熵服务的服务端接口
+type antiEntropyServer interface {
+	nodes(ctx context.Context, req *NodesRequest) (*NodesResponse, error)
+	buckets(ctx context.Context, req *BucketsRequest) (*BucketsResponse, error)
+	fetch(req *FetchRequest, ss grpc.ServerStream) error
+}
+
+var antiEntropyServiceDesc = grpc.ServiceDesc{
+	ServiceName: antiEntropyServiceName,
+	HandlerType: (*antiEntropyServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Nodes", Handler: nodesHandler},
+		{MethodName: "Buckets", Handler: bucketsHandler},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Fetch",
+			Handler:       fetchHandler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "replication.proto",
+}
+
+func nodesHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(NodesRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(antiEntropyServer).nodes(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: nodesMethodName}
+	return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(antiEntropyServer).nodes(ctx, req.(*NodesRequest))
+	})
+}
+
+func bucketsHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(BucketsRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(antiEntropyServer).buckets(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: bucketsMethodName}
+	return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(antiEntropyServer).buckets(ctx, req.(*BucketsRequest))
+	})
+}
+
+func fetchHandler(srv interface{}, ss grpc.ServerStream) error {
+	req := new(FetchRequest)
+	if err := ss.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(antiEntropyServer).fetch(req, ss)
+}
@@ -0,0 +1,216 @@
+// Package accesslog 记录边缘节点的访问日志：每个请求的客户端、请求行、状态码、字节数、缓存状态（X-Cache）、
+// 总耗时和回源耗时，按Apache combined或JSON格式写入可替换的Sink，供现有的分析流水线使用
+package accesslog
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/origin"
+)
+
+// Entry 一条访问日志
+type Entry struct {
+	Time        time.Time     // 收到请求的时间
+	RemoteIP    string        // 客户端IP
+	User        string        // Basic认证的用户名
+	Method      string        // 请求方法
+	URI         string        // 客户端请求的路径和查询参数
+	Proto       string        // 协议版本，例如HTTP/2.0
+	Host        string        // 请求的Host
+	Status      int           // 响应状态码
+	Bytes       int64         // 响应体字节数
+	Referer     string        // Referer请求头
+	UserAgent   string        // User-Agent请求头
+	CacheStatus string        // 响应的X-Cache头，例如HIT、MISS、STALE，不经过缓存的处理器为空
+	Latency     time.Duration // 从收到请求到响应写完的时间
+	Upstream    time.Duration // 等待上游响应头的时间，见origin.Timing
+	SampleRate  float64       // 记录这条日志时的抽样比例，全部记录时为1
+}
+
+// Sink 访问日志的写入目标
+type Sink interface {
+	// Log 写入一条日志，可能被并发调用，返回后不能再使用e
+	Log(e *Entry) error
+}
+
+// SinkFunc 把函数作为Sink
+type SinkFunc func(e *Entry) error
+
+// Log 调用f
+func (f SinkFunc) Log(e *Entry) error {
+	return f(e)
+}
+
+// Options 访问日志选项
+type Options struct {
+	// Sinks 每条日志依次写入的目标，至少一个，例如NewWriterSink(os.Stdout, FormatJSON)
+	Sinks []Sink
+
+	// ErrorHandler Sink返回错误时调用，默认忽略；写日志失败不影响响应
+	ErrorHandler func(err error)
+
+	// SampleRate 随机记录的请求比例，例如0.01只记录约1%的请求，0表示全部记录
+	SampleRate float64
+
+	// KeepErrors 抽样时仍然记录所有5xx响应
+	KeepErrors bool
+}
+
+// Sampler 可以在运行中修改抽样设置的访问日志，NewHandler返回的http.Handler实现了这个接口
+type Sampler interface {
+	// SetSampling 替换Options中的SampleRate和KeepErrors，对之后完成的请求生效
+	SetSampling(rate float64, keepErrors bool) error
+}
+
+// handler 记录访问日志的中间件
+type handler struct {
+	next     http.Handler
+	opts     Options
+	sampling atomic.Pointer[sampling]
+	sample   func() float64 // 返回[0,1)的随机数
+}
+
+// sampling 当前的抽样设置
+type sampling struct {
+	rate       float64
+	keepErrors bool
+}
+
+// NewHandler 返回为每个请求记录访问日志的http.Handler，日志在next返回后写入
+func NewHandler(next http.Handler, opts Options) (http.Handler, error) {
+	if len(opts.Sinks) == 0 {
+		return nil, fmt.Errorf("access log requires at least one sink")
+	}
+	opts.Sinks = append([]Sink(nil), opts.Sinks...)
+	h := &handler{next: next, opts: opts, sample: rand.Float64}
+	if err := h.SetSampling(opts.SampleRate, opts.KeepErrors); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// SetSampling 检查并替换抽样设置，rate为0表示全部记录
+func (h *handler) SetSampling(rate float64, keepErrors bool) error {
+	if rate < 0 || rate > 1 {
+		return fmt.Errorf("invalid access log sample rate %v: must be between 0 and 1", rate)
+	}
+	if rate == 0 {
+		rate = 1
+	}
+	h.sampling.Store(&sampling{rate: rate, keepErrors: keepErrors})
+	return nil
+}
+
+// ServeHTTP 处理请求并记录日志
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	ctx, timing := origin.WithTiming(r.Context())
+	rw := &responseWriter{ResponseWriter: w}
+	h.next.ServeHTTP(rw, r.WithContext(ctx))
+
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	sampling := h.sampling.Load()
+	if sampling.rate < 1 && h.sample() >= sampling.rate && !(sampling.keepErrors && rw.status >= 500) {
+		return
+	}
+	e := &Entry{
+		Time:        start,
+		RemoteIP:    RemoteIP(r),
+		Method:      r.Method,
+		URI:         r.RequestURI,
+		Proto:       r.Proto,
+		Host:        r.Host,
+		Status:      rw.status,
+		Bytes:       rw.bytes,
+		Referer:     r.Referer(),
+		UserAgent:   r.UserAgent(),
+		CacheStatus: w.Header().Get("X-Cache"),
+		Latency:     time.Since(start),
+		Upstream:    timing.Upstream(),
+		SampleRate:  sampling.rate,
+	}
+	if user, _, ok := r.BasicAuth(); ok {
+		e.User = user
+	}
+	if e.URI == "" {
+		e.URI = r.URL.RequestURI()
+	}
+	for _, sink := range h.opts.Sinks {
+		if err := sink.Log(e); err != nil && h.opts.ErrorHandler != nil {
+			h.opts.ErrorHandler(err)
+		}
+	}
+}
+
+// responseWriter 记录状态码和响应体字节数
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader 记录第一个非1xx的状态码
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 && code >= 200 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write 记录写入的字节数
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// ReadFrom 底层的ResponseWriter支持时交给它，文件仍然可以用sendfile发送
+func (w *responseWriter) ReadFrom(src io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	var n int64
+	var err error
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(src)
+	} else {
+		n, err = io.Copy(w.ResponseWriter, src)
+	}
+	w.bytes += n
+	return n, err
+}
+
+// Flush 底层的ResponseWriter支持时发送已写入的数据
+func (w *responseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		flusher.Flush()
+	}
+}
+
+// Unwrap 返回底层的ResponseWriter，供http.ResponseController使用
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// RemoteIP 返回请求连接的远端IP，即日志中的RemoteIP，不解析X-Forwarded-For
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,216 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+	"github.com/seraphico/EdgeOrigin/pkg/origin"
+)
+
+// memorySink 保存收到的日志
+type memorySink struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+func (s *memorySink) Log(e *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, *e)
+	return nil
+}
+
+// readerFromRecorder 记录是否经过ReadFrom写入响应体
+type readerFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom bool
+}
+
+func (w *readerFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	w.readFrom = true
+	return io.Copy(w.ResponseRecorder, src)
+}
+
+func TestFormat(t *testing.T) {
+	e := &Entry{
+		Time:        time.Date(2024, 10, 10, 13, 55, 36, 0, time.FixedZone("", 8*3600)),
+		RemoteIP:    "192.0.2.1",
+		Method:      http.MethodGet,
+		URI:         "/a.js?v=1",
+		Proto:       "HTTP/1.1",
+		Host:        "cdn.example.com",
+		Status:      http.StatusOK,
+		Bytes:       2326,
+		UserAgent:   `curl/8.0 "quoted"`,
+		CacheStatus: "HIT",
+		Latency:     2500 * time.Microsecond,
+		Upstream:    0,
+	}
+
+	t.Run("combined", func(t *testing.T) {
+		want := `192.0.2.1 - - [10/Oct/2024:13:55:36 +0800] "GET /a.js?v=1 HTTP/1.1" 200 2326 "-" "curl/8.0 \"quoted\"" HIT 0.003 0.000`
+		if got := string(FormatCombined.Append(nil, e)); got != want {
+			t.Errorf("Expected\n%s\ngot\n%s", want, got)
+		}
+		evil := *e
+		evil.URI, evil.Bytes = "/a\nb", 0
+		if got := string(FormatCombined.Append(nil, &evil)); !strings.Contains(got, `"GET /a\x0ab HTTP/1.1" 200 -`) {
+			t.Errorf("Expected control characters to be escaped, got %s", got)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var got map[string]interface{}
+		if err := json.Unmarshal(FormatJSON.Append(nil, e), &got); err != nil {
+			t.Fatalf("Failed to decode json: %v", err)
+		}
+		if got["cache_status"] != "HIT" || got["status"] != 200.0 || got["bytes"] != 2326.0 || got["latency_ms"] != 2.5 || got["uri"] != "/a.js?v=1" {
+			t.Errorf("Unexpected json entry %v", got)
+		}
+		if _, ok := got["referer"]; ok {
+			t.Errorf("Expected empty referer to be omitted, got %v", got)
+		}
+	})
+
+	t.Run("writer sink", func(t *testing.T) {
+		var buf bytes.Buffer
+		sink, err := NewWriterSink(&buf, FormatJSON)
+		if err != nil {
+			t.Fatalf("Failed to create sink: %v", err)
+		}
+		sink.Log(e)
+		sink.Log(e)
+		if lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"); len(lines) != 2 {
+			t.Errorf("Expected one line per entry, got %q", buf.String())
+		}
+		if _, err := NewWriterSink(&buf, "common"); err == nil {
+			t.Error("Expected error for unknown format")
+		}
+	})
+}
+
+func TestHandler(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("hello from origin"))
+	}))
+	defer upstream.Close()
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	proxy, err := origin.NewProxy(cache, origin.Options{Upstream: upstream.URL})
+	if err != nil {
+		t.Fatalf("Failed to create proxy: %v", err)
+	}
+
+	t.Run("cache status", func(t *testing.T) {
+		sink := &memorySink{}
+		h, err := NewHandler(proxy, Options{Sinks: []Sink{sink}})
+		if err != nil {
+			t.Fatalf("Failed to create handler: %v", err)
+		}
+		for i := 0; i < 2; i++ {
+			req := httptest.NewRequest(http.MethodGet, "/hello.txt?x=1", nil)
+			req.Header.Set("User-Agent", "test")
+			h.ServeHTTP(httptest.NewRecorder(), req)
+		}
+		if len(sink.entries) != 2 {
+			t.Fatalf("Expected 2 entries, got %d", len(sink.entries))
+		}
+		miss, hit := sink.entries[0], sink.entries[1]
+		if miss.CacheStatus != "MISS" || miss.Upstream < 20*time.Millisecond || miss.Latency < miss.Upstream {
+			t.Errorf("Expected miss with upstream time, got %+v", miss)
+		}
+		if hit.CacheStatus != "HIT" || hit.Upstream != 0 || hit.Bytes != int64(len("hello from origin")) || hit.Status != http.StatusOK {
+			t.Errorf("Expected hit without upstream time, got %+v", hit)
+		}
+		if hit.URI != "/hello.txt?x=1" || hit.RemoteIP != "192.0.2.1" || hit.UserAgent != "test" {
+			t.Errorf("Expected request fields, got %+v", hit)
+		}
+	})
+
+	t.Run("status and sendfile", func(t *testing.T) {
+		sink := &memorySink{}
+		h, _ := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			io.Copy(w, io.LimitReader(strings.NewReader("missing"), 100))
+		}), Options{Sinks: []Sink{sink}})
+		rec := &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+		if !rec.readFrom {
+			t.Error("Expected ReadFrom of the underlying writer to be used")
+		}
+		if e := sink.entries[0]; e.Status != http.StatusNotFound || e.Bytes != 7 || e.CacheStatus != "" {
+			t.Errorf("Expected 404 with 7 bytes, got %+v", e)
+		}
+	})
+
+	t.Run("sampling", func(t *testing.T) {
+		sink := &memorySink{}
+		h, err := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/error" {
+				w.WriteHeader(http.StatusBadGateway)
+			}
+		}), Options{Sinks: []Sink{sink}, SampleRate: 0.25, KeepErrors: true})
+		if err != nil {
+			t.Fatalf("Failed to create handler: %v", err)
+		}
+		n := 0
+		h.(*handler).sample = func() float64 {
+			n++
+			return float64(n%4) / 4
+		}
+		for i := 0; i < 8; i++ {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+		}
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/error", nil))
+		if len(sink.entries) != 3 || sink.entries[2].Status != http.StatusBadGateway {
+			t.Fatalf("Expected 2 sampled entries and the error, got %+v", sink.entries)
+		}
+		if sink.entries[0].SampleRate != 0.25 {
+			t.Errorf("Expected sample rate on entry, got %v", sink.entries[0].SampleRate)
+		}
+		if _, err := NewHandler(proxy, Options{Sinks: []Sink{sink}, SampleRate: 2}); err == nil {
+			t.Error("Expected error for sample rate above 1")
+		}
+
+		// 运行中关闭抽样后全部记录
+		if err := h.(Sampler).SetSampling(0, false); err != nil {
+			t.Fatalf("Failed to set sampling: %v", err)
+		}
+		for i := 0; i < 4; i++ {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+		}
+		if len(sink.entries) != 7 || sink.entries[6].SampleRate != 1 {
+			t.Errorf("Expected every request to be logged after disabling sampling, got %d entries", len(sink.entries))
+		}
+		if err := h.(Sampler).SetSampling(-1, false); err == nil {
+			t.Error("Expected error for negative sample rate")
+		}
+	})
+
+	t.Run("sink error", func(t *testing.T) {
+		var errs []error
+		h, _ := NewHandler(proxy, Options{
+			Sinks:        []Sink{SinkFunc(func(e *Entry) error { return errors.New("disk full") })},
+			ErrorHandler: func(err error) { errs = append(errs, err) },
+		})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/hello.txt", nil))
+		if rec.Code != http.StatusOK || len(errs) != 1 {
+			t.Errorf("Expected response to succeed and error to be reported, got %d %v", rec.Code, errs)
+		}
+		if _, err := NewHandler(proxy, Options{}); err == nil {
+			t.Error("Expected error without sinks")
+		}
+	})
+}
@@ -0,0 +1,207 @@
+package accesslog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTime 轮转文件名中的时间布局，按字典序排列即按时间排列
+const backupTime = "20060102T150405.000"
+
+// Rotation 日志文件的轮转和清理策略，零值表示不轮转
+type Rotation struct {
+	// MaxSize 文件超过这个字节数后轮转，0表示不按大小轮转
+	MaxSize int64 `json:"max_size,omitempty"`
+
+	// Interval 按这个间隔对齐的时间点轮转（24小时为UTC零点），0表示不按时间轮转
+	Interval time.Duration `json:"interval,omitempty"`
+
+	// MaxBackups 保留的轮转文件数，超出时删除最旧的，0表示不限制
+	MaxBackups int `json:"max_backups,omitempty"`
+
+	// MaxAge 删除轮转时间早于这个时间的轮转文件，0表示不限制
+	MaxAge time.Duration `json:"max_age,omitempty"`
+}
+
+// Validate 检查策略
+func (r *Rotation) Validate() error {
+	if r.MaxSize < 0 || r.Interval < 0 || r.MaxBackups < 0 || r.MaxAge < 0 {
+		return fmt.Errorf("log rotation settings cannot be negative")
+	}
+	return nil
+}
+
+// FileSink 把日志逐行追加到文件，按Rotation轮转：当前文件改名为<path>.<时间>，再创建新的文件
+type FileSink struct {
+	path     string
+	format   Format
+	rotation Rotation
+	now      func() time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	period time.Time // 当前文件所在的时间段，按Interval轮转时使用
+	buf    []byte
+}
+
+// NewFileSink 打开（不存在时创建）path，按format写入日志；已有的文件属于更早的时间段时在第一次写入前轮转
+func NewFileSink(path string, format Format, rotation Rotation) (*FileSink, error) {
+	if err := format.validate(); err != nil {
+		return nil, err
+	}
+	if err := rotation.Validate(); err != nil {
+		return nil, err
+	}
+	s := &FileSink{path: path, format: format, rotation: rotation, now: time.Now}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// open 打开日志文件并记录它的大小和时间段
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open access log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat access log: %w", err)
+	}
+	s.file, s.size = f, info.Size()
+	if s.rotation.Interval > 0 {
+		modTime := info.ModTime()
+		if info.Size() == 0 {
+			modTime = s.now()
+		}
+		s.period = modTime.Truncate(s.rotation.Interval)
+	}
+	return nil
+}
+
+// Log 写入一行，写入前按需轮转
+func (s *FileSink) Log(e *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return fmt.Errorf("access log %s is closed", s.path)
+	}
+	s.buf = append(s.format.Append(s.buf[:0], e), '\n')
+	if s.due(int64(len(s.buf))) {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.file.Write(s.buf)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write access log: %w", err)
+	}
+	return nil
+}
+
+// due 写入n字节之前是否需要轮转，空文件不按大小轮转
+func (s *FileSink) due(n int64) bool {
+	if s.rotation.MaxSize > 0 && s.size > 0 && s.size+n > s.rotation.MaxSize {
+		return true
+	}
+	return s.rotation.Interval > 0 && s.now().Truncate(s.rotation.Interval).After(s.period)
+}
+
+// Rotate 立即轮转，例如收到SIGHUP时
+func (s *FileSink) Rotate() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return fmt.Errorf("access log %s is closed", s.path)
+	}
+	return s.rotate()
+}
+
+// rotate 关闭并改名当前文件，打开新文件后清理旧的轮转文件
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("failed to close access log: %w", err)
+	}
+	s.file = nil
+	backup := s.path + "." + s.now().UTC().Format(backupTime)
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(backup); os.IsNotExist(err) {
+			break
+		}
+		backup = fmt.Sprintf("%s.%s.%d", s.path, s.now().UTC().Format(backupTime), i)
+	}
+	if err := os.Rename(s.path, backup); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate access log: %w", err)
+	}
+	if err := s.open(); err != nil {
+		return err
+	}
+	return s.prune()
+}
+
+// prune 按MaxBackups和MaxAge删除旧的轮转文件
+func (s *FileSink) prune() error {
+	if s.rotation.MaxBackups == 0 && s.rotation.MaxAge == 0 {
+		return nil
+	}
+	backups, err := s.Backups()
+	if err != nil {
+		return err
+	}
+	cutoff := s.now().Add(-s.rotation.MaxAge)
+	prefix := filepath.Base(s.path) + "."
+	for i, backup := range backups {
+		stamp := strings.TrimPrefix(filepath.Base(backup), prefix)
+		if len(stamp) > len(backupTime) {
+			stamp = stamp[:len(backupTime)]
+		}
+		rotated, err := time.Parse(backupTime, stamp)
+		expired := s.rotation.MaxAge > 0 && err == nil && rotated.Before(cutoff)
+		excess := s.rotation.MaxBackups > 0 && i < len(backups)-s.rotation.MaxBackups
+		if expired || excess {
+			if err := os.Remove(backup); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove rotated access log: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Backups 返回轮转文件的路径，从旧到新排列
+func (s *FileSink) Backups() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Dir(s.path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rotated access logs: %w", err)
+	}
+	prefix := filepath.Base(s.path) + "."
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, prefix) && len(name) >= len(prefix)+len(backupTime) && !entry.IsDir() {
+			backups = append(backups, filepath.Join(filepath.Dir(s.path), name))
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// Close 关闭日志文件
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
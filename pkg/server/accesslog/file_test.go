@@ -0,0 +1,122 @@
+package accesslog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testEntry 返回用于写入文件的日志
+func testEntry(uri string) *Entry {
+	return &Entry{Time: time.Now(), RemoteIP: "192.0.2.1", Method: "GET", URI: uri, Proto: "HTTP/1.1", Status: 200, Bytes: 10}
+}
+
+// readLines 读取文件的所有行
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestFileSink(t *testing.T) {
+	t.Run("size", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "access.log")
+		line := len(FormatCombined.Append(nil, testEntry("/0"))) + 1
+		sink, err := NewFileSink(path, FormatCombined, Rotation{MaxSize: int64(2 * line), MaxBackups: 2})
+		if err != nil {
+			t.Fatalf("Failed to create sink: %v", err)
+		}
+		defer sink.Close()
+		now := time.Date(2024, 10, 10, 0, 0, 0, 0, time.UTC)
+		sink.now = func() time.Time { now = now.Add(time.Second); return now }
+
+		for i := 0; i < 7; i++ {
+			if err := sink.Log(testEntry("/" + string(rune('0'+i)))); err != nil {
+				t.Fatalf("Failed to log: %v", err)
+			}
+		}
+		if lines := readLines(t, path); len(lines) != 1 || !strings.Contains(lines[0], "/6") {
+			t.Errorf("Expected current file to hold the last entry, got %q", lines)
+		}
+		backups, _ := sink.Backups()
+		if len(backups) != 2 {
+			t.Fatalf("Expected 2 backups to be kept, got %v", backups)
+		}
+		if lines := readLines(t, backups[1]); len(lines) != 2 || !strings.Contains(lines[0], "/4") {
+			t.Errorf("Expected newest backup to hold entries 4 and 5, got %q", lines)
+		}
+	})
+
+	t.Run("interval", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "access.log")
+		if err := os.WriteFile(path, []byte("old\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		yesterday := time.Now().Add(-24 * time.Hour)
+		os.Chtimes(path, yesterday, yesterday)
+
+		sink, err := NewFileSink(path, FormatJSON, Rotation{Interval: time.Hour, MaxAge: 90 * time.Minute})
+		if err != nil {
+			t.Fatalf("Failed to create sink: %v", err)
+		}
+		defer sink.Close()
+		now := time.Now()
+		sink.now = func() time.Time { return now }
+
+		sink.Log(testEntry("/a"))
+		sink.Log(testEntry("/b"))
+		if backups, _ := sink.Backups(); len(backups) != 1 || readLines(t, backups[0])[0] != "old" {
+			t.Fatalf("Expected file from an earlier period to be rotated on first write, got %v", backups)
+		}
+		if lines := readLines(t, path); len(lines) != 2 {
+			t.Errorf("Expected 2 entries in current file, got %q", lines)
+		}
+
+		now = now.Add(time.Hour)
+		sink.Log(testEntry("/c"))
+		now = now.Add(time.Hour)
+		sink.Log(testEntry("/d"))
+		backups, _ := sink.Backups()
+		if len(backups) != 2 {
+			t.Fatalf("Expected backups older than max age to be removed, got %v", backups)
+		}
+		if lines := readLines(t, backups[1]); len(lines) != 1 || !strings.Contains(lines[0], `"/c"`) {
+			t.Errorf("Expected newest backup to hold entry c, got %q", lines)
+		}
+	})
+
+	t.Run("manual", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "access.log")
+		sink, err := NewFileSink(path, FormatCombined, Rotation{})
+		if err != nil {
+			t.Fatalf("Failed to create sink: %v", err)
+		}
+		sink.Log(testEntry("/a"))
+		if err := sink.Rotate(); err != nil {
+			t.Fatalf("Failed to rotate: %v", err)
+		}
+		sink.Log(testEntry("/b"))
+		if backups, _ := sink.Backups(); len(backups) != 1 {
+			t.Errorf("Expected 1 backup, got %v", backups)
+		}
+		sink.Close()
+		if err := sink.Log(testEntry("/c")); err == nil {
+			t.Error("Expected error after close")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		dir := t.TempDir()
+		if _, err := NewFileSink(filepath.Join(dir, "access.log"), FormatJSON, Rotation{MaxSize: -1}); err == nil {
+			t.Error("Expected error for negative max size")
+		}
+		if _, err := NewFileSink(filepath.Join(dir, "missing", "access.log"), FormatJSON, Rotation{}); err == nil {
+			t.Error("Expected error for missing directory")
+		}
+	})
+}
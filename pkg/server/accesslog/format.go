@@ -0,0 +1,159 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Format 访问日志的格式
+type Format string
+
+const (
+	// FormatCombined Apache combined格式，末尾追加缓存状态、总耗时和回源耗时（秒）：
+	// 192.0.2.1 - - [10/Oct/2024:13:55:36 +0800] "GET /a.js HTTP/1.1" 200 2326 "https://example.com/" "curl/8.0" HIT 0.002 0.000
+	FormatCombined Format = "combined"
+
+	// FormatJSON 每行一个JSON对象，耗时单位为毫秒；抽样记录时带有sample_rate，统计时每条按1/sample_rate个请求计算
+	FormatJSON Format = "json"
+)
+
+// combinedTime combined格式的时间布局
+const combinedTime = "02/Jan/2006:15:04:05 -0700"
+
+// jsonEntry JSON格式的一条日志
+type jsonEntry struct {
+	Time        time.Time `json:"time"`
+	RemoteIP    string    `json:"remote_ip"`
+	User        string    `json:"user,omitempty"`
+	Method      string    `json:"method"`
+	URI         string    `json:"uri"`
+	Proto       string    `json:"proto"`
+	Host        string    `json:"host"`
+	Status      int       `json:"status"`
+	Bytes       int64     `json:"bytes"`
+	Referer     string    `json:"referer,omitempty"`
+	UserAgent   string    `json:"user_agent,omitempty"`
+	CacheStatus string    `json:"cache_status,omitempty"`
+	LatencyMS   float64   `json:"latency_ms"`
+	UpstreamMS  float64   `json:"upstream_ms"`
+	SampleRate  float64   `json:"sample_rate,omitempty"`
+}
+
+// validate 检查格式
+func (f Format) validate() error {
+	switch f {
+	case FormatCombined, FormatJSON:
+		return nil
+	}
+	return fmt.Errorf("unknown access log format %q", f)
+}
+
+// Append 把e按格式追加到b，不带换行
+func (f Format) Append(b []byte, e *Entry) []byte {
+	if f == FormatJSON {
+		entry := jsonEntry{
+			Time:        e.Time,
+			RemoteIP:    e.RemoteIP,
+			User:        e.User,
+			Method:      e.Method,
+			URI:         e.URI,
+			Proto:       e.Proto,
+			Host:        e.Host,
+			Status:      e.Status,
+			Bytes:       e.Bytes,
+			Referer:     e.Referer,
+			UserAgent:   e.UserAgent,
+			CacheStatus: e.CacheStatus,
+			LatencyMS:   float64(e.Latency) / float64(time.Millisecond),
+			UpstreamMS:  float64(e.Upstream) / float64(time.Millisecond),
+		}
+		if e.SampleRate < 1 {
+			entry.SampleRate = e.SampleRate
+		}
+		data, _ := json.Marshal(entry)
+		return append(b, data...)
+	}
+
+	b = append(b, orDash(e.RemoteIP)...)
+	b = append(b, " - "...)
+	b = appendEscaped(b, orDash(e.User))
+	b = append(b, " ["...)
+	b = e.Time.AppendFormat(b, combinedTime)
+	b = append(b, `] "`...)
+	b = appendEscaped(b, e.Method+" "+e.URI+" "+e.Proto)
+	b = append(b, `" `...)
+	b = strconv.AppendInt(b, int64(e.Status), 10)
+	b = append(b, ' ')
+	if e.Bytes > 0 {
+		b = strconv.AppendInt(b, e.Bytes, 10)
+	} else {
+		b = append(b, '-')
+	}
+	b = append(b, ` "`...)
+	b = appendEscaped(b, orDash(e.Referer))
+	b = append(b, `" "`...)
+	b = appendEscaped(b, orDash(e.UserAgent))
+	b = append(b, `" `...)
+	b = appendEscaped(b, orDash(e.CacheStatus))
+	b = append(b, ' ')
+	b = strconv.AppendFloat(b, e.Latency.Seconds(), 'f', 3, 64)
+	b = append(b, ' ')
+	b = strconv.AppendFloat(b, e.Upstream.Seconds(), 'f', 3, 64)
+	return b
+}
+
+// orDash 空字符串返回"-"
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// appendEscaped 与Apache相同，把双引号、反斜杠转义，控制字符和非ASCII字节写成\xHH，一条日志总是一行
+func appendEscaped(b []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b = append(b, '\\', c)
+		case c < 0x20 || c >= 0x7f:
+			b = append(b, '\\', 'x', hex[c>>4], hex[c&0xf])
+		default:
+			b = append(b, c)
+		}
+	}
+	return b
+}
+
+// writerSink 把日志逐行写入io.Writer
+type writerSink struct {
+	format Format
+	mu     sync.Mutex
+	w      io.Writer
+	buf    []byte
+}
+
+// NewWriterSink 返回按format把每条日志写成一行的Sink，每行用一次Write写入w，可以并发使用
+func NewWriterSink(w io.Writer, format Format) (Sink, error) {
+	if err := format.validate(); err != nil {
+		return nil, err
+	}
+	return &writerSink{format: format, w: w}, nil
+}
+
+// Log 格式化并写入一行
+func (s *writerSink) Log(e *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buf = append(s.format.Append(s.buf[:0], e), '\n')
+	if _, err := s.w.Write(s.buf); err != nil {
+		return fmt.Errorf("failed to write access log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,569 @@
+// Package admin 提供可嵌入的HTTP管理接口：读写和删除文件、带过滤条件的列表、统计信息、
+// 触发清理和压缩、按前缀或标签清除、重新加载配置，所有响应都是JSON（文件内容除外）
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// TagPurger 支持按标签清除文件的缓存
+type TagPurger interface {
+	// PurgeTag 删除带有tag的所有文件，返回删除的文件数
+	PurgeTag(ctx context.Context, tag string) (int, error)
+}
+
+// Options 管理接口选项，Token、APIKeys和Authorize至少设置一个
+type Options struct {
+	// Token 请求需携带 Authorization: Bearer <Token>，持有者拥有全部权限
+	Token string
+
+	// APIKeys 按角色授权的访问密钥，通常取自filecache.Config.APIKeys
+	APIKeys []filecache.APIKey
+
+	// Authorize 自定义鉴权，设置后代替Token和APIKeys检查，通过的请求拥有全部权限
+	Authorize func(r *http.Request) bool
+
+	// TagPurger 处理按标签清除，例如回源代理（origin.Proxy）按上游的Surrogate-Key清除；
+	// 未设置时使用缓存自身实现的TagPurger
+	TagPurger TagPurger
+
+	// Upstreams 在仪表盘上显示上游的健康状态，例如回源代理（origin.Proxy或origin.Router）
+	Upstreams HealthReporter
+
+	// Reload 处理POST /reload，重新加载配置文件，例如config.Reloader.Reload；未设置时返回501
+	Reload func() error
+}
+
+// handler 管理接口
+type handler struct {
+	cache filecache.Cache
+	opts  Options
+	mux   *http.ServeMux
+	top   topKeys
+}
+
+// NewHandler 返回管理接口，路径相对于挂载点，可用http.StripPrefix挂载到子路径：
+//
+//	GET    /entries             列出文件，支持prefix、mime_type、expired、limit过滤
+//	GET    /entries/{key}       读取文件内容，加上?info=true时返回文件信息
+//	HEAD   /entries/{key}       只返回文件的响应头
+//	PUT    /entries/{key}       写入文件，Content-Type为文件类型，?ttl=1h指定TTL，?ttl=none永不过期
+//	DELETE /entries/{key}       删除文件
+//	GET    /stats               统计信息
+//	POST   /cleanup             清理过期文件
+//	POST   /compact             压缩存储，缓存需实现filecache.Compactor
+//	POST   /purge?prefix=|tag=  按前缀或标签清除文件，按前缀时可以用older_than=24h只清除创建超过该时长的文件
+//	GET    /dashboard           实时仪表盘页面，不需要鉴权，页面用访问令牌读取/dashboard/data
+//	GET    /dashboard/data      仪表盘数据：统计信息、磁盘占用、访问最多的top个键和上游健康状态
+//	POST   /reload              重新加载配置文件，需要Options.Reload
+//
+// 所有路径都可以用namespace参数指定命名空间，只读请求只能访问已经存在的命名空间
+func NewHandler(cache filecache.Cache, opts Options) (http.Handler, error) {
+	if opts.Token == "" && len(opts.APIKeys) == 0 && opts.Authorize == nil {
+		return nil, fmt.Errorf("admin api requires a token, api keys or an authorize function")
+	}
+	for _, key := range opts.APIKeys {
+		if key.Key == "" {
+			return nil, fmt.Errorf("api key %q cannot be empty", key.Name)
+		}
+		if roleLevel(key.Role) == 0 {
+			return nil, fmt.Errorf("invalid role %q for api key %q", key.Role, key.Name)
+		}
+	}
+
+	h := &handler{cache: cache, opts: opts, mux: http.NewServeMux()}
+	h.mux.HandleFunc("/entries", h.handleList)
+	h.mux.HandleFunc("/entries/", h.handleEntry)
+	h.mux.HandleFunc("/stats", h.handleStats)
+	h.mux.HandleFunc("/cleanup", h.handleCleanup)
+	h.mux.HandleFunc("/compact", h.handleCompact)
+	h.mux.HandleFunc("/purge", h.handlePurge)
+	h.mux.HandleFunc("/dashboard", h.handleDashboard)
+	h.mux.HandleFunc("/dashboard/data", h.handleDashboardData)
+	h.mux.HandleFunc("/reload", h.handleReload)
+	return h, nil
+}
+
+// ServeHTTP 鉴权并检查角色后分发请求，仪表盘页面除外
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/dashboard" {
+		h.mux.ServeHTTP(w, r)
+		return
+	}
+	role, ok := h.authenticate(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="edgeorigin"`)
+		writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+	if required := requiredRole(r); roleLevel(role) < roleLevel(required) {
+		writeError(w, http.StatusForbidden, fmt.Errorf("role %s is not allowed to %s %s", role, r.Method, r.URL.Path))
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+// authenticate 检查请求的凭据，返回持有者的角色
+func (h *handler) authenticate(r *http.Request) (filecache.AdminRole, bool) {
+	if h.opts.Authorize != nil {
+		return filecache.RoleAdmin, h.opts.Authorize(r)
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+	if h.opts.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.opts.Token)) == 1 {
+		return filecache.RoleAdmin, true
+	}
+	for _, key := range h.opts.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(key.Key)) == 1 {
+			return key.Role, true
+		}
+	}
+	return "", false
+}
+
+// roleLevel 返回角色的权限等级，未知角色为0
+func roleLevel(role filecache.AdminRole) int {
+	switch role {
+	case filecache.RoleReadOnly:
+		return 1
+	case filecache.RolePurge:
+		return 2
+	case filecache.RoleAdmin:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// requiredRole 返回请求需要的最低角色：读取只需read-only，删除文件和按前缀或标签清除需要purge，
+// 写入、清理、压缩和清除全部文件需要admin
+func requiredRole(r *http.Request) filecache.AdminRole {
+	switch {
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return filecache.RoleReadOnly
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/entries/"):
+		return filecache.RolePurge
+	case r.Method == http.MethodPost && r.URL.Path == "/purge":
+		q := r.URL.Query()
+		if q.Get("tag") != "" || q.Get("prefix") != "" {
+			return filecache.RolePurge
+		}
+	}
+	return filecache.RoleAdmin
+}
+
+// namespace 返回namespace参数指定的命名空间，没有该参数时返回缓存自身，参数出现多次时返回400。
+// GET和HEAD请求只打开已经存在的命名空间（缓存实现filecache.NamespaceLister时检查），
+// 避免只读请求在Badger中登记新的命名空间，不存在时返回404。失败时写入错误响应并返回false
+func (h *handler) namespace(w http.ResponseWriter, r *http.Request) (filecache.Cache, bool) {
+	names := r.URL.Query()["namespace"]
+	switch {
+	case len(names) == 0:
+		return h.cache, true
+	case len(names) > 1:
+		writeError(w, http.StatusBadRequest, errors.New("only one namespace parameter is allowed"))
+		return nil, false
+	case names[0] == "":
+		writeError(w, http.StatusBadRequest, errors.New("namespace cannot be empty"))
+		return nil, false
+	}
+
+	name := names[0]
+	if lister, ok := h.cache.(filecache.NamespaceLister); ok && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		existing, err := lister.Namespaces(r.Context())
+		if err != nil {
+			writeCacheError(w, err)
+			return nil, false
+		}
+		found := false
+		for _, ns := range existing {
+			if ns == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			writeError(w, http.StatusNotFound, fmt.Errorf("namespace %q not found", name))
+			return nil, false
+		}
+	}
+	return h.cache.Namespace(name), true
+}
+
+// handleList 列出文件
+func (h *handler) handleList(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	filter, err := parseFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	cache, ok := h.namespace(w, r)
+	if !ok {
+		return
+	}
+	files, err := cache.List(r.Context())
+	if err != nil {
+		writeCacheError(w, err)
+		return
+	}
+
+	matched := make([]*filecache.FileInfo, 0, len(files))
+	for _, info := range files {
+		if filter.match(info, time.Now()) {
+			matched = append(matched, info)
+		}
+	}
+	total := len(matched)
+	if filter.limit > 0 && len(matched) > filter.limit {
+		matched = matched[:filter.limit]
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"files": matched, "total": total})
+}
+
+// listFilter 列表过滤条件
+type listFilter struct {
+	prefix   string
+	mimeType string
+	expired  *bool
+	limit    int
+}
+
+// parseFilter 解析列表过滤参数
+func parseFilter(r *http.Request) (*listFilter, error) {
+	q := r.URL.Query()
+	f := &listFilter{prefix: q.Get("prefix"), mimeType: q.Get("mime_type")}
+	if v := q.Get("expired"); v != "" {
+		expired, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expired %q", v)
+		}
+		f.expired = &expired
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid limit %q", v)
+		}
+		f.limit = limit
+	}
+	return f, nil
+}
+
+// match 返回文件是否满足过滤条件
+func (f *listFilter) match(info *filecache.FileInfo, now time.Time) bool {
+	if !strings.HasPrefix(info.Key, f.prefix) {
+		return false
+	}
+	if f.mimeType != "" && info.MimeType != f.mimeType {
+		return false
+	}
+	if f.expired != nil && now.After(info.ExpiresAt) != *f.expired {
+		return false
+	}
+	return true
+}
+
+// handleEntry 读写单个文件
+func (h *handler) handleEntry(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/entries/")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, errors.New("key cannot be empty"))
+		return
+	}
+	cache, ok := h.namespace(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if info, _ := strconv.ParseBool(r.URL.Query().Get("info")); info {
+			fileInfo, err := cache.GetInfo(r.Context(), key)
+			if err != nil {
+				writeCacheError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, fileInfo)
+			return
+		}
+		h.serveEntry(w, r, cache, key)
+
+	case http.MethodPut:
+		ttl := time.Duration(0)
+		if v := r.URL.Query().Get("ttl"); v == "none" {
+			ttl = filecache.NoExpiry
+		} else if v != "" {
+			var err error
+			if ttl, err = time.ParseDuration(v); err != nil || ttl < 0 {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid ttl %q", v))
+				return
+			}
+		}
+		mimeType := r.Header.Get("Content-Type")
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		if err := cache.Set(r.Context(), key, r.Body, mimeType, ttl); err != nil {
+			writeCacheError(w, err)
+			return
+		}
+		info, err := cache.GetInfo(r.Context(), key)
+		if err != nil {
+			writeCacheError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, info)
+
+	case http.MethodDelete:
+		if err := cache.Delete(r.Context(), key); err != nil {
+			writeCacheError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		allowMethods(w, r, http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete)
+	}
+}
+
+// serveEntry 返回文件内容
+func (h *handler) serveEntry(w http.ResponseWriter, r *http.Request, cache filecache.Cache, key string) {
+	reader, info, err := cache.Get(r.Context(), key)
+	if err != nil {
+		writeCacheError(w, err)
+		return
+	}
+	defer reader.Close()
+
+	header := w.Header()
+	header.Set("Content-Type", info.MimeType)
+	header.Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	header.Set("Last-Modified", info.CreatedAt.UTC().Format(http.TimeFormat))
+	header.Set("Expires", info.ExpiresAt.UTC().Format(http.TimeFormat))
+	header.Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", path.Base(key)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		io.Copy(w, reader)
+	}
+}
+
+// handleStats 返回统计信息
+func (h *handler) handleStats(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	cache, ok := h.namespace(w, r)
+	if !ok {
+		return
+	}
+	stats, err := cache.Stats()
+	if err != nil {
+		writeCacheError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleCleanup 清理过期文件，支持增量清理的缓存返回清理结果
+func (h *handler) handleCleanup(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	cache, ok := h.namespace(w, r)
+	if !ok {
+		return
+	}
+	if cleaner, ok := cache.(filecache.IncrementalCleaner); ok {
+		result, err := cleaner.CleanupWithOptions(r.Context(), filecache.CleanupOptions{})
+		if err != nil {
+			writeCacheError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	if err := cache.Cleanup(r.Context()); err != nil {
+		writeCacheError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &filecache.CleanupResult{Done: true})
+}
+
+// handleCompact 压缩存储
+func (h *handler) handleCompact(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	cache, ok := h.namespace(w, r)
+	if !ok {
+		return
+	}
+	compactor, ok := cache.(filecache.Compactor)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, errors.New("compaction is not supported"))
+		return
+	}
+	start := time.Now()
+	if err := compactor.Compact(r.Context()); err != nil {
+		writeCacheError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"duration": time.Since(start).String()})
+}
+
+// handleReload 重新加载配置文件
+func (h *handler) handleReload(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	if h.opts.Reload == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("config reload is not supported"))
+		return
+	}
+	start := time.Now()
+	if err := h.opts.Reload(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"duration": time.Since(start).String()})
+}
+
+// handlePurge 按前缀或标签删除文件
+func (h *handler) handlePurge(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	q := r.URL.Query()
+	prefix, tag := q.Get("prefix"), q.Get("tag")
+	cache, ok := h.namespace(w, r)
+	if !ok {
+		return
+	}
+	var olderThan time.Duration
+	if s := q.Get("older_than"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid older_than %q", s))
+			return
+		}
+		olderThan = d
+	}
+
+	switch {
+	case tag != "" && prefix != "":
+		writeError(w, http.StatusBadRequest, errors.New("prefix and tag cannot be combined"))
+
+	case tag != "" && olderThan > 0:
+		writeError(w, http.StatusBadRequest, errors.New("older_than cannot be combined with tag"))
+
+	case tag != "" && h.opts.TagPurger != nil && q.Has("namespace"):
+		// Options.TagPurger清除所有命名空间中的标签，不能只清除一个命名空间
+		writeError(w, http.StatusBadRequest, errors.New("namespace cannot be combined with tag"))
+
+	case tag != "":
+		purger, ok := h.opts.TagPurger, h.opts.TagPurger != nil
+		if !ok {
+			purger, ok = cache.(TagPurger)
+		}
+		if !ok {
+			writeError(w, http.StatusNotImplemented, errors.New("purge by tag is not supported"))
+			return
+		}
+		purged, err := purger.PurgeTag(r.Context(), tag)
+		if err != nil {
+			writeCacheError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"purged": purged})
+
+	case prefix != "" || q.Has("prefix"):
+		purged, err := purgePrefix(r.Context(), cache, prefix, olderThan)
+		if err != nil {
+			writeCacheError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"purged": purged})
+
+	default:
+		writeError(w, http.StatusBadRequest, errors.New("prefix or tag is required"))
+	}
+}
+
+// purgePrefix 删除键以prefix开头的所有文件，prefix为空时删除全部文件但保留统计信息；
+// olderThan大于0时只删除创建时间早于olderThan之前的文件
+func purgePrefix(ctx context.Context, cache filecache.Cache, prefix string, olderThan time.Duration) (int, error) {
+	files, err := cache.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-olderThan)
+	purged := 0
+	for _, info := range files {
+		if !strings.HasPrefix(info.Key, prefix) || (olderThan > 0 && !info.CreatedAt.Before(cutoff)) {
+			continue
+		}
+		if err := cache.Delete(ctx, info.Key); err != nil && !errors.Is(err, filecache.ErrNotFound) {
+			return purged, fmt.Errorf("failed to delete %s: %w", info.Key, err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// allowMethods 检查请求方法，不允许时返回405
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	return false
+}
+
+// writeCacheError 把缓存错误转换为HTTP状态码
+func writeCacheError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, filecache.ErrNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, filecache.ErrEntryTooLarge):
+		code = http.StatusRequestEntityTooLarge
+	case errors.Is(err, filecache.ErrQuotaExceeded):
+		code = http.StatusInsufficientStorage
+	case errors.Is(err, filecache.ErrCacheClosed):
+		code = http.StatusServiceUnavailable
+	}
+	writeError(w, code, err)
+}
+
+// writeError 返回JSON格式的错误
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// writeJSON 返回JSON响应
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
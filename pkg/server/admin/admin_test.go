@@ -0,0 +1,424 @@
+package admin
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/internal/tlstest"
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+	"github.com/seraphico/EdgeOrigin/pkg/server/tlsutil"
+)
+
+// do 发送带令牌的请求
+func do(t *testing.T, srv *httptest.Server, method, path, body string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	if body != "" {
+		req.Header.Set("Content-Type", "text/plain")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// decode 解析JSON响应
+func decode(t *testing.T, resp *http.Response, v interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	cache, err := filecache.NewBadgerCache(&filecache.Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1 << 20,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	handler, err := NewHandler(cache, Options{Token: "secret"})
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	srv := httptest.NewServer(http.StripPrefix("/admin", handler))
+	defer srv.Close()
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/admin/stats")
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected 401, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("PutGetDelete", func(t *testing.T) {
+		resp := do(t, srv, http.MethodPut, "/admin/entries/images/logo.txt?ttl=10m", "logo")
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d", resp.StatusCode)
+		}
+		var info filecache.FileInfo
+		decode(t, resp, &info)
+		if info.Key != "images/logo.txt" || info.Size != 4 {
+			t.Errorf("Unexpected file info: %+v", info)
+		}
+		if ttl := time.Until(info.ExpiresAt); ttl > 10*time.Minute || ttl < 9*time.Minute {
+			t.Errorf("Expected TTL of 10 minutes, got %v", ttl)
+		}
+
+		resp = do(t, srv, http.MethodGet, "/admin/entries/images/logo.txt", "")
+		data, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK || string(data) != "logo" {
+			t.Errorf("Expected 200 with 'logo', got %d with %q", resp.StatusCode, data)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "text/plain" {
+			t.Errorf("Expected text/plain, got %q", ct)
+		}
+
+		resp = do(t, srv, http.MethodDelete, "/admin/entries/images/logo.txt", "")
+		if resp.StatusCode != http.StatusNoContent {
+			t.Errorf("Expected 204, got %d", resp.StatusCode)
+		}
+		resp = do(t, srv, http.MethodGet, "/admin/entries/images/logo.txt?info=true", "")
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected 404, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("PutNoExpiry", func(t *testing.T) {
+		resp := do(t, srv, http.MethodPut, "/admin/entries/fonts/app.woff2?ttl=none", "font")
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d", resp.StatusCode)
+		}
+		var info filecache.FileInfo
+		decode(t, resp, &info)
+		if !info.NeverExpires() {
+			t.Errorf("Expected entry to never expire, got %v", info.ExpiresAt)
+		}
+		do(t, srv, http.MethodDelete, "/admin/entries/fonts/app.woff2", "")
+
+		resp = do(t, srv, http.MethodPut, "/admin/entries/fonts/app.woff2?ttl=-1s", "font")
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 for negative ttl, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		if resp := do(t, srv, http.MethodPut, "/admin/entries/short.txt?ttl=20ms", "short"); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d", resp.StatusCode)
+		}
+		time.Sleep(50 * time.Millisecond)
+
+		// 已过期但尚未清理的文件视为不存在
+		for _, method := range []string{http.MethodGet, http.MethodHead} {
+			for _, path := range []string{"/admin/entries/short.txt", "/admin/entries/short.txt?info"} {
+				if resp := do(t, srv, method, path, ""); resp.StatusCode != http.StatusNotFound {
+					t.Errorf("Expected 404 for %s %s, got %d", method, path, resp.StatusCode)
+				}
+			}
+		}
+		do(t, srv, http.MethodDelete, "/admin/entries/short.txt", "")
+	})
+
+	t.Run("ListWithFilters", func(t *testing.T) {
+		for _, key := range []string{"css/a.css", "css/b.css", "js/app.js"} {
+			do(t, srv, http.MethodPut, "/admin/entries/"+key, key)
+		}
+		do(t, srv, http.MethodPut, "/admin/entries/css/c.css?namespace=tenant", "c")
+
+		var list struct {
+			Files []filecache.FileInfo `json:"files"`
+			Total int                  `json:"total"`
+		}
+		decode(t, do(t, srv, http.MethodGet, "/admin/entries?prefix=css/&limit=1", ""), &list)
+		if list.Total != 2 || len(list.Files) != 1 {
+			t.Errorf("Expected 1 of 2 css files, got %d of %d", len(list.Files), list.Total)
+		}
+		decode(t, do(t, srv, http.MethodGet, "/admin/entries?namespace=tenant", ""), &list)
+		if list.Total != 1 || list.Files[0].Key != "css/c.css" {
+			t.Errorf("Expected only the tenant file, got %+v", list.Files)
+		}
+		decode(t, do(t, srv, http.MethodGet, "/admin/entries?expired=true", ""), &list)
+		if list.Total != 0 {
+			t.Errorf("Expected no expired files, got %d", list.Total)
+		}
+	})
+
+	t.Run("NamespaceParameter", func(t *testing.T) {
+		if resp := do(t, srv, http.MethodGet, "/admin/entries?namespace=tenant&namespace=images", ""); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 for repeated namespace, got %d", resp.StatusCode)
+		}
+		// 只读请求不创建命名空间
+		for _, path := range []string{"/admin/entries?namespace=ghost", "/admin/stats?namespace=ghost", "/admin/entries/a.txt?namespace=ghost"} {
+			if resp := do(t, srv, http.MethodGet, path, ""); resp.StatusCode != http.StatusNotFound {
+				t.Errorf("Expected 404 for %s, got %d", path, resp.StatusCode)
+			}
+		}
+		if resp := do(t, srv, http.MethodHead, "/admin/entries/a.txt?namespace=ghost", ""); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected 404 for HEAD, got %d", resp.StatusCode)
+		}
+		names, err := cache.(filecache.NamespaceLister).Namespaces(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to list namespaces: %v", err)
+		}
+		for _, name := range names {
+			if name == "ghost" {
+				t.Error("Expected read-only requests not to register the namespace")
+			}
+		}
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		var result map[string]int
+		decode(t, do(t, srv, http.MethodPost, "/admin/purge?prefix=css/", ""), &result)
+		if result["purged"] != 2 {
+			t.Errorf("Expected 2 purged files, got %d", result["purged"])
+		}
+		if exists, _ := cache.Exists(context.Background(), "js/app.js"); !exists {
+			t.Error("Expected files outside the prefix to be kept")
+		}
+		if exists, _ := cache.Namespace("tenant").Exists(context.Background(), "css/c.css"); !exists {
+			t.Error("Expected other namespaces to be kept")
+		}
+
+		decode(t, do(t, srv, http.MethodPost, "/admin/purge?prefix=js/&older_than=1h", ""), &result)
+		if result["purged"] != 0 {
+			t.Errorf("Expected recent files to be kept, got %d purged", result["purged"])
+		}
+		for _, query := range []string{"prefix=js/&older_than=soon", "tag=product-42&older_than=1h"} {
+			if resp := do(t, srv, http.MethodPost, "/admin/purge?"+query, ""); resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("Expected 400 for %s, got %d", query, resp.StatusCode)
+			}
+		}
+
+		resp := do(t, srv, http.MethodPost, "/admin/purge?tag=product-42", "")
+		if resp.StatusCode != http.StatusNotImplemented {
+			t.Errorf("Expected 501 for tag purge, got %d", resp.StatusCode)
+		}
+		resp = do(t, srv, http.MethodPost, "/admin/purge", "")
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 without prefix or tag, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Maintenance", func(t *testing.T) {
+		var result filecache.CleanupResult
+		decode(t, do(t, srv, http.MethodPost, "/admin/cleanup", ""), &result)
+		if !result.Done {
+			t.Error("Expected cleanup to finish")
+		}
+		resp := do(t, srv, http.MethodPost, "/admin/compact", "")
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected 200 for compaction, got %d", resp.StatusCode)
+		}
+		resp = do(t, srv, http.MethodGet, "/admin/compact", "")
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("Expected 405, got %d", resp.StatusCode)
+		}
+
+		var stats filecache.Stats
+		decode(t, do(t, srv, http.MethodGet, "/admin/stats", ""), &stats)
+		if stats.TotalFiles != 1 {
+			t.Errorf("Expected 1 file in root namespace, got %d", stats.TotalFiles)
+		}
+	})
+
+	t.Run("Reload", func(t *testing.T) {
+		if resp := do(t, srv, http.MethodPost, "/admin/reload", ""); resp.StatusCode != http.StatusNotImplemented {
+			t.Errorf("Expected 501 without a reload function, got %d", resp.StatusCode)
+		}
+
+		reloads := 0
+		var reloadErr error
+		reloading, err := NewHandler(cache, Options{Token: "secret", Reload: func() error {
+			reloads++
+			return reloadErr
+		}})
+		if err != nil {
+			t.Fatalf("Failed to create handler: %v", err)
+		}
+		srv := httptest.NewServer(http.StripPrefix("/admin", reloading))
+		defer srv.Close()
+		if resp := do(t, srv, http.MethodPost, "/admin/reload", ""); resp.StatusCode != http.StatusOK || reloads != 1 {
+			t.Errorf("Expected reload to succeed, got %d after %d reloads", resp.StatusCode, reloads)
+		}
+		reloadErr = errors.New("invalid config")
+		var body map[string]string
+		resp := do(t, srv, http.MethodPost, "/admin/reload", "")
+		decode(t, resp, &body)
+		if resp.StatusCode != http.StatusInternalServerError || body["error"] != "invalid config" {
+			t.Errorf("Expected reload error to be reported, got %d %v", resp.StatusCode, body)
+		}
+	})
+}
+
+func TestRoles(t *testing.T) {
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+
+	handler, err := NewHandler(cache, Options{APIKeys: []filecache.APIKey{
+		{Name: "dashboard", Key: "viewer", Role: filecache.RoleReadOnly},
+		{Name: "sre", Key: "purger", Role: filecache.RolePurge},
+		{Name: "ops", Key: "root", Role: filecache.RoleAdmin},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	status := func(key, method, path string) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader("x"))
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		key, method, path string
+		want              int
+	}{
+		{"root", http.MethodPut, "/entries/a.txt", http.StatusCreated},
+		{"root", http.MethodPut, "/entries/b.txt", http.StatusCreated},
+		{"viewer", http.MethodGet, "/entries", http.StatusOK},
+		{"viewer", http.MethodGet, "/entries/a.txt", http.StatusOK},
+		{"viewer", http.MethodGet, "/stats", http.StatusOK},
+		{"viewer", http.MethodDelete, "/entries/a.txt", http.StatusForbidden},
+		{"viewer", http.MethodPost, "/purge?prefix=a", http.StatusForbidden},
+		{"purger", http.MethodPut, "/entries/c.txt", http.StatusForbidden},
+		{"purger", http.MethodPost, "/cleanup", http.StatusForbidden},
+		{"purger", http.MethodPost, "/compact", http.StatusForbidden},
+		{"purger", http.MethodPost, "/purge?prefix=", http.StatusForbidden},
+		{"purger", http.MethodPost, "/purge?prefix=a", http.StatusOK},
+		{"purger", http.MethodDelete, "/entries/b.txt", http.StatusNoContent},
+		{"root", http.MethodPost, "/purge?prefix=", http.StatusOK},
+		{"unknown", http.MethodGet, "/stats", http.StatusUnauthorized},
+		{"", http.MethodGet, "/stats", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if got := status(tt.key, tt.method, tt.path); got != tt.want {
+			t.Errorf("%s %s with key %q: expected %d, got %d", tt.method, tt.path, tt.key, tt.want, got)
+		}
+	}
+
+	if _, err := NewHandler(cache, Options{APIKeys: []filecache.APIKey{{Name: "x", Key: "k", Role: "root"}}}); err == nil {
+		t.Error("Expected error for unknown role")
+	}
+	if _, err := NewHandler(cache, Options{}); err == nil {
+		t.Error("Expected error without credentials")
+	}
+}
+
+// fakeTagPurger 记录按标签清除的请求
+type fakeTagPurger struct {
+	tags []string
+}
+
+func (f *fakeTagPurger) PurgeTag(ctx context.Context, tag string) (int, error) {
+	f.tags = append(f.tags, tag)
+	return 3, nil
+}
+
+func TestTagPurgerOption(t *testing.T) {
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	purger := &fakeTagPurger{}
+	handler, err := NewHandler(cache, Options{Token: "secret", TagPurger: purger})
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	var result map[string]int
+	decode(t, do(t, srv, http.MethodPost, "/purge?tag=product-42", ""), &result)
+	if result["purged"] != 3 || len(purger.tags) != 1 || purger.tags[0] != "product-42" {
+		t.Errorf("Expected tag purge to use the configured purger, got %v %v", result, purger.tags)
+	}
+
+	// 配置的TagPurger清除所有命名空间，不能限定到一个命名空间
+	if resp := do(t, srv, http.MethodPost, "/purge?tag=product-42&namespace=site", ""); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for tag with namespace, got %d", resp.StatusCode)
+	}
+	if len(purger.tags) != 1 {
+		t.Errorf("Expected the purger not to be called, got %v", purger.tags)
+	}
+}
+
+func TestTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := tlstest.NewCA(t, dir, "edge-ca")
+	serverCert, serverKey := ca.Issue("edge-1", 2, x509.ExtKeyUsageServerAuth)
+	operatorCert, operatorKey := ca.Issue("operator", 3, x509.ExtKeyUsageClientAuth)
+
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	handler, err := NewHandler(cache, Options{Token: "secret"})
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+
+	// 管理接口要求客户端证书，令牌之外再校验对端身份
+	lis, err := tlsutil.Listen("127.0.0.1:0", &tlsutil.Config{
+		CertFile:     serverCert,
+		KeyFile:      serverKey,
+		ClientCAFile: ca.File,
+	})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	srv := &http.Server{Handler: handler}
+	go srv.Serve(lis)
+	defer srv.Close()
+
+	stats := func(cfg *tlsutil.Config) (*http.Response, error) {
+		tlsConfig, err := cfg.ClientConfig()
+		if err != nil {
+			t.Fatalf("Failed to create client config: %v", err)
+		}
+		req, _ := http.NewRequest(http.MethodGet, "https://"+lis.Addr().String()+"/stats", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		return client.Do(req)
+	}
+
+	resp, err := stats(&tlsutil.Config{CertFile: operatorCert, KeyFile: operatorKey, CAFile: ca.File, ServerName: "edge-1"})
+	if err != nil {
+		t.Fatalf("Failed to get stats over tls: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200, got %d", resp.StatusCode)
+	}
+	if _, err := stats(&tlsutil.Config{CAFile: ca.File, ServerName: "edge-1"}); err == nil {
+		t.Error("Expected client without certificate to be rejected")
+	}
+}
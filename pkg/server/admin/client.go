@@ -0,0 +1,332 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// ClientOptions 管理接口客户端选项
+type ClientOptions struct {
+	// Token 访问令牌或APIKey，请求时放在Authorization: Bearer头中
+	Token string
+
+	// HTTPClient 发送请求的客户端，为空时使用新建的http.Client；地址为unix://时忽略
+	HTTPClient *http.Client
+}
+
+// Client 通过管理接口（NewHandler）访问远程节点的缓存，实现filecache.Cache、filecache.IncrementalCleaner和TagPurger，
+// 用于命令行等工具。远程节点的错误转换回filecache中的错误，可以用errors.Is判断
+type Client struct {
+	base  *url.URL
+	opts  ClientOptions
+	owned bool     // HTTPClient由NewClient创建，根客户端关闭时关闭空闲连接
+	path  []string // 命名空间路径，根客户端为空
+
+	namespaces map[string]*Client
+	nsMu       sync.Mutex
+}
+
+// NewClient 创建管理接口的客户端，addr为管理接口的挂载地址，例如http://127.0.0.1:9090/admin；
+// unix:///run/edgeorigin/admin.sock表示通过Unix套接字访问（见ListenUnix），管理接口挂载在根路径
+func NewClient(addr string, opts ClientOptions) (*Client, error) {
+	base, err := url.Parse(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid admin address %q: %w", addr, err)
+	}
+
+	c := &Client{opts: opts, namespaces: make(map[string]*Client)}
+	switch base.Scheme {
+	case "http", "https":
+		if base.Host == "" {
+			return nil, fmt.Errorf("invalid admin address %q: missing host", addr)
+		}
+		if c.opts.HTTPClient == nil {
+			c.opts.HTTPClient = &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}
+			c.owned = true
+		}
+	case "unix":
+		socket := base.Path
+		if socket == "" {
+			return nil, fmt.Errorf("invalid admin address %q: missing socket path", addr)
+		}
+		c.opts.HTTPClient = &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}}
+		c.owned = true
+		base = &url.URL{Scheme: "http", Host: "unix"}
+	default:
+		return nil, fmt.Errorf("invalid admin address %q: scheme must be http, https or unix", addr)
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/")
+	base.RawQuery = ""
+	c.base = base
+	return c, nil
+}
+
+// url 返回管理接口路径p的地址，带上命名空间参数
+func (c *Client) url(p string, query url.Values) string {
+	u := *c.base
+	u.Path += p
+	if query == nil {
+		query = url.Values{}
+	}
+	for _, name := range c.path {
+		query.Add("namespace", name)
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// do 发送请求，非2xx响应转换为错误
+func (c *Client) do(ctx context.Context, method, p string, query url.Values, body io.Reader, header http.Header) (*http.Response, error) {
+	if len(c.path) > 1 {
+		return nil, errNestedNamespace
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.url(p, query), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if c.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.opts.Token)
+	}
+	resp, err := c.opts.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	return nil, responseError(method, p, resp)
+}
+
+// doJSON 发送请求并把JSON响应解码到v，v为nil时丢弃响应
+func (c *Client) doJSON(ctx context.Context, method, p string, query url.Values, v interface{}) error {
+	resp, err := c.do(ctx, method, p, query, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if v == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", p, err)
+	}
+	return nil
+}
+
+// responseError 把错误响应转换回缓存错误，使调用方可以用errors.Is判断
+func responseError(method, p string, resp *http.Response) error {
+	message := resp.Status
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body); err == nil && body.Error != "" {
+		message = body.Error
+	}
+
+	var sentinel error
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		sentinel = filecache.ErrNotFound
+	case http.StatusRequestEntityTooLarge:
+		sentinel = filecache.ErrEntryTooLarge
+	case http.StatusInsufficientStorage:
+		sentinel = filecache.ErrQuotaExceeded
+	case http.StatusServiceUnavailable:
+		sentinel = filecache.ErrCacheClosed
+	default:
+		return fmt.Errorf("%s %s: %s", method, p, message)
+	}
+	// 远程的错误信息通常已经以哨兵错误的文本开头
+	message = strings.TrimPrefix(strings.TrimPrefix(message, sentinel.Error()), ": ")
+	if message == "" {
+		return sentinel
+	}
+	return fmt.Errorf("%w: %s", sentinel, message)
+}
+
+// entryPath 返回文件的管理接口路径
+func entryPath(key string) string {
+	return "/entries/" + key
+}
+
+// Set 上传文件，ttl为filecache.NoExpiry时永不过期，其他非正数使用远程节点的默认TTL
+func (c *Client) Set(ctx context.Context, key string, data io.Reader, mimeType string, ttl time.Duration) error {
+	query := url.Values{}
+	if ttl == filecache.NoExpiry {
+		query.Set("ttl", "none")
+	} else if ttl > 0 {
+		query.Set("ttl", ttl.String())
+	}
+	header := http.Header{}
+	if mimeType != "" {
+		header.Set("Content-Type", mimeType)
+	}
+	resp, err := c.do(ctx, http.MethodPut, entryPath(key), query, data, header)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// Get 下载文件，调用方负责关闭返回的reader。返回的文件信息取自响应头，只有键、大小、类型、
+// 创建时间和过期时间，时间精确到秒；需要完整信息时使用GetInfo
+func (c *Client) Get(ctx context.Context, key string) (io.ReadCloser, *filecache.FileInfo, error) {
+	resp, err := c.do(ctx, http.MethodGet, entryPath(key), nil, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	info := &filecache.FileInfo{Key: key, Size: resp.ContentLength, MimeType: resp.Header.Get("Content-Type")}
+	info.CreatedAt, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	info.ExpiresAt, _ = http.ParseTime(resp.Header.Get("Expires"))
+	return resp.Body, info, nil
+}
+
+// Exists 检查文件是否存在
+func (c *Client) Exists(ctx context.Context, key string) (bool, error) {
+	resp, err := c.do(ctx, http.MethodHead, entryPath(key), nil, nil, nil)
+	if errors.Is(err, filecache.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+// Delete 删除文件
+func (c *Client) Delete(ctx context.Context, key string) error {
+	return c.doJSON(ctx, http.MethodDelete, entryPath(key), nil, nil)
+}
+
+// List 列出命名空间中的所有文件
+func (c *Client) List(ctx context.Context) ([]*filecache.FileInfo, error) {
+	var resp struct {
+		Files []*filecache.FileInfo `json:"files"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, "/entries", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Files, nil
+}
+
+// GetInfo 获取文件信息
+func (c *Client) GetInfo(ctx context.Context, key string) (*filecache.FileInfo, error) {
+	info := new(filecache.FileInfo)
+	if err := c.doJSON(ctx, http.MethodGet, entryPath(key), url.Values{"info": {"true"}}, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Cleanup 清理远程节点上的过期文件
+func (c *Client) Cleanup(ctx context.Context) error {
+	_, err := c.CleanupWithOptions(ctx, filecache.CleanupOptions{})
+	return err
+}
+
+// CleanupWithOptions 清理远程节点上的过期文件并返回清理结果；管理接口总是一次清理整个键空间，
+// 只接受零值的opts
+func (c *Client) CleanupWithOptions(ctx context.Context, opts filecache.CleanupOptions) (*filecache.CleanupResult, error) {
+	if opts.MaxEntries != 0 || opts.MaxDuration != 0 || opts.BatchSize != 0 || opts.Cursor != "" || opts.Progress != nil {
+		return nil, errors.New("cleanup options are not supported by the admin api")
+	}
+	result := new(filecache.CleanupResult)
+	if err := c.doJSON(ctx, http.MethodPost, "/cleanup", nil, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Flush 删除远程节点上命名空间中的所有文件；管理接口不重置统计信息
+func (c *Client) Flush(ctx context.Context) error {
+	return c.doJSON(ctx, http.MethodPost, "/purge", url.Values{"prefix": {""}}, nil)
+}
+
+// PurgePrefix 删除远程节点上命名空间中键以prefix开头的文件，olderThan大于0时只删除创建超过该时长的文件，
+// 返回删除的文件数。prefix为空表示全部文件，需要admin角色
+func (c *Client) PurgePrefix(ctx context.Context, prefix string, olderThan time.Duration) (int, error) {
+	query := url.Values{"prefix": {prefix}}
+	if olderThan > 0 {
+		query.Set("older_than", olderThan.String())
+	}
+	var result struct {
+		Purged int `json:"purged"`
+	}
+	if err := c.doJSON(ctx, http.MethodPost, "/purge", query, &result); err != nil {
+		return 0, err
+	}
+	return result.Purged, nil
+}
+
+// PurgeTag 删除远程节点上带有tag的文件，返回删除的文件数，实现TagPurger
+func (c *Client) PurgeTag(ctx context.Context, tag string) (int, error) {
+	var result struct {
+		Purged int `json:"purged"`
+	}
+	if err := c.doJSON(ctx, http.MethodPost, "/purge", url.Values{"tag": {tag}}, &result); err != nil {
+		return 0, err
+	}
+	return result.Purged, nil
+}
+
+// errNestedNamespace 管理接口只接受一个namespace参数
+var errNestedNamespace = errors.New("nested namespaces are not supported by the admin api")
+
+// Namespace 返回远程节点上命名空间的客户端，共享同一个HTTPClient；管理接口不支持嵌套的命名空间，
+// 嵌套命名空间的客户端的所有操作都返回错误
+func (c *Client) Namespace(name string) filecache.Cache {
+	if name == "" {
+		return c
+	}
+
+	c.nsMu.Lock()
+	defer c.nsMu.Unlock()
+
+	if ns, ok := c.namespaces[name]; ok {
+		return ns
+	}
+	ns := &Client{base: c.base, opts: c.opts, namespaces: make(map[string]*Client)}
+	ns.path = append(append([]string(nil), c.path...), name)
+	c.namespaces[name] = ns
+	return ns
+}
+
+// Close 关闭NewClient创建的HTTPClient的空闲连接，使用调用方的HTTPClient时和命名空间的Close不做任何事
+func (c *Client) Close() error {
+	if c.owned {
+		c.opts.HTTPClient.CloseIdleConnections()
+	}
+	return nil
+}
+
+// Stats 获取远程节点上命名空间的统计信息
+func (c *Client) Stats() (*filecache.Stats, error) {
+	stats := new(filecache.Stats)
+	if err := c.doJSON(context.Background(), http.MethodGet, "/stats", nil, stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
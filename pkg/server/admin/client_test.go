@@ -0,0 +1,203 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	remote, err := filecache.NewMemoryCacheWithConfig(&filecache.Config{
+		MaxCacheSize:    1 << 20,
+		MaxEntrySize:    1 << 10,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer remote.Close()
+
+	handler, err := NewHandler(remote, Options{Token: "secret"})
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/admin/", http.StripPrefix("/admin", handler))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL+"/admin/", ClientOptions{Token: "secret"})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	var client filecache.Cache = c
+	defer client.Close()
+
+	t.Run("SetAndGet", func(t *testing.T) {
+		if err := client.Set(ctx, "dir/hello world.txt", strings.NewReader("hello world"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		reader, info, err := client.Get(ctx, "dir/hello world.txt")
+		if err != nil {
+			t.Fatalf("Failed to get file: %v", err)
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		if string(data) != "hello world" {
+			t.Errorf("Expected 'hello world', got %q", data)
+		}
+		if info.Size != 11 || info.MimeType != "text/plain" || time.Until(info.ExpiresAt) < 59*time.Minute {
+			t.Errorf("Expected 11 bytes of text/plain expiring in an hour, got %+v", info)
+		}
+
+		info, err = client.GetInfo(ctx, "dir/hello world.txt")
+		if err != nil {
+			t.Fatalf("Failed to get info: %v", err)
+		}
+		if info.Key != "dir/hello world.txt" || info.Size != 11 {
+			t.Errorf("Unexpected info: %+v", info)
+		}
+	})
+
+	t.Run("NoExpiry", func(t *testing.T) {
+		if err := client.Set(ctx, "pinned", strings.NewReader("data"), "text/plain", filecache.NoExpiry); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		info, err := client.GetInfo(ctx, "pinned")
+		if err != nil {
+			t.Fatalf("Failed to get info: %v", err)
+		}
+		if !info.NeverExpires() {
+			t.Errorf("Expected pinned entry to never expire, got %v", info.ExpiresAt)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		if _, _, err := client.Get(ctx, "missing"); !errors.Is(err, filecache.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+		if err := client.Set(ctx, "large", strings.NewReader(strings.Repeat("x", 2048)), "text/plain", 0); !errors.Is(err, filecache.ErrEntryTooLarge) {
+			t.Errorf("Expected ErrEntryTooLarge, got %v", err)
+		}
+		unauthorized, _ := NewClient(srv.URL+"/admin", ClientOptions{Token: "wrong"})
+		if _, err := unauthorized.Stats(); err == nil || !strings.Contains(err.Error(), "unauthorized") {
+			t.Errorf("Expected unauthorized error, got %v", err)
+		}
+	})
+
+	t.Run("ListExistsDelete", func(t *testing.T) {
+		files, err := client.List(ctx)
+		if err != nil {
+			t.Fatalf("Failed to list files: %v", err)
+		}
+		if len(files) != 2 {
+			t.Errorf("Expected 2 files, got %d", len(files))
+		}
+		if ok, err := client.Exists(ctx, "pinned"); err != nil || !ok {
+			t.Errorf("Expected pinned to exist, got %v %v", ok, err)
+		}
+		if err := client.Delete(ctx, "pinned"); err != nil {
+			t.Fatalf("Failed to delete file: %v", err)
+		}
+		if ok, err := client.Exists(ctx, "pinned"); err != nil || ok {
+			t.Errorf("Expected pinned to be deleted, got %v %v", ok, err)
+		}
+	})
+
+	t.Run("Namespace", func(t *testing.T) {
+		ns := client.Namespace("tenant")
+		if err := ns.Set(ctx, "a", strings.NewReader("1"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		if ok, _ := remote.Namespace("tenant").Exists(ctx, "a"); !ok {
+			t.Error("Expected file in the remote namespace")
+		}
+		if ok, _ := client.Exists(ctx, "a"); ok {
+			t.Error("Expected namespaces to be isolated")
+		}
+		stats, err := ns.Stats()
+		if err != nil || stats.TotalFiles != 1 {
+			t.Errorf("Expected 1 file in namespace stats, got %+v %v", stats, err)
+		}
+		if err := ns.Flush(ctx); err != nil {
+			t.Fatalf("Failed to flush namespace: %v", err)
+		}
+		if files, _ := ns.List(ctx); len(files) != 0 {
+			t.Errorf("Expected empty namespace after flush, got %d files", len(files))
+		}
+		if _, err := ns.Namespace("nested").List(ctx); err == nil {
+			t.Error("Expected error for nested namespaces")
+		}
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		for _, key := range []string{"v1/a.js", "v1/b.js", "v2/a.js"} {
+			if err := client.Set(ctx, key, strings.NewReader("1"), "text/javascript", time.Hour); err != nil {
+				t.Fatalf("Failed to set file: %v", err)
+			}
+		}
+		if n, err := c.PurgePrefix(ctx, "v1/", time.Hour); err != nil || n != 0 {
+			t.Errorf("Expected recent files to be kept, got %d %v", n, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if n, err := c.PurgePrefix(ctx, "v1/", time.Millisecond); err != nil || n != 2 {
+			t.Errorf("Expected 2 purged files, got %d %v", n, err)
+		}
+		if ok, _ := client.Exists(ctx, "v2/a.js"); !ok {
+			t.Error("Expected files outside the prefix to be kept")
+		}
+		if _, err := c.PurgeTag(ctx, "release-42"); err == nil {
+			t.Error("Expected error for unsupported tag purge")
+		}
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		result, err := c.CleanupWithOptions(ctx, filecache.CleanupOptions{})
+		if err != nil || !result.Done {
+			t.Errorf("Expected cleanup to finish, got %+v %v", result, err)
+		}
+		if _, err := c.CleanupWithOptions(ctx, filecache.CleanupOptions{MaxEntries: 10}); err == nil {
+			t.Error("Expected error for unsupported cleanup options")
+		}
+	})
+
+	t.Run("Unix", func(t *testing.T) {
+		socket := filepath.Join(t.TempDir(), "admin.sock")
+		lis, err := ListenUnix(socket, 0600)
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		go http.Serve(lis, handler)
+		defer lis.Close()
+
+		client, err := NewClient("unix://"+socket, ClientOptions{Token: "secret"})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		defer client.Close()
+		if ok, err := client.Exists(ctx, "dir/hello world.txt"); err != nil || !ok {
+			t.Errorf("Expected file over unix socket, got %v %v", ok, err)
+		}
+	})
+
+	t.Run("InvalidAddress", func(t *testing.T) {
+		for _, addr := range []string{"ftp://host", "http://", "unix://", "://"} {
+			if _, err := NewClient(addr, ClientOptions{}); err == nil {
+				t.Errorf("Expected error for %q", addr)
+			}
+		}
+	})
+}
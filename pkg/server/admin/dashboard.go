@@ -0,0 +1,134 @@
+package admin
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+	"github.com/seraphico/EdgeOrigin/pkg/origin"
+)
+
+const (
+	// defaultTopKeys 仪表盘默认显示的访问最多的键数
+	defaultTopKeys = 10
+	// maxTopKeys 一次最多返回的键数
+	maxTopKeys = 100
+	// topKeysTTL 访问最多的键需要列出全部文件，结果缓存这段时间，避免每次刷新都扫描键空间
+	topKeysTTL = 10 * time.Second
+)
+
+//go:embed dashboard.html
+var dashboardPage []byte
+
+// HealthReporter 报告上游的健康状态，例如origin.Proxy和origin.Router
+type HealthReporter interface {
+	UpstreamHealth() []origin.UpstreamHealth
+}
+
+// dashboardData 仪表盘的一次快照，命中率和淘汰速率由页面根据相邻两次快照计算
+type dashboardData struct {
+	Time      time.Time               `json:"time"`
+	Stats     *filecache.Stats        `json:"stats"`
+	Disk      *filecache.DiskUsage    `json:"disk,omitempty"`
+	TopKeys   []*filecache.FileInfo   `json:"top_keys"`
+	Upstreams []origin.UpstreamHealth `json:"upstreams,omitempty"`
+}
+
+// topKeys 缓存的访问最多的键
+type topKeys struct {
+	mu      sync.Mutex
+	entries map[string]topKeysEntry // 键为命名空间路径
+}
+
+// topKeysEntry 一个命名空间的访问最多的键
+type topKeysEntry struct {
+	files []*filecache.FileInfo
+	at    time.Time
+}
+
+// get 返回cache中访问次数最多的n个文件，topKeysTTL内复用上一次的结果
+func (t *topKeys) get(r *http.Request, cache filecache.Cache, n int) ([]*filecache.FileInfo, error) {
+	namespace := r.URL.Query().Get("namespace")
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e, ok := t.entries[namespace]; ok && time.Since(e.at) < topKeysTTL && len(e.files) >= n {
+		return e.files[:n], nil
+	}
+
+	files, err := cache.List(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].AccessCount != files[j].AccessCount {
+			return files[i].AccessCount > files[j].AccessCount
+		}
+		return files[i].Key < files[j].Key
+	})
+	if len(files) > maxTopKeys {
+		files = files[:maxTopKeys]
+	}
+	if t.entries == nil {
+		t.entries = make(map[string]topKeysEntry)
+	}
+	t.entries[namespace] = topKeysEntry{files: files, at: time.Now()}
+	if len(files) > n {
+		files = files[:n]
+	}
+	return files, nil
+}
+
+// handleDashboard 返回仪表盘页面，页面本身不包含数据，不需要鉴权
+func (h *handler) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodHead) {
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+	w.Write(dashboardPage)
+}
+
+// handleDashboardData 返回仪表盘的数据：统计信息、磁盘占用、访问最多的键和上游健康状态
+func (h *handler) handleDashboardData(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	n := defaultTopKeys
+	if v := r.URL.Query().Get("top"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n < 0 || n > maxTopKeys {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid top %q", v))
+			return
+		}
+	}
+
+	cache, ok := h.namespace(w, r)
+	if !ok {
+		return
+	}
+	data := &dashboardData{Time: time.Now()}
+	var err error
+	if data.Stats, err = cache.Stats(); err != nil {
+		writeCacheError(w, err)
+		return
+	}
+	if reporter, ok := cache.(filecache.DiskUsageReporter); ok {
+		if data.Disk, err = reporter.DiskUsage(); err != nil {
+			writeCacheError(w, err)
+			return
+		}
+	}
+	if data.TopKeys, err = h.top.get(r, cache, n); err != nil {
+		writeCacheError(w, err)
+		return
+	}
+	if h.opts.Upstreams != nil {
+		data.Upstreams = h.opts.Upstreams.UpstreamHealth()
+	}
+	writeJSON(w, http.StatusOK, data)
+}
@@ -0,0 +1,100 @@
+package admin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+	"github.com/seraphico/EdgeOrigin/pkg/origin"
+)
+
+// staticHealth 返回固定的上游健康状态
+type staticHealth []origin.UpstreamHealth
+
+func (h staticHealth) UpstreamHealth() []origin.UpstreamHealth {
+	return h
+}
+
+func TestDashboard(t *testing.T) {
+	cache, err := filecache.NewBadgerCache(&filecache.Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1 << 20,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	handler, err := NewHandler(cache, Options{
+		Token:     "secret",
+		Upstreams: staticHealth{{Name: "https://origin.example.com", Healthy: false, Failures: 3}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	srv := httptest.NewServer(http.StripPrefix("/admin", handler))
+	defer srv.Close()
+
+	t.Run("Page", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/admin/dashboard")
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+			t.Fatalf("Expected dashboard page without a token, got %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		if !strings.Contains(string(body), `"dashboard/data"`) {
+			t.Error("Expected page to load data relative to the mount point")
+		}
+
+		resp, err = http.Get(srv.URL + "/admin/dashboard/data")
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected data to require a token, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Data", func(t *testing.T) {
+		for _, key := range []string{"cold.txt", "warm.txt", "hot.txt"} {
+			do(t, srv, http.MethodPut, "/admin/entries/"+key, key)
+		}
+		for i := 0; i < 3; i++ {
+			do(t, srv, http.MethodGet, "/admin/entries/hot.txt", "")
+		}
+		do(t, srv, http.MethodGet, "/admin/entries/warm.txt", "")
+
+		resp := do(t, srv, http.MethodGet, "/admin/dashboard/data?top=2", "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", resp.StatusCode)
+		}
+		var data dashboardData
+		decode(t, resp, &data)
+		if data.Stats == nil || data.Stats.TotalFiles != 3 {
+			t.Errorf("Expected stats with 3 files, got %+v", data.Stats)
+		}
+		if len(data.TopKeys) != 2 || data.TopKeys[0].Key != "hot.txt" || data.TopKeys[1].Key != "warm.txt" {
+			t.Errorf("Expected hot.txt and warm.txt as top keys, got %+v", data.TopKeys)
+		}
+		if data.Disk == nil {
+			t.Error("Expected disk usage of the badger cache")
+		}
+		if len(data.Upstreams) != 1 || data.Upstreams[0].Healthy || data.Upstreams[0].Failures != 3 {
+			t.Errorf("Expected upstream health, got %+v", data.Upstreams)
+		}
+
+		if resp := do(t, srv, http.MethodGet, "/admin/dashboard/data?top=1000", ""); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 for too many keys, got %d", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package admin
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ListenUnix 在Unix套接字上监听，用于不能开放额外端口的环境中本机工具访问管理接口：
+//
+//	lis, err := admin.ListenUnix("/run/edgeorigin/admin.sock", 0660)
+//	http.Serve(lis, handler)
+//
+// mode为套接字文件的权限，套接字先在临时目录中创建并设置权限后再移动到path，不存在权限过宽的时间窗口。
+// path上遗留的套接字文件会被替换，但仍有进程在监听或path不是套接字时返回错误；监听器关闭时删除套接字文件
+func ListenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	tmpDir, err := os.MkdirTemp(filepath.Dir(path), ".admin-sock-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tmpPath := filepath.Join(tmpDir, "sock")
+	lis, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmpPath, Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	// 套接字文件移动后由unixListener负责删除
+	lis.SetUnlinkOnClose(false)
+
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		lis.Close()
+		return nil, fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		lis.Close()
+		return nil, fmt.Errorf("failed to move socket to %s: %w", path, err)
+	}
+	return &unixListener{UnixListener: lis, path: path}, nil
+}
+
+// removeStaleSocket 删除没有进程监听的套接字文件
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is already in use", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+	return nil
+}
+
+// unixListener 关闭时删除套接字文件
+type unixListener struct {
+	*net.UnixListener
+	path string
+	once sync.Once
+}
+
+// Close 停止监听并删除套接字文件
+func (l *unixListener) Close() error {
+	err := l.UnixListener.Close()
+	l.once.Do(func() {
+		if removeErr := os.Remove(l.path); removeErr != nil && !os.IsNotExist(removeErr) && err == nil {
+			err = removeErr
+		}
+	})
+	return err
+}
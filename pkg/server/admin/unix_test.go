@@ -0,0 +1,72 @@
+package admin
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "admin.sock")
+
+	// 遗留的套接字文件被替换
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to create stale socket: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	lis, err := ListenUnix(path, 0600)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat socket: %v", err)
+	}
+	if fi.Mode()&os.ModeSocket == 0 || fi.Mode().Perm() != 0600 {
+		t.Errorf("Expected socket with mode 0600, got %v", fi.Mode())
+	}
+
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	handler, _ := NewHandler(cache, Options{Token: "secret"})
+	go http.Serve(lis, handler)
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	req, _ := http.NewRequest(http.MethodGet, "http://admin/stats", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request over socket: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200, got %d", resp.StatusCode)
+	}
+
+	if _, err := ListenUnix(path, 0600); err == nil {
+		t.Error("Expected error when socket is in use")
+	}
+
+	lis.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected socket to be removed on close, got %v", err)
+	}
+
+	regular := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(regular, []byte("x"), 0644)
+	if _, err := ListenUnix(regular, 0600); err == nil {
+		t.Error("Expected error for a regular file")
+	}
+}
@@ -0,0 +1,19 @@
+package edge
+
+import (
+	"net/http"
+
+	"github.com/seraphico/EdgeOrigin/pkg/server/accesslog"
+)
+
+// ClientKeyFunc 返回区分客户端的键，带宽限制和并发限制按它为每个客户端分别计数。
+// 为nil时按连接的远端IP区分，与访问日志中的RemoteIP一致；前面有负载均衡时可以改为按X-Forwarded-For或访问令牌
+type ClientKeyFunc func(r *http.Request) string
+
+// key 返回请求的客户端键
+func (f ClientKeyFunc) key(r *http.Request) string {
+	if f == nil {
+		return accesslog.RemoteIP(r)
+	}
+	return f(r)
+}
@@ -0,0 +1,95 @@
+package edge
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultRetryAfter 超过并发限制时默认让客户端等待的时间
+const defaultRetryAfter = time.Second
+
+// ConcurrencyLimit 每个客户端同时进行中的请求数限制，超过时返回429
+type ConcurrencyLimit struct {
+	// PerClient 每个客户端同时进行中的请求数，0表示不限制
+	PerClient int `json:"per_client,omitempty"`
+
+	// RetryAfter 429响应的Retry-After，按秒向上取整，默认1秒
+	RetryAfter time.Duration `json:"retry_after,omitempty"`
+
+	// ClientKey 区分客户端的方式，见ClientKeyFunc
+	ClientKey ClientKeyFunc `json:"-"`
+}
+
+// validate 检查限制
+func (l *ConcurrencyLimit) validate() error {
+	if l.PerClient < 0 || l.RetryAfter < 0 {
+		return fmt.Errorf("concurrency limits cannot be negative")
+	}
+	return nil
+}
+
+// concurrency 限制每个客户端并发请求数的中间件
+type concurrency struct {
+	next       http.Handler
+	limit      int
+	retryAfter string
+	clientKey  ClientKeyFunc
+
+	mu       sync.Mutex
+	inflight map[string]int // 客户端进行中的请求数，为0时删除
+}
+
+// LimitConcurrency 返回限制每个客户端并发请求数的http.Handler，PerClient为0时直接返回handler
+func LimitConcurrency(handler http.Handler, limit ConcurrencyLimit) (http.Handler, error) {
+	if err := limit.validate(); err != nil {
+		return nil, err
+	}
+	if limit.PerClient == 0 {
+		return handler, nil
+	}
+	if limit.RetryAfter == 0 {
+		limit.RetryAfter = defaultRetryAfter
+	}
+	return &concurrency{
+		next:       handler,
+		limit:      limit.PerClient,
+		retryAfter: strconv.FormatInt(int64((limit.RetryAfter+time.Second-1)/time.Second), 10),
+		clientKey:  limit.ClientKey,
+		inflight:   make(map[string]int),
+	}, nil
+}
+
+// ServeHTTP 客户端进行中的请求未达到限制时处理请求，否则返回429
+func (c *concurrency) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := c.clientKey.key(r)
+	if !c.acquire(key) {
+		w.Header().Set("Retry-After", c.retryAfter)
+		http.Error(w, "too many concurrent requests", http.StatusTooManyRequests)
+		return
+	}
+	defer c.release(key)
+	c.next.ServeHTTP(w, r)
+}
+
+// acquire 增加key进行中的请求数，已达到限制时返回false
+func (c *concurrency) acquire(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inflight[key] >= c.limit {
+		return false
+	}
+	c.inflight[key]++
+	return true
+}
+
+// release 减少key进行中的请求数
+func (c *concurrency) release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inflight[key]--; c.inflight[key] == 0 {
+		delete(c.inflight, key)
+	}
+}
@@ -0,0 +1,102 @@
+package edge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimitConcurrency(t *testing.T) {
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			started <- struct{}{}
+			<-unblock
+		}
+		w.Write([]byte("ok"))
+	})
+	// serve 以ip的身份请求path
+	serve := func(h http.Handler, ip, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = ip + ":40000"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("per client", func(t *testing.T) {
+		h, err := LimitConcurrency(handler, ConcurrencyLimit{PerClient: 2, RetryAfter: 1500 * time.Millisecond})
+		if err != nil {
+			t.Fatalf("Failed to create limiter: %v", err)
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				serve(h, "192.0.2.1", "/slow")
+			}()
+			<-started
+		}
+
+		rec := serve(h, "192.0.2.1", "/fast")
+		if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "2" {
+			t.Errorf("Expected 429 with Retry-After 2, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+		}
+		if rec := serve(h, "192.0.2.2", "/fast"); rec.Code != http.StatusOK {
+			t.Errorf("Expected other clients to be served, got %d", rec.Code)
+		}
+
+		close(unblock)
+		wg.Wait()
+		if rec := serve(h, "192.0.2.1", "/fast"); rec.Code != http.StatusOK {
+			t.Errorf("Expected client to be served after requests finish, got %d", rec.Code)
+		}
+		if n := len(h.(*concurrency).inflight); n != 0 {
+			t.Errorf("Expected idle clients to be removed, got %d", n)
+		}
+	})
+
+	t.Run("client key", func(t *testing.T) {
+		h, err := LimitConcurrency(handler, ConcurrencyLimit{
+			PerClient: 1,
+			ClientKey: func(r *http.Request) string { return r.Header.Get("Authorization") },
+		})
+		if err != nil {
+			t.Fatalf("Failed to create limiter: %v", err)
+		}
+		c := h.(*concurrency)
+		if !c.acquire("Bearer a") {
+			t.Fatal("Expected first request to be admitted")
+		}
+		defer c.release("Bearer a")
+		req := httptest.NewRequest(http.MethodGet, "/fast", nil)
+		req.Header.Set("Authorization", "Bearer a")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+			t.Errorf("Expected 429 with default Retry-After, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+		}
+		req.Header.Set("Authorization", "Bearer b")
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected other token to be served, got %d", rec.Code)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		if h, err := LimitConcurrency(handler, ConcurrencyLimit{}); err != nil || h == nil {
+			t.Errorf("Expected handler without limits, got %v", err)
+		}
+		if _, err := LimitConcurrency(handler, ConcurrencyLimit{PerClient: -1}); err == nil {
+			t.Error("Expected error for negative limit")
+		}
+		if _, err := NewServer(handler, Config{Concurrency: &ConcurrencyLimit{RetryAfter: -time.Second}}); err == nil {
+			t.Error("Expected server to reject negative retry after")
+		}
+	})
+}
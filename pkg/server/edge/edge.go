@@ -0,0 +1,388 @@
+// Package edge 提供边缘节点面向客户端的HTTP服务：同一个TCP地址上提供HTTP/1.1和HTTP/2
+// （TLS时经ALPN协商，明文时可选h2c），可选在UDP上提供HTTP/3（QUIC），并在TCP响应中用Alt-Svc通告，
+// 丢包较多的移动网络上的客户端随后改用HTTP/3
+package edge
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"github.com/seraphico/EdgeOrigin/internal/validate"
+	"github.com/seraphico/EdgeOrigin/pkg/server/accesslog"
+	"github.com/seraphico/EdgeOrigin/pkg/server/tlsutil"
+)
+
+const (
+	defaultReadHeaderTimeout    = 10 * time.Second
+	defaultIdleTimeout          = 2 * time.Minute
+	defaultAltSvcMaxAge         = 24 * time.Hour
+	defaultQUICIdleTimeout      = 30 * time.Second
+	defaultQUICHandshakeTimeout = 5 * time.Second
+	defaultQUICMaxStreams       = 100
+)
+
+// Config 边缘监听的配置
+type Config struct {
+	// Addr TCP监听地址，默认设置了TLS时为":443"，否则为":80"
+	Addr string `json:"addr,omitempty"`
+
+	// TLS 证书和客户端认证，为空时提供明文HTTP；证书文件更新后自动加载，见tlsutil.Config
+	TLS *tlsutil.Config `json:"tls,omitempty"`
+
+	// HTTP2 HTTP/2的参数，为空时使用默认值：TLS时启用，明文时不启用h2c
+	HTTP2 *HTTP2Config `json:"http2,omitempty"`
+
+	// HTTP3 不为空时在UDP上提供HTTP/3，需要设置TLS
+	HTTP3 *HTTP3Config `json:"http3,omitempty"`
+
+	// ReadHeaderTimeout 读取请求头的超时，默认10秒
+	ReadHeaderTimeout time.Duration `json:"read_header_timeout,omitempty"`
+
+	// IdleTimeout 空闲的keep-alive连接和HTTP/2连接保持的时间，默认2分钟
+	IdleTimeout time.Duration `json:"idle_timeout,omitempty"`
+
+	// MaxHeaderBytes 请求头的最大长度，默认与http.Server相同（1MB）
+	MaxHeaderBytes int `json:"max_header_bytes,omitempty"`
+
+	// Bandwidth 响应体的发送速率限制，为空时不限制，见BandwidthLimit
+	Bandwidth *BandwidthLimit `json:"bandwidth,omitempty"`
+
+	// Concurrency 每个客户端的并发请求数限制，为空时不限制，见ConcurrencyLimit
+	Concurrency *ConcurrencyLimit `json:"concurrency,omitempty"`
+
+	// AccessLog 不为空时为每个请求记录访问日志，包括被Concurrency拒绝的请求
+	AccessLog *accesslog.Options `json:"-"`
+}
+
+// HTTP2Config HTTP/2的参数
+type HTTP2Config struct {
+	// Disable 只提供HTTP/1.1
+	Disable bool `json:"disable,omitempty"`
+
+	// H2C 明文监听时接受HTTP/2（prior knowledge和Upgrade: h2c），用于前面有负载均衡终止TLS的部署
+	H2C bool `json:"h2c,omitempty"`
+
+	// MaxConcurrentStreams 每个连接上并发的请求数，默认250
+	MaxConcurrentStreams uint32 `json:"max_concurrent_streams,omitempty"`
+
+	// MaxReadFrameSize 接收的最大帧长度，默认1MB
+	MaxReadFrameSize uint32 `json:"max_read_frame_size,omitempty"`
+}
+
+// HTTP3Config HTTP/3（QUIC）的参数
+type HTTP3Config struct {
+	// Addr UDP监听地址，默认与Config.Addr相同
+	Addr string `json:"addr,omitempty"`
+
+	// AdvertisePort Alt-Svc中通告的端口，默认为UDP监听的端口；UDP端口经过NAT或负载均衡映射时设置
+	AdvertisePort int `json:"advertise_port,omitempty"`
+
+	// AltSvcMaxAge 客户端记住Alt-Svc的时间，默认24小时
+	AltSvcMaxAge time.Duration `json:"alt_svc_max_age,omitempty"`
+
+	// MaxIdleTimeout 没有收到数据的连接保持的时间，默认30秒
+	MaxIdleTimeout time.Duration `json:"max_idle_timeout,omitempty"`
+
+	// HandshakeIdleTimeout 握手完成前等待客户端的时间，默认5秒
+	HandshakeIdleTimeout time.Duration `json:"handshake_idle_timeout,omitempty"`
+
+	// KeepAlivePeriod 大于0时按这个间隔发送keep-alive，避免NAT映射过期，默认不发送
+	KeepAlivePeriod time.Duration `json:"keep_alive_period,omitempty"`
+
+	// MaxIncomingStreams 每个连接上并发的请求数，默认100
+	MaxIncomingStreams int64 `json:"max_incoming_streams,omitempty"`
+
+	// Allow0RTT 接受0-RTT数据，恢复的连接少一次往返；0-RTT请求可能被重放，只在处理器的请求都幂等时开启
+	Allow0RTT bool `json:"allow_0rtt,omitempty"`
+}
+
+// Validate 检查配置并加载TLS证书，但不监听端口；返回的错误列出所有问题，每个问题以字段路径开头，
+// 例如"http3: requires tls"。NewServer创建监听之前先调用它
+func (cfg *Config) Validate() error {
+	var errs validate.Errors
+	if cfg.Addr != "" {
+		if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+			errs.Add("addr", err)
+		}
+	}
+	if cfg.ReadHeaderTimeout < 0 {
+		errs.Addf("read_header_timeout", "cannot be negative")
+	}
+	if cfg.IdleTimeout < 0 {
+		errs.Addf("idle_timeout", "cannot be negative")
+	}
+	if cfg.MaxHeaderBytes < 0 {
+		errs.Addf("max_header_bytes", "cannot be negative")
+	}
+	if cfg.TLS != nil {
+		if _, err := cfg.TLS.ServerConfig(); err != nil {
+			errs.Add("tls", err)
+		}
+	}
+	if cfg.HTTP2 != nil && cfg.HTTP2.H2C && cfg.TLS != nil {
+		errs.Addf("http2.h2c", "requires a cleartext listener")
+	}
+	if h3 := cfg.HTTP3; h3 != nil {
+		if cfg.TLS == nil {
+			errs.Addf("http3", "requires tls")
+		}
+		if h3.Addr != "" {
+			if _, _, err := net.SplitHostPort(h3.Addr); err != nil {
+				errs.Add("http3.addr", err)
+			}
+		}
+		if h3.AdvertisePort < 0 || h3.AdvertisePort > 65535 {
+			errs.Addf("http3.advertise_port", "invalid port %d", h3.AdvertisePort)
+		}
+		if h3.AltSvcMaxAge < 0 || h3.MaxIdleTimeout < 0 || h3.HandshakeIdleTimeout < 0 || h3.KeepAlivePeriod < 0 || h3.MaxIncomingStreams < 0 {
+			errs.Addf("http3", "timeouts and limits cannot be negative")
+		}
+	}
+	if cfg.Bandwidth != nil {
+		errs.Add("bandwidth", cfg.Bandwidth.validate())
+	}
+	if cfg.Concurrency != nil {
+		errs.Add("concurrency", cfg.Concurrency.validate())
+	}
+	return errs.Err()
+}
+
+// Server 边缘监听，同时提供TCP上的HTTP/1.1、HTTP/2和UDP上的HTTP/3
+type Server struct {
+	addr   string
+	h3addr string
+	tls    *tls.Config
+	http   *http.Server
+	h3     *http3.Server
+	maxAge time.Duration
+	port   int          // Alt-Svc中通告的端口，0表示使用UDP监听的端口
+	altSvc atomic.Value // string，HTTP/3开始监听后TCP响应中的Alt-Svc
+	closed atomic.Bool
+
+	accessLog accesslog.Sampler // 没有配置访问日志时为空
+}
+
+// NewServer 按cfg创建边缘监听，请求交给handler处理，例如origin.Proxy或origin.Router
+func NewServer(handler http.Handler, cfg Config) (*Server, error) {
+	h2 := HTTP2Config{}
+	if cfg.HTTP2 != nil {
+		h2 = *cfg.HTTP2
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Bandwidth != nil {
+		var err error
+		if handler, err = Throttle(handler, *cfg.Bandwidth); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Concurrency != nil {
+		var err error
+		if handler, err = LimitConcurrency(handler, *cfg.Concurrency); err != nil {
+			return nil, err
+		}
+	}
+	var sampler accesslog.Sampler
+	if cfg.AccessLog != nil {
+		var err error
+		if handler, err = accesslog.NewHandler(handler, *cfg.AccessLog); err != nil {
+			return nil, err
+		}
+		sampler, _ = handler.(accesslog.Sampler)
+	}
+	if cfg.ReadHeaderTimeout == 0 {
+		cfg.ReadHeaderTimeout = defaultReadHeaderTimeout
+	}
+	if cfg.IdleTimeout == 0 {
+		cfg.IdleTimeout = defaultIdleTimeout
+	}
+	s := &Server{addr: cfg.Addr, accessLog: sampler}
+	s.altSvc.Store("")
+	if s.addr == "" {
+		s.addr = ":80"
+		if cfg.TLS != nil {
+			s.addr = ":443"
+		}
+	}
+	if cfg.TLS != nil {
+		var err error
+		if s.tls, err = cfg.TLS.ServerConfig(); err != nil {
+			return nil, err
+		}
+	}
+
+	s.http = &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+	h2s := &http2.Server{
+		MaxConcurrentStreams: h2.MaxConcurrentStreams,
+		MaxReadFrameSize:     h2.MaxReadFrameSize,
+		IdleTimeout:          cfg.IdleTimeout,
+	}
+	switch {
+	case h2.Disable:
+		// 非nil的空map关闭http.Server自带的HTTP/2
+		s.http.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	case s.tls != nil:
+		s.http.TLSConfig = s.tls.Clone()
+		if err := http2.ConfigureServer(s.http, h2s); err != nil {
+			return nil, fmt.Errorf("failed to configure http2: %w", err)
+		}
+	case h2.H2C:
+		s.http.Handler = h2c.NewHandler(handler, h2s)
+	}
+	if s.tls != nil && s.http.TLSConfig == nil {
+		s.http.TLSConfig = s.tls.Clone()
+		s.http.TLSConfig.NextProtos = []string{"http/1.1"}
+	}
+
+	if h3 := cfg.HTTP3; h3 != nil {
+		quicConfig := &quic.Config{
+			MaxIdleTimeout:       h3.MaxIdleTimeout,
+			HandshakeIdleTimeout: h3.HandshakeIdleTimeout,
+			KeepAlivePeriod:      h3.KeepAlivePeriod,
+			MaxIncomingStreams:   h3.MaxIncomingStreams,
+			Allow0RTT:            h3.Allow0RTT,
+		}
+		if quicConfig.MaxIdleTimeout == 0 {
+			quicConfig.MaxIdleTimeout = defaultQUICIdleTimeout
+		}
+		if quicConfig.HandshakeIdleTimeout == 0 {
+			quicConfig.HandshakeIdleTimeout = defaultQUICHandshakeTimeout
+		}
+		if quicConfig.MaxIncomingStreams == 0 {
+			quicConfig.MaxIncomingStreams = defaultQUICMaxStreams
+		}
+		s.h3 = &http3.Server{
+			TLSConfig:      s.tls.Clone(),
+			QuicConfig:     quicConfig,
+			Handler:        handler,
+			MaxHeaderBytes: cfg.MaxHeaderBytes,
+		}
+		s.h3addr, s.port, s.maxAge = h3.Addr, h3.AdvertisePort, h3.AltSvcMaxAge
+		if s.h3addr == "" {
+			s.h3addr = s.addr
+		}
+		if s.maxAge == 0 {
+			s.maxAge = defaultAltSvcMaxAge
+		}
+		next := s.http.Handler
+		s.http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if altSvc := s.altSvc.Load().(string); altSvc != "" {
+				w.Header().Set("Alt-Svc", altSvc)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	return s, nil
+}
+
+// ListenAndServe 在Config.Addr上监听TCP，设置了HTTP3时同时在UDP上监听，直到出错或Shutdown、Close；
+// 一个监听出错时关闭另一个。Shutdown或Close后返回http.ErrServerClosed
+func (s *Server) ListenAndServe() error {
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+	if s.h3 == nil {
+		return s.Serve(lis)
+	}
+	conn, err := net.ListenPacket("udp", s.h3addr)
+	if err != nil {
+		lis.Close()
+		return fmt.Errorf("failed to listen on udp %s: %w", s.h3addr, err)
+	}
+	defer conn.Close()
+
+	errc := make(chan error, 2)
+	go func() { errc <- s.Serve(lis) }()
+	go func() { errc <- s.ServeQUIC(conn) }()
+	err = <-errc
+	if !errors.Is(err, http.ErrServerClosed) {
+		s.Close()
+	}
+	<-errc
+	return err
+}
+
+// Serve 在lis上接受TCP连接，设置了TLS时在lis上完成TLS握手
+func (s *Server) Serve(lis net.Listener) error {
+	if s.tls != nil {
+		return s.http.ServeTLS(lis, "", "")
+	}
+	return s.http.Serve(lis)
+}
+
+// ServeQUIC 在conn上提供HTTP/3，conn由调用方关闭；开始监听后TCP响应带上Alt-Svc
+func (s *Server) ServeQUIC(conn net.PacketConn) error {
+	if s.h3 == nil {
+		return fmt.Errorf("http3 is not enabled")
+	}
+	port := s.port
+	if port == 0 {
+		if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+			port = addr.Port
+		}
+	}
+	if port != 0 {
+		s.altSvc.Store(fmt.Sprintf(`h3=":%d"; ma=%d`, port, int64(s.maxAge/time.Second)))
+	}
+	err := s.h3.Serve(conn)
+	if s.closed.Load() {
+		return http.ErrServerClosed
+	}
+	return err
+}
+
+// SetAccessLogSampling 在运行中修改访问日志的抽样比例，没有配置访问日志时返回错误
+func (s *Server) SetAccessLogSampling(rate float64, keepErrors bool) error {
+	if s.accessLog == nil {
+		return fmt.Errorf("access log is not enabled")
+	}
+	return s.accessLog.SetSampling(rate, keepErrors)
+}
+
+// Shutdown 停止接受新的连接，等待TCP上进行中的请求完成或ctx结束；HTTP/3连接立即关闭
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.closed.Store(true)
+	s.altSvc.Store("")
+	err := s.http.Shutdown(ctx)
+	if h3err := s.closeQUIC(); err == nil {
+		err = h3err
+	}
+	return err
+}
+
+// Close 立即关闭所有监听和连接
+func (s *Server) Close() error {
+	s.closed.Store(true)
+	s.altSvc.Store("")
+	err := s.http.Close()
+	if h3err := s.closeQUIC(); err == nil {
+		err = h3err
+	}
+	return err
+}
+
+// closeQUIC 关闭HTTP/3监听
+func (s *Server) closeQUIC() error {
+	if s.h3 == nil {
+		return nil
+	}
+	return s.h3.Close()
+}
@@ -0,0 +1,247 @@
+package edge
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+
+	"github.com/seraphico/EdgeOrigin/pkg/server/accesslog"
+	"github.com/seraphico/EdgeOrigin/pkg/server/tlsutil"
+)
+
+// newTestCert 创建127.0.0.1的自签名证书，返回TLS配置和信任它的证书池
+func newTestCert(t *testing.T) (*tlsutil.Config, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "edge"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	dir := t.TempDir()
+	cfg := &tlsutil.Config{CertFile: filepath.Join(dir, "edge.crt"), KeyFile: filepath.Join(dir, "edge.key")}
+	if err := os.WriteFile(cfg.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(cfg.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return cfg, pool
+}
+
+// startServer 在本机随机端口上启动s，返回TCP地址和UDP地址（没有HTTP/3时为空）
+func startServer(t *testing.T, s *Server) (string, string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go s.Serve(lis)
+	var udpAddr string
+	if s.h3 != nil {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen on udp: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		udpAddr = conn.LocalAddr().String()
+		go s.ServeQUIC(conn)
+	}
+	t.Cleanup(func() { s.Close() })
+	return lis.Addr().String(), udpAddr
+}
+
+func protoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.Proto, r.URL.Path)
+	})
+}
+
+func TestServer(t *testing.T) {
+	tlsConfig, pool := newTestCert(t)
+
+	t.Run("http2 over tls", func(t *testing.T) {
+		s, err := NewServer(protoHandler(), Config{TLS: tlsConfig})
+		if err != nil {
+			t.Fatalf("Failed to create server: %v", err)
+		}
+		addr, _ := startServer(t, s)
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}, ForceAttemptHTTP2: true}}
+		resp, err := client.Get("https://" + addr + "/a")
+		if err != nil {
+			t.Fatalf("Failed to get: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.ProtoMajor != 2 || string(body) != "HTTP/2.0 /a" {
+			t.Errorf("Expected HTTP/2, got %s %q", resp.Proto, body)
+		}
+		if resp.Header.Get("Alt-Svc") != "" {
+			t.Errorf("Expected no Alt-Svc without http3, got %q", resp.Header.Get("Alt-Svc"))
+		}
+	})
+
+	t.Run("http2 disabled", func(t *testing.T) {
+		s, err := NewServer(protoHandler(), Config{TLS: tlsConfig, HTTP2: &HTTP2Config{Disable: true}})
+		if err != nil {
+			t.Fatalf("Failed to create server: %v", err)
+		}
+		addr, _ := startServer(t, s)
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}, ForceAttemptHTTP2: true}}
+		resp, err := client.Get("https://" + addr + "/b")
+		if err != nil {
+			t.Fatalf("Failed to get: %v", err)
+		}
+		resp.Body.Close()
+		if resp.ProtoMajor != 1 {
+			t.Errorf("Expected HTTP/1.1, got %s", resp.Proto)
+		}
+	})
+
+	t.Run("h2c", func(t *testing.T) {
+		s, err := NewServer(protoHandler(), Config{HTTP2: &HTTP2Config{H2C: true}})
+		if err != nil {
+			t.Fatalf("Failed to create server: %v", err)
+		}
+		addr, _ := startServer(t, s)
+		client := &http.Client{Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, addr)
+			},
+		}}
+		resp, err := client.Get("http://" + addr + "/c")
+		if err != nil {
+			t.Fatalf("Failed to get: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.ProtoMajor != 2 || string(body) != "HTTP/2.0 /c" {
+			t.Errorf("Expected cleartext HTTP/2, got %s %q", resp.Proto, body)
+		}
+	})
+
+	t.Run("http3", func(t *testing.T) {
+		s, err := NewServer(protoHandler(), Config{TLS: tlsConfig, HTTP3: &HTTP3Config{AltSvcMaxAge: time.Hour}})
+		if err != nil {
+			t.Fatalf("Failed to create server: %v", err)
+		}
+		addr, udpAddr := startServer(t, s)
+		_, port, _ := net.SplitHostPort(udpAddr)
+
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}, ForceAttemptHTTP2: true}}
+		var altSvc string
+		for deadline := time.Now().Add(5 * time.Second); altSvc == "" && time.Now().Before(deadline); {
+			resp, err := client.Get("https://" + addr + "/d")
+			if err != nil {
+				t.Fatalf("Failed to get: %v", err)
+			}
+			resp.Body.Close()
+			altSvc = resp.Header.Get("Alt-Svc")
+		}
+		if want := `h3=":` + port + `"; ma=3600`; altSvc != want {
+			t.Errorf("Expected Alt-Svc %q, got %q", want, altSvc)
+		}
+
+		h3 := &http3.RoundTripper{TLSClientConfig: &tls.Config{RootCAs: pool}}
+		defer h3.Close()
+		resp, err := (&http.Client{Transport: h3, Timeout: 5 * time.Second}).Get("https://" + udpAddr + "/e")
+		if err != nil {
+			t.Fatalf("Failed to get over http3: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.ProtoMajor != 3 || string(body) != "HTTP/3.0 /e" {
+			t.Errorf("Expected HTTP/3, got %s %q", resp.Proto, body)
+		}
+	})
+
+	t.Run("shutdown", func(t *testing.T) {
+		s, err := NewServer(protoHandler(), Config{Addr: "127.0.0.1:0", TLS: tlsConfig, HTTP3: &HTTP3Config{}})
+		if err != nil {
+			t.Fatalf("Failed to create server: %v", err)
+		}
+		done := make(chan error, 1)
+		go func() { done <- s.ListenAndServe() }()
+		time.Sleep(50 * time.Millisecond)
+		if err := s.Shutdown(context.Background()); err != nil {
+			t.Errorf("Failed to shut down: %v", err)
+		}
+		select {
+		case err := <-done:
+			if !errors.Is(err, http.ErrServerClosed) {
+				t.Errorf("Expected ErrServerClosed, got %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("Expected ListenAndServe to return after Shutdown")
+		}
+	})
+
+	t.Run("access log sampling", func(t *testing.T) {
+		sink := accesslog.SinkFunc(func(e *accesslog.Entry) error { return nil })
+		s, err := NewServer(protoHandler(), Config{AccessLog: &accesslog.Options{Sinks: []accesslog.Sink{sink}}})
+		if err != nil {
+			t.Fatalf("Failed to create server: %v", err)
+		}
+		if err := s.SetAccessLogSampling(0.1, true); err != nil {
+			t.Errorf("Failed to set sampling: %v", err)
+		}
+		if err := s.SetAccessLogSampling(2, false); err == nil {
+			t.Error("Expected error for invalid sample rate")
+		}
+		plain, err := NewServer(protoHandler(), Config{})
+		if err != nil {
+			t.Fatalf("Failed to create server: %v", err)
+		}
+		if err := plain.SetAccessLogSampling(0.1, false); err == nil {
+			t.Error("Expected error without access log")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, cfg := range []Config{
+			{HTTP3: &HTTP3Config{}},
+			{TLS: tlsConfig, HTTP2: &HTTP2Config{H2C: true}},
+			{TLS: tlsConfig, HTTP3: &HTTP3Config{AdvertisePort: 70000}},
+			{TLS: &tlsutil.Config{}},
+			{IdleTimeout: -1},
+		} {
+			if _, err := NewServer(protoHandler(), cfg); err == nil {
+				t.Errorf("Expected error for %+v", cfg)
+			}
+		}
+	})
+}
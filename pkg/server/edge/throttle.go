@@ -0,0 +1,178 @@
+package edge
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// minBurst 令牌桶的最小容量，也是速率很低时一次写入的上限
+	minBurst = 64 << 10
+
+	// maxChunk 一次等待令牌后写入的最大字节数，较小的块使发送更平滑
+	maxChunk = 32 << 10
+)
+
+// BandwidthLimit 响应体的发送速率限制，单位为字节/秒。
+// 被限速的响应逐块写入，不能再使用sendfile
+type BandwidthLimit struct {
+	// Total 所有客户端合计的速率，0表示不限制
+	Total int64 `json:"total,omitempty"`
+
+	// PerClient 每个客户端的速率，同一个客户端的并发响应共享，0表示不限制
+	PerClient int64 `json:"per_client,omitempty"`
+
+	// Burst 空闲后允许突发发送的字节数，默认为0.1秒的速率，至少64KB
+	Burst int64 `json:"burst,omitempty"`
+
+	// ClientKey 区分客户端的方式，见ClientKeyFunc
+	ClientKey ClientKeyFunc `json:"-"`
+}
+
+// validate 检查速率
+func (l *BandwidthLimit) validate() error {
+	if l.Total < 0 || l.PerClient < 0 || l.Burst < 0 {
+		return fmt.Errorf("bandwidth limits cannot be negative")
+	}
+	return nil
+}
+
+// burst 返回速率为bytesPerSec的令牌桶容量
+func (l *BandwidthLimit) burst(bytesPerSec int64) int {
+	burst := l.Burst
+	if burst == 0 {
+		burst = bytesPerSec / 10
+	}
+	if burst < minBurst {
+		burst = minBurst
+	}
+	return int(burst)
+}
+
+// throttle 限制响应体发送速率的中间件
+type throttle struct {
+	next    http.Handler
+	limit   BandwidthLimit
+	total   *rate.Limiter
+	clients *clientLimiters
+}
+
+// Throttle 返回限制handler响应体发送速率的http.Handler，Total和PerClient都为0时直接返回handler
+func Throttle(handler http.Handler, limit BandwidthLimit) (http.Handler, error) {
+	if err := limit.validate(); err != nil {
+		return nil, err
+	}
+	if limit.Total == 0 && limit.PerClient == 0 {
+		return handler, nil
+	}
+	t := &throttle{next: handler, limit: limit}
+	if limit.Total > 0 {
+		t.total = rate.NewLimiter(rate.Limit(limit.Total), limit.burst(limit.Total))
+	}
+	if limit.PerClient > 0 {
+		t.clients = &clientLimiters{rate: limit.PerClient, burst: limit.burst(limit.PerClient), limiters: make(map[string]*clientLimiter)}
+	}
+	return t, nil
+}
+
+// ServeHTTP 用限速的ResponseWriter处理请求
+func (t *throttle) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tw := &throttledWriter{ResponseWriter: w, r: r}
+	if t.total != nil {
+		tw.limiters = append(tw.limiters, t.total)
+	}
+	if t.clients != nil {
+		key := t.limit.ClientKey.key(r)
+		client := t.clients.acquire(key)
+		defer t.clients.release(key, client)
+		tw.limiters = append(tw.limiters, client.Limiter)
+	}
+	t.next.ServeHTTP(tw, r)
+}
+
+// clientLimiters 每个客户端的令牌桶，客户端没有进行中的响应时删除，内存占用只与活跃的客户端数有关
+type clientLimiters struct {
+	rate  int64
+	burst int
+
+	mu       sync.Mutex
+	limiters map[string]*clientLimiter
+}
+
+// clientLimiter 一个客户端的令牌桶
+type clientLimiter struct {
+	*rate.Limiter
+	refs int
+}
+
+// acquire 返回key的令牌桶，没有时创建
+func (c *clientLimiters) acquire(key string) *clientLimiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l := c.limiters[key]
+	if l == nil {
+		l = &clientLimiter{Limiter: rate.NewLimiter(rate.Limit(c.rate), c.burst)}
+		c.limiters[key] = l
+	}
+	l.refs++
+	return l
+}
+
+// release 释放acquire返回的令牌桶
+func (c *clientLimiters) release(key string, l *clientLimiter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if l.refs--; l.refs == 0 {
+		delete(c.limiters, key)
+	}
+}
+
+// throttledWriter 写入前从每个令牌桶取得令牌，请求结束时停止等待
+type throttledWriter struct {
+	http.ResponseWriter
+	r        *http.Request
+	limiters []*rate.Limiter
+}
+
+// Write 分块写入，每块写入前等待令牌
+func (w *throttledWriter) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		chunk := len(b)
+		if chunk > maxChunk {
+			chunk = maxChunk
+		}
+		for _, l := range w.limiters {
+			if chunk > l.Burst() {
+				chunk = l.Burst()
+			}
+		}
+		for _, l := range w.limiters {
+			if err := l.WaitN(w.r.Context(), chunk); err != nil {
+				return written, err
+			}
+		}
+		n, err := w.ResponseWriter.Write(b[:chunk])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[chunk:]
+	}
+	return written, nil
+}
+
+// Flush 底层的ResponseWriter支持时发送已写入的数据
+func (w *throttledWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap 返回底层的ResponseWriter，供http.ResponseController使用
+func (w *throttledWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
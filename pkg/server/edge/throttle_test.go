@@ -0,0 +1,104 @@
+package edge
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestThrottle(t *testing.T) {
+	payload := bytes.Repeat([]byte("x"), 128<<10)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(payload)
+	})
+	// serve 并发地为每个客户端IP发送一个请求，返回全部完成的时间
+	serve := func(t *testing.T, h http.Handler, ips ...string) time.Duration {
+		t.Helper()
+		start := time.Now()
+		var wg sync.WaitGroup
+		for _, ip := range ips {
+			wg.Add(1)
+			go func(ip string) {
+				defer wg.Done()
+				req := httptest.NewRequest(http.MethodGet, "/large.bin", nil)
+				req.RemoteAddr = ip + ":40000"
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, req)
+				if rec.Body.Len() != len(payload) {
+					t.Errorf("Expected full body for %s, got %d bytes", ip, rec.Body.Len())
+				}
+			}(ip)
+		}
+		wg.Wait()
+		return time.Since(start)
+	}
+
+	t.Run("per client", func(t *testing.T) {
+		h, err := Throttle(handler, BandwidthLimit{PerClient: 256 << 10})
+		if err != nil {
+			t.Fatalf("Failed to create throttle: %v", err)
+		}
+		// 64KB突发之后剩下的64KB按256KB/s发送，至少0.25秒；两个客户端各有自己的速率
+		if elapsed := serve(t, h, "192.0.2.1", "192.0.2.2"); elapsed < 200*time.Millisecond || elapsed > 600*time.Millisecond {
+			t.Errorf("Expected each client to be limited separately, took %v", elapsed)
+		}
+		if n := len(h.(*throttle).clients.limiters); n != 0 {
+			t.Errorf("Expected idle client limiters to be released, got %d", n)
+		}
+	})
+
+	t.Run("total", func(t *testing.T) {
+		h, err := Throttle(handler, BandwidthLimit{Total: 256 << 10, PerClient: 1 << 30})
+		if err != nil {
+			t.Fatalf("Failed to create throttle: %v", err)
+		}
+		// 两个客户端合计256KB，突发之后的192KB按256KB/s发送，至少0.75秒
+		if elapsed := serve(t, h, "192.0.2.1", "192.0.2.2"); elapsed < 650*time.Millisecond {
+			t.Errorf("Expected clients to share the total rate, took %v", elapsed)
+		}
+	})
+
+	t.Run("client key", func(t *testing.T) {
+		h, err := Throttle(handler, BandwidthLimit{
+			PerClient: 256 << 10,
+			ClientKey: func(r *http.Request) string { return "everyone" },
+		})
+		if err != nil {
+			t.Fatalf("Failed to create throttle: %v", err)
+		}
+		if elapsed := serve(t, h, "192.0.2.1", "192.0.2.2"); elapsed < 650*time.Millisecond {
+			t.Errorf("Expected clients with the same key to share a rate, took %v", elapsed)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		h, err := Throttle(handler, BandwidthLimit{PerClient: 1 << 10})
+		if err != nil {
+			t.Fatalf("Failed to create throttle: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/large.bin", nil).WithContext(ctx))
+		if time.Since(start) > time.Second || rec.Body.Len() >= len(payload) {
+			t.Errorf("Expected write to stop when the client goes away, sent %d bytes in %v", rec.Body.Len(), time.Since(start))
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		if h, err := Throttle(handler, BandwidthLimit{}); err != nil || h == nil {
+			t.Errorf("Expected handler without limits, got %v", err)
+		}
+		if _, err := Throttle(handler, BandwidthLimit{Total: -1}); err == nil {
+			t.Error("Expected error for negative rate")
+		}
+		if _, err := NewServer(handler, Config{Bandwidth: &BandwidthLimit{PerClient: -1}}); err == nil {
+			t.Error("Expected server to reject negative rate")
+		}
+	})
+}
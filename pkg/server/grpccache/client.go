@@ -0,0 +1,301 @@
+package grpccache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/seraphico/EdgeOrigin/internal/grpcwire"
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+	"github.com/seraphico/EdgeOrigin/pkg/server/tlsutil"
+)
+
+// ClientOptions 客户端选项
+type ClientOptions struct {
+	ChunkSize int // Set发送文件数据的分片大小，默认1MB
+
+	// TLS Dial使用TLS连接，设置CertFile和KeyFile时向服务端出示证书（双向TLS）；为空时使用不加密的连接
+	TLS *tlsutil.Config
+}
+
+// Client 通过gRPC访问远程EdgeOrigin节点的缓存，实现filecache.Cache
+// 远程节点的错误转换回filecache中的错误，可以用errors.Is判断
+type Client struct {
+	conn  grpc.ClientConnInterface
+	owned *grpc.ClientConn // Dial创建的连接，根客户端关闭时关闭
+	opts  ClientOptions
+	path  []string // 命名空间路径，根客户端为空
+
+	namespaces map[string]*Client
+	nsMu       sync.Mutex
+}
+
+// NewClient 使用已有的连接创建客户端，关闭客户端不会关闭conn
+func NewClient(conn grpc.ClientConnInterface, opts ClientOptions) *Client {
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = defaultChunkSize
+	}
+	return &Client{conn: conn, opts: opts, namespaces: make(map[string]*Client)}
+}
+
+// Dial 连接addr上的缓存服务，按opts.TLS建立TLS连接，未设置时使用不加密的连接；关闭客户端时关闭连接
+func Dial(addr string, opts ClientOptions, dialOpts ...grpc.DialOption) (*Client, error) {
+	creds := insecure.NewCredentials()
+	if opts.TLS != nil {
+		var err error
+		if creds, err = opts.TLS.ClientCredentials(); err != nil {
+			return nil, fmt.Errorf("failed to configure tls: %w", err)
+		}
+	}
+	dialOpts = append([]grpc.DialOption{grpc.WithTransportCredentials(creds)}, dialOpts...)
+	conn, err := grpc.Dial(addr, dialOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial %s: %w", addr, err)
+	}
+	c := NewClient(conn, opts)
+	c.owned = conn
+	return c, nil
+}
+
+// Set 分片上传文件，读取data失败时取消上传
+func (c *Client) Set(ctx context.Context, key string, data io.Reader, mimeType string, ttl time.Duration) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.conn.NewStream(ctx, &serviceDesc.Streams[setStream], methodName("Set"), grpcwire.CallOption())
+	if err != nil {
+		return fromStatus(err)
+	}
+
+	req := &SetRequest{Namespace: c.path, Key: key, MimeType: mimeType, TTL: ttl}
+	buf := make([]byte, c.opts.ChunkSize)
+	for first := true; ; first = false {
+		n, err := io.ReadFull(data, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("failed to read data: %w", err)
+		}
+		req.Data = buf[:n]
+		if n > 0 || first {
+			if sendErr := stream.SendMsg(req); sendErr != nil {
+				// 服务端提前结束时，真正的错误由RecvMsg返回
+				if sendErr == io.EOF {
+					break
+				}
+				return fromStatus(sendErr)
+			}
+		}
+		if err != nil {
+			break
+		}
+		req = &SetRequest{}
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return fromStatus(err)
+	}
+	return fromStatus(stream.RecvMsg(&Empty{}))
+}
+
+// Get 下载文件，返回的reader边读边接收数据，调用方负责关闭
+func (c *Client) Get(ctx context.Context, key string) (io.ReadCloser, *filecache.FileInfo, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	stream, err := c.conn.NewStream(ctx, &serviceDesc.Streams[getStream], methodName("Get"), grpcwire.CallOption())
+	if err != nil {
+		cancel()
+		return nil, nil, fromStatus(err)
+	}
+	if err := stream.SendMsg(&KeyRequest{Namespace: c.path, Key: key}); err != nil {
+		cancel()
+		return nil, nil, fromStatus(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		cancel()
+		return nil, nil, fromStatus(err)
+	}
+
+	first := new(GetResponse)
+	if err := stream.RecvMsg(first); err != nil {
+		cancel()
+		if err == io.EOF {
+			return nil, nil, fmt.Errorf("get %s: empty response", key)
+		}
+		return nil, nil, fromStatus(err)
+	}
+	if first.Info == nil {
+		cancel()
+		return nil, nil, fmt.Errorf("get %s: missing file info", key)
+	}
+	return &streamReader{stream: stream, cancel: cancel, buf: first.Data}, first.Info, nil
+}
+
+// Exists 检查文件是否存在
+func (c *Client) Exists(ctx context.Context, key string) (bool, error) {
+	resp := new(ExistsResponse)
+	if err := c.conn.Invoke(ctx, methodName("Exists"), &KeyRequest{Namespace: c.path, Key: key}, resp, grpcwire.CallOption()); err != nil {
+		return false, fromStatus(err)
+	}
+	return resp.Exists, nil
+}
+
+// Delete 删除文件
+func (c *Client) Delete(ctx context.Context, key string) error {
+	return fromStatus(c.conn.Invoke(ctx, methodName("Delete"), &KeyRequest{Namespace: c.path, Key: key}, &Empty{}, grpcwire.CallOption()))
+}
+
+// List 列出命名空间中的所有文件
+func (c *Client) List(ctx context.Context) ([]*filecache.FileInfo, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.conn.NewStream(ctx, &serviceDesc.Streams[listStream], methodName("List"), grpcwire.CallOption())
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	if err := stream.SendMsg(&NamespaceRequest{Namespace: c.path}); err != nil {
+		return nil, fromStatus(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, fromStatus(err)
+	}
+
+	var files []*filecache.FileInfo
+	for {
+		resp := new(ListResponse)
+		err := stream.RecvMsg(resp)
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fromStatus(err)
+		}
+		files = append(files, resp.Files...)
+	}
+}
+
+// GetInfo 获取文件信息
+func (c *Client) GetInfo(ctx context.Context, key string) (*filecache.FileInfo, error) {
+	resp := new(fileInfoMessage)
+	if err := c.conn.Invoke(ctx, methodName("GetInfo"), &KeyRequest{Namespace: c.path, Key: key}, resp, grpcwire.CallOption()); err != nil {
+		return nil, fromStatus(err)
+	}
+	return &resp.FileInfo, nil
+}
+
+// Cleanup 清理远程节点上的过期文件
+func (c *Client) Cleanup(ctx context.Context) error {
+	return fromStatus(c.conn.Invoke(ctx, methodName("Cleanup"), &NamespaceRequest{Namespace: c.path}, &Empty{}, grpcwire.CallOption()))
+}
+
+// Flush 清空远程节点上的命名空间
+func (c *Client) Flush(ctx context.Context) error {
+	return fromStatus(c.conn.Invoke(ctx, methodName("Flush"), &NamespaceRequest{Namespace: c.path}, &Empty{}, grpcwire.CallOption()))
+}
+
+// Namespace 返回远程节点上命名空间的客户端，共享同一个连接
+func (c *Client) Namespace(name string) filecache.Cache {
+	if name == "" {
+		return c
+	}
+
+	c.nsMu.Lock()
+	defer c.nsMu.Unlock()
+
+	if ns, ok := c.namespaces[name]; ok {
+		return ns
+	}
+	ns := NewClient(c.conn, c.opts)
+	ns.path = append(append([]string(nil), c.path...), name)
+	c.namespaces[name] = ns
+	return ns
+}
+
+// Close 关闭Dial创建的连接，NewClient创建的客户端和命名空间的Close不做任何事
+func (c *Client) Close() error {
+	if c.owned == nil {
+		return nil
+	}
+	return c.owned.Close()
+}
+
+// Stats 获取远程节点上命名空间的统计信息，命中率由远程节点统计
+func (c *Client) Stats() (*filecache.Stats, error) {
+	resp := new(statsMessage)
+	if err := c.conn.Invoke(context.Background(), methodName("Stats"), &NamespaceRequest{Namespace: c.path}, resp, grpcwire.CallOption()); err != nil {
+		return nil, fromStatus(err)
+	}
+	return &resp.Stats, nil
+}
+
+// streamReader 从Get的响应流中读取文件数据
+type streamReader struct {
+	stream grpc.ClientStream
+	cancel context.CancelFunc
+	buf    []byte
+	err    error
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		resp := new(GetResponse)
+		if err := r.stream.RecvMsg(resp); err != nil {
+			if err == io.EOF {
+				r.err = io.EOF
+			} else {
+				r.err = fromStatus(err)
+			}
+			continue
+		}
+		r.buf = resp.Data
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// Close 取消响应流，未读完的数据被丢弃
+func (r *streamReader) Close() error {
+	r.cancel()
+	return nil
+}
+
+// fromStatus 把gRPC状态码转换回缓存错误，使调用方可以用errors.Is判断
+func fromStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	var sentinel error
+	switch st.Code() {
+	case codes.NotFound:
+		sentinel = filecache.ErrNotFound
+	case codes.OutOfRange:
+		sentinel = filecache.ErrEntryTooLarge
+	case codes.ResourceExhausted:
+		sentinel = filecache.ErrQuotaExceeded
+	case codes.FailedPrecondition:
+		sentinel = filecache.ErrCacheClosed
+	case codes.Canceled:
+		sentinel = context.Canceled
+	case codes.DeadlineExceeded:
+		sentinel = context.DeadlineExceeded
+	default:
+		return err
+	}
+	return fmt.Errorf("%w: %s", sentinel, st.Message())
+}
@@ -0,0 +1,126 @@
+package grpccache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	remote, err := filecache.NewMemoryCacheWithConfig(&filecache.Config{
+		MaxCacheSize:    1 << 20,
+		MaxEntrySize:    1 << 10,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer remote.Close()
+
+	var client filecache.Cache = NewClient(startServer(t, remote, ServerOptions{ChunkSize: 5}), ClientOptions{ChunkSize: 3})
+	defer client.Close()
+
+	t.Run("SetAndGet", func(t *testing.T) {
+		if err := client.Set(ctx, "hello.txt", strings.NewReader("hello world"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		reader, info, err := client.Get(ctx, "hello.txt")
+		if err != nil {
+			t.Fatalf("Failed to get file: %v", err)
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatalf("Failed to read file: %v", err)
+		}
+		if string(data) != "hello world" {
+			t.Errorf("Expected 'hello world', got %q", data)
+		}
+		if info.Size != 11 || info.MimeType != "text/plain" {
+			t.Errorf("Expected 11 bytes of text/plain, got %d bytes of %s", info.Size, info.MimeType)
+		}
+	})
+
+	t.Run("EmptyFile", func(t *testing.T) {
+		if err := client.Set(ctx, "empty", strings.NewReader(""), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		reader, _, err := client.Get(ctx, "empty")
+		if err != nil {
+			t.Fatalf("Failed to get file: %v", err)
+		}
+		defer reader.Close()
+		if data, _ := io.ReadAll(reader); len(data) != 0 {
+			t.Errorf("Expected empty file, got %q", data)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		if _, _, err := client.Get(ctx, "missing"); !errors.Is(err, filecache.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+		if _, err := client.GetInfo(ctx, "missing"); !errors.Is(err, filecache.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+		large := strings.NewReader(strings.Repeat("x", 2<<10))
+		if err := client.Set(ctx, "large", large, "text/plain", time.Hour); !errors.Is(err, filecache.ErrEntryTooLarge) {
+			t.Errorf("Expected ErrEntryTooLarge, got %v", err)
+		}
+	})
+
+	t.Run("ListExistsDelete", func(t *testing.T) {
+		files, err := client.List(ctx)
+		if err != nil {
+			t.Fatalf("Failed to list files: %v", err)
+		}
+		if len(files) != 2 {
+			t.Errorf("Expected 2 files, got %d", len(files))
+		}
+		if err := client.Delete(ctx, "empty"); err != nil {
+			t.Fatalf("Failed to delete file: %v", err)
+		}
+		if exists, err := client.Exists(ctx, "empty"); err != nil || exists {
+			t.Errorf("Expected file to be deleted, got %v, %v", exists, err)
+		}
+	})
+
+	t.Run("Namespace", func(t *testing.T) {
+		ns := client.Namespace("tenant").Namespace("images")
+		if err := ns.Set(ctx, "hello.txt", strings.NewReader("nested"), "text/plain", time.Hour); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		if exists, _ := remote.Namespace("tenant").Namespace("images").Exists(ctx, "hello.txt"); !exists {
+			t.Error("Expected file in nested namespace on the remote node")
+		}
+		stats, err := ns.Stats()
+		if err != nil {
+			t.Fatalf("Failed to get stats: %v", err)
+		}
+		if stats.TotalFiles != 1 {
+			t.Errorf("Expected 1 file in namespace, got %d", stats.TotalFiles)
+		}
+	})
+
+	t.Run("Chain", func(t *testing.T) {
+		local := filecache.NewMemoryCache(1 << 20)
+		chain, err := filecache.Chain(local, client)
+		if err != nil {
+			t.Fatalf("Failed to create chain: %v", err)
+		}
+		reader, _, err := chain.Get(ctx, "hello.txt")
+		if err != nil {
+			t.Fatalf("Failed to get file through chain: %v", err)
+		}
+		reader.Close()
+		if exists, _ := local.Exists(ctx, "hello.txt"); !exists {
+			t.Error("Expected remote hit to be promoted to the local tier")
+		}
+	})
+}
@@ -0,0 +1,332 @@
+package grpccache
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/seraphico/EdgeOrigin/internal/grpcwire"
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// Empty 没有字段的消息
+type Empty struct{}
+
+// MarshalWire 按protobuf线格式编码
+func (*Empty) MarshalWire() ([]byte, error) { return nil, nil }
+
+// UnmarshalWire 按protobuf线格式解码
+func (*Empty) UnmarshalWire([]byte) error { return nil }
+
+// SetRequest 写入文件的消息，只有第一条消息携带Namespace、Key、MimeType、TTL
+type SetRequest struct {
+	Namespace []string
+	Key       string
+	MimeType  string
+	TTL       time.Duration
+	Data      []byte // 文件数据分片
+}
+
+// MarshalWire 按protobuf线格式编码
+func (m *SetRequest) MarshalWire() ([]byte, error) {
+	b := make([]byte, 0, len(m.Data)+len(m.Key)+32)
+	b = appendNamespace(b, 1, m.Namespace)
+	b = grpcwire.AppendString(b, 2, m.Key)
+	b = grpcwire.AppendString(b, 3, m.MimeType)
+	b = grpcwire.AppendVarint(b, 4, uint64(m.TTL))
+	b = grpcwire.AppendBytes(b, 5, m.Data)
+	return b, nil
+}
+
+// UnmarshalWire 按protobuf线格式解码
+func (m *SetRequest) UnmarshalWire(b []byte) error {
+	*m = SetRequest{}
+	return grpcwire.Decode(b, func(f grpcwire.Field) error {
+		switch f.Num {
+		case 1:
+			m.Namespace = append(m.Namespace, f.String())
+		case 2:
+			m.Key = f.String()
+		case 3:
+			m.MimeType = f.String()
+		case 4:
+			m.TTL = time.Duration(f.Varint)
+		case 5:
+			m.Data = append([]byte(nil), f.Bytes...)
+		}
+		return nil
+	})
+}
+
+// KeyRequest 针对单个键的请求
+type KeyRequest struct {
+	Namespace []string
+	Key       string
+}
+
+// MarshalWire 按protobuf线格式编码
+func (m *KeyRequest) MarshalWire() ([]byte, error) {
+	var b []byte
+	b = appendNamespace(b, 1, m.Namespace)
+	b = grpcwire.AppendString(b, 2, m.Key)
+	return b, nil
+}
+
+// UnmarshalWire 按protobuf线格式解码
+func (m *KeyRequest) UnmarshalWire(b []byte) error {
+	*m = KeyRequest{}
+	return grpcwire.Decode(b, func(f grpcwire.Field) error {
+		switch f.Num {
+		case 1:
+			m.Namespace = append(m.Namespace, f.String())
+		case 2:
+			m.Key = f.String()
+		}
+		return nil
+	})
+}
+
+// NamespaceRequest 针对整个命名空间的请求
+type NamespaceRequest struct {
+	Namespace []string
+}
+
+// MarshalWire 按protobuf线格式编码
+func (m *NamespaceRequest) MarshalWire() ([]byte, error) {
+	return appendNamespace(nil, 1, m.Namespace), nil
+}
+
+// UnmarshalWire 按protobuf线格式解码
+func (m *NamespaceRequest) UnmarshalWire(b []byte) error {
+	*m = NamespaceRequest{}
+	return grpcwire.Decode(b, func(f grpcwire.Field) error {
+		if f.Num == 1 {
+			m.Namespace = append(m.Namespace, f.String())
+		}
+		return nil
+	})
+}
+
+// GetResponse 读取文件的消息，只有第一条消息携带Info
+type GetResponse struct {
+	Info *filecache.FileInfo
+	Data []byte // 文件数据分片
+}
+
+// MarshalWire 按protobuf线格式编码
+func (m *GetResponse) MarshalWire() ([]byte, error) {
+	b := make([]byte, 0, len(m.Data)+16)
+	if m.Info != nil {
+		b = appendFileInfo(b, 1, m.Info)
+	}
+	b = grpcwire.AppendBytes(b, 2, m.Data)
+	return b, nil
+}
+
+// UnmarshalWire 按protobuf线格式解码
+func (m *GetResponse) UnmarshalWire(b []byte) error {
+	*m = GetResponse{}
+	return grpcwire.Decode(b, func(f grpcwire.Field) error {
+		switch f.Num {
+		case 1:
+			info, err := decodeFileInfo(f.Bytes)
+			if err != nil {
+				return err
+			}
+			m.Info = info
+		case 2:
+			m.Data = append([]byte(nil), f.Bytes...)
+		}
+		return nil
+	})
+}
+
+// ExistsResponse Exists的结果
+type ExistsResponse struct {
+	Exists bool
+}
+
+// MarshalWire 按protobuf线格式编码
+func (m *ExistsResponse) MarshalWire() ([]byte, error) {
+	if !m.Exists {
+		return nil, nil
+	}
+	return grpcwire.AppendVarint(nil, 1, 1), nil
+}
+
+// UnmarshalWire 按protobuf线格式解码
+func (m *ExistsResponse) UnmarshalWire(b []byte) error {
+	*m = ExistsResponse{}
+	return grpcwire.Decode(b, func(f grpcwire.Field) error {
+		if f.Num == 1 {
+			m.Exists = f.Varint != 0
+		}
+		return nil
+	})
+}
+
+// ListResponse 一批文件信息
+type ListResponse struct {
+	Files []*filecache.FileInfo
+}
+
+// MarshalWire 按protobuf线格式编码
+func (m *ListResponse) MarshalWire() ([]byte, error) {
+	var b []byte
+	for _, info := range m.Files {
+		b = appendFileInfo(b, 1, info)
+	}
+	return b, nil
+}
+
+// UnmarshalWire 按protobuf线格式解码
+func (m *ListResponse) UnmarshalWire(b []byte) error {
+	*m = ListResponse{}
+	return grpcwire.Decode(b, func(f grpcwire.Field) error {
+		if f.Num != 1 {
+			return nil
+		}
+		info, err := decodeFileInfo(f.Bytes)
+		if err != nil {
+			return err
+		}
+		m.Files = append(m.Files, info)
+		return nil
+	})
+}
+
+// fileInfoMessage GetInfo的结果
+type fileInfoMessage struct {
+	filecache.FileInfo
+}
+
+// MarshalWire 按protobuf线格式编码
+func (m *fileInfoMessage) MarshalWire() ([]byte, error) {
+	return marshalFileInfo(nil, &m.FileInfo), nil
+}
+
+// UnmarshalWire 按protobuf线格式解码
+func (m *fileInfoMessage) UnmarshalWire(b []byte) error {
+	info, err := decodeFileInfo(b)
+	if err != nil {
+		return err
+	}
+	m.FileInfo = *info
+	return nil
+}
+
+// statsMessage Stats的结果
+type statsMessage struct {
+	filecache.Stats
+}
+
+// MarshalWire 按protobuf线格式编码
+func (m *statsMessage) MarshalWire() ([]byte, error) {
+	var b []byte
+	b = grpcwire.AppendVarint(b, 1, uint64(m.TotalFiles))
+	b = grpcwire.AppendVarint(b, 2, uint64(m.TotalSize))
+	b = grpcwire.AppendDouble(b, 3, m.HitRate)
+	b = grpcwire.AppendDouble(b, 4, m.MissRate)
+	b = grpcwire.AppendVarint(b, 5, uint64(m.ExpiredFiles))
+	b = grpcwire.AppendVarint(b, 6, uint64(m.Evictions))
+	b = grpcwire.AppendVarint(b, 7, uint64(unixNano(m.LastCleanup)))
+	b = grpcwire.AppendVarint(b, 8, uint64(unixNano(m.LastBackup)))
+	return b, nil
+}
+
+// UnmarshalWire 按protobuf线格式解码
+func (m *statsMessage) UnmarshalWire(b []byte) error {
+	*m = statsMessage{}
+	return grpcwire.Decode(b, func(f grpcwire.Field) error {
+		switch f.Num {
+		case 1:
+			m.TotalFiles = int64(f.Varint)
+		case 2:
+			m.TotalSize = int64(f.Varint)
+		case 3:
+			m.HitRate = f.Double()
+		case 4:
+			m.MissRate = f.Double()
+		case 5:
+			m.ExpiredFiles = int64(f.Varint)
+		case 6:
+			m.Evictions = int64(f.Varint)
+		case 7:
+			m.LastCleanup = fromUnixNano(int64(f.Varint))
+		case 8:
+			m.LastBackup = fromUnixNano(int64(f.Varint))
+		}
+		return nil
+	})
+}
+
+// appendFileInfo 追加FileInfo字段
+func appendFileInfo(b []byte, num protowire.Number, info *filecache.FileInfo) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, marshalFileInfo(nil, info))
+}
+
+// marshalFileInfo 编码FileInfo消息
+func marshalFileInfo(b []byte, info *filecache.FileInfo) []byte {
+	b = grpcwire.AppendString(b, 1, info.Key)
+	b = grpcwire.AppendVarint(b, 2, uint64(info.Size))
+	b = grpcwire.AppendString(b, 3, info.MimeType)
+	b = grpcwire.AppendVarint(b, 4, uint64(unixNano(info.CreatedAt)))
+	b = grpcwire.AppendVarint(b, 5, uint64(unixNano(info.ExpiresAt)))
+	b = grpcwire.AppendVarint(b, 6, uint64(info.AccessCount))
+	b = grpcwire.AppendVarint(b, 7, uint64(unixNano(info.LastAccess)))
+	b = grpcwire.AppendVarint(b, 8, uint64(info.Version))
+	return b
+}
+
+// decodeFileInfo 解码FileInfo消息
+func decodeFileInfo(b []byte) (*filecache.FileInfo, error) {
+	info := &filecache.FileInfo{}
+	err := grpcwire.Decode(b, func(f grpcwire.Field) error {
+		switch f.Num {
+		case 1:
+			info.Key = f.String()
+		case 2:
+			info.Size = int64(f.Varint)
+		case 3:
+			info.MimeType = f.String()
+		case 4:
+			info.CreatedAt = fromUnixNano(int64(f.Varint))
+		case 5:
+			info.ExpiresAt = fromUnixNano(int64(f.Varint))
+		case 6:
+			info.AccessCount = int64(f.Varint)
+		case 7:
+			info.LastAccess = fromUnixNano(int64(f.Varint))
+		case 8:
+			info.Version = int64(f.Varint)
+		}
+		return nil
+	})
+	return info, err
+}
+
+// appendNamespace 追加命名空间路径，repeated字段的空字符串也要保留
+func appendNamespace(b []byte, num protowire.Number, path []string) []byte {
+	for _, name := range path {
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendString(b, name)
+	}
+	return b
+}
+
+// unixNano 返回Unix纳秒，零值时间编码为0
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// fromUnixNano 把Unix纳秒转换为时间，0表示零值时间
+func fromUnixNano(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
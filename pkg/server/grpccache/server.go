@@ -0,0 +1,239 @@
+// Package grpccache 通过gRPC对外提供filecache.Cache，供其他语言的服务和远程节点使用
+// 服务定义见cache.proto，消息直接按protobuf线格式编码，可以用protoc为其他语言生成客户端
+package grpccache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/seraphico/EdgeOrigin/internal/grpcwire"
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+	"github.com/seraphico/EdgeOrigin/pkg/server/tlsutil"
+)
+
+const (
+	defaultChunkSize = 1 << 20 // 1MB
+	// listBatchSize List每条消息包含的文件数
+	listBatchSize = 1000
+)
+
+// ServerOptions 服务选项
+type ServerOptions struct {
+	ChunkSize int // Get推送文件数据的分片大小，默认1MB
+
+	// TLS NewGRPCServer创建的服务器使用TLS，设置ClientCAFile时只接受持有有效客户端证书的连接（双向TLS）
+	TLS *tlsutil.Config
+}
+
+// Server 把filecache.Cache暴露为gRPC服务
+type Server struct {
+	cache filecache.Cache
+	opts  ServerOptions
+}
+
+// NewServer 创建缓存服务，关闭cache由调用方负责
+func NewServer(cache filecache.Cache, opts ServerOptions) *Server {
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = defaultChunkSize
+	}
+	return &Server{cache: cache, opts: opts}
+}
+
+// ServerCodec 返回创建gRPC服务器时使用的选项：grpc.NewServer(grpccache.ServerCodec())
+// 消息是手写的，只有Go客户端时不需要该选项；protoc生成的其他语言客户端访问时需要
+func ServerCodec() grpc.ServerOption {
+	return grpcwire.ServerOption()
+}
+
+// Register 在gRPC服务器上注册缓存服务
+func (s *Server) Register(r grpc.ServiceRegistrar) {
+	r.RegisterService(&serviceDesc, s)
+}
+
+// NewGRPCServer 创建已注册缓存服务的gRPC服务器，使用ServerCodec和opts.TLS；
+// 同一个服务器上还可以注册其他服务，例如复制和集群成员
+func (s *Server) NewGRPCServer(opts ...grpc.ServerOption) (*grpc.Server, error) {
+	opts = append([]grpc.ServerOption{ServerCodec()}, opts...)
+	if s.opts.TLS != nil {
+		creds, err := s.opts.TLS.ServerCredentials()
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure tls: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	server := grpc.NewServer(opts...)
+	s.Register(server)
+	return server, nil
+}
+
+// namespace 返回路径对应的命名空间
+func (s *Server) namespace(path []string) filecache.Cache {
+	cache := s.cache
+	for _, name := range path {
+		cache = cache.Namespace(name)
+	}
+	return cache
+}
+
+// set 接收文件数据并边收边写入缓存
+func (s *Server) set(ss grpc.ServerStream) error {
+	first := new(SetRequest)
+	if err := ss.RecvMsg(first); err != nil {
+		return err
+	}
+	if first.Key == "" {
+		return status.Error(codes.InvalidArgument, "key cannot be empty")
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		if _, err := pw.Write(first.Data); err != nil {
+			return
+		}
+		for {
+			req := new(SetRequest)
+			err := ss.RecvMsg(req)
+			if err == io.EOF {
+				pw.Close()
+				return
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := pw.Write(req.Data); err != nil {
+				return
+			}
+		}
+	}()
+
+	err := s.namespace(first.Namespace).Set(ss.Context(), first.Key, pr, first.MimeType, first.TTL)
+	// 缓存提前返回时让接收数据的协程退出
+	pr.CloseWithError(errors.New("set finished"))
+	if err != nil {
+		return toStatus(err)
+	}
+	return ss.SendMsg(&Empty{})
+}
+
+// get 先发送文件信息，再分片发送文件数据
+func (s *Server) get(req *KeyRequest, ss grpc.ServerStream) error {
+	reader, info, err := s.namespace(req.Namespace).Get(ss.Context(), req.Key)
+	if err != nil {
+		return toStatus(err)
+	}
+	defer reader.Close()
+
+	buf := make([]byte, s.opts.ChunkSize)
+	resp := &GetResponse{Info: info}
+	for {
+		n, err := io.ReadFull(reader, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return status.Errorf(codes.Internal, "failed to read %s: %v", req.Key, err)
+		}
+		resp.Data = buf[:n]
+		if n > 0 || resp.Info != nil {
+			if err := ss.SendMsg(resp); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			return nil
+		}
+		resp = &GetResponse{}
+	}
+}
+
+func (s *Server) exists(ctx context.Context, req *KeyRequest) (*ExistsResponse, error) {
+	exists, err := s.namespace(req.Namespace).Exists(ctx, req.Key)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &ExistsResponse{Exists: exists}, nil
+}
+
+func (s *Server) delete(ctx context.Context, req *KeyRequest) (*Empty, error) {
+	if err := s.namespace(req.Namespace).Delete(ctx, req.Key); err != nil {
+		return nil, toStatus(err)
+	}
+	return &Empty{}, nil
+}
+
+func (s *Server) getInfo(ctx context.Context, req *KeyRequest) (*fileInfoMessage, error) {
+	info, err := s.namespace(req.Namespace).GetInfo(ctx, req.Key)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &fileInfoMessage{FileInfo: *info}, nil
+}
+
+// list 分批发送文件信息
+func (s *Server) list(req *NamespaceRequest, ss grpc.ServerStream) error {
+	files, err := s.namespace(req.Namespace).List(ss.Context())
+	if err != nil {
+		return toStatus(err)
+	}
+	for start := 0; start < len(files); start += listBatchSize {
+		end := start + listBatchSize
+		if end > len(files) {
+			end = len(files)
+		}
+		if err := ss.SendMsg(&ListResponse{Files: files[start:end]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) cleanup(ctx context.Context, req *NamespaceRequest) (*Empty, error) {
+	if err := s.namespace(req.Namespace).Cleanup(ctx); err != nil {
+		return nil, toStatus(err)
+	}
+	return &Empty{}, nil
+}
+
+func (s *Server) flush(ctx context.Context, req *NamespaceRequest) (*Empty, error) {
+	if err := s.namespace(req.Namespace).Flush(ctx); err != nil {
+		return nil, toStatus(err)
+	}
+	return &Empty{}, nil
+}
+
+func (s *Server) stats(_ context.Context, req *NamespaceRequest) (*statsMessage, error) {
+	stats, err := s.namespace(req.Namespace).Stats()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &statsMessage{Stats: *stats}, nil
+}
+
+// toStatus 把缓存错误转换为cache.proto约定的gRPC状态码
+func toStatus(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	code := codes.Internal
+	switch {
+	case errors.Is(err, filecache.ErrNotFound), errors.Is(err, filecache.ErrExpired):
+		// 已过期但尚未清理的文件对客户端来说与不存在相同
+		code = codes.NotFound
+	case errors.Is(err, filecache.ErrEntryTooLarge):
+		code = codes.OutOfRange
+	case errors.Is(err, filecache.ErrQuotaExceeded):
+		code = codes.ResourceExhausted
+	case errors.Is(err, filecache.ErrCacheClosed):
+		code = codes.FailedPrecondition
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	}
+	return status.Error(code, err.Error())
+}
@@ -0,0 +1,315 @@
+package grpccache
+
+import (
+	"context"
+	"crypto/x509"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/seraphico/EdgeOrigin/internal/grpcwire"
+	"github.com/seraphico/EdgeOrigin/internal/tlstest"
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+	"github.com/seraphico/EdgeOrigin/pkg/server/tlsutil"
+)
+
+// startServer 在内存连接上启动缓存服务，返回连接到它的客户端
+func startServer(t *testing.T, cache filecache.Cache, opts ServerOptions, serverOpts ...grpc.ServerOption) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(serverOpts...)
+	NewServer(cache, opts).Register(server)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpcwire.CallOption()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// rawSet 以多条消息发送文件
+func rawSet(ctx context.Context, conn *grpc.ClientConn, first *SetRequest, chunks ...string) error {
+	stream, err := conn.NewStream(ctx, &serviceDesc.Streams[setStream], methodName("Set"))
+	if err != nil {
+		return err
+	}
+	if err := stream.SendMsg(first); err != nil {
+		return err
+	}
+	for _, chunk := range chunks {
+		if err := stream.SendMsg(&SetRequest{Data: []byte(chunk)}); err != nil {
+			return err
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+	return stream.RecvMsg(&Empty{})
+}
+
+// rawGet 读取文件，返回文件信息、内容和消息数
+func rawGet(ctx context.Context, conn *grpc.ClientConn, req *KeyRequest) (*filecache.FileInfo, string, int, error) {
+	stream, err := conn.NewStream(ctx, &serviceDesc.Streams[getStream], methodName("Get"))
+	if err != nil {
+		return nil, "", 0, err
+	}
+	if err := stream.SendMsg(req); err != nil {
+		return nil, "", 0, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, "", 0, err
+	}
+
+	var info *filecache.FileInfo
+	var data strings.Builder
+	messages := 0
+	for {
+		resp := new(GetResponse)
+		if err := stream.RecvMsg(resp); err != nil {
+			if messages > 0 && err == io.EOF {
+				return info, data.String(), messages, nil
+			}
+			return nil, "", messages, err
+		}
+		if messages == 0 {
+			info = resp.Info
+		}
+		data.Write(resp.Data)
+		messages++
+	}
+}
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	conn := startServer(t, cache, ServerOptions{ChunkSize: 4})
+
+	t.Run("SetStreaming", func(t *testing.T) {
+		first := &SetRequest{Key: "hello.txt", MimeType: "text/plain", TTL: time.Hour, Data: []byte("hel")}
+		if err := rawSet(ctx, conn, first, "lo ", "world"); err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		reader, info, err := cache.Get(ctx, "hello.txt")
+		if err != nil {
+			t.Fatalf("Failed to get file: %v", err)
+		}
+		reader.Close()
+		if info.Size != 11 || info.MimeType != "text/plain" {
+			t.Errorf("Expected 11 bytes of text/plain, got %d bytes of %s", info.Size, info.MimeType)
+		}
+	})
+
+	t.Run("GetStreaming", func(t *testing.T) {
+		info, data, messages, err := rawGet(ctx, conn, &KeyRequest{Key: "hello.txt"})
+		if err != nil {
+			t.Fatalf("Failed to get file: %v", err)
+		}
+		if data != "hello world" {
+			t.Errorf("Expected 'hello world', got %q", data)
+		}
+		if messages != 3 {
+			t.Errorf("Expected 3 chunks, got %d", messages)
+		}
+		if info == nil || info.Key != "hello.txt" || info.ExpiresAt.IsZero() {
+			t.Errorf("Expected file info in first message, got %+v", info)
+		}
+	})
+
+	t.Run("ExistsAndDelete", func(t *testing.T) {
+		exists := new(ExistsResponse)
+		if err := conn.Invoke(ctx, methodName("Exists"), &KeyRequest{Key: "hello.txt"}, exists); err != nil {
+			t.Fatalf("Failed to check existence: %v", err)
+		}
+		if !exists.Exists {
+			t.Error("Expected file to exist")
+		}
+		if err := conn.Invoke(ctx, methodName("Delete"), &KeyRequest{Key: "hello.txt"}, &Empty{}); err != nil {
+			t.Fatalf("Failed to delete file: %v", err)
+		}
+		if err := conn.Invoke(ctx, methodName("Exists"), &KeyRequest{Key: "hello.txt"}, exists); err != nil {
+			t.Fatalf("Failed to check existence: %v", err)
+		}
+		if exists.Exists {
+			t.Error("Expected file to be deleted")
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		_, _, _, err := rawGet(ctx, conn, &KeyRequest{Key: "missing"})
+		if status.Code(err) != codes.NotFound {
+			t.Errorf("Expected NotFound, got %v", err)
+		}
+		err = conn.Invoke(ctx, methodName("GetInfo"), &KeyRequest{Key: "missing"}, &fileInfoMessage{})
+		if status.Code(err) != codes.NotFound {
+			t.Errorf("Expected NotFound, got %v", err)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		err := rawSet(ctx, conn, &SetRequest{Namespace: []string{"expiry"}, Key: "short.txt", MimeType: "text/plain", TTL: 50 * time.Millisecond, Data: []byte("short")})
+		if err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		_, _, _, err = rawGet(ctx, conn, &KeyRequest{Namespace: []string{"expiry"}, Key: "short.txt"})
+		if status.Code(err) != codes.NotFound {
+			t.Errorf("Expected NotFound, got %v", err)
+		}
+	})
+
+	t.Run("NamespaceListAndStats", func(t *testing.T) {
+		for _, key := range []string{"a", "b", "c"} {
+			first := &SetRequest{Namespace: []string{"tenant"}, Key: key, MimeType: "text/plain", Data: []byte(key)}
+			if err := rawSet(ctx, conn, first); err != nil {
+				t.Fatalf("Failed to set file: %v", err)
+			}
+		}
+
+		stream, err := conn.NewStream(ctx, &serviceDesc.Streams[listStream], methodName("List"))
+		if err != nil {
+			t.Fatalf("Failed to list files: %v", err)
+		}
+		stream.SendMsg(&NamespaceRequest{Namespace: []string{"tenant"}})
+		stream.CloseSend()
+		var keys []string
+		for {
+			resp := new(ListResponse)
+			if err := stream.RecvMsg(resp); err != nil {
+				break
+			}
+			for _, info := range resp.Files {
+				keys = append(keys, info.Key)
+			}
+		}
+		sort.Strings(keys)
+		if strings.Join(keys, ",") != "a,b,c" {
+			t.Errorf("Expected a,b,c in namespace, got %v", keys)
+		}
+
+		stats := new(statsMessage)
+		if err := conn.Invoke(ctx, methodName("Stats"), &NamespaceRequest{}, stats); err != nil {
+			t.Fatalf("Failed to get stats: %v", err)
+		}
+		if stats.TotalFiles != 0 {
+			t.Errorf("Expected root namespace to be empty, got %d files", stats.TotalFiles)
+		}
+		if err := conn.Invoke(ctx, methodName("Flush"), &NamespaceRequest{Namespace: []string{"tenant"}}, &Empty{}); err != nil {
+			t.Fatalf("Failed to flush namespace: %v", err)
+		}
+		if exists, _ := cache.Namespace("tenant").Exists(ctx, "a"); exists {
+			t.Error("Expected namespace to be flushed")
+		}
+	})
+
+	t.Run("EmptyKey", func(t *testing.T) {
+		err := rawSet(ctx, conn, &SetRequest{Data: []byte("x")})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Expected InvalidArgument, got %v", err)
+		}
+	})
+}
+
+func TestServerCodec(t *testing.T) {
+	ctx := context.Background()
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	if err := cache.Set(ctx, "hello.txt", strings.NewReader("hello"), "text/plain", time.Hour); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+
+	// protoc生成的客户端以默认的proto作为content-subtype
+	protoSubtype := []grpc.CallOption{grpc.ForceCodec(grpcwire.Codec()), grpc.CallContentSubtype("proto")}
+
+	t.Run("ProtoSubtype", func(t *testing.T) {
+		conn := startServer(t, cache, ServerOptions{}, ServerCodec())
+		resp := &fileInfoMessage{}
+		if err := conn.Invoke(ctx, methodName("GetInfo"), &KeyRequest{Key: "hello.txt"}, resp, protoSubtype...); err != nil {
+			t.Fatalf("Failed to get file info: %v", err)
+		}
+		if resp.Size != 5 {
+			t.Errorf("Expected 5 bytes, got %d", resp.Size)
+		}
+	})
+
+	t.Run("DefaultProtoCodec", func(t *testing.T) {
+		conn := startServer(t, cache, ServerOptions{})
+		err := conn.Invoke(ctx, methodName("GetInfo"), &KeyRequest{Key: "hello.txt"}, &fileInfoMessage{}, protoSubtype...)
+		if err == nil {
+			t.Error("Expected error without ServerCodec")
+		}
+	})
+}
+
+func TestServerTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := tlstest.NewCA(t, dir, "edge-ca")
+	serverCert, serverKey := ca.Issue("edge-1", 2, x509.ExtKeyUsageServerAuth)
+	peerCert, peerKey := ca.Issue("edge-2", 3, x509.ExtKeyUsageClientAuth)
+	otherCert, otherKey := ca.Issue("intruder", 4, x509.ExtKeyUsageClientAuth)
+
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	cache.Set(context.Background(), "a.txt", strings.NewReader("a"), "text/plain", time.Hour)
+
+	server, err := NewServer(cache, ServerOptions{TLS: &tlsutil.Config{
+		CertFile:     serverCert,
+		KeyFile:      serverKey,
+		ClientCAFile: ca.File,
+		AllowedPeers: []string{"edge-2"},
+	}}).NewGRPCServer()
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go server.Serve(lis)
+	defer server.Stop()
+
+	exists := func(cert, key string) error {
+		remote, err := Dial(lis.Addr().String(), ClientOptions{TLS: &tlsutil.Config{
+			CertFile: cert, KeyFile: key, CAFile: ca.File, ServerName: "edge-1",
+		}})
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		defer remote.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = remote.Exists(ctx, "a.txt")
+		return err
+	}
+
+	if err := exists(peerCert, peerKey); err != nil {
+		t.Errorf("Expected allowed peer to connect, got %v", err)
+	}
+	if err := exists(otherCert, otherKey); err == nil {
+		t.Error("Expected peer outside allowed_peers to be rejected")
+	}
+	if err := exists("", ""); err == nil {
+		t.Error("Expected client without certificate to be rejected")
+	}
+
+	if _, err := NewServer(cache, ServerOptions{TLS: &tlsutil.Config{}}).NewGRPCServer(); err == nil {
+		t.Error("Expected error for tls without a certificate")
+	}
+}
@@ -0,0 +1,153 @@
+package grpccache
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// 服务定义与cache.proto保持一致
+const serviceName = "edgeorigin.cache.v1.Cache"
+
+// cacheServer 缓存服务的服务端接口
+type cacheServer interface {
+	set(ss grpc.ServerStream) error
+	get(req *KeyRequest, ss grpc.ServerStream) error
+	exists(ctx context.Context, req *KeyRequest) (*ExistsResponse, error)
+	delete(ctx context.Context, req *KeyRequest) (*Empty, error)
+	getInfo(ctx context.Context, req *KeyRequest) (*fileInfoMessage, error)
+	list(req *NamespaceRequest, ss grpc.ServerStream) error
+	cleanup(ctx context.Context, req *NamespaceRequest) (*Empty, error)
+	flush(ctx context.Context, req *NamespaceRequest) (*Empty, error)
+	stats(ctx context.Context, req *NamespaceRequest) (*statsMessage, error)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*cacheServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Exists",
+			Handler: keyHandler("Exists", func(s cacheServer, ctx context.Context, req *KeyRequest) (interface{}, error) {
+				return s.exists(ctx, req)
+			}),
+		},
+		{
+			MethodName: "Delete",
+			Handler: keyHandler("Delete", func(s cacheServer, ctx context.Context, req *KeyRequest) (interface{}, error) {
+				return s.delete(ctx, req)
+			}),
+		},
+		{
+			MethodName: "GetInfo",
+			Handler: keyHandler("GetInfo", func(s cacheServer, ctx context.Context, req *KeyRequest) (interface{}, error) {
+				return s.getInfo(ctx, req)
+			}),
+		},
+		{
+			MethodName: "Cleanup",
+			Handler: namespaceHandler("Cleanup", func(s cacheServer, ctx context.Context, req *NamespaceRequest) (interface{}, error) {
+				return s.cleanup(ctx, req)
+			}),
+		},
+		{
+			MethodName: "Flush",
+			Handler: namespaceHandler("Flush", func(s cacheServer, ctx context.Context, req *NamespaceRequest) (interface{}, error) {
+				return s.flush(ctx, req)
+			}),
+		},
+		{
+			MethodName: "Stats",
+			Handler: namespaceHandler("Stats", func(s cacheServer, ctx context.Context, req *NamespaceRequest) (interface{}, error) {
+				return s.stats(ctx, req)
+			}),
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Set",
+			Handler:       setHandler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Get",
+			Handler:       getHandler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "List",
+			Handler:       listHandler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "cache.proto",
+}
+
+// 流式方法在serviceDesc.Streams中的位置
+const (
+	setStream = iota
+	getStream
+	listStream
+)
+
+// methodName 返回方法的完整名称
+func methodName(name string) string {
+	return "/" + serviceName + "/" + name
+}
+
+// methodHandler 一元方法的处理函数，与grpc.MethodDesc.Handler的类型相同
+type methodHandler = func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error)
+
+// keyHandler 返回请求为KeyRequest的一元方法处理函数
+func keyHandler(name string, call func(s cacheServer, ctx context.Context, req *KeyRequest) (interface{}, error)) methodHandler {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		req := new(KeyRequest)
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return call(srv.(cacheServer), ctx, req)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: methodName(name)}
+		return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return call(srv.(cacheServer), ctx, req.(*KeyRequest))
+		})
+	}
+}
+
+// namespaceHandler 返回请求为NamespaceRequest的一元方法处理函数
+func namespaceHandler(name string, call func(s cacheServer, ctx context.Context, req *NamespaceRequest) (interface{}, error)) methodHandler {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		req := new(NamespaceRequest)
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return call(srv.(cacheServer), ctx, req)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: methodName(name)}
+		return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return call(srv.(cacheServer), ctx, req.(*NamespaceRequest))
+		})
+	}
+}
+
+func setHandler(srv interface{}, ss grpc.ServerStream) error {
+	return srv.(cacheServer).set(ss)
+}
+
+func getHandler(srv interface{}, ss grpc.ServerStream) error {
+	req := new(KeyRequest)
+	if err := ss.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(cacheServer).get(req, ss)
+}
+
+func listHandler(srv interface{}, ss grpc.ServerStream) error {
+	req := new(NamespaceRequest)
+	if err := ss.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(cacheServer).list(req, ss)
+}
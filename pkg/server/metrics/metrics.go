@@ -0,0 +1,179 @@
+// Package metrics 以Prometheus文本格式导出边缘节点的指标：缓存及其各命名空间的文件数、大小、命中率和淘汰数，
+// 存储的磁盘占用和上游的健康状态。每次抓取时读取一次统计信息，不依赖Prometheus的客户端库
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+	"github.com/seraphico/EdgeOrigin/pkg/origin"
+)
+
+// contentType Prometheus文本格式0.0.4
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// HealthReporter 报告上游的健康状态，例如origin.Proxy和origin.Router
+type HealthReporter interface {
+	UpstreamHealth() []origin.UpstreamHealth
+}
+
+// Options 指标选项
+type Options struct {
+	// Upstreams 导出上游的健康状态，例如origin.Router；为空时不导出
+	Upstreams HealthReporter
+}
+
+// handler 指标处理器
+type handler struct {
+	cache filecache.Cache
+	opts  Options
+}
+
+// NewHandler 返回导出cache指标的处理器。cache实现filecache.NamespaceLister时同时导出每个顶层命名空间
+// （例如各站点）的统计信息，namespace标签为空的是cache自身；实现filecache.DiskUsageReporter时导出磁盘占用
+func NewHandler(cache filecache.Cache, opts Options) http.Handler {
+	return &handler{cache: cache, opts: opts}
+}
+
+// family 同名的一组指标
+type family struct {
+	name    string
+	help    string
+	typ     string // gauge或counter
+	samples []sample
+}
+
+// sample 一个带标签的取值
+type sample struct {
+	labels []string // 成对的标签名和取值
+	value  float64
+}
+
+// add 添加一个取值
+func (f *family) add(value float64, labels ...string) {
+	f.samples = append(f.samples, sample{labels: labels, value: value})
+}
+
+// ServeHTTP 收集并返回所有指标，读取统计信息失败时返回500
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	families, err := h.collect(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	for _, f := range families {
+		if len(f.samples) > 0 {
+			writeFamily(&buf, f)
+		}
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(buf.Bytes())
+}
+
+// collect 读取缓存、磁盘和上游的状态
+func (h *handler) collect(r *http.Request) ([]*family, error) {
+	var (
+		files       = &family{name: "edgeorigin_cache_files", help: "Number of entries in the cache.", typ: "gauge"}
+		size        = &family{name: "edgeorigin_cache_size_bytes", help: "Total size of the entries in the cache.", typ: "gauge"}
+		hitRatio    = &family{name: "edgeorigin_cache_hit_ratio", help: "Ratio of reads that found an entry.", typ: "gauge"}
+		expired     = &family{name: "edgeorigin_cache_expired_files", help: "Number of expired entries removed by the last cleanup.", typ: "gauge"}
+		evictions   = &family{name: "edgeorigin_cache_evictions_total", help: "Number of entries evicted by quotas or size limits.", typ: "counter"}
+		lastCleanup = &family{name: "edgeorigin_cache_last_cleanup_timestamp_seconds", help: "Time of the last cleanup, 0 if none.", typ: "gauge"}
+		lastBackup  = &family{name: "edgeorigin_cache_last_backup_timestamp_seconds", help: "Time of the last successful backup, 0 if none.", typ: "gauge"}
+		disk        = &family{name: "edgeorigin_disk_usage_bytes", help: "Disk space used by the storage.", typ: "gauge"}
+		diskTotal   = &family{name: "edgeorigin_disk_total_bytes", help: "Capacity of the file system holding the data directory.", typ: "gauge"}
+		diskFree    = &family{name: "edgeorigin_disk_free_bytes", help: "Free space of the file system holding the data directory.", typ: "gauge"}
+		healthy     = &family{name: "edgeorigin_upstream_healthy", help: "Whether the circuit breaker of the upstream is closed.", typ: "gauge"}
+		failures    = &family{name: "edgeorigin_upstream_consecutive_failures", help: "Consecutive failures of the upstream.", typ: "gauge"}
+	)
+
+	namespaces := []string{""}
+	if lister, ok := h.cache.(filecache.NamespaceLister); ok {
+		names, err := lister.Namespaces(r.Context())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list namespaces: %w", err)
+		}
+		namespaces = append(namespaces, names...)
+	}
+	for _, name := range namespaces {
+		stats, err := h.cache.Namespace(name).Stats()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get stats of namespace %q: %w", name, err)
+		}
+		files.add(float64(stats.TotalFiles), "namespace", name)
+		size.add(float64(stats.TotalSize), "namespace", name)
+		hitRatio.add(stats.HitRate, "namespace", name)
+		expired.add(float64(stats.ExpiredFiles), "namespace", name)
+		evictions.add(float64(stats.Evictions), "namespace", name)
+		lastCleanup.add(timestamp(stats.LastCleanup), "namespace", name)
+		lastBackup.add(timestamp(stats.LastBackup), "namespace", name)
+	}
+
+	if reporter, ok := h.cache.(filecache.DiskUsageReporter); ok {
+		usage, err := reporter.DiskUsage()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get disk usage: %w", err)
+		}
+		disk.add(float64(usage.LSM), "component", "lsm")
+		disk.add(float64(usage.ValueLog), "component", "value_log")
+		if usage.DiskTotal > 0 {
+			diskTotal.add(float64(usage.DiskTotal))
+			diskFree.add(float64(usage.DiskFree))
+		}
+	}
+
+	if h.opts.Upstreams != nil {
+		for _, u := range h.opts.Upstreams.UpstreamHealth() {
+			up := 0.0
+			if u.Healthy {
+				up = 1
+			}
+			healthy.add(up, "site", u.Site, "upstream", u.Name)
+			failures.add(float64(u.Failures), "site", u.Site, "upstream", u.Name)
+		}
+	}
+	return []*family{files, size, hitRatio, expired, evictions, lastCleanup, lastBackup, disk, diskTotal, diskFree, healthy, failures}, nil
+}
+
+// timestamp 返回Unix时间（秒），零值返回0
+func timestamp(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return float64(t.UnixNano()) / 1e9
+}
+
+// labelEscaper 转义标签值中的反斜杠、双引号和换行
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeFamily 按文本格式写出一组指标
+func writeFamily(buf *bytes.Buffer, f *family) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.typ)
+	for _, s := range f.samples {
+		buf.WriteString(f.name)
+		if len(s.labels) > 0 {
+			buf.WriteByte('{')
+			for i := 0; i < len(s.labels); i += 2 {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				fmt.Fprintf(buf, `%s="%s"`, s.labels[i], labelEscaper.Replace(s.labels[i+1]))
+			}
+			buf.WriteByte('}')
+		}
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatFloat(s.value, 'g', -1, 64))
+		buf.WriteByte('\n')
+	}
+}
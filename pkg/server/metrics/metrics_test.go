@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+	"github.com/seraphico/EdgeOrigin/pkg/origin"
+)
+
+// staticHealth 返回固定的上游健康状态
+type staticHealth []origin.UpstreamHealth
+
+func (h staticHealth) UpstreamHealth() []origin.UpstreamHealth {
+	return h
+}
+
+func TestHandler(t *testing.T) {
+	cache, err := filecache.NewBadgerCache(&filecache.Config{
+		DataDir:         t.TempDir(),
+		MaxCacheSize:    1 << 20,
+		DefaultTTL:      time.Hour,
+		CleanupInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx := context.Background()
+	if err := cache.Set(ctx, "a", strings.NewReader("hello"), "text/plain", time.Hour); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+	if err := cache.Namespace(`static."example".com`).Set(ctx, "b", strings.NewReader("hi"), "text/plain", time.Hour); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+
+	upstreams := staticHealth{
+		{Site: "static.example.com", Name: "http://origin-a", Healthy: true},
+		{Site: "static.example.com", Name: "http://origin-b", Failures: 3},
+	}
+	srv := httptest.NewServer(NewHandler(cache, Options{Upstreams: upstreams}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Failed to get metrics: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("Expected metrics in text format, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	for _, want := range []string{
+		"# TYPE edgeorigin_cache_files gauge\n",
+		`edgeorigin_cache_files{namespace=""} 1` + "\n",
+		`edgeorigin_cache_size_bytes{namespace=""} 5` + "\n",
+		`edgeorigin_cache_files{namespace="static.\"example\".com"} 1` + "\n",
+		`edgeorigin_cache_size_bytes{namespace="static.\"example\".com"} 2` + "\n",
+		"# TYPE edgeorigin_cache_evictions_total counter\n",
+		`edgeorigin_disk_usage_bytes{component="lsm"} `,
+		`edgeorigin_upstream_healthy{site="static.example.com",upstream="http://origin-a"} 1` + "\n",
+		`edgeorigin_upstream_healthy{site="static.example.com",upstream="http://origin-b"} 0` + "\n",
+		`edgeorigin_upstream_consecutive_failures{site="static.example.com",upstream="http://origin-b"} 3` + "\n",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", want, body)
+		}
+	}
+
+	t.Run("Method", func(t *testing.T) {
+		resp, err := http.Post(srv.URL, "text/plain", nil)
+		if err != nil {
+			t.Fatalf("Failed to post: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("Expected 405, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		closed, err := filecache.NewBadgerCache(&filecache.Config{DataDir: t.TempDir(), MaxCacheSize: 1 << 20, DefaultTTL: time.Hour, CleanupInterval: time.Hour})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		closed.Close()
+		rec := httptest.NewRecorder()
+		NewHandler(closed, Options{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("Expected 500 for a closed cache, got %d", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,188 @@
+// Package s3api 提供S3兼容的HTTP接口，支持GetObject、PutObject、HeadObject、DeleteObject、
+// DeleteObjects和ListObjectsV2，配置为S3源站的CDN和工具不需要修改代码就可以直接指向EdgeOrigin
+package s3api
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/internal/s3client"
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// Options S3接口选项，Credentials和AnonymousRead至少设置一个
+type Options struct {
+	// Credentials 访问密钥ID到密钥的映射，请求需使用Signature Version 4签名
+	Credentials map[string]string
+
+	// AnonymousRead 允许未签名的GET、HEAD和列表请求，适合作为公开的CDN源站
+	AnonymousRead bool
+
+	// Buckets 允许访问的存储桶，为空时允许任意存储桶
+	Buckets []string
+
+	// Domain 虚拟主机风格访问的域名，例如设置为 s3.example.com 时 assets.s3.example.com 访问assets存储桶；
+	// 为空时只支持路径风格访问
+	Domain string
+}
+
+// handler S3接口，每个存储桶对应缓存中同名的命名空间
+type handler struct {
+	cache   filecache.Cache
+	opts    Options
+	buckets map[string]bool
+	now     func() time.Time
+}
+
+// NewHandler 返回S3兼容接口，存储桶映射为cache中同名的命名空间，对象键即缓存键
+func NewHandler(cache filecache.Cache, opts Options) (http.Handler, error) {
+	if len(opts.Credentials) == 0 && !opts.AnonymousRead {
+		return nil, fmt.Errorf("s3 api requires credentials or anonymous read access")
+	}
+
+	h := &handler{cache: cache, opts: opts, now: time.Now}
+	if len(opts.Buckets) > 0 {
+		h.buckets = make(map[string]bool, len(opts.Buckets))
+		for _, bucket := range opts.Buckets {
+			h.buckets[bucket] = true
+		}
+	}
+	return h, nil
+}
+
+// ServeHTTP 鉴权后按存储桶和对象键分发请求
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h.authenticate(r); err != nil {
+		writeAuthError(w, r, err)
+		return
+	}
+
+	bucket, key := h.route(r)
+	if bucket == "" {
+		if r.Method != http.MethodGet {
+			writeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed", "method not allowed")
+			return
+		}
+		h.listBuckets(w, r)
+		return
+	}
+	if h.buckets != nil && !h.buckets[bucket] {
+		writeError(w, r, http.StatusNotFound, "NoSuchBucket", "the specified bucket does not exist")
+		return
+	}
+	cache := h.cache.Namespace(bucket)
+
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		h.listObjects(w, r, cache, bucket)
+	case key == "" && r.Method == http.MethodPost && r.URL.Query().Has("delete"):
+		h.deleteObjects(w, r, cache)
+	case key == "" && (r.Method == http.MethodHead || r.Method == http.MethodPut):
+		// 命名空间不需要创建，HeadBucket和CreateBucket总是成功
+		w.WriteHeader(http.StatusOK)
+	case key == "":
+		writeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed", "method not allowed")
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		h.getObject(w, r, cache, key)
+	case r.Method == http.MethodPut:
+		h.putObject(w, r, cache, key)
+	case r.Method == http.MethodDelete:
+		h.deleteObject(w, r, cache, key)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed", "method not allowed")
+	}
+}
+
+// authenticate 校验请求签名，允许匿名读取时未签名的读请求直接通过
+func (h *handler) authenticate(r *http.Request) error {
+	if r.Header.Get("Authorization") == "" && h.opts.AnonymousRead &&
+		(r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		return nil
+	}
+	_, err := s3client.Verify(r, func(id string) (string, bool) {
+		secret, ok := h.opts.Credentials[id]
+		return secret, ok
+	}, h.now())
+	return err
+}
+
+// route 从请求中解析存储桶和对象键
+func (h *handler) route(r *http.Request) (string, string) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if h.opts.Domain != "" {
+		host := r.Host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+		if bucket, ok := strings.CutSuffix(host, "."+h.opts.Domain); ok && bucket != "" {
+			return bucket, path
+		}
+	}
+	bucket, key, _ := strings.Cut(path, "/")
+	return bucket, key
+}
+
+// errorResponse S3错误响应
+type errorResponse struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string   `xml:"Code"`
+	Message  string   `xml:"Message"`
+	Resource string   `xml:"Resource"`
+}
+
+// writeError 返回S3格式的错误，HEAD请求只返回状态码
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(status)
+		return
+	}
+	writeXML(w, status, errorResponse{Code: code, Message: message, Resource: r.URL.Path})
+}
+
+// writeAuthError 把签名校验错误转换为S3错误码
+func writeAuthError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, s3client.ErrMalformedAuthorization):
+		writeError(w, r, http.StatusBadRequest, "AuthorizationHeaderMalformed", err.Error())
+	case errors.Is(err, s3client.ErrInvalidAccessKey):
+		writeError(w, r, http.StatusForbidden, "InvalidAccessKeyId", err.Error())
+	case errors.Is(err, s3client.ErrSignatureMismatch):
+		writeError(w, r, http.StatusForbidden, "SignatureDoesNotMatch", err.Error())
+	case errors.Is(err, s3client.ErrRequestTimeSkewed):
+		writeError(w, r, http.StatusForbidden, "RequestTimeTooSkewed", err.Error())
+	default:
+		writeError(w, r, http.StatusForbidden, "AccessDenied", err.Error())
+	}
+}
+
+// writeCacheError 把缓存错误转换为S3错误码
+func writeCacheError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, filecache.ErrNotFound), errors.Is(err, filecache.ErrExpired):
+		// 已过期但尚未清理的对象与不存在的对象一样返回NoSuchKey
+		writeError(w, r, http.StatusNotFound, "NoSuchKey", "the specified key does not exist")
+	case errors.Is(err, errDigestMismatch):
+		writeError(w, r, http.StatusBadRequest, "BadDigest", err.Error())
+	case errors.Is(err, filecache.ErrEntryTooLarge):
+		writeError(w, r, http.StatusBadRequest, "EntityTooLarge", err.Error())
+	case errors.Is(err, filecache.ErrQuotaExceeded):
+		writeError(w, r, http.StatusInsufficientStorage, "QuotaExceeded", err.Error())
+	case errors.Is(err, filecache.ErrCacheClosed):
+		writeError(w, r, http.StatusServiceUnavailable, "ServiceUnavailable", err.Error())
+	default:
+		writeError(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+	}
+}
+
+// writeXML 返回XML响应
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,312 @@
+package s3api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/internal/s3client"
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+var testCreds = s3client.Credentials{AccessKeyID: "edge", SecretAccessKey: "secret"}
+
+// newTestServer 启动S3接口，返回服务和底层缓存
+func newTestServer(t *testing.T, opts Options) (*httptest.Server, filecache.Cache) {
+	t.Helper()
+
+	cache := filecache.NewMemoryCache(1 << 20)
+	t.Cleanup(func() { cache.Close() })
+	handler, err := NewHandler(cache, opts)
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv, cache
+}
+
+// send 发送请求，creds为nil时不签名
+func send(t *testing.T, method, url string, creds *s3client.Credentials, body string, header http.Header) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if creds != nil {
+		payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+		if payloadHash == "" {
+			payloadHash = s3client.UnsignedPayload
+		}
+		s3client.Sign(req, *creds, "us-east-1", "s3", payloadHash, time.Now())
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestS3CacheCompatibility(t *testing.T) {
+	srv, backing := newTestServer(t, Options{Credentials: map[string]string{"edge": "secret"}})
+	ctx := context.Background()
+
+	// filecache的S3缓存可以直接把本接口作为后端
+	cache, err := filecache.NewS3Cache(filecache.S3Config{
+		Endpoint:        srv.URL,
+		Bucket:          "assets",
+		AccessKeyID:     testCreds.AccessKeyID,
+		SecretAccessKey: testCreds.SecretAccessKey,
+		PathStyle:       true,
+		DefaultTTL:      time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	defer cache.Close()
+
+	if err := cache.Set(ctx, "img/logo 1.png", strings.NewReader("png data"), "image/png", 10*time.Minute); err != nil {
+		t.Fatalf("Failed to set file: %v", err)
+	}
+	reader, info, err := cache.Get(ctx, "img/logo 1.png")
+	if err != nil {
+		t.Fatalf("Failed to get file: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(data) != "png data" || info.MimeType != "image/png" {
+		t.Errorf("Expected png data of image/png, got %q of %s", data, info.MimeType)
+	}
+	if ttl := time.Until(info.ExpiresAt); ttl > 10*time.Minute || ttl < 9*time.Minute {
+		t.Errorf("Expected TTL to be kept, got %v", ttl)
+	}
+
+	stored, err := backing.Namespace("assets").GetInfo(ctx, "data/img/logo 1.png")
+	if err != nil {
+		t.Fatalf("Expected object in bucket namespace: %v", err)
+	}
+	if d := stored.ExpiresAt.Sub(info.ExpiresAt); d > time.Millisecond || d < -time.Millisecond {
+		t.Errorf("Expected backing expiry %v, got %v", info.ExpiresAt, stored.ExpiresAt)
+	}
+
+	cache.Set(ctx, "css/site.css", strings.NewReader("body{}"), "text/css", time.Hour)
+	files, err := cache.List(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list files: %v", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("Expected 2 files, got %d", len(files))
+	}
+
+	if err := cache.Flush(ctx); err != nil {
+		t.Fatalf("Failed to flush cache: %v", err)
+	}
+	if files, _ := backing.Namespace("assets").List(ctx); len(files) != 0 {
+		t.Errorf("Expected bucket to be empty after flush, got %d files", len(files))
+	}
+	if _, _, err := cache.Get(ctx, "css/site.css"); err != filecache.ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	srv, backing := newTestServer(t, Options{
+		Credentials:   map[string]string{"edge": "secret"},
+		AnonymousRead: true,
+		Buckets:       []string{"assets"},
+	})
+	url := srv.URL + "/assets/"
+
+	t.Run("Auth", func(t *testing.T) {
+		if resp := send(t, http.MethodPut, url+"a.txt", nil, "a", nil); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected 403 for unsigned write, got %d", resp.StatusCode)
+		}
+		wrong := &s3client.Credentials{AccessKeyID: "edge", SecretAccessKey: "wrong"}
+		resp := send(t, http.MethodGet, url+"a.txt", wrong, "", nil)
+		var res errorResponse
+		xml.NewDecoder(resp.Body).Decode(&res)
+		if resp.StatusCode != http.StatusForbidden || res.Code != "SignatureDoesNotMatch" {
+			t.Errorf("Expected SignatureDoesNotMatch, got %d %s", resp.StatusCode, res.Code)
+		}
+		if resp := send(t, http.MethodGet, srv.URL+"/other/a.txt", &testCreds, "", nil); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected 404 for unknown bucket, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("PutAndGet", func(t *testing.T) {
+		header := http.Header{"Content-Type": {"text/plain"}, "Cache-Control": {"max-age=600"}}
+		resp := send(t, http.MethodPut, url+"docs/hello.txt", &testCreds, "hello world", header)
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") == "" {
+			t.Fatalf("Expected 200 with ETag, got %d", resp.StatusCode)
+		}
+		tag := resp.Header.Get("ETag")
+		info, err := backing.Namespace("assets").GetInfo(context.Background(), "docs/hello.txt")
+		if err != nil {
+			t.Fatalf("Failed to get info: %v", err)
+		}
+		if ttl := time.Until(info.ExpiresAt); ttl > 10*time.Minute || ttl < 9*time.Minute {
+			t.Errorf("Expected TTL from max-age, got %v", ttl)
+		}
+
+		resp = send(t, http.MethodGet, url+"docs/hello.txt", nil, "", http.Header{"Range": {"bytes=6-"}})
+		data, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusPartialContent || string(data) != "world" {
+			t.Errorf("Expected 206 with 'world', got %d with %q", resp.StatusCode, data)
+		}
+		if cr := resp.Header.Get("Content-Range"); cr != "bytes 6-10/11" {
+			t.Errorf("Expected Content-Range bytes 6-10/11, got %q", cr)
+		}
+
+		resp = send(t, http.MethodHead, url+"docs/hello.txt", nil, "", http.Header{"If-None-Match": {tag}})
+		if resp.StatusCode != http.StatusNotModified {
+			t.Errorf("Expected 304, got %d", resp.StatusCode)
+		}
+		resp = send(t, http.MethodGet, url+"docs/hello.txt", nil, "", http.Header{"Range": {"bytes=20-"}})
+		if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+			t.Errorf("Expected 416, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("ExpiredObject", func(t *testing.T) {
+		err := backing.Namespace("assets").Set(context.Background(), "old.txt", strings.NewReader("old"), "text/plain", 10*time.Millisecond)
+		if err != nil {
+			t.Fatalf("Failed to set file: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		resp := send(t, http.MethodGet, url+"old.txt", &testCreds, "", nil)
+		var res errorResponse
+		xml.NewDecoder(resp.Body).Decode(&res)
+		if resp.StatusCode != http.StatusNotFound || res.Code != "NoSuchKey" {
+			t.Errorf("Expected NoSuchKey for an expired object, got %d %s", resp.StatusCode, res.Code)
+		}
+		if resp := send(t, http.MethodHead, url+"old.txt", &testCreds, "", nil); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected 404 for HEAD of an expired object, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("BadDigest", func(t *testing.T) {
+		header := http.Header{"X-Amz-Content-Sha256": {strings.Repeat("0", 64)}}
+		resp := send(t, http.MethodPut, url+"bad.txt", &testCreds, "data", header)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400, got %d", resp.StatusCode)
+		}
+		if exists, _ := backing.Namespace("assets").Exists(context.Background(), "bad.txt"); exists {
+			t.Error("Expected object with bad digest not to be stored")
+		}
+	})
+
+	t.Run("ListObjectsV2", func(t *testing.T) {
+		for _, key := range []string{"docs/a b.txt", "docs/sub/c.txt", "img/x.png", "root.txt"} {
+			send(t, http.MethodPut, url+key, &testCreds, key, nil)
+		}
+
+		list := func(query string) *listBucketResult {
+			resp := send(t, http.MethodGet, url+"?list-type=2"+query, nil, "", nil)
+			res := &listBucketResult{}
+			if err := xml.NewDecoder(resp.Body).Decode(res); err != nil {
+				t.Fatalf("Failed to decode list: %v", err)
+			}
+			return res
+		}
+		names := func(res *listBucketResult) string {
+			var names []string
+			for _, obj := range res.Contents {
+				names = append(names, obj.Key)
+			}
+			for _, p := range res.CommonPrefixes {
+				names = append(names, p.Prefix)
+			}
+			sort.Strings(names)
+			return strings.Join(names, ",")
+		}
+
+		if got := names(list("&delimiter=/")); got != "docs/,img/,root.txt" {
+			t.Errorf("Expected docs/,img/,root.txt, got %s", got)
+		}
+		if got := names(list("&prefix=docs/&delimiter=/&encoding-type=url")); got != "docs/a%20b.txt,docs/hello.txt,docs/sub/" {
+			t.Errorf("Expected url-encoded docs listing, got %s", got)
+		}
+
+		var pages []string
+		token := ""
+		for {
+			res := list("&max-keys=2&continuation-token=" + token)
+			pages = append(pages, names(res))
+			if !res.IsTruncated {
+				break
+			}
+			token = res.NextContinuationToken
+		}
+		if strings.Join(pages, "|") != "docs/a b.txt,docs/hello.txt|docs/sub/c.txt,img/x.png|root.txt" {
+			t.Errorf("Unexpected pages: %v", pages)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if resp := send(t, http.MethodDelete, url+"root.txt", &testCreds, "", nil); resp.StatusCode != http.StatusNoContent {
+			t.Errorf("Expected 204, got %d", resp.StatusCode)
+		}
+		if resp := send(t, http.MethodDelete, url+"root.txt", &testCreds, "", nil); resp.StatusCode != http.StatusNoContent {
+			t.Errorf("Expected 204 for missing object, got %d", resp.StatusCode)
+		}
+		if resp := send(t, http.MethodGet, url+"root.txt", nil, "", nil); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected 404, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("DeleteObjectsBadDigest", func(t *testing.T) {
+		send(t, http.MethodPut, url+"keep.txt", &testCreds, "keep", nil)
+		signed := `<Delete><Object><Key>other.txt</Key></Object></Delete>`
+		tampered := `<Delete><Object><Key>keep.txt</Key></Object></Delete>`
+		sum := sha256.Sum256([]byte(signed))
+		header := http.Header{"X-Amz-Content-Sha256": {hex.EncodeToString(sum[:])}}
+		resp := send(t, http.MethodPost, url+"?delete", &testCreds, tampered, header)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400, got %d", resp.StatusCode)
+		}
+		if exists, _ := backing.Namespace("assets").Exists(context.Background(), "keep.txt"); !exists {
+			t.Error("Expected a tampered delete request not to delete anything")
+		}
+
+		sum = sha256.Sum256([]byte(tampered))
+		header = http.Header{"X-Amz-Content-Sha256": {hex.EncodeToString(sum[:])}}
+		resp = send(t, http.MethodPost, url+"?delete", &testCreds, tampered, header)
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected 200, got %d", resp.StatusCode)
+		}
+		if exists, _ := backing.Namespace("assets").Exists(context.Background(), "keep.txt"); exists {
+			t.Error("Expected a verified delete request to delete the object")
+		}
+	})
+}
+
+func TestVirtualHostStyle(t *testing.T) {
+	srv, backing := newTestServer(t, Options{AnonymousRead: true, Domain: "s3.example.com"})
+	backing.Namespace("media").Set(context.Background(), "video.mp4", strings.NewReader("mp4"), "video/mp4", time.Hour)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/video.mp4", nil)
+	req.Host = "media.s3.example.com"
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(data) != "mp4" {
+		t.Errorf("Expected 200 with 'mp4', got %d with %q", resp.StatusCode, data)
+	}
+}
@@ -0,0 +1,251 @@
+package s3api
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/internal/s3client"
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+const (
+	// s3Namespace S3响应的XML命名空间
+	s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+	// maxKeys 单次列表和批量删除的最大数量
+	maxKeys = 1000
+	// timeFormat 列表中的时间格式
+	timeFormat = "2006-01-02T15:04:05.000Z"
+)
+
+// listObject 列表中的对象
+type listObject struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+// commonPrefix 按分隔符合并的前缀
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+// listBucketResult ListObjectsV2响应
+type listBucketResult struct {
+	XMLName               xml.Name       `xml:"ListBucketResult"`
+	Xmlns                 string         `xml:"xmlns,attr"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	StartAfter            string         `xml:"StartAfter,omitempty"`
+	EncodingType          string         `xml:"EncodingType,omitempty"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	KeyCount              int            `xml:"KeyCount"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	Contents              []listObject   `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+// listObjects 实现ListObjectsV2，支持prefix、delimiter、start-after、max-keys和continuation-token，
+// 过期文件不出现在列表中
+func (h *handler) listObjects(w http.ResponseWriter, r *http.Request, cache filecache.Cache, bucket string) {
+	q := r.URL.Query()
+	if q.Get("list-type") != "2" {
+		writeError(w, r, http.StatusNotImplemented, "NotImplemented", "only ListObjectsV2 is supported")
+		return
+	}
+
+	limit := maxKeys
+	if v := q.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, r, http.StatusBadRequest, "InvalidArgument", "invalid max-keys")
+			return
+		}
+		if n < limit {
+			limit = n
+		}
+	}
+	after := q.Get("start-after")
+	if token := q.Get("continuation-token"); token != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "InvalidArgument", "invalid continuation token")
+			return
+		}
+		after = string(decoded)
+	}
+
+	files, err := cache.List(r.Context())
+	if err != nil {
+		writeCacheError(w, r, err)
+		return
+	}
+	prefix, delimiter := q.Get("prefix"), q.Get("delimiter")
+	now := h.now()
+	byKey := make(map[string]*filecache.FileInfo, len(files))
+	keys := make([]string, 0, len(files))
+	for _, info := range files {
+		if strings.HasPrefix(info.Key, prefix) && !now.After(info.ExpiresAt) {
+			byKey[info.Key] = info
+			keys = append(keys, info.Key)
+		}
+	}
+	sort.Strings(keys)
+
+	encode := func(s string) string { return s }
+	if q.Get("encoding-type") == "url" {
+		encode = func(s string) string { return s3client.Escape(s, false) }
+	}
+	res := &listBucketResult{
+		Xmlns:             s3Namespace,
+		Name:              bucket,
+		Prefix:            encode(prefix),
+		Delimiter:         encode(delimiter),
+		StartAfter:        encode(q.Get("start-after")),
+		EncodingType:      q.Get("encoding-type"),
+		MaxKeys:           limit,
+		ContinuationToken: q.Get("continuation-token"),
+	}
+
+	last := ""
+	for _, key := range keys {
+		// 分隔符之前相同的键合并为一个前缀，前缀和键按同样的顺序分页
+		name, grouped := key, false
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				name, grouped = key[:len(prefix)+i+len(delimiter)], true
+			}
+		}
+		if name <= after || name == last {
+			continue
+		}
+		if res.KeyCount == limit {
+			res.IsTruncated = true
+			break
+		}
+
+		if grouped {
+			res.CommonPrefixes = append(res.CommonPrefixes, commonPrefix{Prefix: encode(name)})
+		} else {
+			info := byKey[key]
+			res.Contents = append(res.Contents, listObject{
+				Key:          encode(key),
+				LastModified: info.CreatedAt.UTC().Format(timeFormat),
+				ETag:         etag(info),
+				Size:         info.Size,
+				StorageClass: "STANDARD",
+			})
+		}
+		res.KeyCount++
+		last = name
+	}
+	if res.IsTruncated {
+		res.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(last))
+	}
+	writeXML(w, http.StatusOK, res)
+}
+
+// deleteRequest DeleteObjects请求
+type deleteRequest struct {
+	Quiet   bool `xml:"Quiet"`
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+}
+
+// deleteResult DeleteObjects响应
+type deleteResult struct {
+	XMLName xml.Name        `xml:"DeleteResult"`
+	Xmlns   string          `xml:"xmlns,attr"`
+	Deleted []deletedObject `xml:"Deleted"`
+	Errors  []deleteError   `xml:"Error"`
+}
+
+type deletedObject struct {
+	Key string `xml:"Key"`
+}
+
+type deleteError struct {
+	Key     string `xml:"Key"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// deleteObjects 实现DeleteObjects，单个对象删除失败不影响其他对象。请求体与PutObject一样校验Content-MD5和
+// X-Amz-Content-Sha256，删除任何对象之前先读完并校验整个请求体，被篡改的请求不会删除任何对象
+func (h *handler) deleteObjects(w http.ResponseWriter, r *http.Request, cache filecache.Cache) {
+	body, err := verifyBody(http.MaxBytesReader(w, r.Body, 2<<20), r.Header.Get("Content-MD5"), r.Header.Get("X-Amz-Content-Sha256"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "InvalidDigest", err.Error())
+		return
+	}
+	data, err := io.ReadAll(body)
+	if errors.Is(err, errDigestMismatch) {
+		writeError(w, r, http.StatusBadRequest, "BadDigest", err.Error())
+		return
+	}
+	var req deleteRequest
+	if err == nil {
+		err = xml.Unmarshal(data, &req)
+	}
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "MalformedXML", err.Error())
+		return
+	}
+	if len(req.Objects) > maxKeys {
+		writeError(w, r, http.StatusBadRequest, "MalformedXML", "too many objects to delete")
+		return
+	}
+
+	res := &deleteResult{Xmlns: s3Namespace}
+	for _, obj := range req.Objects {
+		err := cache.Delete(r.Context(), obj.Key)
+		if err != nil && !errors.Is(err, filecache.ErrNotFound) {
+			res.Errors = append(res.Errors, deleteError{Key: obj.Key, Code: "InternalError", Message: err.Error()})
+			continue
+		}
+		if !req.Quiet {
+			res.Deleted = append(res.Deleted, deletedObject{Key: obj.Key})
+		}
+	}
+	writeXML(w, http.StatusOK, res)
+}
+
+// listBucketsResult ListBuckets响应
+type listBucketsResult struct {
+	XMLName xml.Name `xml:"ListAllMyBucketsResult"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Owner   struct {
+		ID          string `xml:"ID"`
+		DisplayName string `xml:"DisplayName"`
+	} `xml:"Owner"`
+	Buckets []bucketEntry `xml:"Buckets>Bucket"`
+}
+
+type bucketEntry struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+// listBuckets 实现ListBuckets，只列出Options.Buckets中配置的存储桶
+func (h *handler) listBuckets(w http.ResponseWriter, r *http.Request) {
+	res := &listBucketsResult{Xmlns: s3Namespace}
+	res.Owner.ID = "edgeorigin"
+	res.Owner.DisplayName = "edgeorigin"
+	created := time.Unix(0, 0).UTC().Format(timeFormat)
+	for _, bucket := range h.opts.Buckets {
+		res.Buckets = append(res.Buckets, bucketEntry{Name: bucket, CreationDate: created})
+	}
+	writeXML(w, http.StatusOK, res)
+}
@@ -0,0 +1,274 @@
+package s3api
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/internal/s3client"
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// 与filecache.NewS3Cache使用的对象元数据一致，S3缓存可以直接使用本接口作为后端
+const (
+	metaCreatedAt = "X-Amz-Meta-Created-At"
+	metaExpiresAt = "X-Amz-Meta-Expires-At"
+)
+
+// errDigestMismatch 请求体与Content-MD5或X-Amz-Content-Sha256不一致
+var errDigestMismatch = errors.New("content digest mismatch")
+
+// etag 根据创建时间和大小生成ETag
+// 带"-"的ETag与分段上传的格式一致，客户端不会把它当作内容的MD5校验
+func etag(info *filecache.FileInfo) string {
+	return fmt.Sprintf(`"%x-%d"`, info.CreatedAt.UnixNano(), info.Size)
+}
+
+// getObject 实现GetObject和HeadObject，支持单个Range和条件请求
+func (h *handler) getObject(w http.ResponseWriter, r *http.Request, cache filecache.Cache, key string) {
+	reader, info, err := cache.Get(r.Context(), key)
+	if err != nil {
+		writeCacheError(w, r, err)
+		return
+	}
+	defer reader.Close()
+
+	header := w.Header()
+	header.Set("ETag", etag(info))
+	header.Set("Last-Modified", info.CreatedAt.UTC().Format(http.TimeFormat))
+	header.Set(metaCreatedAt, info.CreatedAt.UTC().Format(time.RFC3339Nano))
+	header.Set(metaExpiresAt, info.ExpiresAt.UTC().Format(time.RFC3339Nano))
+	header.Set("Accept-Ranges", "bytes")
+
+	if status := checkPreconditions(r, info); status != 0 {
+		if status == http.StatusPreconditionFailed {
+			writeError(w, r, status, "PreconditionFailed", "at least one of the preconditions did not hold")
+			return
+		}
+		w.WriteHeader(status)
+		return
+	}
+
+	header.Set("Content-Type", info.MimeType)
+	start, length := int64(0), info.Size
+	status := http.StatusOK
+	if spec := r.Header.Get("Range"); spec != "" {
+		var ok bool
+		start, length, ok = parseRange(spec, info.Size)
+		if !ok {
+			header.Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+			writeError(w, r, http.StatusRequestedRangeNotSatisfiable, "InvalidRange", "the requested range is not satisfiable")
+			return
+		}
+		if start != 0 || length != info.Size {
+			header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+length-1, info.Size))
+			status = http.StatusPartialContent
+		}
+	}
+	header.Set("Content-Length", strconv.FormatInt(length, 10))
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	if start > 0 {
+		if seeker, ok := reader.(io.Seeker); ok {
+			_, err = seeker.Seek(start, io.SeekStart)
+		} else {
+			_, err = io.CopyN(io.Discard, reader, start)
+		}
+		if err != nil {
+			return
+		}
+	}
+	io.CopyN(w, reader, length)
+}
+
+// checkPreconditions 检查If-Match、If-None-Match和If-Modified-Since，条件不满足时返回状态码
+func checkPreconditions(r *http.Request, info *filecache.FileInfo) int {
+	tag := etag(info)
+	if match := r.Header.Get("If-Match"); match != "" && match != "*" && !containsETag(match, tag) {
+		return http.StatusPreconditionFailed
+	}
+	if noneMatch := r.Header.Get("If-None-Match"); noneMatch != "" {
+		if noneMatch == "*" || containsETag(noneMatch, tag) {
+			return http.StatusNotModified
+		}
+		return 0
+	}
+	if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil &&
+		!info.CreatedAt.Truncate(time.Second).After(since) {
+		return http.StatusNotModified
+	}
+	return 0
+}
+
+// containsETag 检查逗号分隔的ETag列表中是否包含tag，忽略弱校验前缀
+func containsETag(list, tag string) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRange 解析单个字节范围，多个范围和格式错误的范围按整个文件处理
+func parseRange(spec string, size int64) (int64, int64, bool) {
+	spec, ok := strings.CutPrefix(spec, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, size, true
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, size, true
+	}
+
+	if first == "" {
+		// 后缀范围：最后n个字节
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, size, true
+		}
+		if n == 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, n, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, size, true
+	}
+	if start >= size {
+		return 0, 0, false
+	}
+	end := size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return 0, size, true
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end - start + 1, true
+}
+
+// putObject 实现PutObject，TTL依次取X-Amz-Meta-Expires-At、Cache-Control的max-age和Expires，都没有时使用缓存的默认TTL
+func (h *handler) putObject(w http.ResponseWriter, r *http.Request, cache filecache.Cache, key string) {
+	if r.Header.Get("X-Amz-Copy-Source") != "" {
+		writeError(w, r, http.StatusNotImplemented, "NotImplemented", "copy object is not supported")
+		return
+	}
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if strings.HasPrefix(payloadHash, "STREAMING-") {
+		writeError(w, r, http.StatusNotImplemented, "NotImplemented", "chunked payload signing is not supported")
+		return
+	}
+
+	body, err := verifyBody(r.Body, r.Header.Get("Content-MD5"), payloadHash)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "InvalidDigest", err.Error())
+		return
+	}
+	mimeType := r.Header.Get("Content-Type")
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	if err := cache.Set(r.Context(), key, body, mimeType, ttlFromHeader(r.Header, h.now())); err != nil {
+		writeCacheError(w, r, err)
+		return
+	}
+	info, err := cache.GetInfo(r.Context(), key)
+	if err != nil {
+		writeCacheError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(info))
+	w.WriteHeader(http.StatusOK)
+}
+
+// ttlFromHeader 从请求头解析TTL，没有指定或已经过期时返回0
+func ttlFromHeader(header http.Header, now time.Time) time.Duration {
+	if t, err := time.Parse(time.RFC3339Nano, header.Get(metaExpiresAt)); err == nil {
+		return positive(t.Sub(now))
+	}
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(directive), "max-age="); ok {
+			if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return positive(time.Duration(seconds) * time.Second)
+			}
+		}
+	}
+	if t, err := http.ParseTime(header.Get("Expires")); err == nil {
+		return positive(t.Sub(now))
+	}
+	return 0
+}
+
+// positive 把非正数的TTL转换为0
+func positive(ttl time.Duration) time.Duration {
+	if ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+// verifyBody 返回读完时校验Content-MD5和X-Amz-Content-Sha256的请求体
+func verifyBody(body io.Reader, contentMD5, payloadHash string) (io.Reader, error) {
+	if contentMD5 != "" {
+		sum, err := base64.StdEncoding.DecodeString(contentMD5)
+		if err != nil || len(sum) != md5.Size {
+			return nil, fmt.Errorf("invalid content-md5 %q", contentMD5)
+		}
+		body = &digestReader{r: body, hash: md5.New(), sum: sum}
+	}
+	if payloadHash != "" && payloadHash != s3client.UnsignedPayload {
+		sum, err := hex.DecodeString(payloadHash)
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("invalid x-amz-content-sha256 %q", payloadHash)
+		}
+		body = &digestReader{r: body, hash: sha256.New(), sum: sum}
+	}
+	return body, nil
+}
+
+// digestReader 读到EOF时校验内容摘要，不一致时返回errDigestMismatch使写入失败
+type digestReader struct {
+	r    io.Reader
+	hash hash.Hash
+	sum  []byte
+}
+
+func (d *digestReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.hash.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(d.hash.Sum(nil), d.sum) {
+		return n, errDigestMismatch
+	}
+	return n, err
+}
+
+// deleteObject 实现DeleteObject，对象不存在时同样返回成功
+func (h *handler) deleteObject(w http.ResponseWriter, r *http.Request, cache filecache.Cache, key string) {
+	if err := cache.Delete(r.Context(), key); err != nil && !errors.Is(err, filecache.ErrNotFound) {
+		writeCacheError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,226 @@
+// Package tlsutil 为gRPC、管理接口、S3接口和复制等所有网络接口提供统一的TLS和双向TLS配置，
+// 证书文件更新后新的连接自动使用新证书，不需要重启
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// ErrPeerNotAllowed 对端证书不在允许的列表中
+var ErrPeerNotAllowed = errors.New("peer certificate is not allowed")
+
+// Config TLS配置
+type Config struct {
+	CertFile     string   `json:"cert_file"`      // 证书文件（PEM），服务端必填；客户端设置后用于双向TLS
+	KeyFile      string   `json:"key_file"`       // 私钥文件（PEM）
+	ClientCAFile string   `json:"client_ca_file"` // 服务端校验客户端证书的CA，设置后要求客户端提供证书（双向TLS）
+	CAFile       string   `json:"ca_file"`        // 客户端校验服务端证书的CA，为空时使用系统根证书
+	ServerName   string   `json:"server_name"`    // 客户端校验的服务端名称，为空时使用连接地址中的主机名
+	MinVersion   string   `json:"min_version"`    // 最低TLS版本："1.2"或"1.3"，默认1.2
+	NextProtos   []string `json:"next_protos"`    // ALPN协议列表，例如 h2、http/1.1
+	AllowedPeers []string `json:"allowed_peers"`  // 双向TLS时允许的客户端证书名称（CN或DNS SAN），为空时允许CA签发的所有证书
+}
+
+// ServerConfig 返回服务端TLS配置，可用于tls.Listen和http.Server
+func (c *Config) ServerConfig() (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, fmt.Errorf("tls server requires cert_file and key_file")
+	}
+	minVersion, err := parseVersion(c.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	loader, err := newCertLoader(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		MinVersion: minVersion,
+		NextProtos: c.NextProtos,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return loader.certificate()
+		},
+	}
+	if c.ClientCAFile != "" {
+		pool, err := loadPool(c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	} else if len(c.AllowedPeers) > 0 {
+		return nil, fmt.Errorf("allowed_peers requires client_ca_file")
+	}
+	if len(c.AllowedPeers) > 0 {
+		allowed := make(map[string]bool, len(c.AllowedPeers))
+		for _, name := range c.AllowedPeers {
+			allowed[name] = true
+		}
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyPeer(cs, allowed)
+		}
+	}
+	return cfg, nil
+}
+
+// ClientConfig 返回客户端TLS配置，设置了CertFile时向服务端出示证书
+func (c *Config) ClientConfig() (*tls.Config, error) {
+	minVersion, err := parseVersion(c.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		MinVersion: minVersion,
+		NextProtos: c.NextProtos,
+		ServerName: c.ServerName,
+	}
+	if c.CAFile != "" {
+		if cfg.RootCAs, err = loadPool(c.CAFile); err != nil {
+			return nil, err
+		}
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		loader, err := newCertLoader(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return loader.certificate()
+		}
+	}
+	return cfg, nil
+}
+
+// Listen 在addr上监听TCP连接，cfg不为空时在连接上完成TLS握手，用于管理接口等HTTP服务：http.Serve(lis, handler)
+func Listen(addr string, cfg *Config) (net.Listener, error) {
+	if cfg == nil {
+		return net.Listen("tcp", addr)
+	}
+	tlsConfig, err := cfg.ServerConfig()
+	if err != nil {
+		return nil, err
+	}
+	return tls.Listen("tcp", addr, tlsConfig)
+}
+
+// ServerCredentials 返回gRPC服务端的传输凭据：grpc.NewServer(grpc.Creds(creds))
+func (c *Config) ServerCredentials() (credentials.TransportCredentials, error) {
+	cfg, err := c.ServerConfig()
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(cfg), nil
+}
+
+// ClientCredentials 返回gRPC客户端的传输凭据：grpc.WithTransportCredentials(creds)
+func (c *Config) ClientCredentials() (credentials.TransportCredentials, error) {
+	cfg, err := c.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(cfg), nil
+}
+
+// parseVersion 解析最低TLS版本
+func parseVersion(v string) (uint16, error) {
+	switch v {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported tls min_version %q", v)
+	}
+}
+
+// loadPool 从PEM文件加载CA证书
+func loadPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ca file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", file)
+	}
+	return pool, nil
+}
+
+// verifyPeer 检查对端证书的CN或DNS SAN是否在允许的列表中
+func verifyPeer(cs tls.ConnectionState, allowed map[string]bool) error {
+	if len(cs.PeerCertificates) == 0 {
+		return ErrPeerNotAllowed
+	}
+	leaf := cs.PeerCertificates[0]
+	if allowed[leaf.Subject.CommonName] {
+		return nil
+	}
+	for _, name := range leaf.DNSNames {
+		if allowed[name] {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrPeerNotAllowed, leaf.Subject.CommonName)
+}
+
+// certCheckInterval 检查证书文件是否更新的最短间隔
+const certCheckInterval = 10 * time.Second
+
+// certLoader 加载证书，文件修改时间变化后重新加载，用于证书轮换
+type certLoader struct {
+	certFile, keyFile string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// newCertLoader 加载证书，文件不存在或格式错误时返回错误
+func newCertLoader(certFile, keyFile string) (*certLoader, error) {
+	l := &certLoader{certFile: certFile, keyFile: keyFile}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// load 读取证书和私钥
+func (l *certLoader) load() error {
+	fi, err := os.Stat(l.certFile)
+	if err != nil {
+		return fmt.Errorf("failed to stat certificate: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate: %w", err)
+	}
+	l.cert, l.modTime, l.checkedAt = &cert, fi.ModTime(), time.Now()
+	return nil
+}
+
+// certificate 返回当前证书，重新加载失败时继续使用旧证书
+func (l *certLoader) certificate() (*tls.Certificate, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if time.Since(l.checkedAt) >= certCheckInterval {
+		l.checkedAt = time.Now()
+		if fi, err := os.Stat(l.certFile); err == nil && !fi.ModTime().Equal(l.modTime) {
+			// 证书和私钥可能不是同时写入的，加载失败时下次再试
+			l.load()
+		}
+	}
+	return l.cert, nil
+}
@@ -0,0 +1,128 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/internal/tlstest"
+)
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := tlstest.NewCA(t, dir, "edge-ca")
+	serverCert, serverKey := ca.Issue("edge-1", 2, x509.ExtKeyUsageServerAuth)
+	peerCert, peerKey := ca.Issue("edge-2", 3, x509.ExtKeyUsageClientAuth)
+	otherCert, otherKey := ca.Issue("intruder", 4, x509.ExtKeyUsageClientAuth)
+
+	server := &Config{
+		CertFile:     serverCert,
+		KeyFile:      serverKey,
+		ClientCAFile: ca.File,
+		AllowedPeers: []string{"edge-2"},
+	}
+	client := func(cert, key string) *Config {
+		return &Config{CertFile: cert, KeyFile: key, CAFile: ca.File, ServerName: "edge-1"}
+	}
+
+	t.Run("HTTP", func(t *testing.T) {
+		lis, err := Listen("127.0.0.1:0", server)
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+		})}
+		go srv.Serve(lis)
+		defer srv.Close()
+
+		get := func(cfg *Config) error {
+			tlsCfg, err := cfg.ClientConfig()
+			if err != nil {
+				t.Fatalf("Failed to create client config: %v", err)
+			}
+			c := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsCfg}}
+			resp, err := c.Get("https://" + lis.Addr().String() + "/")
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			return nil
+		}
+		if err := get(client(peerCert, peerKey)); err != nil {
+			t.Errorf("Expected allowed peer to connect, got %v", err)
+		}
+		if err := get(client(otherCert, otherKey)); err == nil {
+			t.Error("Expected peer outside allowed_peers to be rejected")
+		}
+	})
+}
+
+func TestConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	ca := tlstest.NewCA(t, dir, "edge-ca")
+	cert, key := ca.Issue("edge-1", 2, x509.ExtKeyUsageServerAuth)
+
+	tests := map[string]*Config{
+		"MissingCert":     {},
+		"BadVersion":      {CertFile: cert, KeyFile: key, MinVersion: "1.1"},
+		"PeersWithoutCA":  {CertFile: cert, KeyFile: key, AllowedPeers: []string{"edge-2"}},
+		"MissingClientCA": {CertFile: cert, KeyFile: key, ClientCAFile: filepath.Join(dir, "missing.pem")},
+	}
+	for name, cfg := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := cfg.ServerConfig(); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+
+	cfg, err := (&Config{CertFile: cert, KeyFile: key, MinVersion: "1.3", NextProtos: []string{"h2"}}).ServerConfig()
+	if err != nil {
+		t.Fatalf("Failed to create config: %v", err)
+	}
+	if cfg.MinVersion != tls.VersionTLS13 || cfg.NextProtos[0] != "h2" || cfg.ClientAuth != tls.NoClientCert {
+		t.Errorf("Unexpected config: min %x, protos %v, client auth %v", cfg.MinVersion, cfg.NextProtos, cfg.ClientAuth)
+	}
+}
+
+func TestCertificateRotation(t *testing.T) {
+	dir := t.TempDir()
+	ca := tlstest.NewCA(t, dir, "edge-ca")
+	certFile, keyFile := ca.Issue("edge-1", 2, x509.ExtKeyUsageServerAuth)
+
+	loader, err := newCertLoader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+	serial := func() int64 {
+		cert, _ := loader.certificate()
+		leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+		return leaf.SerialNumber.Int64()
+	}
+
+	ca.Issue("edge-1", 5, x509.ExtKeyUsageServerAuth)
+	os.Chtimes(certFile, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+	if got := serial(); got != 2 {
+		t.Errorf("Expected old certificate within check interval, got serial %d", got)
+	}
+	loader.checkedAt = time.Time{}
+	if got := serial(); got != 5 {
+		t.Errorf("Expected rotated certificate, got serial %d", got)
+	}
+
+	// 写入损坏的证书时继续使用旧证书
+	os.WriteFile(certFile, []byte("garbage"), 0600)
+	os.Chtimes(certFile, time.Now().Add(2*time.Minute), time.Now().Add(2*time.Minute))
+	loader.checkedAt = time.Time{}
+	if got := serial(); got != 5 {
+		t.Errorf("Expected previous certificate after failed reload, got serial %d", got)
+	}
+	if _, err := newCertLoader(certFile, keyFile); err == nil {
+		t.Error("Expected error for corrupt certificate")
+	}
+}
@@ -0,0 +1,241 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	xwebdav "golang.org/x/net/webdav"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// fileInfo 实现os.FileInfo，文件还实现webdav.ContentTyper
+type fileInfo struct {
+	name     string
+	size     int64
+	modTime  time.Time
+	mimeType string
+	dir      bool
+}
+
+// newFileInfo 从缓存文件信息创建
+func newFileInfo(info *filecache.FileInfo) *fileInfo {
+	return &fileInfo{
+		name:     path.Base(info.Key),
+		size:     info.Size,
+		modTime:  info.CreatedAt,
+		mimeType: info.MimeType,
+	}
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.dir }
+func (fi *fileInfo) Sys() interface{}   { return nil }
+
+func (fi *fileInfo) Mode() os.FileMode {
+	if fi.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+// ContentType 返回缓存中保存的文件类型，PROPFIND不需要读取文件内容
+func (fi *fileInfo) ContentType(ctx context.Context) (string, error) {
+	if fi.dir || fi.mimeType == "" {
+		return "", xwebdav.ErrNotImplemented
+	}
+	return fi.mimeType, nil
+}
+
+// file 只读的文件或目录，第一次读取时才从缓存获取内容，PROPFIND只需要文件信息
+type file struct {
+	fs   *fileSystem
+	ctx  context.Context
+	key  string
+	info *filecache.FileInfo // 目录为nil
+
+	pos       int64
+	reader    io.ReadCloser
+	readerPos int64
+
+	entries []os.FileInfo // Readdir尚未返回的子项
+	listed  bool
+}
+
+// Read 从当前位置读取，Seek后重新获取文件
+func (f *file) Read(p []byte) (int, error) {
+	if f.info == nil {
+		return 0, os.ErrInvalid
+	}
+	if f.pos >= f.info.Size {
+		return 0, io.EOF
+	}
+	if f.reader == nil || f.readerPos != f.pos {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.reader.Read(p)
+	f.pos += int64(n)
+	f.readerPos += int64(n)
+	return n, err
+}
+
+// open 从缓存获取文件并定位到当前位置
+func (f *file) open() error {
+	if f.reader != nil {
+		f.reader.Close()
+		f.reader = nil
+	}
+	reader, _, err := f.fs.cache.Get(f.ctx, f.key)
+	if err != nil {
+		return err
+	}
+	if seeker, ok := reader.(io.Seeker); ok {
+		_, err = seeker.Seek(f.pos, io.SeekStart)
+	} else {
+		_, err = io.CopyN(io.Discard, reader, f.pos)
+	}
+	if err != nil {
+		reader.Close()
+		return err
+	}
+	f.reader, f.readerPos = reader, f.pos
+	return nil
+}
+
+// Seek 只记录位置，下次读取时生效
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if f.info == nil {
+		return 0, os.ErrInvalid
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += f.info.Size
+	default:
+		return 0, os.ErrInvalid
+	}
+	if offset < 0 {
+		return 0, os.ErrInvalid
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+// Readdir 返回目录下的子项，count<=0时返回全部
+func (f *file) Readdir(count int) ([]os.FileInfo, error) {
+	if f.info != nil {
+		return nil, os.ErrInvalid
+	}
+	if !f.listed {
+		l, err := f.fs.listing(f.ctx)
+		if err != nil {
+			return nil, err
+		}
+		f.entries = l.children(f.key, f.fs.explicitDirs())
+		f.listed = true
+	}
+
+	if count <= 0 {
+		entries := f.entries
+		f.entries = nil
+		return entries, nil
+	}
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(f.entries) {
+		count = len(f.entries)
+	}
+	entries := f.entries[:count]
+	f.entries = f.entries[count:]
+	return entries, nil
+}
+
+// Stat 返回文件信息，目录的修改时间为其中最新文件的创建时间
+func (f *file) Stat() (os.FileInfo, error) {
+	if f.info != nil {
+		return newFileInfo(f.info), nil
+	}
+	fi := &fileInfo{name: path.Base("/" + f.key), dir: true}
+	if f.key != "" {
+		if l, err := f.fs.listing(f.ctx); err == nil {
+			prefix := childPrefix(f.key)
+			for key, info := range l.files {
+				if strings.HasPrefix(key, prefix) && info.CreatedAt.After(fi.modTime) {
+					fi.modTime = info.CreatedAt
+				}
+			}
+		}
+	}
+	return fi, nil
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+func (f *file) Close() error {
+	if f.reader != nil {
+		return f.reader.Close()
+	}
+	return nil
+}
+
+// writer 写入中的文件，关闭时等待缓存写入完成
+type writer struct {
+	fs       *fileSystem
+	ctx      context.Context
+	key      string
+	mimeType string
+	pw       *io.PipeWriter
+	done     chan error
+	size     int64
+	closed   bool
+	err      error
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	n, err := w.pw.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close 结束写入，返回缓存写入的结果
+func (w *writer) Close() error {
+	if w.closed {
+		return w.err
+	}
+	w.closed = true
+	w.pw.Close()
+	w.err = <-w.done
+	w.fs.invalidate(w.ctx)
+	return w.err
+}
+
+// Stat 返回已写入的大小
+func (w *writer) Stat() (os.FileInfo, error) {
+	return &fileInfo{name: path.Base(w.key), size: w.size, modTime: time.Now(), mimeType: w.mimeType}, nil
+}
+
+func (w *writer) Read(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+func (w *writer) Seek(offset int64, whence int) (int64, error) {
+	return 0, errors.New("seek is not supported while writing")
+}
+
+func (w *writer) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, os.ErrInvalid
+}
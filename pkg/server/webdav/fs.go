@@ -0,0 +1,371 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	xwebdav "golang.org/x/net/webdav"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// requestKey 请求状态在context中的键
+type requestKey struct{}
+
+// requestState 单个请求内共享的状态
+type requestState struct {
+	mimeType string // 请求的Content-Type，用作上传文件的类型
+
+	mu      sync.Mutex
+	listing *listing // 请求内缓存的文件列表，修改缓存后失效
+}
+
+// keyOf 把WebDAV路径转换为缓存键，根目录为空字符串
+func keyOf(name string) string {
+	return strings.Trim(path.Clean("/"+name), "/")
+}
+
+// childPrefix 返回目录下缓存键的前缀
+func childPrefix(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	return dir + "/"
+}
+
+// listing 某一时刻缓存中未过期文件的快照
+type listing struct {
+	files map[string]*filecache.FileInfo
+}
+
+// hasDir 返回是否有文件位于dir目录下
+func (l *listing) hasDir(dir string) bool {
+	prefix := childPrefix(dir)
+	for key := range l.files {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// children 返回dir目录下的直接子项，子目录的修改时间为其中最新文件的创建时间
+func (l *listing) children(dir string, dirs map[string]bool) []os.FileInfo {
+	prefix := childPrefix(dir)
+	var entries []os.FileInfo
+	subdirs := make(map[string]*fileInfo)
+	for key, info := range l.files {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			name := rest[:i]
+			sub, ok := subdirs[name]
+			if !ok {
+				sub = &fileInfo{name: name, dir: true}
+				subdirs[name] = sub
+			}
+			if info.CreatedAt.After(sub.modTime) {
+				sub.modTime = info.CreatedAt
+			}
+			continue
+		}
+		entries = append(entries, newFileInfo(info))
+	}
+	for d := range dirs {
+		if rest, ok := strings.CutPrefix(d, prefix); ok && !strings.Contains(rest, "/") {
+			if _, ok := subdirs[rest]; !ok {
+				subdirs[rest] = &fileInfo{name: rest, dir: true}
+			}
+		}
+	}
+	for _, sub := range subdirs {
+		entries = append(entries, sub)
+	}
+	return entries
+}
+
+// fileSystem 基于缓存实现webdav.FileSystem
+type fileSystem struct {
+	cache filecache.Cache
+	ttl   time.Duration
+
+	mu   sync.Mutex
+	dirs map[string]bool // MKCOL创建、还没有文件的空目录
+}
+
+// newFileSystem 创建基于缓存的文件系统
+func newFileSystem(cache filecache.Cache, ttl time.Duration) *fileSystem {
+	return &fileSystem{cache: cache, ttl: ttl, dirs: make(map[string]bool)}
+}
+
+// listing 返回请求内共享的文件列表，不在请求内调用时每次重新列出
+func (fs *fileSystem) listing(ctx context.Context) (*listing, error) {
+	state, _ := ctx.Value(requestKey{}).(*requestState)
+	if state != nil {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		if state.listing != nil {
+			return state.listing, nil
+		}
+	}
+
+	files, err := fs.cache.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	l := &listing{files: make(map[string]*filecache.FileInfo, len(files))}
+	now := time.Now()
+	for _, info := range files {
+		if !now.After(info.ExpiresAt) {
+			l.files[info.Key] = info
+		}
+	}
+	if state != nil {
+		state.listing = l
+	}
+	return l, nil
+}
+
+// invalidate 修改缓存后丢弃请求内的文件列表
+func (fs *fileSystem) invalidate(ctx context.Context) {
+	if state, ok := ctx.Value(requestKey{}).(*requestState); ok {
+		state.mu.Lock()
+		state.listing = nil
+		state.mu.Unlock()
+	}
+}
+
+// explicitDirs 返回空目录的副本
+func (fs *fileSystem) explicitDirs() map[string]bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	dirs := make(map[string]bool, len(fs.dirs))
+	for d := range fs.dirs {
+		dirs[d] = true
+	}
+	return dirs
+}
+
+// isDir 返回key是否是目录：根目录、空目录或有文件位于其下
+func (fs *fileSystem) isDir(ctx context.Context, key string) (bool, error) {
+	if key == "" {
+		return true, nil
+	}
+	fs.mu.Lock()
+	explicit := fs.dirs[key]
+	fs.mu.Unlock()
+	if explicit {
+		return true, nil
+	}
+	l, err := fs.listing(ctx)
+	if err != nil {
+		return false, err
+	}
+	return l.hasDir(key), nil
+}
+
+// Mkdir 创建空目录，目录只保存在内存中
+func (fs *fileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	key := keyOf(name)
+	if _, err := fs.Stat(ctx, name); err == nil {
+		return os.ErrExist
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if parent := path.Dir(key); parent != "." {
+		if ok, err := fs.isDir(ctx, parent); err != nil {
+			return err
+		} else if !ok {
+			return os.ErrNotExist
+		}
+	}
+
+	fs.mu.Lock()
+	fs.dirs[key] = true
+	fs.mu.Unlock()
+	return nil
+}
+
+// OpenFile 打开文件或目录；带写入标志时覆盖文件，关闭时写入缓存
+func (fs *fileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (xwebdav.File, error) {
+	key := keyOf(name)
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		if key == "" {
+			return nil, os.ErrInvalid
+		}
+		if dir, err := fs.isDir(ctx, key); err != nil {
+			return nil, err
+		} else if dir {
+			return nil, os.ErrInvalid
+		}
+		return fs.create(ctx, key), nil
+	}
+
+	info, err := fs.cache.GetInfo(ctx, key)
+	if err == nil {
+		return &file{fs: fs, ctx: ctx, key: key, info: info}, nil
+	}
+	if key != "" && !errors.Is(err, filecache.ErrNotFound) {
+		return nil, err
+	}
+	if dir, err := fs.isDir(ctx, key); err != nil {
+		return nil, err
+	} else if !dir {
+		return nil, os.ErrNotExist
+	}
+	return &file{fs: fs, ctx: ctx, key: key}, nil
+}
+
+// create 返回写入key的文件，写入的数据通过管道交给缓存
+func (fs *fileSystem) create(ctx context.Context, key string) *writer {
+	mimeType := ""
+	if state, ok := ctx.Value(requestKey{}).(*requestState); ok {
+		mimeType = state.mimeType
+	}
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(path.Ext(key))
+	}
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	pr, pw := io.Pipe()
+	w := &writer{fs: fs, ctx: ctx, key: key, mimeType: mimeType, pw: pw, done: make(chan error, 1)}
+	go func() {
+		err := fs.cache.Set(ctx, key, pr, mimeType, fs.ttl)
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+	return w
+}
+
+// RemoveAll 删除文件或目录下的所有文件
+func (fs *fileSystem) RemoveAll(ctx context.Context, name string) error {
+	key := keyOf(name)
+	if key == "" {
+		return os.ErrInvalid
+	}
+	defer fs.invalidate(ctx)
+
+	removed := false
+	if err := fs.cache.Delete(ctx, key); err == nil {
+		removed = true
+	} else if !errors.Is(err, filecache.ErrNotFound) {
+		return err
+	}
+
+	l, err := fs.listing(ctx)
+	if err != nil {
+		return err
+	}
+	prefix := childPrefix(key)
+	for k := range l.files {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		if err := fs.cache.Delete(ctx, k); err != nil && !errors.Is(err, filecache.ErrNotFound) {
+			return fmt.Errorf("failed to delete %s: %w", k, err)
+		}
+		removed = true
+	}
+
+	fs.mu.Lock()
+	for d := range fs.dirs {
+		if d == key || strings.HasPrefix(d, prefix) {
+			delete(fs.dirs, d)
+			removed = true
+		}
+	}
+	fs.mu.Unlock()
+
+	if !removed {
+		return os.ErrNotExist
+	}
+	return nil
+}
+
+// Rename 移动文件或目录，文件保留类型和剩余的TTL
+func (fs *fileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	oldKey, newKey := keyOf(oldName), keyOf(newName)
+	if oldKey == "" || newKey == "" {
+		return os.ErrInvalid
+	}
+	defer fs.invalidate(ctx)
+
+	if err := fs.move(ctx, oldKey, newKey); err == nil {
+		return nil
+	} else if !errors.Is(err, filecache.ErrNotFound) {
+		return err
+	}
+
+	l, err := fs.listing(ctx)
+	if err != nil {
+		return err
+	}
+	oldPrefix, moved := childPrefix(oldKey), false
+	for k := range l.files {
+		if rest, ok := strings.CutPrefix(k, oldPrefix); ok {
+			if err := fs.move(ctx, k, childPrefix(newKey)+rest); err != nil && !errors.Is(err, filecache.ErrNotFound) {
+				return err
+			}
+			moved = true
+		}
+	}
+
+	fs.mu.Lock()
+	for d := range fs.dirs {
+		if d == oldKey {
+			delete(fs.dirs, d)
+			fs.dirs[newKey] = true
+			moved = true
+		} else if rest, ok := strings.CutPrefix(d, oldPrefix); ok {
+			delete(fs.dirs, d)
+			fs.dirs[childPrefix(newKey)+rest] = true
+		}
+	}
+	fs.mu.Unlock()
+
+	if !moved {
+		return os.ErrNotExist
+	}
+	return nil
+}
+
+// move 把单个文件复制到新键后删除旧键
+func (fs *fileSystem) move(ctx context.Context, oldKey, newKey string) error {
+	reader, info, err := fs.cache.Get(ctx, oldKey)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	ttl, ok := info.RemainingTTL()
+	if !ok {
+		ttl = fs.ttl
+	}
+	if err := fs.cache.Set(ctx, newKey, reader, info.MimeType, ttl); err != nil {
+		return fmt.Errorf("failed to copy %s: %w", oldKey, err)
+	}
+	return fs.cache.Delete(ctx, oldKey)
+}
+
+// Stat 返回文件或目录的信息
+func (fs *fileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	f, err := fs.OpenFile(ctx, name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
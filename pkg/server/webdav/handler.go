@@ -0,0 +1,116 @@
+// Package webdav 把缓存以WebDAV目录树的形式对外提供，缓存键中的"/"对应目录层级，
+// 可以在Finder或资源管理器中挂载边缘缓存，拖拽文件进行预热
+package webdav
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	xwebdav "golang.org/x/net/webdav"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// Options WebDAV选项
+type Options struct {
+	// Prefix 挂载的URL前缀，例如 /dav
+	Prefix string
+
+	// TTL 上传文件的TTL，为0时使用缓存的默认TTL
+	TTL time.Duration
+
+	// ReadOnly 只允许读取和列出文件
+	ReadOnly bool
+
+	// Users 基本认证的用户名和密码，为空时必须设置AllowAnonymous
+	Users map[string]string
+
+	// AllowAnonymous 为true时允许在Users为空时不经认证访问，任何能连接的客户端都可以读写缓存，
+	// 只应在可信网络中使用，只需要公开读取时同时设置ReadOnly；Users不为空时不起作用
+	AllowAnonymous bool
+}
+
+// handler WebDAV接口
+type handler struct {
+	cache filecache.Cache
+	opts  Options
+	dav   *xwebdav.Handler
+}
+
+// NewHandler 返回WebDAV接口，目录是虚拟的，由缓存键的前缀推导出来；
+// 空目录只保存在内存中，写入文件后才会出现在缓存里
+func NewHandler(cache filecache.Cache, opts Options) (http.Handler, error) {
+	if len(opts.Users) == 0 && !opts.AllowAnonymous {
+		return nil, fmt.Errorf("webdav requires users or anonymous access")
+	}
+	return &handler{
+		cache: cache,
+		opts:  opts,
+		dav: &xwebdav.Handler{
+			Prefix:     opts.Prefix,
+			FileSystem: newFileSystem(cache, opts.TTL),
+			LockSystem: xwebdav.NewMemLS(),
+		},
+	}, nil
+}
+
+// ServeHTTP 鉴权后交给WebDAV处理，每个请求共享一份文件列表
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="edgeorigin"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if h.opts.ReadOnly && isWrite(r.Method) {
+		http.Error(w, "read-only", http.StatusForbidden)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), requestKey{}, &requestState{mimeType: r.Header.Get("Content-Type")})
+	r = r.WithContext(ctx)
+
+	// WebDAV根据扩展名推断Content-Type，这里改为使用缓存中保存的类型
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		if key, ok := h.key(r.URL.Path); ok && key != "" {
+			if info, err := h.cache.GetInfo(ctx, key); err == nil && info.MimeType != "" {
+				w.Header().Set("Content-Type", info.MimeType)
+			}
+		}
+	}
+	h.dav.ServeHTTP(w, r)
+}
+
+// authorized 检查基本认证，只有设置了AllowAnonymous时Users才可以为空
+func (h *handler) authorized(r *http.Request) bool {
+	if len(h.opts.Users) == 0 {
+		return h.opts.AllowAnonymous
+	}
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	want, ok := h.opts.Users[user]
+	return ok && subtle.ConstantTimeCompare([]byte(password), []byte(want)) == 1
+}
+
+// key 返回URL路径对应的缓存键
+func (h *handler) key(urlPath string) (string, bool) {
+	name, ok := strings.CutPrefix(urlPath, h.opts.Prefix)
+	if !ok {
+		return "", false
+	}
+	return keyOf(name), true
+}
+
+// isWrite 返回请求方法是否会修改缓存
+func isWrite(method string) bool {
+	switch method {
+	case http.MethodPut, http.MethodDelete, "MKCOL", "COPY", "MOVE", "PROPPATCH":
+		return true
+	}
+	return false
+}
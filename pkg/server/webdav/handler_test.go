@@ -0,0 +1,181 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seraphico/EdgeOrigin/pkg/filecache"
+)
+
+// request 发送带基本认证的请求
+func request(t *testing.T, srv *httptest.Server, method, path, body string, header map[string]string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.SetBasicAuth("designer", "secret")
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// propfind 返回目录下的子项路径，目录以"/"结尾
+func propfind(t *testing.T, srv *httptest.Server, path string) []string {
+	t.Helper()
+
+	resp := request(t, srv, "PROPFIND", path, "", map[string]string{"Depth": "1"})
+	if resp.StatusCode != http.StatusMultiStatus {
+		t.Fatalf("Expected 207, got %d", resp.StatusCode)
+	}
+	var ms struct {
+		Responses []struct {
+			Href string `xml:"href"`
+		} `xml:"response"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		t.Fatalf("Failed to decode multistatus: %v", err)
+	}
+	var hrefs []string
+	for _, r := range ms.Responses {
+		if r.Href != path {
+			hrefs = append(hrefs, r.Href)
+		}
+	}
+	sort.Strings(hrefs)
+	return hrefs
+}
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+
+	handler, err := NewHandler(cache, Options{
+		Prefix: "/dav",
+		TTL:    time.Hour,
+		Users:  map[string]string{"designer": "secret"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/dav/")
+		if err != nil {
+			t.Fatalf("Failed to send request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected 401, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("PutAndGet", func(t *testing.T) {
+		resp := request(t, srv, http.MethodPut, "/dav/css/site.css", "body{}", nil)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d", resp.StatusCode)
+		}
+		info, err := cache.GetInfo(ctx, "css/site.css")
+		if err != nil {
+			t.Fatalf("Expected file in cache: %v", err)
+		}
+		if !strings.HasPrefix(info.MimeType, "text/css") {
+			t.Errorf("Expected type from extension, got %s", info.MimeType)
+		}
+		if ttl := time.Until(info.ExpiresAt); ttl > time.Hour || ttl < 59*time.Minute {
+			t.Errorf("Expected TTL of 1 hour, got %v", ttl)
+		}
+
+		cache.Set(ctx, "img/logo", strings.NewReader("png data"), "image/png", time.Hour)
+		resp = request(t, srv, http.MethodGet, "/dav/img/logo", "", map[string]string{"Range": "bytes=4-"})
+		data, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusPartialContent || string(data) != "data" {
+			t.Errorf("Expected 206 with 'data', got %d with %q", resp.StatusCode, data)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "image/png" {
+			t.Errorf("Expected stored type image/png, got %q", ct)
+		}
+	})
+
+	t.Run("Propfind", func(t *testing.T) {
+		cache.Set(ctx, "img/icons/a.svg", strings.NewReader("<svg/>"), "image/svg+xml", time.Hour)
+		if resp := request(t, srv, "MKCOL", "/dav/fonts", "", nil); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d", resp.StatusCode)
+		}
+
+		if got := strings.Join(propfind(t, srv, "/dav/"), ","); got != "/dav/css/,/dav/fonts/,/dav/img/" {
+			t.Errorf("Unexpected root listing: %s", got)
+		}
+		if got := strings.Join(propfind(t, srv, "/dav/img/"), ","); got != "/dav/img/icons/,/dav/img/logo" {
+			t.Errorf("Unexpected img listing: %s", got)
+		}
+		if resp := request(t, srv, "PROPFIND", "/dav/missing/", "", map[string]string{"Depth": "0"}); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected 404, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("MoveAndDelete", func(t *testing.T) {
+		resp := request(t, srv, "MOVE", "/dav/img/", "", map[string]string{"Destination": srv.URL + "/dav/images/"})
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d", resp.StatusCode)
+		}
+		info, err := cache.GetInfo(ctx, "images/icons/a.svg")
+		if err != nil {
+			t.Fatalf("Expected moved file: %v", err)
+		}
+		if info.MimeType != "image/svg+xml" {
+			t.Errorf("Expected type to be kept, got %s", info.MimeType)
+		}
+		if exists, _ := cache.Exists(ctx, "img/logo"); exists {
+			t.Error("Expected old key to be removed")
+		}
+
+		if resp := request(t, srv, http.MethodDelete, "/dav/images/", "", nil); resp.StatusCode != http.StatusNoContent {
+			t.Errorf("Expected 204, got %d", resp.StatusCode)
+		}
+		files, _ := cache.List(ctx)
+		if len(files) != 1 || files[0].Key != "css/site.css" {
+			t.Errorf("Expected only css/site.css to remain, got %d files", len(files))
+		}
+	})
+}
+
+func TestReadOnly(t *testing.T) {
+	cache := filecache.NewMemoryCache(1 << 20)
+	defer cache.Close()
+	cache.Set(context.Background(), "a.txt", strings.NewReader("a"), "text/plain", time.Hour)
+
+	if _, err := NewHandler(cache, Options{ReadOnly: true}); err == nil {
+		t.Error("Expected error for a handler without users or anonymous access")
+	}
+	handler, err := NewHandler(cache, Options{ReadOnly: true, AllowAnonymous: true})
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	if resp := request(t, srv, http.MethodPut, "/b.txt", "b", nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403, got %d", resp.StatusCode)
+	}
+	if resp := request(t, srv, http.MethodGet, "/a.txt", "", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200, got %d", resp.StatusCode)
+	}
+}